@@ -0,0 +1,33 @@
+package main
+
+import (
+	"github.com/FatwaArya/pm-ingest/config"
+	"github.com/FatwaArya/pm-ingest/internal/notify"
+)
+
+// newNotifier creates the whale alert targets configured with ALERT_*; the
+// notifier is empty when none are
+func newNotifier(cfg config.Config) (*notify.Notifier, error) {
+	var targets []notify.Target
+	for _, url := range cfg.AlertWebhookURLs {
+		targets = append(targets, notify.Target{Channel: notify.Webhook{URL: url}, MinNotional: cfg.AlertWebhookMinUSD})
+	}
+	if cfg.AlertDiscordWebhookURL != "" {
+		targets = append(targets, notify.Target{Channel: notify.Discord{WebhookURL: cfg.AlertDiscordWebhookURL}, MinNotional: cfg.AlertDiscordMinUSD})
+	}
+	if cfg.AlertTelegramBotToken != "" {
+		targets = append(targets, notify.Target{
+			Channel:     notify.Telegram{BotToken: cfg.AlertTelegramBotToken, ChatID: cfg.AlertTelegramChatID},
+			MinNotional: cfg.AlertTelegramMinUSD,
+		})
+	}
+
+	notifier := notify.New()
+	for _, t := range targets {
+		t.PerMinute = cfg.AlertsPerMinute
+		if err := notifier.Add(t, cfg.AlertTemplate); err != nil {
+			return nil, err
+		}
+	}
+	return notifier, nil
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/FatwaArya/pm-ingest/config"
+	internalqdb "github.com/FatwaArya/pm-ingest/internal"
+	"github.com/FatwaArya/pm-ingest/internal/domain"
+	"github.com/FatwaArya/pm-ingest/internal/events"
+	internalkafka "github.com/FatwaArya/pm-ingest/internal/kafka"
+	"github.com/FatwaArya/pm-ingest/pkg/dataapi"
+)
+
+// backfillPageSize is how many wallets are read from QuestDB per query
+const backfillPageSize = 1000
+
+// runConfidenceBackfill runs `pm-ingest backfill-confidence`: it scores every
+// wallet in the profiles table, writing each score to the confidence history
+// table and publishing it as a wallet.confidence event
+func runConfidenceBackfill(args []string) error {
+	cfg := config.AppConfig
+	fs := flag.NewFlagSet("backfill-confidence", flag.ExitOnError)
+	concurrency := fs.Int("concurrency", 4, "wallets scored concurrently")
+	maxPositions := fs.Int("max-positions", 1000, "closed positions fetched per wallet (0 for all)")
+	skipScored := fs.Bool("skip-scored", true, "skip wallets that already have a confidence score")
+	publish := fs.Bool("publish", true, "publish wallet.confidence events to "+cfg.EventsTopic)
+	fs.Parse(args)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	useDataAPIPolicy()
+
+	queries := internalqdb.NewQueryClient(cfg.QuestDBHTTPAddr())
+	var scored map[string]bool
+	if *skipScored {
+		// The history table doesn't exist until something was scored
+		var err error
+		if scored, err = scoredWallets(ctx, queries); err != nil {
+			log.Printf("Could not list scored wallets, scoring every wallet: %v", err)
+		} else {
+			log.Printf("Skipping %d wallets that already have a score", len(scored))
+		}
+	}
+
+	var emitter events.Emitter = events.LogEmitter{}
+	if *publish {
+		producer, err := internalkafka.NewProducer(strings.TrimSpace(cfg.KafkaBrokers), cfg.KafkaTopic, kafkaProducerSettings())
+		if err != nil {
+			return fmt.Errorf("failed to create kafka producer: %w", err)
+		}
+		defer producer.Close()
+		defer func() {
+			flushCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			if err := producer.Flush(flushCtx); err != nil {
+				log.Printf("Error flushing confidence events: %v", err)
+			}
+		}()
+		emitter = events.NewKafkaEmitter(producer, cfg.EventsTopic)
+	}
+
+	writer, err := newConfidenceWriter(ctx, config.AppConfig.QuestDBConfidenceTable)
+	if err != nil {
+		return fmt.Errorf("failed to create confidence writer: %w", err)
+	}
+	defer writer.Close(context.Background())
+
+	backfill := domain.NewConfidenceBackfill(dataapi.NewClient(), emitter, *concurrency, *maxPositions)
+	backfill.OnScore(writeConfidence(writer))
+
+	wallets := make(chan string)
+	listErr := make(chan error, 1)
+	go func() {
+		defer close(wallets)
+		query := fmt.Sprintf("SELECT DISTINCT address FROM %s ORDER BY address",
+			cfg.QuestDBTablePrefix+cfg.QuestDBProfilesTable)
+		listErr <- queries.Pages(ctx, query, backfillPageSize, func(page *internalqdb.QueryResult) error {
+			for _, row := range page.Rows() {
+				address := row.String("address")
+				if address == "" || scored[strings.ToLower(address)] {
+					continue
+				}
+				select {
+				case wallets <- address:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+			return nil
+		})
+	}()
+
+	start := time.Now()
+	stats := backfill.Run(ctx, wallets)
+	log.Printf("Confidence backfill done in %s: %d wallets, %d scored, %d failed",
+		time.Since(start).Round(time.Second), stats.Wallets, stats.Scored, stats.Failed)
+	if err := <-listErr; err != nil {
+		return fmt.Errorf("failed to list wallets: %w", err)
+	}
+	return nil
+}
+
+// scoredWallets returns the (lowercase) wallets in the confidence history table
+func scoredWallets(ctx context.Context, queries *internalqdb.QueryClient) (map[string]bool, error) {
+	scored := make(map[string]bool)
+	query := fmt.Sprintf("SELECT DISTINCT wallet FROM %s ORDER BY wallet",
+		config.AppConfig.QuestDBTablePrefix+config.AppConfig.QuestDBConfidenceTable)
+	err := queries.Pages(ctx, query, backfillPageSize, func(page *internalqdb.QueryResult) error {
+		for _, row := range page.Rows() {
+			scored[strings.ToLower(row.String("wallet"))] = true
+		}
+		return nil
+	})
+	return scored, err
+}
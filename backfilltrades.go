@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/FatwaArya/pm-ingest/config"
+	"github.com/FatwaArya/pm-ingest/internal/backfill"
+	internalkafka "github.com/FatwaArya/pm-ingest/internal/kafka"
+	"github.com/FatwaArya/pm-ingest/internal/pipeline"
+	"github.com/FatwaArya/pm-ingest/internal/store"
+	"github.com/FatwaArya/pm-ingest/pkg/dataapi"
+)
+
+// runTradeBackfill runs `pm-ingest backfill-trades`: it produces historical
+// trades from the Data API to the trades topic, marked as backfilled
+func runTradeBackfill(args []string) error {
+	cfg := config.AppConfig
+	fs := flag.NewFlagSet("backfill-trades", flag.ExitOnError)
+	from := fs.String("from", "", "start of the range, RFC 3339 (default: as far back as the API pages)")
+	to := fs.String("to", "", "end of the range, RFC 3339 (default: now)")
+	since := fs.Duration("since", 0, "start of the range relative to now, e.g. 24h (instead of -from)")
+	markets := fs.String("markets", "", "comma-separated condition IDs")
+	user := fs.String("user", "", "wallet address")
+	pageSize := fs.Int("page-size", backfill.DefaultPageSize, "trades requested per page")
+	pause := fs.Duration("pause", 200*time.Millisecond, "pause between pages")
+	dedupe := fs.Bool("dedupe", true, "skip trades already ingested (shared with the live feed via REDIS_URL)")
+	fs.Parse(args)
+
+	var req backfill.Request
+	var err error
+	if req.From, err = parseTimeFlag("from", *from); err != nil {
+		return err
+	}
+	if req.To, err = parseTimeFlag("to", *to); err != nil {
+		return err
+	}
+	if *since > 0 {
+		req.From = time.Now().Add(-*since)
+	}
+	if *markets != "" {
+		req.Markets = strings.Split(*markets, ",")
+	}
+	req.User = *user
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	useDataAPIPolicy()
+
+	producer, err := internalkafka.NewProducer(strings.TrimSpace(cfg.KafkaBrokers), cfg.KafkaTopic, kafkaProducerSettings())
+	if err != nil {
+		return fmt.Errorf("failed to create kafka producer: %w", err)
+	}
+	defer producer.Close()
+
+	backfiller := backfill.New(dataapi.NewClient(), producer, *pageSize, *pause)
+	if *dedupe {
+		var dedupeStore store.Store = store.NewMemoryStore()
+		if cfg.RedisURL != "" {
+			if dedupeStore, err = store.NewRedisStore(ctx, cfg.RedisURL, cfg.RedisPrefix); err != nil {
+				return fmt.Errorf("failed to create redis store: %w", err)
+			}
+		}
+		defer dedupeStore.Close()
+		backfiller.SetDeduper(pipeline.NewDeduper(dedupeStore, cfg.TradeDedupeTTL, cfg.TradeDedupeCacheSize))
+	}
+
+	start := time.Now()
+	stats, err := backfiller.Run(ctx, req)
+	log.Printf("Trade backfill done in %s: %d pages, %d trades fetched, %d produced to %s, %d duplicates skipped",
+		time.Since(start).Round(time.Second), stats.Pages, stats.Fetched, stats.Produced, cfg.KafkaTopic, stats.Skipped)
+	if stats.Produced > 0 && err != nil {
+		log.Printf("Resume with -to %s", time.Unix(stats.Oldest, 0).UTC().Format(time.RFC3339))
+	}
+	if errors.Is(err, backfill.ErrOffsetLimit) {
+		return fmt.Errorf("%w; backfill older trades per market or user", err)
+	}
+	return err
+}
+
+// parseTimeFlag parses an optional RFC 3339 flag value
+func parseTimeFlag(name, value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid -%s: %w", name, err)
+	}
+	return t, nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/FatwaArya/pm-ingest/config"
+	"github.com/FatwaArya/pm-ingest/internal/chaos"
+	"github.com/FatwaArya/pm-ingest/internal/sink"
+	"github.com/gin-gonic/gin"
+)
+
+// newChaos returns the fault injector when CHAOS_ENABLED is set, or nil.
+// API faults are injected into the default HTTP transport, which the data
+// and Gamma API clients use.
+func newChaos() *chaos.Injector {
+	cfg := config.AppConfig
+	if !cfg.ChaosEnabled {
+		return nil
+	}
+	log.Printf("CHAOS MODE: injecting faults (ws disconnect %.3f/s, kafka %.3f, api %.3f, questdb %.3f)",
+		cfg.ChaosWSDisconnectRate, cfg.ChaosKafkaFailRate, cfg.ChaosAPIErrorRate, cfg.ChaosQuestDBFailRate)
+	injector := chaos.New(chaos.Config{
+		WSDisconnectRate: cfg.ChaosWSDisconnectRate,
+		KafkaFailRate:    cfg.ChaosKafkaFailRate,
+		APIErrorRate:     cfg.ChaosAPIErrorRate,
+		QuestDBFailRate:  cfg.ChaosQuestDBFailRate,
+		Seed:             uint64(cfg.ChaosSeed),
+	})
+	http.DefaultTransport = injector.Transport(http.DefaultTransport)
+	return injector
+}
+
+// injectSinkFaults makes the QuestDB sink's flushes fail at the chaos rate
+func injectSinkFaults(injector *chaos.Injector, sinks *sink.Fanout) {
+	for _, s := range sinks.Sinks() {
+		if qs, ok := sink.Underlying(s).(*sink.QuestDBSink); ok {
+			qs.SetFaultInjector(injector.QuestDBFault())
+		}
+	}
+}
+
+// registerChaos serves the number of faults injected so far:
+//
+//	GET /chaos
+func registerChaos(r gin.IRoutes, injector *chaos.Injector) {
+	r.GET("/chaos", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"injected": injector.Stats()})
+	})
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/FatwaArya/pm-ingest/config"
+	internalkafka "github.com/FatwaArya/pm-ingest/internal/kafka"
+	"github.com/FatwaArya/pm-ingest/internal/logging"
+	"github.com/FatwaArya/pm-ingest/pkg/clob"
+	"github.com/FatwaArya/pm-ingest/pkg/rtds"
+)
+
+// clobErrLog limits market channel error logging
+var clobErrLog = logging.NewRateLimited(logging.For("clob"), 5*time.Second)
+
+// clobMarket ingests the CLOB market channel, publishing every book, price
+// change, tick size change and last trade price event to CLOB_MARKET_TOPIC
+// keyed by market. Like the RTDS client it is started and stopped with
+// ingestion, so only the leader publishes.
+type clobMarket struct {
+	ctx      context.Context
+	producer *internalkafka.Producer
+	logger   rtds.Logger
+	max      int
+
+	mu       sync.Mutex
+	client   *clob.MarketClient
+	followed []string
+	known    map[string]bool
+}
+
+func newClobMarket(ctx context.Context, producer *internalkafka.Producer, logger rtds.Logger) *clobMarket {
+	return &clobMarket{
+		ctx:      ctx,
+		producer: producer,
+		logger:   logger,
+		max:      config.AppConfig.ClobMarketMaxAssets,
+		known:    make(map[string]bool),
+	}
+}
+
+// publish sends an event to the market topic
+func (m *clobMarket) publish(market string, event any) {
+	value, err := json.Marshal(event)
+	if err != nil {
+		clobErrLog.Printf("Error encoding market channel event: %v", err)
+		return
+	}
+	m.producer.Produce(m.ctx, config.AppConfig.ClobMarketTopic, []byte(market), value)
+}
+
+// Start connects to the market channel, subscribing to the configured
+// assets and those followed so far
+func (m *clobMarket) Start() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.client != nil {
+		return
+	}
+	m.client = clob.NewMarketClient(
+		clob.WithAssets(config.AppConfig.ClobMarketAssets...),
+		clob.WithAssets(m.followed...),
+		clob.WithHandler(clob.HandlerFuncs{
+			Book:           func(e *clob.BookEvent) { m.publish(e.Market, e) },
+			PriceChange:    func(e *clob.PriceChangeEvent) { m.publish(e.Market, e) },
+			TickSizeChange: func(e *clob.TickSizeChangeEvent) { m.publish(e.Market, e) },
+			LastTradePrice: func(e *clob.LastTradePriceEvent) { m.publish(e.Market, e) },
+			Error:          func(err error) { clobErrLog.Printf("Error parsing market channel message: %v", err) },
+		}),
+		clob.WithLogger(m.logger),
+		clob.WithReconnectPolicy(rtds.DefaultReconnectPolicy()),
+	)
+	c := m.client
+	go func() {
+		if err := c.Run(); err != nil {
+			log.Printf("CLOB market channel error: %v", err)
+		}
+	}()
+}
+
+// Stop closes the market channel connection
+func (m *clobMarket) Stop() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.client != nil {
+		m.client.Close()
+		m.client = nil
+	}
+}
+
+// Follow subscribes to the asset of a trade, up to CLOB_MARKET_MAX_ASSETS
+// followed assets. Use as an Observe middleware.
+func (m *clobMarket) Follow(trade *rtds.ActivityTradePayload) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if trade.Asset == "" || m.known[trade.Asset] || len(m.followed) >= m.max {
+		return
+	}
+	m.known[trade.Asset] = true
+	m.followed = append(m.followed, trade.Asset)
+	if m.client == nil {
+		return
+	}
+	// Writing to the socket shouldn't hold up the trade
+	c, asset := m.client, trade.Asset
+	go func() {
+		if err := c.Subscribe(asset); err != nil {
+			clobErrLog.Printf("Error subscribing to asset %s: %v", asset, err)
+		}
+	}()
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/FatwaArya/pm-ingest/config"
+	internalqdb "github.com/FatwaArya/pm-ingest/internal"
+	internalkafka "github.com/FatwaArya/pm-ingest/internal/kafka"
+	"github.com/FatwaArya/pm-ingest/pkg/rtds"
+)
+
+// clobUserQueue bounds the order events waiting to be written to QuestDB
+const clobUserQueue = 1024
+
+// clobUser keeps our own orders and fills from the clob_user feed: order
+// events are published to CLOB_USER_ORDERS_TOPIC and written to the orders
+// table, trade updates are published to CLOB_USER_TRADES_TOPIC. Both
+// callbacks run on the parse workers, so table writes are queued for Run.
+type clobUser struct {
+	ctx      context.Context
+	producer *internalkafka.Producer // nil when the kafka sink is off
+	writer   *internalqdb.OrderWriter
+	orders   chan *rtds.ClobUserOrder
+}
+
+// newClobUser connects the order writer to QuestDB. producer may be nil.
+func newClobUser(ctx context.Context, producer *internalkafka.Producer) (*clobUser, error) {
+	port, err := questdbPort()
+	if err != nil {
+		return nil, err
+	}
+	writer, err := internalqdb.NewOrderWriter(ctx, config.AppConfig.QuestDBHost, port, questdbTable(config.AppConfig.QuestDBOrdersTable))
+	if err != nil {
+		return nil, err
+	}
+	return &clobUser{
+		ctx:      ctx,
+		producer: producer,
+		writer:   writer,
+		orders:   make(chan *rtds.ClobUserOrder, clobUserQueue),
+	}, nil
+}
+
+// Order publishes an order event and queues it for the orders table
+func (u *clobUser) Order(order *rtds.ClobUserOrder) {
+	u.publish(config.AppConfig.ClobUserOrdersTopic, order.ID, order)
+	select {
+	case u.orders <- order:
+	default:
+		clobErrLog.Printf("Order queue full; %s event of order %s isn't stored", order.Type, order.ID)
+	}
+}
+
+// Trade publishes a trade status update
+func (u *clobUser) Trade(trade *rtds.ClobUserTrade) {
+	u.publish(config.AppConfig.ClobUserTradesTopic, trade.ID, trade)
+}
+
+func (u *clobUser) publish(topic, key string, event any) {
+	if u.producer == nil {
+		return
+	}
+	value, err := json.Marshal(event)
+	if err != nil {
+		clobErrLog.Printf("Error encoding clob_user event: %v", err)
+		return
+	}
+	u.producer.Produce(u.ctx, topic, []byte(key), value)
+}
+
+// Run writes queued order events until ctx is done, then the ones left
+func (u *clobUser) Run(ctx context.Context) error {
+	for {
+		select {
+		case order := <-u.orders:
+			u.write(ctx, order)
+		case <-ctx.Done():
+			ctx = context.WithoutCancel(ctx)
+			for {
+				select {
+				case order := <-u.orders:
+					u.write(ctx, order)
+				default:
+					return nil
+				}
+			}
+		}
+	}
+}
+
+func (u *clobUser) write(ctx context.Context, order *rtds.ClobUserOrder) {
+	if err := u.writer.Write(ctx, order); err != nil {
+		clobErrLog.Printf("Error writing order %s: %v", order.ID, err)
+	}
+}
+
+// Close closes the order writer
+func (u *clobUser) Close(ctx context.Context) error {
+	return u.writer.Close(ctx)
+}
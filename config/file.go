@@ -0,0 +1,74 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// fileValues are the settings of the CONFIG_FILE, by environment variable
+// name. The environment takes precedence over them.
+var fileValues map[string]string
+
+// loadFile reads the YAML (.yaml, .yml) or TOML (.toml) file at path into
+// fileValues; an empty path clears them. Sections nest the variable names:
+//
+//	kafka:
+//	  brokers: redpanda:9092     # KAFKA_BROKERS
+//	  topic: polymarket-trades   # KAFKA_TOPIC
+//	subscription:
+//	  markets: [will-x, will-y]  # SUBSCRIPTION_MARKETS=will-x,will-y
+func loadFile(path string) error {
+	fileValues = nil
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var doc map[string]any
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &doc)
+	case ".toml":
+		err = toml.Unmarshal(data, &doc)
+	default:
+		return fmt.Errorf("unknown config file format %q, want .yaml, .yml or .toml", filepath.Ext(path))
+	}
+	if err != nil {
+		return err
+	}
+	values := make(map[string]string)
+	flatten(values, "", doc)
+	fileValues = values
+	return nil
+}
+
+// flatten stores the leaves of section under their upper-cased paths,
+// joined with underscores; lists become comma-separated
+func flatten(values map[string]string, prefix string, section map[string]any) {
+	for key, value := range section {
+		name := strings.ToUpper(strings.ReplaceAll(key, "-", "_"))
+		if prefix != "" {
+			name = prefix + "_" + name
+		}
+		switch v := value.(type) {
+		case map[string]any:
+			flatten(values, name, v)
+		case []any:
+			items := make([]string, len(v))
+			for i, item := range v {
+				items[i] = fmt.Sprint(item)
+			}
+			values[name] = strings.Join(items, ",")
+		case nil:
+		default:
+			values[name] = fmt.Sprint(v)
+		}
+	}
+}
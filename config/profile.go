@@ -0,0 +1,70 @@
+package config
+
+import (
+	"log"
+	"strings"
+)
+
+// Environment names selected by APP_ENV
+const (
+	EnvDev     = "dev"
+	EnvStaging = "staging"
+	EnvProd    = "prod"
+)
+
+// Profile holds the per-environment defaults. Every value can still be
+// overridden by its own environment variable.
+type Profile struct {
+	Name             string
+	GinMode          string
+	LogLevel         string // debug logs pings, subscriptions and periodic counters
+	DryRun           bool   // Write trades to stdout instead of the configured sinks
+	StrictValidation bool   // Treat invalid configuration values as fatal
+	DiscoveryEnabled bool   // Run the discovery consumer and its QuestDB sink
+}
+
+var profiles = map[string]Profile{
+	EnvDev: {
+		Name:             EnvDev,
+		GinMode:          "debug",
+		LogLevel:         "debug",
+		DryRun:           true,
+		StrictValidation: false,
+		DiscoveryEnabled: false,
+	},
+	EnvStaging: {
+		Name:             EnvStaging,
+		GinMode:          "release",
+		LogLevel:         "debug",
+		DryRun:           false,
+		StrictValidation: true,
+		DiscoveryEnabled: true,
+	},
+	EnvProd: {
+		Name:             EnvProd,
+		GinMode:          "release",
+		LogLevel:         "info",
+		DryRun:           false,
+		StrictValidation: true,
+		DiscoveryEnabled: true,
+	},
+}
+
+// lookupProfile returns the profile for name, falling back to prod for unknown names
+func lookupProfile(name string) Profile {
+	name = strings.ToLower(strings.TrimSpace(name))
+	switch name {
+	case "development":
+		name = EnvDev
+	case "stage":
+		name = EnvStaging
+	case "production":
+		name = EnvProd
+	}
+
+	if p, ok := profiles[name]; ok {
+		return p
+	}
+	log.Printf("Unknown APP_ENV %q, using %s profile", name, EnvProd)
+	return profiles[EnvProd]
+}
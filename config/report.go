@@ -0,0 +1,119 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// Issue is a setting that failed validation
+type Issue struct {
+	Key      string `json:"key"`
+	Value    string `json:"value"`
+	Reason   string `json:"reason"`
+	Fallback string `json:"fallback,omitempty"` // The value used instead, for warnings
+}
+
+func (i Issue) String() string {
+	s := fmt.Sprintf("%s=%q: %s", i.Key, i.Value, i.Reason)
+	if i.Fallback != "" {
+		s += ", using default " + i.Fallback
+	}
+	return s
+}
+
+// Report is the outcome of validating the configuration. Warnings were
+// replaced with their defaults; errors prevent starting.
+type Report struct {
+	Warnings []Issue `json:"warnings,omitempty"`
+	Errors   []Issue `json:"errors,omitempty"`
+}
+
+// Err returns a *ValidationError listing the errors, or nil
+func (r Report) Err() error {
+	if len(r.Errors) == 0 {
+		return nil
+	}
+	return &ValidationError{Issues: r.Errors}
+}
+
+// ValidationError lists the settings that prevent starting
+type ValidationError struct {
+	Issues []Issue
+}
+
+func (e *ValidationError) Error() string {
+	issues := make([]string, len(e.Issues))
+	for i, issue := range e.Issues {
+		issues[i] = issue.String()
+	}
+	return "invalid configuration: " + strings.Join(issues, "; ")
+}
+
+// report collects the issues found by the last Load
+var report Report
+
+// Validation returns the report of the last Load
+func Validation() Report {
+	return report
+}
+
+// secretField matches the Config fields whose values Print redacts
+var secretField = regexp.MustCompile(`Key|Secret|Passphrase|Token|Password|WebhookURL|PostgresURL`)
+
+// Print writes the effective configuration, one field per line, with
+// secrets redacted, followed by the validation report of the last Load
+func Print(w io.Writer) {
+	v := reflect.ValueOf(AppConfig)
+	t := v.Type()
+	for i := range t.NumField() {
+		name := t.Field(i).Name
+		value := fmt.Sprint(v.Field(i).Interface())
+		if secretField.MatchString(name) && !v.Field(i).IsZero() {
+			value = "<redacted>"
+		}
+		fmt.Fprintf(w, "%-28s %s\n", name, value)
+	}
+	for _, issue := range report.Warnings {
+		fmt.Fprintf(w, "WARNING %s\n", issue)
+	}
+	for _, issue := range report.Errors {
+		fmt.Fprintf(w, "ERROR   %s\n", issue)
+	}
+}
+
+// reloadable are the Config fields that can change without a restart
+var reloadable = map[string]bool{
+	"LogLevel":               true,
+	"DiscoveryRule":          true,
+	"AlertWebhookURLs":       true,
+	"AlertWebhookMinUSD":     true,
+	"AlertDiscordWebhookURL": true,
+	"AlertDiscordMinUSD":     true,
+	"AlertTelegramBotToken":  true,
+	"AlertTelegramChatID":    true,
+	"AlertTelegramMinUSD":    true,
+	"AlertsPerMinute":        true,
+	"AlertTemplate":          true,
+}
+
+// Changes lists the fields of c that differ from old: those applied at
+// runtime (log level, discovery rule, alert channels) and those that only
+// take effect after a restart
+func (c Config) Changes(old Config) (applied, restart []string) {
+	v, o := reflect.ValueOf(c), reflect.ValueOf(old)
+	t := v.Type()
+	for i := range t.NumField() {
+		if reflect.DeepEqual(v.Field(i).Interface(), o.Field(i).Interface()) {
+			continue
+		}
+		if name := t.Field(i).Name; reloadable[name] {
+			applied = append(applied, name)
+		} else {
+			restart = append(restart, name)
+		}
+	}
+	return applied, restart
+}
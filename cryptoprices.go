@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/FatwaArya/pm-ingest/config"
+	internalqdb "github.com/FatwaArya/pm-ingest/internal"
+	"github.com/FatwaArya/pm-ingest/internal/logging"
+	"github.com/FatwaArya/pm-ingest/pkg/rtds"
+)
+
+const (
+	// cryptoPriceQueue bounds the prices waiting to be written to QuestDB
+	cryptoPriceQueue = 4096
+	// cryptoPriceFlushInterval is how often buffered prices are sent
+	cryptoPriceFlushInterval = time.Second
+)
+
+var cryptoErrLog = logging.NewRateLimited(logging.For("crypto_prices"), 5*time.Second)
+
+// cryptoPrices writes the prices of the crypto_prices channels to QuestDB.
+// Handle runs on the parse workers, so writes are queued for Run.
+type cryptoPrices struct {
+	writer *internalqdb.CryptoPriceWriter
+	prices chan *rtds.CryptoPrice
+}
+
+// newCryptoPrices connects the crypto price writer to QuestDB
+func newCryptoPrices(ctx context.Context) (*cryptoPrices, error) {
+	port, err := questdbPort()
+	if err != nil {
+		return nil, err
+	}
+	writer, err := internalqdb.NewCryptoPriceWriter(ctx, config.AppConfig.QuestDBHost, port, questdbTable(config.AppConfig.QuestDBCryptoPricesTable))
+	if err != nil {
+		return nil, err
+	}
+	return &cryptoPrices{
+		writer: writer,
+		prices: make(chan *rtds.CryptoPrice, cryptoPriceQueue),
+	}, nil
+}
+
+// Handle queues the prices among channel messages
+func (c *cryptoPrices) Handle(ctx context.Context, topic, messageType string, message any) {
+	price, ok := message.(*rtds.CryptoPrice)
+	if !ok {
+		return
+	}
+	select {
+	case c.prices <- price:
+	default:
+		cryptoErrLog.Printf("Crypto price queue full; %s price of %s isn't stored", price.Source, price.Symbol)
+	}
+}
+
+// Run writes queued prices, flushing every cryptoPriceFlushInterval, until
+// ctx is done, then the ones left
+func (c *cryptoPrices) Run(ctx context.Context) error {
+	ticker := time.NewTicker(cryptoPriceFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case price := <-c.prices:
+			c.write(ctx, price)
+		case <-ticker.C:
+			if err := c.writer.Flush(ctx); err != nil {
+				cryptoErrLog.Printf("Error flushing crypto prices: %v", err)
+			}
+		case <-ctx.Done():
+			ctx = context.WithoutCancel(ctx)
+			for {
+				select {
+				case price := <-c.prices:
+					c.write(ctx, price)
+				default:
+					return nil
+				}
+			}
+		}
+	}
+}
+
+func (c *cryptoPrices) write(ctx context.Context, price *rtds.CryptoPrice) {
+	if err := c.writer.Write(ctx, price); err != nil {
+		cryptoErrLog.Printf("Error writing %s price of %s: %v", price.Source, price.Symbol, err)
+	}
+}
+
+// Close flushes and closes the crypto price writer
+func (c *cryptoPrices) Close(ctx context.Context) error {
+	return c.writer.Close(ctx)
+}
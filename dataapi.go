@@ -0,0 +1,31 @@
+package main
+
+import (
+	"github.com/FatwaArya/pm-ingest/config"
+	"github.com/FatwaArya/pm-ingest/internal/logging"
+	"github.com/FatwaArya/pm-ingest/pkg/dataapi"
+)
+
+// useDataAPIPolicy makes the Data API clients created from now on share
+// the configured rate limit, retries and circuit breakers
+func useDataAPIPolicy() {
+	cfg := config.AppConfig
+	transport := dataapi.UsePolicy(dataapi.Policy{
+		Rate:             cfg.DataAPIRate,
+		Burst:            cfg.DataAPIBurst,
+		MaxRetries:       cfg.DataAPIRetries,
+		InitialBackoff:   cfg.DataAPIRetryBackoff,
+		MaxBackoff:       cfg.DataAPIMaxBackoff,
+		AttemptTimeout:   cfg.DataAPITimeout,
+		BreakerThreshold: cfg.DataAPIBreakerThreshold,
+		BreakerCooldown:  cfg.DataAPIBreakerCooldown,
+	})
+	logger := logging.For("dataapi")
+	transport.OnBreaker(func(endpoint string, open bool) {
+		if open {
+			logger.Warn("Circuit opened, requests are rejected", "endpoint", endpoint, "cooldown", cfg.DataAPIBreakerCooldown)
+		} else {
+			logger.Info("Circuit closed", "endpoint", endpoint)
+		}
+	})
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/FatwaArya/pm-ingest/config"
+	internalkafka "github.com/FatwaArya/pm-ingest/internal/kafka"
+	"github.com/FatwaArya/pm-ingest/pkg/rtds"
+)
+
+// runReplayDLQ runs `pm-ingest replay-dlq`: it reprocesses the dead-letter
+// topic, producing the trades it recovers to the trades topic. Replayed
+// trades skip the ingest middleware (filters, enrichment). Records a
+// consumer gave up on are produced back to the topic they came from, as
+// they were.
+func runReplayDLQ(args []string) error {
+	cfg := config.AppConfig
+	fs := flag.NewFlagSet("replay-dlq", flag.ExitOnError)
+	group := fs.String("group", "dlq-replay", "consumer group tracking replayed records")
+	max := fs.Int("max", 0, "records replayed (0 for all)")
+	skipFailed := fs.Bool("skip-failed", false, "skip records that fail again instead of stopping")
+	dryRun := fs.Bool("dry-run", false, "print records instead of replaying them (still commits them)")
+	fs.Parse(args)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	brokers := strings.TrimSpace(cfg.KafkaBrokers)
+	producer, err := internalkafka.NewProducer(brokers, cfg.KafkaTopic, kafkaProducerSettings(),
+		internalkafka.WithSource(internalkafka.SourceReplay))
+	if err != nil {
+		return fmt.Errorf("failed to create kafka producer: %w", err)
+	}
+	defer producer.Close()
+
+	var skipped, trades int
+	replayed, err := internalkafka.ReplayDeadLetters(ctx, brokers, cfg.DLQTopic, *group, *max,
+		func(ctx context.Context, dl internalkafka.DeadLetter) error {
+			if *dryRun {
+				fmt.Printf("%s\t%s\t%s\t%s\n", dl.FailedAt.Format(time.RFC3339), dl.Stage, dl.Error, dl.Payload)
+				return nil
+			}
+			var recovered []*rtds.ActivityTradePayload
+			var err error
+			if dl.Stage == internalkafka.DLQStageConsume {
+				err = producer.Produce(ctx, dl.Topic, dl.Key, dl.Payload)
+			} else if recovered, err = recoverTrades(dl); err == nil {
+				for _, trade := range recovered {
+					if err = producer.ProduceTrade(ctx, trade); err != nil {
+						break
+					}
+				}
+			}
+			if err != nil {
+				if !*skipFailed {
+					return err
+				}
+				log.Printf("Skipping %s record at %d/%d: %v", dl.Stage, dl.Partition, dl.Offset, err)
+				skipped++
+				return nil
+			}
+			trades += len(recovered)
+			return nil
+		})
+
+	flushCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if flushErr := producer.Flush(flushCtx); flushErr != nil {
+		log.Printf("Error flushing replayed trades: %v", flushErr)
+	}
+	log.Printf("Replayed %d dead letters (%d trades, %d skipped)", replayed, trades, skipped)
+	return err
+}
+
+// recoverTrades parses the trades out of a dead letter again
+func recoverTrades(dl internalkafka.DeadLetter) ([]*rtds.ActivityTradePayload, error) {
+	switch dl.Stage {
+	case internalkafka.DLQStageParse:
+		var trades []*rtds.ActivityTradePayload
+		var errs []error
+		rtds.Dispatch(dl.Payload, rtds.HandlerFuncs{
+			Trade: func(trade *rtds.ActivityTradePayload) { trades = append(trades, trade) },
+			Error: func(err error) { errs = append(errs, err) },
+		})
+		if len(trades) == 0 {
+			return nil, errors.Join(errs...)
+		}
+		return trades, nil
+	case internalkafka.DLQStageSink:
+		var trade rtds.ActivityTradePayload
+		if err := json.Unmarshal(dl.Payload, &trade); err != nil {
+			return nil, fmt.Errorf("failed to parse trade: %w", err)
+		}
+		return []*rtds.ActivityTradePayload{&trade}, nil
+	default:
+		return nil, fmt.Errorf("unknown stage %q", dl.Stage)
+	}
+}
@@ -0,0 +1,159 @@
+package api
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/FatwaArya/pm-ingest/internal/domain"
+	"github.com/FatwaArya/pm-ingest/pkg/pmerrors"
+	"github.com/gin-gonic/gin"
+)
+
+// AdminAuth requires "Authorization: Bearer <token>" on admin routes. An
+// empty token leaves them open, for local development.
+func AdminAuth(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token == "" {
+			return
+		}
+		got, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid admin token"})
+			return
+		}
+	}
+}
+
+// RegisterIngestLists serves the full-fidelity and drop lists. PUT takes an
+// IngestRule and replaces the rule for the same market or event; DELETE
+// takes ?market= or ?event=:
+//
+//	GET    /ingest-lists
+//	PUT    /ingest-lists
+//	DELETE /ingest-lists
+func RegisterIngestLists(r gin.IRoutes, lists *domain.IngestLists) {
+	r.GET("/ingest-lists", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"rules": lists.Rules()})
+	})
+	r.PUT("/ingest-lists", func(c *gin.Context) {
+		var rule domain.IngestRule
+		if err := c.ShouldBindJSON(&rule); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := lists.Set(rule); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"rules": lists.Rules()})
+	})
+	r.DELETE("/ingest-lists", func(c *gin.Context) {
+		market, event := c.Query("market"), c.Query("event")
+		if (market == "") == (event == "") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "pass either market or event"})
+			return
+		}
+		if !lists.Remove(market, event) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "no rule for market or event"})
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+}
+
+// RegisterLabels serves the wallet labels. PUT takes a WalletLabel and
+// replaces the wallet's label of the same name; labels set here are marked
+// manual. A removed detector label comes back if the detector fires again.
+//
+//	GET    /labels
+//	GET    /labels/:wallet
+//	PUT    /labels/:wallet
+//	DELETE /labels/:wallet/:label
+func RegisterLabels(r gin.IRoutes, labels *domain.WalletLabels) {
+	r.GET("/labels", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"wallets": labels.All()})
+	})
+	r.GET("/labels/:wallet", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"wallet": c.Param("wallet"), "labels": labels.Get(c.Param("wallet"))})
+	})
+	r.PUT("/labels/:wallet", func(c *gin.Context) {
+		var label domain.WalletLabel
+		if err := c.ShouldBindJSON(&label); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		label.Source = domain.LabelSourceManual
+		label.AddedAt = time.Time{}
+		if err := labels.Set(c.Param("wallet"), label); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"wallet": c.Param("wallet"), "labels": labels.Get(c.Param("wallet"))})
+	})
+	r.DELETE("/labels/:wallet/:label", func(c *gin.Context) {
+		if !labels.Remove(c.Param("wallet"), domain.Label(c.Param("label"))) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "wallet doesn't have label"})
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+}
+
+// RegisterWalletWatchlist serves the wallet watchlist. POST takes a
+// WatchedWallet and adds it, or replaces the labels and note of a wallet
+// already on the list:
+//
+//	GET    /watchlist
+//	GET    /watchlist/:wallet
+//	POST   /watchlist
+//	DELETE /watchlist/:wallet
+func RegisterWalletWatchlist(r gin.IRoutes, watchlist *domain.WalletWatchlist) {
+	r.GET("/watchlist", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"wallets": watchlist.Wallets()})
+	})
+	r.GET("/watchlist/:wallet", func(c *gin.Context) {
+		wallet, ok := watchlist.Get(c.Param("wallet"))
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "wallet not on watchlist"})
+			return
+		}
+		c.JSON(http.StatusOK, wallet)
+	})
+	r.POST("/watchlist", func(c *gin.Context) {
+		var wallet domain.WatchedWallet
+		if err := c.ShouldBindJSON(&wallet); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		_, existed := watchlist.Get(wallet.Address)
+		saved, err := watchlist.Put(wallet)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, pmerrors.ErrInvalidArgument) {
+				status = http.StatusBadRequest
+			}
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		status := http.StatusCreated
+		if existed {
+			status = http.StatusOK
+		}
+		c.JSON(status, saved)
+	})
+	r.DELETE("/watchlist/:wallet", func(c *gin.Context) {
+		removed, err := watchlist.Remove(c.Param("wallet"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if !removed {
+			c.JSON(http.StatusNotFound, gin.H{"error": "wallet not on watchlist"})
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,212 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	internalqdb "github.com/FatwaArya/pm-ingest/internal"
+	"github.com/FatwaArya/pm-ingest/internal/domain"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// confidenceHistoryWindow is the default history window
+	confidenceHistoryWindow = 30 * 24 * time.Hour
+	// maxConfidencePoints caps the points returned, raw or downsampled
+	maxConfidencePoints = 5000
+	// maxConfidenceLimit bounds the closed positions a live confidence is
+	// computed from
+	maxConfidenceLimit = 1000
+)
+
+// ConfidencePoint is one computed (or, downsampled, averaged) score
+type ConfidencePoint struct {
+	Timestamp          string  `json:"timestamp"`
+	BrierScore         float64 `json:"brierScore"`
+	Calibration        float64 `json:"calibration"`
+	WinRate            float64 `json:"winRate"`
+	ConfidenceInterval float64 `json:"confidenceInterval"`
+	SampleSize         int     `json:"sampleSize"`
+	AvgRealizedPnl     float64 `json:"avgRealizedPnl"`
+	TotalRealizedPnl   float64 `json:"totalRealizedPnl"`
+	PnlVolatility      float64 `json:"pnlVolatility"`
+	SharpeRatio        float64 `json:"sharpeRatio"`
+	MaxDrawdown        float64 `json:"maxDrawdown"`
+	ProfitFactor       float64 `json:"profitFactor"`
+	NoLosses           bool    `json:"noLosses"`
+	ROI                float64 `json:"roi"`
+	Scores             int     `json:"scores"` // Computations in the point
+}
+
+// ConfidenceTrend compares the last point of the history with the first.
+// A falling Brier score is an improving trader.
+type ConfidenceTrend struct {
+	BrierChange       float64 `json:"brierChange"`
+	CalibrationChange float64 `json:"calibrationChange"`
+	WinRateChange     float64 `json:"winRateChange"`
+	Improving         bool    `json:"improving"`
+}
+
+// RegisterConfidenceHistory serves a wallet's stored confidence scores,
+// oldest first. from/to are RFC 3339 times (default: the last 30 days up to
+// now). interval (e.g. 1h, 24h) downsamples to one point per interval,
+// averaging the metrics and keeping the last sample size, total PnL, drawdown
+// and profit factor:
+//
+//	GET /confidence/:address/history?from=&to=&interval=
+func RegisterConfidenceHistory(r gin.IRoutes, client *internalqdb.QueryClient, confidenceTable string) {
+	r.GET("/confidence/:address/history", func(c *gin.Context) {
+		address := strings.ToLower(c.Param("address"))
+		from, to, ok := queryTimeRange(c, confidenceHistoryWindow)
+		if !ok {
+			return
+		}
+
+		where := fmt.Sprintf("lower(wallet) = %s AND timestamp >= %s AND timestamp < %s",
+			internalqdb.Quote(address), internalqdb.QuoteTime(from), internalqdb.QuoteTime(to))
+		query := fmt.Sprintf("SELECT timestamp, brier_score, calibration, win_rate, confidence_interval, "+
+			"sample_size, avg_realized_pnl, total_realized_pnl, pnl_volatility, sharpe_ratio, max_drawdown, "+
+			"profit_factor, no_losses, roi, 1 scores FROM %s WHERE %s ORDER BY timestamp LIMIT %d",
+			confidenceTable, where, maxConfidencePoints)
+
+		interval := c.Query("interval")
+		if interval != "" {
+			d, err := time.ParseDuration(interval)
+			if err != nil || d < time.Minute {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "interval must be a duration of at least 1m"})
+				return
+			}
+			query = fmt.Sprintf("SELECT timestamp, avg(brier_score) brier_score, avg(calibration) calibration, "+
+				"avg(win_rate) win_rate, avg(confidence_interval) confidence_interval, last(sample_size) sample_size, "+
+				"avg(avg_realized_pnl) avg_realized_pnl, last(total_realized_pnl) total_realized_pnl, "+
+				"avg(pnl_volatility) pnl_volatility, avg(sharpe_ratio) sharpe_ratio, last(max_drawdown) max_drawdown, "+
+				"last(profit_factor) profit_factor, last(no_losses) no_losses, avg(roi) roi, count() scores "+
+				"FROM %s WHERE %s SAMPLE BY %s ALIGN TO CALENDAR ORDER BY timestamp LIMIT %d",
+				confidenceTable, where, sampleUnit(d), maxConfidencePoints)
+		}
+
+		result, err := client.Query(c.Request.Context(), query)
+		if err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+			return
+		}
+		rows := result.Rows()
+		points := make([]ConfidencePoint, len(rows))
+		for i, row := range rows {
+			points[i] = ConfidencePoint{
+				Timestamp:          row.String("timestamp"),
+				BrierScore:         row.Float("brier_score"),
+				Calibration:        row.Float("calibration"),
+				WinRate:            row.Float("win_rate"),
+				ConfidenceInterval: row.Float("confidence_interval"),
+				SampleSize:         row.Int("sample_size"),
+				AvgRealizedPnl:     row.Float("avg_realized_pnl"),
+				TotalRealizedPnl:   row.Float("total_realized_pnl"),
+				PnlVolatility:      row.Float("pnl_volatility"),
+				SharpeRatio:        row.Float("sharpe_ratio"),
+				MaxDrawdown:        row.Float("max_drawdown"),
+				ProfitFactor:       row.Float("profit_factor"),
+				NoLosses:           row.Bool("no_losses"),
+				ROI:                row.Float("roi"),
+				Scores:             row.Int("scores"),
+			}
+		}
+
+		resp := gin.H{
+			"address":  address,
+			"from":     from,
+			"to":       to,
+			"interval": interval,
+			"points":   points,
+		}
+		if len(points) >= 2 {
+			first, last := points[0], points[len(points)-1]
+			resp["trend"] = ConfidenceTrend{
+				BrierChange:       last.BrierScore - first.BrierScore,
+				CalibrationChange: last.Calibration - first.Calibration,
+				WinRateChange:     last.WinRate - first.WinRate,
+				Improving:         last.BrierScore < first.BrierScore,
+			}
+		}
+		c.JSON(http.StatusOK, resp)
+	})
+}
+
+// RegisterUserConfidence serves a wallet's current confidence, computed from
+// its closed positions with the largest realized PnL. limit caps the
+// positions (default and max 1000); market restricts them to condition IDs,
+// repeated or comma-separated. Results are cached by the service, and
+// clients may cache them as long. X-Cache tells whether the result was
+// cached (HIT) or computed for the request (MISS), and Age how old a
+// cached one is:
+//
+//	GET /api/v1/users/:address/confidence?limit=&market=
+func RegisterUserConfidence(r gin.IRoutes, confidence *domain.ConfidenceService) {
+	r.GET("/api/v1/users/:address/confidence", func(c *gin.Context) {
+		address := strings.ToLower(c.Param("address"))
+		var q domain.ConfidenceQuery
+		if raw := c.Query("limit"); raw != "" {
+			n, err := strconv.Atoi(raw)
+			if err != nil || n <= 0 || n > maxConfidenceLimit {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", maxConfidenceLimit)})
+				return
+			}
+			q.Limit = n
+		}
+		for _, raw := range c.QueryArray("market") {
+			for _, m := range strings.Split(raw, ",") {
+				if m = strings.TrimSpace(m); m != "" {
+					q.Markets = append(q.Markets, m)
+				}
+			}
+		}
+
+		lookup, err := confidence.LookupConfidence(c.Request.Context(), address, q)
+		if err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+			return
+		}
+		c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", int(confidence.CacheTTL().Seconds())))
+		if lookup.Cached {
+			c.Header("X-Cache", "HIT")
+			if !lookup.ComputedAt.IsZero() {
+				c.Header("Age", strconv.Itoa(int(time.Since(lookup.ComputedAt).Seconds())))
+			}
+		} else {
+			c.Header("X-Cache", "MISS")
+		}
+		c.JSON(http.StatusOK, lookup.Prediction)
+	})
+}
+
+// RegisterConfidenceCache serves the number of cached confidences and the
+// cache's hits and misses so far:
+//
+//	GET /api/v1/confidence/cache
+func RegisterConfidenceCache(r gin.IRoutes, cache *domain.ConfidenceCache) {
+	r.GET("/api/v1/confidence/cache", func(c *gin.Context) {
+		stats := cache.Stats()
+		c.JSON(http.StatusOK, gin.H{
+			"entries": stats.Entries,
+			"hits":    stats.Hits,
+			"misses":  stats.Misses,
+			"ttl":     cache.TTL().String(),
+		})
+	})
+}
+
+// sampleUnit formats d as a QuestDB SAMPLE BY interval in the largest
+// whole unit, rounding down to whole minutes
+func sampleUnit(d time.Duration) string {
+	switch {
+	case d%(24*time.Hour) == 0:
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	default:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	}
+}
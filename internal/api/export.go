@@ -0,0 +1,132 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	internalqdb "github.com/FatwaArya/pm-ingest/internal"
+	"github.com/gin-gonic/gin"
+)
+
+// Export formats
+const (
+	formatCSV   = "csv"
+	formatJSONL = "jsonl"
+)
+
+// exportPageSize is the rows fetched per query when exporting JSON lines
+const exportPageSize = 10000
+
+// RegisterExport streams trades from QuestDB as CSV or JSON lines, for
+// analysts pulling data into notebooks without database credentials.
+// from/to are RFC 3339 times (default: the last 24 hours up to now), market
+// filters by condition ID and format is csv (default) or jsonl:
+//
+//	GET /export/trades?from=&to=&market=&format=csv|jsonl
+func RegisterExport(r gin.IRoutes, client *internalqdb.QueryClient, tradesTable string) {
+	r.GET("/export/trades", func(c *gin.Context) {
+		from, to, ok := queryTimeRange(c, 24*time.Hour)
+		if !ok {
+			return
+		}
+
+		format := strings.ToLower(c.DefaultQuery("format", formatCSV))
+		if format != formatCSV && format != formatJSONL {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv or jsonl"})
+			return
+		}
+
+		where := []string{
+			"timestamp >= " + internalqdb.QuoteTime(from),
+			"timestamp < " + internalqdb.QuoteTime(to),
+		}
+		if market := c.Query("market"); market != "" {
+			where = append(where, "condition_id = "+internalqdb.Quote(market))
+		}
+		query := fmt.Sprintf("SELECT * FROM %s WHERE %s ORDER BY timestamp", tradesTable, strings.Join(where, " AND "))
+
+		// Headers go out with the first chunk; errors after that can only
+		// cut the stream short
+		filename := fmt.Sprintf("trades-%s-%s.%s", from.UTC().Format("20060102T150405"), to.UTC().Format("20060102T150405"), format)
+		c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+		w := &flushWriter{w: c.Writer}
+		var err error
+		switch format {
+		case formatCSV:
+			c.Header("Content-Type", "text/csv; charset=utf-8")
+			err = client.Export(c.Request.Context(), query, w)
+		case formatJSONL:
+			c.Header("Content-Type", "application/x-ndjson")
+			err = exportJSONL(c, client, query, w)
+		}
+		if err != nil {
+			if !w.written {
+				c.Writer.Header().Del("Content-Type")
+				c.Writer.Header().Del("Content-Disposition")
+				c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+				return
+			}
+			log.Printf("Trade export cut short: %v", err)
+		}
+	})
+}
+
+// queryTimeRange parses the from/to query parameters as RFC 3339 times,
+// defaulting to the window up to now. On an invalid range it responds with
+// 400 and returns false.
+func queryTimeRange(c *gin.Context, window time.Duration) (from, to time.Time, ok bool) {
+	to = time.Now()
+	from = to.Add(-window)
+	for _, p := range []struct {
+		name string
+		t    *time.Time
+	}{{"from", &from}, {"to", &to}} {
+		raw := c.Query(p.name)
+		if raw == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": p.name + " must be an RFC 3339 time"})
+			return from, to, false
+		}
+		*p.t = t
+	}
+	if !from.Before(to) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must be before to"})
+		return from, to, false
+	}
+	return from, to, true
+}
+
+// exportJSONL writes one JSON object per row, paging through the result
+func exportJSONL(c *gin.Context, client *internalqdb.QueryClient, query string, w io.Writer) error {
+	enc := json.NewEncoder(w)
+	return client.Pages(c.Request.Context(), query, exportPageSize, func(page *internalqdb.QueryResult) error {
+		for _, row := range page.Rows() {
+			if err := enc.Encode(row); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// flushWriter flushes every write, so the response is sent in chunks as
+// QuestDB produces it instead of buffered
+type flushWriter struct {
+	w       gin.ResponseWriter
+	written bool
+}
+
+func (f *flushWriter) Write(p []byte) (int, error) {
+	f.written = true
+	n, err := f.w.Write(p)
+	f.w.Flush()
+	return n, err
+}
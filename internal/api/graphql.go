@@ -0,0 +1,14 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterGraphQL serves the GraphQL API (see package gql):
+//
+//	POST /graphql
+func RegisterGraphQL(r gin.IRoutes, handler http.Handler) {
+	r.POST("/graphql", gin.WrapH(handler))
+}
@@ -0,0 +1,255 @@
+// Package api serves the HTTP endpoints for the in-process analytics.
+package api
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/FatwaArya/pm-ingest/internal/domain"
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterVelocity serves wallet trade velocity and burst flags:
+//
+//	GET /stats/wallets/:address/velocity
+func RegisterVelocity(r gin.IRoutes, velocity *domain.VelocityTracker) {
+	r.GET("/stats/wallets/:address/velocity", func(c *gin.Context) {
+		vel, ok := velocity.Velocity(c.Param("address"))
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "no recent trades for wallet"})
+			return
+		}
+		c.JSON(http.StatusOK, vel)
+	})
+}
+
+// RegisterActivity serves wallet time-of-day and session profiles:
+//
+//	GET /stats/wallets/:address/activity
+func RegisterActivity(r gin.IRoutes, activity *domain.ActivityTracker) {
+	r.GET("/stats/wallets/:address/activity", func(c *gin.Context) {
+		profile, ok := activity.Profile(c.Param("address"))
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "no trades seen for wallet"})
+			return
+		}
+		c.JSON(http.StatusOK, profile)
+	})
+}
+
+// RegisterPrices serves the last price, VWAP and one-minute bars of an
+// asset (outcome token). ?window= sets the VWAP and series window (default 1h):
+//
+//	GET /prices/:asset
+func RegisterPrices(r gin.IRoutes, prices *domain.PriceService) {
+	r.GET("/prices/:asset", func(c *gin.Context) {
+		asset := c.Param("asset")
+		window := time.Hour
+		if raw := c.Query("window"); raw != "" {
+			d, err := time.ParseDuration(raw)
+			if err != nil || d <= 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "window must be a positive duration"})
+				return
+			}
+			window = d
+		}
+		last, at, ok := prices.LastPrice(asset)
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "no recent trades for asset"})
+			return
+		}
+		resp := gin.H{"asset": asset, "lastPrice": last, "lastTrade": at}
+		if vwap, ok := prices.VWAP(asset, window); ok {
+			resp["vwap"] = vwap
+		}
+		resp["bars"] = prices.Series(asset, time.Now().Add(-window))
+		c.JSON(http.StatusOK, resp)
+	})
+}
+
+// RegisterLiquidity serves market liquidity estimates. With ?notional=USD
+// the response also assesses whether a trade of that size is large for the
+// market:
+//
+//	GET /stats/markets/:market/liquidity
+func RegisterLiquidity(r gin.IRoutes, liquidity *domain.LiquidityEstimator) {
+	r.GET("/stats/markets/:market/liquidity", func(c *gin.Context) {
+		market := c.Param("market")
+		est, ok := liquidity.Liquidity(market)
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not enough recent trades to estimate liquidity", "trades": est.Trades})
+			return
+		}
+		resp := gin.H{"liquidity": est}
+		if raw := c.Query("notional"); raw != "" {
+			notional, err := strconv.ParseFloat(raw, 64)
+			if err != nil || notional <= 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "notional must be a positive number"})
+				return
+			}
+			resp["assessment"] = liquidity.Assess(market, notional)
+		}
+		c.JSON(http.StatusOK, resp)
+	})
+}
+
+// RegisterFlow serves the maker/taker flow and fees of wallets and markets:
+//
+//	GET /stats/wallets/:address
+//	GET /stats/markets/:market  (condition ID or slug)
+func RegisterFlow(r gin.IRoutes, flow *domain.FlowTracker) {
+	r.GET("/stats/wallets/:address", func(c *gin.Context) {
+		stats, ok := flow.Wallet(c.Param("address"))
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "no trades seen for wallet"})
+			return
+		}
+		c.JSON(http.StatusOK, stats)
+	})
+
+	r.GET("/stats/markets/:market", func(c *gin.Context) {
+		stats, ok := flow.Market(c.Param("market"))
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "no trades seen for market"})
+			return
+		}
+		c.JSON(http.StatusOK, stats)
+	})
+}
+
+// RegisterExposure serves a wallet's open positions, their cost, current
+// value and unrealized PnL:
+//
+//	GET /stats/wallets/:address/exposure
+func RegisterExposure(r gin.IRoutes, exposure *domain.ExposureTracker) {
+	r.GET("/stats/wallets/:address/exposure", func(c *gin.Context) {
+		e, err := exposure.Exposure(c.Request.Context(), c.Param("address"))
+		if err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, e)
+	})
+}
+
+// RegisterOwners serves the owner of a proxy wallet and the other wallets
+// of the same owner:
+//
+//	GET /owners/:address
+func RegisterOwners(r gin.IRoutes, owners *domain.OwnerResolver) {
+	r.GET("/owners/:address", func(c *gin.Context) {
+		owner, err := owners.Resolve(c.Request.Context(), c.Param("address"))
+		if err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+			return
+		}
+		wallets, err := owners.Wallets(c.Request.Context(), owner.OwnerID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"owner": owner, "wallets": wallets})
+	})
+}
+
+// RegisterWatchlist serves the market watchlist:
+//
+//	GET    /watchlist/markets
+//	DELETE /watchlist/markets/:conditionId
+func RegisterWatchlist(r gin.IRoutes, watchlist *domain.Watchlist) {
+	r.GET("/watchlist/markets", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"markets": watchlist.Markets()})
+	})
+	r.DELETE("/watchlist/markets/:conditionId", func(c *gin.Context) {
+		if !watchlist.Remove(c.Param("conditionId")) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "market not on watchlist"})
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+}
+
+// RegisterTopTraders serves the precomputed top-traders snapshot. ?limit=
+// returns only the first traders of the ranking:
+//
+//	GET /snapshot/top-traders
+func RegisterTopTraders(r gin.IRoutes, top *domain.TopTraders) {
+	r.GET("/snapshot/top-traders", func(c *gin.Context) {
+		snapshot := top.Snapshot()
+		if raw := c.Query("limit"); raw != "" {
+			limit, err := strconv.Atoi(raw)
+			if err != nil || limit <= 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+				return
+			}
+			if limit < len(snapshot.Traders) {
+				c.JSON(http.StatusOK, domain.TopTradersSnapshot{
+					Traders:     snapshot.Traders[:limit],
+					GeneratedAt: snapshot.GeneratedAt,
+				})
+				return
+			}
+		}
+		c.Data(http.StatusOK, "application/json; charset=utf-8", snapshot.JSON)
+	})
+}
+
+// RegisterLeaderboard serves the rolling leaderboard of a window (24h, 7d
+// or 30d, default 24h), by notional or, with sort=pnl, by realized PnL net
+// of fees:
+//
+//	GET /leaderboard?window=7d&sort=pnl&limit=20&discovered=true
+func RegisterLeaderboard(r gin.IRoutes, leaderboard *domain.Leaderboard) {
+	r.GET("/leaderboard", func(c *gin.Context) {
+		snapshot := leaderboard.Snapshot(domain.LeaderboardWindow(c.DefaultQuery("window", string(domain.LeaderboardDay))))
+		if snapshot == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "window must be 24h, 7d or 30d"})
+			return
+		}
+		limit := len(snapshot.Entries)
+		if raw := c.Query("limit"); raw != "" {
+			n, err := strconv.Atoi(raw)
+			if err != nil || n <= 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+				return
+			}
+			limit = min(n, limit)
+		}
+		sortBy := c.DefaultQuery("sort", "volume")
+		if sortBy != "volume" && sortBy != "pnl" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "sort must be volume or pnl"})
+			return
+		}
+		discovered := c.Query("discovered") == "true"
+		if sortBy == "volume" && !discovered && limit == len(snapshot.Entries) {
+			c.Data(http.StatusOK, "application/json; charset=utf-8", snapshot.JSON)
+			return
+		}
+
+		entries := make([]domain.LeaderboardEntry, 0, len(snapshot.Entries))
+		for _, e := range snapshot.Entries {
+			if !discovered || e.Discovered {
+				entries = append(entries, e)
+			}
+		}
+		if sortBy == "pnl" {
+			// Among the volume leaders; wallets without a known PnL go last
+			sort.SliceStable(entries, func(i, j int) bool {
+				if entries[i].PnLKnown != entries[j].PnLKnown {
+					return entries[i].PnLKnown
+				}
+				return entries[i].NetPnL > entries[j].NetPnL
+			})
+		}
+		for i := range entries {
+			entries[i].Rank = i + 1
+		}
+		c.JSON(http.StatusOK, domain.LeaderboardSnapshot{
+			Window:      snapshot.Window,
+			Entries:     entries[:min(limit, len(entries))],
+			GeneratedAt: snapshot.GeneratedAt,
+		})
+	})
+}
@@ -0,0 +1,172 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/FatwaArya/pm-ingest/internal/stream"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// streamHeartbeat is how often idle connections are pinged, so proxies
+	// keep them open and dead clients are noticed
+	streamHeartbeat = 15 * time.Second
+	// streamWriteTimeout bounds each write to a WebSocket client
+	streamWriteTimeout = 10 * time.Second
+)
+
+var upgrader = websocket.Upgrader{
+	// The stream is read-only public data, so browser UIs on any origin may
+	// subscribe
+	CheckOrigin: func(*http.Request) bool { return true },
+}
+
+// RegisterStream serves the live stream of trades and discovery/confidence
+// events, over WebSocket when the request is an upgrade and as server-sent
+// events otherwise. Each message is {"type": ..., "data": ...}. Query
+// parameters filter the stream; market, wallet and type take several
+// comma-separated values:
+//
+//	GET /stream/trades?market=<conditionId or slug>&wallet=0x...&min_size=1000&type=trade,profile
+func RegisterStream(r gin.IRoutes, hub *stream.Hub) {
+	r.GET("/stream/trades", func(c *gin.Context) {
+		filter, err := streamFilter(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		sub, err := hub.Subscribe(filter)
+		if err != nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+			return
+		}
+		defer hub.Unsubscribe(sub)
+
+		if websocket.IsWebSocketUpgrade(c.Request) {
+			serveWebSocket(c, sub)
+		} else {
+			serveEvents(c, sub)
+		}
+	})
+}
+
+// streamFilter reads the stream filter from the query
+func streamFilter(c *gin.Context) (stream.Filter, error) {
+	filter := stream.Filter{
+		Types:   queryValues(c, "type", false),
+		Markets: queryValues(c, "market", false),
+		Wallets: queryValues(c, "wallet", true),
+	}
+	if raw := c.Query("min_size"); raw != "" {
+		n, err := strconv.ParseFloat(raw, 64)
+		if err != nil || n < 0 {
+			return filter, errors.New("min_size must be a non-negative number")
+		}
+		filter.MinNotional = n
+	}
+	for t := range filter.Types {
+		if t != stream.TypeTrade && t != stream.TypeProfile && t != stream.TypeConfidence {
+			return filter, errors.New("type must be trade, profile or confidence")
+		}
+	}
+	return filter, nil
+}
+
+// queryValues collects the comma-separated values of a repeatable parameter
+func queryValues(c *gin.Context, key string, lower bool) map[string]bool {
+	var values map[string]bool
+	for _, raw := range c.QueryArray(key) {
+		for _, v := range strings.Split(raw, ",") {
+			if v = strings.TrimSpace(v); v == "" {
+				continue
+			}
+			if lower {
+				v = strings.ToLower(v)
+			}
+			if values == nil {
+				values = make(map[string]bool)
+			}
+			values[v] = true
+		}
+	}
+	return values
+}
+
+// serveEvents streams sub as server-sent events until the client leaves
+func serveEvents(c *gin.Context, sub *stream.Subscription) {
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // Unbuffered behind nginx
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case data, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			if _, err := c.Writer.Write(append(append([]byte("data: "), data...), '\n', '\n')); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		case <-heartbeat.C:
+			if _, err := c.Writer.Write([]byte(": heartbeat\n\n")); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		}
+	}
+}
+
+// serveWebSocket streams sub over a WebSocket until either side closes it.
+// Messages from the client are ignored.
+func serveWebSocket(c *gin.Context, sub *stream.Subscription) {
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		return // The upgrader already replied
+	}
+	defer conn.Close()
+
+	// Reading notices the client closing the connection
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-closed:
+			return
+		case data, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+			if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(streamWriteTimeout)); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,301 @@
+// Package archive keeps raw WebSocket messages and parsed trades in hourly
+// gzipped JSONL files, partitioned as
+// <stream>/dt=YYYY-MM-DD/hour=HH/<instance>-<unix>.jsonl.gz, and uploads
+// the finished files to object storage. The archive is a source of truth
+// for replays that doesn't depend on Kafka retention.
+package archive
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/FatwaArya/pm-ingest/internal/logging"
+	"github.com/FatwaArya/pm-ingest/internal/provenance"
+	"github.com/FatwaArya/pm-ingest/pkg/clock"
+)
+
+// Streams archived
+const (
+	StreamRaw    = "raw"    // WebSocket messages as received
+	StreamTrades = "trades" // Trades after parsing and the pipeline
+)
+
+const (
+	fileExt    = ".jsonl.gz"
+	partialExt = ".part" // Appended while a file is being written
+)
+
+var uploadErrLog = logging.NewRateLimited(logging.For("archive"), 5*time.Second)
+
+// Record is a line of the raw stream
+type Record struct {
+	ReceivedAt time.Time       `json:"receivedAt"`
+	Message    json.RawMessage `json:"message"`
+}
+
+// Store is where finished files are uploaded to
+type Store interface {
+	// Upload copies the file at path to key
+	Upload(ctx context.Context, key, path string) error
+}
+
+// Archiver writes records to hourly files under dir and uploads each file
+// once its hour is over. Files left by an earlier run are uploaded too.
+type Archiver struct {
+	dir    string
+	prefix string
+	store  Store // nil keeps the files on disk
+	clock  clock.Clock
+
+	mu    sync.Mutex
+	files map[string]*file // By stream
+
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+	onUpload  func(error)
+}
+
+// file is the open file of a stream's current hour
+type file struct {
+	hour time.Time
+	path string // Without partialExt
+	f    *os.File
+	gz   *gzip.Writer
+	w    *bufio.Writer
+}
+
+// NewArchiver creates an archiver writing under dir and uploading to store
+// (nil to keep files on disk) with keys prefixed by prefix. Finished files
+// are uploaded every interval; onUpload, which may be nil, is called with
+// the outcome of every round of uploads.
+func NewArchiver(dir, prefix string, store Store, interval time.Duration, onUpload func(error)) (*Archiver, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create archive directory: %w", err)
+	}
+	a := &Archiver{
+		dir:      dir,
+		prefix:   strings.Trim(prefix, "/"),
+		store:    store,
+		clock:    clock.Real,
+		files:    make(map[string]*file),
+		done:     make(chan struct{}),
+		onUpload: onUpload,
+	}
+	if err := a.finishPartials(); err != nil {
+		return nil, err
+	}
+	a.wg.Add(1)
+	go a.loop(interval)
+	return a, nil
+}
+
+// SetClock replaces the clock timestamping records and choosing their hour
+func (a *Archiver) SetClock(c clock.Clock) {
+	a.clock = clock.OrReal(c)
+}
+
+// WriteRaw archives a WebSocket message, received now
+func (a *Archiver) WriteRaw(message []byte) error {
+	now := a.clock.Now()
+	line, err := json.Marshal(Record{ReceivedAt: now, Message: rawMessage(message)})
+	if err != nil {
+		return err
+	}
+	return a.write(StreamRaw, now, line)
+}
+
+// WriteJSON archives v to stream
+func (a *Archiver) WriteJSON(stream string, v any) error {
+	line, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return a.write(stream, a.clock.Now(), line)
+}
+
+func (a *Archiver) write(stream string, at time.Time, line []byte) error {
+	hour := at.UTC().Truncate(time.Hour)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	f := a.files[stream]
+	if f != nil && !f.hour.Equal(hour) {
+		if err := f.close(); err != nil {
+			return err
+		}
+		f = nil
+	}
+	if f == nil {
+		var err error
+		if f, err = a.create(stream, hour, at); err != nil {
+			return err
+		}
+		a.files[stream] = f
+	}
+	if _, err := f.w.Write(line); err != nil {
+		return err
+	}
+	return f.w.WriteByte('\n')
+}
+
+// create opens a new file for stream's hour
+func (a *Archiver) create(stream string, hour, at time.Time) (*file, error) {
+	name := fmt.Sprintf("%s-%d%s", provenance.Current().InstanceID, at.UnixNano(), fileExt)
+	path := filepath.Join(a.dir, stream, hour.Format("dt=2006-01-02"), hour.Format("hour=15"), name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path+partialExt, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	gz := gzip.NewWriter(f)
+	return &file{hour: hour, path: path, f: f, gz: gz, w: bufio.NewWriter(gz)}, nil
+}
+
+// Flush writes buffered lines through to disk. The files stay open; a
+// reader sees a valid gzip stream up to the last flush.
+func (a *Archiver) Flush() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var errs []error
+	for _, f := range a.files {
+		errs = append(errs, f.flush())
+	}
+	return errors.Join(errs...)
+}
+
+// Close finishes the open files, whatever their hour, and uploads every
+// finished file
+func (a *Archiver) Close(ctx context.Context) error {
+	a.closeOnce.Do(func() { close(a.done) })
+	a.wg.Wait()
+
+	a.mu.Lock()
+	var errs []error
+	for stream, f := range a.files {
+		errs = append(errs, f.close())
+		delete(a.files, stream)
+	}
+	a.mu.Unlock()
+	errs = append(errs, a.Upload(ctx))
+	return errors.Join(errs...)
+}
+
+// rotate finishes the files of past hours, even of streams that got no
+// record since
+func (a *Archiver) rotate() error {
+	hour := a.clock.Now().UTC().Truncate(time.Hour)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var errs []error
+	for stream, f := range a.files {
+		if f.hour.Before(hour) {
+			errs = append(errs, f.close())
+			delete(a.files, stream)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Upload uploads the finished files under the directory, removing each once
+// uploaded. Without a store it does nothing.
+func (a *Archiver) Upload(ctx context.Context) error {
+	if a.store == nil {
+		return nil
+	}
+	var errs []error
+	err := filepath.WalkDir(a.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(path, fileExt) {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(a.dir, path)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if a.prefix != "" {
+			key = a.prefix + "/" + key
+		}
+		if err := a.store.Upload(ctx, key, path); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", key, err))
+			return nil
+		}
+		return os.Remove(path)
+	})
+	return errors.Join(append(errs, err)...)
+}
+
+// finishPartials renames the files an earlier run didn't finish so they're
+// uploaded. Their gzip stream may be truncated; readers get every line up
+// to its last flush.
+func (a *Archiver) finishPartials() error {
+	return filepath.WalkDir(a.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(path, partialExt) {
+			return err
+		}
+		return os.Rename(path, strings.TrimSuffix(path, partialExt))
+	})
+}
+
+func (a *Archiver) loop(interval time.Duration) {
+	defer a.wg.Done()
+	ticker := a.clock.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-a.done:
+			return
+		case <-ticker.C():
+		}
+		err := a.rotate()
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		err = errors.Join(err, a.Upload(ctx))
+		cancel()
+		if err != nil {
+			uploadErrLog.Printf("Archive upload error: %v", err)
+		}
+		if a.onUpload != nil {
+			a.onUpload(err)
+		}
+	}
+}
+
+func (f *file) flush() error {
+	if err := f.w.Flush(); err != nil {
+		return err
+	}
+	return f.gz.Flush()
+}
+
+// close finishes the gzip stream and renames the file as done
+func (f *file) close() error {
+	err := f.w.Flush()
+	err = errors.Join(err, f.gz.Close(), f.f.Close())
+	if err != nil {
+		return err
+	}
+	return os.Rename(f.path+partialExt, f.path)
+}
+
+// rawMessage returns message as JSON, quoting it if it isn't
+func rawMessage(message []byte) json.RawMessage {
+	if json.Valid(message) {
+		return message
+	}
+	quoted, _ := json.Marshal(string(message))
+	return quoted
+}
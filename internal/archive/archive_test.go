@@ -0,0 +1,96 @@
+package archive
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/FatwaArya/pm-ingest/internal/provenance"
+	"github.com/FatwaArya/pm-ingest/pkg/clock"
+)
+
+type memStore struct {
+	mu      sync.Mutex
+	objects map[string][]string // Lines by key
+}
+
+func (s *memStore) Upload(_ context.Context, key, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	var lines []string
+	scanner := bufio.NewScanner(gz)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.objects[key] = lines
+	return scanner.Err()
+}
+
+func TestArchiverHourlyFiles(t *testing.T) {
+	store := &memStore{objects: make(map[string][]string)}
+	a, err := NewArchiver(t.TempDir(), "archive", store, time.Hour, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake := clock.NewFake(time.Date(2025, 3, 1, 10, 30, 0, 0, time.UTC))
+	a.SetClock(fake)
+
+	a.WriteRaw([]byte(`{"topic": "activity",
+		"type": "trades"}`))
+	a.WriteRaw([]byte("pong?"))
+	fake.Advance(time.Hour)
+	a.WriteJSON(StreamTrades, map[string]string{"transactionHash": "0x1"})
+	if err := a.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	var keys []string
+	for key := range store.objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	instance := provenance.Current().InstanceID
+	want := []string{
+		"archive/raw/dt=2025-03-01/hour=10/" + instance + "-",
+		"archive/trades/dt=2025-03-01/hour=11/" + instance + "-",
+	}
+	if len(keys) != len(want) {
+		t.Fatalf("uploaded %v, want %d files", keys, len(want))
+	}
+	for i, key := range keys {
+		if !strings.HasPrefix(key, want[i]) || !strings.HasSuffix(key, ".jsonl.gz") {
+			t.Errorf("key %q, want %s*.jsonl.gz", key, want[i])
+		}
+	}
+
+	raw := store.objects[keys[0]]
+	if len(raw) != 2 {
+		t.Fatalf("raw lines = %q", raw)
+	}
+	var record Record
+	if err := json.Unmarshal([]byte(raw[0]), &record); err != nil {
+		t.Fatal(err)
+	}
+	if string(record.Message) != `{"topic":"activity","type":"trades"}` || !record.ReceivedAt.Equal(fake.Now().Add(-time.Hour)) {
+		t.Errorf("record = %s at %s", record.Message, record.ReceivedAt)
+	}
+	if err := json.Unmarshal([]byte(raw[1]), &record); err != nil || string(record.Message) != `"pong?"` {
+		t.Errorf("invalid JSON archived as %s (%v)", record.Message, err)
+	}
+}
@@ -0,0 +1,90 @@
+package archive
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// S3Config locates a bucket on S3 or a compatible store, e.g. GCS through
+// its XML API (storage.googleapis.com with HMAC keys) or MinIO
+type S3Config struct {
+	Endpoint  string // Host[:port], e.g. s3.amazonaws.com
+	Bucket    string
+	Region    string
+	AccessKey string // Empty uses the AWS environment variables or the instance role
+	SecretKey string
+	Insecure  bool // Plain HTTP, for local MinIO
+}
+
+// S3Store uploads files to a bucket
+type S3Store struct {
+	client *minio.Client
+	bucket string
+}
+
+// NewS3Store creates a store for cfg. It doesn't connect until the first
+// upload.
+func NewS3Store(cfg S3Config) (*S3Store, error) {
+	creds := credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, "")
+	if cfg.AccessKey == "" {
+		creds = credentials.NewChainCredentials([]credentials.Provider{
+			&credentials.EnvAWS{},
+			&credentials.IAM{},
+		})
+	}
+	client, err := minio.New(cfg.Endpoint, &minio.Options{
+		Creds:  creds,
+		Secure: !cfg.Insecure,
+		Region: cfg.Region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create S3 client: %w", err)
+	}
+	return &S3Store{client: client, bucket: cfg.Bucket}, nil
+}
+
+// Upload copies the file at path to key in the bucket
+func (s *S3Store) Upload(ctx context.Context, key, path string) error {
+	_, err := s.client.FPutObject(ctx, s.bucket, key, path, minio.PutObjectOptions{
+		ContentType: "application/gzip",
+	})
+	return err
+}
+
+// List returns the keys of the archive files under prefix, sorted
+func (s *S3Store) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	for obj := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if obj.Err != nil {
+			return nil, obj.Err
+		}
+		if strings.HasSuffix(obj.Key, fileExt) {
+			keys = append(keys, obj.Key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// Open returns the content of the object at key
+func (s *S3Store) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	return s.client.GetObject(ctx, s.bucket, key, minio.GetObjectOptions{})
+}
+
+// Ping checks the bucket exists and is reachable
+func (s *S3Store) Ping(ctx context.Context) error {
+	ok, err := s.client.BucketExists(ctx, s.bucket)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("bucket %s doesn't exist", s.bucket)
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+// Package backfill loads historical trades from the Polymarket Data API into
+// the trades topic, so QuestDB and the other consumers can be bootstrapped
+// with history before the live feed starts.
+package backfill
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/FatwaArya/pm-ingest/pkg/dataapi"
+	"github.com/FatwaArya/pm-ingest/pkg/rtds"
+)
+
+const (
+	// DefaultPageSize is how many trades are requested per page
+	DefaultPageSize = 500
+	// maxOffset is the deepest the /trades endpoint pages; older trades
+	// need a narrower request (a market or user)
+	maxOffset = 10000
+)
+
+// ErrOffsetLimit is returned when the time range reaches further back than
+// the API pages. The newer trades were still produced.
+var ErrOffsetLimit = errors.New("reached the API offset limit before the start of the range")
+
+// TradeSource fetches pages of trades, newest first
+type TradeSource interface {
+	GetTrades(ctx context.Context, params dataapi.TradesQueryParams) ([]dataapi.Trade, error)
+}
+
+// TradeProducer writes a backfilled trade, e.g. *kafka.Producer
+type TradeProducer interface {
+	ProduceBackfilledTrade(ctx context.Context, trade *rtds.ActivityTradePayload) error
+}
+
+// Deduper reports whether a trade was already ingested, marking it
+// otherwise, e.g. *pipeline.Deduper. Trades that fail to produce are
+// forgotten so a resumed run retries them.
+type Deduper interface {
+	Seen(ctx context.Context, trade *rtds.ActivityTradePayload) (bool, error)
+	Forget(ctx context.Context, trade *rtds.ActivityTradePayload) error
+}
+
+// Request selects the trades to backfill. A zero From or To leaves that end
+// of the range open.
+type Request struct {
+	From    time.Time
+	To      time.Time
+	Markets []string // Condition IDs
+	User    string
+}
+
+// Stats summarizes a backfill run
+type Stats struct {
+	Pages    int   `json:"pages"`
+	Fetched  int   `json:"fetched"`
+	Produced int   `json:"produced"`
+	Skipped  int   `json:"skipped"` // Duplicates of trades already ingested
+	Oldest   int64 `json:"oldest"`  // Timestamp of the oldest trade produced
+}
+
+// Backfiller pages through the trades of a Request and produces those in
+// its time range
+type Backfiller struct {
+	source   TradeSource
+	producer TradeProducer
+	pageSize int
+	pause    time.Duration
+	dedupe   Deduper
+}
+
+// New creates a backfiller reading pages of pageSize trades (DefaultPageSize
+// if <= 0), pausing between pages to stay under the API rate limit
+func New(source TradeSource, producer TradeProducer, pageSize int, pause time.Duration) *Backfiller {
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
+	return &Backfiller{source: source, producer: producer, pageSize: pageSize, pause: pause}
+}
+
+// SetDeduper skips trades d has already seen, live or in an earlier page
+// (pages shift while new trades arrive)
+func (b *Backfiller) SetDeduper(d Deduper) {
+	b.dedupe = d
+}
+
+// Run backfills req. It stops at the first page or produce error, returning
+// the stats so far; since trades are read newest first, Stats.Oldest is
+// where a retry with To set to it can resume.
+func (b *Backfiller) Run(ctx context.Context, req Request) (Stats, error) {
+	var stats Stats
+	for offset := 0; ; offset += b.pageSize {
+		if offset >= maxOffset {
+			return stats, ErrOffsetLimit
+		}
+		page, err := b.source.GetTrades(ctx, dataapi.TradesQueryParams{
+			User:   req.User,
+			Market: req.Markets,
+			Limit:  min(b.pageSize, maxOffset-offset),
+			Offset: offset,
+		})
+		if err != nil {
+			return stats, fmt.Errorf("fetching trades at offset %d: %w", offset, err)
+		}
+		stats.Pages++
+		stats.Fetched += len(page)
+
+		for i := range page {
+			trade := &page[i]
+			ts := time.Unix(trade.Timestamp, 0)
+			if !req.To.IsZero() && ts.After(req.To) {
+				continue
+			}
+			if !req.From.IsZero() && ts.Before(req.From) {
+				return stats, nil // Newest first: everything after is older still
+			}
+			payload := Payload(trade)
+			if b.dedupe != nil {
+				seen, err := b.dedupe.Seen(ctx, payload)
+				if err != nil {
+					return stats, fmt.Errorf("checking trade %s: %w", trade.TransactionHash, err)
+				}
+				if seen {
+					stats.Skipped++
+					continue
+				}
+			}
+			if err := b.producer.ProduceBackfilledTrade(ctx, payload); err != nil {
+				if b.dedupe != nil {
+					b.dedupe.Forget(context.WithoutCancel(ctx), payload)
+				}
+				return stats, fmt.Errorf("producing trade %s: %w", trade.TransactionHash, err)
+			}
+			stats.Produced++
+			stats.Oldest = trade.Timestamp
+		}
+
+		if len(page) < b.pageSize {
+			return stats, nil
+		}
+		if b.pause > 0 {
+			select {
+			case <-ctx.Done():
+				return stats, ctx.Err()
+			case <-time.After(b.pause):
+			}
+		}
+	}
+}
+
+// Payload converts a Data API trade to the payload the live feed produces
+func Payload(t *dataapi.Trade) *rtds.ActivityTradePayload {
+	return &rtds.ActivityTradePayload{
+		Asset:              t.Asset,
+		Side:               t.Side,
+		Price:              t.Price,
+		Size:               t.Size,
+		Timestamp:          t.Timestamp,
+		TransactionHash:    t.TransactionHash,
+		ConditionID:        t.ConditionID,
+		OutcomeIndex:       t.OutcomeIndex,
+		MarketSlug:         t.Slug,
+		EventSlug:          t.EventSlug,
+		EventTitle:         t.Title,
+		OutcomeTitle:       t.Outcome,
+		ProxyWalletAddress: t.ProxyWallet,
+		Name:               t.Name,
+		Pseudonym:          t.Pseudonym,
+		Bio:                t.Bio,
+		Icon:               t.Icon,
+		ProfileImage:       t.ProfileImage,
+	}
+}
@@ -0,0 +1,135 @@
+// Package channels ingests RTDS topics other than activity trades. Each
+// channel parses its topic (see rtds.Channel) and routes parsed messages to
+// a Kafka topic; supporting a new RTDS topic is a matter of implementing
+// Channel and registering it, with no changes to the client or main.
+package channels
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/FatwaArya/pm-ingest/internal/logging"
+	"github.com/FatwaArya/pm-ingest/pkg/rtds"
+)
+
+// routeErrLog limits logging of messages that can't be routed
+var routeErrLog = logging.NewRateLimited(logging.For("channels"), 5*time.Second)
+
+// Channel is an RTDS topic ingested to Kafka
+type Channel interface {
+	rtds.Channel
+	// Route returns the Kafka topic (without the configured prefix) and
+	// record key for a message returned by Parse. An empty topic drops it.
+	Route(message any) (topic string, key []byte)
+}
+
+var (
+	mu       sync.RWMutex
+	registry = make(map[string]Channel)
+)
+
+// Register makes ch available under its topic name and teaches the RTDS
+// parser about the topic
+func Register(ch Channel) {
+	mu.Lock()
+	defer mu.Unlock()
+	registry[ch.Topic()] = ch
+	rtds.RegisterChannel(ch)
+}
+
+// Names returns the registered channel names, sorted
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Enabled returns the named channels, failing on unknown names
+func Enabled(names []string) ([]Channel, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+	var enabled []Channel
+	for _, name := range names {
+		ch, ok := registry[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown rtds channel %q (have %v)", name, Names())
+		}
+		enabled = append(enabled, ch)
+	}
+	return enabled, nil
+}
+
+// Subscriptions builds the subscriptions of every channel
+func Subscriptions(enabled []Channel) ([]rtds.Subscription, error) {
+	var subs []rtds.Subscription
+	for _, ch := range enabled {
+		s, err := ch.Subscriptions()
+		if err != nil {
+			return nil, fmt.Errorf("channel %s: %w", ch.Topic(), err)
+		}
+		subs = append(subs, s...)
+	}
+	return subs, nil
+}
+
+// Producer sends records, e.g. the Kafka producer
+type Producer interface {
+	Produce(ctx context.Context, topic string, key, value []byte) error
+}
+
+// Router produces parsed channel messages to <prefix><route topic>
+type Router struct {
+	producer Producer
+	prefix   string
+	enabled  []string
+}
+
+// NewRouter creates a router for the enabled channels; messages of other
+// registered channels are ignored
+func NewRouter(producer Producer, prefix string, enabled []Channel) *Router {
+	r := &Router{producer: producer, prefix: prefix}
+	for _, ch := range enabled {
+		r.enabled = append(r.enabled, ch.Topic())
+	}
+	return r
+}
+
+// Handle routes one parsed message of an RTDS topic
+func (r *Router) Handle(ctx context.Context, topic, messageType string, message any) {
+	if !slices.Contains(r.enabled, topic) {
+		return
+	}
+	ch, ok := lookup(topic)
+	if !ok {
+		return
+	}
+	kafkaTopic, key := ch.Route(message)
+	if kafkaTopic == "" {
+		return
+	}
+	value, err := json.Marshal(message)
+	if err != nil {
+		routeErrLog.Printf("Error encoding %s/%s message: %v", topic, messageType, err)
+		return
+	}
+	if err := r.producer.Produce(ctx, r.prefix+kafkaTopic, key, value); err != nil {
+		routeErrLog.Printf("Error producing %s/%s message: %v", topic, messageType, err)
+	}
+}
+
+func lookup(topic string) (Channel, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	ch, ok := registry[topic]
+	return ch, ok
+}
@@ -0,0 +1,49 @@
+package channels
+
+import (
+	"encoding/json"
+
+	"github.com/FatwaArya/pm-ingest/pkg/rtds"
+)
+
+func init() {
+	Register(comments{})
+}
+
+// comments ingests market comments, keyed by comment ID
+type comments struct{}
+
+// CommentEvent is a comment created or removed, as produced to Kafka
+type CommentEvent struct {
+	Type    string        `json:"type"` // comment_created or comment_removed
+	Comment *rtds.Comment `json:"comment"`
+}
+
+func (comments) Topic() string { return rtds.TopicComments }
+
+func (comments) Types() []string {
+	return []string{rtds.TypeCommentCreated, rtds.TypeCommentRemoved, rtds.TypeAll}
+}
+
+func (comments) Subscriptions() ([]rtds.Subscription, error) {
+	return []rtds.Subscription{rtds.NewCommentsSubscription()}, nil
+}
+
+func (comments) Parse(messageType string, payload json.RawMessage) (any, error) {
+	if messageType != rtds.TypeCommentCreated && messageType != rtds.TypeCommentRemoved {
+		return nil, nil
+	}
+	comment, err := rtds.ParseComment(payload)
+	if err != nil {
+		return nil, err
+	}
+	return &CommentEvent{Type: messageType, Comment: comment}, nil
+}
+
+func (comments) Route(message any) (string, []byte) {
+	event, ok := message.(*CommentEvent)
+	if !ok {
+		return "", nil
+	}
+	return "comments", []byte(event.Comment.ID)
+}
@@ -0,0 +1,80 @@
+package channels
+
+import (
+	"encoding/json"
+
+	"github.com/FatwaArya/pm-ingest/pkg/rtds"
+)
+
+// Crypto price sources, recorded on each price
+const (
+	SourceBinance   = "binance"
+	SourceChainlink = "chainlink"
+)
+
+func init() {
+	Register(CryptoPrices())
+	Register(ChainlinkPrices())
+}
+
+// cryptoPrices ingests the reference prices of crypto markets from one of
+// the crypto_prices topics, keyed by symbol
+type cryptoPrices struct {
+	topic   string
+	source  string
+	symbols []string
+}
+
+// CryptoPrices returns the channel of Binance prices of symbols (e.g.
+// btcusdt), or of every symbol when none are given. Registering it
+// replaces the default channel of every symbol.
+func CryptoPrices(symbols ...string) Channel {
+	return cryptoPrices{topic: rtds.TopicCryptoPrices, source: SourceBinance, symbols: symbols}
+}
+
+// ChainlinkPrices returns the channel of Chainlink prices of symbols (e.g.
+// btc/usd), or of every symbol when none are given
+func ChainlinkPrices(symbols ...string) Channel {
+	return cryptoPrices{topic: rtds.TopicCryptoPricesChainlink, source: SourceChainlink, symbols: symbols}
+}
+
+func (c cryptoPrices) Topic() string { return c.topic }
+
+func (c cryptoPrices) Types() []string {
+	return []string{rtds.TypeUpdate, rtds.TypeAll}
+}
+
+func (c cryptoPrices) Subscriptions() ([]rtds.Subscription, error) {
+	if c.topic == rtds.TopicCryptoPrices {
+		return []rtds.Subscription{rtds.NewCryptoPricesSubscription(c.symbols...)}, nil
+	}
+	if len(c.symbols) == 0 {
+		return []rtds.Subscription{rtds.NewChainlinkPricesSubscription("")}, nil
+	}
+	// The Chainlink topic filters one symbol per subscription
+	subs := make([]rtds.Subscription, 0, len(c.symbols))
+	for _, symbol := range c.symbols {
+		subs = append(subs, rtds.NewChainlinkPricesSubscription(symbol))
+	}
+	return subs, nil
+}
+
+func (c cryptoPrices) Parse(messageType string, payload json.RawMessage) (any, error) {
+	price, err := rtds.ParseCryptoPrice(payload)
+	if err != nil {
+		return nil, err
+	}
+	if price.Symbol == "" {
+		return nil, nil
+	}
+	price.Source = c.source
+	return price, nil
+}
+
+func (c cryptoPrices) Route(message any) (string, []byte) {
+	price, ok := message.(*rtds.CryptoPrice)
+	if !ok {
+		return "", nil
+	}
+	return "crypto_prices", []byte(price.Source + ":" + price.Symbol)
+}
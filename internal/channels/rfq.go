@@ -0,0 +1,64 @@
+package channels
+
+import (
+	"encoding/json"
+
+	"github.com/FatwaArya/pm-ingest/pkg/rtds"
+)
+
+func init() {
+	Register(rfq{})
+}
+
+// rfq ingests requests for quote and their quotes, keyed by request ID so
+// a request and its quotes stay in order on one partition
+type rfq struct{}
+
+// RFQEvent is a request or quote event, as produced to Kafka
+type RFQEvent struct {
+	Type    string           `json:"type"` // request_created, quote_created, ...
+	Request *rtds.RFQRequest `json:"request,omitempty"`
+	Quote   *rtds.RFQQuote   `json:"quote,omitempty"`
+}
+
+func (rfq) Topic() string { return rtds.TopicRFQ }
+
+func (rfq) Types() []string {
+	return []string{
+		rtds.TypeRequestCreated, rtds.TypeRequestEdited, rtds.TypeRequestCanceled, rtds.TypeRequestExpired,
+		rtds.TypeQuoteCreated, rtds.TypeQuoteEdited, rtds.TypeQuoteCanceled, rtds.TypeQuoteExpired, rtds.TypeAll,
+	}
+}
+
+func (rfq) Subscriptions() ([]rtds.Subscription, error) {
+	return []rtds.Subscription{rtds.NewRFQSubscription()}, nil
+}
+
+func (rfq) Parse(messageType string, payload json.RawMessage) (any, error) {
+	switch {
+	case rtds.IsRFQRequest(messageType):
+		request, err := rtds.ParseRFQRequest(payload)
+		if err != nil {
+			return nil, err
+		}
+		return &RFQEvent{Type: messageType, Request: request}, nil
+	case rtds.IsRFQQuote(messageType):
+		quote, err := rtds.ParseRFQQuote(payload)
+		if err != nil {
+			return nil, err
+		}
+		return &RFQEvent{Type: messageType, Quote: quote}, nil
+	}
+	return nil, nil
+}
+
+func (rfq) Route(message any) (string, []byte) {
+	event, ok := message.(*RFQEvent)
+	if !ok {
+		return "", nil
+	}
+	if event.Request != nil {
+		return "rfq", []byte(event.Request.RequestID)
+	}
+	return "rfq", []byte(event.Quote.RequestID)
+}
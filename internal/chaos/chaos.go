@@ -0,0 +1,173 @@
+// Package chaos injects faults for resilience testing: WebSocket
+// disconnects, Kafka produce failures, Polymarket API 429/500 responses and
+// QuestDB flush errors, each at a configured rate. It exists to exercise
+// the reconnect, retry and WAL paths under failure storms and must never
+// be enabled in production.
+package chaos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/FatwaArya/pm-ingest/pkg/clock"
+)
+
+// ErrInjected is the error returned by injected faults
+var ErrInjected = errors.New("chaos: injected fault")
+
+// Config sets the probability of each fault, from 0 (never) to 1 (always).
+// WSDisconnectRate applies once per second, the others per operation.
+type Config struct {
+	WSDisconnectRate float64
+	KafkaFailRate    float64
+	APIErrorRate     float64
+	QuestDBFailRate  float64
+	Seed             uint64 // 0 seeds from the clock
+}
+
+// Fault kinds, as counted by Stats
+const (
+	FaultWSDisconnect = "ws_disconnect"
+	FaultKafka        = "kafka_produce"
+	FaultAPI          = "api_error"
+	FaultQuestDB      = "questdb_flush"
+)
+
+// Injector decides when to inject faults
+type Injector struct {
+	cfg   Config
+	clock clock.Clock
+
+	mu  sync.Mutex
+	rng *rand.Rand
+
+	injected map[string]*atomic.Uint64
+}
+
+// New creates an injector with the given rates
+func New(cfg Config) *Injector {
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = uint64(time.Now().UnixNano())
+	}
+	i := &Injector{
+		cfg:      cfg,
+		clock:    clock.Real,
+		rng:      rand.New(rand.NewPCG(seed, seed)),
+		injected: make(map[string]*atomic.Uint64),
+	}
+	for _, kind := range []string{FaultWSDisconnect, FaultKafka, FaultAPI, FaultQuestDB} {
+		i.injected[kind] = new(atomic.Uint64)
+	}
+	return i
+}
+
+// SetClock replaces the clock driving disconnects
+func (i *Injector) SetClock(c clock.Clock) {
+	i.clock = clock.OrReal(c)
+}
+
+// roll reports whether a fault of kind with probability rate fires
+func (i *Injector) roll(kind string, rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+	i.mu.Lock()
+	hit := i.rng.Float64() < rate
+	i.mu.Unlock()
+	if hit {
+		i.injected[kind].Add(1)
+	}
+	return hit
+}
+
+// fault returns a hook failing with ErrInjected at rate
+func (i *Injector) fault(kind string, rate float64) func() error {
+	return func() error {
+		if i.roll(kind, rate) {
+			return fmt.Errorf("%w: %s", ErrInjected, kind)
+		}
+		return nil
+	}
+}
+
+// KafkaFault is a hook for kafka.Producer.SetFaultInjector
+func (i *Injector) KafkaFault() func() error {
+	return i.fault(FaultKafka, i.cfg.KafkaFailRate)
+}
+
+// QuestDBFault is a hook for sink.QuestDBSink.SetFaultInjector
+func (i *Injector) QuestDBFault() func() error {
+	return i.fault(FaultQuestDB, i.cfg.QuestDBFailRate)
+}
+
+// RunDisconnects calls disconnect with probability WSDisconnectRate every
+// second until ctx is cancelled
+func (i *Injector) RunDisconnects(ctx context.Context, disconnect func()) error {
+	if i.cfg.WSDisconnectRate <= 0 {
+		return nil
+	}
+	ticker := i.clock.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C():
+			if i.roll(FaultWSDisconnect, i.cfg.WSDisconnectRate) {
+				log.Println("Chaos: dropping the WebSocket connection")
+				disconnect()
+			}
+		}
+	}
+}
+
+// Transport wraps base so requests to Polymarket APIs fail with a 429 or
+// 500 response at APIErrorRate. Other hosts (QuestDB, ...) pass through.
+func (i *Injector) Transport(base http.RoundTripper) http.RoundTripper {
+	return roundTripper(func(req *http.Request) (*http.Response, error) {
+		if !strings.HasSuffix(req.URL.Hostname(), "polymarket.com") || !i.roll(FaultAPI, i.cfg.APIErrorRate) {
+			return base.RoundTrip(req)
+		}
+		status := http.StatusInternalServerError
+		header := http.Header{}
+		i.mu.Lock()
+		if i.rng.IntN(2) == 0 {
+			status = http.StatusTooManyRequests
+			header.Set("Retry-After", "1")
+		}
+		i.mu.Unlock()
+		return &http.Response{
+			Status:     fmt.Sprintf("%d %s", status, http.StatusText(status)),
+			StatusCode: status,
+			Proto:      "HTTP/1.1",
+			ProtoMajor: 1,
+			ProtoMinor: 1,
+			Header:     header,
+			Body:       io.NopCloser(strings.NewReader(`{"error":"chaos: injected fault"}`)),
+			Request:    req,
+		}, nil
+	})
+}
+
+type roundTripper func(*http.Request) (*http.Response, error)
+
+func (f roundTripper) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+// Stats returns the number of faults injected, by kind
+func (i *Injector) Stats() map[string]uint64 {
+	stats := make(map[string]uint64, len(i.injected))
+	for kind, n := range i.injected {
+		stats[kind] = n.Load()
+	}
+	return stats
+}
@@ -0,0 +1,85 @@
+// Package clickhouse writes trades, profiles and confidence scores to
+// ClickHouse over its HTTP interface, in batches of JSONEachRow inserts.
+package clickhouse
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/FatwaArya/pm-ingest/pkg/pmerrors"
+)
+
+// Config locates the server and how inserts are made
+type Config struct {
+	URL      string // HTTP interface, e.g. "http://clickhouse:8123"
+	Database string // Empty for the user's default database
+	User     string
+	Password string
+	// AsyncInsert lets the server buffer inserts and write them in larger
+	// parts; the insert still waits until its rows are written
+	AsyncInsert bool
+}
+
+// Client runs statements over the HTTP interface
+type Client struct {
+	cfg        Config
+	httpClient *http.Client
+}
+
+// NewClient creates a client for cfg
+func NewClient(cfg Config) *Client {
+	return &Client{cfg: cfg, httpClient: &http.Client{Timeout: 30 * time.Second}}
+}
+
+// Exec runs a statement that returns no rows, e.g. CREATE TABLE
+func (c *Client) Exec(ctx context.Context, query string) error {
+	return c.post(ctx, url.Values{}, []byte(query))
+}
+
+// Ping checks the server answers queries
+func (c *Client) Ping(ctx context.Context) error {
+	return c.Exec(ctx, "SELECT 1")
+}
+
+// Insert writes rows, JSON objects one per line, into table
+func (c *Client) Insert(ctx context.Context, table string, rows []byte) error {
+	q := url.Values{}
+	q.Set("query", "INSERT INTO "+table+" FORMAT JSONEachRow")
+	if c.cfg.AsyncInsert {
+		q.Set("async_insert", "1")
+		q.Set("wait_for_async_insert", "1")
+	}
+	return c.post(ctx, q, rows)
+}
+
+func (c *Client) post(ctx context.Context, q url.Values, body []byte) error {
+	if c.cfg.Database != "" {
+		q.Set("database", c.cfg.Database)
+	}
+	endpoint := c.cfg.URL + "/?" + q.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if c.cfg.User != "" {
+		req.Header.Set("X-ClickHouse-User", c.cfg.User)
+		req.Header.Set("X-ClickHouse-Key", c.cfg.Password)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return &pmerrors.APIError{StatusCode: resp.StatusCode, Body: string(body), URL: c.cfg.URL}
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
@@ -0,0 +1,196 @@
+package clickhouse
+
+import (
+	"strings"
+	"time"
+
+	internalqdb "github.com/FatwaArya/pm-ingest/internal"
+	"github.com/FatwaArya/pm-ingest/internal/provenance"
+	"github.com/FatwaArya/pm-ingest/pkg/rtds"
+)
+
+// dateTime encodes a time for a DateTime64(3) column; the zero time is null
+type dateTime time.Time
+
+func (t dateTime) MarshalJSON() ([]byte, error) {
+	if time.Time(t).IsZero() {
+		return []byte("null"), nil
+	}
+	return []byte(`"` + time.Time(t).UTC().Format("2006-01-02 15:04:05.000") + `"`), nil
+}
+
+type tradeRow struct {
+	Timestamp       dateTime `json:"timestamp"`
+	TransactionHash string   `json:"transaction_hash"`
+	Asset           string   `json:"asset"`
+	ConditionID     string   `json:"condition_id"`
+	MarketSlug      string   `json:"market_slug"`
+	EventSlug       string   `json:"event_slug"`
+	EventTitle      string   `json:"event_title"`
+	Outcome         string   `json:"outcome"`
+	OutcomeIndex    int      `json:"outcome_index"`
+	Side            string   `json:"side"`
+	Role            string   `json:"role"`
+	AggressorSide   string   `json:"aggressor_side"`
+	Price           float64  `json:"price"`
+	Size            float64  `json:"size"`
+	ProxyWallet     string   `json:"proxy_wallet"`
+	Maker           string   `json:"maker"`
+	Taker           string   `json:"taker"`
+	MakerOrderID    string   `json:"maker_order_id"`
+	TakerOrderID    string   `json:"taker_order_id"`
+	Name            string   `json:"name"`
+	Pseudonym       string   `json:"pseudonym"`
+	Labels          []string `json:"labels"`
+	Category        string   `json:"category"`
+	MarketEndDate   string   `json:"market_end_date"`
+	MarketLiquidity *float64 `json:"market_liquidity"` // Null for markets whose metadata isn't known
+	MarketVolume    *float64 `json:"market_volume"`
+	Tags            []string `json:"tags"`
+	InstanceID      string   `json:"instance_id"`
+	Hostname        string   `json:"hostname"`
+	Version         string   `json:"pipeline_version"`
+}
+
+func newTradeRow(trade *rtds.ActivityTradePayload, info provenance.Info) tradeRow {
+	row := tradeRow{
+		Timestamp:       dateTime(time.Unix(trade.Timestamp, 0)),
+		TransactionHash: trade.TransactionHash,
+		Asset:           trade.Asset,
+		ConditionID:     trade.ConditionID,
+		MarketSlug:      trade.MarketSlug,
+		EventSlug:       trade.EventSlug,
+		EventTitle:      trade.EventTitle,
+		Outcome:         trade.OutcomeTitle,
+		OutcomeIndex:    trade.OutcomeIndex,
+		Side:            trade.Side,
+		Role:            trade.Role(),
+		AggressorSide:   trade.AggressorSide(),
+		Price:           trade.Price,
+		Size:            trade.Size,
+		ProxyWallet:     trade.ProxyWalletAddress,
+		Maker:           trade.Maker,
+		Taker:           trade.Taker,
+		MakerOrderID:    trade.MakerOrderID,
+		TakerOrderID:    trade.TakerOrderID,
+		Name:            trade.Name,
+		Pseudonym:       trade.Pseudonym,
+		Labels:          nonNil(trade.Labels),
+		Category:        trade.Category,
+		MarketEndDate:   trade.MarketEndDate,
+		Tags:            nonNil(trade.Tags),
+		InstanceID:      info.InstanceID,
+		Hostname:        info.Hostname,
+		Version:         info.Version,
+	}
+	if trade.MarketLiquidity > 0 {
+		row.MarketLiquidity = &trade.MarketLiquidity
+	}
+	if trade.MarketVolume > 0 {
+		row.MarketVolume = &trade.MarketVolume
+	}
+	return row
+}
+
+type profileRow struct {
+	Timestamp          dateTime `json:"timestamp"`
+	Address            string   `json:"address"`
+	Owner              string   `json:"owner"`
+	Name               string   `json:"name"`
+	Pseudonym          string   `json:"pseudonym"`
+	Bio                string   `json:"bio"`
+	Icon               string   `json:"icon"`
+	ProfileImage       string   `json:"profile_image"`
+	XUsername          string   `json:"x_username"`
+	Verified           bool     `json:"verified"`
+	CreatedAt          string   `json:"profile_created_at"`
+	Labels             []string `json:"labels"`
+	TradesLastMinute   int      `json:"trades_last_minute"`
+	TradesLastHour     int      `json:"trades_last_hour"`
+	NotionalLastMinute float64  `json:"notional_last_minute"`
+	NotionalLastHour   float64  `json:"notional_last_hour"`
+	Burst              bool     `json:"burst"`
+	FirstSeen          dateTime `json:"first_seen"`
+	LastSeen           dateTime `json:"last_seen"`
+	InstanceID         string   `json:"instance_id"`
+	Hostname           string   `json:"hostname"`
+	Version            string   `json:"pipeline_version"`
+}
+
+func newProfileRow(p *internalqdb.UserProfile, info provenance.Info, at time.Time) profileRow {
+	var labels []string
+	if p.Labels != "" {
+		labels = strings.Split(p.Labels, ",")
+	}
+	return profileRow{
+		Timestamp:          dateTime(at),
+		Address:            p.Address,
+		Owner:              p.Owner,
+		Name:               p.Name,
+		Pseudonym:          p.Pseudonym,
+		Bio:                p.Bio,
+		Icon:               p.Icon,
+		ProfileImage:       p.ProfileImage,
+		XUsername:          p.XUsername,
+		Verified:           p.Verified,
+		CreatedAt:          p.CreatedAt,
+		Labels:             nonNil(labels),
+		TradesLastMinute:   p.TradesLastMinute,
+		TradesLastHour:     p.TradesLastHour,
+		NotionalLastMinute: p.NotionalLastMinute,
+		NotionalLastHour:   p.NotionalLastHour,
+		Burst:              p.Burst,
+		FirstSeen:          dateTime(p.FirstSeen),
+		LastSeen:           dateTime(p.LastSeen),
+		InstanceID:         info.InstanceID,
+		Hostname:           info.Hostname,
+		Version:            info.Version,
+	}
+}
+
+type confidenceRow struct {
+	Timestamp          dateTime `json:"timestamp"`
+	Wallet             string   `json:"wallet"`
+	BrierScore         float64  `json:"brier_score"`
+	Calibration        float64  `json:"calibration"`
+	WinRate            float64  `json:"win_rate"`
+	ConfidenceInterval float64  `json:"confidence_interval"`
+	SampleSize         int      `json:"sample_size"`
+	AvgRealizedPnl     float64  `json:"avg_realized_pnl"`
+	TotalRealizedPnl   float64  `json:"total_realized_pnl"`
+	PnlVolatility      float64  `json:"pnl_volatility"`
+	SharpeRatio        float64  `json:"sharpe_ratio"`
+	MaxDrawdown        float64  `json:"max_drawdown"`
+	ProfitFactor       float64  `json:"profit_factor"`
+	NoLosses           bool     `json:"no_losses"`
+	ROI                float64  `json:"roi"`
+}
+
+func newConfidenceRow(s *internalqdb.ConfidenceScore) confidenceRow {
+	return confidenceRow{
+		Timestamp:          dateTime(s.ComputedAt),
+		Wallet:             s.Wallet,
+		BrierScore:         s.BrierScore,
+		Calibration:        s.Calibration,
+		WinRate:            s.WinRate,
+		ConfidenceInterval: s.ConfidenceInterval,
+		SampleSize:         s.SampleSize,
+		AvgRealizedPnl:     s.AvgRealizedPnl,
+		TotalRealizedPnl:   s.TotalRealizedPnl,
+		PnlVolatility:      s.PnlVolatility,
+		SharpeRatio:        s.SharpeRatio,
+		MaxDrawdown:        s.MaxDrawdown,
+		ProfitFactor:       s.ProfitFactor,
+		NoLosses:           s.NoLosses,
+		ROI:                s.ROI,
+	}
+}
+
+// nonNil makes a nil slice encode as [] rather than null, which Array
+// columns reject
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
@@ -0,0 +1,120 @@
+package clickhouse
+
+import (
+	"context"
+	"fmt"
+)
+
+// Tables names the tables written to
+type Tables struct {
+	Trades     string
+	Profiles   string
+	Confidence string
+}
+
+// DefaultTables match the QuestDB table names
+var DefaultTables = Tables{
+	Trades:     "polymarket_trades",
+	Profiles:   "user_profiles",
+	Confidence: "confidence_scores",
+}
+
+// CreateTables creates the tables that don't exist yet, and adds the columns
+// added since to confidence tables that do. Trades are deduplicated by their
+// fill identity on merges, like the QuestDB table's dedup keys; profiles
+// keep their latest version per address.
+func (c *Client) CreateTables(ctx context.Context, t Tables) error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS ` + t.Trades + ` (
+			timestamp DateTime64(3, 'UTC'),
+			transaction_hash String,
+			asset String,
+			condition_id LowCardinality(String),
+			market_slug LowCardinality(String),
+			event_slug LowCardinality(String),
+			event_title String,
+			outcome LowCardinality(String),
+			outcome_index UInt8,
+			side LowCardinality(String),
+			role LowCardinality(String),
+			aggressor_side LowCardinality(String),
+			price Float64,
+			size Float64,
+			proxy_wallet String,
+			maker String,
+			taker String,
+			maker_order_id String,
+			taker_order_id String,
+			name String,
+			pseudonym String,
+			labels Array(LowCardinality(String)),
+			category LowCardinality(String),
+			market_end_date String,
+			market_liquidity Nullable(Float64),
+			market_volume Nullable(Float64),
+			tags Array(LowCardinality(String)),
+			instance_id LowCardinality(String),
+			hostname LowCardinality(String),
+			pipeline_version LowCardinality(String)
+		) ENGINE = ReplacingMergeTree
+		PARTITION BY toYYYYMM(timestamp)
+		ORDER BY (condition_id, timestamp, transaction_hash, asset, proxy_wallet, side, size, price)`,
+		`CREATE TABLE IF NOT EXISTS ` + t.Profiles + ` (
+			timestamp DateTime64(3, 'UTC'),
+			address String,
+			owner String,
+			name String,
+			pseudonym String,
+			bio String,
+			icon String,
+			profile_image String,
+			x_username String,
+			verified Bool,
+			profile_created_at String,
+			labels Array(LowCardinality(String)),
+			trades_last_minute UInt32,
+			trades_last_hour UInt32,
+			notional_last_minute Float64,
+			notional_last_hour Float64,
+			burst Bool,
+			first_seen Nullable(DateTime64(3, 'UTC')),
+			last_seen Nullable(DateTime64(3, 'UTC')),
+			instance_id LowCardinality(String),
+			hostname LowCardinality(String),
+			pipeline_version LowCardinality(String)
+		) ENGINE = ReplacingMergeTree(timestamp)
+		ORDER BY address`,
+		`CREATE TABLE IF NOT EXISTS ` + t.Confidence + ` (
+			timestamp DateTime64(3, 'UTC'),
+			wallet String,
+			brier_score Float64,
+			calibration Float64,
+			win_rate Float64,
+			confidence_interval Float64,
+			sample_size UInt32,
+			avg_realized_pnl Float64,
+			total_realized_pnl Float64,
+			pnl_volatility Float64,
+			sharpe_ratio Float64,
+			max_drawdown Float64,
+			profit_factor Float64,
+			no_losses Bool,
+			roi Float64
+		) ENGINE = MergeTree
+		PARTITION BY toYYYYMM(timestamp)
+		ORDER BY (wallet, timestamp)`,
+		`ALTER TABLE ` + t.Confidence + `
+			ADD COLUMN IF NOT EXISTS pnl_volatility Float64,
+			ADD COLUMN IF NOT EXISTS sharpe_ratio Float64,
+			ADD COLUMN IF NOT EXISTS max_drawdown Float64,
+			ADD COLUMN IF NOT EXISTS profit_factor Float64,
+			ADD COLUMN IF NOT EXISTS no_losses Bool,
+			ADD COLUMN IF NOT EXISTS roi Float64`,
+	}
+	for _, stmt := range statements {
+		if err := c.Exec(ctx, stmt); err != nil {
+			return fmt.Errorf("failed to create table: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+package clickhouse
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	internalqdb "github.com/FatwaArya/pm-ingest/internal"
+	"github.com/FatwaArya/pm-ingest/internal/provenance"
+	"github.com/FatwaArya/pm-ingest/pkg/clock"
+	"github.com/FatwaArya/pm-ingest/pkg/rtds"
+)
+
+// maxBufferedRows bounds a table's buffer while ClickHouse is down; older
+// rows are dropped beyond it
+const maxBufferedRows = 100000
+
+// Writer buffers rows per table and inserts them in batches: when a table
+// has batchSize rows, and every flush interval. Writes don't block on
+// ClickHouse; a failed insert is kept and retried with the next one.
+type Writer struct {
+	client    *Client
+	tables    Tables
+	batchSize int
+	clock     clock.Clock
+
+	mu      sync.Mutex
+	buffers map[string]*batch
+	full    chan struct{} // A table reached batchSize
+
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+	onFlush   func(error)
+	dropped   atomic.Uint64
+}
+
+type batch struct {
+	rows bytes.Buffer
+	n    int
+}
+
+// NewWriter creates the tables if needed and starts flushing every
+// interval. onFlush, which may be nil, is called with the outcome of every
+// background flush.
+func NewWriter(ctx context.Context, client *Client, tables Tables, batchSize int, interval time.Duration, onFlush func(error)) (*Writer, error) {
+	if err := client.CreateTables(ctx, tables); err != nil {
+		return nil, err
+	}
+	w := &Writer{
+		client:    client,
+		tables:    tables,
+		batchSize: max(batchSize, 1),
+		clock:     clock.Real,
+		buffers:   make(map[string]*batch),
+		full:      make(chan struct{}, 1),
+		done:      make(chan struct{}),
+		onFlush:   onFlush,
+	}
+	w.wg.Add(1)
+	go w.flushLoop(interval)
+	return w, nil
+}
+
+// WriteTrade buffers a trade row
+func (w *Writer) WriteTrade(trade *rtds.ActivityTradePayload) error {
+	return w.add(w.tables.Trades, newTradeRow(trade, provenance.Current()))
+}
+
+// WriteProfile buffers a profile row, timestamped now
+func (w *Writer) WriteProfile(profile *internalqdb.UserProfile) error {
+	return w.add(w.tables.Profiles, newProfileRow(profile, provenance.Current(), w.clock.Now()))
+}
+
+// WriteConfidence buffers a confidence score row
+func (w *Writer) WriteConfidence(score *internalqdb.ConfidenceScore) error {
+	return w.add(w.tables.Confidence, newConfidenceRow(score))
+}
+
+func (w *Writer) add(table string, row any) error {
+	data, err := json.Marshal(row)
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	b := w.buffers[table]
+	if b == nil {
+		b = &batch{}
+		w.buffers[table] = b
+	}
+	if b.n >= maxBufferedRows {
+		// Drop the oldest row: the first line
+		if i := bytes.IndexByte(b.rows.Bytes(), '\n'); i >= 0 {
+			b.rows.Next(i + 1)
+			b.n--
+			w.dropped.Add(1)
+		}
+	}
+	b.rows.Write(data)
+	b.rows.WriteByte('\n')
+	b.n++
+	full := b.n >= w.batchSize
+	w.mu.Unlock()
+
+	if full {
+		select {
+		case w.full <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Flush inserts every buffered row. Rows of a failed insert stay buffered.
+func (w *Writer) Flush(ctx context.Context) error {
+	w.mu.Lock()
+	pending := w.buffers
+	w.buffers = make(map[string]*batch)
+	w.mu.Unlock()
+
+	var errs []error
+	for table, b := range pending {
+		if b.n == 0 {
+			continue
+		}
+		if err := w.client.Insert(ctx, table, b.rows.Bytes()); err != nil {
+			errs = append(errs, err)
+			w.requeue(table, b)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// requeue puts the rows of a failed insert back ahead of newer ones
+func (w *Writer) requeue(table string, b *batch) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if newer := w.buffers[table]; newer != nil {
+		b.rows.Write(newer.rows.Bytes())
+		b.n += newer.n
+	}
+	for b.n > maxBufferedRows {
+		i := bytes.IndexByte(b.rows.Bytes(), '\n')
+		b.rows.Next(i + 1)
+		b.n--
+		w.dropped.Add(1)
+	}
+	w.buffers[table] = b
+}
+
+// Dropped returns the rows dropped because a table's buffer was full
+func (w *Writer) Dropped() uint64 {
+	return w.dropped.Load()
+}
+
+// Close stops the background flush and flushes what's left
+func (w *Writer) Close(ctx context.Context) error {
+	w.closeOnce.Do(func() { close(w.done) })
+	w.wg.Wait()
+	return w.Flush(ctx)
+}
+
+func (w *Writer) flushLoop(interval time.Duration) {
+	defer w.wg.Done()
+	ticker := w.clock.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C():
+		case <-w.full:
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		err := w.Flush(ctx)
+		cancel()
+		if w.onFlush != nil {
+			w.onFlush(err)
+		}
+	}
+}
@@ -0,0 +1,297 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	internalqdb "github.com/FatwaArya/pm-ingest/internal"
+	"github.com/FatwaArya/pm-ingest/pkg/clock"
+	"github.com/FatwaArya/pm-ingest/pkg/rtds"
+)
+
+const (
+	// sessionGap is the idle time that ends a trading session
+	sessionGap = 30 * time.Minute
+	// reactionWindow is how long after a market is listed a wallet's first
+	// trade in it counts as a reaction
+	reactionWindow = 24 * time.Hour
+
+	// Bot heuristics: with enough trades, trading around the clock, firing
+	// trades seconds apart or jumping on new markets within seconds
+	botMinTrades      = 50
+	botDistinctHours  = 20
+	botMaxAvgGap      = 5 * time.Second
+	botMaxAvgReaction = 10 * time.Second
+	botMinReactions   = 3
+)
+
+// ActivityProfile is when and how a wallet trades. Hours and days are UTC.
+type ActivityProfile struct {
+	Wallet                string    `json:"wallet"`
+	Trades                int64     `json:"trades"`
+	ActiveHours           [24]int64 `json:"activeHours"`
+	ActiveDays            [7]int64  `json:"activeDays"` // From Sunday
+	DistinctHours         int       `json:"distinctHours"`
+	HourEntropy           float64   `json:"hourEntropy"` // 0 (one hour) to 1 (evenly spread over the day)
+	Sessions              int       `json:"sessions"`
+	AvgSessionMinutes     float64   `json:"avgSessionMinutes"`
+	LongestSessionMinutes float64   `json:"longestSessionMinutes"`
+	AvgGapSeconds         float64   `json:"avgGapSeconds"` // Between trades within a session
+	ReactionMarkets       int       `json:"reactionMarkets"`
+	AvgReactionSeconds    float64   `json:"avgReactionSeconds"` // From market listing to the wallet's first trade in it
+	LikelyBot             bool      `json:"likelyBot"`
+}
+
+type walletActivity struct {
+	hours        [24]int64
+	days         [7]int64
+	trades       int64
+	sessionStart int64 // Unix seconds
+	lastTrade    int64
+	sessions     int // Completed sessions
+	sessionTotal int64
+	longest      int64
+	gapSum       int64
+	gaps         int64
+	markets      map[string]bool // Markets traded, for first-trade reactions
+	reactionSum  int64
+	reactions    int
+	lastSeen     time.Time
+	dirty        bool
+}
+
+// ActivityTracker builds time-of-day and session profiles per wallet from
+// the trade stream, and periodically writes the changed ones to QuestDB.
+// Wallets without trades for the retention period are dropped.
+type ActivityTracker struct {
+	mu        sync.Mutex
+	clock     clock.Clock
+	retention time.Duration
+	wallets   map[string]*walletActivity
+	listed    map[string]int64 // Condition ID -> listing time, for new markets
+	lastSweep time.Time
+}
+
+// NewActivityTracker creates a tracker keeping idle wallets for retention
+func NewActivityTracker(retention time.Duration) *ActivityTracker {
+	return &ActivityTracker{
+		clock:     clock.Real,
+		retention: retention,
+		wallets:   make(map[string]*walletActivity),
+		listed:    make(map[string]int64),
+		lastSweep: time.Now(),
+	}
+}
+
+// SetClock replaces the clock used for eviction and the write ticker
+func (t *ActivityTracker) SetClock(c clock.Clock) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.clock = clock.OrReal(c)
+	t.lastSweep = t.clock.Now()
+}
+
+// MarketListed records when a new market appeared, so wallets' reaction
+// time to it can be measured; see MarketDetector.OnNewMarket
+func (t *ActivityTracker) MarketListed(m NewMarket) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.listed[m.ConditionID]; !ok {
+		t.listed[m.ConditionID] = m.FirstTrade.Timestamp
+	}
+}
+
+// Record adds a trade to its wallet's profile
+func (t *ActivityTracker) Record(trade *rtds.ActivityTradePayload) {
+	wallet := strings.ToLower(trade.ProxyWalletAddress)
+	if wallet == "" || trade.Timestamp <= 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.clock.Now()
+	t.sweep(now)
+
+	a, ok := t.wallets[wallet]
+	if !ok {
+		a = &walletActivity{markets: make(map[string]bool)}
+		t.wallets[wallet] = a
+	}
+	a.lastSeen = now
+	a.dirty = true
+
+	ts := trade.Timestamp
+	at := time.Unix(ts, 0).UTC()
+	a.hours[at.Hour()]++
+	a.days[at.Weekday()]++
+	a.trades++
+
+	switch {
+	case a.trades == 1:
+		a.sessionStart, a.lastTrade = ts, ts
+	case ts < a.lastTrade:
+		// Late trade; counted by hour but not in sessions
+	case time.Duration(ts-a.lastTrade)*time.Second > sessionGap:
+		a.closeSession()
+		a.sessionStart, a.lastTrade = ts, ts
+	default:
+		a.gapSum += ts - a.lastTrade
+		a.gaps++
+		a.lastTrade = ts
+	}
+
+	if market := trade.ConditionID; market != "" && !a.markets[market] {
+		a.markets[market] = true
+		if listed, ok := t.listed[market]; ok && ts >= listed && time.Duration(ts-listed)*time.Second <= reactionWindow {
+			a.reactionSum += ts - listed
+			a.reactions++
+		}
+	}
+}
+
+func (a *walletActivity) closeSession() {
+	length := a.lastTrade - a.sessionStart
+	a.sessions++
+	a.sessionTotal += length
+	a.longest = max(a.longest, length)
+}
+
+// Profile returns the activity profile of a wallet
+func (t *ActivityTracker) Profile(address string) (ActivityProfile, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	a, ok := t.wallets[strings.ToLower(address)]
+	if !ok {
+		return ActivityProfile{}, false
+	}
+	return a.profile(strings.ToLower(address)), true
+}
+
+func (a *walletActivity) profile(wallet string) ActivityProfile {
+	p := ActivityProfile{
+		Wallet:          wallet,
+		Trades:          a.trades,
+		ActiveHours:     a.hours,
+		ActiveDays:      a.days,
+		ReactionMarkets: a.reactions,
+	}
+
+	var entropy float64
+	for _, n := range a.hours {
+		if n == 0 {
+			continue
+		}
+		p.DistinctHours++
+		share := float64(n) / float64(a.trades)
+		entropy -= share * math.Log(share)
+	}
+	p.HourEntropy = entropy / math.Log(24)
+
+	// The open session counts as one, up to its last trade
+	current := a.lastTrade - a.sessionStart
+	p.Sessions = a.sessions + 1
+	p.AvgSessionMinutes = float64(a.sessionTotal+current) / float64(p.Sessions) / 60
+	p.LongestSessionMinutes = float64(max(a.longest, current)) / 60
+	if a.gaps > 0 {
+		p.AvgGapSeconds = float64(a.gapSum) / float64(a.gaps)
+	}
+	if a.reactions > 0 {
+		p.AvgReactionSeconds = float64(a.reactionSum) / float64(a.reactions)
+	}
+
+	p.LikelyBot = p.Trades >= botMinTrades && (p.DistinctHours >= botDistinctHours ||
+		(a.gaps > 0 && p.AvgGapSeconds < botMaxAvgGap.Seconds()) ||
+		(a.reactions >= botMinReactions && p.AvgReactionSeconds < botMaxAvgReaction.Seconds()))
+	return p
+}
+
+// Run writes the profiles of wallets that traded since the last run to
+// writer every interval, until ctx is cancelled
+func (t *ActivityTracker) Run(ctx context.Context, writer *internalqdb.ActivityWriter, interval time.Duration) error {
+	ticker := t.clock.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C():
+			if err := t.write(ctx, writer); err != nil {
+				writeErrLog.Printf("Error writing wallet activity to QuestDB: %v", err)
+			}
+		}
+	}
+}
+
+// write sends the changed profiles and flushes them
+func (t *ActivityTracker) write(ctx context.Context, writer *internalqdb.ActivityWriter) error {
+	t.mu.Lock()
+	now := t.clock.Now()
+	var changed []ActivityProfile
+	for wallet, a := range t.wallets {
+		if a.dirty {
+			changed = append(changed, a.profile(wallet))
+			a.dirty = false
+		}
+	}
+	t.mu.Unlock()
+
+	for _, p := range changed {
+		if err := writer.Write(ctx, p.row(), now); err != nil {
+			return err
+		}
+	}
+	return writer.Flush(ctx)
+}
+
+func (p ActivityProfile) row() *internalqdb.WalletActivity {
+	return &internalqdb.WalletActivity{
+		Address:               p.Wallet,
+		Trades:                p.Trades,
+		ActiveHours:           joinCounts(p.ActiveHours[:]),
+		ActiveDays:            joinCounts(p.ActiveDays[:]),
+		DistinctHours:         p.DistinctHours,
+		HourEntropy:           p.HourEntropy,
+		Sessions:              p.Sessions,
+		AvgSessionMinutes:     p.AvgSessionMinutes,
+		LongestSessionMinutes: p.LongestSessionMinutes,
+		AvgGapSeconds:         p.AvgGapSeconds,
+		ReactionMarkets:       p.ReactionMarkets,
+		AvgReactionSeconds:    p.AvgReactionSeconds,
+		LikelyBot:             p.LikelyBot,
+	}
+}
+
+func joinCounts(counts []int64) string {
+	parts := make([]string, len(counts))
+	for i, n := range counts {
+		parts[i] = fmt.Sprint(n)
+	}
+	return strings.Join(parts, ",")
+}
+
+// sweep drops idle wallets and markets listed too long ago to react to;
+// called with mu held
+func (t *ActivityTracker) sweep(now time.Time) {
+	if now.Sub(t.lastSweep) < flowSweepInterval {
+		return
+	}
+	t.lastSweep = now
+	if t.retention > 0 {
+		for wallet, a := range t.wallets {
+			if now.Sub(a.lastSeen) > t.retention {
+				delete(t.wallets, wallet)
+			}
+		}
+	}
+	cutoff := now.Add(-reactionWindow).Unix()
+	for market, listed := range t.listed {
+		if listed < cutoff {
+			delete(t.listed, market)
+		}
+	}
+}
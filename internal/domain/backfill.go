@@ -0,0 +1,118 @@
+package domain
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/FatwaArya/pm-ingest/internal/events"
+	"github.com/FatwaArya/pm-ingest/internal/logging"
+	"github.com/FatwaArya/pm-ingest/pkg/clock"
+	"github.com/FatwaArya/pm-ingest/pkg/dataapi"
+)
+
+// backfillTimeout bounds scoring one wallet, which can take several pages
+// of closed positions
+const backfillTimeout = 2 * time.Minute
+
+// BackfillStats summarizes a confidence backfill run
+type BackfillStats struct {
+	Wallets int // Wallets attempted
+	Scored  int // Wallets with a computed score
+	Failed  int // Wallets whose positions couldn't be fetched
+}
+
+// ConfidenceBackfill computes confidence for a batch of wallets, e.g. every
+// wallet in the profiles table, so traders discovered before confidence
+// scoring existed get a score without waiting to trade again. Each result is
+// passed to the OnScore hooks and published as a wallet.confidence event.
+type ConfidenceBackfill struct {
+	apiClient    *dataapi.Client
+	emitter      events.Emitter
+	concurrency  int
+	maxPositions int
+	clock        clock.Clock
+	onScore      []func(ctx context.Context, score ScoredConfidence)
+}
+
+// NewConfidenceBackfill creates a backfill scoring concurrency wallets at a
+// time from up to maxPositions closed positions each (0 for all)
+func NewConfidenceBackfill(apiClient *dataapi.Client, emitter events.Emitter, concurrency, maxPositions int) *ConfidenceBackfill {
+	return &ConfidenceBackfill{
+		apiClient:    apiClient,
+		emitter:      emitter,
+		concurrency:  max(concurrency, 1),
+		maxPositions: maxPositions,
+		clock:        clock.Real,
+	}
+}
+
+// SetClock replaces the clock used to timestamp scores
+func (b *ConfidenceBackfill) SetClock(c clock.Clock) {
+	b.clock = clock.OrReal(c)
+}
+
+// OnScore registers fn to be called with every computed score, e.g. to
+// persist it. Register hooks before calling Run.
+func (b *ConfidenceBackfill) OnScore(fn func(ctx context.Context, score ScoredConfidence)) {
+	b.onScore = append(b.onScore, fn)
+}
+
+// Run scores every wallet received from wallets until it is closed or ctx
+// is cancelled
+func (b *ConfidenceBackfill) Run(ctx context.Context, wallets <-chan string) BackfillStats {
+	var attempted, scored, failed atomic.Int64
+	var wg sync.WaitGroup
+	for range b.concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for wallet := range wallets {
+				if ctx.Err() != nil {
+					continue // Drain so the producer isn't blocked
+				}
+				n := attempted.Add(1)
+				if b.score(ctx, wallet) {
+					scored.Add(1)
+				} else {
+					failed.Add(1)
+				}
+				if n%100 == 0 {
+					confidenceLog.InfoContext(ctx, "Confidence backfill progress", "wallets", n, "scored", scored.Load(), "failed", failed.Load())
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return BackfillStats{
+		Wallets: int(attempted.Load()),
+		Scored:  int(scored.Load()),
+		Failed:  int(failed.Load()),
+	}
+}
+
+// score computes, persists and publishes the confidence of one wallet
+func (b *ConfidenceBackfill) score(ctx context.Context, wallet string) bool {
+	ctx, cancel := context.WithTimeout(ctx, backfillTimeout)
+	defer cancel()
+
+	positions, err := b.apiClient.GetAllClosedPositions(ctx, dataapi.ClosedPositionsQueryParams{User: wallet}, b.maxPositions)
+	if err != nil {
+		confidenceLog.ErrorContext(ctx, "Error fetching closed positions", logging.KeyWallet, wallet, "error", err)
+		return false
+	}
+	score := ScoredConfidence{
+		Wallet:     wallet,
+		Prediction: CalculateConfidence(positions),
+		ComputedAt: b.clock.Now(),
+		Freshness:  1,
+	}
+	for _, fn := range b.onScore {
+		fn(ctx, score)
+	}
+	if err := b.emitter.Emit(ctx, events.New(events.TypeWalletConfidence, wallet, score)); err != nil {
+		confidenceLog.ErrorContext(ctx, "Error emitting confidence", logging.KeyWallet, wallet, "error", err)
+	}
+	return true
+}
@@ -1,10 +1,12 @@
 package domain
 
 import (
+	"cmp"
 	"context"
 	"math"
+	"slices"
 
-	"github.com/FatwaArya/pm-ingest/internal"
+	"github.com/FatwaArya/pm-ingest/pkg/dataapi"
 )
 
 type PredictionResult struct {
@@ -15,10 +17,40 @@ type PredictionResult struct {
 	SampleSize         int     // Total number of trades analyzed
 	AvgRealizedPnl     float64 // Average realized profit/loss
 	TotalRealizedPnl   float64 // Total realized profit/loss
+
+	// Scores of trades against their markets' resolved outcomes (see
+	// TradeScores), where BrierScore takes a profitable position as the
+	// outcome. Zero until some traded market resolved, or without a
+	// TradeScorer.
+	TradeBrierScore        float64
+	TradeLogScore          float64
+	TimeWeightedBrierScore float64 // Trades placed longer before the close weigh more
+	TimeWeightedLogScore   float64
+	ResolvedTrades         int // Trades scored
+
+	// Risk-adjusted metrics over the closed positions
+	PnlVolatility float64 // Sample standard deviation of realized PnL per position; 0 for fewer than two positions
+	SharpeRatio   float64 // AvgRealizedPnl / PnlVolatility; 0 when PnL doesn't vary, rather than infinite
+	MaxDrawdown   float64 // Largest peak-to-trough fall of cumulative PnL, positions in closing order (USD)
+	ProfitFactor  float64 // Gross profit / gross loss; 0 without losses (see NoLosses)
+	NoLosses      bool    // Profits but no losses, so the profit factor is unbounded
+	ROI           float64 // TotalRealizedPnl / capital deployed (shares bought * average price), in percent
+}
+
+// CompareProfitFactor orders results by profit factor, a result with
+// NoLosses above any finite one, for ranking with slices.SortFunc
+func CompareProfitFactor(a, b PredictionResult) int {
+	if a.NoLosses != b.NoLosses {
+		if a.NoLosses {
+			return 1
+		}
+		return -1
+	}
+	return cmp.Compare(a.ProfitFactor, b.ProfitFactor)
 }
 
 // CalculateConfidence calculates user confidence metrics based on closed positions
-func CalculateConfidence(closedPositions []internal.ClosedPosition) PredictionResult {
+func CalculateConfidence(closedPositions []dataapi.ClosedPosition) PredictionResult {
 	if len(closedPositions) == 0 {
 		return PredictionResult{
 			BrierScore:         0.0,
@@ -33,6 +65,7 @@ func CalculateConfidence(closedPositions []internal.ClosedPosition) PredictionRe
 
 	sampleSize := len(closedPositions)
 	var wins, totalPnl, brierSum float64
+	var grossProfit, grossLoss, deployed float64
 	var pnlValues []float64
 
 	// Group positions by price buckets for calibration
@@ -48,6 +81,12 @@ func CalculateConfidence(closedPositions []internal.ClosedPosition) PredictionRe
 		// Accumulate PnL
 		totalPnl += pos.RealizedPnl
 		pnlValues = append(pnlValues, pos.RealizedPnl)
+		if pos.RealizedPnl > 0 {
+			grossProfit += pos.RealizedPnl
+		} else {
+			grossLoss -= pos.RealizedPnl
+		}
+		deployed += pos.TotalBought * pos.AvgPrice
 
 		// Calculate Brier score
 		// avgPrice represents the user's probability estimate (0-1)
@@ -108,6 +147,7 @@ func CalculateConfidence(closedPositions []internal.ClosedPosition) PredictionRe
 
 	// Calculate confidence interval using standard deviation of PnL
 	confidenceInterval := 0.0
+	stdDev := 0.0
 	if len(pnlValues) > 1 {
 		// Calculate standard deviation
 		var variance float64
@@ -115,13 +155,26 @@ func CalculateConfidence(closedPositions []internal.ClosedPosition) PredictionRe
 			variance += math.Pow(pnl-avgPnl, 2)
 		}
 		variance /= float64(len(pnlValues) - 1)
-		stdDev := math.Sqrt(variance)
+		stdDev = math.Sqrt(variance)
 
 		// 95% confidence interval (approximately 1.96 standard deviations)
 		// Normalized by sample size (larger sample = tighter interval)
 		confidenceInterval = (1.96 * stdDev) / math.Sqrt(float64(sampleSize))
 	}
 
+	sharpe := 0.0
+	if stdDev > 0 {
+		sharpe = avgPnl / stdDev
+	}
+	profitFactor := 0.0
+	if grossLoss > 0 {
+		profitFactor = grossProfit / grossLoss
+	}
+	roi := 0.0
+	if deployed > 0 {
+		roi = totalPnl / deployed * 100.0
+	}
+
 	return PredictionResult{
 		BrierScore:         brierScore,
 		Calibration:        calibration,
@@ -130,27 +183,74 @@ func CalculateConfidence(closedPositions []internal.ClosedPosition) PredictionRe
 		SampleSize:         sampleSize,
 		AvgRealizedPnl:     avgPnl,
 		TotalRealizedPnl:   totalPnl,
+		PnlVolatility:      stdDev,
+		SharpeRatio:        sharpe,
+		MaxDrawdown:        maxDrawdown(closedPositions),
+		ProfitFactor:       profitFactor,
+		NoLosses:           grossLoss == 0 && grossProfit > 0,
+		ROI:                roi,
 	}
 }
 
+// maxDrawdown returns the largest fall of cumulative realized PnL from a
+// previous peak, with positions taken in the order they closed
+func maxDrawdown(positions []dataapi.ClosedPosition) float64 {
+	ordered := slices.Clone(positions)
+	slices.SortStableFunc(ordered, func(a, b dataapi.ClosedPosition) int {
+		return cmp.Compare(a.Timestamp, b.Timestamp)
+	})
+	var cumulative, peak, drawdown float64
+	for _, pos := range ordered {
+		cumulative += pos.RealizedPnl
+		peak = max(peak, cumulative)
+		drawdown = max(drawdown, peak-cumulative)
+	}
+	return drawdown
+}
+
 // CalculateConfidenceForUser calculates confidence for a specific user address
 // This is a helper that combines fetching closed positions and calculating confidence
-func CalculateConfidenceForUser(ctx context.Context, apiClient *internal.PolymarketAPIClient, userAddress string, limit int) (PredictionResult, error) {
+func CalculateConfidenceForUser(ctx context.Context, apiClient *dataapi.Client, userAddress string, limit int) (PredictionResult, error) {
+	return CalculateConfidenceForQuery(ctx, apiClient, userAddress, ConfidenceQuery{Limit: limit})
+}
+
+// DefaultConfidenceLimit is the number of closed positions, largest realized
+// PnL first, a confidence is computed from unless a query says otherwise
+const DefaultConfidenceLimit = 1000
+
+// ConfidenceQuery narrows the closed positions a confidence is computed from
+type ConfidenceQuery struct {
+	Limit   int      // Max closed positions, largest realized PnL first (default DefaultConfidenceLimit); MaxDrawdown uses them all
+	Markets []string // Condition IDs to restrict to; empty for all markets
+}
+
+// CalculateConfidenceForQuery calculates confidence from the user's closed
+// positions matching q
+func CalculateConfidenceForQuery(ctx context.Context, apiClient *dataapi.Client, userAddress string, q ConfidenceQuery) (PredictionResult, error) {
+	limit := q.Limit
 	if limit <= 0 {
-		limit = 1000 // Default to max allowed
+		limit = DefaultConfidenceLimit
 	}
 
-	params := internal.ClosedPositionsQueryParams{
+	// The drawdown runs over the whole history, not just the most
+	// profitable positions, so page through all of them (50 per request)
+	// and pick the limit largest here
+	params := dataapi.ClosedPositionsQueryParams{
 		User:          userAddress,
-		Limit:         limit,
-		SortBy:        "REALIZEDPNL",
-		SortDirection: "DESC",
+		Market:        q.Markets,
+		SortBy:        "TIMESTAMP",
+		SortDirection: "ASC",
 	}
-
-	closedPositions, err := apiClient.GetClosedPositions(ctx, params)
+	history, err := apiClient.GetAllClosedPositions(ctx, params, 0)
 	if err != nil {
 		return PredictionResult{}, err
 	}
 
-	return CalculateConfidence(closedPositions), nil
+	closedPositions := slices.Clone(history)
+	slices.SortStableFunc(closedPositions, func(a, b dataapi.ClosedPosition) int {
+		return cmp.Compare(b.RealizedPnl, a.RealizedPnl)
+	})
+	result := CalculateConfidence(closedPositions[:min(limit, len(closedPositions))])
+	result.MaxDrawdown = maxDrawdown(history)
+	return result, nil
 }
@@ -0,0 +1,109 @@
+package domain
+
+import (
+	"math"
+	"slices"
+	"testing"
+
+	"github.com/FatwaArya/pm-ingest/pkg/dataapi"
+)
+
+func TestRiskMetrics(t *testing.T) {
+	// position closed at ts with pnl, from 100 shares bought at 0.5
+	position := func(ts int64, pnl float64) dataapi.ClosedPosition {
+		return dataapi.ClosedPosition{Timestamp: ts, RealizedPnl: pnl, TotalBought: 100, AvgPrice: 0.5}
+	}
+
+	for _, tt := range []struct {
+		name      string
+		positions []dataapi.ClosedPosition
+		want      PredictionResult
+	}{
+		{
+			name: "no positions",
+		},
+		{
+			name:      "single position",
+			positions: []dataapi.ClosedPosition{position(1, 10)},
+			want:      PredictionResult{NoLosses: true, ROI: 20},
+		},
+		{
+			name:      "no losses",
+			positions: []dataapi.ClosedPosition{position(1, 10), position(2, 30)},
+			want: PredictionResult{
+				PnlVolatility: math.Sqrt(200),
+				SharpeRatio:   20 / math.Sqrt(200),
+				NoLosses:      true,
+				ROI:           40,
+			},
+		},
+		{
+			name:      "all losses",
+			positions: []dataapi.ClosedPosition{position(2, -30), position(1, -10)},
+			want: PredictionResult{
+				PnlVolatility: math.Sqrt(200),
+				SharpeRatio:   -20 / math.Sqrt(200),
+				MaxDrawdown:   40,
+				ROI:           -40,
+			},
+		},
+		{
+			name:      "zero variance",
+			positions: []dataapi.ClosedPosition{position(1, 5), position(2, 5), position(3, 5)},
+			want:      PredictionResult{NoLosses: true, ROI: 10},
+		},
+		{
+			// In closing order: +20, -30, +5, -10, so the drawdown runs from
+			// the peak of 20 down to -15
+			name:      "drawdown in closing order",
+			positions: []dataapi.ClosedPosition{position(3, 5), position(1, 20), position(4, -10), position(2, -30)},
+			want: PredictionResult{
+				PnlVolatility: math.Sqrt(456.25),
+				SharpeRatio:   -3.75 / math.Sqrt(456.25),
+				MaxDrawdown:   35,
+				ProfitFactor:  25.0 / 40,
+				ROI:           -7.5,
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got := CalculateConfidence(tt.positions)
+			for _, m := range []struct {
+				name      string
+				got, want float64
+			}{
+				{"PnlVolatility", got.PnlVolatility, tt.want.PnlVolatility},
+				{"SharpeRatio", got.SharpeRatio, tt.want.SharpeRatio},
+				{"MaxDrawdown", got.MaxDrawdown, tt.want.MaxDrawdown},
+				{"ProfitFactor", got.ProfitFactor, tt.want.ProfitFactor},
+				{"ROI", got.ROI, tt.want.ROI},
+			} {
+				if math.Abs(m.got-m.want) > 1e-9 {
+					t.Errorf("%s = %v, want %v", m.name, m.got, m.want)
+				}
+			}
+			if got.NoLosses != tt.want.NoLosses {
+				t.Errorf("NoLosses = %v, want %v", got.NoLosses, tt.want.NoLosses)
+			}
+		})
+	}
+}
+
+func TestCompareProfitFactor(t *testing.T) {
+	results := []PredictionResult{
+		{NoLosses: true},
+		{ProfitFactor: 3},
+		{},
+		{ProfitFactor: 0.5},
+	}
+	slices.SortFunc(results, CompareProfitFactor)
+	want := []PredictionResult{
+		{},
+		{ProfitFactor: 0.5},
+		{ProfitFactor: 3},
+		{NoLosses: true},
+	}
+	if !slices.Equal(results, want) {
+		t.Errorf("sorted %+v, want %+v", results, want)
+	}
+}
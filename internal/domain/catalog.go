@@ -0,0 +1,194 @@
+package domain
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/FatwaArya/pm-ingest/internal/pipeline"
+	"github.com/FatwaArya/pm-ingest/pkg/gamma"
+	"github.com/FatwaArya/pm-ingest/pkg/rtds"
+)
+
+const (
+	// catalogConcurrency bounds concurrent Gamma lookups; markets over the
+	// limit are looked up on a later trade
+	catalogConcurrency = 4
+	// catalogRetry is how long a market whose lookup failed is left alone
+	catalogRetry = time.Minute
+)
+
+// MarketCatalog caches Gamma metadata for the markets seen on the trade
+// stream. Lookups run in the background, so a market's first trades pass
+// before its metadata is known.
+type MarketCatalog struct {
+	gamma *gamma.Client
+	sem   chan struct{}
+	wait  time.Duration // How long Enrich waits for a lookup
+
+	mu      sync.RWMutex
+	markets map[string]*gamma.Market // By condition ID
+	failed  map[string]time.Time     // Condition ID -> failed lookup time
+	pending map[string]chan struct{} // Closed when the lookup is done
+}
+
+// NewMarketCatalog creates an empty catalog backed by gammaClient
+func NewMarketCatalog(gammaClient *gamma.Client) *MarketCatalog {
+	return &MarketCatalog{
+		gamma:   gammaClient,
+		sem:     make(chan struct{}, catalogConcurrency),
+		markets: make(map[string]*gamma.Market),
+		failed:  make(map[string]time.Time),
+		pending: make(map[string]chan struct{}),
+	}
+}
+
+// SetEnrichWait makes Enrich wait up to wait for the lookup of a market
+// it doesn't know yet, so its first trades are enriched too. Waiting holds
+// up the pipeline; with 0 (the default) Enrich never waits.
+func (c *MarketCatalog) SetEnrichWait(wait time.Duration) {
+	c.wait = wait
+}
+
+// Add caches metadata fetched elsewhere, e.g. by the new market detector
+func (c *MarketCatalog) Add(m *gamma.Market) {
+	if m == nil || m.ConditionID == "" {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.markets[m.ConditionID] = m
+	delete(c.failed, m.ConditionID)
+}
+
+// AddNewMarket caches the metadata of a market.new event; see MarketDetector.OnNewMarket
+func (c *MarketCatalog) AddNewMarket(m NewMarket) {
+	c.Add(m.Market)
+}
+
+// Market returns the cached metadata of a market
+func (c *MarketCatalog) Market(conditionID string) (*gamma.Market, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	m, ok := c.markets[conditionID]
+	return m, ok
+}
+
+// Category returns a market's category, normalized to a lowercase slug
+// ("Pop Culture" -> "pop-culture"), or "" if it isn't known (yet)
+func (c *MarketCatalog) Category(conditionID string) string {
+	m, ok := c.Market(conditionID)
+	if !ok {
+		return ""
+	}
+	return CategorySlug(m.Category)
+}
+
+// CategorySlug normalizes a category name for use in topic names
+func CategorySlug(category string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(category)), " ", "-")
+}
+
+// Middleware looks up the metadata of markets not in the catalog yet
+func (c *MarketCatalog) Middleware() pipeline.Middleware {
+	return func(next pipeline.Handler) pipeline.Handler {
+		return func(ctx context.Context, trade *rtds.ActivityTradePayload) error {
+			c.lookup(ctx, trade.ConditionID)
+			return next(ctx, trade)
+		}
+	}
+}
+
+// Enrich copies the metadata of the trade's market onto it: category, end
+// date, liquidity, volume, outcome names and tags. A market not in the
+// catalog is looked up; the trade goes on without metadata if the lookup
+// doesn't finish within the enrich wait. Enrich never fails.
+func (c *MarketCatalog) Enrich(ctx context.Context, trade *rtds.ActivityTradePayload) error {
+	m, ok := c.Market(trade.ConditionID)
+	if !ok {
+		done := c.lookup(ctx, trade.ConditionID)
+		if done == nil || c.wait <= 0 {
+			return nil
+		}
+		timer := time.NewTimer(c.wait)
+		defer timer.Stop()
+		select {
+		case <-done:
+		case <-timer.C:
+		case <-ctx.Done():
+		}
+		if m, ok = c.Market(trade.ConditionID); !ok {
+			return nil
+		}
+	}
+
+	trade.Category = strings.TrimSpace(m.Category)
+	trade.Tags = m.TagLabels()
+	if trade.Category == "" && len(trade.Tags) > 0 {
+		// Newer markets have no category, only tags
+		trade.Category = trade.Tags[0]
+	}
+	trade.MarketEndDate = m.EndDate
+	trade.MarketLiquidity = m.Liquidity
+	trade.MarketVolume = m.Volume
+	if names, err := m.OutcomeNames(); err == nil && len(names) > 0 {
+		trade.Outcomes = names
+	}
+	return nil
+}
+
+// lookup fetches a market in the background unless it is cached or failed
+// recently. It returns a channel closed once the lookup in flight is done,
+// or nil if there is none.
+func (c *MarketCatalog) lookup(ctx context.Context, conditionID string) <-chan struct{} {
+	if conditionID == "" {
+		return nil
+	}
+	c.mu.RLock()
+	_, known := c.markets[conditionID]
+	failedAt, failed := c.failed[conditionID]
+	pending := c.pending[conditionID]
+	c.mu.RUnlock()
+	if pending != nil {
+		return pending
+	}
+	if known || (failed && time.Since(failedAt) < catalogRetry) {
+		return nil
+	}
+
+	select {
+	case c.sem <- struct{}{}:
+	default:
+		return nil
+	}
+	c.mu.Lock()
+	if pending := c.pending[conditionID]; pending != nil {
+		c.mu.Unlock()
+		<-c.sem
+		return pending
+	}
+	done := make(chan struct{})
+	c.pending[conditionID] = done
+	c.mu.Unlock()
+
+	go func() {
+		defer func() { <-c.sem }()
+		ctx, cancel := context.WithTimeout(ctx, newMarketTimeout)
+		defer cancel()
+		m, err := c.gamma.GetMarketByConditionID(ctx, conditionID)
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		delete(c.pending, conditionID)
+		defer close(done)
+		if err != nil {
+			c.failed[conditionID] = time.Now()
+			lookupErrLog.Printf("Error looking up market %s: %v", conditionID, err)
+			return
+		}
+		c.markets[conditionID] = m
+		delete(c.failed, conditionID)
+	}()
+	return done
+}
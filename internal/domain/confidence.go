@@ -4,22 +4,34 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
-	"sync"
+	"sort"
+	"strings"
 	"time"
 
-	"github.com/FatwaArya/pm-ingest/internal"
 	internalkafka "github.com/FatwaArya/pm-ingest/internal/kafka"
-	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/FatwaArya/pm-ingest/internal/logging"
+	"github.com/FatwaArya/pm-ingest/internal/pipeline"
+	"github.com/FatwaArya/pm-ingest/internal/schema"
+	"github.com/FatwaArya/pm-ingest/internal/store"
+	"github.com/FatwaArya/pm-ingest/pkg/clock"
+	"github.com/FatwaArya/pm-ingest/pkg/dataapi"
 )
 
-// ConfidenceService calculates user confidence based on new bets and closed positions
+// ConfidenceService calculates user confidence based on new bets and closed positions.
+// All per-user state (rate-limit markers and cached results) lives in a
+// store.Store; with a Redis store the service itself is stateless, so
+// replicas can be scaled or restarted without recomputing recent users.
 type ConfidenceService struct {
-	consumer       *internalkafka.Consumer
-	apiClient      *internal.PolymarketAPIClient
-	processedUsers map[string]time.Time // Track when we last processed each user
-	mu             sync.RWMutex
-	minInterval    time.Duration // Minimum time between confidence calculations for same user
+	consumer    *internalkafka.Consumer
+	apiClient   *dataapi.Client
+	state       store.Store   // Rate-limit markers and cached results per user
+	minInterval time.Duration // Minimum time between confidence calculations for same user
+	shard       Shard
+	clock       clock.Clock
+	workers     *pipeline.Pool
+	cache       *ConfidenceCache
+	scorer      *TradeScorer
+	onResult    []func(ctx context.Context, result ConfidenceResult)
 }
 
 // ConfidenceResult represents the calculated confidence for a user
@@ -30,98 +42,268 @@ type ConfidenceResult struct {
 	LatestBet   internalkafka.TradeMessage `json:"latestBet,omitempty"`
 }
 
+var confidenceLog = logging.For("confidence")
+
+// ConfidenceSchema is the schema of published confidence results. Version 2
+// added the trade score fields to the prediction, version 3 the risk metrics.
+var ConfidenceSchema = schema.MustNew("ConfidenceResult", 3, ConfidenceResult{})
+
 // NewConfidenceService creates a new confidence calculation service
-func NewConfidenceService(brokers string, topic string, groupID string) (*ConfidenceService, error) {
-	consumer, err := internalkafka.NewConsumer(brokers, topic, groupID)
+func NewConfidenceService(brokers string, topic string, groupID string, options ...internalkafka.ConsumerOption) (*ConfidenceService, error) {
+	consumer, err := internalkafka.NewConsumer(brokers, topic, groupID, options...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create kafka consumer: %w", err)
 	}
 
-	apiClient := internal.NewPolymarketAPIClient()
+	apiClient := dataapi.NewClient()
 
 	return &ConfidenceService{
-		consumer:       consumer,
-		apiClient:      apiClient,
-		processedUsers: make(map[string]time.Time),
-		minInterval:    5 * time.Minute, // Don't recalculate for same user more than once per 5 minutes
+		consumer:    consumer,
+		apiClient:   apiClient,
+		state:       store.NewMemoryStore(),
+		minInterval: 5 * time.Minute, // Don't recalculate for same user more than once per 5 minutes
+		clock:       clock.Real,
 	}, nil
 }
 
-// Run starts the confidence service
+// SetStore replaces the in-memory rate-limit state and result cache, e.g.
+// with a Redis store shared by all confidence replicas
+func (cs *ConfidenceService) SetStore(s store.Store) {
+	cs.state = s
+}
+
+// SetCache keeps every calculated result in cache, and skips calculations
+// (after a restart too) for wallets with a result younger than the minimum
+// interval. Default queries are answered from it.
+func (cs *ConfidenceService) SetCache(cache *ConfidenceCache) {
+	cs.cache = cache
+}
+
+// SetTradeScorer adds trade-level scores to every result
+func (cs *ConfidenceService) SetTradeScorer(s *TradeScorer) {
+	cs.scorer = s
+}
+
+// SetShard restricts the service to wallets that hash to shard
+func (cs *ConfidenceService) SetShard(shard Shard) {
+	cs.shard = shard
+}
+
+// OnResult registers fn to be called with every calculated result, e.g. to
+// publish or persist it. Hooks run on the calculation goroutine, in order.
+func (cs *ConfidenceService) OnResult(fn func(ctx context.Context, result ConfidenceResult)) {
+	cs.onResult = append(cs.onResult, fn)
+}
+
+// SetClock replaces the clock used to timestamp results. Rate limiting is
+// measured by the store, see store.MemoryStore.SetClock.
+func (cs *ConfidenceService) SetClock(c clock.Clock) {
+	cs.clock = clock.OrReal(c)
+}
+
+// SetWorkers runs calculations on pool instead of a goroutine per bet
+func (cs *ConfidenceService) SetWorkers(pool *pipeline.Pool) {
+	cs.workers = pool
+}
+
+// Run starts the confidence service. Cancelling ctx stops consumption and
+// cancels calculations still in flight.
 func (cs *ConfidenceService) Run(ctx context.Context) error {
-	return cs.consumer.Run(ctx, cs.handleBet)
+	return cs.consumer.RunTrades(ctx, cs.handleBet)
 }
 
 // handleBet processes a new bet from Kafka and calculates confidence
-func (cs *ConfidenceService) handleBet(record *kgo.Record) {
-	var tradeMsg internalkafka.TradeMessage
-	if err := json.Unmarshal(record.Value, &tradeMsg); err != nil {
-		log.Printf("Error unmarshaling trade message: %v", err)
-		return
-	}
+func (cs *ConfidenceService) handleBet(ctx context.Context, tradeMsg internalkafka.TradeMessage) error {
 
 	// Skip if no proxy wallet (can't calculate confidence without user)
 	if tradeMsg.ProxyWallet == "" {
-		return
+		return nil
 	}
 
-	// Check if we should process this user (rate limiting)
-	cs.mu.RLock()
-	lastProcessed, exists := cs.processedUsers[tradeMsg.ProxyWallet]
-	cs.mu.RUnlock()
+	// Another replica handles wallets outside our shard
+	if !cs.shard.Owns(tradeMsg.ProxyWallet) {
+		return nil
+	}
 
-	if exists && time.Since(lastProcessed) < cs.minInterval {
-		return // Skip if processed recently
+	// A cached result computed recently, e.g. before a restart, is as good
+	if cs.cache != nil {
+		if cached, ok := cs.cache.Get(ctx, tradeMsg.ProxyWallet); ok && cs.clock.Now().Sub(cached.ComputedAt) < cs.minInterval {
+			return nil
+		}
 	}
 
-	// Update processed time
-	cs.mu.Lock()
-	cs.processedUsers[tradeMsg.ProxyWallet] = time.Now()
-	cs.mu.Unlock()
+	// Check if we should process this user (rate limiting). SetIfAbsent is an
+	// atomic check-and-set, so only one replica wins for a given user; the
+	// marker expires after minInterval so the next bet triggers a recalculation
+	allowed, err := cs.state.SetIfAbsent(ctx, rateLimitKey(tradeMsg.ProxyWallet), cs.minInterval)
+	if err != nil {
+		return fmt.Errorf("failed to check confidence rate limit for %s: %w", tradeMsg.ProxyWallet, err)
+	}
+	if !allowed {
+		return nil // Skip if processed recently
+	}
 
-	// Calculate confidence in a goroutine to avoid blocking
-	go cs.calculateAndLogConfidence(context.Background(), tradeMsg)
+	// Committing only handled bets means calculating before the next
+	// record, so a failed calculation is retried by the consumer
+	if cs.consumer.CommitMode() != internalkafka.CommitAuto {
+		return cs.calculateAndLogConfidence(ctx, tradeMsg)
+	}
+
+	// Calculate confidence on a worker to avoid blocking
+	spawn(ctx, cs.workers, func() { cs.calculateAndLogConfidence(ctx, tradeMsg) })
+	return nil
 }
 
-// calculateAndLogConfidence fetches closed positions and calculates confidence
-func (cs *ConfidenceService) calculateAndLogConfidence(ctx context.Context, bet internalkafka.TradeMessage) {
+// calculateAndLogConfidence fetches closed positions and calculates
+// confidence. On error the rate-limit marker is released, so the next bet
+// (or the consumer's retry of this one) calculates again.
+func (cs *ConfidenceService) calculateAndLogConfidence(ctx context.Context, bet internalkafka.TradeMessage) error {
+	ctx, cancel := context.WithTimeout(ctx, confidenceTimeout)
+	defer cancel()
+
 	userAddress := bet.ProxyWallet
+	ctx = logging.WithAttrs(ctx, logging.KeyTxHash, bet.TransactionHash, logging.KeyWallet, userAddress)
 
 	// Fetch closed positions for the user
-	prediction, err := CalculateConfidenceForUser(ctx, cs.apiClient, userAddress, 50)
+	prediction, err := CalculateConfidenceForQuery(ctx, cs.apiClient, userAddress, ConfidenceQuery{})
 	if err != nil {
-		log.Printf("Error calculating confidence for user %s: %v", userAddress, err)
-		return
+		confidenceLog.ErrorContext(ctx, "Error calculating confidence", "error", err)
+		// Release the rate-limit marker so the next bet retries
+		releaseCtx, release := cleanupContext(ctx)
+		defer release()
+		if err := cs.state.Delete(releaseCtx, rateLimitKey(userAddress)); err != nil {
+			confidenceLog.ErrorContext(ctx, "Error releasing confidence rate limit", "error", err)
+		}
+		return fmt.Errorf("failed to calculate confidence for %s: %w", userAddress, err)
 	}
+	cs.scorer.Score(ctx, userAddress, ConfidenceQuery{}, &prediction)
+	cs.cacheResult(ctx, resultKey(userAddress), prediction)
 
 	// Create confidence result
 	result := ConfidenceResult{
 		UserAddress: userAddress,
-		Timestamp:   time.Now().Unix(),
+		Timestamp:   cs.clock.Now().Unix(),
 		Prediction:  prediction,
 		LatestBet:   bet,
 	}
+	if cs.cache != nil {
+		cs.cache.Record(ctx, result.Scored())
+	}
 
-	// Log the confidence result
-	cs.logConfidenceResult(result)
+	if len(cs.onResult) == 0 {
+		confidenceLog.InfoContext(ctx, "Confidence calculated",
+			"brier_score", prediction.BrierScore, "win_rate_pct", prediction.WinRate, "sample_size", prediction.SampleSize)
+	}
+	for _, fn := range cs.onResult {
+		fn(ctx, result)
+	}
+	return nil
 }
 
-// logConfidenceResult logs the confidence calculation result
-func (cs *ConfidenceService) logConfidenceResult(result ConfidenceResult) {
-	log.Printf("Confidence calculated for user %s:", result.UserAddress)
-	log.Printf("  Sample Size: %d", result.Prediction.SampleSize)
-	log.Printf("  Win Rate: %.2f%%", result.Prediction.WinRate)
-	log.Printf("  Avg Realized PnL: $%.2f", result.Prediction.AvgRealizedPnl)
-	log.Printf("  Total Realized PnL: $%.2f", result.Prediction.TotalRealizedPnl)
-	log.Printf("  Brier Score: %.4f (lower is better)", result.Prediction.BrierScore)
-	log.Printf("  Calibration: %.2f%%", result.Prediction.Calibration)
-	log.Printf("  Confidence Interval: ±$%.2f", result.Prediction.ConfidenceInterval)
-	log.Printf("  Latest Bet: %s on %s at $%.4f", result.LatestBet.Side, result.LatestBet.Slug, result.LatestBet.Price)
+// Scored returns the result as a score, for hooks shared with the
+// ConfidenceRefresher such as the QuestDB confidence writer
+func (r ConfidenceResult) Scored() ScoredConfidence {
+	return ScoredConfidence{
+		Wallet:     r.UserAddress,
+		Prediction: r.Prediction,
+		ComputedAt: time.Unix(r.Timestamp, 0),
+		Freshness:  1,
+	}
 }
 
-// GetConfidenceForUser manually calculates confidence for a specific user
+// GetConfidenceForUser returns the cached confidence for a user, calculating
+// (and caching) it if no result newer than minInterval exists
 func (cs *ConfidenceService) GetConfidenceForUser(ctx context.Context, userAddress string) (PredictionResult, error) {
-	return CalculateConfidenceForUser(ctx, cs.apiClient, userAddress, 50)
+	return cs.GetConfidence(ctx, userAddress, ConfidenceQuery{})
+}
+
+// ConfidenceLookup is a confidence returned by LookupConfidence
+type ConfidenceLookup struct {
+	Prediction PredictionResult
+	ComputedAt time.Time // Zero if not known
+	Cached     bool      // Whether it came from a cache
+}
+
+// GetConfidence is GetConfidenceForUser over the positions matching q. Each
+// distinct query is cached separately.
+func (cs *ConfidenceService) GetConfidence(ctx context.Context, userAddress string, q ConfidenceQuery) (PredictionResult, error) {
+	lookup, err := cs.LookupConfidence(ctx, userAddress, q)
+	return lookup.Prediction, err
+}
+
+// LookupConfidence is GetConfidence, reporting whether the result was cached
+func (cs *ConfidenceService) LookupConfidence(ctx context.Context, userAddress string, q ConfidenceQuery) (ConfidenceLookup, error) {
+	key := queryKey(userAddress, q)
+	if cs.cache != nil && key == resultKey(userAddress) {
+		if cached, ok := cs.cache.Get(ctx, userAddress); ok {
+			return ConfidenceLookup{Prediction: cached.Prediction, ComputedAt: cached.ComputedAt, Cached: true}, nil
+		}
+	}
+	if data, ok, err := cs.state.Get(ctx, key); err == nil && ok {
+		var cached PredictionResult
+		if err := json.Unmarshal(data, &cached); err == nil {
+			return ConfidenceLookup{Prediction: cached, Cached: true}, nil
+		}
+	}
+
+	prediction, err := CalculateConfidenceForQuery(ctx, cs.apiClient, userAddress, q)
+	if err != nil {
+		return ConfidenceLookup{}, err
+	}
+	cs.scorer.Score(ctx, userAddress, q, &prediction)
+	computedAt := cs.clock.Now()
+	cs.cacheResult(ctx, key, prediction)
+	if cs.cache != nil && key == resultKey(userAddress) {
+		cs.cache.Put(ctx, CachedConfidence{Wallet: userAddress, Prediction: prediction, ComputedAt: computedAt})
+	}
+	return ConfidenceLookup{Prediction: prediction, ComputedAt: computedAt}, nil
+}
+
+// CacheTTL is how long computed results are cached, and so how stale a
+// result may be
+func (cs *ConfidenceService) CacheTTL() time.Duration {
+	if cs.cache != nil {
+		return max(cs.cache.TTL(), cs.minInterval)
+	}
+	return cs.minInterval
+}
+
+// cacheResult stores a prediction under key for minInterval
+func (cs *ConfidenceService) cacheResult(ctx context.Context, key string, prediction PredictionResult) {
+	data, err := json.Marshal(prediction)
+	if err != nil {
+		return
+	}
+	if err := cs.state.Set(ctx, key, data, cs.minInterval); err != nil {
+		confidenceLog.ErrorContext(ctx, "Error caching confidence", "key", key, "error", err)
+	}
+}
+
+func rateLimitKey(userAddress string) string {
+	return store.PrefixConfidence + strings.ToLower(userAddress)
+}
+
+func resultKey(userAddress string) string {
+	return store.PrefixResult + strings.ToLower(userAddress)
+}
+
+// queryKey is resultKey for the default query (the DefaultConfidenceLimit
+// largest positions of all markets), suffixed with the limit and sorted
+// markets otherwise
+func queryKey(userAddress string, q ConfidenceQuery) string {
+	key := resultKey(userAddress)
+	if q.Limit <= 0 {
+		q.Limit = DefaultConfidenceLimit
+	}
+	if q.Limit == DefaultConfidenceLimit && len(q.Markets) == 0 {
+		return key
+	}
+	markets := make([]string, len(q.Markets))
+	for i, m := range q.Markets {
+		markets[i] = strings.ToLower(m)
+	}
+	sort.Strings(markets)
+	return fmt.Sprintf("%s:%d:%s", key, q.Limit, strings.Join(markets, ","))
 }
 
 // Close closes the confidence service
@@ -0,0 +1,199 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/FatwaArya/pm-ingest/internal/store"
+	"github.com/FatwaArya/pm-ingest/pkg/clock"
+)
+
+// CachedConfidence is the latest confidence computed for a wallet
+type CachedConfidence struct {
+	Wallet     string           `json:"wallet"`
+	Prediction PredictionResult `json:"prediction"`
+	ComputedAt time.Time        `json:"computedAt"`
+}
+
+// ConfidenceCacheStats counts the entries and lookups of a ConfidenceCache
+type ConfidenceCacheStats struct {
+	Entries int    `json:"entries"`
+	Hits    uint64 `json:"hits"`
+	Misses  uint64 `json:"misses"`
+}
+
+// ConfidenceCache keeps the latest PredictionResult of each wallet for a
+// TTL, whichever service computed it. Entries live in memory and, with
+// SetStore, in a shared store such as Redis, where other replicas (and this
+// one, after a restart) find them. Without a shared store, Save and Load
+// carry the entries across restarts in a file.
+type ConfidenceCache struct {
+	ttl    time.Duration
+	clock  clock.Clock
+	shared store.Store
+
+	mu      sync.Mutex
+	entries map[string]CachedConfidence
+
+	hits, misses atomic.Uint64
+}
+
+// NewConfidenceCache creates an empty cache keeping results for ttl
+func NewConfidenceCache(ttl time.Duration) *ConfidenceCache {
+	return &ConfidenceCache{
+		ttl:     ttl,
+		clock:   clock.Real,
+		entries: make(map[string]CachedConfidence),
+	}
+}
+
+// SetStore also keeps entries in s, read on a miss in memory
+func (c *ConfidenceCache) SetStore(s store.Store) {
+	c.shared = s
+}
+
+// SetClock replaces the clock entries expire against
+func (c *ConfidenceCache) SetClock(cl clock.Clock) {
+	c.clock = clock.OrReal(cl)
+}
+
+// TTL returns how long results are cached
+func (c *ConfidenceCache) TTL() time.Duration {
+	return c.ttl
+}
+
+// Get returns the cached confidence of wallet, if computed within the TTL
+func (c *ConfidenceCache) Get(ctx context.Context, wallet string) (CachedConfidence, bool) {
+	wallet = strings.ToLower(wallet)
+	now := c.clock.Now()
+
+	c.mu.Lock()
+	entry, ok := c.entries[wallet]
+	if ok && now.Sub(entry.ComputedAt) >= c.ttl {
+		delete(c.entries, wallet)
+		ok = false
+	}
+	c.mu.Unlock()
+
+	if !ok && c.shared != nil {
+		if data, found, err := c.shared.Get(ctx, confidenceCacheKey(wallet)); err != nil {
+			confidenceLog.ErrorContext(ctx, "Error reading cached confidence", "wallet", wallet, "error", err)
+		} else if found && json.Unmarshal(data, &entry) == nil && now.Sub(entry.ComputedAt) < c.ttl {
+			ok = true
+			c.mu.Lock()
+			c.entries[wallet] = entry
+			c.mu.Unlock()
+		}
+	}
+
+	if ok {
+		c.hits.Add(1)
+	} else {
+		c.misses.Add(1)
+	}
+	return entry, ok
+}
+
+// Put caches a wallet's confidence, unless a newer one is cached already
+func (c *ConfidenceCache) Put(ctx context.Context, entry CachedConfidence) {
+	entry.Wallet = strings.ToLower(entry.Wallet)
+	if entry.Wallet == "" {
+		return
+	}
+	c.mu.Lock()
+	if previous, ok := c.entries[entry.Wallet]; ok && previous.ComputedAt.After(entry.ComputedAt) {
+		c.mu.Unlock()
+		return
+	}
+	c.entries[entry.Wallet] = entry
+	c.mu.Unlock()
+
+	if c.shared == nil {
+		return
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	if err := c.shared.Set(ctx, confidenceCacheKey(entry.Wallet), data, c.ttl); err != nil {
+		confidenceLog.ErrorContext(ctx, "Error caching confidence", "wallet", entry.Wallet, "error", err)
+	}
+}
+
+// Record caches a computed score; it fits ConfidenceRefresher.OnScore
+func (c *ConfidenceCache) Record(ctx context.Context, score ScoredConfidence) {
+	c.Put(ctx, CachedConfidence{Wallet: score.Wallet, Prediction: score.Prediction, ComputedAt: score.ComputedAt})
+}
+
+// Stats returns the number of entries in memory and the lookups so far
+func (c *ConfidenceCache) Stats() ConfidenceCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return ConfidenceCacheStats{Entries: len(c.entries), Hits: c.hits.Load(), Misses: c.misses.Load()}
+}
+
+// Load adds the unexpired entries saved at path. A missing file is an
+// empty cache.
+func (c *ConfidenceCache) Load(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read confidence cache: %w", err)
+	}
+	var entries []CachedConfidence
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("failed to decode confidence cache %s: %w", path, err)
+	}
+	now := c.clock.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, entry := range entries {
+		if now.Sub(entry.ComputedAt) < c.ttl {
+			c.entries[strings.ToLower(entry.Wallet)] = entry
+		}
+	}
+	return nil
+}
+
+// Save writes the unexpired entries to path, replacing it atomically
+func (c *ConfidenceCache) Save(path string) error {
+	now := c.clock.Now()
+	c.mu.Lock()
+	entries := make([]CachedConfidence, 0, len(c.entries))
+	for _, entry := range c.entries {
+		if now.Sub(entry.ComputedAt) < c.ttl {
+			entries = append(entries, entry)
+		}
+	}
+	c.mu.Unlock()
+
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create confidence cache directory: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to save confidence cache: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to save confidence cache: %w", err)
+	}
+	return nil
+}
+
+func confidenceCacheKey(wallet string) string {
+	return store.PrefixConfidenceCache + wallet
+}
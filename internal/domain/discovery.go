@@ -1,23 +1,47 @@
 package domain
 
 import (
+	"cmp"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
 	"strconv"
 	"strings"
-	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/FatwaArya/pm-ingest/config"
 	internalqdb "github.com/FatwaArya/pm-ingest/internal"
+	"github.com/FatwaArya/pm-ingest/internal/health"
 	internalkafka "github.com/FatwaArya/pm-ingest/internal/kafka"
-	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/FatwaArya/pm-ingest/internal/logging"
+	"github.com/FatwaArya/pm-ingest/internal/notify"
+	"github.com/FatwaArya/pm-ingest/internal/pipeline"
+	"github.com/FatwaArya/pm-ingest/internal/store"
+	"github.com/FatwaArya/pm-ingest/pkg/dataapi"
 )
 
 const (
 	MinimumTradeSize = 10000 // USD
+
+	// Per-operation timeouts for work spawned from a trade
+	profileTimeout    = 15 * time.Second
+	confidenceTimeout = 60 * time.Second
+	cleanupTimeout    = 5 * time.Second
+
+	// defaultSeenKeys bounds the in-memory seen-address set: seen markers,
+	// profile claims and first-seen times
+	defaultSeenKeys = 300000
+
+	// profileClaimTTL outlives the retry schedule, so a claim left by a
+	// crashed replica eventually expires
+	profileClaimTTL = time.Hour
+)
+
+var (
+	discoveryLog = logging.For("discovery")
+	writeErrLog  = logging.NewRateLimited(logging.For("discovery"), 5*time.Second)
+	lookupErrLog = logging.NewRateLimited(logging.For("discovery"), 5*time.Second)
 )
 
 // UserProfile represents a user profile fetched from Polymarket API
@@ -36,19 +60,39 @@ type UserProfile struct {
 type DiscoveryService struct {
 	consumer      *internalkafka.Consumer
 	profileWriter *internalqdb.ProfileWriter
-	seenAddresses map[string]bool
-	mu            sync.RWMutex
+	seen          store.Store
+	questdbHealth *health.SinkHealth
+	shard         Shard
+	velocity      *VelocityTracker
+	owners        *OwnerResolver
+	refresher     *ConfidenceRefresher
+	retries       *ProfileRetryQueue
+	labels        *WalletLabels
+	watchlist     *WalletWatchlist
+	workers       *pipeline.Pool
+	notifier      atomic.Pointer[notify.Notifier]
+	profiles      *ProfileFetcher
+	exposure      *ExposureTracker
+	scorer        *TradeScorer
+	refresh       time.Duration // How long a saved profile is left alone; 0 is forever
+	rule          atomic.Pointer[DiscoveryRule]
+	history       *internalqdb.QueryClient
+	profileTable  string
+	onProfile     []func(ctx context.Context, profile *internalqdb.UserProfile)
 }
 
-// NewDiscoveryService creates a new discovery service
-func NewDiscoveryService(brokers string, topic string, groupID string) (*DiscoveryService, error) {
-	consumer, err := internalkafka.NewConsumer(brokers, topic, groupID)
+// NewDiscoveryService creates a new discovery service. With an
+// at-least-once commit mode (see internalkafka.WithCommitMode) a trade's
+// offset is only committed once its wallet's profile is persisted.
+func NewDiscoveryService(brokers string, topic string, groupID string, options ...internalkafka.ConsumerOption) (*DiscoveryService, error) {
+	consumer, err := internalkafka.NewConsumer(brokers, topic, groupID, options...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create kafka consumer: %w", err)
 	}
 
 	// Create QuestDB writer for profiles
-	ctx := context.Background()
+	ctx, cancel := context.WithTimeout(context.Background(), profileTimeout)
+	defer cancel()
 	host := config.AppConfig.QuestDBHost
 	portStr := config.AppConfig.QuestDBILPPort
 	if portStr == "" {
@@ -58,98 +102,479 @@ func NewDiscoveryService(brokers string, topic string, groupID string) (*Discove
 	if err != nil {
 		port = 9009 // Fallback to default
 	}
-	profileWriter, err := internalqdb.NewProfileWriter(ctx, host, port)
+	cfg := config.AppConfig
+	profileWriter, err := internalqdb.NewProfileWriter(ctx, host, port, internalqdb.WithTable(internalqdb.TableConfig{
+		Name:        cfg.QuestDBTablePrefix + cfg.QuestDBProfilesTable,
+		PartitionBy: cfg.QuestDBPartitionBy,
+		SchemaAddr:  cfg.QuestDBSchemaAddr(),
+		Symbols:     cfg.QuestDBProfileSymbols,
+	}))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create profile writer: %w", err)
 	}
 
-	return &DiscoveryService{
+	ds := &DiscoveryService{
 		consumer:      consumer,
 		profileWriter: profileWriter,
-		seenAddresses: make(map[string]bool),
-	}, nil
+		seen:          store.NewLRUStore(defaultSeenKeys),
+		profileTable:  cfg.QuestDBTablePrefix + cfg.QuestDBProfilesTable,
+		velocity:      NewVelocityTracker(DefaultBurstRule),
+	}
+	ds.retries = NewProfileRetryQueue(ds.persistProfile, func(ctx context.Context, profile *internalqdb.UserProfile) {
+		ds.releaseClaim(ctx, profile.Address)
+	})
+	return ds, nil
+}
+
+// SetSinkHealth enables health tracking for the QuestDB profile sink.
+// While QuestDB is degraded profiles are queued for retry instead of being
+// written (and failing) on every trade.
+func (ds *DiscoveryService) SetSinkHealth(h *health.SinkHealth) {
+	ds.questdbHealth = h
+}
+
+// OnProfile registers fn to be called with every profile once it's
+// persisted, e.g. to copy it to another store
+func (ds *DiscoveryService) OnProfile(fn func(ctx context.Context, profile *internalqdb.UserProfile)) {
+	ds.onProfile = append(ds.onProfile, fn)
+}
+
+// SetStore replaces the bounded in-memory seen-address set, e.g. with a
+// Redis store shared by all discovery replicas
+func (ds *DiscoveryService) SetStore(s store.Store) {
+	ds.seen = s
+}
+
+// SetOwnerResolver makes profiles record the owner of each wallet, so
+// traders using several proxy wallets can be grouped
+func (ds *DiscoveryService) SetOwnerResolver(r *OwnerResolver) {
+	ds.owners = r
+}
+
+// SetLabels records the wallet's labels on its profile
+func (ds *DiscoveryService) SetLabels(l *WalletLabels) {
+	ds.labels = l
+}
+
+// SetWatchlist discovers every trade of the watched wallets, whatever the
+// discovery rule says
+func (ds *DiscoveryService) SetWatchlist(w *WalletWatchlist) {
+	ds.watchlist = w
+}
+
+// SetConfidenceRefresher schedules discovered wallets for periodic
+// confidence refreshes and shares computed results with the refresher
+func (ds *DiscoveryService) SetConfidenceRefresher(r *ConfidenceRefresher) {
+	ds.refresher = r
+}
+
+// SetTradeScorer adds trade-level scores to the confidence of discovered
+// wallets
+func (ds *DiscoveryService) SetTradeScorer(s *TradeScorer) {
+	ds.scorer = s
+}
+
+// SetWorkers runs confidence calculations, and profile saves with
+// CommitAuto, on pool instead of a goroutine per trade
+func (ds *DiscoveryService) SetWorkers(pool *pipeline.Pool) {
+	ds.workers = pool
+}
+
+// SetNotifier sends whale alerts for trades meeting the notifier's
+// thresholds, which may be below MinimumTradeSize. It may be called while
+// the service runs; nil stops alerts.
+func (ds *DiscoveryService) SetNotifier(n *notify.Notifier) {
+	ds.notifier.Store(n)
+}
+
+// SetProfileFetcher fills saved profiles in from wallets' public profiles
+// (name, bio, images, ...) instead of only the owner's name
+func (ds *DiscoveryService) SetProfileFetcher(f *ProfileFetcher) {
+	ds.profiles = f
 }
 
-// Run starts the discovery service
+// SetExposure fetches the open positions of discovered traders, logging
+// their exposure with their confidence
+func (ds *DiscoveryService) SetExposure(t *ExposureTracker) {
+	ds.exposure = t
+}
+
+// SetProfileRefresh saves a wallet's profile again, freshly fetched and
+// with its last-seen time, on its first trade once refresh has passed.
+// With 0 (the default) a profile is saved once.
+func (ds *DiscoveryService) SetProfileRefresh(refresh time.Duration) {
+	ds.refresh = refresh
+}
+
+// SetProfileHistory looks addresses missing from the store up in the
+// profiles table through q, so a restart with an empty store doesn't save
+// every wallet's profile again
+func (ds *DiscoveryService) SetProfileHistory(q *internalqdb.QueryClient) {
+	ds.history = q
+}
+
+// SetRule replaces the default rule, notional >= MinimumTradeSize, that
+// selects the trades whose wallets are profiled and scored. It may be
+// called while the service runs (the new rule's price moves start over);
+// nil restores the default.
+func (ds *DiscoveryService) SetRule(rule *DiscoveryRule) {
+	ds.rule.Store(rule)
+}
+
+// SetShard restricts the service to wallets that hash to shard
+func (ds *DiscoveryService) SetShard(shard Shard) {
+	ds.shard = shard
+}
+
+// Run starts the discovery service. Cancelling ctx stops consumption and
+// cancels profile writes and confidence calculations still in flight.
 func (ds *DiscoveryService) Run(ctx context.Context) error {
-	return ds.consumer.Run(ctx, ds.handleTrade)
+	go ds.retries.Run(ctx)
+	return ds.consumer.RunTrades(ctx, ds.handleTrade)
+}
+
+// ConsumerLag returns how many trades the service is behind
+func (ds *DiscoveryService) ConsumerLag() int64 {
+	return ds.consumer.Lag()
+}
+
+// PendingProfiles returns the number of profiles waiting to be retried
+func (ds *DiscoveryService) PendingProfiles() int {
+	return ds.retries.Len()
 }
 
 // handleTrade processes a trade message from Kafka
-func (ds *DiscoveryService) handleTrade(record *kgo.Record) {
-	var tradeMsg internalkafka.TradeMessage
+func (ds *DiscoveryService) handleTrade(ctx context.Context, tradeMsg internalkafka.TradeMessage) error {
 	var tradeSizeInUSD float64
-	if err := json.Unmarshal(record.Value, &tradeMsg); err != nil {
-		log.Printf("Error unmarshaling trade message: %v", err)
-		return
+	ctx = logging.WithAttrs(ctx, logging.KeyTxHash, tradeMsg.TransactionHash, logging.KeyWallet, tradeMsg.ProxyWallet)
+
+	// Price moves span every wallet's trades, not just our shard's
+	if rule := ds.rule.Load(); rule != nil {
+		rule.Observe(tradeMsg)
+	}
+
+	// Another replica handles wallets outside our shard
+	if !ds.shard.Owns(tradeMsg.ProxyWallet) {
+		return nil
 	}
 
-	apiClient := internalqdb.NewPolymarketAPIClient()
+	apiClient := dataapi.NewClient()
 
 	tradeSizeInUSD = tradeMsg.Size * tradeMsg.Price
-	// Filter trades with size >= 10k USD
-	if tradeSizeInUSD < MinimumTradeSize {
-		return
+	// Velocity counts every trade of the wallet, not just high-value ones
+	ds.velocity.RecordTrade(tradeMsg.ProxyWallet, tradeMsg.Timestamp, tradeSizeInUSD)
+
+	// Whale alerts are news only for trades as they happen, not for
+	// backfilled or replayed ones
+	envelope, ok := internalkafka.EnvelopeFrom(ctx)
+	live := !ok || envelope.Live()
+	if notifier := ds.notifier.Load(); notifier != nil && tradeSizeInUSD >= notifier.MinNotional() && live {
+		alert := notify.NewAlert(tradeMsg)
+		spawn(ctx, ds.workers, func() { notifier.Notify(ctx, alert) })
+	}
+
+	watched := ds.watchlist != nil && ds.watchlist.Contains(tradeMsg.ProxyWallet)
+	if !watched && !ds.discovers(ctx, tradeMsg, tradeSizeInUSD) {
+		return nil
 	}
 
-	log.Printf("Processing high-value trade: size=%.2f, proxyWallet=%s",
-		tradeSizeInUSD, tradeMsg.ProxyWallet)
+	discoveryLog.InfoContext(ctx, "Processing high-value trade", "notional", tradeSizeInUSD)
 
 	// Process proxy wallet address
-	if tradeMsg.ProxyWallet != "" {
-		go ds.fetchAndSaveProfile(context.Background(), tradeMsg.ProxyWallet)
-		go ds.calculateAndLogConfidence(context.Background(), apiClient, tradeMsg.ProxyWallet)
+	if tradeMsg.ProxyWallet == "" {
+		return nil
+	}
+
+	// Committing only handled trades means saving the profile and
+	// calculating confidence before the next record, so the consumer retries
+	// either on failure (a saved profile stays claimed and isn't written
+	// again); otherwise failed writes are left to the retry queue
+	if ds.consumer.CommitMode() != internalkafka.CommitAuto {
+		if err := ds.fetchAndSaveProfile(ctx, tradeMsg.ProxyWallet, time.Unix(tradeMsg.Timestamp, 0)); err != nil {
+			return err
+		}
+		return ds.calculateAndLogConfidence(ctx, apiClient, tradeMsg.ProxyWallet)
+	}
+	spawn(ctx, ds.workers, func() { ds.calculateAndLogConfidence(ctx, apiClient, tradeMsg.ProxyWallet) })
+	spawn(ctx, ds.workers, func() {
+		if err := ds.fetchAndSaveProfile(ctx, tradeMsg.ProxyWallet, time.Unix(tradeMsg.Timestamp, 0)); err != nil {
+			writeErrLog.PrintfContext(ctx, "Error saving profile for address %s: %v", tradeMsg.ProxyWallet, err)
+		}
+	})
+	return nil
+}
+
+// discovers reports whether the trade meets the discovery rule, by default
+// a notional of at least MinimumTradeSize. Trades the rule fails on are
+// skipped.
+func (ds *DiscoveryService) discovers(ctx context.Context, trade internalkafka.TradeMessage, notional float64) bool {
+	rule := ds.rule.Load()
+	if rule == nil {
+		return notional >= MinimumTradeSize
+	}
+	vel, _ := ds.velocity.Velocity(trade.ProxyWallet)
+	match, err := rule.Match(trade, vel)
+	if err != nil {
+		writeErrLog.PrintfContext(ctx, "Error evaluating discovery rule for %s: %v", trade.TransactionHash, err)
+		return false
 	}
+	return match
 }
 
-// fetchAndSaveProfile saves a user profile to QuestDB
-func (ds *DiscoveryService) fetchAndSaveProfile(ctx context.Context, address string) {
+// fetchAndSaveProfile saves a user profile to QuestDB. The address is only
+// marked seen once the profile is persisted. Failed writes are queued for
+// retry with CommitAuto, and returned for the consumer to retry otherwise.
+func (ds *DiscoveryService) fetchAndSaveProfile(ctx context.Context, address string, tradedAt time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, profileTimeout)
+	defer cancel()
+
 	// Check if we've already processed this address
-	ds.mu.Lock()
-	if ds.seenAddresses[strings.ToLower(address)] {
-		ds.mu.Unlock()
-		return
+	key := strings.ToLower(address)
+	if _, seen, err := ds.seen.Get(ctx, store.PrefixSeen+key); err != nil {
+		return fmt.Errorf("failed to check seen address: %w", err)
+	} else if seen {
+		return nil
+	}
+	if ds.savedBefore(ctx, key) {
+		return nil
+	}
+
+	// Claim the address so concurrent trades (or replicas) write it once
+	claimed, err := ds.seen.SetIfAbsent(ctx, store.PrefixProfileClaim+key, profileClaimTTL)
+	if err != nil {
+		return fmt.Errorf("failed to claim address: %w", err)
+	}
+	if !claimed {
+		return nil
 	}
-	ds.seenAddresses[strings.ToLower(address)] = true
-	ds.mu.Unlock()
 
-	// Create profile with just the address
+	// Create profile with the address and how fast the wallet is trading
 	profile := &internalqdb.UserProfile{
-		Address: address,
+		Address:   address,
+		FirstSeen: ds.firstSeen(ctx, key, tradedAt),
+		LastSeen:  tradedAt,
+	}
+	if vel, ok := ds.velocity.Velocity(address); ok {
+		profile.TradesLastMinute = vel.TradesLastMinute
+		profile.TradesLastHour = vel.TradesLastHour
+		profile.NotionalLastMinute = vel.NotionalLastMinute
+		profile.NotionalLastHour = vel.NotionalLastHour
+		profile.Burst = vel.Burst
+		if vel.Burst {
+			discoveryLog.InfoContext(ctx, "Burst trading", logging.KeyWallet, address,
+				"trades_last_minute", vel.TradesLastMinute, "notional_last_minute", vel.NotionalLastMinute)
+		}
+	}
+
+	if ds.labels != nil {
+		profile.Labels = strings.Join(ds.labels.Names(address), ",")
+	}
+
+	// The owner is best effort; a failed lookup shouldn't hold up the profile
+	if ds.owners != nil {
+		if owner, err := ds.owners.Resolve(ctx, address); err != nil {
+			writeErrLog.Printf("Error resolving owner of %s: %v", address, err)
+		} else {
+			profile.Owner = owner.OwnerID
+			profile.Name = owner.Name
+			profile.Pseudonym = owner.Pseudonym
+		}
+	}
+
+	// So is the public profile
+	if ds.profiles != nil {
+		if public, err := ds.profiles.Fetch(ctx, address); err != nil {
+			writeErrLog.Printf("Error fetching profile of %s: %v", address, err)
+		} else {
+			profile.Name = cmp.Or(public.Name, profile.Name)
+			profile.Pseudonym = cmp.Or(public.Pseudonym, profile.Pseudonym)
+			profile.Bio = public.Bio
+			profile.ProfileImage = public.ProfileImage
+			profile.XUsername = public.XUsername
+			profile.Verified = public.VerifiedBadge
+			profile.CreatedAt = public.CreatedAt
+		}
+	}
+
+	if err := ds.persistProfile(ctx, profile); err != nil {
+		if ds.consumer.CommitMode() != internalkafka.CommitAuto {
+			ds.releaseClaim(ctx, address)
+			return err
+		}
+		writeErrLog.Printf("Error saving profile for address %s, queued for retry: %v", address, err)
+		ds.retries.Add(ctx, profile)
+		return nil
+	}
+	discoveryLog.InfoContext(ctx, "Saved profile", logging.KeyWallet, address)
+	return nil
+}
+
+// savedBefore reports whether the profiles table has a profile of the
+// address recent enough not to be refreshed, restoring the store's seen
+// marker and first-seen time from it. Lookup errors are logged and
+// reported as false: at worst the profile is written again.
+func (ds *DiscoveryService) savedBefore(ctx context.Context, key string) bool {
+	if ds.history == nil {
+		return false
+	}
+	history, found, err := ds.history.ProfileHistory(ctx, ds.profileTable, key)
+	if err != nil {
+		lookupErrLog.Printf("Error looking up saved profile of %s: %v", key, err)
+		return false
+	}
+	if !found {
+		return false
+	}
+	var ttl time.Duration
+	if ds.refresh > 0 {
+		if ttl = ds.refresh - time.Since(history.Written); ttl <= 0 {
+			return false
+		}
+	}
+	if !history.FirstSeen.IsZero() {
+		value := []byte(strconv.FormatInt(history.FirstSeen.UnixMilli(), 10))
+		if err := ds.seen.Set(ctx, store.PrefixFirstSeen+key, value, 0); err != nil {
+			writeErrLog.Printf("Error recording first trade of %s: %v", key, err)
+		}
+	}
+	if err := ds.seen.Set(ctx, store.PrefixSeen+key, nil, ttl); err != nil {
+		writeErrLog.Printf("Error marking address %s seen: %v", key, err)
+	}
+	return true
+}
+
+// firstSeen returns when the wallet was first seen trading, recording
+// tradedAt if it's new. Best effort: on store errors it's tradedAt.
+func (ds *DiscoveryService) firstSeen(ctx context.Context, key string, tradedAt time.Time) time.Time {
+	data, ok, err := ds.seen.Get(ctx, store.PrefixFirstSeen+key)
+	if err == nil && ok {
+		if ms, err := strconv.ParseInt(string(data), 10, 64); err == nil {
+			return time.UnixMilli(ms)
+		}
+	}
+	value := []byte(strconv.FormatInt(tradedAt.UnixMilli(), 10))
+	if err := ds.seen.Set(ctx, store.PrefixFirstSeen+key, value, 0); err != nil {
+		writeErrLog.Printf("Error recording first trade of %s: %v", key, err)
+	}
+	return tradedAt
+}
+
+// errQuestDBDegraded skips profile writes while the QuestDB sink is degraded
+var errQuestDBDegraded = errors.New("questdb is degraded")
+
+// persistProfile writes and flushes a profile, then marks its address seen
+func (ds *DiscoveryService) persistProfile(ctx context.Context, profile *internalqdb.UserProfile) error {
+	if ds.questdbHealth != nil && !ds.questdbHealth.Allow() {
+		return errQuestDBDegraded
 	}
 
 	// Write profile to QuestDB
 	if err := ds.profileWriter.Write(ctx, profile); err != nil {
-		log.Printf("Error writing profile to QuestDB for address %s: %v", address, err)
-		return
+		ds.recordWriteFailure(err)
+		return fmt.Errorf("write: %w", err)
 	}
 
 	// Flush to ensure data is written
 	if err := ds.profileWriter.Flush(ctx); err != nil {
-		log.Printf("Error flushing profile to QuestDB for address %s: %v", address, err)
-		return
+		ds.recordWriteFailure(err)
+		return fmt.Errorf("flush: %w", err)
+	}
+	if ds.questdbHealth != nil {
+		ds.questdbHealth.RecordSuccess()
+	}
+	for _, fn := range ds.onProfile {
+		fn(ctx, profile)
+	}
+
+	// The profile is persisted even if marking it seen fails; at worst a
+	// later trade writes it again
+	ctx, cancel := cleanupContext(ctx)
+	defer cancel()
+	key := strings.ToLower(profile.Address)
+	if err := ds.seen.Set(ctx, store.PrefixSeen+key, nil, ds.refresh); err != nil {
+		writeErrLog.Printf("Error marking address %s seen: %v", profile.Address, err)
+	}
+	ds.releaseClaim(ctx, profile.Address)
+	return nil
+}
+
+// recordWriteFailure marks the QuestDB sink as failing
+func (ds *DiscoveryService) recordWriteFailure(err error) {
+	if ds.questdbHealth != nil {
+		ds.questdbHealth.RecordFailure(err)
 	}
+}
 
-	log.Printf("Saved profile for address: %s", address)
+// releaseClaim lets a later trade write the address again. It runs even if
+// ctx has expired, since that is usually why the write failed.
+func (ds *DiscoveryService) releaseClaim(ctx context.Context, address string) {
+	ctx, cancel := cleanupContext(ctx)
+	defer cancel()
+	if err := ds.seen.Delete(ctx, store.PrefixProfileClaim+strings.ToLower(address)); err != nil {
+		writeErrLog.Printf("Error releasing claim on address %s: %v", address, err)
+	}
 }
 
-// calculateAndLogConfidence calculates and logs confidence metrics for a user
-func (ds *DiscoveryService) calculateAndLogConfidence(ctx context.Context, apiClient *internalqdb.PolymarketAPIClient, userAddress string) {
+// calculateAndLogConfidence calculates and logs confidence metrics for a
+// user. Only a failed calculation is returned; a failed exposure refresh is
+// logged.
+func (ds *DiscoveryService) calculateAndLogConfidence(ctx context.Context, apiClient *dataapi.Client, userAddress string) error {
+	ctx, cancel := context.WithTimeout(ctx, confidenceTimeout)
+	defer cancel()
+
 	prediction, err := CalculateConfidenceForUser(ctx, apiClient, userAddress, 1000)
 	if err != nil {
-		log.Printf("Error calculating confidence for user %s: %v", userAddress, err)
+		discoveryLog.ErrorContext(ctx, "Error calculating confidence", "error", err)
+		return fmt.Errorf("failed to calculate confidence for %s: %w", userAddress, err)
+	}
+	ds.scorer.Score(ctx, userAddress, ConfidenceQuery{}, &prediction)
+	if ds.refresher != nil {
+		ds.refresher.Update(ctx, userAddress, prediction)
+	}
+
+	discoveryLog.InfoContext(ctx, "Confidence calculated",
+		"sample_size", prediction.SampleSize,
+		"win_rate_pct", prediction.WinRate,
+		"avg_realized_pnl", prediction.AvgRealizedPnl,
+		"total_realized_pnl", prediction.TotalRealizedPnl,
+		"brier_score", prediction.BrierScore,
+		"calibration_pct", prediction.Calibration,
+		"confidence_interval", prediction.ConfidenceInterval,
+	)
+
+	if ds.exposure == nil {
+		return nil
+	}
+	exposure, err := ds.exposure.Refresh(ctx, userAddress)
+	if err != nil {
+		lookupErrLog.PrintfContext(ctx, "Error fetching open positions of %s: %v", userAddress, err)
+		return nil
+	}
+	discoveryLog.InfoContext(ctx, "Exposure fetched",
+		"open_positions", exposure.OpenPositions,
+		"cost", exposure.Cost,
+		"value", exposure.Value,
+		"unrealized_pnl", exposure.UnrealizedPnl,
+	)
+	return nil
+}
+
+// HandleSettlement recalculates the confidence of a wallet whose position
+// in a market was just settled, rather than waiting for its next big trade
+func (ds *DiscoveryService) HandleSettlement(ctx context.Context, s Settlement) {
+	if !ds.shard.Owns(s.Wallet) {
 		return
 	}
+	ctx = logging.WithAttrs(ctx, logging.KeyWallet, s.Wallet)
+	discoveryLog.InfoContext(ctx, "Settled position, recalculating confidence",
+		"market", s.Slug, "realized_pnl", s.RealizedPnl, "won", s.Won)
+	spawn(ctx, ds.workers, func() { ds.calculateAndLogConfidence(ctx, dataapi.NewClient(), s.Wallet) })
+}
 
-	// Log the confidence result
-	log.Printf("Confidence calculated for user %s:", userAddress)
-	log.Printf("  Sample Size: %d", prediction.SampleSize)
-	log.Printf("  Win Rate: %.2f%%", prediction.WinRate)
-	log.Printf("  Avg Realized PnL: $%.2f", prediction.AvgRealizedPnl)
-	log.Printf("  Total Realized PnL: $%.2f", prediction.TotalRealizedPnl)
-	log.Printf("  Brier Score: %.4f (lower is better)", prediction.BrierScore)
-	log.Printf("  Calibration: %.2f%%", prediction.Calibration)
-	log.Printf("  Confidence Interval: ±$%.2f", prediction.ConfidenceInterval)
+// cleanupContext detaches from ctx's cancellation so state can be rolled back
+// after ctx expires, bounded by cleanupTimeout
+func cleanupContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(ctx), cleanupTimeout)
 }
 
 // Close closes the discovery service
@@ -158,7 +583,6 @@ func (ds *DiscoveryService) Close() {
 		ds.consumer.Close()
 	}
 	if ds.profileWriter != nil {
-		ctx := context.Background()
-		ds.profileWriter.Close(ctx)
+		ds.profileWriter.Close(context.Background())
 	}
 }
@@ -0,0 +1,94 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/FatwaArya/pm-ingest/internal/store"
+	"github.com/FatwaArya/pm-ingest/pkg/clock"
+	"github.com/FatwaArya/pm-ingest/pkg/dataapi"
+)
+
+// exposureMaxPositions caps the open positions fetched per wallet; market
+// makers can hold thousands
+const exposureMaxPositions = 1000
+
+// Exposure is what a wallet holds in markets still open, per the Data API
+type Exposure struct {
+	Wallet        string             `json:"wallet"`
+	OpenPositions int                `json:"openPositions"`
+	Redeemable    int                `json:"redeemable"`    // Positions of resolved markets not yet redeemed
+	Cost          float64            `json:"cost"`          // USD paid for the shares held
+	Value         float64            `json:"value"`         // USD at current prices
+	UnrealizedPnl float64            `json:"unrealizedPnl"` // Value - Cost
+	RealizedPnl   float64            `json:"realizedPnl"`   // From partial sells of the open positions
+	Positions     []dataapi.Position `json:"positions"`     // Largest current value first
+	FetchedAt     time.Time          `json:"fetchedAt"`
+}
+
+// NewExposure sums up the open positions of wallet
+func NewExposure(wallet string, positions []dataapi.Position, at time.Time) Exposure {
+	e := Exposure{Wallet: wallet, OpenPositions: len(positions), Positions: positions, FetchedAt: at}
+	for _, p := range positions {
+		if p.Redeemable {
+			e.Redeemable++
+		}
+		e.Cost += p.InitialValue
+		e.Value += p.CurrentValue
+		e.RealizedPnl += p.RealizedPnl
+	}
+	e.UnrealizedPnl = e.Value - e.Cost
+	return e
+}
+
+// ExposureTracker fetches wallets' open positions and caches their exposure
+// in a store, so replicas share lookups
+type ExposureTracker struct {
+	client *dataapi.Client
+	state  store.Store
+	ttl    time.Duration
+	clock  clock.Clock
+}
+
+// NewExposureTracker creates a tracker caching exposures in state for ttl
+func NewExposureTracker(state store.Store, client *dataapi.Client, ttl time.Duration) *ExposureTracker {
+	return &ExposureTracker{client: client, state: state, ttl: ttl, clock: clock.Real}
+}
+
+// SetClock replaces the clock that FetchedAt is read from
+func (t *ExposureTracker) SetClock(c clock.Clock) {
+	t.clock = clock.OrReal(c)
+}
+
+// Exposure returns the wallet's cached exposure, fetching it when missing
+// or expired
+func (t *ExposureTracker) Exposure(ctx context.Context, wallet string) (Exposure, error) {
+	wallet = strings.ToLower(wallet)
+	if data, ok, err := t.state.Get(ctx, store.PrefixExposure+wallet); err == nil && ok {
+		var e Exposure
+		if err := json.Unmarshal(data, &e); err == nil {
+			return e, nil
+		}
+	}
+	return t.Refresh(ctx, wallet)
+}
+
+// Refresh fetches the wallet's open positions and caches its exposure
+func (t *ExposureTracker) Refresh(ctx context.Context, wallet string) (Exposure, error) {
+	wallet = strings.ToLower(wallet)
+	positions, err := t.client.GetAllPositions(ctx, wallet, exposureMaxPositions)
+	if err != nil {
+		return Exposure{}, err
+	}
+	e := NewExposure(wallet, positions, t.clock.Now())
+
+	// Caching is best effort; the exposure is good either way
+	if data, err := json.Marshal(e); err == nil {
+		if err := t.state.Set(ctx, store.PrefixExposure+wallet, data, t.ttl); err != nil {
+			writeErrLog.Printf("Error caching exposure of %s: %v", wallet, err)
+		}
+	}
+	return e, nil
+}
@@ -0,0 +1,229 @@
+package domain
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/FatwaArya/pm-ingest/internal/events"
+	"github.com/FatwaArya/pm-ingest/internal/logging"
+	"github.com/FatwaArya/pm-ingest/pkg/clock"
+	"github.com/FatwaArya/pm-ingest/pkg/rtds"
+)
+
+// Reconciliation outcomes of a clob_user fill
+const (
+	FillMatched      = "matched"
+	FillMissing      = "missing"       // Never appeared in the public activity feed
+	FillSizeMismatch = "size_mismatch" // Appeared with a different size
+)
+
+const (
+	// fillSizeTolerance absorbs rounding between the two feeds (shares)
+	fillSizeTolerance = 1e-6
+	// userTradeRetention is how long clob_user trade IDs are remembered, so
+	// status updates (MINED, CONFIRMED, ...) don't add a trade's fills again
+	userTradeRetention = time.Hour
+)
+
+var fillLog = logging.For("fills")
+
+// FillReconciliation is the outcome of matching one of our fills, a
+// (taker order, maker order) pair of a clob_user trade, against the public
+// activity trades with the same order IDs
+type FillReconciliation struct {
+	TradeID      string    `json:"tradeId"`
+	Market       string    `json:"market"`
+	TakerOrderID string    `json:"takerOrderId"`
+	MakerOrderID string    `json:"makerOrderId"`
+	Status       string    `json:"status"`
+	Size         float64   `json:"size"`       // Per clob_user
+	PublicSize   float64   `json:"publicSize"` // Sum of the matching public trades
+	PublicTrades int       `json:"publicTrades"`
+	TxHashes     []string  `json:"txHashes,omitempty"`
+	MatchedAt    time.Time `json:"matchedAt"`
+}
+
+type fillKey struct{ taker, maker string }
+
+// pendingFill is a fill of ours waiting for the grace period to pass
+type pendingFill struct {
+	rec  FillReconciliation
+	seen time.Time
+}
+
+// publicFill aggregates the public trades of one order pair
+type publicFill struct {
+	size     float64
+	trades   int
+	txHashes []string
+	keys     map[string]bool // Dedupe keys, so replays aren't counted twice
+	seen     time.Time
+}
+
+// FillReconciler correlates our fills from the authenticated clob_user feed
+// with the public activity trades by order IDs. Each fill is settled once
+// the grace period has passed since it was matched, and a reconciliation
+// record is emitted: matched, missing from the public feed, or public with a
+// different size.
+type FillReconciler struct {
+	owner   string // Our API key, the owner of our orders; "" treats every maker order as ours
+	emitter events.Emitter
+	grace   time.Duration
+	clock   clock.Clock
+
+	mu      sync.Mutex
+	trades  map[string]time.Time // clob_user trade IDs already expanded into fills
+	pending map[fillKey]*pendingFill
+	public  map[fillKey]*publicFill
+}
+
+// NewFillReconciler creates a reconciler for the orders of owner, waiting
+// grace for public trades to appear
+func NewFillReconciler(owner string, emitter events.Emitter, grace time.Duration) *FillReconciler {
+	return &FillReconciler{
+		owner:   owner,
+		emitter: emitter,
+		grace:   grace,
+		clock:   clock.Real,
+		trades:  make(map[string]time.Time),
+		pending: make(map[fillKey]*pendingFill),
+		public:  make(map[fillKey]*publicFill),
+	}
+}
+
+// SetClock replaces the clock driving the grace period
+func (r *FillReconciler) SetClock(c clock.Clock) {
+	r.clock = clock.OrReal(c)
+}
+
+// RecordUserTrade adds the fills of a clob_user trade. Status updates of a
+// trade already seen are ignored, and failed trades are dropped.
+func (r *FillReconciler) RecordUserTrade(trade *rtds.ClobUserTrade) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if trade.Status == "FAILED" {
+		for _, mo := range trade.MakerOrders {
+			delete(r.pending, fillKey{trade.TakerOrderID, mo.OrderID})
+		}
+		return
+	}
+	if _, ok := r.trades[trade.ID]; ok {
+		return
+	}
+	now := r.clock.Now()
+	r.trades[trade.ID] = now
+
+	taker := r.owner == "" || trade.Owner == r.owner
+	for _, mo := range trade.MakerOrders {
+		if !taker && mo.Owner != r.owner {
+			continue // Another maker's part of the trade
+		}
+		size, _ := strconv.ParseFloat(mo.MatchedAmount, 64)
+		r.pending[fillKey{trade.TakerOrderID, mo.OrderID}] = &pendingFill{
+			rec: FillReconciliation{
+				TradeID:      trade.ID,
+				Market:       trade.Market,
+				TakerOrderID: trade.TakerOrderID,
+				MakerOrderID: mo.OrderID,
+				Size:         size,
+				MatchedAt:    now,
+			},
+			seen: now,
+		}
+	}
+}
+
+// Record adds a public activity trade. Trades without both order IDs can't
+// be matched and are ignored; duplicates are counted once, so Record can
+// observe trades ahead of the dedupe and filter stages.
+func (r *FillReconciler) Record(trade *rtds.ActivityTradePayload) {
+	if trade.TakerOrderID == "" || trade.MakerOrderID == "" {
+		return
+	}
+	key := fillKey{trade.TakerOrderID, trade.MakerOrderID}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, ok := r.public[key]
+	if !ok {
+		p = &publicFill{keys: make(map[string]bool)}
+		r.public[key] = p
+	}
+	if p.keys[trade.DedupeKey()] {
+		return
+	}
+	p.keys[trade.DedupeKey()] = true
+	p.size += trade.Size
+	p.trades++
+	p.seen = r.clock.Now()
+	if trade.TransactionHash != "" {
+		p.txHashes = append(p.txHashes, trade.TransactionHash)
+	}
+}
+
+// Run settles fills past the grace period until ctx is cancelled
+func (r *FillReconciler) Run(ctx context.Context) error {
+	ticker := r.clock.NewTicker(max(r.grace/4, time.Second))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C():
+			r.Settle(ctx)
+		}
+	}
+}
+
+// Settle emits a reconciliation for every fill older than the grace period
+// and forgets public trades no fill claimed within twice the grace period
+func (r *FillReconciler) Settle(ctx context.Context) []FillReconciliation {
+	r.mu.Lock()
+	now := r.clock.Now()
+	var settled []FillReconciliation
+	for key, f := range r.pending {
+		if now.Sub(f.seen) < r.grace {
+			continue
+		}
+		rec := f.rec
+		if p, ok := r.public[key]; ok {
+			rec.PublicSize, rec.PublicTrades, rec.TxHashes = p.size, p.trades, p.txHashes
+			delete(r.public, key)
+		}
+		switch {
+		case rec.PublicTrades == 0:
+			rec.Status = FillMissing
+		case math.Abs(rec.PublicSize-rec.Size) > fillSizeTolerance:
+			rec.Status = FillSizeMismatch
+		default:
+			rec.Status = FillMatched
+		}
+		settled = append(settled, rec)
+		delete(r.pending, key)
+	}
+	for key, p := range r.public {
+		if now.Sub(p.seen) > 2*r.grace {
+			delete(r.public, key)
+		}
+	}
+	for id, seen := range r.trades {
+		if now.Sub(seen) > userTradeRetention {
+			delete(r.trades, id)
+		}
+	}
+	r.mu.Unlock()
+
+	for _, rec := range settled {
+		if rec.Status != FillMatched {
+			fillLog.WarnContext(ctx, "Fill not matched by public trades", "trade_id", rec.TradeID, "status", rec.Status,
+				"taker_order_id", rec.TakerOrderID, "maker_order_id", rec.MakerOrderID,
+				"size", rec.Size, "public_size", rec.PublicSize, "public_trades", rec.PublicTrades)
+		}
+		if err := r.emitter.Emit(ctx, events.New(events.TypeFillReconciliation, rec.TradeID, rec)); err != nil {
+			fillLog.ErrorContext(ctx, "Error emitting fill reconciliation", "trade_id", rec.TradeID, "error", err)
+		}
+	}
+	return settled
+}
@@ -0,0 +1,248 @@
+package domain
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/FatwaArya/pm-ingest/pkg/clock"
+	"github.com/FatwaArya/pm-ingest/pkg/rtds"
+)
+
+// flowSweepInterval is how often idle wallets and markets are evicted
+const flowSweepInterval = time.Minute
+
+// RoleFlow is the volume traded in one role (maker or taker)
+type RoleFlow struct {
+	Trades       int64   `json:"trades"`
+	Notional     float64 `json:"notional"`
+	BuyNotional  float64 `json:"buyNotional"`
+	SellNotional float64 `json:"sellNotional"`
+	Fees         float64 `json:"fees"`
+}
+
+func (f *RoleFlow) add(side string, notional, fee float64) {
+	f.Trades++
+	f.Notional += notional
+	f.Fees += fee
+	switch side {
+	case rtds.SideBuy:
+		f.BuyNotional += notional
+	case rtds.SideSell:
+		f.SellNotional += notional
+	}
+}
+
+// FlowStats breaks down the trades of a wallet or market by role. Aggressor
+// notional attributes each fill to the taker's side, so AggressorBuy minus
+// AggressorSell is the net pressure on the price.
+// Fees are totalled and bucketed by hour, so fee drag can be compared
+// across wallets and over time.
+type FlowStats struct {
+	Trades                int64     `json:"trades"`
+	Notional              float64   `json:"notional"`
+	Maker                 RoleFlow  `json:"maker"`
+	Taker                 RoleFlow  `json:"taker"`
+	UnknownRoleTrades     int64     `json:"unknownRoleTrades"`
+	AggressorBuyNotional  float64   `json:"aggressorBuyNotional"`
+	AggressorSellNotional float64   `json:"aggressorSellNotional"`
+	NetAggressorNotional  float64   `json:"netAggressorNotional"`
+	MakerShare            float64   `json:"makerShare"`       // Fraction of role-attributed notional provided as maker
+	Fees                  float64   `json:"fees"`             // Total fee drag in USD
+	EffectiveFeeRate      float64   `json:"effectiveFeeRate"` // Fees as a fraction of notional
+	HourlyFees            []FeeHour `json:"hourlyFees"`       // Oldest first, covering the retention period
+	FirstTrade            time.Time `json:"firstTrade"`
+	LastTrade             time.Time `json:"lastTrade"`
+	lastSeen              time.Time
+}
+
+// FeeHour is the fees paid within one clock hour of trade time
+type FeeHour struct {
+	Hour             time.Time `json:"hour"`
+	Trades           int64     `json:"trades"`
+	Notional         float64   `json:"notional"`
+	Fees             float64   `json:"fees"`
+	EffectiveFeeRate float64   `json:"effectiveFeeRate"`
+}
+
+// addFee adds a trade's fee to its hour, dropping hours older than retention
+func (s *FlowStats) addFee(ts time.Time, notional, fee float64, retention time.Duration) {
+	s.Fees += fee
+	if s.Notional > 0 {
+		s.EffectiveFeeRate = s.Fees / s.Notional
+	}
+
+	hour := ts.Truncate(time.Hour)
+	i := len(s.HourlyFees)
+	for i > 0 && s.HourlyFees[i-1].Hour.After(hour) {
+		i-- // Late trade; hours are kept in order
+	}
+	if i == 0 || !s.HourlyFees[i-1].Hour.Equal(hour) {
+		s.HourlyFees = slices.Insert(s.HourlyFees, i, FeeHour{Hour: hour})
+		i++
+	}
+	h := &s.HourlyFees[i-1]
+	h.Trades++
+	h.Notional += notional
+	h.Fees += fee
+	if h.Notional > 0 {
+		h.EffectiveFeeRate = h.Fees / h.Notional
+	}
+
+	if retention > 0 {
+		cutoff := s.LastTrade.Add(-retention)
+		drop := 0
+		for drop < len(s.HourlyFees) && s.HourlyFees[drop].Hour.Add(time.Hour).Before(cutoff) {
+			drop++
+		}
+		s.HourlyFees = s.HourlyFees[drop:]
+	}
+}
+
+func (s *FlowStats) add(trade *rtds.ActivityTradePayload, now time.Time, retention time.Duration) {
+	notional := trade.Size * trade.Price
+	ts := time.Unix(trade.Timestamp, 0)
+	if s.Trades == 0 || ts.Before(s.FirstTrade) {
+		s.FirstTrade = ts
+	}
+	if ts.After(s.LastTrade) {
+		s.LastTrade = ts
+	}
+	s.Trades++
+	s.Notional += notional
+	s.lastSeen = now
+
+	switch trade.Role() {
+	case rtds.RoleMaker:
+		s.Maker.add(trade.Side, notional, trade.Fee)
+	case rtds.RoleTaker:
+		s.Taker.add(trade.Side, notional, trade.Fee)
+	default:
+		s.UnknownRoleTrades++
+	}
+	switch trade.AggressorSide() {
+	case rtds.SideBuy:
+		s.AggressorBuyNotional += notional
+	case rtds.SideSell:
+		s.AggressorSellNotional += notional
+	}
+	s.NetAggressorNotional = s.AggressorBuyNotional - s.AggressorSellNotional
+	if attributed := s.Maker.Notional + s.Taker.Notional; attributed > 0 {
+		s.MakerShare = s.Maker.Notional / attributed
+	}
+	s.addFee(ts, notional, trade.Fee, retention)
+}
+
+// FlowTracker keeps maker/taker flow per wallet and per market in memory.
+// Wallets and markets without trades for the retention period are dropped.
+type FlowTracker struct {
+	mu        sync.Mutex
+	clock     clock.Clock
+	retention time.Duration
+	wallets   map[string]*FlowStats
+	markets   map[string]*FlowStats
+	lastSweep time.Time
+}
+
+// NewFlowTracker creates a tracker keeping idle entries for retention
+func NewFlowTracker(retention time.Duration) *FlowTracker {
+	return &FlowTracker{
+		clock:     clock.Real,
+		retention: retention,
+		wallets:   make(map[string]*FlowStats),
+		markets:   make(map[string]*FlowStats),
+		lastSweep: time.Now(),
+	}
+}
+
+// SetClock replaces the clock used for eviction
+func (t *FlowTracker) SetClock(c clock.Clock) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.clock = clock.OrReal(c)
+	t.lastSweep = t.clock.Now()
+}
+
+// Record adds a trade to its wallet's and market's stats
+func (t *FlowTracker) Record(trade *rtds.ActivityTradePayload) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.clock.Now()
+	t.sweep(now)
+	if wallet := strings.ToLower(trade.ProxyWalletAddress); wallet != "" {
+		statsFor(t.wallets, wallet).add(trade, now, t.retention)
+	}
+	if market := MarketKey(trade); market != "" {
+		statsFor(t.markets, market).add(trade, now, t.retention)
+	}
+}
+
+// Wallet returns the flow of a wallet
+func (t *FlowTracker) Wallet(address string) (FlowStats, bool) {
+	return t.get(t.wallets, strings.ToLower(address))
+}
+
+// Market returns the flow of a market by condition ID (or slug)
+func (t *FlowTracker) Market(key string) (FlowStats, bool) {
+	return t.get(t.markets, key)
+}
+
+func (t *FlowTracker) get(m map[string]*FlowStats, key string) (FlowStats, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s, ok := m[key]
+	if !ok {
+		return FlowStats{}, false
+	}
+	stats := *s
+	stats.HourlyFees = slices.Clone(s.HourlyFees)
+	return stats, true
+}
+
+// Wallets returns the flow of every tracked wallet, without hourly fees
+func (t *FlowTracker) Wallets() map[string]FlowStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	wallets := make(map[string]FlowStats, len(t.wallets))
+	for address, s := range t.wallets {
+		stats := *s
+		stats.HourlyFees = nil
+		wallets[address] = stats
+	}
+	return wallets
+}
+
+// sweep evicts idle entries; called with mu held
+func (t *FlowTracker) sweep(now time.Time) {
+	if t.retention <= 0 || now.Sub(t.lastSweep) < flowSweepInterval {
+		return
+	}
+	t.lastSweep = now
+	for _, m := range []map[string]*FlowStats{t.wallets, t.markets} {
+		for key, s := range m {
+			if now.Sub(s.lastSeen) > t.retention {
+				delete(m, key)
+			}
+		}
+	}
+}
+
+func statsFor(m map[string]*FlowStats, key string) *FlowStats {
+	s, ok := m[key]
+	if !ok {
+		s = &FlowStats{}
+		m[key] = s
+	}
+	return s
+}
+
+// MarketKey identifies a trade's market: the condition ID, or the slug for
+// payloads without one
+func MarketKey(trade *rtds.ActivityTradePayload) string {
+	if trade.ConditionID != "" {
+		return trade.ConditionID
+	}
+	return trade.MarketSlug
+}
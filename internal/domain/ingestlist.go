@@ -0,0 +1,139 @@
+package domain
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/FatwaArya/pm-ingest/pkg/rtds"
+)
+
+// Ingest list modes
+const (
+	// IngestFull ingests every trade of the market or event, bypassing the
+	// min-size, filter and sample stages
+	IngestFull = "full"
+	// IngestDrop drops every trade of the market or event
+	IngestDrop = "drop"
+)
+
+// IngestRule selects the fidelity of one market (condition ID or slug) or
+// event (event slug). When several rules match a trade, the highest
+// priority wins, then the market rule over the event rule.
+type IngestRule struct {
+	Market   string    `json:"market,omitempty"`
+	Event    string    `json:"event,omitempty"`
+	Mode     string    `json:"mode"`
+	Priority int       `json:"priority"`
+	AddedAt  time.Time `json:"addedAt"`
+}
+
+// key identifies the rule's market or event
+func (r IngestRule) key() string {
+	if r.Market != "" {
+		return "market:" + r.Market
+	}
+	return "event:" + r.Event
+}
+
+// Validate checks that the rule names exactly one market or event and a known mode
+func (r IngestRule) Validate() error {
+	if (r.Market == "") == (r.Event == "") {
+		return fmt.Errorf("rule needs either a market or an event")
+	}
+	if r.Mode != IngestFull && r.Mode != IngestDrop {
+		return fmt.Errorf("mode must be %q or %q", IngestFull, IngestDrop)
+	}
+	return nil
+}
+
+// IngestLists holds the allow (full fidelity) and drop lists, manageable at
+// runtime through the admin API
+type IngestLists struct {
+	mu    sync.RWMutex
+	rules map[string]IngestRule
+}
+
+// NewIngestLists creates empty lists
+func NewIngestLists() *IngestLists {
+	return &IngestLists{rules: make(map[string]IngestRule)}
+}
+
+// Set adds a rule, replacing the rule for the same market or event
+func (l *IngestLists) Set(rule IngestRule) error {
+	rule.Market = strings.TrimSpace(rule.Market)
+	rule.Event = strings.TrimSpace(rule.Event)
+	rule.Mode = strings.ToLower(rule.Mode)
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+	if rule.AddedAt.IsZero() {
+		rule.AddedAt = time.Now()
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rules[rule.key()] = rule
+	return nil
+}
+
+// Remove deletes the rule of a market or event and reports whether there was one
+func (l *IngestLists) Remove(market, event string) bool {
+	key := IngestRule{Market: market, Event: event}.key()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, ok := l.rules[key]
+	delete(l.rules, key)
+	return ok
+}
+
+// Rules returns every rule, highest priority first
+func (l *IngestLists) Rules() []IngestRule {
+	l.mu.RLock()
+	rules := make([]IngestRule, 0, len(l.rules))
+	for _, r := range l.rules {
+		rules = append(rules, r)
+	}
+	l.mu.RUnlock()
+	slices.SortFunc(rules, func(a, b IngestRule) int {
+		if a.Priority != b.Priority {
+			return b.Priority - a.Priority
+		}
+		return strings.Compare(a.key(), b.key())
+	})
+	return rules
+}
+
+// Match returns the mode of the rule deciding a trade, or "" if none matches
+func (l *IngestLists) Match(trade *rtds.ActivityTradePayload) string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if len(l.rules) == 0 {
+		return ""
+	}
+	var best *IngestRule
+	for _, key := range []string{
+		"market:" + trade.ConditionID,
+		"market:" + trade.MarketSlug,
+		"event:" + trade.EventSlug,
+	} {
+		if r, ok := l.rules[key]; ok && (best == nil || r.Priority > best.Priority) {
+			best = &r
+		}
+	}
+	if best == nil {
+		return ""
+	}
+	return best.Mode
+}
+
+// Full reports whether a trade is ingested with full fidelity
+func (l *IngestLists) Full(trade *rtds.ActivityTradePayload) bool {
+	return l.Match(trade) == IngestFull
+}
+
+// Keep reports whether a trade isn't on the drop list
+func (l *IngestLists) Keep(trade *rtds.ActivityTradePayload) bool {
+	return l.Match(trade) != IngestDrop
+}
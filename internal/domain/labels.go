@@ -0,0 +1,239 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/FatwaArya/pm-ingest/pkg/clock"
+	"github.com/FatwaArya/pm-ingest/pkg/rtds"
+)
+
+// Label tags a wallet for downstream consumers
+type Label string
+
+// Wallet labels
+const (
+	LabelWhale          Label = "whale"
+	LabelMarketMaker    Label = "market_maker"
+	LabelInsiderSuspect Label = "insider_suspect"
+	LabelCopyTarget     Label = "copy_target"
+	LabelDenylisted     Label = "denylisted"
+)
+
+// Labels lists every known label
+var Labels = []Label{LabelWhale, LabelMarketMaker, LabelInsiderSuspect, LabelCopyTarget, LabelDenylisted}
+
+// Label sources
+const (
+	LabelSourceManual   = "manual"
+	LabelSourceDetector = "detector"
+)
+
+// WalletLabel is a label on a wallet and why it was set
+type WalletLabel struct {
+	Label   Label     `json:"label"`
+	Source  string    `json:"source"` // manual or detector
+	Reason  string    `json:"reason,omitempty"`
+	AddedAt time.Time `json:"addedAt"`
+}
+
+// Validate checks that the label is known
+func (l WalletLabel) Validate() error {
+	if !slices.Contains(Labels, l.Label) {
+		return fmt.Errorf("unknown label %q", l.Label)
+	}
+	return nil
+}
+
+// LabelRules are the thresholds the detectors label wallets at. Zero
+// disables a detector.
+type LabelRules struct {
+	WhaleNotional         float64 // Single trade notional, USD
+	MarketMakerShare      float64 // Fraction of notional traded as maker...
+	MarketMakerMinTrades  int64   // ...over at least this many trades
+	InsiderNotional       float64 // Trade on a watchlisted (e.g. newly listed) market
+	CopyTargetCalibration float64 // Confidence calibration, percent...
+	CopyTargetMinSamples  int     // ...over at least this many closed positions
+}
+
+// DefaultLabelRules labels the wallets discovery already treats as high
+// value, mostly-maker wallets, and well-calibrated traders
+var DefaultLabelRules = LabelRules{
+	WhaleNotional:         MinimumTradeSize,
+	MarketMakerShare:      0.8,
+	MarketMakerMinTrades:  50,
+	InsiderNotional:       MinimumTradeSize,
+	CopyTargetCalibration: 70,
+	CopyTargetMinSamples:  20,
+}
+
+// WalletLabels stores the labels of each wallet. Labels are set by the
+// detectors (Detect, DetectCopyTargets) or manually, and copied onto every
+// trade of the wallet by Enrich. A manual label is never replaced by a
+// detector.
+type WalletLabels struct {
+	rules LabelRules
+	clock clock.Clock
+
+	mu      sync.RWMutex
+	wallets map[string]map[Label]WalletLabel
+}
+
+// NewWalletLabels creates an empty label store detecting with rules
+func NewWalletLabels(rules LabelRules) *WalletLabels {
+	return &WalletLabels{
+		rules:   rules,
+		clock:   clock.Real,
+		wallets: make(map[string]map[Label]WalletLabel),
+	}
+}
+
+// SetClock replaces the clock labels are timestamped with
+func (l *WalletLabels) SetClock(c clock.Clock) {
+	l.clock = clock.OrReal(c)
+}
+
+// Set adds or replaces a label on wallet
+func (l *WalletLabels) Set(wallet string, label WalletLabel) error {
+	if err := label.Validate(); err != nil {
+		return err
+	}
+	if label.AddedAt.IsZero() {
+		label.AddedAt = l.clock.Now()
+	}
+	wallet = strings.ToLower(wallet)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	labels, ok := l.wallets[wallet]
+	if !ok {
+		labels = make(map[Label]WalletLabel)
+		l.wallets[wallet] = labels
+	}
+	labels[label.Label] = label
+	return nil
+}
+
+// detected labels wallet unless it already has the label. reason is only
+// formatted for new labels, since detectors fire on every trade.
+func (l *WalletLabels) detected(wallet string, label Label, reason string, args ...any) {
+	if l.Has(wallet, label) {
+		return
+	}
+	l.Set(wallet, WalletLabel{Label: label, Source: LabelSourceDetector, Reason: fmt.Sprintf(reason, args...)})
+}
+
+// Remove takes a label off wallet and reports whether it was there
+func (l *WalletLabels) Remove(wallet string, label Label) bool {
+	wallet = strings.ToLower(wallet)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	labels := l.wallets[wallet]
+	if _, ok := labels[label]; !ok {
+		return false
+	}
+	delete(labels, label)
+	if len(labels) == 0 {
+		delete(l.wallets, wallet)
+	}
+	return true
+}
+
+// Get returns the labels of wallet, sorted by label
+func (l *WalletLabels) Get(wallet string) []WalletLabel {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return sortedLabels(l.wallets[strings.ToLower(wallet)])
+}
+
+// Has reports whether wallet has label
+func (l *WalletLabels) Has(wallet string, label Label) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	_, ok := l.wallets[strings.ToLower(wallet)][label]
+	return ok
+}
+
+// Names returns the label names of wallet, sorted
+func (l *WalletLabels) Names(wallet string) []string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	labels := l.wallets[strings.ToLower(wallet)]
+	if len(labels) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(labels))
+	for label := range labels {
+		names = append(names, string(label))
+	}
+	slices.Sort(names)
+	return names
+}
+
+// All returns the labels of every labelled wallet
+func (l *WalletLabels) All() map[string][]WalletLabel {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	all := make(map[string][]WalletLabel, len(l.wallets))
+	for wallet, labels := range l.wallets {
+		all[wallet] = sortedLabels(labels)
+	}
+	return all
+}
+
+func sortedLabels(labels map[Label]WalletLabel) []WalletLabel {
+	sorted := make([]WalletLabel, 0, len(labels))
+	for _, label := range labels {
+		sorted = append(sorted, label)
+	}
+	slices.SortFunc(sorted, func(a, b WalletLabel) int {
+		return strings.Compare(string(a.Label), string(b.Label))
+	})
+	return sorted
+}
+
+// Detect returns a trade observer labelling whales, market makers (from
+// flow) and large trades on watchlisted markets. flow and watchlist may be
+// nil to disable their detectors.
+func (l *WalletLabels) Detect(flow *FlowTracker, watchlist *Watchlist) func(*rtds.ActivityTradePayload) {
+	r := l.rules
+	return func(trade *rtds.ActivityTradePayload) {
+		wallet := trade.ProxyWalletAddress
+		if wallet == "" {
+			return
+		}
+		notional := trade.Size * trade.Price
+		if r.WhaleNotional > 0 && notional >= r.WhaleNotional {
+			l.detected(wallet, LabelWhale, "$%.0f trade on %s", notional, trade.MarketSlug)
+		}
+		if r.InsiderNotional > 0 && watchlist != nil && notional >= r.InsiderNotional && watchlist.Contains(trade.ConditionID) {
+			l.detected(wallet, LabelInsiderSuspect, "$%.0f trade on watched market %s", notional, trade.MarketSlug)
+		}
+		if r.MarketMakerShare > 0 && flow != nil {
+			if stats, ok := flow.Wallet(wallet); ok && stats.Trades >= r.MarketMakerMinTrades && stats.MakerShare >= r.MarketMakerShare {
+				l.detected(wallet, LabelMarketMaker, "%.0f%% maker over %d trades", stats.MakerShare*100, stats.Trades)
+			}
+		}
+	}
+}
+
+// DetectCopyTargets returns a confidence hook labelling well-calibrated
+// wallets as copy targets
+func (l *WalletLabels) DetectCopyTargets() func(ctx context.Context, score ScoredConfidence) {
+	r := l.rules
+	return func(ctx context.Context, score ScoredConfidence) {
+		p := score.Prediction
+		if r.CopyTargetCalibration > 0 && p.SampleSize >= r.CopyTargetMinSamples && p.Calibration >= r.CopyTargetCalibration {
+			l.detected(score.Wallet, LabelCopyTarget, "%.0f%% calibration over %d positions", p.Calibration, p.SampleSize)
+		}
+	}
+}
+
+// Enrich sets the labels of the trade's wallet on the trade
+func (l *WalletLabels) Enrich(ctx context.Context, trade *rtds.ActivityTradePayload) error {
+	trade.Labels = l.Names(trade.ProxyWalletAddress)
+	return nil
+}
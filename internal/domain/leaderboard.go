@@ -0,0 +1,389 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/FatwaArya/pm-ingest/internal/logging"
+	"github.com/FatwaArya/pm-ingest/pkg/clock"
+	"github.com/FatwaArya/pm-ingest/pkg/dataapi"
+	"github.com/FatwaArya/pm-ingest/pkg/rtds"
+)
+
+// LeaderboardWindow is a rolling period the leaderboard ranks wallets over
+type LeaderboardWindow string
+
+const (
+	LeaderboardDay   LeaderboardWindow = "24h"
+	LeaderboardWeek  LeaderboardWindow = "7d"
+	LeaderboardMonth LeaderboardWindow = "30d"
+)
+
+// LeaderboardWindows are the ranked windows, shortest first
+var LeaderboardWindows = []LeaderboardWindow{LeaderboardDay, LeaderboardWeek, LeaderboardMonth}
+
+// Duration returns the length of the window
+func (w LeaderboardWindow) Duration() time.Duration {
+	switch w {
+	case LeaderboardDay:
+		return 24 * time.Hour
+	case LeaderboardWeek:
+		return 7 * 24 * time.Hour
+	case LeaderboardMonth:
+		return 30 * 24 * time.Hour
+	}
+	return 0
+}
+
+const (
+	// leaderboardRetention is how much trade history is kept per wallet
+	leaderboardRetention = 30 * 24 * time.Hour
+	// leaderboardMaxPositions bounds the closed positions fetched per
+	// wallet for its realized PnL, newest first
+	leaderboardMaxPositions = 500
+)
+
+var leaderboardLog = logging.NewRateLimited(logging.For("leaderboard"), 5*time.Second)
+
+// LeaderboardEntry is one ranked wallet of a window
+type LeaderboardEntry struct {
+	Rank        int       `json:"rank"`
+	Wallet      string    `json:"wallet"`
+	Notional    float64   `json:"notional"` // USD traded within the window
+	Trades      int64     `json:"trades"`
+	Fees        float64   `json:"fees"`        // Paid on the trades within the window, as in FlowStats
+	RealizedPnL float64   `json:"realizedPnl"` // Of positions closed within the window
+	NetPnL      float64   `json:"netPnl"`      // RealizedPnL less Fees, what ranks by PnL
+	PnLKnown    bool      `json:"pnlKnown"`    // False until the closed positions were fetched
+	Discovered  bool      `json:"discovered"`  // Profiled by discovery as a high-value trader
+	LastTrade   time.Time `json:"lastTrade"`
+}
+
+// LeaderboardSnapshot is the ranking of one window at one point in time,
+// by notional. JSON holds the encoded snapshot, as for TopTradersSnapshot.
+type LeaderboardSnapshot struct {
+	Window      LeaderboardWindow  `json:"window"`
+	Entries     []LeaderboardEntry `json:"entries"`
+	GeneratedAt time.Time          `json:"generatedAt"`
+	JSON        []byte             `json:"-"`
+}
+
+// volumeHour is a wallet's trading within one clock hour
+type volumeHour struct {
+	start    int64 // Unix seconds
+	notional float64
+	fees     float64
+	trades   int64
+}
+
+// walletVolume is a wallet's hourly trading over the retention period
+type walletVolume struct {
+	hours     []volumeHour // Oldest first
+	lastTrade time.Time
+}
+
+func (v *walletVolume) add(at time.Time, notional, fee float64) {
+	start := at.Truncate(time.Hour).Unix()
+	i := len(v.hours)
+	for i > 0 && v.hours[i-1].start > start {
+		i--
+	}
+	if i > 0 && v.hours[i-1].start == start {
+		v.hours[i-1].notional += notional
+		v.hours[i-1].fees += fee
+		v.hours[i-1].trades++
+	} else {
+		v.hours = append(v.hours, volumeHour{})
+		copy(v.hours[i+1:], v.hours[i:])
+		v.hours[i] = volumeHour{start: start, notional: notional, fees: fee, trades: 1}
+	}
+	if at.After(v.lastTrade) {
+		v.lastTrade = at
+	}
+}
+
+// total sums the hours ending after since
+func (v *walletVolume) total(since time.Time) (notional, fees float64, trades int64) {
+	cutoff := since.Add(-time.Hour).Unix()
+	for i := len(v.hours) - 1; i >= 0 && v.hours[i].start > cutoff; i-- {
+		notional += v.hours[i].notional
+		fees += v.hours[i].fees
+		trades += v.hours[i].trades
+	}
+	return notional, fees, trades
+}
+
+// trim drops the hours ending before since
+func (v *walletVolume) trim(since time.Time) {
+	cutoff := since.Add(-time.Hour).Unix()
+	i := 0
+	for i < len(v.hours) && v.hours[i].start <= cutoff {
+		i++
+	}
+	v.hours = v.hours[i:]
+}
+
+// walletPnL is a wallet's realized PnL per window, as of fetchedAt
+type walletPnL struct {
+	windows   map[LeaderboardWindow]float64
+	fetchedAt time.Time
+}
+
+// Leaderboard ranks wallets by the notional they traded over the last 24
+// hours, 7 days and 30 days, rebuilt every interval from the trade stream.
+// The realized PnL of the ranked wallets comes from their closed positions
+// in the Data API, refreshed once older than pnlTTL, so it is only known
+// for wallets that made a ranking; the fees they paid in the window are
+// taken off it for their net PnL. Each rebuild is published to a Kafka
+// topic when a producer is set, one record per window.
+type Leaderboard struct {
+	apiClient *dataapi.Client
+	size      int
+	interval  time.Duration
+	pnlTTL    time.Duration
+	clock     clock.Clock
+	producer  SummaryProducer
+	topic     string
+
+	mu         sync.Mutex
+	wallets    map[string]*walletVolume
+	discovered map[string]struct{}
+	pnl        map[string]*walletPnL
+
+	snapshots atomic.Pointer[map[LeaderboardWindow]*LeaderboardSnapshot]
+}
+
+// NewLeaderboard creates a leaderboard of the size most active wallets per
+// window, rebuilt every interval. A nil apiClient leaves PnL unknown.
+func NewLeaderboard(apiClient *dataapi.Client, size int, interval, pnlTTL time.Duration) *Leaderboard {
+	l := &Leaderboard{
+		apiClient:  apiClient,
+		size:       size,
+		interval:   interval,
+		pnlTTL:     pnlTTL,
+		clock:      clock.Real,
+		wallets:    make(map[string]*walletVolume),
+		discovered: make(map[string]struct{}),
+		pnl:        make(map[string]*walletPnL),
+	}
+	snapshots := make(map[LeaderboardWindow]*LeaderboardSnapshot, len(LeaderboardWindows))
+	for _, w := range LeaderboardWindows {
+		snapshots[w] = encodeLeaderboard(&LeaderboardSnapshot{Window: w, Entries: []LeaderboardEntry{}})
+	}
+	l.snapshots.Store(&snapshots)
+	return l
+}
+
+// SetClock replaces the clock driving the windows and rebuild schedule
+func (l *Leaderboard) SetClock(c clock.Clock) {
+	l.clock = clock.OrReal(c)
+}
+
+// SetProducer publishes every rebuild to topic
+func (l *Leaderboard) SetProducer(producer SummaryProducer, topic string) {
+	l.producer = producer
+	l.topic = topic
+}
+
+// Record adds a trade to its wallet's volume
+func (l *Leaderboard) Record(trade *rtds.ActivityTradePayload) {
+	wallet := strings.ToLower(trade.ProxyWalletAddress)
+	if wallet == "" || trade.Size <= 0 {
+		return
+	}
+	at := time.Unix(trade.Timestamp, 0)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if at.Before(l.clock.Now().Add(-leaderboardRetention)) {
+		return
+	}
+	v, ok := l.wallets[wallet]
+	if !ok {
+		v = &walletVolume{}
+		l.wallets[wallet] = v
+	}
+	v.add(at, trade.Price*trade.Size, trade.Fee)
+}
+
+// MarkDiscovered flags wallet as a high-value trader found by discovery
+func (l *Leaderboard) MarkDiscovered(wallet string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.discovered[strings.ToLower(wallet)] = struct{}{}
+}
+
+// Snapshot returns the latest ranking of window, or nil for an unknown window
+func (l *Leaderboard) Snapshot(window LeaderboardWindow) *LeaderboardSnapshot {
+	return (*l.snapshots.Load())[window]
+}
+
+// Run rebuilds the leaderboard every interval until ctx is cancelled
+func (l *Leaderboard) Run(ctx context.Context) error {
+	l.Rebuild(ctx)
+	ticker := l.clock.NewTicker(l.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C():
+			l.Rebuild(ctx)
+		}
+	}
+}
+
+// Rebuild ranks the wallets of every window, refreshes the realized PnL of
+// the ranked ones and replaces (and publishes) the snapshots
+func (l *Leaderboard) Rebuild(ctx context.Context) {
+	now := l.clock.Now()
+	rankings := l.rank(now)
+
+	ranked := make(map[string]struct{})
+	for _, entries := range rankings {
+		for _, e := range entries {
+			ranked[e.Wallet] = struct{}{}
+		}
+	}
+	for wallet := range ranked {
+		if ctx.Err() != nil {
+			break
+		}
+		l.refreshPnL(ctx, wallet, now)
+	}
+
+	l.mu.Lock()
+	for wallet := range l.pnl {
+		if _, ok := ranked[wallet]; !ok {
+			delete(l.pnl, wallet)
+		}
+	}
+	snapshots := make(map[LeaderboardWindow]*LeaderboardSnapshot, len(rankings))
+	for window, entries := range rankings {
+		for i := range entries {
+			if pnl, ok := l.pnl[entries[i].Wallet]; ok {
+				entries[i].RealizedPnL = pnl.windows[window]
+				entries[i].NetPnL = entries[i].RealizedPnL - entries[i].Fees
+				entries[i].PnLKnown = true
+			}
+			_, entries[i].Discovered = l.discovered[entries[i].Wallet]
+		}
+		snapshots[window] = encodeLeaderboard(&LeaderboardSnapshot{Window: window, Entries: entries, GeneratedAt: now})
+	}
+	l.mu.Unlock()
+	l.snapshots.Store(&snapshots)
+
+	if l.producer != nil {
+		l.publish(ctx, snapshots)
+	}
+}
+
+// rank sorts the wallets of every window by notional, dropping history
+// older than the retention period
+func (l *Leaderboard) rank(now time.Time) map[LeaderboardWindow][]LeaderboardEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for wallet, v := range l.wallets {
+		v.trim(now.Add(-leaderboardRetention))
+		if len(v.hours) == 0 {
+			delete(l.wallets, wallet)
+		}
+	}
+
+	rankings := make(map[LeaderboardWindow][]LeaderboardEntry, len(LeaderboardWindows))
+	for _, window := range LeaderboardWindows {
+		since := now.Add(-window.Duration())
+		entries := make([]LeaderboardEntry, 0, len(l.wallets))
+		for wallet, v := range l.wallets {
+			notional, fees, trades := v.total(since)
+			if trades == 0 {
+				continue
+			}
+			entries = append(entries, LeaderboardEntry{
+				Wallet:    wallet,
+				Notional:  notional,
+				Trades:    trades,
+				Fees:      fees,
+				LastTrade: v.lastTrade,
+			})
+		}
+		sort.Slice(entries, func(i, j int) bool {
+			if entries[i].Notional != entries[j].Notional {
+				return entries[i].Notional > entries[j].Notional
+			}
+			return entries[i].Wallet < entries[j].Wallet
+		})
+		if len(entries) > l.size {
+			entries = entries[:l.size]
+		}
+		for i := range entries {
+			entries[i].Rank = i + 1
+		}
+		rankings[window] = entries
+	}
+	return rankings
+}
+
+// refreshPnL fetches the closed positions of wallet when its PnL is older
+// than pnlTTL, keeping the previous PnL if the request fails
+func (l *Leaderboard) refreshPnL(ctx context.Context, wallet string, now time.Time) {
+	if l.apiClient == nil {
+		return
+	}
+	l.mu.Lock()
+	cached, ok := l.pnl[wallet]
+	l.mu.Unlock()
+	if ok && now.Sub(cached.fetchedAt) < l.pnlTTL {
+		return
+	}
+
+	positions, err := l.apiClient.GetAllClosedPositions(ctx, dataapi.ClosedPositionsQueryParams{
+		User:          wallet,
+		SortBy:        "TIMESTAMP",
+		SortDirection: "DESC",
+	}, leaderboardMaxPositions)
+	if err != nil {
+		leaderboardLog.Printf("Error fetching closed positions of %s: %v", wallet, err)
+		return
+	}
+
+	pnl := &walletPnL{windows: make(map[LeaderboardWindow]float64, len(LeaderboardWindows)), fetchedAt: now}
+	for _, p := range positions {
+		closed := time.Unix(p.Timestamp, 0)
+		for _, window := range LeaderboardWindows {
+			if !closed.Before(now.Add(-window.Duration())) {
+				pnl.windows[window] += p.RealizedPnl
+			}
+		}
+	}
+	l.mu.Lock()
+	l.pnl[wallet] = pnl
+	l.mu.Unlock()
+}
+
+// publish sends one record per window, keyed by the window
+func (l *Leaderboard) publish(ctx context.Context, snapshots map[LeaderboardWindow]*LeaderboardSnapshot) {
+	for _, window := range LeaderboardWindows {
+		s := snapshots[window]
+		if s.JSON == nil {
+			continue
+		}
+		if err := l.producer.Produce(ctx, l.topic, []byte(window), s.JSON); err != nil {
+			leaderboardLog.Printf("Error publishing %s leaderboard: %v", window, err)
+		}
+	}
+}
+
+func encodeLeaderboard(s *LeaderboardSnapshot) *LeaderboardSnapshot {
+	data, err := json.Marshal(s)
+	if err != nil {
+		leaderboardLog.Printf("Error encoding %s leaderboard: %v", s.Window, err)
+		return s
+	}
+	s.JSON = data
+	return s
+}
@@ -0,0 +1,220 @@
+package domain
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/FatwaArya/pm-ingest/pkg/clock"
+	"github.com/FatwaArya/pm-ingest/pkg/rtds"
+)
+
+const (
+	// liquidityWindow is how many recent trades per market the estimate uses
+	liquidityWindow = 500
+	// liquidityMinTrades is the sample size below which no estimate is made
+	liquidityMinTrades = 20
+	// largeImpact is the estimated price move (in probability) that makes a
+	// trade large regardless of how it ranks against recent trade sizes
+	largeImpact = 0.02
+	// largePercentile ranks a trade as large against recent trade sizes
+	largePercentile = 95
+)
+
+// MarketLiquidity estimates a market's effective liquidity from its recent
+// trades, without the order book. Impact is Kyle's lambda: the average price
+// move per dollar traded between consecutive fills.
+type MarketLiquidity struct {
+	Trades         int     `json:"trades"`
+	MedianNotional float64 `json:"medianNotional"`
+	P90Notional    float64 `json:"p90Notional"`
+	P95Notional    float64 `json:"p95Notional"`
+	ImpactPerUSD   float64 `json:"impactPerUsd"`
+	DepthPerCent   float64 `json:"depthPerCent"` // USD to move the price one cent; 0 if unknown
+	Window         string  `json:"window"`       // Time spanned by the sample
+}
+
+// TradeAssessment puts a trade's size in the context of its market
+type TradeAssessment struct {
+	Notional        float64 `json:"notional"`
+	Percentile      float64 `json:"percentile"`      // Share of recent trades smaller than this one
+	EstimatedImpact float64 `json:"estimatedImpact"` // Expected price move, in probability
+	Large           bool    `json:"large"`
+	Known           bool    `json:"known"` // False if the market has too few trades to judge
+}
+
+type liquidityTrade struct {
+	at       time.Time
+	price    float64 // Probability of outcome 0, so both outcomes share a scale
+	notional float64
+}
+
+type marketWindow struct {
+	trades   []liquidityTrade // Ring buffer, oldest at next once full
+	next     int
+	lastSeen time.Time
+}
+
+// LiquidityEstimator keeps the recent trades of each market to estimate
+// its liquidity
+type LiquidityEstimator struct {
+	mu        sync.Mutex
+	clock     clock.Clock
+	retention time.Duration
+	markets   map[string]*marketWindow
+	lastSweep time.Time
+}
+
+// NewLiquidityEstimator creates an estimator that forgets markets idle for
+// retention
+func NewLiquidityEstimator(retention time.Duration) *LiquidityEstimator {
+	return &LiquidityEstimator{
+		clock:     clock.Real,
+		retention: retention,
+		markets:   make(map[string]*marketWindow),
+		lastSweep: time.Now(),
+	}
+}
+
+// SetClock replaces the clock used for eviction
+func (l *LiquidityEstimator) SetClock(c clock.Clock) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.clock = clock.OrReal(c)
+	l.lastSweep = l.clock.Now()
+}
+
+// Record adds a trade to its market's sample
+func (l *LiquidityEstimator) Record(trade *rtds.ActivityTradePayload) {
+	key := MarketKey(trade)
+	if key == "" || trade.Price <= 0 || trade.Size <= 0 {
+		return
+	}
+	price := trade.Price
+	if trade.OutcomeIndex == 1 {
+		price = 1 - price
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.clock.Now()
+	l.sweep(now)
+
+	w, ok := l.markets[key]
+	if !ok {
+		w = &marketWindow{}
+		l.markets[key] = w
+	}
+	t := liquidityTrade{at: time.Unix(trade.Timestamp, 0), price: price, notional: trade.Size * trade.Price}
+	if len(w.trades) < liquidityWindow {
+		w.trades = append(w.trades, t)
+	} else {
+		w.trades[w.next] = t
+		w.next = (w.next + 1) % liquidityWindow
+	}
+	w.lastSeen = now
+}
+
+// Liquidity returns the estimate for a market (condition ID or slug), and
+// false if it has fewer than liquidityMinTrades recent trades
+func (l *LiquidityEstimator) Liquidity(market string) (MarketLiquidity, bool) {
+	return estimateLiquidity(l.snapshot(market))
+}
+
+// Assess ranks a trade of notional USD against the market's recent trades
+// and estimates its price impact. A trade is large if it is in the top 5% of
+// recent sizes or would move the price by at least two cents.
+func (l *LiquidityEstimator) Assess(market string, notional float64) TradeAssessment {
+	a := TradeAssessment{Notional: notional}
+	trades := l.snapshot(market)
+	est, ok := estimateLiquidity(trades)
+	if !ok {
+		return a
+	}
+
+	smaller := 0
+	for _, t := range trades {
+		if t.notional < notional {
+			smaller++
+		}
+	}
+	a.Known = true
+	a.Percentile = 100 * float64(smaller) / float64(len(trades))
+	a.EstimatedImpact = notional * est.ImpactPerUSD
+	a.Large = notional >= est.P95Notional || a.EstimatedImpact >= largeImpact
+	return a
+}
+
+// snapshot copies a market's window, oldest first
+func (l *LiquidityEstimator) snapshot(market string) []liquidityTrade {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	w, ok := l.markets[market]
+	if !ok {
+		return nil
+	}
+	return w.ordered()
+}
+
+func estimateLiquidity(trades []liquidityTrade) (MarketLiquidity, bool) {
+	if len(trades) < liquidityMinTrades {
+		return MarketLiquidity{Trades: len(trades)}, false
+	}
+
+	notionals := make([]float64, len(trades))
+	var moved, traded float64
+	for i, t := range trades {
+		notionals[i] = t.notional
+		if i > 0 {
+			moved += math.Abs(t.price - trades[i-1].price)
+			traded += t.notional
+		}
+	}
+	sort.Float64s(notionals)
+
+	est := MarketLiquidity{
+		Trades:         len(trades),
+		MedianNotional: percentile(notionals, 50),
+		P90Notional:    percentile(notionals, 90),
+		P95Notional:    percentile(notionals, largePercentile),
+		Window:         trades[len(trades)-1].at.Sub(trades[0].at).String(),
+	}
+	if traded > 0 {
+		est.ImpactPerUSD = moved / traded
+	}
+	if est.ImpactPerUSD > 0 {
+		est.DepthPerCent = 0.01 / est.ImpactPerUSD
+	}
+	return est, true
+}
+
+// ordered returns the window oldest first
+func (w *marketWindow) ordered() []liquidityTrade {
+	return append(append([]liquidityTrade(nil), w.trades[w.next:]...), w.trades[:w.next]...)
+}
+
+// sweep drops idle markets; called with mu held
+func (l *LiquidityEstimator) sweep(now time.Time) {
+	if l.retention <= 0 || now.Sub(l.lastSweep) < flowSweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for key, w := range l.markets {
+		if now.Sub(w.lastSeen) > l.retention {
+			delete(l.markets, key)
+		}
+	}
+}
+
+// percentile returns the p-th percentile of sorted values (nearest rank)
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	return sorted[rank]
+}
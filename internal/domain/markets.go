@@ -0,0 +1,175 @@
+package domain
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/FatwaArya/pm-ingest/internal/events"
+	"github.com/FatwaArya/pm-ingest/internal/logging"
+	"github.com/FatwaArya/pm-ingest/internal/pipeline"
+	"github.com/FatwaArya/pm-ingest/internal/store"
+	"github.com/FatwaArya/pm-ingest/pkg/gamma"
+	"github.com/FatwaArya/pm-ingest/pkg/rtds"
+)
+
+const (
+	// newMarketTimeout bounds the store check, Gamma lookup and emit for one market
+	newMarketTimeout = 15 * time.Second
+	// newMarketConcurrency bounds concurrent lookups; markets over the limit
+	// are picked up again on their next trade
+	newMarketConcurrency = 8
+)
+
+var marketLog = logging.For("markets")
+
+// NewMarket is the payload of a market.new event
+type NewMarket struct {
+	ConditionID string        `json:"conditionId"`
+	Slug        string        `json:"slug,omitempty"`
+	EventSlug   string        `json:"eventSlug,omitempty"`
+	Title       string        `json:"title,omitempty"`
+	FirstTrade  FirstTrade    `json:"firstTrade"`
+	Market      *gamma.Market `json:"market,omitempty"` // Nil if the Gamma lookup failed
+}
+
+// FirstTrade is the trade a new market was detected by
+type FirstTrade struct {
+	Wallet    string  `json:"wallet"`
+	Side      string  `json:"side"`
+	Outcome   string  `json:"outcome"`
+	Price     float64 `json:"price"`
+	Size      float64 `json:"size"`
+	Timestamp int64   `json:"timestamp"`
+}
+
+// MarketDetector emits a market.new event for the first trade seen on each
+// condition ID. Markets are marked seen in the store, so with Redis a market
+// is announced once across replicas and restarts.
+type MarketDetector struct {
+	seen    store.Store
+	gamma   *gamma.Client
+	emitter events.Emitter
+	sem     chan struct{}
+
+	mu    sync.Mutex
+	known map[string]bool // Condition IDs handled (or in flight) in this process
+	onNew []func(NewMarket)
+}
+
+// NewMarketDetector creates a detector that marks markets seen in seen,
+// looks them up with gammaClient and publishes events through emitter
+func NewMarketDetector(seen store.Store, gammaClient *gamma.Client, emitter events.Emitter) *MarketDetector {
+	return &MarketDetector{
+		seen:    seen,
+		gamma:   gammaClient,
+		emitter: emitter,
+		sem:     make(chan struct{}, newMarketConcurrency),
+		known:   make(map[string]bool),
+	}
+}
+
+// OnNewMarket registers fn to run for every new market after its event is
+// emitted, e.g. to add it to a watchlist
+func (d *MarketDetector) OnNewMarket(fn func(NewMarket)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.onNew = append(d.onNew, fn)
+}
+
+// Middleware checks the market of every trade. Lookups run in the
+// background so trades are never held up by Gamma.
+func (d *MarketDetector) Middleware() pipeline.Middleware {
+	return func(next pipeline.Handler) pipeline.Handler {
+		return func(ctx context.Context, trade *rtds.ActivityTradePayload) error {
+			d.Observe(ctx, trade)
+			return next(ctx, trade)
+		}
+	}
+}
+
+// Observe starts handling trade's market if this process hasn't seen it
+func (d *MarketDetector) Observe(ctx context.Context, trade *rtds.ActivityTradePayload) {
+	conditionID := trade.ConditionID
+	if conditionID == "" {
+		return
+	}
+
+	d.mu.Lock()
+	if d.known[conditionID] {
+		d.mu.Unlock()
+		return
+	}
+	d.known[conditionID] = true
+	d.mu.Unlock()
+
+	select {
+	case d.sem <- struct{}{}:
+	default:
+		d.forget(conditionID) // Busy; retry on the market's next trade
+		return
+	}
+
+	first := FirstTrade{
+		Wallet:    trade.ProxyWalletAddress,
+		Side:      trade.Side,
+		Outcome:   trade.OutcomeTitle,
+		Price:     trade.Price,
+		Size:      trade.Size,
+		Timestamp: trade.Timestamp,
+	}
+	market := NewMarket{
+		ConditionID: conditionID,
+		Slug:        trade.MarketSlug,
+		EventSlug:   trade.EventSlug,
+		Title:       trade.EventTitle,
+		FirstTrade:  first,
+	}
+	go func() {
+		defer func() { <-d.sem }()
+		lookupCtx, cancel := context.WithTimeout(ctx, newMarketTimeout)
+		defer cancel()
+		if err := d.announce(lookupCtx, market); err != nil {
+			marketLog.ErrorContext(ctx, "Error announcing new market", "market", conditionID, "error", err)
+			d.forget(conditionID)
+		}
+	}()
+}
+
+// announce emits market unless another process (or an earlier run) already has
+func (d *MarketDetector) announce(ctx context.Context, market NewMarket) error {
+	key := store.PrefixMarket + market.ConditionID
+	isNew, err := d.seen.SetIfAbsent(ctx, key, 0)
+	if err != nil || !isNew {
+		return err
+	}
+
+	// Metadata is best effort: the event still goes out with what the trade carried
+	if m, err := d.gamma.GetMarketByConditionID(ctx, market.ConditionID); err != nil {
+		marketLog.WarnContext(ctx, "New market Gamma lookup failed", "market", market.ConditionID, "error", err)
+	} else {
+		market.Market = m
+	}
+
+	if err := d.emitter.Emit(ctx, events.New(events.TypeMarketNew, market.ConditionID, market)); err != nil {
+		// Unmark so the next trade retries the event
+		if delErr := d.seen.Delete(context.WithoutCancel(ctx), key); delErr != nil {
+			marketLog.ErrorContext(ctx, "Error unmarking new market", "market", market.ConditionID, "error", delErr)
+		}
+		return err
+	}
+
+	d.mu.Lock()
+	hooks := d.onNew
+	d.mu.Unlock()
+	for _, fn := range hooks {
+		fn(market)
+	}
+	return nil
+}
+
+func (d *MarketDetector) forget(conditionID string) {
+	d.mu.Lock()
+	delete(d.known, conditionID)
+	d.mu.Unlock()
+}
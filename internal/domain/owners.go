@@ -0,0 +1,148 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/FatwaArya/pm-ingest/internal/store"
+	"github.com/FatwaArya/pm-ingest/pkg/clock"
+	"github.com/FatwaArya/pm-ingest/pkg/gamma"
+	"github.com/FatwaArya/pm-ingest/pkg/pmerrors"
+)
+
+// ownerTTL is how long a resolved owner is trusted before it's looked up again
+const ownerTTL = 24 * time.Hour
+
+// Owner sources
+const (
+	OwnerSourceProfile = "profile" // Polymarket public profile
+	OwnerSourceSelf    = "self"    // No profile; the wallet is its own entity
+)
+
+// Owner maps a proxy wallet to the entity controlling it. Wallets with the
+// same OwnerID belong to the same trader.
+type Owner struct {
+	Wallet     string    `json:"wallet"`
+	OwnerID    string    `json:"ownerId"`
+	Name       string    `json:"name,omitempty"`
+	Pseudonym  string    `json:"pseudonym,omitempty"`
+	Source     string    `json:"source"`
+	ResolvedAt time.Time `json:"resolvedAt"`
+}
+
+// OwnerResolver resolves proxy wallets to their owners through the Gamma
+// public profile API and keeps the mapping, both ways, in a store
+type OwnerResolver struct {
+	state    store.Store
+	gamma    *gamma.Client
+	profiles *ProfileFetcher
+	clock    clock.Clock
+	mu       sync.Mutex // Serializes read-modify-write of owner wallet lists
+}
+
+// NewOwnerResolver creates a resolver keeping mappings in state
+func NewOwnerResolver(state store.Store, gammaClient *gamma.Client) *OwnerResolver {
+	return &OwnerResolver{state: state, gamma: gammaClient, clock: clock.Real}
+}
+
+// SetClock replaces the clock used to timestamp mappings
+func (r *OwnerResolver) SetClock(c clock.Clock) {
+	r.clock = clock.OrReal(c)
+}
+
+// SetProfileFetcher looks profiles up through f, sharing its retries and
+// cache, instead of calling the API directly
+func (r *OwnerResolver) SetProfileFetcher(f *ProfileFetcher) {
+	r.profiles = f
+}
+
+// Resolve returns the owner of wallet, from the store if it was resolved
+// within ownerTTL, otherwise from its public profile. A wallet without a
+// profile is its own owner.
+func (r *OwnerResolver) Resolve(ctx context.Context, wallet string) (Owner, error) {
+	wallet = strings.ToLower(wallet)
+	if data, ok, err := r.state.Get(ctx, store.PrefixOwner+wallet); err == nil && ok {
+		var owner Owner
+		if err := json.Unmarshal(data, &owner); err == nil {
+			return owner, nil
+		}
+	}
+
+	owner := Owner{Wallet: wallet, OwnerID: wallet, Source: OwnerSourceSelf}
+	var profile *gamma.PublicProfile
+	var err error
+	if r.profiles != nil {
+		profile, err = r.profiles.Fetch(ctx, wallet)
+	} else {
+		profile, err = r.gamma.GetPublicProfile(ctx, wallet)
+	}
+	var apiErr *pmerrors.APIError
+	switch {
+	case errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound:
+	case err != nil:
+		return Owner{}, err
+	default:
+		owner.Name = profile.Name
+		owner.Pseudonym = profile.Pseudonym
+		if len(profile.Users) > 0 && profile.Users[0].ID != "" {
+			owner.OwnerID = "user:" + profile.Users[0].ID
+			owner.Source = OwnerSourceProfile
+		}
+	}
+	return owner, r.Link(ctx, owner)
+}
+
+// Link records owner's mapping, e.g. from a source other than the profile
+// API such as an on-chain proxy factory lookup
+func (r *OwnerResolver) Link(ctx context.Context, owner Owner) error {
+	owner.Wallet = strings.ToLower(owner.Wallet)
+	if owner.ResolvedAt.IsZero() {
+		owner.ResolvedAt = r.clock.Now()
+	}
+	data, err := json.Marshal(owner)
+	if err != nil {
+		return err
+	}
+	if err := r.state.Set(ctx, store.PrefixOwner+owner.Wallet, data, ownerTTL); err != nil {
+		return err
+	}
+	return r.addWallet(ctx, owner.OwnerID, owner.Wallet)
+}
+
+// Wallets returns the wallets known to belong to ownerID
+func (r *OwnerResolver) Wallets(ctx context.Context, ownerID string) ([]string, error) {
+	data, ok, err := r.state.Get(ctx, store.PrefixWallets+ownerID)
+	if err != nil || !ok {
+		return nil, err
+	}
+	var wallets []string
+	if err := json.Unmarshal(data, &wallets); err != nil {
+		return nil, err
+	}
+	return wallets, nil
+}
+
+// addWallet adds wallet to ownerID's wallet list. Concurrent updates from
+// other replicas can drop a wallet until it is resolved again.
+func (r *OwnerResolver) addWallet(ctx context.Context, ownerID, wallet string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	wallets, err := r.Wallets(ctx, ownerID)
+	if err != nil {
+		return err
+	}
+	if slices.Contains(wallets, wallet) {
+		return nil
+	}
+	data, err := json.Marshal(append(wallets, wallet))
+	if err != nil {
+		return err
+	}
+	return r.state.Set(ctx, store.PrefixWallets+ownerID, data, 0)
+}
@@ -0,0 +1,141 @@
+package domain
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/FatwaArya/pm-ingest/pkg/rtds"
+)
+
+// OutcomePosition is a wallet's holding in one outcome of a market
+type OutcomePosition struct {
+	Shares float64 `json:"shares"`
+	Cost   float64 `json:"cost"` // Cost basis of the shares still held
+}
+
+// Position is a wallet's holdings in a market, built from its trades
+type Position struct {
+	Wallet      string                   `json:"wallet"`
+	ConditionID string                   `json:"conditionId"`
+	Slug        string                   `json:"slug,omitempty"`
+	Outcomes    map[int]*OutcomePosition `json:"outcomes"`     // By outcome index
+	OutcomeName map[int]string           `json:"outcomeNames"` // By outcome index, as seen on trades
+	RealizedPnl float64                  `json:"realizedPnl"`  // From shares sold before resolution
+	Trades      int                      `json:"trades"`
+}
+
+func (p *Position) add(trade *rtds.ActivityTradePayload) {
+	pos := p.Outcomes[trade.OutcomeIndex]
+	if pos == nil {
+		pos = &OutcomePosition{}
+		p.Outcomes[trade.OutcomeIndex] = pos
+	}
+	if trade.OutcomeTitle != "" {
+		p.OutcomeName[trade.OutcomeIndex] = trade.OutcomeTitle
+	}
+	p.Trades++
+
+	switch trade.Side {
+	case rtds.SideBuy:
+		pos.Shares += trade.Size
+		pos.Cost += trade.Size * trade.Price
+	case rtds.SideSell:
+		// Shares bought before tracking started have no known cost; only
+		// the tracked part of the position is reduced
+		sold := min(trade.Size, pos.Shares)
+		if sold <= 0 {
+			return
+		}
+		avgCost := pos.Cost / pos.Shares
+		p.RealizedPnl += sold * (trade.Price - avgCost)
+		pos.Cost -= sold * avgCost
+		pos.Shares -= sold
+	}
+}
+
+// PositionBook keeps the positions of tracked wallets from the live trade
+// stream. A wallet is tracked from its first trade of at least minNotional
+// (or from Track); earlier trades aren't known, so positions cover only
+// what was traded since.
+type PositionBook struct {
+	minNotional float64
+
+	mu      sync.Mutex
+	tracked map[string]bool
+	markets map[string]map[string]*Position // Condition ID -> wallet -> position
+}
+
+// NewPositionBook creates a book that starts tracking a wallet at its first
+// trade of at least minNotional USD
+func NewPositionBook(minNotional float64) *PositionBook {
+	return &PositionBook{
+		minNotional: minNotional,
+		tracked:     make(map[string]bool),
+		markets:     make(map[string]map[string]*Position),
+	}
+}
+
+// Track starts tracking wallet regardless of trade size
+func (b *PositionBook) Track(wallet string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tracked[strings.ToLower(wallet)] = true
+}
+
+// Record applies a trade to the wallet's position if the wallet is tracked
+func (b *PositionBook) Record(trade *rtds.ActivityTradePayload) {
+	if trade.ProxyWalletAddress == "" || trade.ConditionID == "" {
+		return
+	}
+	wallet := strings.ToLower(trade.ProxyWalletAddress)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.tracked[wallet] {
+		if trade.Size*trade.Price < b.minNotional {
+			return
+		}
+		b.tracked[wallet] = true
+	}
+
+	positions := b.markets[trade.ConditionID]
+	if positions == nil {
+		positions = make(map[string]*Position)
+		b.markets[trade.ConditionID] = positions
+	}
+	pos := positions[wallet]
+	if pos == nil {
+		pos = &Position{
+			Wallet:      wallet,
+			ConditionID: trade.ConditionID,
+			Slug:        trade.MarketSlug,
+			Outcomes:    make(map[int]*OutcomePosition),
+			OutcomeName: make(map[int]string),
+		}
+		positions[wallet] = pos
+	}
+	pos.add(trade)
+}
+
+// Markets returns the condition IDs with open tracked positions
+func (b *PositionBook) Markets() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	ids := make([]string, 0, len(b.markets))
+	for id := range b.markets {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// Close removes a market from the book and returns its positions
+func (b *PositionBook) Close(conditionID string) []*Position {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	positions := make([]*Position, 0, len(b.markets[conditionID]))
+	for _, pos := range b.markets[conditionID] {
+		positions = append(positions, pos)
+	}
+	delete(b.markets, conditionID)
+	return positions
+}
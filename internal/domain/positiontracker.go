@@ -0,0 +1,277 @@
+package domain
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	internalqdb "github.com/FatwaArya/pm-ingest/internal"
+	"github.com/FatwaArya/pm-ingest/pkg/clock"
+	"github.com/FatwaArya/pm-ingest/pkg/dataapi"
+	"github.com/FatwaArya/pm-ingest/pkg/rtds"
+)
+
+// positionPollInterval is how often the tracker looks for wallets due a
+// refresh
+const positionPollInterval = time.Second
+
+// PositionTracker keeps the open exposure, cost basis and mark-to-market
+// PnL of tracked wallets current. A trade of a tracked wallet schedules a
+// refresh of its positions from the Data API, at most once per
+// minInterval so a burst of fills costs one lookup, and every refresh is
+// written to QuestDB as a snapshot of the wallet's positions. A position
+// gone since the last refresh (sold or redeemed) is written with size 0,
+// so the latest row of every asset is current; positions that closed while
+// the process was down aren't noticed.
+type PositionTracker struct {
+	exposure    exposureSource
+	minInterval time.Duration
+	clock       clock.Clock
+	watchlist   *WalletWatchlist
+	onExposure  []func(ctx context.Context, e Exposure)
+
+	mu        sync.Mutex
+	tracked   map[string]bool
+	refreshed map[string]time.Time // Last refresh per wallet
+	due       map[string]time.Time // Scheduled refreshes per wallet
+	untracked []string             // Wallets to forget the written rows of
+
+	written map[string]map[string]*internalqdb.WalletPosition // Last rows written per wallet, by asset; Run goroutine only
+}
+
+// exposureSource refreshes a wallet's positions; an *ExposureTracker
+// outside tests
+type exposureSource interface {
+	Refresh(ctx context.Context, wallet string) (Exposure, error)
+}
+
+// positionSink stores refreshed positions; an *internalqdb.PositionWriter
+// outside tests
+type positionSink interface {
+	Write(ctx context.Context, p *internalqdb.WalletPosition, ts time.Time) error
+	Flush(ctx context.Context) error
+}
+
+// NewPositionTracker creates a tracker refreshing wallets through exposure,
+// each at most once per minInterval
+func NewPositionTracker(exposure *ExposureTracker, minInterval time.Duration) *PositionTracker {
+	return &PositionTracker{
+		exposure:    exposure,
+		minInterval: minInterval,
+		clock:       clock.Real,
+		tracked:     make(map[string]bool),
+		refreshed:   make(map[string]time.Time),
+		due:         make(map[string]time.Time),
+		written:     make(map[string]map[string]*internalqdb.WalletPosition),
+	}
+}
+
+// SetClock replaces the clock refreshes are scheduled by
+func (t *PositionTracker) SetClock(c clock.Clock) {
+	t.clock = clock.OrReal(c)
+}
+
+// SetWatchlist also tracks the wallets on w
+func (t *PositionTracker) SetWatchlist(w *WalletWatchlist) {
+	t.watchlist = w
+}
+
+// OnExposure registers fn to be called with every refreshed exposure.
+// Hooks run on the Run goroutine, in order.
+func (t *PositionTracker) OnExposure(fn func(ctx context.Context, e Exposure)) {
+	t.onExposure = append(t.onExposure, fn)
+}
+
+// Track starts tracking wallet and schedules its first refresh
+func (t *PositionTracker) Track(wallet string) {
+	wallet = strings.ToLower(wallet)
+	if wallet == "" {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.tracked[wallet] {
+		t.tracked[wallet] = true
+		t.schedule(wallet)
+	}
+}
+
+// Untrack stops tracking wallet and reports whether it was tracked
+func (t *PositionTracker) Untrack(wallet string) bool {
+	wallet = strings.ToLower(wallet)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	ok := t.tracked[wallet]
+	delete(t.tracked, wallet)
+	delete(t.refreshed, wallet)
+	delete(t.due, wallet)
+	if ok {
+		t.untracked = append(t.untracked, wallet)
+	}
+	return ok
+}
+
+// Tracked returns the number of tracked wallets
+func (t *PositionTracker) Tracked() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.tracked)
+}
+
+// Record schedules a refresh of the trade's wallet if it is tracked
+func (t *PositionTracker) Record(trade *rtds.ActivityTradePayload) {
+	wallet := strings.ToLower(trade.ProxyWalletAddress)
+	if wallet == "" {
+		return
+	}
+	watched := t.watchlist != nil && t.watchlist.Contains(wallet)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.tracked[wallet] && !watched {
+		return
+	}
+	t.schedule(wallet)
+}
+
+// schedule sets the wallet's refresh for now, or for minInterval after its
+// last one; called with mu held
+func (t *PositionTracker) schedule(wallet string) {
+	if _, ok := t.due[wallet]; ok {
+		return
+	}
+	at := t.clock.Now()
+	if last, ok := t.refreshed[wallet]; ok {
+		at = later(at, last.Add(t.minInterval))
+	}
+	t.due[wallet] = at
+}
+
+func later(a, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+	return a
+}
+
+// Run refreshes the wallets as they come due and writes their positions
+// to writer, until ctx is cancelled
+func (t *PositionTracker) Run(ctx context.Context, writer *internalqdb.PositionWriter) error {
+	var sink positionSink
+	if writer != nil {
+		sink = writer
+	}
+	ticker := t.clock.NewTicker(positionPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C():
+			t.refreshDue(ctx, sink)
+		}
+	}
+}
+
+// refreshDue refreshes the wallets due by now
+func (t *PositionTracker) refreshDue(ctx context.Context, writer positionSink) {
+	now := t.clock.Now()
+	t.mu.Lock()
+	for _, wallet := range t.untracked {
+		delete(t.written, wallet)
+	}
+	t.untracked = nil
+	var wallets []string
+	for wallet, at := range t.due {
+		if !at.After(now) {
+			wallets = append(wallets, wallet)
+			delete(t.due, wallet)
+			t.refreshed[wallet] = now
+		}
+	}
+	t.mu.Unlock()
+	if len(wallets) == 0 {
+		return
+	}
+
+	for _, wallet := range wallets {
+		if ctx.Err() != nil {
+			return
+		}
+		e, err := t.exposure.Refresh(ctx, wallet)
+		if err != nil {
+			writeErrLog.Printf("Error refreshing positions of %s: %v", wallet, err)
+			continue
+		}
+		if writer != nil {
+			t.writePositions(ctx, writer, wallet, e)
+		}
+		for _, fn := range t.onExposure {
+			fn(ctx, e)
+		}
+	}
+	if writer != nil {
+		if err := writer.Flush(ctx); err != nil {
+			writeErrLog.Printf("Error flushing positions to QuestDB: %v", err)
+		}
+	}
+}
+
+// writePositions writes the wallet's positions, and a size 0 row for each
+// one written last time that is gone. If a write fails the previous rows
+// are kept, so the next refresh diffs against them again.
+func (t *PositionTracker) writePositions(ctx context.Context, writer positionSink, wallet string, e Exposure) {
+	previous := t.written[wallet]
+	current := make(map[string]*internalqdb.WalletPosition, len(e.Positions))
+	rows := make([]*internalqdb.WalletPosition, 0, len(e.Positions)+len(previous))
+	for _, p := range e.Positions {
+		row := walletPosition(wallet, p)
+		current[row.Asset] = row
+		rows = append(rows, row)
+	}
+	for asset, row := range previous {
+		if _, ok := current[asset]; !ok {
+			rows = append(rows, closedPosition(row))
+		}
+	}
+
+	for _, row := range rows {
+		if err := writer.Write(ctx, row, e.FetchedAt); err != nil {
+			writeErrLog.Printf("Error writing positions of %s to QuestDB: %v", wallet, err)
+			return
+		}
+	}
+	if len(current) == 0 {
+		delete(t.written, wallet)
+	} else {
+		t.written[wallet] = current
+	}
+}
+
+// closedPosition is the row of a position that is gone: what it was, with
+// nothing left held
+func closedPosition(last *internalqdb.WalletPosition) *internalqdb.WalletPosition {
+	row := *last
+	row.Size, row.Cost, row.Value, row.UnrealizedPnl = 0, 0, 0, 0
+	row.Redeemable = false
+	return &row
+}
+
+// walletPosition converts a Data API position to its QuestDB row
+func walletPosition(wallet string, p dataapi.Position) *internalqdb.WalletPosition {
+	return &internalqdb.WalletPosition{
+		Wallet:        wallet,
+		ConditionID:   p.ConditionID,
+		Asset:         p.Asset,
+		Outcome:       p.Outcome,
+		Slug:          p.Slug,
+		Size:          p.Size,
+		AvgPrice:      p.AvgPrice,
+		Cost:          p.InitialValue,
+		CurPrice:      p.CurPrice,
+		Value:         p.CurrentValue,
+		UnrealizedPnl: p.CurrentValue - p.InitialValue,
+		RealizedPnl:   p.RealizedPnl,
+		Redeemable:    p.Redeemable,
+	}
+}
@@ -0,0 +1,118 @@
+package domain
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	internalqdb "github.com/FatwaArya/pm-ingest/internal"
+	"github.com/FatwaArya/pm-ingest/pkg/clock"
+	"github.com/FatwaArya/pm-ingest/pkg/dataapi"
+	"github.com/FatwaArya/pm-ingest/pkg/rtds"
+)
+
+// fakePositions serves each wallet's positions from a map
+type fakePositions struct {
+	positions map[string][]dataapi.Position
+	refreshes map[string]int
+}
+
+func (f *fakePositions) Refresh(_ context.Context, wallet string) (Exposure, error) {
+	f.refreshes[wallet]++
+	return NewExposure(wallet, f.positions[wallet], time.Unix(0, 0)), nil
+}
+
+// positionRows collects the rows written
+type positionRows []internalqdb.WalletPosition
+
+func (r *positionRows) Write(_ context.Context, p *internalqdb.WalletPosition, _ time.Time) error {
+	*r = append(*r, *p)
+	return nil
+}
+
+func (r *positionRows) Flush(context.Context) error { return nil }
+
+func newTestTracker(c clock.Clock) (*PositionTracker, *fakePositions) {
+	source := &fakePositions{positions: make(map[string][]dataapi.Position), refreshes: make(map[string]int)}
+	t := NewPositionTracker(nil, time.Minute)
+	t.exposure = source
+	t.SetClock(c)
+	return t, source
+}
+
+func TestPositionTrackerSchedule(t *testing.T) {
+	c := clock.NewFake(time.Unix(1_700_000_000, 0))
+	tracker, source := newTestTracker(c)
+	var rows positionRows
+
+	tracker.Track("0xAbC")
+	tracker.refreshDue(t.Context(), &rows)
+	if n := source.refreshes["0xabc"]; n != 1 {
+		t.Fatalf("tracked wallet refreshed %d times, want 1", n)
+	}
+
+	// A burst of fills schedules one refresh, a minute after the last
+	trade := &rtds.ActivityTradePayload{ProxyWalletAddress: "0xABC"}
+	tracker.Record(trade)
+	tracker.Record(trade)
+	c.Advance(30 * time.Second)
+	tracker.refreshDue(t.Context(), &rows)
+	if n := source.refreshes["0xabc"]; n != 1 {
+		t.Fatalf("refreshed %d times before minInterval passed, want 1", n)
+	}
+	c.Advance(30 * time.Second)
+	tracker.refreshDue(t.Context(), &rows)
+	tracker.refreshDue(t.Context(), &rows)
+	if n := source.refreshes["0xabc"]; n != 2 {
+		t.Fatalf("refreshed %d times after minInterval, want 2", n)
+	}
+
+	// Trades of other wallets are ignored
+	tracker.Record(&rtds.ActivityTradePayload{ProxyWalletAddress: "0xdef"})
+	tracker.refreshDue(t.Context(), &rows)
+	if n := source.refreshes["0xdef"]; n != 0 {
+		t.Errorf("untracked wallet refreshed %d times", n)
+	}
+}
+
+func TestPositionTrackerWritesClosedPositions(t *testing.T) {
+	c := clock.NewFake(time.Unix(1_700_000_000, 0))
+	tracker, source := newTestTracker(c)
+	var rows positionRows
+	refresh := func(positions ...dataapi.Position) positionRows {
+		source.positions["0xabc"] = positions
+		rows = nil
+		tracker.Track("0xabc")
+		tracker.Record(&rtds.ActivityTradePayload{ProxyWalletAddress: "0xabc"})
+		c.Advance(time.Minute)
+		tracker.refreshDue(t.Context(), &rows)
+		return rows
+	}
+	yes := dataapi.Position{Asset: "yes", ConditionID: "0xc1", Size: 10, InitialValue: 4, CurrentValue: 6}
+	no := dataapi.Position{Asset: "no", ConditionID: "0xc2", Size: 5, InitialValue: 2, CurrentValue: 1}
+
+	if got := refresh(yes, no); len(got) != 2 {
+		t.Fatalf("wrote %d rows, want 2", len(got))
+	}
+
+	// "no" was sold: it's written with nothing held
+	got := refresh(yes)
+	if len(got) != 2 {
+		t.Fatalf("wrote %+v, want yes and a closed no", got)
+	}
+	closed := got[1]
+	if closed.Asset != "no" || closed.ConditionID != "0xc2" || closed.Size != 0 || closed.Value != 0 || closed.UnrealizedPnl != 0 {
+		t.Errorf("closed row = %+v", closed)
+	}
+
+	// Once written closed it isn't written again
+	if got := refresh(yes); len(got) != 1 || got[0].Asset != "yes" {
+		t.Errorf("wrote %+v, want yes only", got)
+	}
+	if got := refresh(); len(got) != 1 || got[0].Asset != "yes" || got[0].Size != 0 {
+		t.Errorf("wrote %+v, want a closed yes", got)
+	}
+	if got := refresh(); len(got) != 0 {
+		t.Errorf("wrote %+v with nothing open before or now", got)
+	}
+}
@@ -0,0 +1,235 @@
+package domain
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	internalqdb "github.com/FatwaArya/pm-ingest/internal"
+	"github.com/FatwaArya/pm-ingest/pkg/clock"
+	"github.com/FatwaArya/pm-ingest/pkg/rtds"
+)
+
+// priceBarInterval is the resolution of the rolling price series
+const priceBarInterval = time.Minute
+
+// PriceSource is the read side of the price service, for components that
+// need current prices (impact measurement, mark-to-market)
+type PriceSource interface {
+	// LastPrice returns the last traded price of an asset and when it traded
+	LastPrice(asset string) (float64, time.Time, bool)
+	// VWAP returns the volume-weighted average price over the last window
+	VWAP(asset string, window time.Duration) (float64, bool)
+}
+
+// PriceBar is an asset's trading within one bar interval
+type PriceBar struct {
+	Start    time.Time `json:"start"`
+	Open     float64   `json:"open"`
+	High     float64   `json:"high"`
+	Low      float64   `json:"low"`
+	Close    float64   `json:"close"`
+	Volume   float64   `json:"volume"`   // Shares
+	Notional float64   `json:"notional"` // USD
+	VWAP     float64   `json:"vwap"`
+	Trades   int64     `json:"trades"`
+}
+
+func (b *PriceBar) add(price, size float64) {
+	if b.Trades == 0 {
+		b.Open, b.High, b.Low = price, price, price
+	}
+	b.High = max(b.High, price)
+	b.Low = min(b.Low, price)
+	b.Close = price
+	b.Volume += size
+	b.Notional += price * size
+	if b.Volume > 0 {
+		b.VWAP = b.Notional / b.Volume
+	}
+	b.Trades++
+}
+
+type assetSeries struct {
+	market    string
+	bars      []PriceBar // Oldest first, only minutes with trades
+	lastPrice float64
+	lastTrade time.Time
+	flushed   time.Time // Start of the newest bar already written
+}
+
+// PriceService keeps a rolling series of one-minute bars per asset (outcome
+// token) from the trade stream, so prices are derived once and shared.
+// Bars older than window are dropped; Run persists them to QuestDB.
+type PriceService struct {
+	mu        sync.RWMutex
+	clock     clock.Clock
+	window    time.Duration
+	assets    map[string]*assetSeries
+	lastSweep time.Time
+}
+
+var _ PriceSource = (*PriceService)(nil)
+
+// NewPriceService creates a service keeping window of bars per asset
+func NewPriceService(window time.Duration) *PriceService {
+	return &PriceService{
+		clock:     clock.Real,
+		window:    window,
+		assets:    make(map[string]*assetSeries),
+		lastSweep: time.Now(),
+	}
+}
+
+// SetClock replaces the clock used for eviction and the flush ticker
+func (p *PriceService) SetClock(c clock.Clock) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.clock = clock.OrReal(c)
+	p.lastSweep = p.clock.Now()
+}
+
+// Record adds a trade to its asset's series
+func (p *PriceService) Record(trade *rtds.ActivityTradePayload) {
+	if trade.Asset == "" || trade.Price <= 0 || trade.Size <= 0 {
+		return
+	}
+	ts := time.Unix(trade.Timestamp, 0)
+	start := ts.Truncate(priceBarInterval)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.clock.Now()
+	p.sweep(now)
+	if now.Sub(start) > p.window {
+		return // Replayed trade outside the window
+	}
+
+	s, ok := p.assets[trade.Asset]
+	if !ok {
+		s = &assetSeries{market: trade.ConditionID}
+		p.assets[trade.Asset] = s
+	}
+	i, found := slices.BinarySearchFunc(s.bars, start, func(b PriceBar, t time.Time) int {
+		return b.Start.Compare(t)
+	})
+	if !found {
+		s.bars = slices.Insert(s.bars, i, PriceBar{Start: start})
+	}
+	s.bars[i].add(trade.Price, trade.Size)
+	if !ts.Before(s.lastTrade) {
+		s.lastPrice = trade.Price
+		s.lastTrade = ts
+	}
+}
+
+// LastPrice returns the last traded price of an asset and when it traded
+func (p *PriceService) LastPrice(asset string) (float64, time.Time, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	s, ok := p.assets[asset]
+	if !ok {
+		return 0, time.Time{}, false
+	}
+	return s.lastPrice, s.lastTrade, true
+}
+
+// VWAP returns the volume-weighted average price over the last window
+func (p *PriceService) VWAP(asset string, window time.Duration) (float64, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	s, ok := p.assets[asset]
+	if !ok {
+		return 0, false
+	}
+	cutoff := p.clock.Now().Add(-window).Truncate(priceBarInterval)
+	var volume, notional float64
+	for i := len(s.bars) - 1; i >= 0 && !s.bars[i].Start.Before(cutoff); i-- {
+		volume += s.bars[i].Volume
+		notional += s.bars[i].Notional
+	}
+	if volume == 0 {
+		return 0, false
+	}
+	return notional / volume, true
+}
+
+// Series returns an asset's bars starting at or after since, oldest first
+func (p *PriceService) Series(asset string, since time.Time) []PriceBar {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	s, ok := p.assets[asset]
+	if !ok {
+		return nil
+	}
+	i, _ := slices.BinarySearchFunc(s.bars, since, func(b PriceBar, t time.Time) int {
+		return b.Start.Compare(t)
+	})
+	return slices.Clone(s.bars[i:])
+}
+
+// Run writes completed bars to writer every interval until ctx is cancelled
+func (p *PriceService) Run(ctx context.Context, writer *internalqdb.PriceBarWriter, interval time.Duration) error {
+	ticker := p.clock.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C():
+			if err := p.write(ctx, writer); err != nil {
+				writeErrLog.Printf("Error writing price bars to QuestDB: %v", err)
+			}
+		}
+	}
+}
+
+// write sends bars that closed since the last write. Late trades landing in
+// a bar already written aren't rewritten.
+func (p *PriceService) write(ctx context.Context, writer *internalqdb.PriceBarWriter) error {
+	p.mu.Lock()
+	current := p.clock.Now().Truncate(priceBarInterval)
+	var rows []internalqdb.PriceBar
+	for asset, s := range p.assets {
+		for _, b := range s.bars {
+			if !b.Start.After(s.flushed) || !b.Start.Before(current) {
+				continue
+			}
+			rows = append(rows, internalqdb.PriceBar{
+				Asset: asset, Market: s.market, Start: b.Start,
+				Open: b.Open, High: b.High, Low: b.Low, Close: b.Close,
+				Volume: b.Volume, Notional: b.Notional, VWAP: b.VWAP, Trades: b.Trades,
+			})
+			s.flushed = b.Start
+		}
+	}
+	p.mu.Unlock()
+
+	for i := range rows {
+		if err := writer.Write(ctx, &rows[i]); err != nil {
+			return err
+		}
+	}
+	return writer.Flush(ctx)
+}
+
+// sweep drops bars older than the window and assets left without bars;
+// called with mu held
+func (p *PriceService) sweep(now time.Time) {
+	if now.Sub(p.lastSweep) < flowSweepInterval {
+		return
+	}
+	p.lastSweep = now
+	cutoff := now.Add(-p.window)
+	for asset, s := range p.assets {
+		drop := 0
+		for drop < len(s.bars) && s.bars[drop].Start.Before(cutoff) {
+			drop++
+		}
+		s.bars = s.bars[drop:]
+		if len(s.bars) == 0 {
+			delete(p.assets, asset)
+		}
+	}
+}
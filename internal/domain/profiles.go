@@ -0,0 +1,80 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/FatwaArya/pm-ingest/internal/store"
+	"github.com/FatwaArya/pm-ingest/pkg/gamma"
+	"github.com/FatwaArya/pm-ingest/pkg/pmerrors"
+)
+
+const (
+	profileFetchAttempts = 3
+	profileFetchBackoff  = 500 * time.Millisecond
+)
+
+// ProfileFetcher fetches public profiles from the Gamma API, retrying
+// transient failures, and caches them in a store
+type ProfileFetcher struct {
+	gamma *gamma.Client
+	state store.Store
+	ttl   time.Duration
+}
+
+// NewProfileFetcher creates a fetcher caching profiles in state for ttl
+func NewProfileFetcher(state store.Store, gammaClient *gamma.Client, ttl time.Duration) *ProfileFetcher {
+	return &ProfileFetcher{gamma: gammaClient, state: state, ttl: ttl}
+}
+
+// Fetch returns the public profile of wallet. A wallet without a profile
+// gets an empty one (only ProxyWallet set), cached like the others so it
+// isn't looked up on every trade.
+func (f *ProfileFetcher) Fetch(ctx context.Context, wallet string) (*gamma.PublicProfile, error) {
+	wallet = strings.ToLower(wallet)
+	if data, ok, err := f.state.Get(ctx, store.PrefixProfile+wallet); err == nil && ok {
+		var profile gamma.PublicProfile
+		if err := json.Unmarshal(data, &profile); err == nil {
+			return &profile, nil
+		}
+	}
+
+	profile, err := f.fetch(ctx, wallet)
+	var apiErr *pmerrors.APIError
+	switch {
+	case errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound:
+		profile = &gamma.PublicProfile{ProxyWallet: wallet}
+	case err != nil:
+		return nil, err
+	}
+
+	// Caching is best effort; the profile is good either way
+	if data, err := json.Marshal(profile); err == nil {
+		if err := f.state.Set(ctx, store.PrefixProfile+wallet, data, f.ttl); err != nil {
+			writeErrLog.Printf("Error caching profile of %s: %v", wallet, err)
+		}
+	}
+	return profile, nil
+}
+
+// fetch calls the API, retrying rate limits, 5xx responses and network
+// errors with exponential backoff
+func (f *ProfileFetcher) fetch(ctx context.Context, wallet string) (*gamma.PublicProfile, error) {
+	backoff := profileFetchBackoff
+	for attempt := 1; ; attempt++ {
+		profile, err := f.gamma.GetPublicProfile(ctx, wallet)
+		if err == nil || attempt == profileFetchAttempts || !pmerrors.IsRetryable(err) {
+			return profile, err
+		}
+		select {
+		case <-time.After(backoff):
+			backoff *= 2
+		case <-ctx.Done():
+			return nil, err
+		}
+	}
+}
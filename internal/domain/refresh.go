@@ -0,0 +1,250 @@
+package domain
+
+import (
+	"context"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/FatwaArya/pm-ingest/internal/events"
+	"github.com/FatwaArya/pm-ingest/internal/logging"
+	"github.com/FatwaArya/pm-ingest/pkg/clock"
+	"github.com/FatwaArya/pm-ingest/pkg/dataapi"
+)
+
+const (
+	// refreshConcurrency bounds concurrent recalculations per refresh run,
+	// keeping the data API request rate reasonable
+	refreshConcurrency = 4
+	// refreshRetention drops wallets that haven't been tracked for a week
+	refreshRetention = 7 * 24 * time.Hour
+)
+
+// ScoredConfidence is a wallet's latest confidence and how fresh it is.
+// Freshness decays exponentially with age, reaching 1/e at the stale TTL.
+type ScoredConfidence struct {
+	Wallet     string           `json:"wallet"`
+	Prediction PredictionResult `json:"prediction"`
+	ComputedAt time.Time        `json:"computedAt"`
+	Freshness  float64          `json:"freshness"`
+	Stale      bool             `json:"stale"`
+}
+
+type refreshEntry struct {
+	score     *ScoredConfidence // Nil until first computed
+	tracked   time.Time         // Last time the wallet was (re)tracked
+	computing bool
+}
+
+// ConfidenceRefresher recomputes confidence for tracked wallets (discovered
+// traders, settled positions, ...) on a cadence instead of only when they
+// trade again, and publishes each result as a wallet.confidence event
+type ConfidenceRefresher struct {
+	apiClient  *dataapi.Client
+	scorer     *TradeScorer
+	emitter    events.Emitter
+	interval   time.Duration
+	staleAfter time.Duration
+	clock      clock.Clock
+
+	mu      sync.Mutex
+	wallets map[string]*refreshEntry
+	onScore []func(ctx context.Context, score ScoredConfidence)
+}
+
+// NewConfidenceRefresher creates a refresher recomputing each wallet every
+// interval and marking scores stale after staleAfter
+func NewConfidenceRefresher(apiClient *dataapi.Client, emitter events.Emitter, interval, staleAfter time.Duration) *ConfidenceRefresher {
+	return &ConfidenceRefresher{
+		apiClient:  apiClient,
+		emitter:    emitter,
+		interval:   interval,
+		staleAfter: staleAfter,
+		clock:      clock.Real,
+		wallets:    make(map[string]*refreshEntry),
+	}
+}
+
+// SetClock replaces the clock driving the schedule and score ages
+func (r *ConfidenceRefresher) SetClock(c clock.Clock) {
+	r.clock = clock.OrReal(c)
+}
+
+// SetTradeScorer adds trade-level scores to refreshed results
+func (r *ConfidenceRefresher) SetTradeScorer(s *TradeScorer) {
+	r.scorer = s
+}
+
+// OnScore registers fn to be called with every computed score, whether
+// refreshed here or recorded through Update
+func (r *ConfidenceRefresher) OnScore(fn func(ctx context.Context, score ScoredConfidence)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.onScore = append(r.onScore, fn)
+}
+
+// Track adds wallet to the refresh schedule, or keeps it there
+func (r *ConfidenceRefresher) Track(wallet string) {
+	wallet = strings.ToLower(wallet)
+	if wallet == "" {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e, ok := r.wallets[wallet]
+	if !ok {
+		e = &refreshEntry{}
+		r.wallets[wallet] = e
+	}
+	e.tracked = r.clock.Now()
+}
+
+// Update records a confidence computed elsewhere (e.g. on a high-value
+// trade), which resets the wallet's schedule
+func (r *ConfidenceRefresher) Update(ctx context.Context, wallet string, prediction PredictionResult) {
+	r.Track(wallet)
+	score := r.store(strings.ToLower(wallet), prediction)
+	r.scored(ctx, score)
+}
+
+// Score returns the latest confidence of a wallet with its current freshness
+func (r *ConfidenceRefresher) Score(wallet string) (ScoredConfidence, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e, ok := r.wallets[strings.ToLower(wallet)]
+	if !ok || e.score == nil {
+		return ScoredConfidence{}, false
+	}
+	return r.aged(*e.score), true
+}
+
+func (r *ConfidenceRefresher) aged(s ScoredConfidence) ScoredConfidence {
+	age := r.clock.Since(s.ComputedAt)
+	if r.staleAfter > 0 {
+		s.Freshness = math.Exp(-float64(age) / float64(r.staleAfter))
+		s.Stale = age > r.staleAfter
+	} else {
+		s.Freshness = 1
+	}
+	return s
+}
+
+// Run refreshes due wallets every interval until ctx is cancelled
+func (r *ConfidenceRefresher) Run(ctx context.Context) error {
+	ticker := r.clock.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C():
+			r.Refresh(ctx)
+		}
+	}
+}
+
+// Refresh recomputes every wallet whose score is older than the interval
+// and waits for the recalculations to finish
+func (r *ConfidenceRefresher) Refresh(ctx context.Context) {
+	due := r.due()
+	if len(due) == 0 {
+		return
+	}
+	confidenceLog.InfoContext(ctx, "Refreshing confidence", "wallets", len(due))
+
+	sem := make(chan struct{}, refreshConcurrency)
+	var wg sync.WaitGroup
+	for _, wallet := range due {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			r.release(due)
+			wg.Wait()
+			return
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			r.refresh(ctx, wallet)
+		}()
+	}
+	wg.Wait()
+}
+
+// due marks and returns the wallets to refresh, dropping ones no longer tracked
+func (r *ConfidenceRefresher) due() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.clock.Now()
+	var due []string
+	for wallet, e := range r.wallets {
+		if now.Sub(e.tracked) > refreshRetention {
+			delete(r.wallets, wallet)
+			continue
+		}
+		if e.computing || (e.score != nil && now.Sub(e.score.ComputedAt) < r.interval) {
+			continue
+		}
+		e.computing = true
+		due = append(due, wallet)
+	}
+	return due
+}
+
+// release clears the computing mark of wallets that weren't refreshed
+func (r *ConfidenceRefresher) release(wallets []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, wallet := range wallets {
+		if e, ok := r.wallets[wallet]; ok {
+			e.computing = false
+		}
+	}
+}
+
+func (r *ConfidenceRefresher) refresh(ctx context.Context, wallet string) {
+	ctx, cancel := context.WithTimeout(ctx, confidenceTimeout)
+	defer cancel()
+
+	prediction, err := CalculateConfidenceForUser(ctx, r.apiClient, wallet, 1000)
+	if err != nil {
+		r.release([]string{wallet})
+		confidenceLog.ErrorContext(ctx, "Error refreshing confidence", logging.KeyWallet, wallet, "error", err)
+		return
+	}
+	r.scorer.Score(ctx, wallet, ConfidenceQuery{}, &prediction)
+	score := r.store(wallet, prediction)
+	r.scored(ctx, score)
+	if err := r.emitter.Emit(ctx, events.New(events.TypeWalletConfidence, wallet, score)); err != nil {
+		confidenceLog.ErrorContext(ctx, "Error emitting confidence", logging.KeyWallet, wallet, "error", err)
+	}
+}
+
+// scored runs the OnScore hooks
+func (r *ConfidenceRefresher) scored(ctx context.Context, score ScoredConfidence) {
+	r.mu.Lock()
+	hooks := r.onScore
+	r.mu.Unlock()
+	for _, fn := range hooks {
+		fn(ctx, score)
+	}
+}
+
+// store saves a freshly computed prediction and returns its score
+func (r *ConfidenceRefresher) store(wallet string, prediction PredictionResult) ScoredConfidence {
+	score := ScoredConfidence{
+		Wallet:     wallet,
+		Prediction: prediction,
+		ComputedAt: r.clock.Now(),
+		Freshness:  1,
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if e, ok := r.wallets[wallet]; ok {
+		e.score = &score
+		e.computing = false
+	}
+	return score
+}
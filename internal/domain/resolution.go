@@ -0,0 +1,184 @@
+package domain
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/FatwaArya/pm-ingest/internal/events"
+	"github.com/FatwaArya/pm-ingest/internal/logging"
+	"github.com/FatwaArya/pm-ingest/pkg/clock"
+	"github.com/FatwaArya/pm-ingest/pkg/gamma"
+)
+
+const (
+	// resolutionBatchSize is how many condition IDs are looked up per Gamma request
+	resolutionBatchSize = 50
+	// resolutionTimeout bounds one poll of all open markets
+	resolutionTimeout = 2 * time.Minute
+)
+
+var resolutionLog = logging.For("resolution")
+
+// lookupResolved looks ids up in Gamma, resolutionBatchSize at a time, and
+// calls fn with each resolved market and its winning outcome
+func lookupResolved(ctx context.Context, client *gamma.Client, ids []string, fn func(market *gamma.Market, winner int)) error {
+	for start := 0; start < len(ids); start += resolutionBatchSize {
+		batch := ids[start:min(start+resolutionBatchSize, len(ids))]
+		markets, err := client.GetMarkets(ctx, gamma.MarketsQueryParams{ConditionIDs: batch, Limit: len(batch)})
+		if err != nil {
+			return err
+		}
+		for i := range markets {
+			if winner, ok := markets[i].WinningOutcome(); ok {
+				fn(&markets[i], winner)
+			}
+		}
+	}
+	return nil
+}
+
+// Settlement is a wallet's position in a market reconciled against the
+// winning outcome
+type Settlement struct {
+	Wallet         string  `json:"wallet"`
+	ConditionID    string  `json:"conditionId"`
+	Slug           string  `json:"slug,omitempty"`
+	WinningIndex   int     `json:"winningIndex"`
+	WinningOutcome string  `json:"winningOutcome,omitempty"`
+	WinningShares  float64 `json:"winningShares"`
+	LosingShares   float64 `json:"losingShares"`
+	CostBasis      float64 `json:"costBasis"`   // Cost of the shares held at resolution
+	Payout         float64 `json:"payout"`      // $1 per winning share
+	RealizedPnl    float64 `json:"realizedPnl"` // Sales before resolution plus payout minus cost basis
+	Won            bool    `json:"won"`
+	ResolvedAt     int64   `json:"resolvedAt"` // Unix seconds when the resolution was detected
+}
+
+// settle reconciles pos against its market's resolution
+func settle(pos *Position, r MarketResolution) Settlement {
+	winner := r.WinningIndex
+	s := Settlement{
+		Wallet:         pos.Wallet,
+		ConditionID:    pos.ConditionID,
+		Slug:           pos.Slug,
+		WinningIndex:   winner,
+		WinningOutcome: r.WinningOutcome,
+		RealizedPnl:    pos.RealizedPnl,
+		ResolvedAt:     r.ResolvedAt,
+	}
+	if s.Slug == "" {
+		s.Slug = r.Slug
+	}
+	if s.WinningOutcome == "" {
+		s.WinningOutcome = pos.OutcomeName[winner]
+	}
+
+	for idx, o := range pos.Outcomes {
+		s.CostBasis += o.Cost
+		if idx == winner {
+			s.WinningShares += o.Shares
+		} else {
+			s.LosingShares += o.Shares
+		}
+	}
+	s.Payout = s.WinningShares
+	s.RealizedPnl += s.Payout - s.CostBasis
+	s.Won = s.RealizedPnl > 0
+	return s
+}
+
+// ResolutionReconciler settles the positions tracked wallets hold in a
+// market when it resolves, emitting a wallet.settlement event per wallet.
+// Settlement hooks let confidence be recalculated exactly when a wallet's
+// bet is decided. It either polls Gamma for the book's markets itself (Run)
+// or is handed the resolutions a ResolutionWatcher sees (Settle), which
+// already polls every traded market.
+type ResolutionReconciler struct {
+	book     *PositionBook
+	gamma    *gamma.Client
+	emitter  events.Emitter
+	interval time.Duration
+	clock    clock.Clock
+
+	mu       sync.Mutex
+	onSettle []func(context.Context, Settlement)
+}
+
+// NewResolutionReconciler creates a reconciler checking book's markets every interval
+func NewResolutionReconciler(book *PositionBook, gammaClient *gamma.Client, emitter events.Emitter, interval time.Duration) *ResolutionReconciler {
+	return &ResolutionReconciler{
+		book:     book,
+		gamma:    gammaClient,
+		emitter:  emitter,
+		interval: interval,
+		clock:    clock.Real,
+	}
+}
+
+// SetClock replaces the clock driving the poll ticker
+func (r *ResolutionReconciler) SetClock(c clock.Clock) {
+	r.clock = clock.OrReal(c)
+}
+
+// OnSettlement registers fn to run for every settlement after its event is emitted
+func (r *ResolutionReconciler) OnSettlement(fn func(context.Context, Settlement)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.onSettle = append(r.onSettle, fn)
+}
+
+// Run polls until ctx is cancelled
+func (r *ResolutionReconciler) Run(ctx context.Context) error {
+	ticker := r.clock.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C():
+			r.Reconcile(ctx)
+		}
+	}
+}
+
+// Reconcile checks every market in the book once and settles the resolved
+// ones. Settlement hooks get ctx, not the poll's timeout.
+func (r *ResolutionReconciler) Reconcile(ctx context.Context) {
+	lookupCtx, cancel := context.WithTimeout(ctx, resolutionTimeout)
+	defer cancel()
+
+	now := r.clock.Now()
+	err := lookupResolved(lookupCtx, r.gamma, r.book.Markets(), func(market *gamma.Market, winner int) {
+		r.Settle(ctx, newMarketResolution(market, winner, now))
+	})
+	if err != nil {
+		resolutionLog.ErrorContext(ctx, "Error checking market resolutions", "error", err)
+	}
+}
+
+// Settle emits a settlement for every tracked position in the resolved
+// market. It has the signature of a ResolutionWatcher hook.
+func (r *ResolutionReconciler) Settle(ctx context.Context, res MarketResolution) {
+	positions := r.book.Close(res.ConditionID)
+	if len(positions) == 0 {
+		return
+	}
+	resolutionLog.InfoContext(ctx, "Settling tracked positions", "market", res.Slug,
+		"winning_index", res.WinningIndex, "positions", len(positions))
+
+	r.mu.Lock()
+	hooks := r.onSettle
+	r.mu.Unlock()
+
+	for _, pos := range positions {
+		s := settle(pos, res)
+		if err := r.emitter.Emit(ctx, events.New(events.TypeWalletSettlement, s.Wallet, s)); err != nil {
+			resolutionLog.ErrorContext(ctx, "Error emitting settlement", logging.KeyWallet, s.Wallet,
+				"market", s.ConditionID, "error", err)
+		}
+		for _, fn := range hooks {
+			fn(ctx, s)
+		}
+	}
+}
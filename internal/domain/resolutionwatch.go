@@ -0,0 +1,255 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/FatwaArya/pm-ingest/internal/events"
+	"github.com/FatwaArya/pm-ingest/internal/store"
+	"github.com/FatwaArya/pm-ingest/pkg/clock"
+	"github.com/FatwaArya/pm-ingest/pkg/gamma"
+	"github.com/FatwaArya/pm-ingest/pkg/rtds"
+)
+
+const (
+	// resolutionWatchExpiry is how long a market is watched after its last
+	// trade; markets left open longer are picked up again when traded
+	resolutionWatchExpiry = 90 * 24 * time.Hour
+	// resolvedMarketsMax bounds the resolutions a watcher keeps; the least
+	// recently looked up are forgotten first
+	resolvedMarketsMax = 100000
+)
+
+// MarketResolution is the winning outcome of a resolved market
+type MarketResolution struct {
+	ConditionID    string   `json:"conditionId"`
+	Slug           string   `json:"slug,omitempty"`
+	Question       string   `json:"question,omitempty"`
+	Outcomes       []string `json:"outcomes,omitempty"`
+	WinningIndex   int      `json:"winningIndex"`
+	WinningOutcome string   `json:"winningOutcome,omitempty"`
+	WinningAsset   string   `json:"winningAsset,omitempty"` // Token ID of the winning outcome
+	EndDate        string   `json:"endDate,omitempty"`      // RFC 3339, when the market was scheduled to end
+	ResolvedAt     int64    `json:"resolvedAt"`             // Unix seconds when the resolution was detected
+}
+
+// Won reports whether a trade in outcomeIndex was on the winning side
+func (r MarketResolution) Won(outcomeIndex int) bool {
+	return outcomeIndex == r.WinningIndex
+}
+
+// ClosedAt returns when the market stopped trading: its end date, or when
+// its resolution was detected if it has none
+func (r MarketResolution) ClosedAt() time.Time {
+	if end, err := time.Parse(time.RFC3339, r.EndDate); err == nil {
+		return end
+	}
+	return time.Unix(r.ResolvedAt, 0)
+}
+
+// newMarketResolution describes market, resolved to winner, at resolvedAt
+func newMarketResolution(market *gamma.Market, winner int, resolvedAt time.Time) MarketResolution {
+	r := MarketResolution{
+		ConditionID:  market.ConditionID,
+		Slug:         market.Slug,
+		Question:     market.Question,
+		WinningIndex: winner,
+		EndDate:      market.EndDate,
+		ResolvedAt:   resolvedAt.Unix(),
+	}
+	if names, err := market.OutcomeNames(); err == nil {
+		r.Outcomes = names
+		if winner < len(names) {
+			r.WinningOutcome = names[winner]
+		}
+	}
+	if ids, err := market.TokenIDs(); err == nil && winner < len(ids) {
+		r.WinningAsset = ids[winner]
+	}
+	return r
+}
+
+// ResolutionWatcher polls Gamma for the resolution of every market seen in
+// the trade stream. Each resolution is emitted once as a market.resolved
+// event and handed to the resolution hooks, e.g. to label trades with the
+// winning outcome in QuestDB or settle positions with
+// ResolutionReconciler.Settle.
+type ResolutionWatcher struct {
+	gamma    *gamma.Client
+	emitter  events.Emitter
+	interval time.Duration
+	clock    clock.Clock
+	resolved *store.LRUStore // Condition ID -> JSON MarketResolution
+
+	mu           sync.Mutex
+	watched      map[string]time.Time // Condition ID -> last trade
+	onResolution []func(context.Context, MarketResolution)
+}
+
+// NewResolutionWatcher creates a watcher checking traded markets every interval
+func NewResolutionWatcher(gammaClient *gamma.Client, emitter events.Emitter, interval time.Duration) *ResolutionWatcher {
+	return &ResolutionWatcher{
+		gamma:    gammaClient,
+		emitter:  emitter,
+		interval: interval,
+		clock:    clock.Real,
+		resolved: store.NewLRUStore(resolvedMarketsMax),
+		watched:  make(map[string]time.Time),
+	}
+}
+
+// SetClock replaces the clock driving the poll ticker
+func (w *ResolutionWatcher) SetClock(c clock.Clock) {
+	w.clock = clock.OrReal(c)
+}
+
+// OnResolution registers fn to run for every resolution after its event is emitted
+func (w *ResolutionWatcher) OnResolution(fn func(context.Context, MarketResolution)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.onResolution = append(w.onResolution, fn)
+}
+
+// Record watches the trade's market until it resolves
+func (w *ResolutionWatcher) Record(trade *rtds.ActivityTradePayload) {
+	id := strings.ToLower(trade.ConditionID)
+	if id == "" {
+		return
+	}
+	now := w.clock.Now()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, ok := w.lookup(id); !ok {
+		w.watched[id] = now
+	}
+}
+
+// Resolution returns the resolution of a market, if it was seen resolving
+// (and not forgotten since)
+func (w *ResolutionWatcher) Resolution(conditionID string) (MarketResolution, bool) {
+	return w.lookup(strings.ToLower(conditionID))
+}
+
+// lookup returns the kept resolution of the market with lowercase id
+func (w *ResolutionWatcher) lookup(id string) (MarketResolution, bool) {
+	data, ok, _ := w.resolved.Get(context.Background(), id)
+	if !ok {
+		return MarketResolution{}, false
+	}
+	var r MarketResolution
+	if err := json.Unmarshal(data, &r); err != nil {
+		return MarketResolution{}, false
+	}
+	return r, true
+}
+
+// keep stores the resolution of the market with lowercase id
+func (w *ResolutionWatcher) keep(id string, r MarketResolution) {
+	if data, err := json.Marshal(r); err == nil {
+		w.resolved.Set(context.Background(), id, data, 0)
+	}
+}
+
+// Watched returns the number of markets waiting for resolution
+func (w *ResolutionWatcher) Watched() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.watched)
+}
+
+// Resolutions returns the resolutions of the resolved markets among
+// conditionIDs, looking up in Gamma the ones not seen resolving. Those of
+// unwatched markets are kept for later lookups but not announced, as they
+// may be long past.
+func (w *ResolutionWatcher) Resolutions(ctx context.Context, conditionIDs []string) (map[string]MarketResolution, error) {
+	found := make(map[string]MarketResolution, len(conditionIDs))
+	var missing []string
+	for _, id := range conditionIDs {
+		id = strings.ToLower(id)
+		if r, ok := w.lookup(id); ok {
+			found[id] = r
+		} else if id != "" && !slices.Contains(missing, id) {
+			missing = append(missing, id)
+		}
+	}
+
+	now := w.clock.Now()
+	err := lookupResolved(ctx, w.gamma, missing, func(market *gamma.Market, winner int) {
+		r := newMarketResolution(market, winner, now)
+		id := strings.ToLower(r.ConditionID)
+		found[id] = r
+		// Watched markets are left for Poll to announce
+		w.mu.Lock()
+		if _, watched := w.watched[id]; !watched {
+			w.keep(id, r)
+		}
+		w.mu.Unlock()
+	})
+	return found, err
+}
+
+// Run polls until ctx is cancelled
+func (w *ResolutionWatcher) Run(ctx context.Context) error {
+	ticker := w.clock.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C():
+			w.Poll(ctx)
+		}
+	}
+}
+
+// Poll checks every watched market once and reports the resolved ones.
+// Resolution hooks get ctx, not the poll's timeout.
+func (w *ResolutionWatcher) Poll(ctx context.Context) {
+	lookupCtx, cancel := context.WithTimeout(ctx, resolutionTimeout)
+	defer cancel()
+
+	now := w.clock.Now()
+	w.mu.Lock()
+	ids := make([]string, 0, len(w.watched))
+	for id, last := range w.watched {
+		if now.Sub(last) > resolutionWatchExpiry {
+			delete(w.watched, id)
+			continue
+		}
+		ids = append(ids, id)
+	}
+	w.mu.Unlock()
+
+	err := lookupResolved(lookupCtx, w.gamma, ids, func(market *gamma.Market, winner int) {
+		w.resolve(ctx, newMarketResolution(market, winner, now))
+	})
+	if err != nil {
+		resolutionLog.ErrorContext(ctx, "Error checking market resolutions", "error", err)
+	}
+}
+
+// resolve records a resolution, emits its event and runs the hooks
+func (w *ResolutionWatcher) resolve(ctx context.Context, r MarketResolution) {
+	id := strings.ToLower(r.ConditionID)
+	w.mu.Lock()
+	if _, ok := w.lookup(id); ok {
+		w.mu.Unlock()
+		return
+	}
+	delete(w.watched, id)
+	w.keep(id, r)
+	hooks := w.onResolution
+	w.mu.Unlock()
+
+	resolutionLog.InfoContext(ctx, "Market resolved", "market", r.Slug, "winning_outcome", r.WinningOutcome)
+	if err := w.emitter.Emit(ctx, events.New(events.TypeMarketResolved, r.ConditionID, r)); err != nil {
+		resolutionLog.ErrorContext(ctx, "Error emitting resolution", "market", r.ConditionID, "error", err)
+	}
+	for _, fn := range hooks {
+		fn(ctx, r)
+	}
+}
@@ -0,0 +1,167 @@
+package domain
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	internalqdb "github.com/FatwaArya/pm-ingest/internal"
+	"github.com/FatwaArya/pm-ingest/internal/logging"
+	"github.com/FatwaArya/pm-ingest/pkg/clock"
+)
+
+const (
+	// Backoff between attempts to write a failed profile, doubling from
+	// retryBaseDelay up to retryMaxDelay
+	retryBaseDelay = 5 * time.Second
+	retryMaxDelay  = 5 * time.Minute
+	// retryMaxAttempts bounds how long a profile is retried (about 40m)
+	retryMaxAttempts = 12
+	// retryQueueSize bounds memory during a long QuestDB outage; the oldest
+	// profile is dropped when full
+	retryQueueSize = 10000
+)
+
+type profileRetry struct {
+	profile  *internalqdb.UserProfile
+	attempts int
+	due      time.Time
+}
+
+// ProfileRetryQueue holds profiles whose QuestDB write failed and writes
+// them again with exponential backoff. A profile queued again (the wallet
+// traded again) replaces the queued one but keeps its schedule.
+type ProfileRetryQueue struct {
+	write   func(ctx context.Context, profile *internalqdb.UserProfile) error
+	dropped func(ctx context.Context, profile *internalqdb.UserProfile)
+	clock   clock.Clock
+
+	mu      sync.Mutex
+	pending map[string]*profileRetry
+	order   []string // Addresses, oldest first
+}
+
+// NewProfileRetryQueue creates a queue retrying with write. dropped is called
+// with profiles given up on (after retryMaxAttempts or on overflow).
+func NewProfileRetryQueue(write func(ctx context.Context, profile *internalqdb.UserProfile) error,
+	dropped func(ctx context.Context, profile *internalqdb.UserProfile)) *ProfileRetryQueue {
+	return &ProfileRetryQueue{
+		write:   write,
+		dropped: dropped,
+		clock:   clock.Real,
+		pending: make(map[string]*profileRetry),
+	}
+}
+
+// SetClock replaces the clock driving the backoff
+func (q *ProfileRetryQueue) SetClock(c clock.Clock) {
+	q.clock = clock.OrReal(c)
+}
+
+// Add queues a profile for another write attempt
+func (q *ProfileRetryQueue) Add(ctx context.Context, profile *internalqdb.UserProfile) {
+	address := strings.ToLower(profile.Address)
+	var evicted *internalqdb.UserProfile
+
+	q.mu.Lock()
+	if r, ok := q.pending[address]; ok {
+		r.profile = profile
+		q.mu.Unlock()
+		return
+	}
+	if len(q.order) >= retryQueueSize {
+		oldest := q.order[0]
+		q.order = q.order[1:]
+		evicted = q.pending[oldest].profile
+		delete(q.pending, oldest)
+	}
+	q.pending[address] = &profileRetry{profile: profile, due: q.clock.Now().Add(retryBaseDelay)}
+	q.order = append(q.order, address)
+	q.mu.Unlock()
+
+	if evicted != nil {
+		discoveryLog.WarnContext(ctx, "Profile retry queue full, dropping profile", logging.KeyWallet, evicted.Address)
+		q.dropped(ctx, evicted)
+	}
+}
+
+// Len returns the number of queued profiles
+func (q *ProfileRetryQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.pending)
+}
+
+// Run retries due profiles every second until ctx is cancelled
+func (q *ProfileRetryQueue) Run(ctx context.Context) error {
+	ticker := q.clock.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C():
+			q.retryDue(ctx)
+		}
+	}
+}
+
+// retryDue writes every due profile, in queue order, rescheduling failures
+func (q *ProfileRetryQueue) retryDue(ctx context.Context) {
+	for _, r := range q.due() {
+		if ctx.Err() != nil {
+			return
+		}
+		err := q.write(ctx, r.profile)
+		address := strings.ToLower(r.profile.Address)
+
+		q.mu.Lock()
+		current, ok := q.pending[address]
+		if !ok || current != r {
+			q.mu.Unlock()
+			continue // Evicted while writing
+		}
+		if err == nil {
+			q.remove(address)
+			q.mu.Unlock()
+			discoveryLog.InfoContext(ctx, "Saved profile after retries", logging.KeyWallet, r.profile.Address, "retries", r.attempts+1)
+			continue
+		}
+		r.attempts++
+		if r.attempts >= retryMaxAttempts {
+			q.remove(address)
+			q.mu.Unlock()
+			discoveryLog.ErrorContext(ctx, "Giving up on profile", logging.KeyWallet, r.profile.Address, "retries", r.attempts, "error", err)
+			q.dropped(ctx, r.profile)
+			continue
+		}
+		r.due = q.clock.Now().Add(min(retryBaseDelay<<r.attempts, retryMaxDelay))
+		q.mu.Unlock()
+	}
+}
+
+// due returns the profiles whose next attempt is due
+func (q *ProfileRetryQueue) due() []*profileRetry {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	now := q.clock.Now()
+	var due []*profileRetry
+	for _, address := range q.order {
+		if r := q.pending[address]; !r.due.After(now) {
+			due = append(due, r)
+		}
+	}
+	return due
+}
+
+// remove deletes a queued address; called with mu held
+func (q *ProfileRetryQueue) remove(address string) {
+	delete(q.pending, address)
+	for i, a := range q.order {
+		if a == address {
+			q.order = append(q.order[:i], q.order[i+1:]...)
+			break
+		}
+	}
+}
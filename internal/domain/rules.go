@@ -0,0 +1,184 @@
+package domain
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	internalkafka "github.com/FatwaArya/pm-ingest/internal/kafka"
+	"github.com/google/cel-go/cel"
+)
+
+// discoveryEnv declares the variables of discovery rules: the trade's
+// fields as in FILTER_EXPR, the wallet's rolling volume and the asset's
+// recent price move
+func discoveryEnv() (*cel.Env, error) {
+	return cel.NewEnv(
+		cel.CrossTypeNumericComparisons(true),
+		cel.Variable("asset", cel.StringType),
+		cel.Variable("side", cel.StringType),
+		cel.Variable("price", cel.DoubleType),
+		cel.Variable("size", cel.DoubleType),
+		cel.Variable("notional", cel.DoubleType),
+		cel.Variable("timestamp", cel.IntType),
+		cel.Variable("conditionId", cel.StringType),
+		cel.Variable("outcome", cel.StringType),
+		cel.Variable("slug", cel.StringType),
+		cel.Variable("eventSlug", cel.StringType),
+		cel.Variable("title", cel.StringType),
+		cel.Variable("proxyWallet", cel.StringType),
+		cel.Variable("labels", cel.ListType(cel.StringType)),
+		cel.Variable("walletTradesMinute", cel.IntType),
+		cel.Variable("walletTradesHour", cel.IntType),
+		cel.Variable("walletNotionalMinute", cel.DoubleType),
+		cel.Variable("walletNotionalHour", cel.DoubleType),
+		cel.Variable("priceMove", cel.DoubleType),
+	)
+}
+
+// DiscoveryRule decides which trades the discovery service acts on: a CEL
+// expression combining criteria with && and ||, e.g.
+//
+//	notional >= 10000 || (walletNotionalHour >= 50000 && eventSlug in ["us-election"])
+//	priceMove >= 0.1 && notional >= 1000
+//
+// walletNotionalMinute/Hour and walletTradesMinute/Hour are the wallet's
+// volume over the last minute and hour, this trade included. priceMove is
+// how far (absolute, in price) the trade moved the asset from its lowest
+// or highest price within the rule's window.
+type DiscoveryRule struct {
+	expression string
+	program    cel.Program
+	moves      *priceMoves
+}
+
+// CompileDiscoveryRule compiles expression; priceMove looks back over
+// window
+func CompileDiscoveryRule(expression string, window time.Duration) (*DiscoveryRule, error) {
+	env, err := discoveryEnv()
+	if err != nil {
+		return nil, err
+	}
+	ast, issues := env.Compile(expression)
+	if issues.Err() != nil {
+		return nil, fmt.Errorf("invalid discovery rule: %w", issues.Err())
+	}
+	if ast.OutputType() != cel.BoolType {
+		return nil, fmt.Errorf("discovery rule must be a bool, not %s", ast.OutputType())
+	}
+	prg, err := env.Program(ast)
+	if err != nil {
+		return nil, fmt.Errorf("invalid discovery rule: %w", err)
+	}
+	return &DiscoveryRule{expression: expression, program: prg, moves: newPriceMoves(window)}, nil
+}
+
+// String returns the rule's expression
+func (r *DiscoveryRule) String() string {
+	return r.expression
+}
+
+// Observe tracks the price of trade for priceMove. Every trade should be
+// observed, including those of wallets outside the shard.
+func (r *DiscoveryRule) Observe(trade internalkafka.TradeMessage) {
+	r.moves.record(trade.Asset, trade.Price, time.Unix(trade.Timestamp, 0))
+}
+
+// Match reports whether trade, observed first, meets the rule given its
+// wallet's velocity
+func (r *DiscoveryRule) Match(trade internalkafka.TradeMessage, vel WalletVelocity) (bool, error) {
+	move := r.moves.move(trade.Asset, trade.Price, time.Unix(trade.Timestamp, 0))
+	labels := trade.Labels
+	if labels == nil {
+		labels = []string{}
+	}
+	out, _, err := r.program.Eval(map[string]any{
+		"asset":                trade.Asset,
+		"side":                 trade.Side,
+		"price":                trade.Price,
+		"size":                 trade.Size,
+		"notional":             trade.Size * trade.Price,
+		"timestamp":            trade.Timestamp,
+		"conditionId":          trade.ConditionId,
+		"outcome":              trade.Outcome,
+		"slug":                 trade.Slug,
+		"eventSlug":            trade.EventSlug,
+		"title":                trade.Title,
+		"proxyWallet":          trade.ProxyWallet,
+		"labels":               labels,
+		"walletTradesMinute":   vel.TradesLastMinute,
+		"walletTradesHour":     vel.TradesLastHour,
+		"walletNotionalMinute": vel.NotionalLastMinute,
+		"walletNotionalHour":   vel.NotionalLastHour,
+		"priceMove":            move,
+	})
+	if err != nil {
+		return false, err
+	}
+	match, ok := out.Value().(bool)
+	if !ok {
+		return false, fmt.Errorf("discovery rule returned %T, not bool", out.Value())
+	}
+	return match, nil
+}
+
+// priceMoves keeps each asset's price range over a sliding window
+type priceMoves struct {
+	window time.Duration
+
+	mu        sync.Mutex
+	assets    map[string][]pricePoint
+	lastSweep time.Time
+}
+
+type pricePoint struct {
+	at    time.Time
+	price float64
+}
+
+func newPriceMoves(window time.Duration) *priceMoves {
+	return &priceMoves{window: window, assets: make(map[string][]pricePoint)}
+}
+
+// record adds a trade price to its asset's window
+func (m *priceMoves) record(asset string, price float64, at time.Time) {
+	if m.window <= 0 || asset == "" {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cutoff := at.Add(-m.window)
+	points := m.assets[asset]
+	i := 0
+	for i < len(points) && points[i].at.Before(cutoff) {
+		i++
+	}
+	m.assets[asset] = append(points[i:], pricePoint{at: at, price: price})
+
+	// Drop assets that stopped trading
+	if at.Sub(m.lastSweep) > m.window {
+		m.lastSweep = at
+		for a, pts := range m.assets {
+			if pts[len(pts)-1].at.Before(cutoff) {
+				delete(m.assets, a)
+			}
+		}
+	}
+}
+
+// move returns the largest distance of price from the asset's prices
+// within the window before at
+func (m *priceMoves) move(asset string, price float64, at time.Time) float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cutoff := at.Add(-m.window)
+	var move float64
+	for _, p := range m.assets[asset] {
+		if !p.at.Before(cutoff) {
+			move = math.Max(move, math.Abs(price-p.price))
+		}
+	}
+	return move
+}
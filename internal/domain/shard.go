@@ -0,0 +1,59 @@
+package domain
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strings"
+)
+
+// Shard selects the subset of wallets an analytics replica is responsible for.
+// Every replica consumes the full trade stream (each shard uses its own
+// consumer group) and drops wallets that hash to another shard, so the
+// API-heavy work per wallet is split across replicas without coordination.
+type Shard struct {
+	Index int
+	Count int
+}
+
+// NewShard validates and returns a shard; count <= 1 disables sharding
+func NewShard(index int, count int) (Shard, error) {
+	if count <= 1 {
+		return Shard{Index: 0, Count: 1}, nil
+	}
+	if index < 0 || index >= count {
+		return Shard{}, fmt.Errorf("shard index %d out of range for %d shards", index, count)
+	}
+	return Shard{Index: index, Count: count}, nil
+}
+
+// Enabled reports whether the wallet space is split across more than one shard
+func (s Shard) Enabled() bool {
+	return s.Count > 1
+}
+
+// Owns reports whether wallet belongs to this shard
+func (s Shard) Owns(wallet string) bool {
+	if !s.Enabled() {
+		return true
+	}
+	return ShardFor(wallet, s.Count) == s.Index
+}
+
+// GroupID suffixes a consumer group ID with the shard so each shard reads
+// every record instead of sharing partitions with the other shards
+func (s Shard) GroupID(base string) string {
+	if !s.Enabled() {
+		return base
+	}
+	return fmt.Sprintf("%s-shard-%d-of-%d", base, s.Index, s.Count)
+}
+
+// ShardFor returns the shard (0..count-1) that wallet hashes to
+func ShardFor(wallet string, count int) int {
+	if count <= 1 {
+		return 0
+	}
+	h := fnv.New32a()
+	h.Write([]byte(strings.ToLower(wallet)))
+	return int(h.Sum32() % uint32(count))
+}
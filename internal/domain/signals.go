@@ -0,0 +1,187 @@
+package domain
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/FatwaArya/pm-ingest/internal/events"
+	"github.com/FatwaArya/pm-ingest/internal/logging"
+	"github.com/FatwaArya/pm-ingest/pkg/rtds"
+)
+
+// signalQueueSize bounds the signals waiting to be emitted
+const signalQueueSize = 1024
+
+var signalLog = logging.NewRateLimited(logging.For("signals"), 5*time.Second)
+
+// Signal reasons: why a wallet's trades are signalled
+const (
+	SignalReasonTracked    = "tracked"     // On the configured list
+	SignalReasonWatchlist  = "watchlist"   // On the wallet watchlist
+	SignalReasonCopyTarget = "copy_target" // Labelled a copy target
+)
+
+// Signal is a trade of a tracked wallet, for copy trading
+type Signal struct {
+	Wallet      string    `json:"wallet"`
+	Reason      string    `json:"reason"`
+	ConditionID string    `json:"conditionId"`
+	Slug        string    `json:"slug,omitempty"`
+	Title       string    `json:"title,omitempty"`
+	Asset       string    `json:"asset"`
+	Outcome     string    `json:"outcome,omitempty"`
+	Side        string    `json:"side"`
+	Size        float64   `json:"size"`
+	Price       float64   `json:"price"`
+	Notional    float64   `json:"notional"`
+	Confidence  float64   `json:"confidence"` // Calibration (0-100) decayed by freshness
+	Scored      bool      `json:"scored"`     // False when no confidence was computed yet
+	TxHash      string    `json:"transactionHash,omitempty"`
+	TradedAt    time.Time `json:"tradedAt"`
+}
+
+// SignalService turns the trades of tracked wallets into signal events:
+// wallets on the configured list or the wallet watchlist and, with a label
+// store, wallets labelled copy targets. Signals are queued and emitted by
+// Run, so Record never blocks the pipeline; they are dropped while the
+// queue is full.
+type SignalService struct {
+	labels      *WalletLabels
+	watchlist   *WalletWatchlist
+	minNotional float64
+	emitters    []events.Emitter
+	queue       chan Signal
+	dropped     atomic.Uint64
+
+	mu         sync.RWMutex
+	wallets    map[string]struct{}
+	confidence *ConfidenceRefresher
+}
+
+// NewSignalService creates a service emitting the signals of trades worth
+// at least minNotional to every emitter
+func NewSignalService(wallets []string, minNotional float64, emitters ...events.Emitter) *SignalService {
+	s := &SignalService{
+		minNotional: minNotional,
+		emitters:    emitters,
+		queue:       make(chan Signal, signalQueueSize),
+		wallets:     make(map[string]struct{}, len(wallets)),
+	}
+	for _, wallet := range wallets {
+		s.Track(wallet)
+	}
+	return s
+}
+
+// SetLabels also signals the wallets labelled copy targets in labels
+func (s *SignalService) SetLabels(labels *WalletLabels) {
+	s.labels = labels
+}
+
+// SetWatchlist also signals the wallets on watchlist
+func (s *SignalService) SetWatchlist(watchlist *WalletWatchlist) {
+	s.watchlist = watchlist
+}
+
+// SetConfidence adds the wallets' scores of refresher to their signals
+func (s *SignalService) SetConfidence(refresher *ConfidenceRefresher) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.confidence = refresher
+}
+
+// Track adds wallet to the tracked wallets
+func (s *SignalService) Track(wallet string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.wallets[strings.ToLower(wallet)] = struct{}{}
+}
+
+// Untrack removes wallet from the tracked wallets and reports whether it was tracked
+func (s *SignalService) Untrack(wallet string) bool {
+	wallet = strings.ToLower(wallet)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.wallets[wallet]
+	delete(s.wallets, wallet)
+	return ok
+}
+
+// Dropped returns the number of signals dropped on a full queue
+func (s *SignalService) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Record queues a signal for the trade if its wallet is tracked
+func (s *SignalService) Record(trade *rtds.ActivityTradePayload) {
+	notional := trade.Price * trade.Size
+	if trade.ProxyWalletAddress == "" || notional < s.minNotional {
+		return
+	}
+	wallet := strings.ToLower(trade.ProxyWalletAddress)
+
+	s.mu.RLock()
+	_, tracked := s.wallets[wallet]
+	refresher := s.confidence
+	s.mu.RUnlock()
+	var reason string
+	switch {
+	case tracked:
+		reason = SignalReasonTracked
+	case s.watchlist != nil && s.watchlist.Contains(wallet):
+		reason = SignalReasonWatchlist
+	case s.labels != nil && s.labels.Has(wallet, LabelCopyTarget):
+		reason = SignalReasonCopyTarget
+	default:
+		return
+	}
+
+	signal := Signal{
+		Wallet:      wallet,
+		Reason:      reason,
+		ConditionID: trade.ConditionID,
+		Slug:        trade.MarketSlug,
+		Title:       trade.EventTitle,
+		Asset:       trade.Asset,
+		Outcome:     trade.OutcomeTitle,
+		Side:        trade.Side,
+		Size:        trade.Size,
+		Price:       trade.Price,
+		Notional:    notional,
+		TxHash:      trade.TransactionHash,
+		TradedAt:    time.Unix(trade.Timestamp, 0).UTC(),
+	}
+	if refresher != nil {
+		if score, ok := refresher.Score(wallet); ok {
+			signal.Confidence = score.Prediction.Calibration * score.Freshness
+			signal.Scored = true
+		}
+	}
+
+	select {
+	case s.queue <- signal:
+	default:
+		s.dropped.Add(1)
+		signalLog.Printf("Signal queue full, dropping signal of %s", wallet)
+	}
+}
+
+// Run emits the queued signals until ctx is cancelled
+func (s *SignalService) Run(ctx context.Context) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case signal := <-s.queue:
+			event := events.New(events.TypeTradeSignal, signal.Wallet, signal)
+			for _, emitter := range s.emitters {
+				if err := emitter.Emit(ctx, event); err != nil {
+					signalLog.Printf("Error emitting signal of %s: %v", signal.Wallet, err)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/FatwaArya/pm-ingest/pkg/clock"
+)
+
+// neutralConfidence weighs wallets whose confidence hasn't been computed
+const neutralConfidence = 50
+
+// TopTrader is one ranked wallet of the top-traders snapshot
+type TopTrader struct {
+	Rank       int       `json:"rank"`
+	Wallet     string    `json:"wallet"`
+	Score      float64   `json:"score"`
+	Confidence float64   `json:"confidence"` // Calibration (0-100) decayed by freshness
+	Scored     bool      `json:"scored"`     // False when the confidence is the neutral default
+	Stale      bool      `json:"stale"`
+	Trades     int64     `json:"trades"`
+	Notional   float64   `json:"notional"` // Volume within the flow retention period
+	Fees       float64   `json:"fees"`
+	LastTrade  time.Time `json:"lastTrade"`
+}
+
+// TopTradersSnapshot is a ranking computed at one point in time. JSON holds
+// the encoded snapshot, so the most common request is served without work.
+type TopTradersSnapshot struct {
+	Traders     []TopTrader `json:"traders"`
+	GeneratedAt time.Time   `json:"generatedAt"`
+	JSON        []byte      `json:"-"`
+}
+
+// TopTraders keeps an in-memory ranking of the top wallets by confidence
+// and recent volume, rebuilt every interval from the flow tracker and the
+// confidence refresher. A wallet's score is log10(1+notional) weighted by
+// its confidence, or by neutralConfidence until one is computed.
+type TopTraders struct {
+	flow     *FlowTracker
+	size     int
+	interval time.Duration
+	clock    clock.Clock
+
+	mu         sync.Mutex
+	confidence *ConfidenceRefresher
+
+	snapshot atomic.Pointer[TopTradersSnapshot]
+}
+
+// NewTopTraders creates a snapshot of the size best wallets, rebuilt every interval
+func NewTopTraders(flow *FlowTracker, size int, interval time.Duration) *TopTraders {
+	t := &TopTraders{
+		flow:     flow,
+		size:     size,
+		interval: interval,
+		clock:    clock.Real,
+	}
+	t.snapshot.Store(encodeSnapshot(&TopTradersSnapshot{Traders: []TopTrader{}}))
+	return t
+}
+
+// SetClock replaces the clock driving the rebuild schedule
+func (t *TopTraders) SetClock(c clock.Clock) {
+	t.clock = clock.OrReal(c)
+}
+
+// SetConfidence ranks wallets by the scores of refresher
+func (t *TopTraders) SetConfidence(refresher *ConfidenceRefresher) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.confidence = refresher
+}
+
+// Snapshot returns the latest ranking
+func (t *TopTraders) Snapshot() *TopTradersSnapshot {
+	return t.snapshot.Load()
+}
+
+// Run rebuilds the snapshot every interval until ctx is cancelled
+func (t *TopTraders) Run(ctx context.Context) error {
+	t.Rebuild()
+	ticker := t.clock.NewTicker(t.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C():
+			t.Rebuild()
+		}
+	}
+}
+
+// Rebuild ranks the tracked wallets and replaces the snapshot
+func (t *TopTraders) Rebuild() {
+	t.mu.Lock()
+	refresher := t.confidence
+	t.mu.Unlock()
+
+	wallets := t.flow.Wallets()
+	traders := make([]TopTrader, 0, len(wallets))
+	for wallet, stats := range wallets {
+		trader := TopTrader{
+			Wallet:     wallet,
+			Confidence: neutralConfidence,
+			Trades:     stats.Trades,
+			Notional:   stats.Notional,
+			Fees:       stats.Fees,
+			LastTrade:  stats.LastTrade,
+		}
+		if refresher != nil {
+			if score, ok := refresher.Score(wallet); ok {
+				trader.Confidence = score.Prediction.Calibration * score.Freshness
+				trader.Scored = true
+				trader.Stale = score.Stale
+			}
+		}
+		trader.Score = math.Log10(1+trader.Notional) * trader.Confidence / 100
+		traders = append(traders, trader)
+	}
+
+	sort.Slice(traders, func(i, j int) bool {
+		if traders[i].Score != traders[j].Score {
+			return traders[i].Score > traders[j].Score
+		}
+		return traders[i].Wallet < traders[j].Wallet
+	})
+	if len(traders) > t.size {
+		traders = traders[:t.size]
+	}
+	for i := range traders {
+		traders[i].Rank = i + 1
+	}
+
+	t.snapshot.Store(encodeSnapshot(&TopTradersSnapshot{Traders: traders, GeneratedAt: t.clock.Now()}))
+}
+
+func encodeSnapshot(s *TopTradersSnapshot) *TopTradersSnapshot {
+	data, err := json.Marshal(s)
+	if err != nil {
+		leaderboardLog.Printf("Error encoding top traders snapshot: %v", err)
+		return s
+	}
+	s.JSON = data
+	return s
+}
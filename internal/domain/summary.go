@@ -0,0 +1,199 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/FatwaArya/pm-ingest/pkg/clock"
+	"github.com/FatwaArya/pm-ingest/pkg/rtds"
+)
+
+const (
+	// summaryInterval is the window each market summary covers
+	summaryInterval = time.Minute
+	// summaryLateness is how long after a window ends trades may still
+	// arrive for it before its summary is published
+	summaryLateness = 5 * time.Second
+)
+
+// MarketSummary is a market's trading within one summary window. Buy and
+// sell notional follow the aggressor side where the role is known, so the
+// imbalance is the net pressure on the price, from -1 (all selling) to 1.
+type MarketSummary struct {
+	ConditionID   string             `json:"conditionId"`
+	Slug          string             `json:"slug,omitempty"`
+	EventSlug     string             `json:"eventSlug,omitempty"`
+	Start         time.Time          `json:"start"`
+	End           time.Time          `json:"end"`
+	Trades        int64              `json:"trades"`
+	Volume        float64            `json:"volume"`   // Shares
+	Notional      float64            `json:"notional"` // USD
+	BuyNotional   float64            `json:"buyNotional"`
+	SellNotional  float64            `json:"sellNotional"`
+	Imbalance     float64            `json:"imbalance"`
+	LastPrice     float64            `json:"lastPrice"`
+	LastOutcome   string             `json:"lastOutcome,omitempty"`
+	LastPrices    map[string]float64 `json:"lastPrices"` // By outcome
+	UniqueWallets int                `json:"uniqueWallets"`
+	lastTrade     int64
+	wallets       map[string]struct{}
+}
+
+func (s *MarketSummary) add(trade *rtds.ActivityTradePayload) {
+	notional := trade.Price * trade.Size
+	s.Trades++
+	s.Volume += trade.Size
+	s.Notional += notional
+	side := trade.AggressorSide()
+	if side == "" {
+		side = trade.Side
+	}
+	switch side {
+	case rtds.SideBuy:
+		s.BuyNotional += notional
+	case rtds.SideSell:
+		s.SellNotional += notional
+	}
+	if total := s.BuyNotional + s.SellNotional; total > 0 {
+		s.Imbalance = (s.BuyNotional - s.SellNotional) / total
+	}
+	if trade.Timestamp >= s.lastTrade {
+		s.lastTrade = trade.Timestamp
+		s.LastPrice = trade.Price
+		s.LastOutcome = trade.OutcomeTitle
+	}
+	if trade.OutcomeTitle != "" {
+		s.LastPrices[trade.OutcomeTitle] = trade.Price
+	}
+	if wallet := strings.ToLower(trade.ProxyWalletAddress); wallet != "" {
+		s.wallets[wallet] = struct{}{}
+		s.UniqueWallets = len(s.wallets)
+	}
+}
+
+// SummaryProducer sends encoded summaries, e.g. a Kafka producer
+type SummaryProducer interface {
+	Produce(ctx context.Context, topic string, key, value []byte) error
+}
+
+// MarketSummarizer aggregates the trade stream into per-minute summaries
+// per market and publishes each once its minute is over, giving consumers
+// a compact alternative to the raw trades. Trades arriving after their
+// minute was published are dropped.
+type MarketSummarizer struct {
+	producer SummaryProducer
+	topic    string
+	clock    clock.Clock
+
+	mu        sync.Mutex
+	windows   map[time.Time]map[string]*MarketSummary
+	published time.Time // End of the newest published window
+	late      int64
+}
+
+// NewMarketSummarizer creates a summarizer publishing to topic
+func NewMarketSummarizer(producer SummaryProducer, topic string) *MarketSummarizer {
+	return &MarketSummarizer{
+		producer: producer,
+		topic:    topic,
+		clock:    clock.Real,
+		windows:  make(map[time.Time]map[string]*MarketSummary),
+	}
+}
+
+// SetClock replaces the clock that closes windows
+func (m *MarketSummarizer) SetClock(c clock.Clock) {
+	m.clock = clock.OrReal(c)
+}
+
+// Record adds a trade to its market's summary for the trade's minute
+func (m *MarketSummarizer) Record(trade *rtds.ActivityTradePayload) {
+	if trade.ConditionID == "" || trade.Size <= 0 {
+		return
+	}
+	start := time.Unix(trade.Timestamp, 0).Truncate(summaryInterval)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if start.Before(m.published) {
+		m.late++
+		return
+	}
+	markets, ok := m.windows[start]
+	if !ok {
+		markets = make(map[string]*MarketSummary)
+		m.windows[start] = markets
+	}
+	s, ok := markets[trade.ConditionID]
+	if !ok {
+		s = &MarketSummary{
+			ConditionID: trade.ConditionID,
+			Slug:        trade.MarketSlug,
+			EventSlug:   trade.EventSlug,
+			Start:       start,
+			End:         start.Add(summaryInterval),
+			LastPrices:  make(map[string]float64),
+			wallets:     make(map[string]struct{}),
+		}
+		markets[trade.ConditionID] = s
+	}
+	s.add(trade)
+}
+
+// Late returns the number of trades dropped for arriving after their
+// window was published
+func (m *MarketSummarizer) Late() int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.late
+}
+
+// Run publishes closed windows every second until ctx is cancelled
+func (m *MarketSummarizer) Run(ctx context.Context) error {
+	ticker := m.clock.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C():
+			m.publish(ctx)
+		}
+	}
+}
+
+// publish sends the summaries of every window that ended more than
+// summaryLateness ago
+func (m *MarketSummarizer) publish(ctx context.Context) {
+	cutoff := m.clock.Now().Add(-summaryLateness)
+	var closed []*MarketSummary
+	m.mu.Lock()
+	for start, markets := range m.windows {
+		end := start.Add(summaryInterval)
+		if end.After(cutoff) {
+			continue
+		}
+		for _, s := range markets {
+			closed = append(closed, s)
+		}
+		delete(m.windows, start)
+		if end.After(m.published) {
+			m.published = end
+		}
+	}
+	m.mu.Unlock()
+
+	for _, s := range closed {
+		value, err := json.Marshal(s)
+		if err != nil {
+			writeErrLog.Printf("Error encoding summary of %s: %v", s.ConditionID, err)
+			continue
+		}
+		if err := m.producer.Produce(ctx, m.topic, []byte(s.ConditionID), value); err != nil {
+			writeErrLog.Printf("Error publishing summary of %s: %v", s.ConditionID, err)
+		}
+	}
+}
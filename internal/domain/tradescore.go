@@ -0,0 +1,125 @@
+package domain
+
+import (
+	"context"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/FatwaArya/pm-ingest/pkg/dataapi"
+)
+
+const (
+	// tradeScoreLeadUnit and tradeScoreMaxLead shape the time weights: a
+	// trade weighs 1 plus its lead time before the market closed in weeks,
+	// up to 30 days ahead
+	tradeScoreLeadUnit = 7 * 24 * time.Hour
+	tradeScoreMaxLead  = 30 * 24 * time.Hour
+	// tradeScoreEpsilon keeps log scores finite for prices of 0 or 1
+	tradeScoreEpsilon = 1e-6
+)
+
+// TradeScores are Brier and log scores of trades against the outcomes
+// their markets resolved to. A trade forecasts its side at its price: a
+// buy at 0.3 says 30% for the outcome bought, a sell at 0.3 says 70%
+// against it. Lower is better for both; the time-weighted variants count
+// trades placed longer before the market closed more, so early correct
+// bets improve them most.
+type TradeScores struct {
+	Brier             float64
+	Log               float64
+	TimeWeightedBrier float64
+	TimeWeightedLog   float64
+	Trades            int // Trades in resolved markets
+}
+
+// ScoreTrades scores the trades whose markets are in resolutions, keyed
+// by lowercase condition ID
+func ScoreTrades(trades []dataapi.Trade, resolutions map[string]MarketResolution) TradeScores {
+	var s TradeScores
+	var weights float64
+	for _, t := range trades {
+		r, ok := resolutions[strings.ToLower(t.ConditionID)]
+		if !ok {
+			continue
+		}
+		forecast, won := t.Price, r.Won(t.OutcomeIndex)
+		if strings.EqualFold(t.Side, "SELL") {
+			forecast, won = 1-t.Price, !won
+		}
+		forecast = min(max(forecast, tradeScoreEpsilon), 1-tradeScoreEpsilon)
+		outcome, likelihood := 0.0, 1-forecast
+		if won {
+			outcome, likelihood = 1, forecast
+		}
+		brier := (forecast - outcome) * (forecast - outcome)
+		logLoss := -math.Log(likelihood)
+
+		lead := r.ClosedAt().Sub(time.Unix(t.Timestamp, 0))
+		weight := 1 + float64(min(max(lead, 0), tradeScoreMaxLead))/float64(tradeScoreLeadUnit)
+
+		s.Trades++
+		s.Brier += brier
+		s.Log += logLoss
+		s.TimeWeightedBrier += weight * brier
+		s.TimeWeightedLog += weight * logLoss
+		weights += weight
+	}
+	if s.Trades == 0 {
+		return s
+	}
+	s.Brier /= float64(s.Trades)
+	s.Log /= float64(s.Trades)
+	s.TimeWeightedBrier /= weights
+	s.TimeWeightedLog /= weights
+	return s
+}
+
+// TradeScorer adds trade-level scores to confidence results, from a
+// wallet's recent trades and the resolutions of their markets. A nil
+// scorer adds nothing.
+type TradeScorer struct {
+	client      *dataapi.Client
+	resolutions *ResolutionWatcher
+	maxTrades   int
+}
+
+// NewTradeScorer creates a scorer looking at up to maxTrades of a wallet's
+// most recent trades
+func NewTradeScorer(client *dataapi.Client, resolutions *ResolutionWatcher, maxTrades int) *TradeScorer {
+	return &TradeScorer{client: client, resolutions: resolutions, maxTrades: maxTrades}
+}
+
+// Score fills the trade-level scores of p for the wallet's trades in the
+// markets of q (all markets if none). If the trades can't be fetched p is
+// left as it was: the position-based scores still stand.
+func (s *TradeScorer) Score(ctx context.Context, wallet string, q ConfidenceQuery, p *PredictionResult) {
+	if s == nil {
+		return
+	}
+	takerOnly := false
+	trades, err := s.client.GetTrades(ctx, dataapi.TradesQueryParams{
+		User:      wallet,
+		Market:    q.Markets,
+		TakerOnly: &takerOnly,
+		Limit:     s.maxTrades,
+	})
+	if err != nil {
+		confidenceLog.ErrorContext(ctx, "Error fetching trades to score", "wallet", wallet, "error", err)
+		return
+	}
+	ids := make([]string, 0, len(trades))
+	for _, t := range trades {
+		ids = append(ids, t.ConditionID)
+	}
+	resolutions, err := s.resolutions.Resolutions(ctx, ids)
+	if err != nil {
+		confidenceLog.ErrorContext(ctx, "Error looking up resolutions to score trades", "wallet", wallet, "error", err)
+	}
+	scores := ScoreTrades(trades, resolutions)
+	p.TradeBrierScore = scores.Brier
+	p.TradeLogScore = scores.Log
+	p.TimeWeightedBrierScore = scores.TimeWeightedBrier
+	p.TimeWeightedLogScore = scores.TimeWeightedLog
+	p.ResolvedTrades = scores.Trades
+}
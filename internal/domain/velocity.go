@@ -0,0 +1,182 @@
+package domain
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/FatwaArya/pm-ingest/pkg/clock"
+	"github.com/FatwaArya/pm-ingest/pkg/rtds"
+)
+
+// velocityBuckets one-minute buckets make up the hourly window
+const velocityBuckets = 60
+
+// BurstRule flags a wallet as bursting when it reaches either threshold
+// within the last minute, or trades at Factor times its hourly per-minute
+// average (with at least MinTrades in the minute). Zero disables a condition.
+type BurstRule struct {
+	Trades    int
+	Notional  float64
+	Factor    float64
+	MinTrades int
+}
+
+// DefaultBurstRule catches both bots (many small fills) and whales building
+// a position quickly (large notional)
+var DefaultBurstRule = BurstRule{
+	Trades:    20,
+	Notional:  50000,
+	Factor:    5,
+	MinTrades: 5,
+}
+
+// WalletVelocity is a wallet's rolling trade rate. "Last minute" is the
+// current clock minute, "last hour" the current and previous 59 minutes.
+type WalletVelocity struct {
+	TradesLastMinute   int       `json:"tradesLastMinute"`
+	TradesLastHour     int       `json:"tradesLastHour"`
+	NotionalLastMinute float64   `json:"notionalLastMinute"`
+	NotionalLastHour   float64   `json:"notionalLastHour"`
+	Burst              bool      `json:"burst"`
+	LastBurst          time.Time `json:"lastBurst,omitempty"`
+}
+
+type velocityBucket struct {
+	minute   int64 // Unix minute the bucket holds
+	trades   int
+	notional float64
+}
+
+type walletWindow struct {
+	buckets   [velocityBuckets]velocityBucket
+	lastBurst time.Time
+	lastSeen  time.Time
+}
+
+// VelocityTracker keeps per-wallet trade counts and notional over the last
+// minute and hour, bucketed by trade time
+type VelocityTracker struct {
+	mu        sync.Mutex
+	clock     clock.Clock
+	rule      BurstRule
+	wallets   map[string]*walletWindow
+	lastSweep time.Time
+}
+
+// NewVelocityTracker creates a tracker flagging bursts with rule
+func NewVelocityTracker(rule BurstRule) *VelocityTracker {
+	return &VelocityTracker{
+		clock:     clock.Real,
+		rule:      rule,
+		wallets:   make(map[string]*walletWindow),
+		lastSweep: time.Now(),
+	}
+}
+
+// SetClock replaces the clock the windows are measured against
+func (v *VelocityTracker) SetClock(c clock.Clock) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.clock = clock.OrReal(c)
+	v.lastSweep = v.clock.Now()
+}
+
+// Record adds a trade to its wallet's window
+func (v *VelocityTracker) Record(trade *rtds.ActivityTradePayload) {
+	v.RecordTrade(trade.ProxyWalletAddress, trade.Timestamp, trade.Size*trade.Price)
+}
+
+// RecordTrade adds a trade at timestamp (Unix seconds) to wallet's window.
+// Trades older than an hour (e.g. replays) are ignored.
+func (v *VelocityTracker) RecordTrade(wallet string, timestamp int64, notional float64) {
+	wallet = strings.ToLower(wallet)
+	if wallet == "" {
+		return
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	now := v.clock.Now()
+	v.sweep(now)
+
+	minute := timestamp / 60
+	if minute <= now.Unix()/60-velocityBuckets {
+		return
+	}
+	w, ok := v.wallets[wallet]
+	if !ok {
+		w = &walletWindow{}
+		v.wallets[wallet] = w
+	}
+	b := &w.buckets[minute%velocityBuckets]
+	if b.minute != minute {
+		*b = velocityBucket{minute: minute}
+	}
+	b.trades++
+	b.notional += notional
+	w.lastSeen = now
+
+	if v.rule.bursting(w.velocity(now)) {
+		w.lastBurst = now
+	}
+}
+
+// Velocity returns a wallet's current rolling rate
+func (v *VelocityTracker) Velocity(address string) (WalletVelocity, bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	w, ok := v.wallets[strings.ToLower(address)]
+	if !ok {
+		return WalletVelocity{}, false
+	}
+	now := v.clock.Now()
+	vel := w.velocity(now)
+	vel.Burst = v.rule.bursting(vel)
+	vel.LastBurst = w.lastBurst
+	return vel, true
+}
+
+func (w *walletWindow) velocity(now time.Time) WalletVelocity {
+	current := now.Unix() / 60
+	var vel WalletVelocity
+	for _, b := range w.buckets {
+		if b.trades == 0 || b.minute <= current-velocityBuckets || b.minute > current {
+			continue
+		}
+		vel.TradesLastHour += b.trades
+		vel.NotionalLastHour += b.notional
+		if b.minute == current {
+			vel.TradesLastMinute += b.trades
+			vel.NotionalLastMinute += b.notional
+		}
+	}
+	return vel
+}
+
+func (r BurstRule) bursting(vel WalletVelocity) bool {
+	if r.Trades > 0 && vel.TradesLastMinute >= r.Trades {
+		return true
+	}
+	if r.Notional > 0 && vel.NotionalLastMinute >= r.Notional {
+		return true
+	}
+	if r.Factor > 0 && vel.TradesLastMinute >= r.MinTrades {
+		avg := float64(vel.TradesLastHour) / velocityBuckets
+		return float64(vel.TradesLastMinute) >= r.Factor*avg
+	}
+	return false
+}
+
+// sweep drops wallets without trades in the last hour; called with mu held
+func (v *VelocityTracker) sweep(now time.Time) {
+	if now.Sub(v.lastSweep) < flowSweepInterval {
+		return
+	}
+	v.lastSweep = now
+	for wallet, w := range v.wallets {
+		if now.Sub(w.lastSeen) > velocityBuckets*time.Minute {
+			delete(v.wallets, wallet)
+		}
+	}
+}
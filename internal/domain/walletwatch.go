@@ -0,0 +1,176 @@
+package domain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/FatwaArya/pm-ingest/pkg/clock"
+	"github.com/FatwaArya/pm-ingest/pkg/pmerrors"
+)
+
+var walletAddress = regexp.MustCompile(`^0x[0-9a-f]{40}$`)
+
+// WatchedWallet is a wallet on the wallet watchlist
+type WatchedWallet struct {
+	Address   string    `json:"address"`
+	Labels    []string  `json:"labels,omitempty"`
+	Note      string    `json:"note,omitempty"`
+	AddedAt   time.Time `json:"addedAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// WalletWatchlist is the set of wallets tracked on purpose, in addition to
+// the ones discovery finds by trade size: discovery profiles every trade of
+// a watched wallet and the signal service signals it. The list is saved to
+// a JSON file on every change, so it survives restarts.
+type WalletWatchlist struct {
+	path  string
+	clock clock.Clock
+
+	mu      sync.RWMutex
+	wallets map[string]WatchedWallet
+}
+
+// NewWalletWatchlist loads the watchlist saved at path, or starts an empty
+// one if there is no file yet. An empty path keeps the list in memory.
+func NewWalletWatchlist(path string) (*WalletWatchlist, error) {
+	w := &WalletWatchlist{
+		path:    path,
+		clock:   clock.Real,
+		wallets: make(map[string]WatchedWallet),
+	}
+	if path == "" {
+		return w, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return w, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read wallet watchlist: %w", err)
+	}
+	var wallets []WatchedWallet
+	if err := json.Unmarshal(data, &wallets); err != nil {
+		return nil, fmt.Errorf("failed to decode wallet watchlist %s: %w", path, err)
+	}
+	for _, wallet := range wallets {
+		w.wallets[strings.ToLower(wallet.Address)] = wallet
+	}
+	return w, nil
+}
+
+// SetClock replaces the clock entries are timestamped with
+func (w *WalletWatchlist) SetClock(c clock.Clock) {
+	w.clock = clock.OrReal(c)
+}
+
+// Put adds wallet to the watchlist, or replaces its labels and note if it
+// is already there, and returns the saved entry
+func (w *WalletWatchlist) Put(wallet WatchedWallet) (WatchedWallet, error) {
+	wallet.Address = strings.ToLower(strings.TrimSpace(wallet.Address))
+	if !walletAddress.MatchString(wallet.Address) {
+		return WatchedWallet{}, fmt.Errorf("%w: address must be a 0x-prefixed 20-byte hex address", pmerrors.ErrInvalidArgument)
+	}
+	now := w.clock.Now().UTC()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	previous, existed := w.wallets[wallet.Address]
+	wallet.AddedAt, wallet.UpdatedAt = now, now
+	if existed {
+		wallet.AddedAt = previous.AddedAt
+	}
+	w.wallets[wallet.Address] = wallet
+	if err := w.save(); err != nil {
+		if existed {
+			w.wallets[wallet.Address] = previous
+		} else {
+			delete(w.wallets, wallet.Address)
+		}
+		return WatchedWallet{}, err
+	}
+	return wallet, nil
+}
+
+// Remove takes a wallet off the watchlist and reports whether it was on it
+func (w *WalletWatchlist) Remove(address string) (bool, error) {
+	address = strings.ToLower(address)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	previous, ok := w.wallets[address]
+	if !ok {
+		return false, nil
+	}
+	delete(w.wallets, address)
+	if err := w.save(); err != nil {
+		w.wallets[address] = previous
+		return false, err
+	}
+	return true, nil
+}
+
+// Get returns the watchlist entry of a wallet
+func (w *WalletWatchlist) Get(address string) (WatchedWallet, bool) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	wallet, ok := w.wallets[strings.ToLower(address)]
+	return wallet, ok
+}
+
+// Contains reports whether a wallet is on the watchlist
+func (w *WalletWatchlist) Contains(address string) bool {
+	_, ok := w.Get(address)
+	return ok
+}
+
+// Wallets returns the watched wallets, most recently added first
+func (w *WalletWatchlist) Wallets() []WatchedWallet {
+	w.mu.RLock()
+	wallets := make([]WatchedWallet, 0, len(w.wallets))
+	for _, wallet := range w.wallets {
+		wallets = append(wallets, wallet)
+	}
+	w.mu.RUnlock()
+	slices.SortFunc(wallets, func(a, b WatchedWallet) int {
+		if c := b.AddedAt.Compare(a.AddedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Address, b.Address)
+	})
+	return wallets
+}
+
+// save writes the watchlist to its file, replacing it atomically; called
+// with mu held
+func (w *WalletWatchlist) save() error {
+	if w.path == "" {
+		return nil
+	}
+	wallets := make([]WatchedWallet, 0, len(w.wallets))
+	for _, wallet := range w.wallets {
+		wallets = append(wallets, wallet)
+	}
+	slices.SortFunc(wallets, func(a, b WatchedWallet) int { return strings.Compare(a.Address, b.Address) })
+	data, err := json.MarshalIndent(wallets, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(w.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create wallet watchlist directory: %w", err)
+	}
+	tmp := w.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to save wallet watchlist: %w", err)
+	}
+	if err := os.Rename(tmp, w.path); err != nil {
+		return fmt.Errorf("failed to save wallet watchlist: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package domain
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// WatchedMarket is a market on the watchlist
+type WatchedMarket struct {
+	ConditionID string    `json:"conditionId"`
+	Slug        string    `json:"slug,omitempty"`
+	Title       string    `json:"title,omitempty"`
+	Reason      string    `json:"reason"` // Why it was added, e.g. "new-market"
+	AddedAt     time.Time `json:"addedAt"`
+}
+
+// Watchlist is the set of markets flagged for closer attention
+type Watchlist struct {
+	mu      sync.RWMutex
+	markets map[string]WatchedMarket
+}
+
+// NewWatchlist creates an empty watchlist
+func NewWatchlist() *Watchlist {
+	return &Watchlist{markets: make(map[string]WatchedMarket)}
+}
+
+// Add puts market on the watchlist, keeping the original entry if it is
+// already there. It reports whether the market was added.
+func (w *Watchlist) Add(market WatchedMarket) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, ok := w.markets[market.ConditionID]; ok {
+		return false
+	}
+	if market.AddedAt.IsZero() {
+		market.AddedAt = time.Now()
+	}
+	w.markets[market.ConditionID] = market
+	return true
+}
+
+// Remove takes a market off the watchlist and reports whether it was on it
+func (w *Watchlist) Remove(conditionID string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, ok := w.markets[conditionID]
+	delete(w.markets, conditionID)
+	return ok
+}
+
+// Contains reports whether a market is on the watchlist
+func (w *Watchlist) Contains(conditionID string) bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	_, ok := w.markets[conditionID]
+	return ok
+}
+
+// Markets returns the watched markets, most recently added first
+func (w *Watchlist) Markets() []WatchedMarket {
+	w.mu.RLock()
+	markets := make([]WatchedMarket, 0, len(w.markets))
+	for _, m := range w.markets {
+		markets = append(markets, m)
+	}
+	w.mu.RUnlock()
+	slices.SortFunc(markets, func(a, b WatchedMarket) int {
+		return b.AddedAt.Compare(a.AddedAt)
+	})
+	return markets
+}
+
+// WatchNewMarkets adds every market detected by detector to the watchlist
+func (w *Watchlist) WatchNewMarkets(detector *MarketDetector) {
+	detector.OnNewMarket(func(m NewMarket) {
+		w.Add(WatchedMarket{ConditionID: m.ConditionID, Slug: m.Slug, Title: m.Title, Reason: "new-market"})
+	})
+}
@@ -0,0 +1,20 @@
+package domain
+
+import (
+	"context"
+
+	"github.com/FatwaArya/pm-ingest/internal/pipeline"
+)
+
+// spawn runs fn on pool, or on a goroutine of its own when pool is nil.
+// A job the pool refuses (ctx done or pool closed on shutdown) is skipped,
+// as is one OverflowDropOldest later discards: confidence is recalculated
+// on the wallet's next big trade, and profiles are retried on the next
+// trade of an unseen address.
+func spawn(ctx context.Context, pool *pipeline.Pool, fn func()) {
+	if pool == nil {
+		go fn()
+		return
+	}
+	_ = pool.Submit(ctx, fn)
+}
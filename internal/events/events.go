@@ -0,0 +1,150 @@
+// Package events publishes domain events (new markets, alerts, ...) for
+// downstream consumers.
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	internalkafka "github.com/FatwaArya/pm-ingest/internal/kafka"
+	"github.com/FatwaArya/pm-ingest/internal/logging"
+	"github.com/FatwaArya/pm-ingest/internal/schema"
+	"github.com/FatwaArya/pm-ingest/pkg/pmerrors"
+)
+
+// Event types
+const (
+	TypeMarketNew          = "market.new"
+	TypeMarketResolved     = "market.resolved"
+	TypeWalletSettlement   = "wallet.settlement"
+	TypeWalletConfidence   = "wallet.confidence"
+	TypeFillReconciliation = "fill.reconciliation"
+	TypeTradeSignal        = "trade.signal"
+)
+
+// Event is a JSON-encoded notification. Key groups related events (e.g. a
+// condition ID) and is used as the Kafka record key.
+type Event struct {
+	Type      string `json:"type"`
+	Key       string `json:"key"`
+	Timestamp int64  `json:"timestamp"` // Unix milliseconds
+	Data      any    `json:"data"`
+}
+
+// New creates an event of type stamped with the current time
+func New(eventType, key string, data any) Event {
+	return Event{Type: eventType, Key: key, Timestamp: time.Now().UnixMilli(), Data: data}
+}
+
+// eventRecord is an Event as schema-encoded: its data varies by type, so
+// it is kept as JSON
+type eventRecord struct {
+	Type      string `json:"type"`
+	Key       string `json:"key"`
+	Timestamp int64  `json:"timestamp"`
+	Data      string `json:"data"`
+}
+
+// EventSchema is the schema of events published with Avro or Protobuf
+var EventSchema = schema.MustNew("Event", 1, eventRecord{})
+
+// Emitter publishes events
+type Emitter interface {
+	Emit(ctx context.Context, event Event) error
+}
+
+// KafkaEmitter produces events to a Kafka topic
+type KafkaEmitter struct {
+	producer *internalkafka.Producer
+	topic    string
+}
+
+// NewKafkaEmitter produces events to topic through producer
+func NewKafkaEmitter(producer *internalkafka.Producer, topic string) *KafkaEmitter {
+	return &KafkaEmitter{producer: producer, topic: topic}
+}
+
+func (e *KafkaEmitter) Emit(ctx context.Context, event Event) error {
+	value, err := encode(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+	return e.producer.Produce(ctx, e.topic, []byte(event.Key), value)
+}
+
+// encode serializes event in the configured format
+func encode(event Event) ([]byte, error) {
+	if internalkafka.Format() == schema.FormatJSON {
+		return json.Marshal(event)
+	}
+	data, err := json.Marshal(event.Data)
+	if err != nil {
+		return nil, err
+	}
+	return internalkafka.EncodeValue(EventSchema, &eventRecord{
+		Type:      event.Type,
+		Key:       event.Key,
+		Timestamp: event.Timestamp,
+		Data:      string(data),
+	})
+}
+
+// LogEmitter logs events, for deployments without Kafka
+type LogEmitter struct{}
+
+var eventLog = logging.For("events")
+
+func (LogEmitter) Emit(ctx context.Context, event Event) error {
+	data, err := json.Marshal(event.Data)
+	if err != nil {
+		return err
+	}
+	eventLog.InfoContext(ctx, "Event", "type", event.Type, "key", event.Key, "data", string(data))
+	return nil
+}
+
+// WebhookEmitter posts events as JSON to a URL
+type WebhookEmitter struct {
+	URL    string
+	Client *http.Client // nil uses a client with a 10s timeout
+}
+
+var webhookClient = &http.Client{Timeout: 10 * time.Second}
+
+func (w WebhookEmitter) Emit(ctx context.Context, event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := w.Client
+	if client == nil {
+		client = webhookClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err // Without the URL, which may hold a secret
+		}
+		return fmt.Errorf("failed to post event to webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return &pmerrors.APIError{StatusCode: resp.StatusCode, Body: string(body), URL: "webhook"}
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package fixtures
+
+import (
+	"embed"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// payloads holds captured WebSocket frames and clob_user payloads.
+// Files are named after what they contain; the extension is only a hint
+// (.json for JSON frames, .txt for plain-text frames such as "pong").
+//
+//go:embed payloads
+var payloads embed.FS
+
+const payloadDir = "payloads"
+
+// Fixture is a single captured payload
+type Fixture struct {
+	Name string // File name without extension, e.g. "activity_trade_buy"
+	Data []byte
+}
+
+// Names returns the names of all fixtures in sorted order
+func Names() []string {
+	entries, err := payloads.ReadDir(payloadDir)
+	if err != nil {
+		return nil
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		names = append(names, strings.TrimSuffix(entry.Name(), path.Ext(entry.Name())))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// All returns every fixture in the corpus
+func All() []Fixture {
+	names := Names()
+	all := make([]Fixture, 0, len(names))
+	for _, name := range names {
+		data, err := Load(name)
+		if err != nil {
+			continue
+		}
+		all = append(all, Fixture{Name: name, Data: data})
+	}
+	return all
+}
+
+// WithPrefix returns the fixtures whose name starts with prefix
+// (e.g. "activity_", "clob_user_")
+func WithPrefix(prefix string) []Fixture {
+	var matched []Fixture
+	for _, f := range All() {
+		if strings.HasPrefix(f.Name, prefix) {
+			matched = append(matched, f)
+		}
+	}
+	return matched
+}
+
+// Load returns the raw bytes of the named fixture
+func Load(name string) ([]byte, error) {
+	entries, err := payloads.ReadDir(payloadDir)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		if strings.TrimSuffix(entry.Name(), path.Ext(entry.Name())) == name {
+			return payloads.ReadFile(path.Join(payloadDir, entry.Name()))
+		}
+	}
+	return nil, fmt.Errorf("fixture %q not found", name)
+}
+
+// MustLoad is like Load but panics if the fixture does not exist
+func MustLoad(name string) []byte {
+	data, err := Load(name)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
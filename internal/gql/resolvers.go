@@ -0,0 +1,186 @@
+package gql
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	internalqdb "github.com/FatwaArya/pm-ingest/internal"
+	graphql "github.com/graph-gophers/graphql-go"
+	"github.com/graph-gophers/graphql-go/relay"
+)
+
+// maxLimit caps the rows any list field returns
+const maxLimit = 1000
+
+// Tables names the QuestDB tables the API reads
+type Tables struct {
+	Trades     string
+	Profiles   string
+	Confidence string
+}
+
+// NewHandler creates the GraphQL HTTP handler, resolving fields with queries
+// through client
+func NewHandler(client *internalqdb.QueryClient, tables Tables) (http.Handler, error) {
+	s, err := graphql.ParseSchema(schema, &Resolver{client: client, tables: tables},
+		graphql.MaxDepth(6))
+	if err != nil {
+		return nil, fmt.Errorf("invalid graphql schema: %w", err)
+	}
+	return &relay.Handler{Schema: s}, nil
+}
+
+// Resolver resolves the root query fields
+type Resolver struct {
+	client *internalqdb.QueryClient
+	tables Tables
+}
+
+// query runs sql and returns its rows
+func (r *Resolver) query(ctx context.Context, sql string) ([]internalqdb.Row, error) {
+	result, err := r.client.Query(ctx, sql)
+	if err != nil {
+		return nil, err
+	}
+	return result.Rows(), nil
+}
+
+// Trader resolves Query.trader
+func (r *Resolver) Trader(ctx context.Context, args struct{ Address string }) (*TraderResolver, error) {
+	return r.trader(ctx, args.Address)
+}
+
+func (r *Resolver) trader(ctx context.Context, address string) (*TraderResolver, error) {
+	rows, err := r.query(ctx, fmt.Sprintf(
+		"SELECT %s FROM %s WHERE lower(address) = %s ORDER BY timestamp DESC LIMIT 1",
+		profileColumns, r.tables.Profiles, internalqdb.Quote(strings.ToLower(address))))
+	if err != nil || len(rows) == 0 {
+		return nil, err
+	}
+	return &TraderResolver{root: r, row: rows[0]}, nil
+}
+
+// Traders resolves Query.traders
+func (r *Resolver) Traders(ctx context.Context, args struct{ Limit int32 }) ([]*TraderResolver, error) {
+	rows, err := r.query(ctx, fmt.Sprintf(
+		"SELECT %s FROM %s LATEST ON timestamp PARTITION BY address ORDER BY timestamp DESC LIMIT %d",
+		profileColumns, r.tables.Profiles, limit(args.Limit)))
+	if err != nil {
+		return nil, err
+	}
+	traders := make([]*TraderResolver, len(rows))
+	for i, row := range rows {
+		traders[i] = &TraderResolver{root: r, row: row}
+	}
+	return traders, nil
+}
+
+// tradeFilter narrows a trades query
+type tradeFilter struct {
+	Wallet *string
+	Market *string
+	From   *string
+	To     *string
+	Limit  int32
+}
+
+// Trades resolves Query.trades
+func (r *Resolver) Trades(ctx context.Context, args tradeFilter) ([]*TradeResolver, error) {
+	return r.trades(ctx, args)
+}
+
+func (r *Resolver) trades(ctx context.Context, f tradeFilter) ([]*TradeResolver, error) {
+	var where []string
+	if f.Wallet != nil {
+		where = append(where, "lower(proxy_wallet) = "+internalqdb.Quote(strings.ToLower(*f.Wallet)))
+	}
+	if f.Market != nil {
+		where = append(where, "condition_id = "+internalqdb.Quote(*f.Market))
+	}
+	timeWhere, err := timeRange(f.From, f.To)
+	if err != nil {
+		return nil, err
+	}
+	where = append(where, timeWhere...)
+
+	sql := fmt.Sprintf("SELECT %s FROM %s", tradeColumns, r.tables.Trades)
+	if len(where) > 0 {
+		sql += " WHERE " + strings.Join(where, " AND ")
+	}
+	sql += fmt.Sprintf(" ORDER BY timestamp DESC LIMIT %d", limit(f.Limit))
+
+	rows, err := r.query(ctx, sql)
+	if err != nil {
+		return nil, err
+	}
+	trades := make([]*TradeResolver, len(rows))
+	for i, row := range rows {
+		trades[i] = &TradeResolver{root: r, row: row}
+	}
+	return trades, nil
+}
+
+// Market resolves Query.market
+func (r *Resolver) Market(ctx context.Context, args struct{ ConditionId string }) (*MarketResolver, error) {
+	return r.market(ctx, args.ConditionId)
+}
+
+func (r *Resolver) market(ctx context.Context, conditionID string) (*MarketResolver, error) {
+	rows, err := r.query(ctx, fmt.Sprintf("SELECT %s FROM %s WHERE condition_id = %s GROUP BY condition_id",
+		marketColumns, r.tables.Trades, internalqdb.Quote(conditionID)))
+	if err != nil || len(rows) == 0 {
+		return nil, err
+	}
+	return &MarketResolver{root: r, row: rows[0]}, nil
+}
+
+// confidence returns a wallet's computed scores, newest first
+func (r *Resolver) confidence(ctx context.Context, wallet string, from, to *string, n int32) ([]*ConfidenceResolver, error) {
+	where := []string{"lower(wallet) = " + internalqdb.Quote(strings.ToLower(wallet))}
+	timeWhere, err := timeRange(from, to)
+	if err != nil {
+		return nil, err
+	}
+	where = append(where, timeWhere...)
+
+	rows, err := r.query(ctx, fmt.Sprintf("SELECT %s FROM %s WHERE %s ORDER BY timestamp DESC LIMIT %d",
+		confidenceColumns, r.tables.Confidence, strings.Join(where, " AND "), limit(n)))
+	if err != nil {
+		return nil, err
+	}
+	scores := make([]*ConfidenceResolver, len(rows))
+	for i, row := range rows {
+		scores[i] = &ConfidenceResolver{row: row}
+	}
+	return scores, nil
+}
+
+// limit clamps a requested row count to 1..maxLimit
+func limit(n int32) int {
+	if n <= 0 {
+		return 1
+	}
+	return min(int(n), maxLimit)
+}
+
+// timeRange returns the conditions selecting timestamps in [from, to)
+func timeRange(from, to *string) ([]string, error) {
+	var where []string
+	for _, bound := range []struct {
+		value *string
+		op    string
+	}{{from, ">="}, {to, "<"}} {
+		if bound.value == nil {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, *bound.value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid time %q: expected RFC 3339", *bound.value)
+		}
+		where = append(where, "timestamp "+bound.op+" "+internalqdb.QuoteTime(t))
+	}
+	return where, nil
+}
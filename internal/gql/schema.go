@@ -0,0 +1,86 @@
+// Package gql serves a GraphQL API over the data stored in QuestDB: trader
+// profiles, trades, markets (aggregated from trades) and confidence history.
+// Every field is resolved with its own query, so clients only pay for the
+// nested views they ask for.
+package gql
+
+// schema is the GraphQL schema. Timestamps are RFC 3339 strings.
+const schema = `
+schema {
+	query: Query
+}
+
+type Query {
+	# Latest profile of a wallet
+	trader(address: String!): Trader
+	# Most recently updated profiles
+	traders(limit: Int = 50): [Trader!]!
+	trades(wallet: String, market: String, from: String, to: String, limit: Int = 100): [Trade!]!
+	# Market by condition ID, aggregated from its trades
+	market(conditionId: String!): Market
+}
+
+type Trader {
+	address: String!
+	name: String!
+	pseudonym: String!
+	bio: String!
+	owner: String!
+	profileImage: String!
+	updatedAt: String!
+	trades(market: String, from: String, to: String, limit: Int = 100): [Trade!]!
+	# Markets the wallet traded, most volume first
+	markets(limit: Int = 20): [Market!]!
+	# Latest computed confidence
+	confidence: ConfidenceScore
+	# Computed confidence over time, newest first
+	confidenceHistory(from: String, to: String, limit: Int = 100): [ConfidenceScore!]!
+}
+
+type Trade {
+	transactionHash: String!
+	timestamp: String!
+	side: String!
+	outcome: String!
+	price: Float!
+	size: Float!
+	notional: Float!
+	role: String!
+	aggressorSide: String!
+	proxyWallet: String!
+	conditionId: String!
+	marketSlug: String!
+	trader: Trader
+	market: Market
+}
+
+type Market {
+	conditionId: String!
+	slug: String!
+	eventSlug: String!
+	title: String!
+	tradeCount: Int!
+	volume: Float!
+	firstTrade: String!
+	lastTrade: String!
+	trades(from: String, to: String, limit: Int = 100): [Trade!]!
+}
+
+type ConfidenceScore {
+	timestamp: String!
+	brierScore: Float!
+	calibration: Float!
+	winRate: Float!
+	confidenceInterval: Float!
+	sampleSize: Int!
+	avgRealizedPnl: Float!
+	totalRealizedPnl: Float!
+	pnlVolatility: Float!
+	sharpeRatio: Float!
+	maxDrawdown: Float!
+	# 0 without losses; see noLosses
+	profitFactor: Float!
+	noLosses: Boolean!
+	roi: Float!
+}
+`
@@ -0,0 +1,71 @@
+package gql
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	internalqdb "github.com/FatwaArya/pm-ingest/internal"
+)
+
+// ParseSchema checks every field against its resolver method
+func TestSchemaMatchesResolvers(t *testing.T) {
+	if _, err := NewHandler(internalqdb.NewQueryClient("localhost:9000"), Tables{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTimeRange(t *testing.T) {
+	from, to := "2024-05-01T00:00:00Z", "2024-05-02T12:30:00+02:00"
+	where, err := timeRange(&from, &to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"timestamp >= '2024-05-01T00:00:00.000000Z'",
+		"timestamp < '2024-05-02T10:30:00.000000Z'",
+	}
+	if len(where) != 2 || where[0] != want[0] || where[1] != want[1] {
+		t.Errorf("timeRange = %q, want %q", where, want)
+	}
+
+	bad := "yesterday"
+	if _, err := timeRange(&bad, nil); err == nil {
+		t.Error("timeRange accepted a non-RFC 3339 time")
+	}
+}
+
+// Addresses are stored as they arrive, so lookups match them in any case
+func TestTraderMatchesAnyCase(t *testing.T) {
+	var queries []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.Query().Get("query"))
+		json.NewEncoder(w).Encode(internalqdb.QueryResult{})
+	}))
+	defer srv.Close()
+	r := &Resolver{client: internalqdb.NewQueryClient(strings.TrimPrefix(srv.URL, "http://")), tables: Tables{Profiles: "profiles", Trades: "trades"}}
+
+	ctx := context.Background()
+	if _, err := r.trader(ctx, "0xAbC"); err != nil {
+		t.Fatal(err)
+	}
+	wallet := "0xAbC"
+	if _, err := r.trades(ctx, tradeFilter{Wallet: &wallet}); err != nil {
+		t.Fatal(err)
+	}
+	trader := &TraderResolver{root: r, row: internalqdb.Row{"address": "0xAbC"}}
+	if _, err := trader.Markets(ctx, struct{ Limit int32 }{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(queries) != 3 {
+		t.Fatalf("ran %d queries, want 3", len(queries))
+	}
+	for i, want := range []string{"lower(address) = '0xabc'", "lower(proxy_wallet) = '0xabc'", "lower(proxy_wallet) = '0xabc'"} {
+		if !strings.Contains(queries[i], want) {
+			t.Errorf("query %q doesn't contain %q", queries[i], want)
+		}
+	}
+}
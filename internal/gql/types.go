@@ -0,0 +1,157 @@
+package gql
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	internalqdb "github.com/FatwaArya/pm-ingest/internal"
+)
+
+// Columns selected for each type
+const (
+	profileColumns = "address, name, pseudonym, bio, owner, profile_image, timestamp"
+	tradeColumns   = "transaction_hash, timestamp, side, outcome, price, size, role, aggressor_side, " +
+		"proxy_wallet, condition_id, market_slug"
+	marketColumns = "condition_id, last(market_slug) market_slug, last(event_slug) event_slug, " +
+		"last(event_title) event_title, count() trades, sum(price * size) volume, " +
+		"min(timestamp) first_trade, max(timestamp) last_trade"
+	confidenceColumns = "timestamp, brier_score, calibration, win_rate, confidence_interval, " +
+		"sample_size, avg_realized_pnl, total_realized_pnl, pnl_volatility, sharpe_ratio, max_drawdown, " +
+		"profit_factor, no_losses, roi"
+)
+
+// TraderResolver resolves a profile row
+type TraderResolver struct {
+	root *Resolver
+	row  internalqdb.Row
+}
+
+func (t *TraderResolver) Address() string      { return t.row.String("address") }
+func (t *TraderResolver) Name() string         { return t.row.String("name") }
+func (t *TraderResolver) Pseudonym() string    { return t.row.String("pseudonym") }
+func (t *TraderResolver) Bio() string          { return t.row.String("bio") }
+func (t *TraderResolver) Owner() string        { return t.row.String("owner") }
+func (t *TraderResolver) ProfileImage() string { return t.row.String("profile_image") }
+func (t *TraderResolver) UpdatedAt() string    { return t.row.String("timestamp") }
+
+// Trades resolves the trader's trades
+func (t *TraderResolver) Trades(ctx context.Context, args struct {
+	Market *string
+	From   *string
+	To     *string
+	Limit  int32
+}) ([]*TradeResolver, error) {
+	wallet := t.Address()
+	return t.root.trades(ctx, tradeFilter{Wallet: &wallet, Market: args.Market, From: args.From, To: args.To, Limit: args.Limit})
+}
+
+// Markets resolves the markets the trader traded, by the trader's volume
+func (t *TraderResolver) Markets(ctx context.Context, args struct{ Limit int32 }) ([]*MarketResolver, error) {
+	rows, err := t.root.query(ctx, fmt.Sprintf(
+		"SELECT %s FROM %s WHERE lower(proxy_wallet) = %s GROUP BY condition_id ORDER BY volume DESC LIMIT %d",
+		marketColumns, t.root.tables.Trades, internalqdb.Quote(strings.ToLower(t.Address())), limit(args.Limit)))
+	if err != nil {
+		return nil, err
+	}
+	markets := make([]*MarketResolver, len(rows))
+	for i, row := range rows {
+		markets[i] = &MarketResolver{root: t.root, row: row}
+	}
+	return markets, nil
+}
+
+// Confidence resolves the trader's latest score
+func (t *TraderResolver) Confidence(ctx context.Context) (*ConfidenceResolver, error) {
+	scores, err := t.root.confidence(ctx, t.Address(), nil, nil, 1)
+	if err != nil || len(scores) == 0 {
+		return nil, err
+	}
+	return scores[0], nil
+}
+
+// ConfidenceHistory resolves the trader's scores over time
+func (t *TraderResolver) ConfidenceHistory(ctx context.Context, args struct {
+	From  *string
+	To    *string
+	Limit int32
+}) ([]*ConfidenceResolver, error) {
+	return t.root.confidence(ctx, t.Address(), args.From, args.To, args.Limit)
+}
+
+// TradeResolver resolves a trade row
+type TradeResolver struct {
+	root *Resolver
+	row  internalqdb.Row
+}
+
+func (t *TradeResolver) TransactionHash() string { return t.row.String("transaction_hash") }
+func (t *TradeResolver) Timestamp() string       { return t.row.String("timestamp") }
+func (t *TradeResolver) Side() string            { return t.row.String("side") }
+func (t *TradeResolver) Outcome() string         { return t.row.String("outcome") }
+func (t *TradeResolver) Price() float64          { return t.row.Float("price") }
+func (t *TradeResolver) Size() float64           { return t.row.Float("size") }
+func (t *TradeResolver) Notional() float64       { return t.Price() * t.Size() }
+func (t *TradeResolver) Role() string            { return t.row.String("role") }
+func (t *TradeResolver) AggressorSide() string   { return t.row.String("aggressor_side") }
+func (t *TradeResolver) ProxyWallet() string     { return t.row.String("proxy_wallet") }
+func (t *TradeResolver) ConditionId() string     { return t.row.String("condition_id") }
+func (t *TradeResolver) MarketSlug() string      { return t.row.String("market_slug") }
+
+// Trader resolves the profile of the trade's wallet
+func (t *TradeResolver) Trader(ctx context.Context) (*TraderResolver, error) {
+	return t.root.trader(ctx, t.ProxyWallet())
+}
+
+// Market resolves the trade's market
+func (t *TradeResolver) Market(ctx context.Context) (*MarketResolver, error) {
+	if t.ConditionId() == "" {
+		return nil, nil
+	}
+	return t.root.market(ctx, t.ConditionId())
+}
+
+// MarketResolver resolves a market aggregated from trades
+type MarketResolver struct {
+	root *Resolver
+	row  internalqdb.Row
+}
+
+func (m *MarketResolver) ConditionId() string { return m.row.String("condition_id") }
+func (m *MarketResolver) Slug() string        { return m.row.String("market_slug") }
+func (m *MarketResolver) EventSlug() string   { return m.row.String("event_slug") }
+func (m *MarketResolver) Title() string       { return m.row.String("event_title") }
+func (m *MarketResolver) TradeCount() int32   { return int32(m.row.Int("trades")) }
+func (m *MarketResolver) Volume() float64     { return m.row.Float("volume") }
+func (m *MarketResolver) FirstTrade() string  { return m.row.String("first_trade") }
+func (m *MarketResolver) LastTrade() string   { return m.row.String("last_trade") }
+
+// Trades resolves the market's trades
+func (m *MarketResolver) Trades(ctx context.Context, args struct {
+	From  *string
+	To    *string
+	Limit int32
+}) ([]*TradeResolver, error) {
+	market := m.ConditionId()
+	return m.root.trades(ctx, tradeFilter{Market: &market, From: args.From, To: args.To, Limit: args.Limit})
+}
+
+// ConfidenceResolver resolves a confidence score row
+type ConfidenceResolver struct {
+	row internalqdb.Row
+}
+
+func (c *ConfidenceResolver) Timestamp() string           { return c.row.String("timestamp") }
+func (c *ConfidenceResolver) BrierScore() float64         { return c.row.Float("brier_score") }
+func (c *ConfidenceResolver) Calibration() float64        { return c.row.Float("calibration") }
+func (c *ConfidenceResolver) WinRate() float64            { return c.row.Float("win_rate") }
+func (c *ConfidenceResolver) ConfidenceInterval() float64 { return c.row.Float("confidence_interval") }
+func (c *ConfidenceResolver) SampleSize() int32           { return int32(c.row.Int("sample_size")) }
+func (c *ConfidenceResolver) AvgRealizedPnl() float64     { return c.row.Float("avg_realized_pnl") }
+func (c *ConfidenceResolver) TotalRealizedPnl() float64   { return c.row.Float("total_realized_pnl") }
+func (c *ConfidenceResolver) PnlVolatility() float64      { return c.row.Float("pnl_volatility") }
+func (c *ConfidenceResolver) SharpeRatio() float64        { return c.row.Float("sharpe_ratio") }
+func (c *ConfidenceResolver) MaxDrawdown() float64        { return c.row.Float("max_drawdown") }
+func (c *ConfidenceResolver) ProfitFactor() float64       { return c.row.Float("profit_factor") }
+func (c *ConfidenceResolver) NoLosses() bool              { return c.row.Bool("no_losses") }
+func (c *ConfidenceResolver) ROI() float64                { return c.row.Float("roi") }
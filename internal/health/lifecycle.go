@@ -0,0 +1,263 @@
+package health
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/FatwaArya/pm-ingest/internal/logging"
+	"github.com/FatwaArya/pm-ingest/pkg/clock"
+	"github.com/gin-gonic/gin"
+)
+
+var healthLog = logging.For("health")
+
+// Check verifies that a dependency is reachable
+type Check func(ctx context.Context) error
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Lifecycle tracks the startup/readiness/drain state of the process and
+// exposes it through Kubernetes-style probe endpoints:
+//
+//	/startupz - 200 once all dependency checks have passed at least once
+//	/healthz  - 200 while the process is alive (liveness); 503 when a
+//	            liveness check fails, e.g. ingestion stalled, so it's restarted
+//	/readyz   - 200 while the process should receive traffic; flips to 503
+//	            as soon as shutdown begins so the pod is removed from endpoints.
+//	            Failing checks of tracked sinks report "degraded" instead of
+//	            503, since the pipeline keeps running on the remaining sinks.
+type Lifecycle struct {
+	mu       sync.RWMutex
+	clock    clock.Clock
+	checks   []namedCheck
+	liveness []namedCheck
+	statuses map[string]func() any
+	sinks    map[string]*SinkHealth
+	started  atomic.Bool
+	ready    atomic.Bool
+	draining atomic.Bool
+}
+
+// NewLifecycle creates a lifecycle in the not-started, not-ready state
+func NewLifecycle() *Lifecycle {
+	return &Lifecycle{clock: clock.Real}
+}
+
+// SetClock replaces the clock used for startup retries, the drain delay and
+// sinks tracked afterwards
+func (l *Lifecycle) SetClock(c clock.Clock) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.clock = clock.OrReal(c)
+}
+
+func (l *Lifecycle) getClock() clock.Clock {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.clock
+}
+
+// AddCheck registers a dependency check used by the startup probe and /readyz
+func (l *Lifecycle) AddCheck(name string, check Check) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.checks = append(l.checks, namedCheck{name: name, check: check})
+}
+
+// AddLivenessCheck registers a check of /healthz. Its failure means the
+// process is stuck and restarting it may help; a dependency being down
+// belongs in AddCheck instead.
+func (l *Lifecycle) AddLivenessCheck(name string, check Check) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.liveness = append(l.liveness, namedCheck{name: name, check: check})
+}
+
+// AddStatus reports what fn returns under name in the /healthz and /readyz
+// responses, e.g. connection state or consumer lag
+func (l *Lifecycle) AddStatus(name string, fn func() any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.statuses == nil {
+		l.statuses = make(map[string]func() any)
+	}
+	l.statuses[name] = fn
+}
+
+// Statuses returns the current value of every status
+func (l *Lifecycle) Statuses() map[string]any {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	statuses := make(map[string]any, len(l.statuses))
+	for name, fn := range l.statuses {
+		statuses[name] = fn()
+	}
+	return statuses
+}
+
+// RunChecks runs all dependency checks and returns the failures keyed by name
+func (l *Lifecycle) RunChecks(ctx context.Context) map[string]error {
+	l.mu.RLock()
+	checks := append([]namedCheck(nil), l.checks...)
+	l.mu.RUnlock()
+	return runChecks(ctx, checks)
+}
+
+// RunLivenessChecks runs the liveness checks and returns the failures
+// keyed by name
+func (l *Lifecycle) RunLivenessChecks(ctx context.Context) map[string]error {
+	l.mu.RLock()
+	checks := append([]namedCheck(nil), l.liveness...)
+	l.mu.RUnlock()
+	return runChecks(ctx, checks)
+}
+
+func runChecks(ctx context.Context, checks []namedCheck) map[string]error {
+	failures := make(map[string]error)
+	for _, c := range checks {
+		if err := c.check(ctx); err != nil {
+			failures[c.name] = err
+		}
+	}
+	return failures
+}
+
+// WaitForDependencies blocks until every check passes, retrying every interval.
+// On success the process is marked started and ready.
+func (l *Lifecycle) WaitForDependencies(ctx context.Context, interval time.Duration) error {
+	ticker := l.getClock().NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		checkCtx, cancel := context.WithTimeout(ctx, interval)
+		failures := l.RunChecks(checkCtx)
+		cancel()
+
+		if len(failures) == 0 {
+			l.started.Store(true)
+			l.ready.Store(true)
+			return nil
+		}
+		for name, err := range failures {
+			healthLog.WarnContext(ctx, "Startup check failed", "check", name, "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C():
+		}
+	}
+}
+
+// Started reports whether startup dependency checks have passed
+func (l *Lifecycle) Started() bool {
+	return l.started.Load()
+}
+
+// Ready reports whether the process should receive traffic
+func (l *Lifecycle) Ready() bool {
+	return l.ready.Load() && !l.draining.Load()
+}
+
+// Draining reports whether shutdown has begun
+func (l *Lifecycle) Draining() bool {
+	return l.draining.Load()
+}
+
+// BeginDrain flips readiness to not-ready and waits for delay so that the
+// orchestrator stops routing to this instance (matching a preStop hook or
+// endpoint propagation delay) before subsystems are torn down.
+func (l *Lifecycle) BeginDrain(delay time.Duration) {
+	if l.draining.Swap(true) {
+		return // Already draining
+	}
+	healthLog.Info("Readiness set to not-ready, draining", "delay", delay)
+	if delay > 0 {
+		<-l.getClock().NewTimer(delay).C()
+	}
+}
+
+// Register adds the probe endpoints to the router
+func (l *Lifecycle) Register(r gin.IRoutes) {
+	r.GET("/healthz", func(c *gin.Context) {
+		failures := l.RunLivenessChecks(c.Request.Context())
+		if len(failures) > 0 {
+			errs := make(gin.H, len(failures))
+			for name, err := range failures {
+				errs[name] = err.Error()
+			}
+			c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unhealthy", "checks": errs, "details": l.Statuses()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "ok", "details": l.Statuses()})
+	})
+
+	r.GET("/startupz", func(c *gin.Context) {
+		if !l.Started() {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"status": "starting"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "started"})
+	})
+
+	r.GET("/readyz", func(c *gin.Context) {
+		if l.Draining() {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"status": "draining"})
+			return
+		}
+		if !l.Ready() {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"status": "not ready"})
+			return
+		}
+
+		sinks := l.SinkStatuses()
+		degraded := false
+		for _, st := range sinks {
+			if st.State == SinkDegraded {
+				degraded = true
+			}
+		}
+
+		failures := l.RunChecks(c.Request.Context())
+		errs := make(gin.H, len(failures))
+		fatal := false
+		for name, err := range failures {
+			errs[name] = err.Error()
+			if _, tracked := sinks[name]; tracked {
+				degraded = true
+			} else {
+				fatal = true
+			}
+		}
+
+		details := l.Statuses()
+		switch {
+		case fatal:
+			c.JSON(http.StatusServiceUnavailable, gin.H{"status": "not ready", "checks": errs, "sinks": sinks, "details": details})
+		case degraded:
+			c.JSON(http.StatusOK, gin.H{"status": "degraded", "checks": errs, "sinks": sinks, "details": details})
+		default:
+			c.JSON(http.StatusOK, gin.H{"status": "ready", "sinks": sinks, "details": details})
+		}
+	})
+}
+
+// TCPCheck returns a check that succeeds if a TCP connection to addr can be opened
+func TCPCheck(addr string) Check {
+	return func(ctx context.Context) error {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+}
@@ -0,0 +1,174 @@
+package health
+
+import (
+	"sync"
+	"time"
+
+	"github.com/FatwaArya/pm-ingest/pkg/clock"
+)
+
+// Sink states
+const (
+	SinkHealthy  = "healthy"
+	SinkDegraded = "degraded"
+)
+
+// SinkHealth tracks the health of a single output (Kafka, QuestDB, ...).
+// After threshold consecutive failures the sink is marked degraded; callers
+// should then stop hammering it and only send a probe once per retryInterval
+// (see Allow). The first success afterwards marks it healthy again.
+// State changes are logged once instead of logging every failed write.
+type SinkHealth struct {
+	name          string
+	threshold     int
+	retryInterval time.Duration
+	clock         clock.Clock
+
+	mu                  sync.Mutex
+	degraded            bool
+	consecutiveFailures int
+	lastError           string
+	lastAttempt         time.Time
+	since               time.Time
+	failuresTotal       uint64
+	successesTotal      uint64
+	onRecover           []func()
+}
+
+// SinkStatus is a point-in-time view of a sink's health
+type SinkStatus struct {
+	State               string    `json:"state"`
+	Since               time.Time `json:"since"`
+	ConsecutiveFailures int       `json:"consecutiveFailures"`
+	LastError           string    `json:"lastError,omitempty"`
+	FailuresTotal       uint64    `json:"failuresTotal"`
+	SuccessesTotal      uint64    `json:"successesTotal"`
+}
+
+func newSinkHealth(name string, threshold int, retryInterval time.Duration, c clock.Clock) *SinkHealth {
+	if threshold <= 0 {
+		threshold = 1
+	}
+	return &SinkHealth{
+		name:          name,
+		threshold:     threshold,
+		retryInterval: retryInterval,
+		clock:         c,
+		since:         c.Now(),
+	}
+}
+
+// OnRecover registers a callback run (in its own goroutine) when the sink
+// goes from degraded back to healthy, e.g. to replay buffered data
+func (s *SinkHealth) OnRecover(fn func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onRecover = append(s.onRecover, fn)
+}
+
+// Allow reports whether a write should be attempted. Healthy sinks always
+// allow writes; degraded sinks allow one probe per retry interval.
+func (s *SinkHealth) Allow() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.degraded {
+		return true
+	}
+	if s.clock.Since(s.lastAttempt) < s.retryInterval {
+		return false
+	}
+	s.lastAttempt = s.clock.Now()
+	return true
+}
+
+// Degraded reports whether the sink is currently degraded
+func (s *SinkHealth) Degraded() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.degraded
+}
+
+// RecordSuccess marks a successful write
+func (s *SinkHealth) RecordSuccess() {
+	s.mu.Lock()
+	s.successesTotal++
+	s.consecutiveFailures = 0
+	if !s.degraded {
+		s.mu.Unlock()
+		return
+	}
+	s.degraded = false
+	s.lastError = ""
+	s.since = s.clock.Now()
+	callbacks := append([]func(){}, s.onRecover...)
+	s.mu.Unlock()
+
+	healthLog.Info("Sink recovered", "sink", s.name)
+	for _, fn := range callbacks {
+		go fn()
+	}
+}
+
+// RecordFailure marks a failed write
+func (s *SinkHealth) RecordFailure(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failuresTotal++
+	s.consecutiveFailures++
+	s.lastAttempt = s.clock.Now()
+	if err != nil {
+		s.lastError = err.Error()
+	}
+	if s.degraded || s.consecutiveFailures < s.threshold {
+		return
+	}
+	s.degraded = true
+	s.since = s.clock.Now()
+	healthLog.Warn("Sink degraded", "sink", s.name, "consecutive_failures", s.consecutiveFailures, "error", err)
+}
+
+// Status returns the current status of the sink
+func (s *SinkHealth) Status() SinkStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state := SinkHealthy
+	if s.degraded {
+		state = SinkDegraded
+	}
+	return SinkStatus{
+		State:               state,
+		Since:               s.since,
+		ConsecutiveFailures: s.consecutiveFailures,
+		LastError:           s.lastError,
+		FailuresTotal:       s.failuresTotal,
+		SuccessesTotal:      s.successesTotal,
+	}
+}
+
+// TrackSink registers a sink whose health is reported by /readyz.
+// Degraded sinks don't make the process not-ready (the pipeline keeps running
+// with the remaining sinks) but flip the reported status to "degraded".
+func (l *Lifecycle) TrackSink(name string, threshold int, retryInterval time.Duration) *SinkHealth {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.sinks == nil {
+		l.sinks = make(map[string]*SinkHealth)
+	}
+	if s, ok := l.sinks[name]; ok {
+		return s
+	}
+	s := newSinkHealth(name, threshold, retryInterval, l.clock)
+	l.sinks[name] = s
+	return s
+}
+
+// SinkStatuses returns the status of every tracked sink
+func (l *Lifecycle) SinkStatuses() map[string]SinkStatus {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	statuses := make(map[string]SinkStatus, len(l.sinks))
+	for name, s := range l.sinks {
+		statuses[name] = s.Status()
+	}
+	return statuses
+}
@@ -0,0 +1,6 @@
+// Package integration runs the ingest pipeline end to end against Redpanda
+// and QuestDB started with testcontainers. The tests need Docker and are
+// behind the integration build tag:
+//
+//	go test -tags integration ./internal/integration
+package integration
@@ -0,0 +1,32 @@
+//go:build integration
+
+package integration
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// withHash returns a copy of an activity trade frame with its transaction hash replaced
+func withHash(t *testing.T, frame []byte, hash string) []byte {
+	t.Helper()
+	var msg map[string]json.RawMessage
+	if err := json.Unmarshal(frame, &msg); err != nil {
+		t.Fatalf("unmarshal frame: %v", err)
+	}
+	var payload map[string]any
+	if err := json.Unmarshal(msg["payload"], &payload); err != nil {
+		t.Fatalf("unmarshal payload: %v", err)
+	}
+	payload["transactionHash"] = hash
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatalf("marshal payload: %v", err)
+	}
+	msg["payload"] = raw
+	out, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatalf("marshal frame: %v", err)
+	}
+	return out
+}
@@ -0,0 +1,239 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	internalkafka "github.com/FatwaArya/pm-ingest/internal/kafka"
+	"github.com/FatwaArya/pm-ingest/internal/pipeline"
+	"github.com/FatwaArya/pm-ingest/internal/sink"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/redpanda"
+	"github.com/testcontainers/testcontainers-go/wait"
+	"github.com/twmb/franz-go/pkg/kgo"
+
+	internalqdb "github.com/FatwaArya/pm-ingest/internal"
+)
+
+const (
+	redpandaImage = "docker.redpanda.com/redpandadata/redpanda:v24.2.4"
+	questdbImage  = "questdb/questdb:8.1.1"
+	// waitTimeout bounds polling for rows to land in Kafka or QuestDB
+	waitTimeout = 30 * time.Second
+)
+
+// harness holds the containers shared by every test in the package
+type harness struct {
+	brokers     string
+	questdbHost string
+	ilpPort     int
+	httpAddr    string
+}
+
+var env harness
+
+func TestMain(m *testing.M) {
+	ctx := context.Background()
+
+	rp, err := redpanda.Run(ctx, redpandaImage, redpanda.WithAutoCreateTopics())
+	if err != nil {
+		log.Fatalf("failed to start redpanda: %v", err)
+	}
+	brokers, err := rp.KafkaSeedBroker(ctx)
+	if err != nil {
+		log.Fatalf("failed to get redpanda broker: %v", err)
+	}
+
+	qdb, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image:        questdbImage,
+			ExposedPorts: []string{"9000/tcp", "9009/tcp"},
+			WaitingFor:   wait.ForHTTP("/").WithPort("9000/tcp").WithStartupTimeout(time.Minute),
+		},
+		Started: true,
+	})
+	if err != nil {
+		log.Fatalf("failed to start questdb: %v", err)
+	}
+	host, err := qdb.Host(ctx)
+	if err != nil {
+		log.Fatalf("failed to get questdb host: %v", err)
+	}
+	ilp, err := qdb.MappedPort(ctx, "9009/tcp")
+	if err != nil {
+		log.Fatalf("failed to get questdb ILP port: %v", err)
+	}
+	httpPort, err := qdb.MappedPort(ctx, "9000/tcp")
+	if err != nil {
+		log.Fatalf("failed to get questdb HTTP port: %v", err)
+	}
+
+	env = harness{
+		brokers:     brokers,
+		questdbHost: host,
+		ilpPort:     ilp.Int(),
+		httpAddr:    net.JoinHostPort(host, httpPort.Port()),
+	}
+
+	code := m.Run()
+
+	testcontainers.TerminateContainer(rp)
+	testcontainers.TerminateContainer(qdb)
+	if code != 0 {
+		log.Fatalf("integration tests failed")
+	}
+}
+
+// run is one pipeline wired to the Kafka and QuestDB sinks, writing to a
+// topic and table unique to the test
+type run struct {
+	topic    string
+	table    string
+	pipeline *pipeline.Pipeline
+	sinks    *sink.Fanout
+}
+
+func newRun(t *testing.T, middleware ...pipeline.Middleware) *run {
+	t.Helper()
+	ctx := context.Background()
+	name := fmt.Sprintf("it_%s_%d", sanitize(t.Name()), time.Now().UnixNano())
+
+	producer, err := internalkafka.NewProducer(env.brokers, name)
+	if err != nil {
+		t.Fatalf("NewProducer: %v", err)
+	}
+	qs, err := sink.NewQuestDBSink(ctx, env.questdbHost, env.ilpPort, sink.QuestDBTables{
+		Trades:   internalqdb.TableConfig{Name: name + "_trades"},
+		Profiles: internalqdb.TableConfig{Name: name + "_profiles"},
+	}, nil, nil)
+	if err != nil {
+		t.Fatalf("NewQuestDBSink: %v", err)
+	}
+	sinks := sink.NewFanout(sink.NewKafkaSink(producer, nil), qs)
+	t.Cleanup(func() { sinks.Close(context.Background()) })
+
+	logger := testLogger{t}
+	p := pipeline.NewIngest(middleware, sinks.WriteTrade, logger, logger)
+	p.Start(ctx)
+	return &run{topic: name, table: name + "_trades", pipeline: p, sinks: sinks}
+}
+
+// submit queues raw WebSocket messages
+func (r *run) submit(t *testing.T, messages ...[]byte) {
+	t.Helper()
+	for _, m := range messages {
+		if err := r.pipeline.Submit(context.Background(), m); err != nil {
+			t.Fatalf("Submit: %v", err)
+		}
+	}
+}
+
+// drain closes the pipeline, as on shutdown, and flushes the sinks
+func (r *run) drain(t *testing.T) {
+	t.Helper()
+	r.pipeline.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), waitTimeout)
+	defer cancel()
+	if err := r.sinks.Flush(ctx); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+}
+
+// kafkaCount waits until want records are on the topic (or the timeout
+// passes) and returns how many there are
+func (r *run) kafkaCount(t *testing.T, want int) int {
+	t.Helper()
+	cl, err := kgo.NewClient(
+		kgo.SeedBrokers(env.brokers),
+		kgo.ConsumeTopics(r.topic),
+		kgo.ConsumeResetOffset(kgo.NewOffset().AtStart()),
+	)
+	if err != nil {
+		t.Fatalf("kgo.NewClient: %v", err)
+	}
+	defer cl.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), waitTimeout)
+	defer cancel()
+	got := 0
+	for got < want {
+		fetches := cl.PollFetches(ctx)
+		if ctx.Err() != nil {
+			break
+		}
+		got += fetches.NumRecords()
+	}
+	// Catch records beyond want
+	extraCtx, extraCancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer extraCancel()
+	got += cl.PollFetches(extraCtx).NumRecords()
+	return got
+}
+
+// questdbCount waits until the trade table has want rows (or the timeout
+// passes) and returns its row count
+func (r *run) questdbCount(t *testing.T, want int) int {
+	t.Helper()
+	deadline := time.Now().Add(waitTimeout)
+	got := -1
+	for time.Now().Before(deadline) {
+		got = queryCount(t, r.table)
+		if got >= want {
+			return got
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+	return got
+}
+
+// queryCount returns the row count of table, or -1 while it doesn't exist yet
+func queryCount(t *testing.T, table string) int {
+	t.Helper()
+	q := url.Values{"query": {"select count() from '" + table + "'"}}
+	resp, err := http.Get("http://" + env.httpAddr + "/exec?" + q.Encode())
+	if err != nil {
+		t.Fatalf("questdb query: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return -1
+	}
+	var result struct {
+		Dataset [][]json.Number `json:"dataset"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil || len(result.Dataset) == 0 {
+		return -1
+	}
+	n, err := strconv.Atoi(result.Dataset[0][0].String())
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+type testLogger struct{ t *testing.T }
+
+func (l testLogger) Printf(format string, args ...any) { l.t.Logf(format, args...) }
+
+func sanitize(name string) string {
+	out := make([]byte, 0, len(name))
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' {
+			out = append(out, c)
+		} else {
+			out = append(out, '_')
+		}
+	}
+	return string(out)
+}
@@ -0,0 +1,104 @@
+//go:build integration
+
+package integration
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/FatwaArya/pm-ingest/internal/fixtures"
+	"github.com/FatwaArya/pm-ingest/internal/pipeline"
+	"github.com/FatwaArya/pm-ingest/internal/store"
+	"github.com/FatwaArya/pm-ingest/pkg/rtds"
+)
+
+// activityFixtures are the recorded frames containing activity trades
+func activityFixtures() [][]byte {
+	var frames [][]byte
+	for _, f := range fixtures.All() {
+		frames = append(frames, f.Data)
+	}
+	return frames
+}
+
+// countTrades returns how many trades, and how many distinct fills, the
+// frames contain
+func countTrades(frames [][]byte) (trades, unique int) {
+	seen := make(map[string]bool)
+	for _, frame := range frames {
+		rtds.Dispatch(frame, rtds.HandlerFuncs{Trade: func(t *rtds.ActivityTradePayload) {
+			trades++
+			if !seen[t.DedupeKey()] {
+				seen[t.DedupeKey()] = true
+				unique++
+			}
+		}})
+	}
+	return trades, unique
+}
+
+func TestPipelineWritesEveryTrade(t *testing.T) {
+	frames := activityFixtures()
+	trades, _ := countTrades(frames)
+	if trades == 0 {
+		t.Fatal("no trades in fixtures")
+	}
+
+	r := newRun(t)
+	r.submit(t, frames...)
+	r.drain(t)
+
+	if got := r.kafkaCount(t, trades); got != trades {
+		t.Errorf("kafka records = %d, want %d", got, trades)
+	}
+	if got := r.questdbCount(t, trades); got != trades {
+		t.Errorf("questdb rows = %d, want %d", got, trades)
+	}
+}
+
+func TestPipelineDedupesReplayedFrames(t *testing.T) {
+	frames := activityFixtures()
+	// Every frame twice, as after a reconnect replay
+	replayed := append(append([][]byte{}, frames...), frames...)
+	_, unique := countTrades(replayed)
+
+	r := newRun(t, pipeline.Dedupe(store.NewMemoryStore(), time.Minute, nil))
+	r.submit(t, replayed...)
+	r.drain(t)
+
+	if got := r.kafkaCount(t, unique); got != unique {
+		t.Errorf("kafka records = %d, want %d", got, unique)
+	}
+	if got := r.questdbCount(t, unique); got != unique {
+		t.Errorf("questdb rows = %d, want %d", got, unique)
+	}
+	for _, s := range r.pipeline.Stats() {
+		if s.Name == "process" && int(s.Out) != unique {
+			t.Errorf("process stage out = %d, want %d", s.Out, unique)
+		}
+	}
+}
+
+func TestShutdownDrainsQueuedMessages(t *testing.T) {
+	buy := fixtures.MustLoad("activity_trade_buy")
+	const n = 2000
+
+	// Distinct transaction hashes so every trade is a separate fill
+	frames := make([][]byte, n)
+	for i := range frames {
+		frames[i] = withHash(t, buy, fmt.Sprintf("0xdrain%06d", i))
+	}
+
+	r := newRun(t)
+	r.submit(t, frames...)
+	// Close immediately: everything already queued must still be written
+	r.drain(t)
+
+	if got := r.kafkaCount(t, n); got != n {
+		t.Errorf("kafka records = %d, want %d", got, n)
+	}
+	if got := r.questdbCount(t, n); got != n {
+		t.Errorf("questdb rows = %d, want %d", got, n)
+	}
+}
@@ -2,48 +2,387 @@ package kafka
 
 import (
 	"context"
-	"log"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/FatwaArya/pm-ingest/internal/logging"
 	"github.com/twmb/franz-go/pkg/kgo"
 )
 
+// fetchErrLog limits fetch error logging while brokers are unreachable
+var fetchErrLog = logging.NewRateLimited(logging.For("kafka"), 5*time.Second)
+
+// handleErrLog limits record handler error logging
+var handleErrLog = logging.NewRateLimited(logging.For("kafka"), 5*time.Second)
+
 // Consumer is a simple Kafka consumer wrapper.
-// It is not wired into main yet; you can use it in a separate
-// service for notifications, analytics, etc.
 type Consumer struct {
-	client *kgo.Client
+	client  *kgo.Client
+	mode    CommitMode
+	workers int
+	key     func(*kgo.Record) []byte
+	dlq     *DeadLetterQueue
+
+	lagMu sync.Mutex
+	lag   map[int32]int64 // By partition
+}
+
+// CommitMode decides when a consumer commits the offsets of handled records
+type CommitMode string
+
+const (
+	// CommitAuto commits polled offsets periodically, whether or not their
+	// records were handled yet. A crash may lose or repeat records.
+	CommitAuto CommitMode = "auto"
+	// CommitOnSuccess commits synchronously as handlers succeed, up to the
+	// last record of the partition handled without a gap (see
+	// WithWorkers): at-least-once, at the cost of a round trip per commit
+	CommitOnSuccess CommitMode = "success"
+	// CommitBatched marks records as their handler succeeds and commits the
+	// marks periodically: at-least-once, with up to one commit interval of
+	// records repeated after a crash
+	CommitBatched CommitMode = "batched"
+)
+
+// ParseCommitMode parses a commit mode name
+func ParseCommitMode(s string) (CommitMode, error) {
+	switch mode := CommitMode(s); mode {
+	case CommitAuto, CommitOnSuccess, CommitBatched:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown commit mode %q", s)
+}
+
+type consumerConfig struct {
+	opts    []kgo.Opt
+	mode    CommitMode
+	workers int
+	key     func(*kgo.Record) []byte
+	dlq     *DeadLetterQueue
+}
+
+// ConsumerOption configures a consumer
+type ConsumerOption func(*consumerConfig)
+
+// WithManualCommit disables offset autocommit; RunBatches commits each
+// batch once it is handled
+func WithManualCommit() ConsumerOption {
+	return func(c *consumerConfig) {
+		c.opts = append(c.opts, kgo.DisableAutoCommit())
+	}
+}
+
+// WithCommitMode sets when Run commits offsets (CommitAuto by default)
+func WithCommitMode(mode CommitMode) ConsumerOption {
+	return func(c *consumerConfig) {
+		c.mode = mode
+		switch mode {
+		case CommitOnSuccess:
+			c.opts = append(c.opts, kgo.DisableAutoCommit())
+		case CommitBatched:
+			c.opts = append(c.opts, kgo.AutoCommitMarks())
+		}
+	}
+}
+
+// WithWorkers has Run handle records on n goroutines. Records with the same
+// ordering key (see WithOrderingKey) are handled in order by one of them,
+// records of different keys in parallel, and Run keeps polling while they
+// are handled. A partition's offsets are committed up to its first record
+// still being handled. n <= 1 handles records one at a time.
+func WithWorkers(n int) ConsumerOption {
+	return func(c *consumerConfig) {
+		c.workers = n
+	}
+}
+
+// WithOrderingKey sets the key that decides which records WithWorkers
+// keeps in order in Run, e.g. a header when records are keyed by something
+// else. By default it is the record key; unkeyed records are kept in order
+// per partition. RunTrades orders by proxy wallet instead.
+func WithOrderingKey(key func(*kgo.Record) []byte) ConsumerOption {
+	return func(c *consumerConfig) {
+		c.key = key
+	}
+}
+
+// WithDeadLetter has Run send records it gives up on (see Run) to q
+// rather than only logging them
+func WithDeadLetter(q *DeadLetterQueue) ConsumerOption {
+	return func(c *consumerConfig) {
+		c.dlq = q
+	}
 }
 
 // NewConsumer creates a new consumer subscribed to the given topic.
-func NewConsumer(brokers string, topic string, groupID string) (*Consumer, error) {
-	opts := []kgo.Opt{
-		kgo.SeedBrokers(brokers),
-		kgo.ConsumerGroup(groupID),
-		kgo.ConsumeTopics(topic),
+func NewConsumer(brokers string, topic string, groupID string, options ...ConsumerOption) (*Consumer, error) {
+	cfg := consumerConfig{
+		opts: append(ClientOpts(brokers),
+			kgo.ConsumerGroup(groupID),
+			kgo.ConsumeTopics(topic),
+		),
+		mode: CommitAuto,
+	}
+	for _, o := range options {
+		o(&cfg)
 	}
 
-	cl, err := kgo.NewClient(opts...)
+	cl, err := kgo.NewClient(cfg.opts...)
 	if err != nil {
 		return nil, err
 	}
 
-	return &Consumer{client: cl}, nil
+	key := cfg.key
+	if key == nil {
+		key = func(r *kgo.Record) []byte { return r.Key }
+	}
+	return &Consumer{client: cl, mode: cfg.mode, workers: cfg.workers, key: key, dlq: cfg.dlq, lag: make(map[int32]int64)}, nil
+}
+
+// CommitMode returns when the consumer commits offsets
+func (c *Consumer) CommitMode() CommitMode {
+	return c.mode
+}
+
+// recordLag updates the per-partition lag from a poll: records between the
+// last one fetched and the high watermark
+func (c *Consumer) recordLag(fetches kgo.Fetches) {
+	c.lagMu.Lock()
+	defer c.lagMu.Unlock()
+	fetches.EachPartition(func(p kgo.FetchTopicPartition) {
+		if len(p.Records) == 0 {
+			return
+		}
+		last := p.Records[len(p.Records)-1].Offset
+		c.lag[p.Partition] = max(p.HighWatermark-last-1, 0)
+	})
 }
 
-// Run starts a basic poll loop and passes records to the handler.
-func (c *Consumer) Run(ctx context.Context, handler func(*kgo.Record)) error {
+// Lag returns how many records the consumer is behind, summed over its
+// partitions, as of the last poll
+func (c *Consumer) Lag() int64 {
+	c.lagMu.Lock()
+	defer c.lagMu.Unlock()
+	var total int64
+	for _, lag := range c.lag {
+		total += lag
+	}
+	return total
+}
+
+// permanentError marks a record its handler can never handle
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps a handler error for a record that will never succeed,
+// e.g. one that doesn't decode. Run gives up on it (see Run) instead of
+// retrying the record.
+func Permanent(err error) error {
+	return permanentError{err: err}
+}
+
+// Run starts a basic poll loop and passes records to the handler until ctx
+// is cancelled or the client is closed. The handler receives ctx so work it
+// spawns is cancelled along with the consumer; ctx also carries the
+// record's envelope (see EnvelopeFrom).
+//
+// With CommitAuto, records whose handler fails are logged and skipped.
+// Otherwise a failed record is retried with backoff, up to
+// maxRecordAttempts times, and its offset is only committed once it
+// succeeds or Run gives up on it. A record that fails permanently or runs
+// out of attempts goes to the dead-letter queue (see WithDeadLetter), so
+// one bad record can't stall its partition. With WithWorkers, offsets are
+// committed as described there.
+func (c *Consumer) Run(ctx context.Context, handler func(context.Context, *kgo.Record) error) error {
+	return c.run(ctx, func(r *kgo.Record) job {
+		return job{record: r, key: c.key(r), handle: func(ctx context.Context) error { return handler(ctx, r) }}
+	})
+}
+
+// RunTrades is Run for trade records: each record is decoded once and
+// handler gets the trade. With WithWorkers, trades are kept in order per
+// proxy wallet, whatever their record key. Records that don't decode are
+// logged and skipped.
+func (c *Consumer) RunTrades(ctx context.Context, handler func(context.Context, TradeMessage) error) error {
+	return c.run(ctx, func(r *kgo.Record) job {
+		trade, err := DecodeTrade(r.Value)
+		if err != nil {
+			err = Permanent(fmt.Errorf("failed to decode trade message: %w", err))
+			return job{record: r, key: r.Key, handle: func(context.Context) error { return err }}
+		}
+		key := r.Key
+		if trade.ProxyWallet != "" {
+			key = []byte(strings.ToLower(trade.ProxyWallet))
+		}
+		return job{record: r, key: key, handle: func(ctx context.Context) error { return handler(ctx, trade) }}
+	})
+}
+
+// job is a polled record ready to be handled, with its ordering key
+type job struct {
+	record *kgo.Record
+	key    []byte
+	handle func(context.Context) error
+}
+
+// run polls and handles records, prepared into jobs by prepare
+func (c *Consumer) run(ctx context.Context, prepare func(*kgo.Record) job) (err error) {
+	var l *lanes
+	if c.workers > 1 {
+		l = c.startLanes(ctx, c.commit)
+		defer func() {
+			if laneErr := l.stop(); err == nil {
+				err = laneErr
+			}
+		}()
+	}
 	for {
 		fetches := c.client.PollFetches(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if fetches.IsClientClosed() {
+			return nil
+		}
 		if errs := fetches.Errors(); len(errs) > 0 {
 			for _, e := range errs {
-				log.Printf("Kafka fetch error: %v", e)
+				fetchErrLog.Printf("Kafka fetch error: %v", e)
+			}
+		}
+		c.recordLag(fetches)
+		records := fetches.Records()
+		jobs := make([]job, len(records))
+		for i, r := range records {
+			jobs[i] = prepare(r)
+		}
+		if l != nil {
+			if err := l.dispatch(ctx, jobs); err != nil {
+				return err
 			}
+			continue
 		}
-		fetches.EachRecord(func(r *kgo.Record) {
-			if handler != nil {
-				handler(r)
+		for _, j := range jobs {
+			if err := c.process(ctx, j); err != nil {
+				return err
 			}
-		})
+			c.commit(ctx, j.record)
+		}
+	}
+}
+
+// process handles j per the commit mode. It only returns an error when
+// ctx is cancelled while retrying.
+func (c *Consumer) process(ctx context.Context, j job) error {
+	r := j.record
+	ctx = logging.WithAttrs(ctx, logging.KeyTopic, r.Topic, "partition", r.Partition, "offset", r.Offset)
+	ctx = WithEnvelope(ctx, ParseEnvelope(r))
+	ctx, span := consumeSpan(ctx, r)
+	defer span.End()
+	if c.mode == CommitAuto {
+		if err := j.handle(ctx); err != nil {
+			span.RecordError(err)
+			handleErrLog.PrintfContext(ctx, "Error handling record %s/%d@%d: %v", r.Topic, r.Partition, r.Offset, err)
+		}
+		return nil
+	}
+
+	delay := batchRetryBase
+	for attempt := 1; ; attempt++ {
+		err := j.handle(ctx)
+		if err == nil {
+			return nil
+		}
+		span.RecordError(err)
+		var permanent permanentError
+		if errors.As(err, &permanent) || attempt == maxRecordAttempts {
+			c.giveUp(ctx, r, err)
+			return nil
+		}
+		handleErrLog.PrintfContext(ctx, "Error handling record %s/%d@%d, retrying in %s: %v", r.Topic, r.Partition, r.Offset, delay, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, batchRetryMax)
+	}
+}
+
+// giveUp skips a record its handler failed on for good, dead-lettering it
+// if the consumer has a dead-letter queue
+func (c *Consumer) giveUp(ctx context.Context, r *kgo.Record, err error) {
+	if c.dlq == nil {
+		handleErrLog.PrintfContext(ctx, "Skipping record %s/%d@%d: %v", r.Topic, r.Partition, r.Offset, err)
+		return
+	}
+	handleErrLog.PrintfContext(ctx, "Dead-lettering record %s/%d@%d: %v", r.Topic, r.Partition, r.Offset, err)
+	c.dlq.PublishRecord(ctx, r, err)
+}
+
+// commit commits the offsets of handled records per the commit mode
+func (c *Consumer) commit(ctx context.Context, records ...*kgo.Record) {
+	switch c.mode {
+	case CommitBatched:
+		c.client.MarkCommitRecords(records...)
+	case CommitOnSuccess:
+		if err := c.client.CommitRecords(ctx, records...); err != nil {
+			fetchErrLog.Printf("Kafka commit error: %v", err)
+		}
+	}
+}
+
+// Backoff between attempts to handle a failed record or batch
+const (
+	batchRetryBase = time.Second
+	batchRetryMax  = 30 * time.Second
+)
+
+// maxRecordAttempts bounds the attempts Run makes at a failing record,
+// about a minute of backoff
+const maxRecordAttempts = 6
+
+// RunBatches polls like Run but passes each poll's records to handler as a
+// batch, retrying it with backoff until it succeeds, then commits the
+// batch's offsets. Records are handled at least once: after a crash the
+// uncommitted batch is consumed again. Use with WithManualCommit.
+func (c *Consumer) RunBatches(ctx context.Context, handler func(context.Context, []*kgo.Record) error) error {
+	for {
+		fetches := c.client.PollFetches(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if fetches.IsClientClosed() {
+			return nil
+		}
+		for _, e := range fetches.Errors() {
+			fetchErrLog.Printf("Kafka fetch error: %v", e)
+		}
+		c.recordLag(fetches)
+		records := fetches.Records()
+		if len(records) == 0 {
+			continue
+		}
+
+		for delay := batchRetryBase; ; delay = min(delay*2, batchRetryMax) {
+			err := handler(ctx, records)
+			if err == nil {
+				break
+			}
+			fetchErrLog.Printf("Error handling batch of %d records, retrying in %s: %v", len(records), delay, err)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(delay):
+			}
+		}
+		if err := c.client.CommitRecords(ctx, records...); err != nil {
+			fetchErrLog.Printf("Kafka commit error: %v", err)
+		}
 	}
 }
 
@@ -0,0 +1,173 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// Pipeline stages a dead letter can come from
+const (
+	DLQStageParse   = "parse"   // Raw WebSocket message that didn't parse
+	DLQStageSink    = "sink"    // JSON trade that no sink accepted
+	DLQStageConsume = "consume" // Consumed record its handler gave up on
+)
+
+// Dead-letter record headers carrying the failure metadata. The record
+// value is the raw payload, unchanged.
+const (
+	HeaderDLQStage    = "dlq-stage"
+	HeaderDLQError    = "dlq-error"
+	HeaderDLQFailedAt = "dlq-failed-at"
+	HeaderDLQTopic    = "dlq-topic" // Topic a consume stage record was read from
+)
+
+// maxDLQErrorLen bounds the error header
+const maxDLQErrorLen = 1024
+
+// DeadLetterQueue publishes messages the pipeline had to drop to a
+// dead-letter topic, so they can be inspected and replayed
+type DeadLetterQueue struct {
+	producer  *Producer
+	topic     string
+	published atomic.Uint64
+}
+
+// NewDeadLetterQueue creates a DLQ publishing to topic through producer
+func NewDeadLetterQueue(producer *Producer, topic string) *DeadLetterQueue {
+	return &DeadLetterQueue{producer: producer, topic: topic}
+}
+
+// Publish sends payload, dropped by stage because of cause, to the
+// dead-letter topic
+func (q *DeadLetterQueue) Publish(ctx context.Context, stage string, payload []byte, cause error) {
+	q.publish(ctx, stage, nil, payload, cause)
+}
+
+// PublishRecord sends r, consumed but not handled because of cause, to the
+// dead-letter topic with its key and topic, so replay-dlq can produce it
+// back there
+func (q *DeadLetterQueue) PublishRecord(ctx context.Context, r *kgo.Record, cause error) {
+	q.publish(ctx, DLQStageConsume, r.Key, r.Value, cause, kgo.RecordHeader{Key: HeaderDLQTopic, Value: []byte(r.Topic)})
+}
+
+func (q *DeadLetterQueue) publish(ctx context.Context, stage string, key, payload []byte, cause error, extra ...kgo.RecordHeader) {
+	msg := ""
+	if cause != nil {
+		msg = cause.Error()
+		if len(msg) > maxDLQErrorLen {
+			msg = msg[:maxDLQErrorLen]
+		}
+	}
+	headers := append(provenanceHeaders(),
+		kgo.RecordHeader{Key: HeaderDLQStage, Value: []byte(stage)},
+		kgo.RecordHeader{Key: HeaderDLQError, Value: []byte(msg)},
+		kgo.RecordHeader{Key: HeaderDLQFailedAt, Value: []byte(strconv.FormatInt(time.Now().UnixMilli(), 10))},
+	)
+	record := &kgo.Record{Topic: q.topic, Key: key, Value: payload, Headers: append(headers, extra...)}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), deliveryTimeout)
+	q.producer.client.Produce(ctx, record, func(record *kgo.Record, err error) {
+		cancel()
+		if err != nil {
+			produceErrLog.Printf("Kafka produce error on %s: %v", record.Topic, err)
+		}
+	})
+	q.published.Add(1)
+}
+
+// Published returns how many messages were sent to the dead-letter topic
+func (q *DeadLetterQueue) Published() uint64 {
+	return q.published.Load()
+}
+
+// DeadLetter is a record read back from the dead-letter topic
+type DeadLetter struct {
+	Stage     string
+	Error     string
+	FailedAt  time.Time
+	Topic     string // Source topic of a consume stage record
+	Key       []byte
+	Payload   []byte
+	Partition int32
+	Offset    int64
+}
+
+// ParseDeadLetter reads the failure metadata from a dead-letter record
+func ParseDeadLetter(r *kgo.Record) DeadLetter {
+	dl := DeadLetter{Key: r.Key, Payload: r.Value, Partition: r.Partition, Offset: r.Offset}
+	for _, h := range r.Headers {
+		switch h.Key {
+		case HeaderDLQStage:
+			dl.Stage = string(h.Value)
+		case HeaderDLQError:
+			dl.Error = string(h.Value)
+		case HeaderDLQTopic:
+			dl.Topic = string(h.Value)
+		case HeaderDLQFailedAt:
+			if ms, err := strconv.ParseInt(string(h.Value), 10, 64); err == nil {
+				dl.FailedAt = time.UnixMilli(ms)
+			}
+		}
+	}
+	return dl
+}
+
+// dlqIdleTimeout is how long ReplayDeadLetters and ReadTopic wait for more
+// records before deciding they've caught up with the topic
+const dlqIdleTimeout = 5 * time.Second
+
+// ReplayDeadLetters reads the dead-letter topic as consumer group groupID,
+// passing each record to handle and committing it once handled, until the
+// topic is drained, max records were handled (0 for no limit) or handle
+// fails. A failed record isn't committed, so the next replay starts with it.
+func ReplayDeadLetters(ctx context.Context, brokers, topic, groupID string, max int, handle func(context.Context, DeadLetter) error) (int, error) {
+	cl, err := kgo.NewClient(append(ClientOpts(brokers),
+		kgo.ConsumerGroup(groupID),
+		kgo.ConsumeTopics(topic),
+		kgo.ConsumeResetOffset(kgo.NewOffset().AtStart()),
+		kgo.DisableAutoCommit(),
+	)...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create kafka client: %w", err)
+	}
+	defer cl.Close()
+
+	replayed := 0
+	for max <= 0 || replayed < max {
+		pollCtx, cancel := context.WithTimeout(ctx, dlqIdleTimeout)
+		fetches := cl.PollFetches(pollCtx)
+		cancel()
+		if ctx.Err() != nil {
+			return replayed, ctx.Err()
+		}
+		for _, e := range fetches.Errors() {
+			if !errors.Is(e.Err, context.DeadlineExceeded) {
+				fetchErrLog.Printf("Kafka fetch error: %v", e.Err)
+			}
+		}
+		records := fetches.Records()
+		if len(records) == 0 {
+			return replayed, nil
+		}
+		for _, r := range records {
+			if max > 0 && replayed >= max {
+				break
+			}
+			dl := ParseDeadLetter(r)
+			if err := handle(ctx, dl); err != nil {
+				return replayed, fmt.Errorf("replaying %s record at %d/%d: %w", dl.Stage, dl.Partition, dl.Offset, err)
+			}
+			if err := cl.CommitRecords(ctx, r); err != nil {
+				return replayed, fmt.Errorf("failed to commit replayed record: %w", err)
+			}
+			replayed++
+		}
+	}
+	return replayed, nil
+}
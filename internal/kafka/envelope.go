@@ -0,0 +1,120 @@
+package kafka
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/FatwaArya/pm-ingest/internal/provenance"
+	"github.com/FatwaArya/pm-ingest/pkg/rtds"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// Record headers describing where a record came from, on top of the
+// provenance headers of the replica that produced it
+const (
+	HeaderSource        = "source"         // One of the Source constants
+	HeaderSchemaVersion = "schema_version" // Of the value's schema
+	HeaderIngestedAt    = "ingested_at"    // Unix milliseconds, when produced
+	HeaderConnectionID  = "connection_id"  // Of the WebSocket connection the message came on
+	HeaderWSTopic       = "ws_topic"       // Topic and type of the WebSocket message
+	HeaderWSType        = "ws_type"
+)
+
+// Sources of records
+const (
+	SourceLive     = "live"     // The WebSocket feed
+	SourceBackfill = "backfill" // The Data API, by backfill-trades
+	SourceReplay   = "replay"   // An archive or topic, by replay or replay-dlq
+)
+
+// Envelope is what the headers of a record say about it
+type Envelope struct {
+	Source        string
+	SchemaVersion int // 0 if not known
+	IngestedAt    time.Time
+	ConnectionID  string
+	WSTopic       string
+	WSType        string
+	InstanceID    string
+	Hostname      string
+	Version       string
+}
+
+// Live reports whether the record came from the live feed
+func (e Envelope) Live() bool {
+	return e.Source == SourceLive
+}
+
+// ParseEnvelope reads the headers of r. Records produced before the source
+// header existed count as live, unless marked with HeaderBackfill.
+func ParseEnvelope(r *kgo.Record) Envelope {
+	e := Envelope{Source: SourceLive}
+	for _, h := range r.Headers {
+		value := string(h.Value)
+		switch h.Key {
+		case HeaderSource:
+			e.Source = value
+		case HeaderBackfill:
+			if value == "true" && e.Source == SourceLive {
+				e.Source = SourceBackfill
+			}
+		case HeaderSchemaVersion:
+			e.SchemaVersion, _ = strconv.Atoi(value)
+		case HeaderIngestedAt:
+			if ms, err := strconv.ParseInt(value, 10, 64); err == nil {
+				e.IngestedAt = time.UnixMilli(ms)
+			}
+		case HeaderConnectionID:
+			e.ConnectionID = value
+		case HeaderWSTopic:
+			e.WSTopic = value
+		case HeaderWSType:
+			e.WSType = value
+		case provenance.HeaderInstanceID:
+			e.InstanceID = value
+		case provenance.HeaderHostname:
+			e.Hostname = value
+		case provenance.HeaderVersion:
+			e.Version = value
+		}
+	}
+	return e
+}
+
+type envelopeKey struct{}
+
+// WithEnvelope returns ctx carrying the envelope of the record being handled
+func WithEnvelope(ctx context.Context, e Envelope) context.Context {
+	return context.WithValue(ctx, envelopeKey{}, e)
+}
+
+// EnvelopeFrom returns the envelope of the record ctx was passed to a
+// handler with, and whether there is one
+func EnvelopeFrom(ctx context.Context) (Envelope, bool) {
+	e, ok := ctx.Value(envelopeKey{}).(Envelope)
+	return e, ok
+}
+
+// tradeHeaders stamps a trade record with its source and, for trades
+// parsed from a WebSocket message, the message's envelope
+func tradeHeaders(source string, trade *rtds.ActivityTradePayload) []kgo.RecordHeader {
+	headers := append(provenanceHeaders(),
+		kgo.RecordHeader{Key: HeaderSource, Value: []byte(source)},
+		kgo.RecordHeader{Key: HeaderSchemaVersion, Value: []byte(strconv.Itoa(TradeSchema.Version))},
+		kgo.RecordHeader{Key: HeaderIngestedAt, Value: []byte(strconv.FormatInt(time.Now().UnixMilli(), 10))},
+	)
+	if trade == nil {
+		return headers
+	}
+	for _, h := range []kgo.RecordHeader{
+		{Key: HeaderConnectionID, Value: []byte(trade.Envelope.ConnectionID)},
+		{Key: HeaderWSTopic, Value: []byte(trade.Envelope.Topic)},
+		{Key: HeaderWSType, Value: []byte(trade.Envelope.Type)},
+	} {
+		if len(h.Value) > 0 {
+			headers = append(headers, h)
+		}
+	}
+	return headers
+}
@@ -0,0 +1,130 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// HandoverReady is announced by an instance once it receives live data
+const HandoverReady = "ready"
+
+// HandoverMessage is exchanged on the control topic during a planned restart
+type HandoverMessage struct {
+	Type       string `json:"type"`
+	InstanceID string `json:"instanceId"`
+	Timestamp  int64  `json:"timestamp"`
+}
+
+// Handover coordinates zero-gap restarts between an old and a new instance.
+//
+// The new instance connects and subscribes while the old one is still
+// ingesting, and once it has received live data it announces "ready" on the
+// control topic. Every running instance watches that topic and stops its own
+// ingestion when another instance announces ready. Trades received by both
+// during the overlap are removed by the shared dedupe store.
+type Handover struct {
+	client       *kgo.Client
+	topic        string
+	instanceID   string
+	startedAt    time.Time
+	onSuperseded func()
+	announceOnce sync.Once
+	stopOnce     sync.Once
+}
+
+// NewHandover creates a handover participant. onSuperseded is called once,
+// when a newer instance announces it is ready to take over.
+func NewHandover(brokers string, topic string, instanceID string, onSuperseded func()) (*Handover, error) {
+	opts := append(ClientOpts(brokers),
+		kgo.ConsumeTopics(topic),
+		kgo.ConsumeResetOffset(kgo.NewOffset().AtEnd()), // Only announcements made after we started matter
+		kgo.AllowAutoTopicCreation(),
+	)
+
+	cl, err := kgo.NewClient(opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kafka client: %w", err)
+	}
+
+	return &Handover{
+		client:       cl,
+		topic:        topic,
+		instanceID:   instanceID,
+		startedAt:    time.Now(),
+		onSuperseded: onSuperseded,
+	}, nil
+}
+
+// Run watches the control topic until ctx is cancelled
+func (h *Handover) Run(ctx context.Context) error {
+	for {
+		fetches := h.client.PollFetches(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if fetches.IsClientClosed() {
+			return nil
+		}
+		if errs := fetches.Errors(); len(errs) > 0 {
+			for _, e := range errs {
+				fetchErrLog.Printf("Handover fetch error: %v", e)
+			}
+		}
+		fetches.EachRecord(h.handle)
+	}
+}
+
+func (h *Handover) handle(record *kgo.Record) {
+	var msg HandoverMessage
+	if err := json.Unmarshal(record.Value, &msg); err != nil {
+		return
+	}
+	if msg.Type != HandoverReady || msg.InstanceID == h.instanceID {
+		return
+	}
+	// Ignore stale announcements from instances that started before us
+	if time.Unix(0, msg.Timestamp).Before(h.startedAt) {
+		return
+	}
+
+	h.stopOnce.Do(func() {
+		kafkaLog.Info("Handover: newer instance is live, stopping ingestion", "instance_id", msg.InstanceID)
+		if h.onSuperseded != nil {
+			h.onSuperseded()
+		}
+	})
+}
+
+// AnnounceReady tells older instances that this one is receiving live data.
+// Only the first call publishes; later calls are no-ops.
+func (h *Handover) AnnounceReady(ctx context.Context) {
+	h.announceOnce.Do(func() {
+		value, err := json.Marshal(HandoverMessage{
+			Type:       HandoverReady,
+			InstanceID: h.instanceID,
+			Timestamp:  time.Now().UnixNano(),
+		})
+		if err != nil {
+			return
+		}
+
+		record := &kgo.Record{Topic: h.topic, Key: []byte(h.instanceID), Value: value}
+		if err := h.client.ProduceSync(ctx, record).FirstErr(); err != nil {
+			kafkaLog.ErrorContext(ctx, "Handover: failed to announce ready", "error", err)
+			return
+		}
+		kafkaLog.InfoContext(ctx, "Handover: announced ready", "instance_id", h.instanceID)
+	})
+}
+
+// Close closes the control topic client
+func (h *Handover) Close() {
+	if h.client != nil {
+		h.client.Close()
+	}
+}
@@ -0,0 +1,168 @@
+package kafka
+
+import (
+	"context"
+	"encoding/binary"
+	"hash/fnv"
+	"sync"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// laneBuffer is how many jobs a lane queues before dispatching to it (and
+// so polling) waits. A slow key only holds up the other lanes once its own
+// lane is this far behind.
+const laneBuffer = 256
+
+// lanes handles jobs on a fixed set of goroutines that outlive a poll: jobs
+// with the same ordering key go to the same lane and are handled in order.
+// A partition's offsets are committed as far as its records are handled
+// without a gap, so a lane that is ahead can't commit past a record another
+// one is still handling.
+type lanes struct {
+	c      *Consumer
+	commit func(context.Context, ...*kgo.Record)
+	queues []chan job
+	wg     sync.WaitGroup
+
+	mu      sync.Mutex
+	pending map[topicPartition]*partitionOffsets
+
+	failOnce sync.Once
+	failed   chan struct{} // Closed once err is set
+	err      error
+}
+
+type topicPartition struct {
+	topic     string
+	partition int32
+}
+
+// partitionOffsets tracks a partition's dispatched records until they are
+// committed
+type partitionOffsets struct {
+	records []*kgo.Record // In offset order
+	done    map[int64]bool
+}
+
+// startLanes starts c.workers lanes handling jobs with ctx, committing
+// records with commit
+func (c *Consumer) startLanes(ctx context.Context, commit func(context.Context, ...*kgo.Record)) *lanes {
+	l := &lanes{
+		c:       c,
+		commit:  commit,
+		queues:  make([]chan job, c.workers),
+		pending: make(map[topicPartition]*partitionOffsets),
+		failed:  make(chan struct{}),
+	}
+	for i := range l.queues {
+		l.queues[i] = make(chan job, laneBuffer)
+		l.wg.Add(1)
+		go l.run(ctx, l.queues[i])
+	}
+	return l
+}
+
+// run handles a lane's jobs in order. After a failure it only drains the
+// queue, since Run is returning.
+func (l *lanes) run(ctx context.Context, queue chan job) {
+	defer l.wg.Done()
+	for j := range queue {
+		if ctx.Err() != nil || l.hasFailed() {
+			continue
+		}
+		if err := l.c.process(ctx, j); err != nil {
+			l.fail(err)
+			continue
+		}
+		l.complete(ctx, j.record)
+	}
+}
+
+// fail records the first error a lane failed with
+func (l *lanes) fail(err error) {
+	l.failOnce.Do(func() {
+		l.err = err
+		close(l.failed)
+	})
+}
+
+func (l *lanes) hasFailed() bool {
+	select {
+	case <-l.failed:
+		return true
+	default:
+		return false
+	}
+}
+
+// dispatch queues jobs on their lanes, waiting only while a lane's queue is
+// full. It returns the error a lane failed with, if any.
+func (l *lanes) dispatch(ctx context.Context, jobs []job) error {
+	for _, j := range jobs {
+		if l.hasFailed() {
+			return l.err
+		}
+		tp := topicPartition{j.record.Topic, j.record.Partition}
+		l.mu.Lock()
+		p := l.pending[tp]
+		if p == nil {
+			p = &partitionOffsets{done: make(map[int64]bool)}
+			l.pending[tp] = p
+		}
+		p.records = append(p.records, j.record)
+		l.mu.Unlock()
+
+		select {
+		case l.queues[l.lane(j)] <- j:
+		case <-l.failed:
+			return l.err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// complete marks r handled and commits its partition up to the last
+// record handled without a gap. Commits happen under the lock so they
+// can't be reordered.
+func (l *lanes) complete(ctx context.Context, r *kgo.Record) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	p := l.pending[topicPartition{r.Topic, r.Partition}]
+	p.done[r.Offset] = true
+	n := 0
+	for n < len(p.records) && p.done[p.records[n].Offset] {
+		delete(p.done, p.records[n].Offset)
+		n++
+	}
+	if n == 0 {
+		return
+	}
+	last := p.records[n-1]
+	p.records = p.records[n:]
+	l.commit(ctx, last)
+}
+
+// stop waits for the lanes to finish their queued jobs and returns the
+// error one failed with, if any
+func (l *lanes) stop() error {
+	for _, q := range l.queues {
+		close(q)
+	}
+	l.wg.Wait()
+	return l.err
+}
+
+// lane returns the lane handling j: its ordering key's hash, or its
+// partition's when it has no key
+func (l *lanes) lane(j job) int {
+	h := fnv.New32a()
+	if len(j.key) > 0 {
+		h.Write(j.key)
+	} else {
+		h.Write(binary.BigEndian.AppendUint32(nil, uint32(j.record.Partition)))
+	}
+	return int(h.Sum32() % uint32(len(l.queues)))
+}
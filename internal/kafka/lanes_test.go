@@ -0,0 +1,207 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// commits records the offsets lanes commit
+type commits struct {
+	mu      sync.Mutex
+	offsets []int64
+	added   chan struct{}
+}
+
+func newCommits() *commits {
+	return &commits{added: make(chan struct{}, 100)}
+}
+
+func (c *commits) commit(_ context.Context, records ...*kgo.Record) {
+	c.mu.Lock()
+	for _, r := range records {
+		c.offsets = append(c.offsets, r.Offset)
+	}
+	c.mu.Unlock()
+	c.added <- struct{}{}
+}
+
+// waitFor waits until offset was committed
+func (c *commits) waitFor(t *testing.T, offset int64) {
+	t.Helper()
+	deadline := time.After(5 * time.Second)
+	for {
+		c.mu.Lock()
+		n := len(c.offsets)
+		last := int64(-1)
+		if n > 0 {
+			last = c.offsets[n-1]
+		}
+		c.mu.Unlock()
+		if last >= offset {
+			return
+		}
+		select {
+		case <-c.added:
+		case <-deadline:
+			t.Fatalf("offset %d not committed, last commit %d", offset, last)
+		}
+	}
+}
+
+func (c *commits) snapshot() []int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]int64(nil), c.offsets...)
+}
+
+func testJob(offset int64, key string, handle func(context.Context) error) job {
+	return job{
+		record: &kgo.Record{Topic: "trades", Partition: 0, Offset: offset},
+		key:    []byte(key),
+		handle: handle,
+	}
+}
+
+func TestLanesKeepKeyOrder(t *testing.T) {
+	ctx := context.Background()
+	c := &Consumer{mode: CommitOnSuccess, workers: 4}
+	commits := newCommits()
+	l := c.startLanes(ctx, commits.commit)
+
+	var mu sync.Mutex
+	handled := make(map[string][]int64)
+	var jobs []job
+	for i := range 60 {
+		key := []string{"a", "b", "c"}[i%3]
+		offset := int64(i)
+		jobs = append(jobs, testJob(offset, key, func(context.Context) error {
+			mu.Lock()
+			handled[key] = append(handled[key], offset)
+			mu.Unlock()
+			return nil
+		}))
+	}
+	// Spread over polls, like Run dispatches them
+	for i := 0; i < len(jobs); i += 7 {
+		if err := l.dispatch(ctx, jobs[i:min(i+7, len(jobs))]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	commits.waitFor(t, 59)
+	if err := l.stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	for key, offsets := range handled {
+		if len(offsets) != 20 {
+			t.Errorf("handled %d records of %s, want 20", len(offsets), key)
+		}
+		for i := 1; i < len(offsets); i++ {
+			if offsets[i] < offsets[i-1] {
+				t.Errorf("%s handled out of order: %v", key, offsets)
+				break
+			}
+		}
+	}
+	got := commits.snapshot()
+	for i := 1; i < len(got); i++ {
+		if got[i] <= got[i-1] {
+			t.Errorf("commits went backwards: %v", got)
+			break
+		}
+	}
+}
+
+func TestLanesCommitBoundary(t *testing.T) {
+	ctx := context.Background()
+	c := &Consumer{mode: CommitOnSuccess, workers: 8}
+	commits := newCommits()
+	l := c.startLanes(ctx, commits.commit)
+
+	release := make(chan struct{})
+	fastDone := make(chan struct{}, 3)
+	fast := func(context.Context) error {
+		fastDone <- struct{}{}
+		return nil
+	}
+	slow := testJob(0, "slow", func(context.Context) error {
+		<-release
+		return nil
+	})
+	// Both keys must land on different lanes for the slow one not to
+	// hold up the fast one
+	if l.lane(slow) == l.lane(testJob(1, "fast", nil)) {
+		t.Fatal("slow and fast keys share a lane")
+	}
+	if err := l.dispatch(ctx, []job{slow, testJob(1, "fast", fast), testJob(2, "fast", fast)}); err != nil {
+		t.Fatal(err)
+	}
+	// A later poll isn't held up by the slow record either
+	if err := l.dispatch(ctx, []job{testJob(3, "fast", fast)}); err != nil {
+		t.Fatal(err)
+	}
+	for range 3 {
+		select {
+		case <-fastDone:
+		case <-time.After(5 * time.Second):
+			t.Fatal("fast records blocked behind the slow one")
+		}
+	}
+	// The fast records are done, but committing them would skip offset 0
+	if got := commits.snapshot(); len(got) != 0 {
+		t.Fatalf("committed %v while offset 0 was being handled", got)
+	}
+
+	close(release)
+	commits.waitFor(t, 3)
+	if err := l.stop(); err != nil {
+		t.Fatal(err)
+	}
+	if got := commits.snapshot(); len(got) != 1 || got[0] != 3 {
+		t.Errorf("committed %v, want [3]", got)
+	}
+}
+
+func TestLanesPropagateError(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := &Consumer{mode: CommitOnSuccess, workers: 2}
+	commits := newCommits()
+	l := c.startLanes(ctx, commits.commit)
+
+	// The handler keeps failing, so the record is retried until the
+	// consumer is cancelled
+	attempted := make(chan struct{}, 1)
+	failing := testJob(0, "a", func(context.Context) error {
+		select {
+		case attempted <- struct{}{}:
+		default:
+		}
+		return errors.New("unavailable")
+	})
+	if err := l.dispatch(ctx, []job{failing}); err != nil {
+		t.Fatal(err)
+	}
+	<-attempted
+	cancel()
+
+	select {
+	case <-l.failed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("lane didn't fail")
+	}
+	if err := l.dispatch(context.Background(), []job{testJob(1, "a", nil)}); !errors.Is(err, context.Canceled) {
+		t.Errorf("dispatch() after failure = %v, want context.Canceled", err)
+	}
+	if err := l.stop(); !errors.Is(err, context.Canceled) {
+		t.Errorf("stop() = %v, want context.Canceled", err)
+	}
+	if got := commits.snapshot(); len(got) != 0 {
+		t.Errorf("committed %v past a failed record", got)
+	}
+}
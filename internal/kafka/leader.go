@@ -0,0 +1,125 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/FatwaArya/pm-ingest/internal/logging"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// leaderPartition is the partition whose owner is considered the leader.
+// Kafka assigns every partition to exactly one group member, so whichever
+// instance holds it is the single active ingester.
+const leaderPartition int32 = 0
+
+// LeaderElector uses Kafka consumer group membership to elect a single leader
+// among replicas. Every replica joins the same group on a coordination topic;
+// the member that is assigned partition 0 is the leader, everyone else is a
+// hot standby. When the leader dies or leaves, the group rebalances and the
+// partition (and leadership) moves to another member.
+type LeaderElector struct {
+	client    *kgo.Client
+	topic     string
+	isLeader  atomic.Bool
+	onElected func()
+	onRevoked func()
+}
+
+// NewLeaderElector creates a leader elector for the given coordination topic and group.
+// onElected is called when this instance becomes leader and onRevoked when it
+// loses leadership; both are called from the Kafka client's rebalance goroutine
+// and should not block for long.
+func NewLeaderElector(brokers string, topic string, groupID string, onElected func(), onRevoked func()) (*LeaderElector, error) {
+	le := &LeaderElector{
+		topic:     topic,
+		onElected: onElected,
+		onRevoked: onRevoked,
+	}
+
+	opts := append(ClientOpts(brokers),
+		kgo.ConsumerGroup(groupID),
+		kgo.ConsumeTopics(topic),
+		kgo.AllowAutoTopicCreation(),
+		kgo.OnPartitionsAssigned(le.handleAssigned),
+		kgo.OnPartitionsRevoked(le.handleRevoked),
+		kgo.OnPartitionsLost(le.handleRevoked),
+	)
+
+	cl, err := kgo.NewClient(opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kafka client: %w", err)
+	}
+	le.client = cl
+
+	return le, nil
+}
+
+// Run keeps the group membership alive until ctx is cancelled.
+// The coordination topic carries no data; polling just drives the group.
+func (le *LeaderElector) Run(ctx context.Context) error {
+	for {
+		fetches := le.client.PollFetches(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if fetches.IsClientClosed() {
+			return nil
+		}
+		if errs := fetches.Errors(); len(errs) > 0 {
+			for _, e := range errs {
+				fetchErrLog.PrintfContext(ctx, "Leader election fetch error: %v", e)
+			}
+		}
+	}
+}
+
+// IsLeader reports whether this instance currently holds leadership
+func (le *LeaderElector) IsLeader() bool {
+	return le.isLeader.Load()
+}
+
+// handleAssigned promotes this instance if the leader partition was assigned
+func (le *LeaderElector) handleAssigned(ctx context.Context, _ *kgo.Client, assigned map[string][]int32) {
+	if !containsPartition(assigned[le.topic], leaderPartition) {
+		return
+	}
+	if le.isLeader.Swap(true) {
+		return // Already leader
+	}
+	kafkaLog.InfoContext(ctx, "Acquired leadership", logging.KeyTopic, le.topic)
+	if le.onElected != nil {
+		le.onElected()
+	}
+}
+
+// handleRevoked demotes this instance if the leader partition was revoked or lost
+func (le *LeaderElector) handleRevoked(ctx context.Context, _ *kgo.Client, revoked map[string][]int32) {
+	if !containsPartition(revoked[le.topic], leaderPartition) {
+		return
+	}
+	if !le.isLeader.Swap(false) {
+		return // Was not leader
+	}
+	kafkaLog.WarnContext(ctx, "Lost leadership", logging.KeyTopic, le.topic)
+	if le.onRevoked != nil {
+		le.onRevoked()
+	}
+}
+
+// Close leaves the group, handing leadership to a standby
+func (le *LeaderElector) Close() {
+	if le.client != nil {
+		le.client.Close()
+	}
+}
+
+func containsPartition(partitions []int32, partition int32) bool {
+	for _, p := range partitions {
+		if p == partition {
+			return true
+		}
+	}
+	return false
+}
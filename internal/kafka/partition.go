@@ -0,0 +1,73 @@
+package kafka
+
+import (
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// PartitionBy decides the key of trade records, and so which partition
+// they go to and which trades keep their relative order
+type PartitionBy string
+
+const (
+	// PartitionByTransaction keys trades by transaction hash, keeping the
+	// fills of a transaction together
+	PartitionByTransaction PartitionBy = "transaction"
+	// PartitionByMarket keys trades by condition ID, keeping each market's
+	// trades in order for per-market aggregators
+	PartitionByMarket PartitionBy = "market"
+	// PartitionByWallet keys trades by proxy wallet, keeping each wallet's
+	// trades in order
+	PartitionByWallet PartitionBy = "wallet"
+	// PartitionRoundRobin leaves trades unkeyed and spreads them evenly,
+	// with no ordering between them
+	PartitionRoundRobin PartitionBy = "round-robin"
+)
+
+// key returns the record key of a trade. A trade missing the field is
+// unkeyed.
+func (p PartitionBy) key(txHash, conditionID, wallet string) []byte {
+	var key string
+	switch p {
+	case PartitionByMarket:
+		key = conditionID
+	case PartitionByWallet:
+		key = wallet
+	case PartitionRoundRobin:
+	default:
+		key = txHash
+	}
+	if key == "" {
+		return nil
+	}
+	return []byte(key)
+}
+
+// roundRobinPartitioner hashes keyed records like the default partitioner,
+// so other records keep their keys' partitions, and spreads unkeyed ones
+// round robin rather than in sticky batches
+func roundRobinPartitioner() kgo.Partitioner {
+	return partitioner{keyed: kgo.StickyKeyPartitioner(nil), unkeyed: kgo.RoundRobinPartitioner()}
+}
+
+type partitioner struct {
+	keyed, unkeyed kgo.Partitioner
+}
+
+func (p partitioner) ForTopic(topic string) kgo.TopicPartitioner {
+	return topicPartitioner{keyed: p.keyed.ForTopic(topic), unkeyed: p.unkeyed.ForTopic(topic)}
+}
+
+type topicPartitioner struct {
+	keyed, unkeyed kgo.TopicPartitioner
+}
+
+func (p topicPartitioner) RequiresConsistency(r *kgo.Record) bool {
+	return r.Key != nil
+}
+
+func (p topicPartitioner) Partition(r *kgo.Record, n int) int {
+	if r.Key != nil {
+		return p.keyed.Partition(r, n)
+	}
+	return p.unkeyed.Partition(r, n)
+}
@@ -2,45 +2,177 @@ package kafka
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"log"
-	"strings"
+	"time"
 
-	"github.com/FatwaArya/pm-ingest/utils"
+	"github.com/FatwaArya/pm-ingest/internal/health"
+	"github.com/FatwaArya/pm-ingest/internal/logging"
+	"github.com/FatwaArya/pm-ingest/internal/provenance"
+	"github.com/FatwaArya/pm-ingest/internal/wal"
+	"github.com/FatwaArya/pm-ingest/pkg/rtds"
 	"github.com/twmb/franz-go/pkg/kgo"
 )
 
+var kafkaLog = logging.For("kafka")
+
+// produceErrLog limits async delivery error logging during broker outages
+var produceErrLog = logging.NewRateLimited(logging.For("kafka"), 5*time.Second)
+
+// deliveryTimeout bounds how long a record may be retried before its
+// promise fails, so a broker outage surfaces as errors instead of an
+// ever-growing buffer
+const deliveryTimeout = 30 * time.Second
+
 type Producer struct {
 	client *kgo.Client
 	topic  string
+	sink   *health.SinkHealth
+	wal    *wal.WAL
+	fault  func() error
+	source string      // Of the trades produced
+	keyBy  PartitionBy // Of the trades produced
 }
 
 type TradeMessage struct {
-	Side            string  `json:"side"`
-	Outcome         string  `json:"outcome"`
-	EventSlug       string  `json:"eventSlug"`
-	Slug            string  `json:"slug"`
-	ConditionId     string  `json:"conditionId"`
-	TransactionHash string  `json:"transactionHash"`
-	ProxyWallet     string  `json:"proxyWallet"`
-	QuestionId      string  `json:"questionId"`
-	Price           float64 `json:"price"`
-	Size            float64 `json:"size"`
-	Fee             float64 `json:"fee"`
-	Timestamp       int64   `json:"timestamp"`
+	Side            string   `json:"side"`
+	Outcome         string   `json:"outcome"`
+	EventSlug       string   `json:"eventSlug"`
+	Slug            string   `json:"slug"`
+	ConditionId     string   `json:"conditionId"`
+	TransactionHash string   `json:"transactionHash"`
+	ProxyWallet     string   `json:"proxyWallet"`
+	QuestionId      string   `json:"questionId"`
+	Title           string   `json:"title,omitempty"`
+	OutcomeIndex    int      `json:"outcomeIndex,omitempty"`
+	Name            string   `json:"name,omitempty"`
+	Pseudonym       string   `json:"pseudonym,omitempty"`
+	Asset           string   `json:"asset"`
+	Maker           string   `json:"maker,omitempty"`
+	Taker           string   `json:"taker,omitempty"`
+	MakerOrderId    string   `json:"makerOrderId,omitempty"`
+	TakerOrderId    string   `json:"takerOrderId,omitempty"`
+	Role            string   `json:"role,omitempty"`          // Whether proxyWallet was maker or taker
+	AggressorSide   string   `json:"aggressorSide,omitempty"` // Taker's side, the direction of the flow
+	Price           float64  `json:"price"`
+	Size            float64  `json:"size"`
+	Fee             float64  `json:"fee"`
+	Timestamp       int64    `json:"timestamp"`
+	Labels          []string `json:"labels,omitempty"` // Wallet labels, e.g. whale
+	Category        string   `json:"category,omitempty"`
+	MarketEndDate   string   `json:"marketEndDate,omitempty"`
+	MarketLiquidity float64  `json:"marketLiquidity,omitempty"`
+	MarketVolume    float64  `json:"marketVolume,omitempty"`
+	Outcomes        []string `json:"outcomes,omitempty"`
+	Tags            []string `json:"tags,omitempty"`
+}
+
+// ProducerSettings tune how a producer batches and delivers records. The
+// zero value keeps franz-go's defaults, which are idempotent.
+type ProducerSettings struct {
+	// DisableIdempotence opts out of broker-side deduplication of retried
+	// batches, for clusters that don't grant IDEMPOTENT_WRITE
+	DisableIdempotence bool
+	Compression        string        // none, gzip, snappy, lz4 or zstd; empty keeps snappy where brokers support it
+	Linger             time.Duration // How long a partition's batch waits to fill up; 0 sends right away
+	BatchMaxBytes      int32         // Largest batch; 0 keeps the default of ~1MB
+	// MaxInFlight is the number of produce requests in flight per broker
+	// (0 keeps the default). Idempotent producers always allow 5 and keep
+	// records in order; without idempotence more than 1 may reorder them.
+	MaxInFlight int
+	Retries     int         // Times a record is retried before it fails; 0 retries until the delivery timeout
+	PartitionBy PartitionBy // Key of trade records; empty keys them by transaction
+}
+
+// ParseCompression returns the codec named name
+func ParseCompression(name string) (kgo.CompressionCodec, error) {
+	switch name {
+	case "none":
+		return kgo.NoCompression(), nil
+	case "gzip":
+		return kgo.GzipCompression(), nil
+	case "snappy":
+		return kgo.SnappyCompression(), nil
+	case "lz4":
+		return kgo.Lz4Compression(), nil
+	case "zstd":
+		return kgo.ZstdCompression(), nil
+	}
+	return kgo.CompressionCodec{}, fmt.Errorf("unknown compression %q", name)
+}
+
+// Opts converts the settings to franz-go client options
+func (s ProducerSettings) Opts() ([]kgo.Opt, error) {
+	var opts []kgo.Opt
+	if s.Compression != "" {
+		codec, err := ParseCompression(s.Compression)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, kgo.ProducerBatchCompression(codec))
+	}
+	if s.DisableIdempotence {
+		opts = append(opts, kgo.DisableIdempotentWrite())
+		if s.MaxInFlight > 0 {
+			opts = append(opts, kgo.MaxProduceRequestsInflightPerBroker(s.MaxInFlight))
+		}
+	}
+	if s.Linger > 0 {
+		opts = append(opts, kgo.ProducerLinger(s.Linger))
+	}
+	if s.BatchMaxBytes > 0 {
+		opts = append(opts, kgo.ProducerBatchMaxBytes(s.BatchMaxBytes))
+	}
+	if s.Retries > 0 {
+		opts = append(opts, kgo.RecordRetries(s.Retries))
+	}
+	if s.PartitionBy == PartitionRoundRobin {
+		opts = append(opts, kgo.RecordPartitioner(roundRobinPartitioner()))
+	}
+	return opts, nil
+}
+
+type producerConfig struct {
+	opts     []kgo.Opt
+	settings ProducerSettings
+	source   string
+}
+
+// ProducerOption configures a producer
+type ProducerOption func(*producerConfig)
+
+// WithSettings applies batching and delivery settings
+func WithSettings(s ProducerSettings) ProducerOption {
+	return func(c *producerConfig) {
+		c.settings = s
+	}
+}
+
+// WithSource sets the source trades are marked with (SourceLive by default)
+func WithSource(source string) ProducerOption {
+	return func(c *producerConfig) {
+		c.source = source
+	}
 }
 
 // NewProducer creates a Kafka producer for the given brokers and topic.
 // brokers: comma-separated list, e.g. "localhost:19092"
-func NewProducer(brokers string, topic string) (*Producer, error) {
-	bs := strings.Split(brokers, ",")
-	opts := []kgo.Opt{
-		kgo.SeedBrokers(bs...),
-		kgo.AllowAutoTopicCreation(),
+func NewProducer(brokers string, topic string, options ...ProducerOption) (*Producer, error) {
+	cfg := producerConfig{
+		opts: append(ClientOpts(brokers),
+			kgo.AllowAutoTopicCreation(),
+			kgo.RecordDeliveryTimeout(deliveryTimeout),
+		),
+		source: SourceLive,
+	}
+	for _, o := range options {
+		o(&cfg)
+	}
+	settingOpts, err := cfg.settings.Opts()
+	if err != nil {
+		return nil, fmt.Errorf("invalid producer settings: %w", err)
 	}
 
-	cl, err := kgo.NewClient(opts...)
+	cl, err := kgo.NewClient(append(cfg.opts, settingOpts...)...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create kafka client: %w", err)
 	}
@@ -48,14 +180,92 @@ func NewProducer(brokers string, topic string) (*Producer, error) {
 	return &Producer{
 		client: cl,
 		topic:  topic,
+		source: cfg.source,
+		keyBy:  cfg.settings.PartitionBy,
 	}, nil
 }
 
 // ProduceTrade serializes the trade as JSON and sends it to Kafka.
-func (p *Producer) ProduceTrade(ctx context.Context, trade *utils.ActivityTradePayload) error {
+// Delivery is asynchronous: the record is bounded by deliveryTimeout and
+// fails (spilling to the WAL, if enabled) when ctx is cancelled first.
+func (p *Producer) ProduceTrade(ctx context.Context, trade *rtds.ActivityTradePayload) error {
 	if trade == nil {
 		return nil
 	}
+	key, value, err := p.encodeTrade(trade)
+	if err != nil {
+		return err
+	}
+
+	record := &kgo.Record{
+		Topic:   p.topic,
+		Key:     key,
+		Value:   value,
+		Headers: tradeHeaders(p.source, trade),
+	}
+
+	// An injected fault fails the trade as a failed delivery would
+	if p.fault != nil {
+		if err := p.fault(); err != nil {
+			if p.wal == nil {
+				return err
+			}
+			p.sink.RecordFailure(err)
+			return p.wal.Append(value)
+		}
+	}
+
+	// The promise runs exactly once per record, releasing the timeout
+	ctx, cancel := context.WithTimeout(ctx, deliveryTimeout)
+
+	if p.wal == nil {
+		// Asynchronous production with callback logging.
+		p.client.Produce(ctx, record, func(record *kgo.Record, err error) {
+			cancel()
+			if err != nil {
+				produceErrLog.Printf("Kafka produce error: %v", err)
+			}
+		})
+		return nil
+	}
+
+	// Kafka is degraded: go straight to the WAL, letting a probe through
+	// once per retry interval to detect recovery
+	if !p.sink.Allow() {
+		cancel()
+		return p.wal.Append(value)
+	}
+
+	// TryProduce fails fast when the buffer is full instead of blocking ingestion
+	p.client.TryProduce(ctx, record, func(record *kgo.Record, err error) {
+		cancel()
+		if err != nil {
+			p.sink.RecordFailure(err)
+			if walErr := p.wal.Append(record.Value); walErr != nil {
+				produceErrLog.Printf("Kafka produce error: %v (WAL append failed: %v)", err, walErr)
+			}
+			return
+		}
+		p.sink.RecordSuccess()
+	})
+
+	return nil
+}
+
+// ProduceTradeTo sends a copy of the trade to another topic, e.g. a
+// category topic. Copies aren't spilled to the WAL; the WAL replays to the
+// main topic only.
+func (p *Producer) ProduceTradeTo(ctx context.Context, topic string, trade *rtds.ActivityTradePayload) error {
+	key, value, err := p.encodeTrade(trade)
+	if err != nil {
+		return err
+	}
+	p.produce(ctx, &kgo.Record{Topic: topic, Key: key, Value: value, Headers: tradeHeaders(p.source, trade)})
+	return nil
+}
+
+// encodeTrade returns the record key and value of a trade
+func (p *Producer) encodeTrade(trade *rtds.ActivityTradePayload) (key, value []byte, err error) {
 	tradeMessage := TradeMessage{
 		Side:            trade.Side,
 		Outcome:         trade.OutcomeTitle,
@@ -65,42 +275,204 @@ func (p *Producer) ProduceTrade(ctx context.Context, trade *utils.ActivityTradeP
 		TransactionHash: trade.TransactionHash,
 		ProxyWallet:     trade.ProxyWalletAddress,
 		QuestionId:      trade.QuestionID,
+		Title:           trade.EventTitle,
+		OutcomeIndex:    trade.OutcomeIndex,
+		Name:            trade.Name,
+		Pseudonym:       trade.Pseudonym,
+		Asset:           trade.Asset,
+		Maker:           trade.Maker,
+		Taker:           trade.Taker,
+		MakerOrderId:    trade.MakerOrderID,
+		TakerOrderId:    trade.TakerOrderID,
+		Role:            trade.Role(),
+		AggressorSide:   trade.AggressorSide(),
 		Price:           trade.Price,
 		Size:            trade.Size,
 		Fee:             trade.Fee,
 		Timestamp:       trade.Timestamp,
+		Labels:          trade.Labels,
+		Category:        trade.Category,
+		MarketEndDate:   trade.MarketEndDate,
+		MarketLiquidity: trade.MarketLiquidity,
+		MarketVolume:    trade.MarketVolume,
+		Outcomes:        trade.Outcomes,
+		Tags:            trade.Tags,
 	}
 
-	value, err := json.Marshal(tradeMessage)
+	value, err = EncodeValue(TradeSchema, &tradeMessage)
 	if err != nil {
-		return fmt.Errorf("failed to marshal trade: %w", err)
+		return nil, nil, fmt.Errorf("failed to marshal trade: %w", err)
 	}
 
-	// Use transaction hash as key when available to keep related records in the same partition.
-	var key []byte
-	if trade.TransactionHash != "" {
-		key = []byte(trade.TransactionHash)
+	return p.keyBy.key(trade.TransactionHash, trade.ConditionID, trade.ProxyWalletAddress), value, nil
+}
+
+// Payload converts the message back into the trade it was encoded from.
+// Role and aggressor side are derived from the maker and taker fields.
+func (m *TradeMessage) Payload() *rtds.ActivityTradePayload {
+	return &rtds.ActivityTradePayload{
+		Asset:              m.Asset,
+		Side:               m.Side,
+		Price:              m.Price,
+		Size:               m.Size,
+		Fee:                m.Fee,
+		Timestamp:          m.Timestamp,
+		TransactionHash:    m.TransactionHash,
+		Maker:              m.Maker,
+		Taker:              m.Taker,
+		MakerOrderID:       m.MakerOrderId,
+		TakerOrderID:       m.TakerOrderId,
+		ConditionID:        m.ConditionId,
+		OutcomeIndex:       m.OutcomeIndex,
+		QuestionID:         m.QuestionId,
+		MarketSlug:         m.Slug,
+		EventSlug:          m.EventSlug,
+		EventTitle:         m.Title,
+		OutcomeTitle:       m.Outcome,
+		ProxyWalletAddress: m.ProxyWallet,
+		Name:               m.Name,
+		Pseudonym:          m.Pseudonym,
+		Labels:             m.Labels,
+		Category:           m.Category,
+		MarketEndDate:      m.MarketEndDate,
+		MarketLiquidity:    m.MarketLiquidity,
+		MarketVolume:       m.MarketVolume,
+		Outcomes:           m.Outcomes,
+		Tags:               m.Tags,
 	}
+}
+
+// HeaderBackfill marks trades produced by a backfill rather than the live
+// feed; its value is "true". HeaderSource says so too.
+const HeaderBackfill = "backfill"
 
+// ProduceBackfilledTrade sends a historical trade to the trades topic,
+// marked with HeaderBackfill. Unlike ProduceTrade it waits for delivery and
+// returns the error, so a backfill can stop (and resume) where it failed.
+func (p *Producer) ProduceBackfilledTrade(ctx context.Context, trade *rtds.ActivityTradePayload) error {
+	key, value, err := p.encodeTrade(trade)
+	if err != nil {
+		return err
+	}
 	record := &kgo.Record{
-		Topic: p.topic,
-		Key:   key,
-		Value: value,
+		Topic:   p.topic,
+		Key:     key,
+		Value:   value,
+		Headers: append(tradeHeaders(SourceBackfill, trade), kgo.RecordHeader{Key: HeaderBackfill, Value: []byte("true")}),
 	}
+	ctx, cancel := context.WithTimeout(ctx, deliveryTimeout)
+	defer cancel()
+	return p.client.ProduceSync(ctx, record).FirstErr()
+}
+
+// Produce sends a record to topic asynchronously. Unlike trades, these
+// records aren't spilled to the WAL; failures are logged.
+func (p *Producer) Produce(ctx context.Context, topic string, key, value []byte) error {
+	p.produce(ctx, &kgo.Record{
+		Topic:   topic,
+		Key:     key,
+		Value:   value,
+		Headers: provenanceHeaders(),
+	})
+	return nil
+}
 
-	// Asynchronous production with callback logging.
+// produce sends record asynchronously, logging a failure
+func (p *Producer) produce(ctx context.Context, record *kgo.Record) {
+	ctx, cancel := context.WithTimeout(ctx, deliveryTimeout)
 	p.client.Produce(ctx, record, func(record *kgo.Record, err error) {
+		cancel()
 		if err != nil {
-			log.Printf("Kafka produce error: %v", err)
+			produceErrLog.Printf("Kafka produce error on %s: %v", record.Topic, err)
 		}
 	})
+}
 
-	return nil
+// EnableFallback makes the producer track Kafka health in sink and spill
+// records that can't be delivered to w. Buffered records are replayed
+// when Kafka recovers (and once now, for records left by a previous run).
+func (p *Producer) EnableFallback(sink *health.SinkHealth, w *wal.WAL) {
+	p.sink = sink
+	p.wal = w
+	sink.OnRecover(p.replayWAL)
+	go p.replayWAL()
 }
 
-// Close flushes pending records and closes the Kafka client.
+// replayWAL re-produces records buffered in the WAL, in order
+func (p *Producer) replayWAL() {
+	if p.wal.Len() == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	replayed, err := p.wal.Replay(func(value []byte) error {
+		msg, err := DecodeTrade(value)
+		if err != nil {
+			return nil // Drop corrupt records rather than blocking the WAL
+		}
+		record := &kgo.Record{
+			Topic:   p.topic,
+			Key:     p.keyBy.key(msg.TransactionHash, msg.ConditionId, msg.ProxyWallet),
+			Value:   value,
+			Headers: tradeHeaders(p.source, nil),
+		}
+		return p.client.ProduceSync(ctx, record).FirstErr()
+	})
+	if err != nil {
+		kafkaLog.ErrorContext(ctx, "WAL replay stopped", "replayed", replayed, "error", err)
+		return
+	}
+	kafkaLog.InfoContext(ctx, "Replayed WAL to Kafka", "replayed", replayed)
+}
+
+// SetFaultInjector makes ProduceTrade fail trades for which fault returns
+// an error, for chaos testing. Call before producing.
+func (p *Producer) SetFaultInjector(fault func() error) {
+	p.fault = fault
+}
+
+// Healthy reports false while Kafka is degraded (always true without fallback)
+func (p *Producer) Healthy() bool {
+	return p.sink == nil || !p.sink.Degraded()
+}
+
+// Ping checks that at least one broker is reachable
+func (p *Producer) Ping(ctx context.Context) error {
+	return p.client.Ping(ctx)
+}
+
+// provenanceHeaders stamps records with the emitting replica and build
+func provenanceHeaders() []kgo.RecordHeader {
+	info := provenance.Current()
+	return []kgo.RecordHeader{
+		{Key: provenance.HeaderInstanceID, Value: []byte(info.InstanceID)},
+		{Key: provenance.HeaderHostname, Value: []byte(info.Hostname)},
+		{Key: provenance.HeaderVersion, Value: []byte(info.Version)},
+	}
+}
+
+// Flush waits until all buffered records are delivered or ctx is done
+func (p *Producer) Flush(ctx context.Context) error {
+	return p.client.Flush(ctx)
+}
+
+// closeFlushTimeout bounds how long Close waits for buffered records
+const closeFlushTimeout = 10 * time.Second
+
+// Close delivers the records still buffered, waiting up to
+// closeFlushTimeout, then closes the Kafka client. Records that couldn't be
+// delivered by then are failed (and spilled to the WAL, if enabled).
 func (p *Producer) Close() {
-	if p.client != nil {
-		p.client.Close()
+	if p.client == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), closeFlushTimeout)
+	defer cancel()
+	if err := p.client.Flush(ctx); err != nil {
+		kafkaLog.ErrorContext(ctx, "Kafka producer closed with records undelivered",
+			"undelivered", p.client.BufferedProduceRecords(), "error", err)
 	}
+	p.client.Close()
 }
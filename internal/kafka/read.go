@@ -0,0 +1,49 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// ReadTopic reads topic from the start, without a consumer group, passing
+// each record's value and timestamp to handle until the topic is drained,
+// handle fails or ctx is done. It returns how many records were handled.
+func ReadTopic(ctx context.Context, brokers, topic string, handle func(ctx context.Context, value []byte, at time.Time) error) (int, error) {
+	cl, err := kgo.NewClient(append(ClientOpts(brokers),
+		kgo.ConsumeTopics(topic),
+		kgo.ConsumeResetOffset(kgo.NewOffset().AtStart()),
+	)...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create kafka client: %w", err)
+	}
+	defer cl.Close()
+
+	read := 0
+	for {
+		pollCtx, cancel := context.WithTimeout(ctx, dlqIdleTimeout)
+		fetches := cl.PollFetches(pollCtx)
+		cancel()
+		if ctx.Err() != nil {
+			return read, ctx.Err()
+		}
+		for _, e := range fetches.Errors() {
+			if !errors.Is(e.Err, context.DeadlineExceeded) {
+				fetchErrLog.Printf("Kafka fetch error: %v", e.Err)
+			}
+		}
+		records := fetches.Records()
+		if len(records) == 0 {
+			return read, nil
+		}
+		for _, r := range records {
+			if err := handle(ctx, r.Value, r.Timestamp); err != nil {
+				return read, fmt.Errorf("record at %d/%d: %w", r.Partition, r.Offset, err)
+			}
+			read++
+		}
+	}
+}
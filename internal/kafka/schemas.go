@@ -0,0 +1,36 @@
+package kafka
+
+import (
+	"github.com/FatwaArya/pm-ingest/internal/schema"
+)
+
+// TradeSchema is the schema of trade records. Version 2 added the market
+// metadata fields.
+var TradeSchema = schema.MustNew("Trade", 2, TradeMessage{})
+
+// serializer encodes the values this package produces; plain JSON until
+// UseSerializer is called
+var serializer, _ = schema.NewSerializer(schema.FormatJSON, nil)
+
+// UseSerializer encodes trades, and the values passed to EncodeValue, with
+// s from now on. Call it once at startup, after registering the schemas.
+func UseSerializer(s *schema.Serializer) {
+	serializer = s
+}
+
+// EncodeValue serializes v, of the struct of sc, in the configured format
+func EncodeValue(sc *schema.Schema, v any) ([]byte, error) {
+	return serializer.Encode(sc, v)
+}
+
+// DecodeTrade deserializes a trade record value, JSON or schema-encoded
+func DecodeTrade(value []byte) (TradeMessage, error) {
+	var msg TradeMessage
+	err := serializer.Decode(TradeSchema, value, &msg)
+	return msg, err
+}
+
+// Format returns the configured serialization format
+func Format() string {
+	return serializer.Format()
+}
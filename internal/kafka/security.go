@@ -0,0 +1,137 @@
+package kafka
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/sasl"
+	"github.com/twmb/franz-go/pkg/sasl/oauth"
+	"github.com/twmb/franz-go/pkg/sasl/plain"
+	"github.com/twmb/franz-go/pkg/sasl/scram"
+)
+
+// SASL mechanisms accepted in Security.SASLMechanism
+const (
+	SASLPlain       = "PLAIN"
+	SASLScramSHA256 = "SCRAM-SHA-256"
+	SASLScramSHA512 = "SCRAM-SHA-512"
+	SASLOAuthBearer = "OAUTHBEARER"
+)
+
+// Security holds the client ID and the TLS and SASL settings of a secured
+// cluster (Confluent Cloud, MSK with SCRAM, ...). The zero value connects
+// in plaintext without authentication.
+type Security struct {
+	ClientID string
+
+	TLS                   bool
+	TLSCAFile             string // PEM CA bundle; empty uses the system roots
+	TLSCertFile           string // PEM client certificate, for mutual TLS
+	TLSKeyFile            string
+	TLSInsecureSkipVerify bool
+
+	SASLMechanism string // "" for none
+	SASLUsername  string
+	SASLPassword  string
+	SASLToken     string // OAUTHBEARER token
+}
+
+// securityOpts are added to every client this package creates
+var securityOpts []kgo.Opt
+
+// Configure applies sec to the Kafka clients created from now on, by this
+// package and by callers of ClientOpts. Call it once at startup.
+func Configure(sec Security) error {
+	opts, err := sec.Opts()
+	if err != nil {
+		return err
+	}
+	securityOpts = opts
+	return nil
+}
+
+// ClientOpts returns the seed brokers of a comma-separated list with the
+// options set by Configure and the tracing hooks, for clients created
+// outside this package
+func ClientOpts(brokers string) []kgo.Opt {
+	opts := []kgo.Opt{kgo.SeedBrokers(strings.Split(brokers, ",")...), kgo.WithHooks(tracingHooks{})}
+	return append(opts, securityOpts...)
+}
+
+// Opts converts the settings to franz-go client options
+func (s Security) Opts() ([]kgo.Opt, error) {
+	var opts []kgo.Opt
+	if s.ClientID != "" {
+		opts = append(opts, kgo.ClientID(s.ClientID))
+	}
+
+	if s.TLS {
+		cfg, err := s.tlsConfig()
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, kgo.DialTLSConfig(cfg))
+	}
+
+	mechanism, err := s.saslMechanism()
+	if err != nil {
+		return nil, err
+	}
+	if mechanism != nil {
+		opts = append(opts, kgo.SASL(mechanism))
+	}
+	return opts, nil
+}
+
+func (s Security) tlsConfig() (*tls.Config, error) {
+	cfg := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: s.TLSInsecureSkipVerify,
+	}
+	if s.TLSCAFile != "" {
+		pem, err := os.ReadFile(s.TLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading kafka CA file: %w", err)
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in kafka CA file %s", s.TLSCAFile)
+		}
+	}
+	if s.TLSCertFile != "" || s.TLSKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(s.TLSCertFile, s.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading kafka client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+func (s Security) saslMechanism() (sasl.Mechanism, error) {
+	switch strings.ToUpper(s.SASLMechanism) {
+	case "":
+		return nil, nil
+	case SASLPlain:
+		return plain.Auth{User: s.SASLUsername, Pass: s.SASLPassword}.AsMechanism(), nil
+	case SASLScramSHA256:
+		return scram.Auth{User: s.SASLUsername, Pass: s.SASLPassword}.AsSha256Mechanism(), nil
+	case SASLScramSHA512:
+		return scram.Auth{User: s.SASLUsername, Pass: s.SASLPassword}.AsSha512Mechanism(), nil
+	case SASLOAuthBearer:
+		if s.SASLToken == "" {
+			return nil, fmt.Errorf("kafka SASL %s needs a token", SASLOAuthBearer)
+		}
+		token := s.SASLToken
+		return oauth.Oauth(func(context.Context) (oauth.Auth, error) {
+			return oauth.Auth{Token: token}, nil
+		}), nil
+	default:
+		return nil, fmt.Errorf("unknown kafka SASL mechanism %q", s.SASLMechanism)
+	}
+}
@@ -0,0 +1,91 @@
+package kafka
+
+import (
+	"context"
+
+	"github.com/FatwaArya/pm-ingest/internal/tracing"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// headerCarrier reads and writes trace context in a record's headers
+type headerCarrier struct {
+	record *kgo.Record
+}
+
+func (c headerCarrier) Get(key string) string {
+	for _, h := range c.record.Headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+// Set replaces key, so a record produced again (DLQ replays) carries only
+// its latest trace context
+func (c headerCarrier) Set(key, value string) {
+	for i, h := range c.record.Headers {
+		if h.Key == key {
+			c.record.Headers[i].Value = []byte(value)
+			return
+		}
+	}
+	c.record.Headers = append(c.record.Headers, kgo.RecordHeader{Key: key, Value: []byte(value)})
+}
+
+func (c headerCarrier) Keys() []string {
+	keys := make([]string, len(c.record.Headers))
+	for i, h := range c.record.Headers {
+		keys[i] = h.Key
+	}
+	return keys
+}
+
+// tracingHooks gives every produced record a span, from buffering to
+// acknowledgement, and writes its context to the record's headers
+type tracingHooks struct{}
+
+var (
+	_ kgo.HookProduceRecordBuffered   = tracingHooks{}
+	_ kgo.HookProduceRecordUnbuffered = tracingHooks{}
+)
+
+func (tracingHooks) OnProduceRecordBuffered(r *kgo.Record) {
+	ctx := r.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, _ = tracing.Start(ctx, "kafka.produce "+r.Topic,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(attribute.String("messaging.destination.name", r.Topic)))
+	otel.GetTextMapPropagator().Inject(ctx, headerCarrier{r})
+	r.Context = ctx
+}
+
+func (tracingHooks) OnProduceRecordUnbuffered(r *kgo.Record, err error) {
+	if r.Context == nil {
+		return
+	}
+	span := trace.SpanFromContext(r.Context)
+	span.SetAttributes(
+		attribute.Int("messaging.kafka.destination.partition", int(r.Partition)),
+		attribute.Int64("messaging.kafka.message.offset", r.Offset),
+	)
+	tracing.End(span, err)
+}
+
+// consumeSpan starts the span of handling r, continuing the trace its
+// producer wrote to its headers
+func consumeSpan(ctx context.Context, r *kgo.Record) (context.Context, trace.Span) {
+	ctx = otel.GetTextMapPropagator().Extract(ctx, headerCarrier{r})
+	return tracing.Start(ctx, "kafka.consume "+r.Topic,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("messaging.destination.name", r.Topic),
+			attribute.Int("messaging.kafka.destination.partition", int(r.Partition)),
+			attribute.Int64("messaging.kafka.message.offset", r.Offset),
+		))
+}
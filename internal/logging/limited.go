@@ -0,0 +1,97 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/FatwaArya/pm-ingest/pkg/clock"
+)
+
+// RateLimited logs at most once per interval. Messages dropped in between are
+// counted and reported with the next message that gets through, so a burst of
+// identical errors produces one line per interval instead of one per event.
+// Lines are logged at error level: every caller reports errors it recovers
+// from.
+type RateLimited struct {
+	logger     *slog.Logger
+	interval   time.Duration
+	clock      clock.Clock
+	mu         sync.Mutex
+	last       time.Time
+	suppressed uint64
+}
+
+// NewRateLimited creates a logger that emits at most one line per interval
+// through logger (nil uses slog's default logger)
+func NewRateLimited(logger *slog.Logger, interval time.Duration) *RateLimited {
+	if logger == nil {
+		logger = For("")
+	}
+	return &RateLimited{logger: logger, interval: interval, clock: clock.Real}
+}
+
+// SetClock replaces the clock used to measure the interval
+func (r *RateLimited) SetClock(c clock.Clock) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.clock = clock.OrReal(c)
+}
+
+// Printf logs the message if the interval has elapsed since the last one
+func (r *RateLimited) Printf(format string, args ...any) {
+	r.PrintfContext(context.Background(), format, args...)
+}
+
+// PrintfContext is Printf with the correlation attributes of ctx (see
+// WithAttrs)
+func (r *RateLimited) PrintfContext(ctx context.Context, format string, args ...any) {
+	r.mu.Lock()
+	now := r.clock.Now()
+	if !r.last.IsZero() && now.Sub(r.last) < r.interval {
+		r.suppressed++
+		r.mu.Unlock()
+		return
+	}
+	suppressed := r.suppressed
+	r.suppressed = 0
+	r.last = now
+	r.mu.Unlock()
+
+	var attrs []any
+	if suppressed > 0 {
+		attrs = append(attrs, "suppressed", suppressed)
+	}
+	r.logger.ErrorContext(ctx, fmt.Sprintf(format, args...), attrs...)
+}
+
+// Sampled logs one out of every n messages
+type Sampled struct {
+	every uint64
+	count atomic.Uint64
+}
+
+// NewSampled creates a logger that emits every nth message (n <= 1 logs all)
+func NewSampled(n uint64) *Sampled {
+	if n == 0 {
+		n = 1
+	}
+	return &Sampled{every: n}
+}
+
+// Printf logs the message if it falls on the sampling boundary
+func (s *Sampled) Printf(format string, args ...any) {
+	n := s.count.Add(1)
+	if (n-1)%s.every != 0 {
+		return
+	}
+	msg := fmt.Sprintf(format, args...)
+	if s.every > 1 {
+		slog.Info(msg, "sampled", fmt.Sprintf("1/%d", s.every))
+		return
+	}
+	slog.Info(msg)
+}
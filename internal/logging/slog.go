@@ -0,0 +1,133 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// Correlation fields, so the lines about one trade or message can be found
+// together whatever subsystem logged them
+const (
+	KeySubsystem = "subsystem"
+	KeyTxHash    = "tx_hash"
+	KeyWallet    = "wallet"
+	KeyTopic     = "topic"
+)
+
+// Setup makes slog's default logger write to w at level ("debug", "info",
+// "warn" or "error") in format ("text" or "json"). The standard log package
+// is routed through it too, so log.Printf lines come out structured, at
+// info level.
+func Setup(w io.Writer, level, format string) error {
+	if err := SetLevel(level); err != nil {
+		return err
+	}
+	opts := &slog.HandlerOptions{Level: &minLevel}
+	var h slog.Handler
+	switch strings.ToLower(format) {
+	case "", "text":
+		h = slog.NewTextHandler(w, opts)
+	case "json":
+		h = slog.NewJSONHandler(w, opts)
+	default:
+		return fmt.Errorf("invalid log format %q", format)
+	}
+	slog.SetDefault(slog.New(h))
+	return nil
+}
+
+// minLevel is the level of the handler installed by Setup
+var minLevel slog.LevelVar
+
+// SetLevel changes the level logged from, e.g. on a configuration reload
+func SetLevel(level string) error {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("invalid log level %q", level)
+	}
+	minLevel.Set(lvl)
+	return nil
+}
+
+// For returns the logger of a subsystem: its lines carry subsystem=name.
+// It writes through whatever slog's default logger is when it logs, so
+// package-level loggers created before Setup pick up its level and format.
+func For(subsystem string) *slog.Logger {
+	return slog.New(deferred{}).With(KeySubsystem, subsystem)
+}
+
+// WithAttrs returns a context carrying correlation attributes (key-value
+// pairs as in slog.Logger.With). Loggers from For add them to the lines
+// logged with that context.
+func WithAttrs(ctx context.Context, args ...any) context.Context {
+	if len(args) == 0 {
+		return ctx
+	}
+	prev, _ := ctx.Value(attrsKey{}).([]any)
+	return context.WithValue(ctx, attrsKey{}, append(prev[:len(prev):len(prev)], args...))
+}
+
+type attrsKey struct{}
+
+// deferred hands records to slog's current default handler, with the
+// attributes and groups given to it so far and those of the context
+type deferred struct {
+	wrap func(slog.Handler) slog.Handler
+}
+
+func (d deferred) handler() slog.Handler {
+	h := slog.Default().Handler()
+	if d.wrap != nil {
+		h = d.wrap(h)
+	}
+	return h
+}
+
+func (d deferred) Enabled(ctx context.Context, level slog.Level) bool {
+	return slog.Default().Handler().Enabled(ctx, level)
+}
+
+func (d deferred) Handle(ctx context.Context, r slog.Record) error {
+	if args, ok := ctx.Value(attrsKey{}).([]any); ok {
+		r = r.Clone()
+		r.Add(args...)
+	}
+	return d.handler().Handle(ctx, r)
+}
+
+func (d deferred) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return d.then(func(h slog.Handler) slog.Handler { return h.WithAttrs(attrs) })
+}
+
+func (d deferred) WithGroup(name string) slog.Handler {
+	return d.then(func(h slog.Handler) slog.Handler { return h.WithGroup(name) })
+}
+
+func (d deferred) then(next func(slog.Handler) slog.Handler) deferred {
+	prev := d.wrap
+	return deferred{wrap: func(h slog.Handler) slog.Handler {
+		if prev != nil {
+			h = prev(h)
+		}
+		return next(h)
+	}}
+}
+
+// Printer adapts a slog.Logger to the Printf loggers taken by the rtds and
+// pipeline packages, logging each message at Level
+type Printer struct {
+	Logger *slog.Logger
+	Level  slog.Level
+}
+
+// Printf logs the formatted message
+func (p Printer) Printf(format string, args ...any) {
+	ctx := context.Background()
+	if !p.Logger.Enabled(ctx, p.Level) {
+		return
+	}
+	p.Logger.Log(ctx, p.Level, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,75 @@
+package metrics
+
+import (
+	"math"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// LatencyBuckets are histogram bounds in seconds for I/O latencies
+var LatencyBuckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5}
+
+// Histogram counts observations into fixed buckets. It is safe for
+// concurrent use and cheap enough for hot paths.
+type Histogram struct {
+	bounds []float64
+	counts []atomic.Uint64 // One per bound, plus +Inf
+	sum    atomic.Uint64   // float64 bits
+	count  atomic.Uint64
+}
+
+// NewHistogram creates a histogram with the given upper bounds, which are
+// sorted
+func NewHistogram(bounds []float64) *Histogram {
+	b := append([]float64(nil), bounds...)
+	sort.Float64s(b)
+	return &Histogram{bounds: b, counts: make([]atomic.Uint64, len(b)+1)}
+}
+
+// Observe records a value
+func (h *Histogram) Observe(v float64) {
+	h.counts[sort.SearchFloat64s(h.bounds, v)].Add(1)
+	for {
+		old := h.sum.Load()
+		if h.sum.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+v)) {
+			break
+		}
+	}
+	h.count.Add(1)
+}
+
+// ObserveDuration records a duration in seconds
+func (h *Histogram) ObserveDuration(d time.Duration) {
+	h.Observe(d.Seconds())
+}
+
+// HistogramSnapshot is a point-in-time view of a histogram. Counts are
+// cumulative: Counts[i] observations were <= Bounds[i].
+type HistogramSnapshot struct {
+	Bounds []float64
+	Counts []uint64
+	Sum    float64
+	Count  uint64
+}
+
+// Snapshot returns the current counts
+func (h *Histogram) Snapshot() HistogramSnapshot {
+	s := HistogramSnapshot{
+		Bounds: h.bounds,
+		Counts: make([]uint64, len(h.bounds)),
+		Sum:    math.Float64frombits(h.sum.Load()),
+	}
+	var total uint64
+	for i := range h.bounds {
+		total += h.counts[i].Load()
+		s.Counts[i] = total
+	}
+	s.Count = total + h.counts[len(h.bounds)].Load()
+	return s
+}
+
+// HistogramExporter is implemented by exporters with native histograms
+type HistogramExporter interface {
+	Histogram(name string, h HistogramSnapshot, tags []string)
+}
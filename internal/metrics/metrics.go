@@ -0,0 +1,133 @@
+// Package metrics reports internal counters, gauges and histograms
+// (pipeline stages, sink health, ...) to an external metrics backend: pushed
+// to StatsD on an interval, or served to Prometheus on scrape.
+package metrics
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/FatwaArya/pm-ingest/internal/logging"
+	"github.com/FatwaArya/pm-ingest/pkg/clock"
+)
+
+var metricsLog = logging.For("metrics")
+
+// Exporter names selectable with METRICS_EXPORTER
+const (
+	ExporterNone       = "none"
+	ExporterStatsD     = "statsd"
+	ExporterDogStatsD  = "dogstatsd"
+	ExporterPrometheus = "prometheus"
+)
+
+// Exporter sends metrics to a backend. Tags are "key:value" pairs; backends
+// without tag support drop them.
+type Exporter interface {
+	Gauge(name string, value float64, tags []string)
+	Count(name string, delta int64, tags []string)
+	// Flush sends buffered metrics
+	Flush() error
+	Close() error
+}
+
+// Source adds its current values to the reporter on every report
+type Source func(r *Reporter)
+
+// Reporter collects metrics from sources every interval and sends them to
+// the exporter. Cumulative counters are converted to deltas, since StatsD
+// counts are per flush.
+type Reporter struct {
+	exporter Exporter
+	clock    clock.Clock
+
+	mu      sync.Mutex
+	sources []Source
+	totals  map[string]uint64
+}
+
+// NewReporter creates a reporter sending to exporter
+func NewReporter(exporter Exporter) *Reporter {
+	return &Reporter{
+		exporter: exporter,
+		clock:    clock.Real,
+		totals:   make(map[string]uint64),
+	}
+}
+
+// SetClock replaces the clock driving the report interval
+func (r *Reporter) SetClock(c clock.Clock) {
+	r.clock = clock.OrReal(c)
+}
+
+// AddSource adds a source collected on every report
+func (r *Reporter) AddSource(s Source) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sources = append(r.sources, s)
+}
+
+// Gauge reports the current value of name
+func (r *Reporter) Gauge(name string, value float64, tags ...string) {
+	r.exporter.Gauge(name, value, tags)
+}
+
+// Total reports a cumulative counter as the increase since the last report.
+// The first report of a counter (and one after a reset) sends the full total.
+func (r *Reporter) Total(name string, total uint64, tags ...string) {
+	key := name + "|" + strings.Join(tags, ",")
+	last, seen := r.totals[key]
+	r.totals[key] = total
+	if seen && total >= last {
+		total -= last
+	}
+	if total > 0 {
+		r.exporter.Count(name, int64(total), tags)
+	}
+}
+
+// Histogram reports a histogram. Exporters without native histograms get
+// its count (as a counter) and mean (as a gauge, name.avg).
+func (r *Reporter) Histogram(name string, h *Histogram, tags ...string) {
+	snapshot := h.Snapshot()
+	if he, ok := r.exporter.(HistogramExporter); ok {
+		he.Histogram(name, snapshot, tags)
+		return
+	}
+	r.Total(name+".count", snapshot.Count, tags...)
+	if snapshot.Count > 0 {
+		r.Gauge(name+".avg", snapshot.Sum/float64(snapshot.Count), tags...)
+	}
+}
+
+// Report collects every source and flushes the exporter
+func (r *Reporter) Report() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, s := range r.sources {
+		s(r)
+	}
+	return r.exporter.Flush()
+}
+
+// Run reports every interval until ctx is cancelled, then reports once more
+// and closes the exporter
+func (r *Reporter) Run(ctx context.Context, interval time.Duration) error {
+	ticker := r.clock.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			if err := r.Report(); err != nil {
+				metricsLog.ErrorContext(ctx, "Metrics report error", "error", err)
+			}
+			return r.exporter.Close()
+		case <-ticker.C():
+			if err := r.Report(); err != nil {
+				metricsLog.ErrorContext(ctx, "Metrics report error", "error", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Prometheus is an Exporter keeping the latest values in memory and
+// serving them in the Prometheus text format. Counters accumulate the
+// deltas the reporter sends; tags ("key:value") become labels.
+type Prometheus struct {
+	prefix string
+	report func() error
+
+	mu     sync.Mutex
+	series map[string]*promSeries // By metric name, then labels
+}
+
+type promSeries struct {
+	kind   string // counter, gauge or histogram
+	values map[string]float64
+	hists  map[string]HistogramSnapshot
+}
+
+// NewPrometheus creates an exporter prefixing metric names with prefix
+func NewPrometheus(prefix string) *Prometheus {
+	return &Prometheus{prefix: prefix, series: make(map[string]*promSeries)}
+}
+
+// CollectOnScrape makes every scrape run report first (usually
+// Reporter.Report), so values are current instead of up to an interval old
+func (p *Prometheus) CollectOnScrape(report func() error) {
+	p.report = report
+}
+
+// get returns the series of a metric, creating it; called with mu held
+func (p *Prometheus) get(name, kind string) *promSeries {
+	s, ok := p.series[name]
+	if !ok {
+		s = &promSeries{kind: kind, values: make(map[string]float64), hists: make(map[string]HistogramSnapshot)}
+		p.series[name] = s
+	}
+	return s
+}
+
+// Gauge sets a gauge
+func (p *Prometheus) Gauge(name string, value float64, tags []string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.get(p.metricName(name, ""), "gauge").values[promLabels(tags)] = value
+}
+
+// Count adds to a counter
+func (p *Prometheus) Count(name string, delta int64, tags []string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.get(p.metricName(name, "_total"), "counter").values[promLabels(tags)] += float64(delta)
+}
+
+// Histogram replaces a histogram's snapshot
+func (p *Prometheus) Histogram(name string, h HistogramSnapshot, tags []string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.get(p.metricName(name, "_seconds"), "histogram").hists[promLabels(tags)] = h
+}
+
+// Flush is a no-op: values are served on scrape
+func (p *Prometheus) Flush() error { return nil }
+
+// Close is a no-op
+func (p *Prometheus) Close() error { return nil }
+
+// ServeHTTP serves the metrics, e.g. on /metrics
+func (p *Prometheus) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if p.report != nil {
+		if err := p.report(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	p.Write(w)
+}
+
+// Write writes the metrics in the text exposition format
+func (p *Prometheus) Write(w io.Writer) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	bw := bufio.NewWriter(w)
+	names := make([]string, 0, len(p.series))
+	for name := range p.series {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		s := p.series[name]
+		bw.WriteString("# TYPE " + name + " " + s.kind + "\n")
+		if s.kind == "histogram" {
+			for _, labels := range sortedKeys(s.hists) {
+				writeHistogram(bw, name, labels, s.hists[labels])
+			}
+			continue
+		}
+		for _, labels := range sortedKeys(s.values) {
+			bw.WriteString(name + braces(labels) + " " + formatFloat(s.values[labels]) + "\n")
+		}
+	}
+	return bw.Flush()
+}
+
+// writeHistogram writes the bucket, sum and count lines of a histogram
+func writeHistogram(w *bufio.Writer, name, labels string, h HistogramSnapshot) {
+	for i, bound := range h.Bounds {
+		w.WriteString(name + "_bucket" + braces(joinLabels(labels, `le="`+formatFloat(bound)+`"`)) +
+			" " + strconv.FormatUint(h.Counts[i], 10) + "\n")
+	}
+	w.WriteString(name + "_bucket" + braces(joinLabels(labels, `le="+Inf"`)) + " " + strconv.FormatUint(h.Count, 10) + "\n")
+	w.WriteString(name + "_sum" + braces(labels) + " " + formatFloat(h.Sum) + "\n")
+	w.WriteString(name + "_count" + braces(labels) + " " + strconv.FormatUint(h.Count, 10) + "\n")
+}
+
+// metricName turns a dotted reporter name into a Prometheus metric name
+func (p *Prometheus) metricName(name, suffix string) string {
+	return sanitize(p.prefix+name) + suffix
+}
+
+// sanitize replaces characters Prometheus doesn't allow in names with _
+func sanitize(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == ':':
+			return r
+		}
+		return '_'
+	}, s)
+}
+
+// promLabels formats "key:value" tags as sorted label pairs
+func promLabels(tags []string) string {
+	pairs := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		key, value, _ := strings.Cut(tag, ":")
+		value = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+		pairs = append(pairs, sanitize(key)+`="`+value+`"`)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func joinLabels(a, b string) string {
+	if a == "" {
+		return b
+	}
+	return a + "," + b
+}
+
+func braces(labels string) string {
+	if labels == "" {
+		return ""
+	}
+	return "{" + labels + "}"
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
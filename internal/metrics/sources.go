@@ -0,0 +1,78 @@
+package metrics
+
+import (
+	"github.com/FatwaArya/pm-ingest/internal/health"
+	"github.com/FatwaArya/pm-ingest/internal/pipeline"
+)
+
+// PipelineSource reports per-stage counters of the pipeline returned by p,
+// which may return nil until the pipeline is running
+func PipelineSource(p func() *pipeline.Pipeline) Source {
+	return func(r *Reporter) {
+		ingest := p()
+		if ingest == nil {
+			return
+		}
+		for _, s := range ingest.Stats() {
+			tags := []string{"stage:" + s.Name}
+			r.Total("pipeline.in", s.In, tags...)
+			r.Total("pipeline.out", s.Out, tags...)
+			r.Total("pipeline.filtered", s.Filtered, tags...)
+			r.Total("pipeline.errors", s.Errors, tags...)
+			r.Total("pipeline.retries", s.Retries, tags...)
+			r.Total("pipeline.dropped", s.Dropped, tags...)
+			r.Gauge("pipeline.queued", float64(s.Queued), tags...)
+			r.Gauge("pipeline.busy_workers", float64(s.BusyWorkers), tags...)
+			r.Gauge("pipeline.latency_ms", float64(s.AvgLatency.Microseconds())/1000, tags...)
+		}
+	}
+}
+
+// PoolSource reports the queue and counters of a worker pool
+func PoolSource(name string, p *pipeline.Pool) Source {
+	return func(r *Reporter) {
+		s := p.Stats()
+		tags := []string{"pool:" + name}
+		r.Gauge("pool.queued", float64(s.Queued), tags...)
+		r.Total("pool.done", s.Done, tags...)
+		r.Total("pool.dropped", s.Dropped, tags...)
+	}
+}
+
+// SinkSource reports the health of the sinks tracked by lifecycle
+func SinkSource(lifecycle *health.Lifecycle) Source {
+	return func(r *Reporter) {
+		for name, s := range lifecycle.SinkStatuses() {
+			tags := []string{"sink:" + name}
+			degraded := 0.0
+			if s.State == health.SinkDegraded {
+				degraded = 1
+			}
+			r.Gauge("sink.degraded", degraded, tags...)
+			r.Total("sink.failures", s.FailuresTotal, tags...)
+			r.Total("sink.successes", s.SuccessesTotal, tags...)
+		}
+	}
+}
+
+// ConsumerLagSource reports how far a Kafka consumer is behind, tagged with
+// the service consuming
+func ConsumerLagSource(service string, lag func() int64) Source {
+	return func(r *Reporter) {
+		r.Gauge("kafka.consumer_lag", float64(lag()), "service:"+service)
+	}
+}
+
+// CounterSource reports the cumulative counter returned by total
+func CounterSource(name string, total func() uint64, tags ...string) Source {
+	return func(r *Reporter) {
+		r.Total(name, total(), tags...)
+	}
+}
+
+// HistogramSource reports a histogram
+func HistogramSource(name string, h *Histogram, tags ...string) Source {
+	return func(r *Reporter) {
+		r.Histogram(name, h, tags...)
+	}
+}
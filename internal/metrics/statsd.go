@@ -0,0 +1,97 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// maxPacketSize keeps UDP packets under the typical MTU, as the Datadog
+// agent recommends
+const maxPacketSize = 1432
+
+// StatsD is an Exporter writing the StatsD line protocol over UDP. With
+// DogStatsD, tags are sent in the Datadog extension format (|#k:v,...);
+// plain StatsD drops them.
+type StatsD struct {
+	conn       net.Conn
+	prefix     string
+	globalTags []string
+	dogstatsd  bool
+
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+// NewStatsD connects to the agent at addr. prefix is prepended to every
+// metric name and tags are added to every metric.
+func NewStatsD(addr, prefix string, tags []string, dogstatsd bool) (*StatsD, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to statsd at %s: %w", addr, err)
+	}
+	return &StatsD{
+		conn:       conn,
+		prefix:     prefix,
+		globalTags: tags,
+		dogstatsd:  dogstatsd,
+	}, nil
+}
+
+// Gauge buffers a gauge
+func (s *StatsD) Gauge(name string, value float64, tags []string) {
+	s.write(name, strconv.FormatFloat(value, 'f', -1, 64), "g", tags)
+}
+
+// Count buffers a counter increment
+func (s *StatsD) Count(name string, delta int64, tags []string) {
+	s.write(name, strconv.FormatInt(delta, 10), "c", tags)
+}
+
+func (s *StatsD) write(name, value, kind string, tags []string) {
+	line := s.prefix + name + ":" + value + "|" + kind
+	if s.dogstatsd {
+		if all := append(append([]string{}, s.globalTags...), tags...); len(all) > 0 {
+			line += "|#" + strings.Join(all, ",")
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.buf.Len() > 0 && s.buf.Len()+1+len(line) > maxPacketSize {
+		s.send()
+	}
+	if s.buf.Len() > 0 {
+		s.buf.WriteByte('\n')
+	}
+	s.buf.WriteString(line)
+}
+
+// Flush sends the buffered metrics
+func (s *StatsD) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.send()
+}
+
+// send writes the buffer as one packet; called with mu held
+func (s *StatsD) send() error {
+	if s.buf.Len() == 0 {
+		return nil
+	}
+	_, err := s.conn.Write(s.buf.Bytes())
+	s.buf.Reset()
+	return err
+}
+
+// Close flushes and closes the connection
+func (s *StatsD) Close() error {
+	flushErr := s.Flush()
+	if err := s.conn.Close(); err != nil {
+		return err
+	}
+	return flushErr
+}
@@ -0,0 +1,89 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/FatwaArya/pm-ingest/pkg/pmerrors"
+)
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// postJSON posts body as JSON to apiURL. logURL is reported in errors in its
+// place, so URLs holding secrets aren't logged.
+func postJSON(ctx context.Context, apiURL, logURL string, body any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, apiURL, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err // Without the URL
+		}
+		return fmt.Errorf("failed to make request to %s: %w", logURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return &pmerrors.APIError{StatusCode: resp.StatusCode, Body: string(body), URL: logURL}
+	}
+	return nil
+}
+
+// Webhook posts alerts as JSON: the rendered text and the alert's fields
+type Webhook struct {
+	URL string
+}
+
+func (w Webhook) Name() string { return "webhook" }
+
+func (w Webhook) Send(ctx context.Context, text string, alert Alert) error {
+	return postJSON(ctx, w.URL, "webhook", struct {
+		Text  string `json:"text"`
+		Alert Alert  `json:"alert"`
+	}{text, alert})
+}
+
+// Discord posts alerts to a Discord channel webhook
+type Discord struct {
+	WebhookURL string
+}
+
+func (d Discord) Name() string { return "discord" }
+
+func (d Discord) Send(ctx context.Context, text string, _ Alert) error {
+	return postJSON(ctx, d.WebhookURL, "discord webhook", map[string]string{"content": text})
+}
+
+// Telegram sends alerts to a chat through a bot
+type Telegram struct {
+	BotToken string
+	ChatID   string
+}
+
+func (t Telegram) Name() string { return "telegram" }
+
+func (t Telegram) Send(ctx context.Context, text string, _ Alert) error {
+	apiURL := fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage", t.BotToken)
+	return postJSON(ctx, apiURL, "telegram sendMessage", map[string]any{
+		"chat_id":                  t.ChatID,
+		"text":                     text,
+		"disable_web_page_preview": true,
+	})
+}
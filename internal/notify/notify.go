@@ -0,0 +1,204 @@
+// Package notify pushes whale trade alerts to webhooks, Discord and
+// Telegram. Each target has its own size threshold and rate limit, and
+// renders alerts with a text/template.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	internalkafka "github.com/FatwaArya/pm-ingest/internal/kafka"
+	"github.com/FatwaArya/pm-ingest/internal/logging"
+	"github.com/FatwaArya/pm-ingest/pkg/clock"
+)
+
+// DefaultTemplate renders an alert as a one-line summary with links
+const DefaultTemplate = `Whale {{.Side}} ${{printf "%.0f" .Notional}} of {{.Outcome}} on {{.MarketSlug}} at {{printf "%.3f" .Price}} by {{.Trader}}
+{{.MarketURL}}
+{{.ProfileURL}}`
+
+var (
+	notifyLog  = logging.For("notify")
+	sendErrLog = logging.NewRateLimited(logging.For("notify"), 5*time.Second)
+)
+
+// Alert is a high-value trade, as passed to templates
+type Alert struct {
+	MarketSlug string
+	EventSlug  string
+	Title      string
+	Outcome    string
+	Side       string
+	Size       float64 // Shares
+	Price      float64
+	Notional   float64 // USD
+	Wallet     string
+	Trader     string // Display name, pseudonym or shortened wallet
+	Labels     []string
+	TxHash     string
+	Time       time.Time
+	MarketURL  string
+	ProfileURL string
+}
+
+// NewAlert creates the alert for trade
+func NewAlert(trade internalkafka.TradeMessage) Alert {
+	trader := trade.Name
+	if trader == "" {
+		trader = trade.Pseudonym
+	}
+	if trader == "" {
+		trader = shortWallet(trade.ProxyWallet)
+	}
+	eventSlug := trade.EventSlug
+	if eventSlug == "" {
+		eventSlug = trade.Slug
+	}
+	return Alert{
+		MarketSlug: trade.Slug,
+		EventSlug:  trade.EventSlug,
+		Title:      trade.Title,
+		Outcome:    trade.Outcome,
+		Side:       trade.Side,
+		Size:       trade.Size,
+		Price:      trade.Price,
+		Notional:   trade.Size * trade.Price,
+		Wallet:     trade.ProxyWallet,
+		Trader:     trader,
+		Labels:     trade.Labels,
+		TxHash:     trade.TransactionHash,
+		Time:       time.Unix(trade.Timestamp, 0).UTC(),
+		MarketURL:  "https://polymarket.com/event/" + eventSlug,
+		ProfileURL: "https://polymarket.com/profile/" + trade.ProxyWallet,
+	}
+}
+
+func shortWallet(wallet string) string {
+	if len(wallet) <= 10 {
+		return wallet
+	}
+	return wallet[:6] + "…" + wallet[len(wallet)-4:]
+}
+
+// Channel delivers rendered alerts
+type Channel interface {
+	Name() string
+	Send(ctx context.Context, text string, alert Alert) error
+}
+
+// Target is a channel with the alerts it should receive
+type Target struct {
+	Channel     Channel
+	MinNotional float64 // USD; smaller trades aren't sent
+	PerMinute   int     // Alerts sent per minute at most; 0 is unlimited
+}
+
+type target struct {
+	Target
+	tmpl *template.Template
+
+	mu     sync.Mutex
+	tokens float64
+	refill time.Time
+}
+
+// allow takes a token from the target's bucket, refilled at PerMinute a
+// minute up to PerMinute
+func (t *target) allow(now time.Time) bool {
+	if t.PerMinute <= 0 {
+		return true
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	capacity := float64(t.PerMinute)
+	if t.refill.IsZero() {
+		t.tokens = capacity
+	} else {
+		t.tokens = min(capacity, t.tokens+now.Sub(t.refill).Minutes()*capacity)
+	}
+	t.refill = now
+	if t.tokens < 1 {
+		return false
+	}
+	t.tokens--
+	return true
+}
+
+// Notifier sends alerts to its targets
+type Notifier struct {
+	targets []*target
+	clock   clock.Clock
+}
+
+// New creates a notifier without targets
+func New() *Notifier {
+	return &Notifier{clock: clock.OrReal(nil)}
+}
+
+// SetClock replaces the clock used for rate limiting
+func (n *Notifier) SetClock(c clock.Clock) {
+	n.clock = clock.OrReal(c)
+}
+
+// Add adds a target, rendering its alerts with tmpl (DefaultTemplate if
+// empty)
+func (n *Notifier) Add(t Target, tmpl string) error {
+	if tmpl == "" {
+		tmpl = DefaultTemplate
+	}
+	parsed, err := template.New(t.Channel.Name()).Parse(tmpl)
+	if err != nil {
+		return fmt.Errorf("alert template for %s: %w", t.Channel.Name(), err)
+	}
+	n.targets = append(n.targets, &target{Target: t, tmpl: parsed})
+	return nil
+}
+
+// Empty reports whether the notifier has no targets
+func (n *Notifier) Empty() bool {
+	return len(n.targets) == 0
+}
+
+// MinNotional returns the lowest threshold of any target: smaller trades
+// are never sent
+func (n *Notifier) MinNotional() float64 {
+	if n.Empty() {
+		return 0
+	}
+	lowest := n.targets[0].MinNotional
+	for _, t := range n.targets[1:] {
+		lowest = min(lowest, t.MinNotional)
+	}
+	return lowest
+}
+
+// Notify sends alert to every target whose threshold it meets and whose
+// rate limit allows it. Send errors are logged, not returned: an alert is
+// best effort and never holds up ingestion.
+func (n *Notifier) Notify(ctx context.Context, alert Alert) {
+	for _, t := range n.targets {
+		if alert.Notional < t.MinNotional || !t.allow(n.clock.Now()) {
+			continue
+		}
+		var text bytes.Buffer
+		if err := t.tmpl.Execute(&text, alert); err != nil {
+			sendErrLog.Printf("Error rendering alert for %s: %v", t.Channel.Name(), err)
+			continue
+		}
+		if err := t.Channel.Send(ctx, strings.TrimSpace(text.String()), alert); err != nil {
+			sendErrLog.Printf("Error sending alert to %s: %v", t.Channel.Name(), err)
+		}
+	}
+}
+
+// LogTargets logs the configured targets
+func (n *Notifier) LogTargets() {
+	for _, t := range n.targets {
+		notifyLog.Info("Whale alerts enabled", "channel", t.Channel.Name(), "min_notional", t.MinNotional)
+	}
+}
@@ -0,0 +1,79 @@
+package pipeline
+
+import (
+	"context"
+	"time"
+
+	"github.com/FatwaArya/pm-ingest/internal/store"
+	"github.com/FatwaArya/pm-ingest/pkg/clock"
+	"github.com/FatwaArya/pm-ingest/pkg/rtds"
+)
+
+// Deduper remembers trades by their DedupeKey for a TTL, so the same fill
+// seen again after a WebSocket reconnect, from an overlapping replica or
+// from a backfill is dropped. The store (Redis, to share it between
+// replicas and backfill runs) is fronted by a bounded LRU of the keys this
+// process has seen, sparing a round trip for the common duplicates.
+//
+// Order IDs aren't part of the key: trades backfilled from the Data API
+// don't carry them, and must still match the live fill.
+type Deduper struct {
+	store  store.Store
+	ttl    time.Duration
+	recent *store.LRUStore // Nil without an LRU
+}
+
+// NewDeduper creates a deduper remembering trades in s for ttl, with an LRU
+// of up to size keys in front (none if size <= 0)
+func NewDeduper(s store.Store, ttl time.Duration, size int) *Deduper {
+	d := &Deduper{store: s, ttl: ttl}
+	if size > 0 {
+		d.recent = store.NewLRUStore(size)
+	}
+	return d
+}
+
+// SetClock replaces the clock the LRU's expiry is measured against. The
+// store's expiry is its own, see store.MemoryStore.SetClock.
+func (d *Deduper) SetClock(c clock.Clock) {
+	if d.recent != nil {
+		d.recent.SetClock(c)
+	}
+}
+
+// Seen reports whether trade was already seen within the TTL, and marks it
+// seen otherwise
+func (d *Deduper) Seen(ctx context.Context, trade *rtds.ActivityTradePayload) (bool, error) {
+	key := store.PrefixTrade + trade.DedupeKey()
+	if d.recent != nil {
+		if _, ok, _ := d.recent.Get(ctx, key); ok {
+			return true, nil
+		}
+	}
+	isNew, err := d.store.SetIfAbsent(ctx, key, d.ttl)
+	if err != nil {
+		return false, err
+	}
+	// Only keys set here have a known expiry; a duplicate's may be sooner
+	if isNew && d.recent != nil {
+		d.recent.Set(ctx, key, nil, d.ttl)
+	}
+	return !isNew, nil
+}
+
+// Forget unmarks trade, e.g. when it couldn't be delivered after all
+func (d *Deduper) Forget(ctx context.Context, trade *rtds.ActivityTradePayload) error {
+	key := store.PrefixTrade + trade.DedupeKey()
+	if d.recent != nil {
+		d.recent.Delete(ctx, key)
+	}
+	return d.store.Delete(ctx, key)
+}
+
+// Len returns the number of keys in the LRU
+func (d *Deduper) Len() int {
+	if d.recent == nil {
+		return 0
+	}
+	return d.recent.Len()
+}
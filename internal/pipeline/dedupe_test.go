@@ -0,0 +1,70 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/FatwaArya/pm-ingest/internal/store"
+	"github.com/FatwaArya/pm-ingest/pkg/clock"
+	"github.com/FatwaArya/pm-ingest/pkg/rtds"
+)
+
+func TestDeduper(t *testing.T) {
+	ctx := context.Background()
+	d := NewDeduper(store.NewMemoryStore(), time.Minute, 2)
+	trade := func(hash string) *rtds.ActivityTradePayload {
+		return &rtds.ActivityTradePayload{TransactionHash: hash, Asset: "1", Side: rtds.SideBuy, Size: 10, Price: 0.5}
+	}
+
+	for _, tt := range []struct {
+		hash string
+		seen bool
+	}{
+		{"0xa", false},
+		{"0xa", true},
+		{"0xb", false},
+		{"0xc", false}, // Evicts 0xa from the LRU; the store still has it
+		{"0xa", true},
+	} {
+		seen, err := d.Seen(ctx, trade(tt.hash))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seen != tt.seen {
+			t.Errorf("Seen(%s) = %t, want %t", tt.hash, seen, tt.seen)
+		}
+	}
+	if n := d.Len(); n != 2 {
+		t.Errorf("Len = %d, want 2", n)
+	}
+
+	if err := d.Forget(ctx, trade("0xb")); err != nil {
+		t.Fatal(err)
+	}
+	if seen, _ := d.Seen(ctx, trade("0xb")); seen {
+		t.Error("forgotten trade was seen")
+	}
+}
+
+func TestDeduperExpiry(t *testing.T) {
+	ctx := context.Background()
+	fake := clock.NewFake(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	s := store.NewMemoryStore()
+	s.SetClock(fake)
+	d := NewDeduper(s, time.Minute, 10)
+	d.SetClock(fake)
+	trade := &rtds.ActivityTradePayload{TransactionHash: "0xa", Asset: "1", Side: rtds.SideBuy, Size: 10, Price: 0.5}
+
+	if seen, _ := d.Seen(ctx, trade); seen {
+		t.Fatal("new trade was seen")
+	}
+	fake.Advance(30 * time.Second)
+	if seen, _ := d.Seen(ctx, trade); !seen {
+		t.Error("trade within the TTL wasn't seen")
+	}
+	fake.Advance(time.Minute)
+	if seen, _ := d.Seen(ctx, trade); seen {
+		t.Error("trade past the TTL was seen")
+	}
+}
@@ -0,0 +1,111 @@
+package pipeline
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/FatwaArya/pm-ingest/pkg/rtds"
+	"github.com/google/cel-go/cel"
+)
+
+// filterEnv declares the trade fields available to filter expressions.
+// Names follow the trade's JSON fields; notional is size * price. Numbers
+// of different types compare, so `size > 1000` works on a double field.
+func filterEnv() (*cel.Env, error) {
+	return cel.NewEnv(
+		cel.CrossTypeNumericComparisons(true),
+		cel.Variable("asset", cel.StringType),
+		cel.Variable("side", cel.StringType),
+		cel.Variable("price", cel.DoubleType),
+		cel.Variable("size", cel.DoubleType),
+		cel.Variable("notional", cel.DoubleType),
+		cel.Variable("fee", cel.DoubleType),
+		cel.Variable("timestamp", cel.IntType),
+		cel.Variable("conditionId", cel.StringType),
+		cel.Variable("outcomeIndex", cel.IntType),
+		cel.Variable("outcome", cel.StringType),
+		cel.Variable("slug", cel.StringType),
+		cel.Variable("eventSlug", cel.StringType),
+		cel.Variable("title", cel.StringType),
+		cel.Variable("proxyWallet", cel.StringType),
+		cel.Variable("name", cel.StringType),
+		cel.Variable("pseudonym", cel.StringType),
+		cel.Variable("role", cel.StringType),
+		cel.Variable("aggressorSide", cel.StringType),
+	)
+}
+
+// CompileFilter compiles a CEL expression over a trade's fields, e.g.
+//
+//	size*price > 5000 && eventSlug.contains("election")
+//
+// into a predicate. The expression must evaluate to a bool.
+func CompileFilter(expression string) (func(*rtds.ActivityTradePayload) (bool, error), error) {
+	env, err := filterEnv()
+	if err != nil {
+		return nil, err
+	}
+	ast, issues := env.Compile(expression)
+	if issues.Err() != nil {
+		return nil, fmt.Errorf("invalid filter expression: %w", issues.Err())
+	}
+	if ast.OutputType() != cel.BoolType {
+		return nil, fmt.Errorf("filter expression must be a bool, not %s", ast.OutputType())
+	}
+	prg, err := env.Program(ast)
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter expression: %w", err)
+	}
+
+	return func(trade *rtds.ActivityTradePayload) (bool, error) {
+		out, _, err := prg.Eval(map[string]any{
+			"asset":         trade.Asset,
+			"side":          trade.Side,
+			"price":         trade.Price,
+			"size":          trade.Size,
+			"notional":      trade.Size * trade.Price,
+			"fee":           trade.Fee,
+			"timestamp":     trade.Timestamp,
+			"conditionId":   trade.ConditionID,
+			"outcomeIndex":  trade.OutcomeIndex,
+			"outcome":       trade.OutcomeTitle,
+			"slug":          trade.MarketSlug,
+			"eventSlug":     trade.EventSlug,
+			"title":         trade.EventTitle,
+			"proxyWallet":   trade.ProxyWalletAddress,
+			"name":          trade.Name,
+			"pseudonym":     trade.Pseudonym,
+			"role":          trade.Role(),
+			"aggressorSide": trade.AggressorSide(),
+		})
+		if err != nil {
+			return false, err
+		}
+		keep, ok := out.Value().(bool)
+		if !ok {
+			return false, fmt.Errorf("filter returned %T, not bool", out.Value())
+		}
+		return keep, nil
+	}, nil
+}
+
+// Expr keeps trades matching a CEL filter expression (see CompileFilter).
+// Trades the expression fails on (e.g. division by zero) are passed on
+// rather than lost.
+func Expr(expression string, logger Logger) (Middleware, error) {
+	match, err := CompileFilter(expression)
+	if err != nil {
+		return nil, err
+	}
+	if logger == nil {
+		logger = log.Default()
+	}
+	return Filter(func(trade *rtds.ActivityTradePayload) bool {
+		keep, err := match(trade)
+		if err != nil {
+			logger.Printf("Error evaluating filter for id=%s: %v", trade.TransactionHash, err)
+			return true
+		}
+		return keep
+	}), nil
+}
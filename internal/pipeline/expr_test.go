@@ -0,0 +1,52 @@
+package pipeline
+
+import (
+	"testing"
+
+	"github.com/FatwaArya/pm-ingest/pkg/rtds"
+)
+
+func TestCompileFilter(t *testing.T) {
+	trade := &rtds.ActivityTradePayload{
+		Side:      rtds.SideBuy,
+		Price:     0.5,
+		Size:      20000,
+		EventSlug: "presidential-election-2028",
+	}
+
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{`size*price > 5000 && eventSlug.contains("election")`, true},
+		{`notional > 50000`, false},
+		{`side == "BUY" || eventSlug.startsWith("nba")`, true},
+		{`eventSlug.contains("sports")`, false},
+	}
+	for _, tt := range tests {
+		match, err := CompileFilter(tt.expr)
+		if err != nil {
+			t.Fatalf("CompileFilter(%q): %v", tt.expr, err)
+		}
+		got, err := match(trade)
+		if err != nil {
+			t.Fatalf("%q: %v", tt.expr, err)
+		}
+		if got != tt.want {
+			t.Errorf("%q = %t, want %t", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestCompileFilterRejectsInvalid(t *testing.T) {
+	for _, expr := range []string{
+		`size * price`,     // Not a bool
+		`volume > 10`,      // Unknown field
+		`eventSlug > 5000`, // Type mismatch
+		`size > 1000 &&`,   // Syntax error
+	} {
+		if _, err := CompileFilter(expr); err == nil {
+			t.Errorf("CompileFilter(%q) succeeded, want error", expr)
+		}
+	}
+}
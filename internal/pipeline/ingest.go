@@ -0,0 +1,135 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/FatwaArya/pm-ingest/pkg/rtds"
+)
+
+// IngestOption configures NewIngest
+type IngestOption func(*ingestConfig)
+
+type ingestConfig struct {
+	userTrade func(*rtds.ClobUserTrade)
+	userOrder func(*rtds.ClobUserOrder)
+	channel   func(ctx context.Context, topic, messageType string, message any)
+	dead      func(ctx context.Context, stage string, payload []byte, err error)
+	parse     StageConfig
+}
+
+// WithUserTrades passes clob_user trade updates, parsed alongside activity
+// trades, to fn. fn runs on the parse workers and must not block.
+func WithUserTrades(fn func(*rtds.ClobUserTrade)) IngestOption {
+	return func(c *ingestConfig) { c.userTrade = fn }
+}
+
+// WithUserOrders passes clob_user order events (placements, updates and
+// cancellations) to fn. fn runs on the parse workers and must not block.
+func WithUserOrders(fn func(*rtds.ClobUserOrder)) IngestOption {
+	return func(c *ingestConfig) { c.userOrder = fn }
+}
+
+// WithChannels passes messages of registered RTDS channels (see
+// rtds.RegisterChannel) to fn. fn runs on the parse workers and must not
+// block.
+func WithChannels(fn func(ctx context.Context, topic, messageType string, message any)) IngestOption {
+	return func(c *ingestConfig) { c.channel = fn }
+}
+
+// WithDeadLetter passes items the ingest flow drops to fn: raw messages
+// that didn't parse (stage "parse") and JSON trades the sink failed to
+// write (stage "sink"). fn must not block.
+func WithDeadLetter(fn func(ctx context.Context, stage string, payload []byte, err error)) IngestOption {
+	return func(c *ingestConfig) { c.dead = fn }
+}
+
+// WithParseQueue sets the parse stage's workers, queue size and what Submit
+// does when that queue is full. More than one worker parses messages
+// concurrently, so trades of different messages may reach the sink out of
+// order.
+func WithParseQueue(workers, size int, overflow Overflow) IngestOption {
+	return func(c *ingestConfig) {
+		c.parse.Workers, c.parse.Buffer, c.parse.Overflow = workers, size, overflow
+	}
+}
+
+// channelHandler adds channel messages to the typed callbacks
+type channelHandler struct {
+	rtds.HandlerFuncs
+	ctx context.Context
+	fn  func(ctx context.Context, topic, messageType string, message any)
+}
+
+func (h channelHandler) OnChannel(topic, messageType string, message any) {
+	h.fn(h.ctx, topic, messageType, message)
+}
+
+// NewIngest builds the staged ingest flow:
+//
+//	parse   - raw WebSocket message -> activity trades (batches fan out);
+//	          channel messages go to the WithChannels callback
+//	process - the middleware chain; dropped trades count as filtered
+//	sink    - writeTrade
+//
+// Messages that only partly parse are logged to parseLog; errors that drop
+// an item are logged to errLog and passed to the WithDeadLetter callback.
+func NewIngest(middleware []Middleware, writeTrade Handler, parseLog, errLog Logger, opts ...IngestOption) *Pipeline {
+	cfg := ingestConfig{parse: StageConfig{Buffer: 1024}}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	parse := NewStage("parse", cfg.parse,
+		func(ctx context.Context, message []byte) ([]*rtds.ActivityTradePayload, error) {
+			var trades []*rtds.ActivityTradePayload
+			var errs []error
+			funcs := rtds.HandlerFuncs{
+				Trade:     func(trade *rtds.ActivityTradePayload) { trades = append(trades, trade) },
+				UserTrade: cfg.userTrade,
+				Order:     cfg.userOrder,
+				Error:     func(err error) { errs = append(errs, err) },
+			}
+			var handler rtds.EventHandler = funcs
+			if cfg.channel != nil {
+				handler = channelHandler{HandlerFuncs: funcs, ctx: ctx, fn: cfg.channel}
+			}
+			rtds.Dispatch(message, handler)
+			if len(trades) == 0 {
+				err := errors.Join(errs...) // nil for pongs and other topics
+				if err != nil && cfg.dead != nil {
+					cfg.dead(ctx, "parse", message, err)
+				}
+				return nil, err
+			}
+			for _, err := range errs {
+				parseLog.Printf("Error parsing message: %v", err)
+			}
+			return trades, nil
+		})
+
+	process := NewStage("process", StageConfig{},
+		func(ctx context.Context, trade *rtds.ActivityTradePayload) ([]*rtds.ActivityTradePayload, error) {
+			ctx = TradeContext(ctx, trade)
+			var out []*rtds.ActivityTradePayload
+			err := Chain(func(_ context.Context, t *rtds.ActivityTradePayload) error {
+				out = append(out, t)
+				return nil
+			}, middleware...)(ctx, trade)
+			return out, err
+		})
+
+	write := NewStage("sink", StageConfig{},
+		func(ctx context.Context, trade *rtds.ActivityTradePayload) ([]struct{}, error) {
+			err := writeTrade(TradeContext(ctx, trade), trade)
+			if err != nil && cfg.dead != nil {
+				if payload, jsonErr := json.Marshal(trade); jsonErr == nil {
+					cfg.dead(ctx, "sink", payload, err)
+				}
+			}
+			return []struct{}{{}}, err
+		})
+
+	return New(errLog, parse, process, write)
+}
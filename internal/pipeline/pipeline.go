@@ -0,0 +1,58 @@
+// Package pipeline composes the per-trade processing path from middleware
+// stages (filter, dedupe, enrich, transform) in front of the sinks.
+package pipeline
+
+import (
+	"context"
+
+	"github.com/FatwaArya/pm-ingest/internal/logging"
+	"github.com/FatwaArya/pm-ingest/pkg/rtds"
+)
+
+// Logger receives errors stages recover from; *log.Logger satisfies it
+type Logger interface {
+	Printf(format string, args ...any)
+}
+
+// ContextLogger is a Logger that also takes the context of the trade a
+// message is about, for its correlation fields (logging.RateLimited
+// satisfies it)
+type ContextLogger interface {
+	Logger
+	PrintfContext(ctx context.Context, format string, args ...any)
+}
+
+// logf logs through logger with ctx when it takes one
+func logf(ctx context.Context, logger Logger, format string, args ...any) {
+	if cl, ok := logger.(ContextLogger); ok {
+		cl.PrintfContext(ctx, format, args...)
+		return
+	}
+	logger.Printf(format, args...)
+}
+
+// TradeContext returns ctx carrying trade's transaction hash, wallet and
+// topic as log correlation fields
+func TradeContext(ctx context.Context, trade *rtds.ActivityTradePayload) context.Context {
+	return logging.WithAttrs(ctx,
+		logging.KeyTxHash, trade.TransactionHash,
+		logging.KeyWallet, trade.ProxyWalletAddress,
+		logging.KeyTopic, rtds.TopicActivity,
+	)
+}
+
+// Handler processes a single trade
+type Handler func(ctx context.Context, trade *rtds.ActivityTradePayload) error
+
+// Middleware wraps a Handler. A stage drops a trade by returning without
+// calling next.
+type Middleware func(next Handler) Handler
+
+// Chain builds a handler that runs the stages in order before final
+func Chain(final Handler, stages ...Middleware) Handler {
+	h := final
+	for i := len(stages) - 1; i >= 0; i-- {
+		h = stages[i](h)
+	}
+	return h
+}
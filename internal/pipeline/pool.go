@@ -0,0 +1,146 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// Overflow decides what happens to an item submitted to a full queue
+type Overflow int
+
+const (
+	// OverflowBlock waits for room, pushing back on the submitter
+	OverflowBlock Overflow = iota
+	// OverflowDropOldest discards the oldest queued item to make room, so
+	// the submitter never waits
+	OverflowDropOldest
+)
+
+// ParseOverflow parses "block" or "drop-oldest"
+func ParseOverflow(s string) (Overflow, error) {
+	switch s {
+	case "block":
+		return OverflowBlock, nil
+	case "drop-oldest":
+		return OverflowDropOldest, nil
+	}
+	return OverflowBlock, fmt.Errorf("unknown overflow policy %q", s)
+}
+
+func (o Overflow) String() string {
+	if o == OverflowDropOldest {
+		return "drop-oldest"
+	}
+	return "block"
+}
+
+// enqueue sends item to ch following overflow, returning how many queued
+// items were dropped to make room
+func enqueue[T any](ctx context.Context, ch chan T, item T, overflow Overflow) (uint64, error) {
+	if overflow == OverflowBlock {
+		select {
+		case ch <- item:
+			return 0, nil
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+	}
+	var dropped uint64
+	for {
+		select {
+		case ch <- item:
+			return dropped, nil
+		default:
+		}
+		select {
+		case <-ch:
+			dropped++
+		default: // A worker took one first; try again
+		}
+	}
+}
+
+// PoolStats is a point-in-time view of a pool's counters
+type PoolStats struct {
+	Workers  int    `json:"workers"`
+	Queued   int    `json:"queued"`
+	Capacity int    `json:"capacity"`
+	Done     uint64 `json:"done"`
+	Dropped  uint64 `json:"dropped"`
+}
+
+// Pool runs jobs on a fixed number of workers fed by a bounded queue, in
+// place of a goroutine per job
+type Pool struct {
+	jobs     chan func()
+	overflow Overflow
+	workers  int
+	wg       sync.WaitGroup
+	done     atomic.Uint64
+	dropped  atomic.Uint64
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// NewPool starts workers (at least 1) taking jobs from a queue of size
+// (at least 1)
+func NewPool(workers, size int, overflow Overflow) *Pool {
+	workers, size = max(workers, 1), max(size, 1)
+	p := &Pool{jobs: make(chan func(), size), overflow: overflow, workers: workers}
+	for i := 0; i < workers; i++ {
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			for job := range p.jobs {
+				job()
+				p.done.Add(1)
+			}
+		}()
+	}
+	return p
+}
+
+// Submit queues job. With OverflowBlock it waits for room until ctx is
+// done; with OverflowDropOldest it returns at once.
+func (p *Pool) Submit(ctx context.Context, job func()) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return ErrPipelineClosed
+	}
+	dropped, err := enqueue(ctx, p.jobs, job, p.overflow)
+	p.dropped.Add(dropped)
+	return err
+}
+
+// Close stops accepting jobs and waits for the queued ones to run
+func (p *Pool) Close() {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	close(p.jobs)
+	p.mu.Unlock()
+	p.wg.Wait()
+}
+
+// Dropped returns the number of jobs discarded by OverflowDropOldest
+func (p *Pool) Dropped() uint64 {
+	return p.dropped.Load()
+}
+
+// Stats returns the pool's counters
+func (p *Pool) Stats() PoolStats {
+	return PoolStats{
+		Workers:  p.workers,
+		Queued:   len(p.jobs),
+		Capacity: cap(p.jobs),
+		Done:     p.done.Load(),
+		Dropped:  p.dropped.Load(),
+	}
+}
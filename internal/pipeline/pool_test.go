@@ -0,0 +1,65 @@
+package pipeline
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+)
+
+func TestPoolDropOldest(t *testing.T) {
+	ctx := context.Background()
+	p := NewPool(1, 2, OverflowDropOldest)
+
+	// Hold the only worker so jobs pile up in the queue
+	release := make(chan struct{})
+	started := make(chan struct{})
+	if err := p.Submit(ctx, func() { close(started); <-release }); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+
+	var ran [4]atomic.Bool
+	for i := range ran {
+		if err := p.Submit(ctx, func() { ran[i].Store(true) }); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(release)
+	p.Close()
+
+	for i, want := range []bool{false, false, true, true} {
+		if got := ran[i].Load(); got != want {
+			t.Errorf("job %d ran = %t, want %t", i, got, want)
+		}
+	}
+	if s := p.Stats(); s.Dropped != 2 || s.Done != 3 {
+		t.Errorf("Stats = %+v, want 2 dropped and 3 done", s)
+	}
+	if err := p.Submit(ctx, func() {}); err != ErrPipelineClosed {
+		t.Errorf("Submit after Close = %v, want ErrPipelineClosed", err)
+	}
+}
+
+func TestPipelineSubmitDropOldest(t *testing.T) {
+	var got []int
+	sink := NewStage("sink", StageConfig{Buffer: 2, Overflow: OverflowDropOldest},
+		func(_ context.Context, n int) ([]struct{}, error) {
+			got = append(got, n)
+			return nil, nil
+		})
+	p := New(nil, sink)
+	for n := 1; n <= 4; n++ { // Not started, so nothing drains the queue
+		if err := p.Submit(context.Background(), n); err != nil {
+			t.Fatal(err)
+		}
+	}
+	p.Start(context.Background())
+	p.Close()
+
+	if len(got) != 2 || got[0] != 3 || got[1] != 4 {
+		t.Errorf("processed %v, want [3 4]", got)
+	}
+	if dropped := p.Stats()[0].Dropped; dropped != 2 {
+		t.Errorf("Dropped = %d, want 2", dropped)
+	}
+}
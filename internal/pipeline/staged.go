@@ -0,0 +1,297 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/FatwaArya/pm-ingest/internal/tracing"
+	"github.com/FatwaArya/pm-ingest/pkg/rtds"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ErrorPolicy decides what a stage does when processing an item fails.
+// The item is retried up to Retries times, Backoff apart; after that it is
+// dropped, counted as an error and logged.
+type ErrorPolicy struct {
+	Retries int
+	Backoff time.Duration
+}
+
+// StageConfig configures a stage
+type StageConfig struct {
+	Workers int // Concurrent workers (default 1, which preserves order)
+	Buffer  int // Input queue capacity (default 128)
+	OnError ErrorPolicy
+	// Overflow applies to Submit, for the first stage only; stages always
+	// block the one before them
+	Overflow Overflow
+}
+
+// Stage is one step of a Pipeline. Create stages with NewStage.
+type Stage interface {
+	Name() string
+	process(ctx context.Context, item any) ([]any, error)
+	config() StageConfig
+}
+
+type stage[In, Out any] struct {
+	name string
+	cfg  StageConfig
+	fn   func(context.Context, In) ([]Out, error)
+}
+
+// NewStage creates a stage that turns each In into zero or more Outs:
+// returning none filters the item out, several fans it out. The previous
+// stage must output In.
+func NewStage[In, Out any](name string, cfg StageConfig, fn func(ctx context.Context, in In) ([]Out, error)) Stage {
+	return &stage[In, Out]{name: name, cfg: cfg, fn: fn}
+}
+
+func (s *stage[In, Out]) Name() string        { return s.name }
+func (s *stage[In, Out]) config() StageConfig { return s.cfg }
+
+func (s *stage[In, Out]) process(ctx context.Context, item any) ([]any, error) {
+	in, ok := item.(In)
+	if !ok {
+		return nil, fmt.Errorf("stage %s: unexpected input type %T", s.name, item)
+	}
+	outs, err := s.fn(ctx, in)
+	if err != nil || len(outs) == 0 {
+		return nil, err
+	}
+	result := make([]any, len(outs))
+	for i, out := range outs {
+		result[i] = out
+	}
+	return result, nil
+}
+
+// StageStats is a point-in-time view of a stage's counters
+type StageStats struct {
+	Name        string        `json:"name"`
+	Workers     int           `json:"workers"`
+	Queued      int           `json:"queued"`
+	Capacity    int           `json:"capacity"`
+	In          uint64        `json:"in"`
+	Out         uint64        `json:"out"`
+	Filtered    uint64        `json:"filtered"`
+	Errors      uint64        `json:"errors"`
+	Retries     uint64        `json:"retries"`
+	Dropped     uint64        `json:"dropped"`
+	BusyWorkers int64         `json:"busyWorkers"`
+	AvgLatency  time.Duration `json:"avgLatencyNs"`
+}
+
+type stageRunner struct {
+	stage   Stage
+	cfg     StageConfig
+	input   chan any
+	in      atomic.Uint64
+	out     atomic.Uint64
+	filter  atomic.Uint64
+	errs    atomic.Uint64
+	retries atomic.Uint64
+	dropped atomic.Uint64
+	busy    atomic.Int64
+	busyNs  atomic.Int64
+}
+
+// traced is an item submitted with a trace: each stage processing it, and
+// the items it turns into, get a span continuing that trace
+type traced struct {
+	span trace.SpanContext
+	item any
+}
+
+// ErrPipelineClosed is returned by Submit after Close
+var ErrPipelineClosed = errors.New("pipeline closed")
+
+// Pipeline runs items through stages connected by bounded channels. A full
+// stage blocks the one before it, so a slow sink pushes back all the way to
+// Submit instead of growing memory without bound.
+type Pipeline struct {
+	runners []*stageRunner
+	logger  Logger
+
+	mu      sync.RWMutex
+	closed  bool
+	started bool
+	wg      []*sync.WaitGroup
+}
+
+// New creates a pipeline from stages, in order. Stage errors are reported
+// to logger.
+func New(logger Logger, stages ...Stage) *Pipeline {
+	p := &Pipeline{logger: logger}
+	for _, s := range stages {
+		cfg := s.config()
+		if cfg.Workers <= 0 {
+			cfg.Workers = 1
+		}
+		if cfg.Buffer <= 0 {
+			cfg.Buffer = 128
+		}
+		p.runners = append(p.runners, &stageRunner{stage: s, cfg: cfg, input: make(chan any, cfg.Buffer)})
+	}
+	return p
+}
+
+// Start launches the stage workers. Items in flight keep being processed
+// with ctx until Close drains the pipeline.
+func (p *Pipeline) Start(ctx context.Context) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.started {
+		return
+	}
+	p.started = true
+
+	for i, r := range p.runners {
+		var next chan any
+		if i+1 < len(p.runners) {
+			next = p.runners[i+1].input
+		}
+		wg := &sync.WaitGroup{}
+		p.wg = append(p.wg, wg)
+		for w := 0; w < r.cfg.Workers; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				p.work(ctx, r, next)
+			}()
+		}
+	}
+}
+
+// Submit queues an item for the first stage. While it is full Submit
+// blocks, or drops the oldest queued item with OverflowDropOldest. A span
+// in ctx is continued by the stages processing the item.
+func (p *Pipeline) Submit(ctx context.Context, item any) error {
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		item = traced{span: sc, item: item}
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed || len(p.runners) == 0 {
+		return ErrPipelineClosed
+	}
+	first := p.runners[0]
+	dropped, err := enqueue(ctx, first.input, item, first.cfg.Overflow)
+	first.dropped.Add(dropped)
+	return err
+}
+
+// Close stops accepting items and waits until every queued item has gone
+// through all stages
+func (p *Pipeline) Close() {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	started := p.started
+	p.mu.Unlock()
+
+	// Each stage's input is closed once the stage before it has finished
+	for i, r := range p.runners {
+		close(r.input)
+		if started {
+			p.wg[i].Wait()
+		}
+	}
+}
+
+// Stats returns the counters of every stage
+func (p *Pipeline) Stats() []StageStats {
+	stats := make([]StageStats, len(p.runners))
+	for i, r := range p.runners {
+		var avg time.Duration
+		if n := r.in.Load(); n > 0 {
+			avg = time.Duration(r.busyNs.Load() / int64(n))
+		}
+		stats[i] = StageStats{
+			Name:        r.stage.Name(),
+			Workers:     r.cfg.Workers,
+			Queued:      len(r.input),
+			Capacity:    cap(r.input),
+			In:          r.in.Load(),
+			Out:         r.out.Load(),
+			Filtered:    r.filter.Load(),
+			Errors:      r.errs.Load(),
+			Retries:     r.retries.Load(),
+			Dropped:     r.dropped.Load(),
+			BusyWorkers: r.busy.Load(),
+			AvgLatency:  avg,
+		}
+	}
+	return stats
+}
+
+func (p *Pipeline) work(ctx context.Context, r *stageRunner, next chan any) {
+	for item := range r.input {
+		r.in.Add(1)
+		r.busy.Add(1)
+		start := time.Now()
+		itemCtx, item := ctx, item
+		var span trace.Span
+		if t, ok := item.(traced); ok {
+			itemCtx, span = tracing.Start(trace.ContextWithSpanContext(ctx, t.span), "pipeline."+r.stage.Name())
+			item = t.item
+		}
+		outs, err := p.processWithRetry(itemCtx, r, item)
+		if span != nil {
+			tracing.End(span, err)
+			for i, out := range outs {
+				outs[i] = traced{span: span.SpanContext(), item: out}
+			}
+		}
+		r.busyNs.Add(int64(time.Since(start)))
+		r.busy.Add(-1)
+
+		switch {
+		case err != nil:
+			r.errs.Add(1)
+			if p.logger != nil {
+				logCtx := itemCtx
+				if trade, ok := item.(*rtds.ActivityTradePayload); ok {
+					logCtx = TradeContext(ctx, trade)
+				}
+				logf(logCtx, p.logger, "Pipeline stage %s error: %v", r.stage.Name(), err)
+			}
+		case len(outs) == 0:
+			r.filter.Add(1)
+		default:
+			r.out.Add(uint64(len(outs)))
+			if next == nil {
+				continue
+			}
+			for _, out := range outs {
+				next <- out
+			}
+		}
+	}
+}
+
+func (p *Pipeline) processWithRetry(ctx context.Context, r *stageRunner, item any) ([]any, error) {
+	outs, err := r.stage.process(ctx, item)
+	for attempt := 0; err != nil && attempt < r.cfg.OnError.Retries; attempt++ {
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		r.retries.Add(1)
+		if r.cfg.OnError.Backoff > 0 {
+			select {
+			case <-time.After(r.cfg.OnError.Backoff):
+			case <-ctx.Done():
+				return nil, err
+			}
+		}
+		outs, err = r.stage.process(ctx, item)
+	}
+	return outs, err
+}
@@ -0,0 +1,131 @@
+package pipeline
+
+import (
+	"context"
+	"hash/fnv"
+	"log"
+	"math"
+	"strings"
+
+	"github.com/FatwaArya/pm-ingest/pkg/rtds"
+)
+
+// Filter passes on trades for which keep returns true
+func Filter(keep func(*rtds.ActivityTradePayload) bool) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, trade *rtds.ActivityTradePayload) error {
+			if !keep(trade) {
+				return nil
+			}
+			return next(ctx, trade)
+		}
+	}
+}
+
+// Unless skips mw for trades for which bypass returns true, passing them
+// straight on (e.g. markets ingested with full fidelity skip sampling)
+func Unless(bypass func(*rtds.ActivityTradePayload) bool, mw Middleware) Middleware {
+	return func(next Handler) Handler {
+		wrapped := mw(next)
+		return func(ctx context.Context, trade *rtds.ActivityTradePayload) error {
+			if bypass(trade) {
+				return next(ctx, trade)
+			}
+			return wrapped(ctx, trade)
+		}
+	}
+}
+
+// MinSize drops trades whose notional (size * price) is below usd
+func MinSize(usd float64) Middleware {
+	return Filter(func(trade *rtds.ActivityTradePayload) bool {
+		return trade.Size*trade.Price >= usd
+	})
+}
+
+// Sample keeps a fraction rate (0-1) of trades below whaleUSD notional and
+// every trade at or above it, so a low-cost deployment exercises the whole
+// pipeline without the full firehose. Sampling hashes the fill's dedupe key,
+// so replicas and replays keep the same trades.
+func Sample(rate, whaleUSD float64) Middleware {
+	if rate >= 1 {
+		return Filter(func(*rtds.ActivityTradePayload) bool { return true })
+	}
+	threshold := uint64(math.Max(rate, 0) * math.MaxUint64)
+	return Filter(func(trade *rtds.ActivityTradePayload) bool {
+		if trade.Size*trade.Price >= whaleUSD {
+			return true
+		}
+		h := fnv.New64a()
+		h.Write([]byte(trade.DedupeKey()))
+		return h.Sum64() < threshold
+	})
+}
+
+// Dedupe drops fills d has already seen (reconnects, overlapping replicas,
+// backfills). If the store fails the trade is passed on rather than lost.
+func Dedupe(d *Deduper, logger Logger) Middleware {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return func(next Handler) Handler {
+		return func(ctx context.Context, trade *rtds.ActivityTradePayload) error {
+			seen, err := d.Seen(ctx, trade)
+			if err != nil {
+				logf(ctx, logger, "Error checking trade dedupe for id=%s: %v", trade.TransactionHash, err)
+			} else if seen {
+				return nil
+			}
+			return next(ctx, trade)
+		}
+	}
+}
+
+// Observe passes every trade to fn (e.g. to update statistics) and on
+func Observe(fn func(*rtds.ActivityTradePayload)) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, trade *rtds.ActivityTradePayload) error {
+			fn(trade)
+			return next(ctx, trade)
+		}
+	}
+}
+
+// Enrich fills in fields on the trade before it's passed on, e.g. a market
+// category. An enrichment error is returned and the trade is dropped.
+func Enrich(enrich func(context.Context, *rtds.ActivityTradePayload) error) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, trade *rtds.ActivityTradePayload) error {
+			if err := enrich(ctx, trade); err != nil {
+				return err
+			}
+			return next(ctx, trade)
+		}
+	}
+}
+
+// Transform passes on the trade returned by fn; returning nil drops it
+func Transform(fn func(*rtds.ActivityTradePayload) *rtds.ActivityTradePayload) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, trade *rtds.ActivityTradePayload) error {
+			if trade = fn(trade); trade == nil {
+				return nil
+			}
+			return next(ctx, trade)
+		}
+	}
+}
+
+// Normalize lowercases wallet addresses and trims whitespace from side and
+// outcome, so downstream keys and joins are consistent
+func Normalize() Middleware {
+	return Transform(func(trade *rtds.ActivityTradePayload) *rtds.ActivityTradePayload {
+		normalized := *trade
+		normalized.ProxyWalletAddress = strings.ToLower(trade.ProxyWalletAddress)
+		normalized.Maker = strings.ToLower(trade.Maker)
+		normalized.Taker = strings.ToLower(trade.Taker)
+		normalized.Side = strings.ToUpper(strings.TrimSpace(trade.Side))
+		normalized.OutcomeTitle = strings.TrimSpace(trade.OutcomeTitle)
+		return &normalized
+	})
+}
@@ -0,0 +1,67 @@
+package postgres
+
+import (
+	"strings"
+	"time"
+
+	internalqdb "github.com/FatwaArya/pm-ingest/internal"
+	"github.com/FatwaArya/pm-ingest/internal/provenance"
+	"github.com/FatwaArya/pm-ingest/pkg/rtds"
+)
+
+// tradeColumns are the trade table's columns, in the order of tradeRow
+var tradeColumns = []string{
+	"timestamp", "transaction_hash", "asset", "condition_id", "market_slug", "event_slug", "event_title",
+	"outcome", "outcome_index", "side", "role", "aggressor_side", "price", "size",
+	"proxy_wallet", "maker", "taker", "maker_order_id", "taker_order_id", "name", "pseudonym", "labels",
+	"category", "market_end_date", "market_liquidity", "market_volume", "tags",
+	"instance_id", "hostname", "pipeline_version",
+}
+
+func tradeRow(trade *rtds.ActivityTradePayload, info provenance.Info) []any {
+	return []any{
+		time.Unix(trade.Timestamp, 0).UTC(), trade.TransactionHash, trade.Asset, trade.ConditionID,
+		trade.MarketSlug, trade.EventSlug, trade.EventTitle,
+		trade.OutcomeTitle, int16(trade.OutcomeIndex), trade.Side, trade.Role(), trade.AggressorSide(), trade.Price, trade.Size,
+		trade.ProxyWalletAddress, trade.Maker, trade.Taker, trade.MakerOrderID, trade.TakerOrderID, trade.Name, trade.Pseudonym, trade.Labels,
+		trade.Category, trade.MarketEndDate, positive(trade.MarketLiquidity), positive(trade.MarketVolume), trade.Tags,
+		info.InstanceID, info.Hostname, info.Version,
+	}
+}
+
+// profileColumns are the profile table's columns, in the order of profileRow
+var profileColumns = []string{
+	"timestamp", "address", "owner", "name", "pseudonym", "bio", "icon", "profile_image",
+	"x_username", "verified", "profile_created_at", "labels",
+	"trades_last_minute", "trades_last_hour", "notional_last_minute", "notional_last_hour", "burst",
+	"first_seen", "last_seen", "instance_id", "hostname", "pipeline_version",
+}
+
+func profileRow(p *internalqdb.UserProfile, info provenance.Info, at time.Time) []any {
+	var labels []string
+	if p.Labels != "" {
+		labels = strings.Split(p.Labels, ",")
+	}
+	return []any{
+		at.UTC(), p.Address, p.Owner, p.Name, p.Pseudonym, p.Bio, p.Icon, p.ProfileImage,
+		p.XUsername, p.Verified, p.CreatedAt, labels,
+		int32(p.TradesLastMinute), int32(p.TradesLastHour), p.NotionalLastMinute, p.NotionalLastHour, p.Burst,
+		nullTime(p.FirstSeen), nullTime(p.LastSeen), info.InstanceID, info.Hostname, info.Version,
+	}
+}
+
+// positive returns nil, a NULL, for a value that isn't known
+func positive(v float64) *float64 {
+	if v <= 0 {
+		return nil
+	}
+	return &v
+}
+
+// nullTime returns nil, a NULL, for the zero time
+func nullTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
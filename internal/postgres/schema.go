@@ -0,0 +1,113 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Tables names the tables written to
+type Tables struct {
+	Trades   string
+	Profiles string
+}
+
+// DefaultTables match the QuestDB table names
+var DefaultTables = Tables{
+	Trades:   "polymarket_trades",
+	Profiles: "user_profiles",
+}
+
+// CreateTables creates the tables that don't exist yet and, with
+// timescale, turns them into hypertables partitioned by timestamp. Both
+// tables are append only, like in QuestDB: a profile gets a row every time
+// it's saved.
+func CreateTables(ctx context.Context, pool *pgxpool.Pool, t Tables, timescale bool) error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS ` + t.Trades + ` (
+			timestamp TIMESTAMPTZ NOT NULL,
+			transaction_hash TEXT NOT NULL,
+			asset TEXT NOT NULL,
+			condition_id TEXT NOT NULL,
+			market_slug TEXT,
+			event_slug TEXT,
+			event_title TEXT,
+			outcome TEXT,
+			outcome_index SMALLINT,
+			side TEXT,
+			role TEXT,
+			aggressor_side TEXT,
+			price DOUBLE PRECISION,
+			size DOUBLE PRECISION,
+			proxy_wallet TEXT,
+			maker TEXT,
+			taker TEXT,
+			maker_order_id TEXT,
+			taker_order_id TEXT,
+			name TEXT,
+			pseudonym TEXT,
+			labels TEXT[],
+			category TEXT,
+			market_end_date TEXT,
+			market_liquidity DOUBLE PRECISION,
+			market_volume DOUBLE PRECISION,
+			tags TEXT[],
+			instance_id TEXT,
+			hostname TEXT,
+			pipeline_version TEXT
+		)`,
+		`CREATE INDEX IF NOT EXISTS ` + indexName(t.Trades, "condition_id") + ` ON ` + t.Trades + ` (condition_id, timestamp DESC)`,
+		`CREATE INDEX IF NOT EXISTS ` + indexName(t.Trades, "proxy_wallet") + ` ON ` + t.Trades + ` (proxy_wallet, timestamp DESC)`,
+		`CREATE TABLE IF NOT EXISTS ` + t.Profiles + ` (
+			timestamp TIMESTAMPTZ NOT NULL,
+			address TEXT NOT NULL,
+			owner TEXT,
+			name TEXT,
+			pseudonym TEXT,
+			bio TEXT,
+			icon TEXT,
+			profile_image TEXT,
+			x_username TEXT,
+			verified BOOLEAN,
+			profile_created_at TEXT,
+			labels TEXT[],
+			trades_last_minute INTEGER,
+			trades_last_hour INTEGER,
+			notional_last_minute DOUBLE PRECISION,
+			notional_last_hour DOUBLE PRECISION,
+			burst BOOLEAN,
+			first_seen TIMESTAMPTZ,
+			last_seen TIMESTAMPTZ,
+			instance_id TEXT,
+			hostname TEXT,
+			pipeline_version TEXT
+		)`,
+		`CREATE INDEX IF NOT EXISTS ` + indexName(t.Profiles, "address") + ` ON ` + t.Profiles + ` (address, timestamp DESC)`,
+	}
+	for _, stmt := range statements {
+		if _, err := pool.Exec(ctx, stmt); err != nil {
+			return fmt.Errorf("failed to create table: %w", err)
+		}
+	}
+	if !timescale {
+		return nil
+	}
+
+	if _, err := pool.Exec(ctx, `CREATE EXTENSION IF NOT EXISTS timescaledb`); err != nil {
+		return fmt.Errorf("failed to enable timescaledb: %w", err)
+	}
+	for _, table := range []string{t.Trades, t.Profiles} {
+		if _, err := pool.Exec(ctx, `SELECT create_hypertable($1::regclass, 'timestamp', if_not_exists => TRUE, migrate_data => TRUE)`, table); err != nil {
+			return fmt.Errorf("failed to create hypertable %s: %w", table, err)
+		}
+	}
+	return nil
+}
+
+// indexName names an index on column of table, which may be schema
+// qualified; indexes live in their table's schema and can't be
+func indexName(table, column string) string {
+	return table[strings.LastIndexByte(table, '.')+1:] + "_" + column + "_idx"
+}
@@ -0,0 +1,170 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	internalqdb "github.com/FatwaArya/pm-ingest/internal"
+	"github.com/FatwaArya/pm-ingest/internal/provenance"
+	"github.com/FatwaArya/pm-ingest/pkg/clock"
+	"github.com/FatwaArya/pm-ingest/pkg/rtds"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// maxBufferedRows bounds a table's buffer while Postgres is down; older
+// rows are dropped beyond it
+const maxBufferedRows = 100000
+
+// Writer buffers rows per table and inserts them in batches with COPY: when
+// a table has batchSize rows, and every flush interval. Writes don't block
+// on Postgres; a failed batch is kept and retried with the next one.
+type Writer struct {
+	pool      *pgxpool.Pool
+	tables    Tables
+	batchSize int
+	clock     clock.Clock
+
+	mu      sync.Mutex
+	buffers map[string][][]any
+	full    chan struct{} // A table reached batchSize
+
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+	onFlush   func(error)
+	dropped   atomic.Uint64
+}
+
+// NewWriter creates the tables if needed and starts flushing every
+// interval. onFlush, which may be nil, is called with the outcome of every
+// background flush.
+func NewWriter(ctx context.Context, pool *pgxpool.Pool, tables Tables, timescale bool, batchSize int, interval time.Duration, onFlush func(error)) (*Writer, error) {
+	if err := CreateTables(ctx, pool, tables, timescale); err != nil {
+		return nil, err
+	}
+	w := &Writer{
+		pool:      pool,
+		tables:    tables,
+		batchSize: max(batchSize, 1),
+		clock:     clock.Real,
+		buffers:   make(map[string][][]any),
+		full:      make(chan struct{}, 1),
+		done:      make(chan struct{}),
+		onFlush:   onFlush,
+	}
+	w.wg.Add(1)
+	go w.flushLoop(interval)
+	return w, nil
+}
+
+// WriteTrade buffers a trade row
+func (w *Writer) WriteTrade(trade *rtds.ActivityTradePayload) {
+	w.add(w.tables.Trades, tradeRow(trade, provenance.Current()))
+}
+
+// WriteProfile buffers a profile row, timestamped now
+func (w *Writer) WriteProfile(profile *internalqdb.UserProfile) {
+	w.add(w.tables.Profiles, profileRow(profile, provenance.Current(), w.clock.Now()))
+}
+
+func (w *Writer) add(table string, row []any) {
+	w.mu.Lock()
+	rows := w.buffers[table]
+	if len(rows) >= maxBufferedRows {
+		rows = rows[1:]
+		w.dropped.Add(1)
+	}
+	rows = append(rows, row)
+	w.buffers[table] = rows
+	full := len(rows) >= w.batchSize
+	w.mu.Unlock()
+
+	if full {
+		select {
+		case w.full <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Flush copies every buffered row. Rows of a failed copy stay buffered.
+func (w *Writer) Flush(ctx context.Context) error {
+	w.mu.Lock()
+	pending := w.buffers
+	w.buffers = make(map[string][][]any)
+	w.mu.Unlock()
+
+	var errs []error
+	for table, rows := range pending {
+		if len(rows) == 0 {
+			continue
+		}
+		if err := w.copy(ctx, table, rows); err != nil {
+			errs = append(errs, err)
+			w.requeue(table, rows)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (w *Writer) copy(ctx context.Context, table string, rows [][]any) error {
+	columns := tradeColumns
+	if table == w.tables.Profiles {
+		columns = profileColumns
+	}
+	_, err := w.pool.CopyFrom(ctx, pgx.Identifier(strings.Split(table, ".")), columns, pgx.CopyFromRows(rows))
+	if err != nil {
+		return fmt.Errorf("failed to copy %d rows to %s: %w", len(rows), table, err)
+	}
+	return nil
+}
+
+// requeue puts the rows of a failed copy back ahead of newer ones
+func (w *Writer) requeue(table string, rows [][]any) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	rows = append(rows, w.buffers[table]...)
+	if over := len(rows) - maxBufferedRows; over > 0 {
+		rows = rows[over:]
+		w.dropped.Add(uint64(over))
+	}
+	w.buffers[table] = rows
+}
+
+// Dropped returns the rows dropped because a table's buffer was full
+func (w *Writer) Dropped() uint64 {
+	return w.dropped.Load()
+}
+
+// Close stops the background flush and flushes what's left
+func (w *Writer) Close(ctx context.Context) error {
+	w.closeOnce.Do(func() { close(w.done) })
+	w.wg.Wait()
+	return w.Flush(ctx)
+}
+
+func (w *Writer) flushLoop(interval time.Duration) {
+	defer w.wg.Done()
+	ticker := w.clock.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C():
+		case <-w.full:
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		err := w.Flush(ctx)
+		cancel()
+		if w.onFlush != nil {
+			w.onFlush(err)
+		}
+	}
+}
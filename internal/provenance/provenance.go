@@ -0,0 +1,104 @@
+package provenance
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"os"
+	"runtime/debug"
+	"sync"
+)
+
+// Version is the pipeline version, set at build time with
+// -ldflags "-X github.com/FatwaArya/pm-ingest/internal/provenance.Version=v1.2.3".
+// When unset it falls back to the VCS revision embedded by the Go toolchain.
+var Version = ""
+
+// Kafka header / QuestDB column names
+const (
+	HeaderInstanceID = "instance_id"
+	HeaderHostname   = "hostname"
+	HeaderVersion    = "pipeline_version"
+)
+
+// Info identifies the replica and build that emitted a record
+type Info struct {
+	InstanceID string `json:"instanceId"`
+	Hostname   string `json:"hostname"`
+	Version    string `json:"version"`
+}
+
+var (
+	mu      sync.RWMutex
+	current = detect()
+)
+
+// Current returns the provenance of this process
+func Current() Info {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current
+}
+
+// SetInstanceID overrides the generated instance ID (e.g. with a pod name)
+func SetInstanceID(id string) {
+	if id == "" {
+		return
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	current.InstanceID = id
+}
+
+func detect() Info {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	return Info{
+		InstanceID: hostname + "-" + randomSuffix(),
+		Hostname:   hostname,
+		Version:    buildVersion(),
+	}
+}
+
+// buildVersion resolves the version from ldflags or embedded build info
+func buildVersion() string {
+	if Version != "" {
+		return Version
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "dev"
+	}
+	var revision, modified string
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			revision = s.Value
+		case "vcs.modified":
+			modified = s.Value
+		}
+	}
+	if revision == "" {
+		if info.Main.Version != "" && info.Main.Version != "(devel)" {
+			return info.Main.Version
+		}
+		return "dev"
+	}
+	if len(revision) > 12 {
+		revision = revision[:12]
+	}
+	if modified == "true" {
+		revision += "-dirty"
+	}
+	return revision
+}
+
+// randomSuffix distinguishes restarts of the same host
+func randomSuffix() string {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return "0"
+	}
+	return hex.EncodeToString(b)
+}
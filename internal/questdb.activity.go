@@ -0,0 +1,132 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	qdb "github.com/questdb/go-questdb-client/v3"
+)
+
+// ActivityWriter writes wallet activity profiles to QuestDB
+type ActivityWriter struct {
+	sender qdb.LineSender
+	table  TableConfig
+	mu     sync.Mutex
+}
+
+var defaultActivityTable = TableConfig{
+	Name:    "wallet_activity",
+	Symbols: []string{"address"},
+}
+
+// WalletActivity is a wallet's time-of-day and session profile
+type WalletActivity struct {
+	Address               string
+	Trades                int64
+	ActiveHours           string // Trades per UTC hour, comma separated
+	ActiveDays            string // Trades per weekday from Sunday, comma separated
+	DistinctHours         int
+	HourEntropy           float64
+	Sessions              int
+	AvgSessionMinutes     float64
+	LongestSessionMinutes float64
+	AvgGapSeconds         float64
+	ReactionMarkets       int
+	AvgReactionSeconds    float64
+	LikelyBot             bool
+}
+
+// NewActivityWriter creates a new QuestDB activity writer using ILP over TCP
+func NewActivityWriter(ctx context.Context, host string, port int, opts ...WriterOption) (*ActivityWriter, error) {
+	conf := fmt.Sprintf("tcp::addr=%s:%d;", host, port)
+	table := tableConfig(defaultActivityTable, opts)
+	if err := ensureTable(ctx, table, activityColumns(table.Symbols)); err != nil {
+		return nil, err
+	}
+
+	sender, err := qdb.LineSenderFromConf(ctx, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ActivityWriter{
+		sender: sender,
+		table:  table,
+	}, nil
+}
+
+func activityStrings(a *WalletActivity) []stringField {
+	return []stringField{
+		{"address", a.Address},
+		{"active_hours", a.ActiveHours},
+		{"active_days", a.ActiveDays},
+	}
+}
+
+func activityColumns(symbols []string) []column {
+	cols := stringColumns(activityStrings(&WalletActivity{}), symbols)
+	return append(cols,
+		column{"trades", colLong},
+		column{"distinct_hours", colLong},
+		column{"hour_entropy", colDouble},
+		column{"sessions", colLong},
+		column{"avg_session_minutes", colDouble},
+		column{"longest_session_minutes", colDouble},
+		column{"avg_gap_seconds", colDouble},
+		column{"reaction_markets", colLong},
+		column{"avg_reaction_seconds", colDouble},
+		column{"likely_bot", colBoolean},
+	)
+}
+
+// Write writes an activity profile to QuestDB at ts
+func (w *ActivityWriter) Write(ctx context.Context, a *WalletActivity, ts time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	ctx, cancel := withWriteTimeout(ctx)
+	defer cancel()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return writeStrings(w.sender, w.table.Name, w.table.Symbols, activityStrings(a)).
+		Int64Column("trades", a.Trades).
+		Int64Column("distinct_hours", int64(a.DistinctHours)).
+		Float64Column("hour_entropy", a.HourEntropy).
+		Int64Column("sessions", int64(a.Sessions)).
+		Float64Column("avg_session_minutes", a.AvgSessionMinutes).
+		Float64Column("longest_session_minutes", a.LongestSessionMinutes).
+		Float64Column("avg_gap_seconds", a.AvgGapSeconds).
+		Int64Column("reaction_markets", int64(a.ReactionMarkets)).
+		Float64Column("avg_reaction_seconds", a.AvgReactionSeconds).
+		BoolColumn("likely_bot", a.LikelyBot).
+		At(ctx, ts)
+}
+
+// Flush sends all buffered data to QuestDB
+func (w *ActivityWriter) Flush(ctx context.Context) error {
+	ctx, cancel := withWriteTimeout(ctx)
+	defer cancel()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.sender.Flush(ctx)
+}
+
+// Close flushes pending data and closes the connection to QuestDB
+func (w *ActivityWriter) Close(ctx context.Context) error {
+	ctx, cancel := withWriteTimeout(ctx)
+	defer cancel()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.sender.Flush(ctx); err != nil {
+		ingestLog.ErrorContext(ctx, "QuestDB final flush error", "table", w.table.Name, "error", err)
+	}
+
+	return w.sender.Close(ctx)
+}
@@ -0,0 +1,134 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	qdb "github.com/questdb/go-questdb-client/v3"
+)
+
+// ConfidenceWriter writes computed wallet confidence scores to QuestDB, one
+// row per computation, so their history can be queried
+type ConfidenceWriter struct {
+	sender qdb.LineSender
+	table  TableConfig
+	mu     sync.Mutex
+}
+
+var defaultConfidenceTable = TableConfig{
+	Name:    "confidence_scores",
+	Symbols: []string{"wallet"},
+}
+
+// ConfidenceScore is one computation of a wallet's confidence metrics
+type ConfidenceScore struct {
+	Wallet             string
+	BrierScore         float64
+	Calibration        float64
+	WinRate            float64
+	ConfidenceInterval float64
+	SampleSize         int
+	AvgRealizedPnl     float64
+	TotalRealizedPnl   float64
+	PnlVolatility      float64
+	SharpeRatio        float64
+	MaxDrawdown        float64
+	ProfitFactor       float64
+	NoLosses           bool
+	ROI                float64
+	ComputedAt         time.Time
+}
+
+// NewConfidenceWriter creates a new QuestDB confidence writer using ILP over TCP
+func NewConfidenceWriter(ctx context.Context, host string, port int, opts ...WriterOption) (*ConfidenceWriter, error) {
+	conf := fmt.Sprintf("tcp::addr=%s:%d;", host, port)
+	table := tableConfig(defaultConfidenceTable, opts)
+	if err := ensureTable(ctx, table, confidenceColumns(table.Symbols)); err != nil {
+		return nil, err
+	}
+
+	sender, err := qdb.LineSenderFromConf(ctx, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ConfidenceWriter{
+		sender: sender,
+		table:  table,
+	}, nil
+}
+
+func confidenceStrings(score *ConfidenceScore) []stringField {
+	return []stringField{
+		{"wallet", score.Wallet},
+	}
+}
+
+func confidenceColumns(symbols []string) []column {
+	cols := stringColumns(confidenceStrings(&ConfidenceScore{}), symbols)
+	return append(cols,
+		column{"brier_score", colDouble},
+		column{"calibration", colDouble},
+		column{"win_rate", colDouble},
+		column{"confidence_interval", colDouble},
+		column{"sample_size", colLong},
+		column{"avg_realized_pnl", colDouble},
+		column{"total_realized_pnl", colDouble},
+		column{"pnl_volatility", colDouble},
+		column{"sharpe_ratio", colDouble},
+		column{"max_drawdown", colDouble},
+		column{"profit_factor", colDouble},
+		column{"no_losses", colBoolean},
+		column{"roi", colDouble},
+	)
+}
+
+// Write writes a confidence score and flushes it, timestamped with its
+// computation time. Scores are infrequent, so they aren't batched.
+func (w *ConfidenceWriter) Write(ctx context.Context, score *ConfidenceScore) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	ctx, cancel := withWriteTimeout(ctx)
+	defer cancel()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	err := writeStrings(w.sender, w.table.Name, w.table.Symbols, confidenceStrings(score)).
+		Float64Column("brier_score", score.BrierScore).
+		Float64Column("calibration", score.Calibration).
+		Float64Column("win_rate", score.WinRate).
+		Float64Column("confidence_interval", score.ConfidenceInterval).
+		Int64Column("sample_size", int64(score.SampleSize)).
+		Float64Column("avg_realized_pnl", score.AvgRealizedPnl).
+		Float64Column("total_realized_pnl", score.TotalRealizedPnl).
+		Float64Column("pnl_volatility", score.PnlVolatility).
+		Float64Column("sharpe_ratio", score.SharpeRatio).
+		Float64Column("max_drawdown", score.MaxDrawdown).
+		Float64Column("profit_factor", score.ProfitFactor).
+		BoolColumn("no_losses", score.NoLosses).
+		Float64Column("roi", score.ROI).
+		At(ctx, score.ComputedAt)
+	if err != nil {
+		return err
+	}
+	return w.sender.Flush(ctx)
+}
+
+// Close flushes pending data and closes the connection to QuestDB
+func (w *ConfidenceWriter) Close(ctx context.Context) error {
+	ctx, cancel := withWriteTimeout(ctx)
+	defer cancel()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.sender.Flush(ctx); err != nil {
+		ingestLog.ErrorContext(ctx, "QuestDB final flush error", "table", w.table.Name, "error", err)
+	}
+
+	return w.sender.Close(ctx)
+}
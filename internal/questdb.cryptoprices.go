@@ -0,0 +1,103 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/FatwaArya/pm-ingest/pkg/rtds"
+	qdb "github.com/questdb/go-questdb-client/v3"
+)
+
+// CryptoPriceWriter writes the reference prices of crypto markets to
+// QuestDB, for joining trades with the underlying price (e.g. with ASOF
+// JOIN). Prices are buffered until Flush.
+type CryptoPriceWriter struct {
+	sender qdb.LineSender
+	table  TableConfig
+	mu     sync.Mutex
+}
+
+var defaultCryptoPriceTable = TableConfig{
+	Name:    "crypto_prices",
+	Symbols: []string{"symbol", "source"},
+}
+
+// NewCryptoPriceWriter creates a new QuestDB crypto price writer using ILP over TCP
+func NewCryptoPriceWriter(ctx context.Context, host string, port int, opts ...WriterOption) (*CryptoPriceWriter, error) {
+	conf := fmt.Sprintf("tcp::addr=%s:%d;", host, port)
+	table := tableConfig(defaultCryptoPriceTable, opts)
+	if err := ensureTable(ctx, table, cryptoPriceColumns(table.Symbols)); err != nil {
+		return nil, err
+	}
+
+	sender, err := qdb.LineSenderFromConf(ctx, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CryptoPriceWriter{
+		sender: sender,
+		table:  table,
+	}, nil
+}
+
+func cryptoPriceStrings(price *rtds.CryptoPrice) []stringField {
+	return []stringField{
+		{"symbol", price.Symbol},
+		{"source", price.Source},
+	}
+}
+
+func cryptoPriceColumns(symbols []string) []column {
+	cols := stringColumns(cryptoPriceStrings(&rtds.CryptoPrice{}), symbols)
+	return append(cols, column{"value", colDouble})
+}
+
+// Write buffers a price, timestamped with the feed's time (or now, if the
+// feed didn't send one)
+func (w *CryptoPriceWriter) Write(ctx context.Context, price *rtds.CryptoPrice) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	ctx, cancel := withWriteTimeout(ctx)
+	defer cancel()
+
+	at := time.Now()
+	if price.Timestamp > 0 {
+		at = time.UnixMilli(price.Timestamp)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return writeStrings(w.sender, w.table.Name, w.table.Symbols, cryptoPriceStrings(price)).
+		Float64Column("value", price.Value).
+		At(ctx, at)
+}
+
+// Flush sends all buffered data to QuestDB
+func (w *CryptoPriceWriter) Flush(ctx context.Context) error {
+	ctx, cancel := withWriteTimeout(ctx)
+	defer cancel()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.sender.Flush(ctx)
+}
+
+// Close flushes pending data and closes the connection to QuestDB
+func (w *CryptoPriceWriter) Close(ctx context.Context) error {
+	ctx, cancel := withWriteTimeout(ctx)
+	defer cancel()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.sender.Flush(ctx); err != nil {
+		ingestLog.ErrorContext(ctx, "QuestDB final flush error", "table", w.table.Name, "error", err)
+	}
+
+	return w.sender.Close(ctx)
+}
@@ -3,79 +3,153 @@ package internal
 import (
 	"context"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
-	"github.com/FatwaArya/pm-ingest/utils"
+	"github.com/FatwaArya/pm-ingest/internal/logging"
+	"github.com/FatwaArya/pm-ingest/internal/provenance"
+	"github.com/FatwaArya/pm-ingest/pkg/rtds"
 	qdb "github.com/questdb/go-questdb-client/v3"
 )
 
+// ingestLog logs QuestDB writer errors that can't be returned to the caller
+var ingestLog = logging.For("ingest")
+
+// writeTimeout bounds a single ILP write or flush when the caller's context
+// has no deadline, so a stalled QuestDB connection can't block callers forever
+const writeTimeout = 10 * time.Second
+
+// withWriteTimeout applies writeTimeout unless ctx already has a deadline
+func withWriteTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, writeTimeout)
+}
+
 type TradeWriter struct {
-	sender    qdb.LineSender
-	tableName string
-	mu        sync.Mutex
+	sender qdb.LineSender
+	table  TableConfig
+	mu     sync.Mutex
+}
+
+// defaultTradeTable is the trades table with the low-cardinality string
+// columns as symbols, deduplicated on the fields of rtds DedupeKey
+var defaultTradeTable = TableConfig{
+	Name: "polymarket_trades",
+	Symbols: []string{
+		"side", "outcome", "event_slug", "role", "aggressor_side", "category",
+		provenance.HeaderInstanceID, provenance.HeaderHostname, provenance.HeaderVersion,
+	},
+	DedupKeys: []string{"transaction_hash", "asset", "proxy_wallet", "side", "size", "price"},
 }
 
 // NewTradeWriter creates a new QuestDB trade writer using ILP over TCP
 // with periodic background flushing (auto-flush not supported for TCP)
-func NewTradeWriter(ctx context.Context, host string, port int) (*TradeWriter, error) {
-	conf := fmt.Sprintf("tcp::addr=%s:%d;", host, port)
-
-	sender, err := qdb.LineSenderFromConf(ctx, conf)
-	if err != nil {
-		return nil, err
-	}
-
-	return &TradeWriter{
-		sender:    sender,
-		tableName: "polymarket_trades",
-	}, nil
+func NewTradeWriter(ctx context.Context, host string, port int, opts ...WriterOption) (*TradeWriter, error) {
+	return newTradeWriter(ctx, fmt.Sprintf("tcp::addr=%s:%d;", host, port), opts)
 }
 
 // NewTradeWriterHTTP creates a new QuestDB trade writer using HTTP protocol with auto-flush
-func NewTradeWriterHTTP(ctx context.Context, host string, port int) (*TradeWriter, error) {
+func NewTradeWriterHTTP(ctx context.Context, host string, port int, opts ...WriterOption) (*TradeWriter, error) {
 	// HTTP protocol supports auto-flush
-	conf := fmt.Sprintf("http::addr=%s:%d;auto_flush_interval=1000;", host, port)
+	return newTradeWriter(ctx, fmt.Sprintf("http::addr=%s:%d;auto_flush_interval=1000;", host, port), opts)
+}
+
+func newTradeWriter(ctx context.Context, conf string, opts []WriterOption) (*TradeWriter, error) {
+	table := tableConfig(defaultTradeTable, opts)
+	if err := ensureTable(ctx, table, tradeColumns(table.Symbols)); err != nil {
+		return nil, err
+	}
 
 	sender, err := qdb.LineSenderFromConf(ctx, conf)
 	if err != nil {
 		return nil, err
 	}
+
 	return &TradeWriter{
-		sender:    sender,
-		tableName: "polymarket_trades",
+		sender: sender,
+		table:  table,
 	}, nil
 }
 
-// Write writes a single trade to QuestDB
-func (w *TradeWriter) Write(ctx context.Context, trade *utils.ActivityTradePayload) error {
+// tradeStrings are the string columns of a trade row
+func tradeStrings(trade *rtds.ActivityTradePayload, info provenance.Info) []stringField {
+	return []stringField{
+		{"side", trade.Side},
+		{"outcome", trade.OutcomeTitle},
+		{"event_slug", trade.EventSlug},
+		{"role", trade.Role()},
+		{"aggressor_side", trade.AggressorSide()},
+		{provenance.HeaderInstanceID, info.InstanceID},
+		{provenance.HeaderHostname, info.Hostname},
+		{provenance.HeaderVersion, info.Version},
+		{"asset", trade.Asset},
+		{"transaction_hash", trade.TransactionHash},
+		{"condition_id", trade.ConditionID},
+		{"market_slug", trade.MarketSlug},
+		{"event_title", trade.EventTitle},
+		{"proxy_wallet", trade.ProxyWalletAddress},
+		{"maker", trade.Maker},
+		{"taker", trade.Taker},
+		{"maker_order_id", trade.MakerOrderID},
+		{"taker_order_id", trade.TakerOrderID},
+		{"name", trade.Name},
+		{"pseudonym", trade.Pseudonym},
+		{"labels", strings.Join(trade.Labels, ",")},
+		{"category", trade.Category},
+		{"market_end_date", trade.MarketEndDate},
+		{"tags", strings.Join(trade.Tags, ",")},
+	}
+}
+
+func tradeColumns(symbols []string) []column {
+	cols := stringColumns(tradeStrings(&rtds.ActivityTradePayload{}, provenance.Info{}), symbols)
+	return append(cols,
+		column{"price", colDouble},
+		column{"size", colDouble},
+		column{"outcome_index", colLong},
+		column{"market_liquidity", colDouble},
+		column{"market_volume", colDouble},
+	)
+}
+
+// Write writes a single trade to QuestDB. A write that triggers a flush
+// honors ctx cancellation and deadline (writeTimeout if ctx has none).
+func (w *TradeWriter) Write(ctx context.Context, trade *rtds.ActivityTradePayload) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	ctx, cancel := withWriteTimeout(ctx)
+	defer cancel()
+
 	// Timestamp in the payload is in seconds, convert to time.Time
 	ts := time.Unix(trade.Timestamp, 0)
+	info := provenance.Current()
 
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
-	return w.sender.
-		Table(w.tableName).
-		Symbol("side", trade.Side).
-		Symbol("outcome", trade.OutcomeTitle).
-		Symbol("event_slug", trade.EventSlug).
-		StringColumn("asset", trade.Asset).
+	row := writeStrings(w.sender, w.table.Name, w.table.Symbols, tradeStrings(trade, info)).
 		Float64Column("price", trade.Price).
 		Float64Column("size", trade.Size).
-		StringColumn("transaction_hash", trade.TransactionHash).
-		StringColumn("condition_id", trade.ConditionID).
-		Int64Column("outcome_index", int64(trade.OutcomeIndex)).
-		StringColumn("market_slug", trade.MarketSlug).
-		StringColumn("event_title", trade.EventTitle).
-		StringColumn("proxy_wallet", trade.ProxyWalletAddress).
-		StringColumn("name", trade.Name).
-		StringColumn("pseudonym", trade.Pseudonym).
-		At(ctx, ts)
+		Int64Column("outcome_index", int64(trade.OutcomeIndex))
+	// Left null for markets whose metadata isn't known
+	if trade.MarketLiquidity > 0 {
+		row = row.Float64Column("market_liquidity", trade.MarketLiquidity)
+	}
+	if trade.MarketVolume > 0 {
+		row = row.Float64Column("market_volume", trade.MarketVolume)
+	}
+	return row.At(ctx, ts)
 }
 
 // WriteBatch writes multiple trades to QuestDB
-func (w *TradeWriter) WriteBatch(ctx context.Context, trades []*utils.ActivityTradePayload) error {
+func (w *TradeWriter) WriteBatch(ctx context.Context, trades []*rtds.ActivityTradePayload) error {
+	ctx, cancel := withWriteTimeout(ctx)
+	defer cancel()
+
 	for _, trade := range trades {
 		if err := w.Write(ctx, trade); err != nil {
 			return err
@@ -86,6 +160,9 @@ func (w *TradeWriter) WriteBatch(ctx context.Context, trades []*utils.ActivityTr
 
 // Flush sends all buffered data to QuestDB
 func (w *TradeWriter) Flush(ctx context.Context) error {
+	ctx, cancel := withWriteTimeout(ctx)
+	defer cancel()
+
 	w.mu.Lock()
 	defer w.mu.Unlock()
 	return w.sender.Flush(ctx)
@@ -93,12 +170,15 @@ func (w *TradeWriter) Flush(ctx context.Context) error {
 
 // Close flushes pending data and closes the connection to QuestDB
 func (w *TradeWriter) Close(ctx context.Context) error {
+	ctx, cancel := withWriteTimeout(ctx)
+	defer cancel()
+
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
 	// Final flush before closing
 	if err := w.sender.Flush(ctx); err != nil {
-		fmt.Printf("QuestDB final flush error: %v\n", err)
+		ingestLog.ErrorContext(ctx, "QuestDB final flush error", "table", w.table.Name, "error", err)
 	}
 
 	return w.sender.Close(ctx)
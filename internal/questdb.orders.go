@@ -0,0 +1,121 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/FatwaArya/pm-ingest/pkg/rtds"
+	qdb "github.com/questdb/go-questdb-client/v3"
+)
+
+// OrderWriter writes the lifecycle events (PLACEMENT, UPDATE, CANCELLATION)
+// of our own orders from the clob_user feed to QuestDB, one row per event
+type OrderWriter struct {
+	sender qdb.LineSender
+	table  TableConfig
+	mu     sync.Mutex
+}
+
+var defaultOrderTable = TableConfig{
+	Name:    "clob_orders",
+	Symbols: []string{"market", "asset_id", "side", "type", "outcome", "owner"},
+}
+
+// NewOrderWriter creates a new QuestDB order writer using ILP over TCP
+func NewOrderWriter(ctx context.Context, host string, port int, opts ...WriterOption) (*OrderWriter, error) {
+	conf := fmt.Sprintf("tcp::addr=%s:%d;", host, port)
+	table := tableConfig(defaultOrderTable, opts)
+	if err := ensureTable(ctx, table, orderColumns(table.Symbols)); err != nil {
+		return nil, err
+	}
+
+	sender, err := qdb.LineSenderFromConf(ctx, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	return &OrderWriter{
+		sender: sender,
+		table:  table,
+	}, nil
+}
+
+func orderStrings(order *rtds.ClobUserOrder) []stringField {
+	return []stringField{
+		{"order_id", order.ID},
+		{"market", order.Market},
+		{"asset_id", order.AssetID},
+		{"side", order.Side},
+		{"type", order.Type},
+		{"outcome", order.Outcome},
+		{"owner", order.Owner},
+	}
+}
+
+func orderColumns(symbols []string) []column {
+	cols := stringColumns(orderStrings(&rtds.ClobUserOrder{}), symbols)
+	return append(cols,
+		column{"price", colDouble},
+		column{"original_size", colDouble},
+		column{"size_matched", colDouble},
+	)
+}
+
+// Write writes an order event and flushes it, timestamped with the event
+// time (or now, if the feed didn't send one). Our own orders are
+// infrequent, so they aren't batched.
+func (w *OrderWriter) Write(ctx context.Context, order *rtds.ClobUserOrder) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	ctx, cancel := withWriteTimeout(ctx)
+	defer cancel()
+
+	price, _ := strconv.ParseFloat(order.Price, 64)
+	originalSize, _ := strconv.ParseFloat(order.OriginalSize, 64)
+	sizeMatched, _ := strconv.ParseFloat(order.SizeMatched, 64)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	err := writeStrings(w.sender, w.table.Name, w.table.Symbols, orderStrings(order)).
+		Float64Column("price", price).
+		Float64Column("original_size", originalSize).
+		Float64Column("size_matched", sizeMatched).
+		At(ctx, orderTime(order.Timestamp))
+	if err != nil {
+		return err
+	}
+	return w.sender.Flush(ctx)
+}
+
+// orderTime parses a clob_user timestamp, in seconds or milliseconds
+func orderTime(ts string) time.Time {
+	n, err := strconv.ParseInt(ts, 10, 64)
+	switch {
+	case err != nil || n <= 0:
+		return time.Now()
+	case n > 1e12:
+		return time.UnixMilli(n)
+	default:
+		return time.Unix(n, 0)
+	}
+}
+
+// Close flushes pending data and closes the connection to QuestDB
+func (w *OrderWriter) Close(ctx context.Context) error {
+	ctx, cancel := withWriteTimeout(ctx)
+	defer cancel()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.sender.Flush(ctx); err != nil {
+		ingestLog.ErrorContext(ctx, "QuestDB final flush error", "table", w.table.Name, "error", err)
+	}
+
+	return w.sender.Close(ctx)
+}
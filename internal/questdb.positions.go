@@ -0,0 +1,132 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	qdb "github.com/questdb/go-questdb-client/v3"
+)
+
+// PositionWriter writes snapshots of wallets' open positions to QuestDB,
+// one row per position per refresh
+type PositionWriter struct {
+	sender qdb.LineSender
+	table  TableConfig
+	mu     sync.Mutex
+}
+
+var defaultPositionTable = TableConfig{
+	Name:    "positions",
+	Symbols: []string{"wallet", "condition_id", "outcome"},
+}
+
+// WalletPosition is a wallet's open position in one outcome, marked to
+// market
+type WalletPosition struct {
+	Wallet        string
+	ConditionID   string
+	Asset         string
+	Outcome       string
+	Slug          string
+	Size          float64 // Shares held
+	AvgPrice      float64
+	Cost          float64 // Cost basis of the shares held
+	CurPrice      float64
+	Value         float64 // Size * CurPrice
+	UnrealizedPnl float64 // Value - Cost
+	RealizedPnl   float64 // From partial sells
+	Redeemable    bool
+}
+
+// NewPositionWriter creates a new QuestDB position writer using ILP over TCP
+func NewPositionWriter(ctx context.Context, host string, port int, opts ...WriterOption) (*PositionWriter, error) {
+	conf := fmt.Sprintf("tcp::addr=%s:%d;", host, port)
+	table := tableConfig(defaultPositionTable, opts)
+	if err := ensureTable(ctx, table, positionColumns(table.Symbols)); err != nil {
+		return nil, err
+	}
+
+	sender, err := qdb.LineSenderFromConf(ctx, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PositionWriter{
+		sender: sender,
+		table:  table,
+	}, nil
+}
+
+func positionStrings(p *WalletPosition) []stringField {
+	return []stringField{
+		{"wallet", p.Wallet},
+		{"condition_id", p.ConditionID},
+		{"outcome", p.Outcome},
+		{"asset", p.Asset},
+		{"slug", p.Slug},
+	}
+}
+
+func positionColumns(symbols []string) []column {
+	cols := stringColumns(positionStrings(&WalletPosition{}), symbols)
+	return append(cols,
+		column{"size", colDouble},
+		column{"avg_price", colDouble},
+		column{"cost", colDouble},
+		column{"cur_price", colDouble},
+		column{"value", colDouble},
+		column{"unrealized_pnl", colDouble},
+		column{"realized_pnl", colDouble},
+		column{"redeemable", colBoolean},
+	)
+}
+
+// Write buffers a position as of ts
+func (w *PositionWriter) Write(ctx context.Context, p *WalletPosition, ts time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	ctx, cancel := withWriteTimeout(ctx)
+	defer cancel()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return writeStrings(w.sender, w.table.Name, w.table.Symbols, positionStrings(p)).
+		Float64Column("size", p.Size).
+		Float64Column("avg_price", p.AvgPrice).
+		Float64Column("cost", p.Cost).
+		Float64Column("cur_price", p.CurPrice).
+		Float64Column("value", p.Value).
+		Float64Column("unrealized_pnl", p.UnrealizedPnl).
+		Float64Column("realized_pnl", p.RealizedPnl).
+		BoolColumn("redeemable", p.Redeemable).
+		At(ctx, ts)
+}
+
+// Flush sends all buffered data to QuestDB
+func (w *PositionWriter) Flush(ctx context.Context) error {
+	ctx, cancel := withWriteTimeout(ctx)
+	defer cancel()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.sender.Flush(ctx)
+}
+
+// Close flushes pending data and closes the connection to QuestDB
+func (w *PositionWriter) Close(ctx context.Context) error {
+	ctx, cancel := withWriteTimeout(ctx)
+	defer cancel()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.sender.Flush(ctx); err != nil {
+		ingestLog.ErrorContext(ctx, "QuestDB final flush error", "table", w.table.Name, "error", err)
+	}
+
+	return w.sender.Close(ctx)
+}
@@ -0,0 +1,125 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	qdb "github.com/questdb/go-questdb-client/v3"
+)
+
+// PriceBarWriter writes per-asset price bars to QuestDB
+type PriceBarWriter struct {
+	sender qdb.LineSender
+	table  TableConfig
+	mu     sync.Mutex
+}
+
+var defaultPriceTable = TableConfig{
+	Name:    "asset_prices",
+	Symbols: []string{"asset", "market"},
+}
+
+// PriceBar is one asset's trading over an interval
+type PriceBar struct {
+	Asset    string
+	Market   string // Condition ID
+	Start    time.Time
+	Open     float64
+	High     float64
+	Low      float64
+	Close    float64
+	Volume   float64 // Shares
+	Notional float64 // USD
+	VWAP     float64
+	Trades   int64
+}
+
+// NewPriceBarWriter creates a new QuestDB price bar writer using ILP over TCP
+func NewPriceBarWriter(ctx context.Context, host string, port int, opts ...WriterOption) (*PriceBarWriter, error) {
+	conf := fmt.Sprintf("tcp::addr=%s:%d;", host, port)
+	table := tableConfig(defaultPriceTable, opts)
+	if err := ensureTable(ctx, table, priceColumns(table.Symbols)); err != nil {
+		return nil, err
+	}
+
+	sender, err := qdb.LineSenderFromConf(ctx, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PriceBarWriter{
+		sender: sender,
+		table:  table,
+	}, nil
+}
+
+func priceStrings(bar *PriceBar) []stringField {
+	return []stringField{
+		{"asset", bar.Asset},
+		{"market", bar.Market},
+	}
+}
+
+func priceColumns(symbols []string) []column {
+	cols := stringColumns(priceStrings(&PriceBar{}), symbols)
+	return append(cols,
+		column{"open", colDouble},
+		column{"high", colDouble},
+		column{"low", colDouble},
+		column{"close", colDouble},
+		column{"volume", colDouble},
+		column{"notional", colDouble},
+		column{"vwap", colDouble},
+		column{"trades", colLong},
+	)
+}
+
+// Write writes a price bar to QuestDB, timestamped with its start
+func (w *PriceBarWriter) Write(ctx context.Context, bar *PriceBar) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	ctx, cancel := withWriteTimeout(ctx)
+	defer cancel()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return writeStrings(w.sender, w.table.Name, w.table.Symbols, priceStrings(bar)).
+		Float64Column("open", bar.Open).
+		Float64Column("high", bar.High).
+		Float64Column("low", bar.Low).
+		Float64Column("close", bar.Close).
+		Float64Column("volume", bar.Volume).
+		Float64Column("notional", bar.Notional).
+		Float64Column("vwap", bar.VWAP).
+		Int64Column("trades", bar.Trades).
+		At(ctx, bar.Start)
+}
+
+// Flush sends all buffered data to QuestDB
+func (w *PriceBarWriter) Flush(ctx context.Context) error {
+	ctx, cancel := withWriteTimeout(ctx)
+	defer cancel()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.sender.Flush(ctx)
+}
+
+// Close flushes pending data and closes the connection to QuestDB
+func (w *PriceBarWriter) Close(ctx context.Context) error {
+	ctx, cancel := withWriteTimeout(ctx)
+	defer cancel()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.sender.Flush(ctx); err != nil {
+		ingestLog.ErrorContext(ctx, "QuestDB final flush error", "table", w.table.Name, "error", err)
+	}
+
+	return w.sender.Close(ctx)
+}
@@ -3,18 +3,28 @@ package internal
 import (
 	"context"
 	"fmt"
-	"log"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/FatwaArya/pm-ingest/internal/provenance"
 	qdb "github.com/questdb/go-questdb-client/v3"
 )
 
 // ProfileWriter writes user profiles to QuestDB
 type ProfileWriter struct {
-	sender    qdb.LineSender
-	tableName string
-	mu        sync.Mutex
+	sender qdb.LineSender
+	table  TableConfig
+	mu     sync.Mutex
+}
+
+// defaultProfileTable is the profiles table keyed by address and owner symbols
+var defaultProfileTable = TableConfig{
+	Name: "user_profiles",
+	Symbols: []string{
+		"address", "owner",
+		provenance.HeaderInstanceID, provenance.HeaderHostname, provenance.HeaderVersion,
+	},
 }
 
 // UserProfile represents a user profile to be written to QuestDB
@@ -25,11 +35,30 @@ type UserProfile struct {
 	Bio          string
 	Icon         string
 	ProfileImage string
+	XUsername    string
+	Verified     bool
+	CreatedAt    string // When the Polymarket profile was created, as reported
+	Owner        string // Owner ID shared by all wallets of the same trader
+	Labels       string // Comma-separated wallet labels
+	// Trade velocity when the profile was written
+	TradesLastMinute   int
+	TradesLastHour     int
+	NotionalLastMinute float64
+	NotionalLastHour   float64
+	Burst              bool
+	// When the wallet was first seen trading, and its trade that triggered
+	// this write; zero values are left null
+	FirstSeen time.Time
+	LastSeen  time.Time
 }
 
 // NewProfileWriter creates a new QuestDB profile writer using ILP over TCP
-func NewProfileWriter(ctx context.Context, host string, port int) (*ProfileWriter, error) {
+func NewProfileWriter(ctx context.Context, host string, port int, opts ...WriterOption) (*ProfileWriter, error) {
 	conf := fmt.Sprintf("tcp::addr=%s:%d;", host, port)
+	table := tableConfig(defaultProfileTable, opts)
+	if err := ensureTable(ctx, table, profileColumns(table.Symbols)); err != nil {
+		return nil, err
+	}
 
 	sender, err := qdb.LineSenderFromConf(ctx, conf)
 	if err != nil {
@@ -37,29 +66,78 @@ func NewProfileWriter(ctx context.Context, host string, port int) (*ProfileWrite
 	}
 
 	return &ProfileWriter{
-		sender:    sender,
-		tableName: "user_profiles",
+		sender: sender,
+		table:  table,
 	}, nil
 }
 
+// profileStrings are the string columns of a profile row
+func profileStrings(profile *UserProfile, info provenance.Info) []stringField {
+	return []stringField{
+		{"address", profile.Address},
+		{"owner", profile.Owner},
+		{provenance.HeaderInstanceID, info.InstanceID},
+		{provenance.HeaderHostname, info.Hostname},
+		{provenance.HeaderVersion, info.Version},
+		{"name", profile.Name},
+		{"pseudonym", profile.Pseudonym},
+		{"bio", profile.Bio},
+		{"icon", profile.Icon},
+		{"profile_image", profile.ProfileImage},
+		{"x_username", profile.XUsername},
+		{"profile_created_at", profile.CreatedAt},
+		{"labels", profile.Labels},
+	}
+}
+
+func profileColumns(symbols []string) []column {
+	cols := stringColumns(profileStrings(&UserProfile{}, provenance.Info{}), symbols)
+	return append(cols,
+		column{"trades_last_minute", colLong},
+		column{"trades_last_hour", colLong},
+		column{"notional_last_minute", colDouble},
+		column{"notional_last_hour", colDouble},
+		column{"burst", colBoolean},
+		column{"verified", colBoolean},
+		column{"first_seen", colTimestamp},
+		column{"last_seen", colTimestamp},
+	)
+}
+
 // Write writes a user profile to QuestDB
 func (w *ProfileWriter) Write(ctx context.Context, profile *UserProfile) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	ctx, cancel := withWriteTimeout(ctx)
+	defer cancel()
+
+	info := provenance.Current()
+
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
-	return w.sender.
-		Table(w.tableName).
-		Symbol("address", profile.Address).
-		StringColumn("name", profile.Name).
-		StringColumn("pseudonym", profile.Pseudonym).
-		StringColumn("bio", profile.Bio).
-		StringColumn("icon", profile.Icon).
-		StringColumn("profile_image", profile.ProfileImage).
-		At(ctx, time.Now())
+	row := writeStrings(w.sender, w.table.Name, w.table.Symbols, profileStrings(profile, info)).
+		Int64Column("trades_last_minute", int64(profile.TradesLastMinute)).
+		Int64Column("trades_last_hour", int64(profile.TradesLastHour)).
+		Float64Column("notional_last_minute", profile.NotionalLastMinute).
+		Float64Column("notional_last_hour", profile.NotionalLastHour).
+		BoolColumn("burst", profile.Burst).
+		BoolColumn("verified", profile.Verified)
+	if !profile.FirstSeen.IsZero() {
+		row = row.TimestampColumn("first_seen", profile.FirstSeen)
+	}
+	if !profile.LastSeen.IsZero() {
+		row = row.TimestampColumn("last_seen", profile.LastSeen)
+	}
+	return row.At(ctx, time.Now())
 }
 
 // Flush sends all buffered data to QuestDB
 func (w *ProfileWriter) Flush(ctx context.Context) error {
+	ctx, cancel := withWriteTimeout(ctx)
+	defer cancel()
+
 	w.mu.Lock()
 	defer w.mu.Unlock()
 	return w.sender.Flush(ctx)
@@ -67,13 +145,38 @@ func (w *ProfileWriter) Flush(ctx context.Context) error {
 
 // Close flushes pending data and closes the connection to QuestDB
 func (w *ProfileWriter) Close(ctx context.Context) error {
+	ctx, cancel := withWriteTimeout(ctx)
+	defer cancel()
+
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
 	// Final flush before closing
 	if err := w.sender.Flush(ctx); err != nil {
-		log.Printf("QuestDB final flush error: %v", err)
+		ingestLog.ErrorContext(ctx, "QuestDB final flush error", "table", w.table.Name, "error", err)
 	}
 
 	return w.sender.Close(ctx)
 }
+
+// ProfileHistory is what a profiles table holds about an address
+type ProfileHistory struct {
+	FirstSeen time.Time // Zero for profiles written without it
+	Written   time.Time // When its latest profile was written
+}
+
+// ProfileHistory looks address up in the profiles table, reporting whether
+// a profile of it was ever written
+func (c *QueryClient) ProfileHistory(ctx context.Context, table, address string) (ProfileHistory, bool, error) {
+	result, err := c.Query(ctx, fmt.Sprintf(
+		"SELECT min(first_seen) first_seen, max(timestamp) written FROM %s WHERE lower(address) = %s",
+		table, Quote(strings.ToLower(address))))
+	if err != nil {
+		return ProfileHistory{}, false, err
+	}
+	rows := result.Rows()
+	if len(rows) == 0 || rows[0].Time("written").IsZero() {
+		return ProfileHistory{}, false, nil
+	}
+	return ProfileHistory{FirstSeen: rows[0].Time("first_seen"), Written: rows[0].Time("written")}, true, nil
+}
@@ -0,0 +1,172 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// queryTimeout bounds a read query when the caller's context has no deadline
+const queryTimeout = 30 * time.Second
+
+// QueryClient runs SQL queries against the QuestDB HTTP API
+type QueryClient struct {
+	addr       string
+	httpClient *http.Client
+}
+
+// NewQueryClient creates a client for the QuestDB HTTP API at addr (host:port)
+func NewQueryClient(addr string) *QueryClient {
+	return &QueryClient{
+		addr:       addr,
+		httpClient: &http.Client{},
+	}
+}
+
+// QueryColumn is a column of a query result
+type QueryColumn struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// QueryResult is the result of a query as returned by /exec
+type QueryResult struct {
+	Columns []QueryColumn `json:"columns"`
+	Dataset [][]any       `json:"dataset"`
+	Count   int           `json:"count"`
+}
+
+// Rows returns the dataset as rows keyed by column name
+func (r *QueryResult) Rows() []Row {
+	rows := make([]Row, len(r.Dataset))
+	for i, values := range r.Dataset {
+		row := make(Row, len(r.Columns))
+		for j, col := range r.Columns {
+			if j < len(values) {
+				row[col.Name] = values[j]
+			}
+		}
+		rows[i] = row
+	}
+	return rows
+}
+
+// Row is a query result row keyed by column name. The accessors return the
+// zero value for NULL or missing columns.
+type Row map[string]any
+
+// String returns a string column
+func (r Row) String(name string) string {
+	s, _ := r[name].(string)
+	return s
+}
+
+// Float returns a numeric column
+func (r Row) Float(name string) float64 {
+	f, _ := r[name].(float64)
+	return f
+}
+
+// Int returns a numeric column as an int
+func (r Row) Int(name string) int {
+	return int(r.Float(name))
+}
+
+// Bool returns a boolean column
+func (r Row) Bool(name string) bool {
+	b, _ := r[name].(bool)
+	return b
+}
+
+// Time returns a timestamp column
+func (r Row) Time(name string) time.Time {
+	t, _ := time.Parse(time.RFC3339Nano, r.String(name))
+	return t
+}
+
+// Query runs query and returns its result. Without a deadline on ctx the
+// query is bounded by queryTimeout.
+func (c *QueryClient) Query(ctx context.Context, query string) (*QueryResult, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, queryTimeout)
+		defer cancel()
+	}
+	resp, err := c.get(ctx, "/exec", query)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result QueryResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode query result: %w", err)
+	}
+	return &result, nil
+}
+
+// Export streams the result of query to w as CSV (with a header row), via
+// the /exp endpoint. It isn't bounded by queryTimeout, since exports can be
+// large; cancel ctx to stop it.
+func (c *QueryClient) Export(ctx context.Context, query string, w io.Writer) error {
+	resp, err := c.get(ctx, "/exp", query)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		return fmt.Errorf("export interrupted: %w", err)
+	}
+	return nil
+}
+
+// Pages runs query in pages of pageSize rows, calling fn with each until a
+// page comes back short. query must have a stable order and no LIMIT.
+func (c *QueryClient) Pages(ctx context.Context, query string, pageSize int, fn func(*QueryResult) error) error {
+	for lo := 0; ; lo += pageSize {
+		result, err := c.Query(ctx, fmt.Sprintf("%s LIMIT %d, %d", query, lo, lo+pageSize))
+		if err != nil {
+			return err
+		}
+		if err := fn(result); err != nil {
+			return err
+		}
+		if len(result.Dataset) < pageSize {
+			return nil
+		}
+	}
+}
+
+// get sends query to endpoint, returning the response when QuestDB accepted it
+func (c *QueryClient) get(ctx context.Context, endpoint, query string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		"http://"+c.addr+endpoint+"?query="+url.QueryEscape(query), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("questdb query failed: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("questdb query failed: status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return resp, nil
+}
+
+// Quote returns s as an SQL string literal
+func Quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// QuoteTime returns t as an SQL timestamp literal
+func QuoteTime(t time.Time) string {
+	return "'" + t.UTC().Format("2006-01-02T15:04:05.000000Z") + "'"
+}
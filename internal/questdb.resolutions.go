@@ -0,0 +1,105 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	qdb "github.com/questdb/go-questdb-client/v3"
+)
+
+// ResolutionWriter writes resolved markets to QuestDB. Rather than
+// rewriting trades, the table labels them by join:
+//
+//	SELECT t.*, t.outcome_index = r.winning_index won
+//	FROM trades t JOIN market_resolutions r ON (condition_id)
+type ResolutionWriter struct {
+	sender qdb.LineSender
+	table  TableConfig
+	mu     sync.Mutex
+}
+
+var defaultResolutionTable = TableConfig{
+	Name:    "market_resolutions",
+	Symbols: []string{"condition_id"},
+}
+
+// MarketResolution is a market's winning outcome
+type MarketResolution struct {
+	ConditionID    string
+	Slug           string
+	WinningOutcome string
+	WinningAsset   string // Token ID of the winning outcome
+	WinningIndex   int
+	ResolvedAt     time.Time // When the resolution was detected
+}
+
+// NewResolutionWriter creates a new QuestDB resolution writer using ILP over TCP
+func NewResolutionWriter(ctx context.Context, host string, port int, opts ...WriterOption) (*ResolutionWriter, error) {
+	conf := fmt.Sprintf("tcp::addr=%s:%d;", host, port)
+	table := tableConfig(defaultResolutionTable, opts)
+	if err := ensureTable(ctx, table, resolutionColumns(table.Symbols)); err != nil {
+		return nil, err
+	}
+
+	sender, err := qdb.LineSenderFromConf(ctx, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ResolutionWriter{
+		sender: sender,
+		table:  table,
+	}, nil
+}
+
+func resolutionStrings(r *MarketResolution) []stringField {
+	return []stringField{
+		{"condition_id", r.ConditionID},
+		{"slug", r.Slug},
+		{"winning_outcome", r.WinningOutcome},
+		{"winning_asset", r.WinningAsset},
+	}
+}
+
+func resolutionColumns(symbols []string) []column {
+	cols := stringColumns(resolutionStrings(&MarketResolution{}), symbols)
+	return append(cols, column{"winning_index", colLong})
+}
+
+// Write sends a resolution to QuestDB right away; resolutions are rare and
+// wanted for joins as soon as they happen
+func (w *ResolutionWriter) Write(ctx context.Context, r *MarketResolution) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	ctx, cancel := withWriteTimeout(ctx)
+	defer cancel()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	err := writeStrings(w.sender, w.table.Name, w.table.Symbols, resolutionStrings(r)).
+		Int64Column("winning_index", int64(r.WinningIndex)).
+		At(ctx, r.ResolvedAt)
+	if err != nil {
+		return err
+	}
+	return w.sender.Flush(ctx)
+}
+
+// Close flushes pending data and closes the connection to QuestDB
+func (w *ResolutionWriter) Close(ctx context.Context) error {
+	ctx, cancel := withWriteTimeout(ctx)
+	defer cancel()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.sender.Flush(ctx); err != nil {
+		ingestLog.ErrorContext(ctx, "QuestDB final flush error", "table", w.table.Name, "error", err)
+	}
+
+	return w.sender.Close(ctx)
+}
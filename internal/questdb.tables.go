@@ -0,0 +1,189 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	qdb "github.com/questdb/go-questdb-client/v3"
+)
+
+// Partition granularities for QuestDB tables
+const (
+	PartitionNone  = "NONE"
+	PartitionHour  = "HOUR"
+	PartitionDay   = "DAY"
+	PartitionWeek  = "WEEK"
+	PartitionMonth = "MONTH"
+	PartitionYear  = "YEAR"
+)
+
+// ValidPartition reports whether p is a QuestDB partition granularity
+func ValidPartition(p string) bool {
+	switch strings.ToUpper(p) {
+	case PartitionNone, PartitionHour, PartitionDay, PartitionWeek, PartitionMonth, PartitionYear:
+		return true
+	}
+	return false
+}
+
+// TableConfig names a writer's table and selects its layout, so several
+// environments can share a QuestDB instance
+type TableConfig struct {
+	Name string
+	// PartitionBy creates the table with this granularity through the HTTP
+	// API at SchemaAddr. Tables created by ILP are partitioned by DAY, and
+	// an existing table's partitioning is never changed.
+	PartitionBy string
+	SchemaAddr  string // QuestDB HTTP host:port
+	// Symbols are the string columns written as SYMBOL (indexed, for
+	// low-cardinality values); nil keeps the writer's defaults
+	Symbols []string
+	// DedupKeys (with the timestamp) identify a row. On WAL tables created
+	// through SchemaAddr rows written again with the same keys replace the
+	// existing row, so replays converge instead of duplicating.
+	DedupKeys []string
+}
+
+// WriterOption configures a QuestDB writer
+type WriterOption func(*TableConfig)
+
+// WithTable overrides the writer's table settings with the non-zero fields of cfg
+func WithTable(cfg TableConfig) WriterOption {
+	return func(t *TableConfig) {
+		if cfg.Name != "" {
+			t.Name = cfg.Name
+		}
+		if cfg.PartitionBy != "" {
+			t.PartitionBy = strings.ToUpper(cfg.PartitionBy)
+		}
+		if cfg.SchemaAddr != "" {
+			t.SchemaAddr = cfg.SchemaAddr
+		}
+		if cfg.Symbols != nil {
+			t.Symbols = cfg.Symbols
+		}
+		if cfg.DedupKeys != nil {
+			t.DedupKeys = cfg.DedupKeys
+		}
+	}
+}
+
+// tableConfig applies opts to a writer's defaults
+func tableConfig(defaults TableConfig, opts []WriterOption) TableConfig {
+	for _, opt := range opts {
+		opt(&defaults)
+	}
+	return defaults
+}
+
+// stringField is a string column value; whether it is written as a symbol
+// depends on the table config
+type stringField struct {
+	name  string
+	value string
+}
+
+// Column types used in table DDL
+const (
+	colSymbol    = "SYMBOL"
+	colString    = "STRING"
+	colDouble    = "DOUBLE"
+	colLong      = "LONG"
+	colBoolean   = "BOOLEAN"
+	colTimestamp = "TIMESTAMP"
+)
+
+type column struct {
+	name string
+	typ  string
+}
+
+// stringColumns types a writer's string fields as SYMBOL or STRING
+func stringColumns(fields []stringField, symbols []string) []column {
+	cols := make([]column, len(fields))
+	for i, f := range fields {
+		cols[i] = column{name: f.name, typ: colString}
+		if slices.Contains(symbols, f.name) {
+			cols[i].typ = colSymbol
+		}
+	}
+	return cols
+}
+
+// writeStrings starts a row with fields, symbols first as ILP requires
+func writeStrings(sender qdb.LineSender, table string, symbols []string, fields []stringField) qdb.LineSender {
+	row := sender.Table(table)
+	for _, f := range fields {
+		if slices.Contains(symbols, f.name) {
+			row = row.Symbol(f.name, f.value)
+		}
+	}
+	for _, f := range fields {
+		if !slices.Contains(symbols, f.name) {
+			row = row.StringColumn(f.name, f.value)
+		}
+	}
+	return row
+}
+
+// ensureTable creates the table with its partitioning if cfg asks for one.
+// Without a partition or schema address the table is left to ILP.
+func ensureTable(ctx context.Context, cfg TableConfig, cols []column) error {
+	if cfg.PartitionBy == "" || cfg.SchemaAddr == "" {
+		return nil
+	}
+	if !ValidPartition(cfg.PartitionBy) {
+		return fmt.Errorf("invalid partition %q for table %s", cfg.PartitionBy, cfg.Name)
+	}
+
+	defs := make([]string, 0, len(cols)+1)
+	for _, c := range cols {
+		defs = append(defs, c.name+" "+c.typ)
+	}
+	defs = append(defs, "timestamp TIMESTAMP")
+	query := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s) TIMESTAMP(timestamp) PARTITION BY %s",
+		cfg.Name, strings.Join(defs, ", "), cfg.PartitionBy)
+	if cfg.PartitionBy != PartitionNone {
+		query += " WAL"
+	}
+
+	if err := execDDL(ctx, cfg.SchemaAddr, query); err != nil {
+		return fmt.Errorf("failed to create table %s: %w", cfg.Name, err)
+	}
+
+	// Enabled separately so tables created before the keys were set get them
+	if len(cfg.DedupKeys) > 0 && cfg.PartitionBy != PartitionNone {
+		keys := append([]string{"timestamp"}, cfg.DedupKeys...)
+		query := fmt.Sprintf("ALTER TABLE %s DEDUP ENABLE UPSERT KEYS(%s)", cfg.Name, strings.Join(keys, ", "))
+		if err := execDDL(ctx, cfg.SchemaAddr, query); err != nil {
+			return fmt.Errorf("failed to enable dedup on table %s: %w", cfg.Name, err)
+		}
+	}
+	return nil
+}
+
+// execDDL runs a DDL statement through the QuestDB HTTP API at addr
+func execDDL(ctx context.Context, addr, query string) error {
+	ctx, cancel := context.WithTimeout(ctx, writeTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		"http://"+addr+"/exec?query="+url.QueryEscape(query), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := (&http.Client{Timeout: writeTimeout}).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("status %d: %s", resp.StatusCode, body)
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package replay
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/FatwaArya/pm-ingest/internal/archive"
+)
+
+// maxLine bounds a message line; WebSocket messages are far smaller
+const maxLine = 16 << 20
+
+// Files reads the messages of files, and of the files under directories,
+// in name order. A file ending in .gz is gunzipped. Each line is a raw
+// stream record of the archive, or a message as received.
+func Files(paths ...string) Source {
+	return func(ctx context.Context, emit func(Message) error) error {
+		files, err := expand(paths)
+		if err != nil {
+			return err
+		}
+		for _, path := range files {
+			if err := readFile(ctx, path, emit); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// Bucket reads the archive files under prefix in store, in key order
+func Bucket(store *archive.S3Store, prefix string) Source {
+	return func(ctx context.Context, emit func(Message) error) error {
+		keys, err := store.List(ctx, prefix)
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			body, err := store.Open(ctx, key)
+			if err != nil {
+				return err
+			}
+			err = readLines(ctx, body, strings.HasSuffix(key, ".gz"), emit)
+			body.Close()
+			if err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+		}
+		return nil
+	}
+}
+
+// expand replaces directories with the files under them
+func expand(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		var found []string
+		err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err == nil && !d.IsDir() && (strings.HasSuffix(p, ".jsonl") || strings.HasSuffix(p, ".jsonl.gz")) {
+				found = append(found, p)
+			}
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(found)
+		files = append(files, found...)
+	}
+	return files, nil
+}
+
+func readFile(ctx context.Context, path string, emit func(Message) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := readLines(ctx, f, strings.HasSuffix(path, ".gz"), emit); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// readLines emits a message per line of r. A truncated gzip stream, as
+// left by a crash, ends the file without an error.
+func readLines(ctx context.Context, r io.Reader, gzipped bool, emit func(Message) error) error {
+	if gzipped {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLine)
+	for scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if err := emit(parseLine(line)); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil && err != io.ErrUnexpectedEOF {
+		return err
+	}
+	return nil
+}
+
+// parseLine reads an archive record, or takes the line as the message
+func parseLine(line []byte) Message {
+	var record archive.Record
+	if json.Unmarshal(line, &record) == nil && len(record.Message) > 0 {
+		data := []byte(record.Message)
+		// Messages that weren't JSON are archived as strings
+		var text string
+		if json.Unmarshal(data, &text) == nil {
+			data = []byte(text)
+		}
+		return Message{At: record.ReceivedAt, Data: data}
+	}
+	data := bytes.Clone(line)
+	var message struct {
+		Timestamp int64 `json:"timestamp"`
+	}
+	var at time.Time
+	if json.Unmarshal(data, &message) == nil && message.Timestamp > 0 {
+		at = time.UnixMilli(message.Timestamp)
+	}
+	return Message{At: at, Data: data}
+}
@@ -0,0 +1,123 @@
+// Package replay feeds captured WebSocket messages back through the ingest
+// pipeline, for reprocessing after a fix or for load testing. Messages come
+// from archive files (see package archive), a bucket of them, or a Kafka
+// topic, and are submitted at full speed or at their original pace.
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/FatwaArya/pm-ingest/pkg/clock"
+	"github.com/FatwaArya/pm-ingest/pkg/rtds"
+)
+
+// Message is a captured WebSocket message
+type Message struct {
+	At   time.Time // When it was received; zero if not known
+	Data []byte
+}
+
+// Source emits captured messages in order until it's exhausted, emit fails
+// or ctx is done
+type Source func(ctx context.Context, emit func(Message) error) error
+
+// Stats counts what a replay did
+type Stats struct {
+	Messages int
+	First    time.Time // Timestamps of the first and last message, if known
+	Last     time.Time
+}
+
+// Replayer submits the messages of a source
+type Replayer struct {
+	speed float64
+	clock clock.Clock
+}
+
+// New creates a replayer. A speed of 0 submits messages as fast as the
+// pipeline takes them; 1 keeps the gaps between their timestamps, 2 halves
+// them, and so on.
+func New(speed float64) *Replayer {
+	return &Replayer{speed: speed, clock: clock.Real}
+}
+
+// SetClock replaces the clock pacing the replay
+func (r *Replayer) SetClock(c clock.Clock) {
+	r.clock = clock.OrReal(c)
+}
+
+// Run submits every message of src. Messages without a timestamp are
+// submitted right away.
+func (r *Replayer) Run(ctx context.Context, src Source, submit func(ctx context.Context, message []byte) error) (Stats, error) {
+	var (
+		stats   Stats
+		started time.Time // Wall time the first timed message was submitted
+	)
+	err := src(ctx, func(m Message) error {
+		if r.speed > 0 && !m.At.IsZero() {
+			if started.IsZero() {
+				started = r.clock.Now()
+				stats.First = m.At
+			}
+			if m.At.After(stats.First) {
+				due := started.Add(time.Duration(float64(m.At.Sub(stats.First)) / r.speed))
+				if err := r.wait(ctx, due); err != nil {
+					return err
+				}
+			}
+		}
+		if err := submit(ctx, m.Data); err != nil {
+			return err
+		}
+		stats.Messages++
+		if stats.First.IsZero() {
+			stats.First = m.At
+		}
+		if m.At.After(stats.Last) {
+			stats.Last = m.At
+		}
+		return nil
+	})
+	return stats, err
+}
+
+// wait blocks until due or ctx is done
+func (r *Replayer) wait(ctx context.Context, due time.Time) error {
+	delay := due.Sub(r.clock.Now())
+	if delay <= 0 {
+		return nil
+	}
+	timer := r.clock.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// TradeMessage wraps a trade, as produced to the trades topic, in the
+// activity message it came in. Messages that already are WebSocket
+// messages are returned as they are.
+func TradeMessage(data []byte, at time.Time) []byte {
+	var probe struct {
+		Topic           string `json:"topic"`
+		TransactionHash string `json:"transactionHash"`
+	}
+	if json.Unmarshal(data, &probe) != nil || probe.Topic != "" || probe.TransactionHash == "" {
+		return data
+	}
+	wrapped, err := json.Marshal(rtds.IncomingMessage{
+		Topic:     rtds.TopicActivity,
+		Type:      rtds.TypeTrades,
+		Timestamp: at.UnixMilli(),
+		Payload:   data,
+	})
+	if err != nil {
+		return data
+	}
+	return wrapped
+}
@@ -0,0 +1,92 @@
+package replay
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/FatwaArya/pm-ingest/pkg/clock"
+)
+
+func TestReplayerKeepsPace(t *testing.T) {
+	start := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	src := Source(func(ctx context.Context, emit func(Message) error) error {
+		for _, offset := range []time.Duration{0, 4 * time.Second, 10 * time.Second} {
+			if err := emit(Message{At: start.Add(offset), Data: []byte("{}")}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	fake := clock.NewFake(time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC))
+	r := New(2)
+	r.SetClock(fake)
+	var submitted []time.Duration
+	done := make(chan Stats)
+	go func() {
+		stats, _ := r.Run(context.Background(), src, func(context.Context, []byte) error {
+			submitted = append(submitted, fake.Since(time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)))
+			return nil
+		})
+		done <- stats
+	}()
+	// At twice the pace, the gaps of 4s and 6s become 2s and 3s
+	fake.BlockUntil(1)
+	fake.Advance(2 * time.Second)
+	fake.BlockUntil(1)
+	fake.Advance(3 * time.Second)
+	stats := <-done
+
+	want := []time.Duration{0, 2 * time.Second, 5 * time.Second}
+	if len(submitted) != len(want) {
+		t.Fatalf("submitted at %v, want %v", submitted, want)
+	}
+	for i := range want {
+		if submitted[i] != want[i] {
+			t.Errorf("message %d submitted at %v, want %v", i, submitted[i], want[i])
+		}
+	}
+	if stats.Messages != 3 || !stats.First.Equal(start) || !stats.Last.Equal(start.Add(10*time.Second)) {
+		t.Errorf("stats = %+v", stats)
+	}
+}
+
+func TestFilesReadsArchiveRecords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "capture.jsonl")
+	lines := `{"receivedAt":"2025-03-01T10:00:00Z","message":{"topic":"activity","type":"trades"}}
+{"receivedAt":"2025-03-01T10:00:01Z","message":"pong"}
+
+{"topic":"activity","type":"trades","timestamp":1740823202000}
+`
+	if err := os.WriteFile(path, []byte(lines), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []Message
+	err := Files(filepath.Dir(path))(context.Background(), func(m Message) error {
+		got = append(got, m)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		data string
+		at   time.Time
+	}{
+		{`{"topic":"activity","type":"trades"}`, time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)},
+		{`pong`, time.Date(2025, 3, 1, 10, 0, 1, 0, time.UTC)},
+		{`{"topic":"activity","type":"trades","timestamp":1740823202000}`, time.Date(2025, 3, 1, 10, 0, 2, 0, time.UTC)},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d messages, want %d", len(got), len(want))
+	}
+	for i, w := range want {
+		if string(got[i].Data) != w.data || !got[i].At.Equal(w.at) {
+			t.Errorf("message %d = %s at %s, want %s at %s", i, got[i].Data, got[i].At, w.data, w.at)
+		}
+	}
+}
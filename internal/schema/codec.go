@@ -0,0 +1,277 @@
+package schema
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+var errShortAvro = errors.New("avro: truncated record")
+
+// appendAvro appends the Avro binary encoding of the struct v
+func appendAvro(b []byte, r *recordType, v reflect.Value) []byte {
+	for _, f := range r.fields {
+		fv := v.Field(f.index)
+		switch f.kind {
+		case kindString:
+			b = appendAvroString(b, fv.String())
+		case kindDouble:
+			b = binary.LittleEndian.AppendUint64(b, math.Float64bits(fv.Float()))
+		case kindLong:
+			b = appendAvroLong(b, fv.Int())
+		case kindBool:
+			if fv.Bool() {
+				b = append(b, 1)
+			} else {
+				b = append(b, 0)
+			}
+		case kindStrings:
+			// One block of all items, then the empty block ending the array
+			if n := fv.Len(); n > 0 {
+				b = appendAvroLong(b, int64(n))
+				for i := 0; i < n; i++ {
+					b = appendAvroString(b, fv.Index(i).String())
+				}
+			}
+			b = appendAvroLong(b, 0)
+		case kindRecord:
+			b = appendAvro(b, f.record, fv)
+		}
+	}
+	return b
+}
+
+func appendAvroLong(b []byte, n int64) []byte {
+	return binary.AppendUvarint(b, uint64(n<<1)^uint64(n>>63))
+}
+
+func appendAvroString(b []byte, s string) []byte {
+	b = appendAvroLong(b, int64(len(s)))
+	return append(b, s...)
+}
+
+// avroReader decodes Avro binary data
+type avroReader struct {
+	data []byte
+}
+
+func (r *avroReader) long() (int64, error) {
+	u, n := binary.Uvarint(r.data)
+	if n <= 0 {
+		return 0, errShortAvro
+	}
+	r.data = r.data[n:]
+	return int64(u>>1) ^ -int64(u&1), nil
+}
+
+func (r *avroReader) bytes(n int) ([]byte, error) {
+	if n < 0 || n > len(r.data) {
+		return nil, errShortAvro
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b, nil
+}
+
+func (r *avroReader) string() (string, error) {
+	n, err := r.long()
+	if err != nil {
+		return "", err
+	}
+	b, err := r.bytes(int(n))
+	return string(b), err
+}
+
+// readAvro decodes a record into the struct v. Data ending at a field
+// boundary leaves the remaining fields zero: it was written by an older
+// version of the schema.
+func readAvro(r *avroReader, rec *recordType, v reflect.Value) error {
+	for _, f := range rec.fields {
+		if len(r.data) == 0 {
+			return nil
+		}
+		if err := readAvroField(r, f, v.Field(f.index)); err != nil {
+			return fmt.Errorf("field %s: %w", f.name, err)
+		}
+	}
+	return nil
+}
+
+func readAvroField(r *avroReader, f field, fv reflect.Value) error {
+	switch f.kind {
+	case kindString:
+		s, err := r.string()
+		if err != nil {
+			return err
+		}
+		fv.SetString(s)
+	case kindDouble:
+		b, err := r.bytes(8)
+		if err != nil {
+			return err
+		}
+		fv.SetFloat(math.Float64frombits(binary.LittleEndian.Uint64(b)))
+	case kindLong:
+		n, err := r.long()
+		if err != nil {
+			return err
+		}
+		fv.SetInt(n)
+	case kindBool:
+		b, err := r.bytes(1)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(b[0] != 0)
+	case kindStrings:
+		var items []string
+		for {
+			n, err := r.long()
+			if err != nil {
+				return err
+			}
+			if n == 0 {
+				break
+			}
+			if n < 0 { // A negative count is followed by the block size in bytes
+				n = -n
+				if _, err := r.long(); err != nil {
+					return err
+				}
+			}
+			for ; n > 0; n-- {
+				s, err := r.string()
+				if err != nil {
+					return err
+				}
+				items = append(items, s)
+			}
+		}
+		fv.Set(reflect.ValueOf(items).Convert(fv.Type()))
+	case kindRecord:
+		for _, sub := range f.record.fields {
+			if err := readAvroField(r, sub, fv.Field(sub.index)); err != nil {
+				return fmt.Errorf("%s: %w", sub.name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// appendProto appends the Protobuf encoding of the struct v; zero values
+// are omitted, as in proto3
+func appendProto(b []byte, r *recordType, v reflect.Value) []byte {
+	for i, f := range r.fields {
+		num := protowire.Number(i + 1)
+		fv := v.Field(f.index)
+		switch f.kind {
+		case kindString:
+			if s := fv.String(); s != "" {
+				b = protowire.AppendTag(b, num, protowire.BytesType)
+				b = protowire.AppendString(b, s)
+			}
+		case kindDouble:
+			if x := fv.Float(); x != 0 {
+				b = protowire.AppendTag(b, num, protowire.Fixed64Type)
+				b = protowire.AppendFixed64(b, math.Float64bits(x))
+			}
+		case kindLong:
+			if n := fv.Int(); n != 0 {
+				b = protowire.AppendTag(b, num, protowire.VarintType)
+				b = protowire.AppendVarint(b, uint64(n))
+			}
+		case kindBool:
+			if fv.Bool() {
+				b = protowire.AppendTag(b, num, protowire.VarintType)
+				b = protowire.AppendVarint(b, 1)
+			}
+		case kindStrings:
+			for j := 0; j < fv.Len(); j++ {
+				b = protowire.AppendTag(b, num, protowire.BytesType)
+				b = protowire.AppendString(b, fv.Index(j).String())
+			}
+		case kindRecord:
+			if sub := appendProto(nil, f.record, fv); len(sub) > 0 {
+				b = protowire.AppendTag(b, num, protowire.BytesType)
+				b = protowire.AppendBytes(b, sub)
+			}
+		}
+	}
+	return b
+}
+
+// readProto decodes a message into the struct v, skipping unknown fields
+func readProto(data []byte, r *recordType, v reflect.Value) error {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+
+		idx := int(num) - 1
+		if idx < 0 || idx >= len(r.fields) || typ != r.fields[idx].protoWireType() {
+			if n = protowire.ConsumeFieldValue(num, typ, data); n < 0 {
+				return protowire.ParseError(n)
+			}
+			data = data[n:]
+			continue
+		}
+
+		f := r.fields[idx]
+		fv := v.Field(f.index)
+		switch f.kind {
+		case kindDouble:
+			x, m := protowire.ConsumeFixed64(data)
+			if m < 0 {
+				return protowire.ParseError(m)
+			}
+			fv.SetFloat(math.Float64frombits(x))
+			n = m
+		case kindLong, kindBool:
+			x, m := protowire.ConsumeVarint(data)
+			if m < 0 {
+				return protowire.ParseError(m)
+			}
+			if f.kind == kindBool {
+				fv.SetBool(x != 0)
+			} else {
+				fv.SetInt(int64(x))
+			}
+			n = m
+		default:
+			b, m := protowire.ConsumeBytes(data)
+			if m < 0 {
+				return protowire.ParseError(m)
+			}
+			switch f.kind {
+			case kindString:
+				fv.SetString(string(b))
+			case kindStrings:
+				fv.Set(reflect.Append(fv, reflect.ValueOf(string(b)).Convert(fv.Type().Elem())))
+			case kindRecord:
+				if err := readProto(b, f.record, fv); err != nil {
+					return fmt.Errorf("field %s: %w", f.name, err)
+				}
+			}
+			n = m
+		}
+		data = data[n:]
+	}
+	return nil
+}
+
+func (f field) protoWireType() protowire.Type {
+	switch f.kind {
+	case kindDouble:
+		return protowire.Fixed64Type
+	case kindLong, kindBool:
+		return protowire.VarintType
+	default:
+		return protowire.BytesType
+	}
+}
@@ -0,0 +1,80 @@
+package schema
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/FatwaArya/pm-ingest/pkg/pmerrors"
+)
+
+// registryContentType is the Schema Registry API media type
+const registryContentType = "application/vnd.schemaregistry.v1+json"
+
+// Registry is a Confluent Schema Registry client
+type Registry struct {
+	httpClient *http.Client
+	baseURL    string
+	username   string
+	password   string
+}
+
+// NewRegistry creates a client for the registry at baseURL. username and
+// password, if set, are sent as basic auth (e.g. a Confluent Cloud API key).
+func NewRegistry(baseURL, username, password string) *Registry {
+	return &Registry{
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		username:   username,
+		password:   password,
+	}
+}
+
+// Register registers schema under subject, returning its ID. Registering a
+// schema the subject already has returns the existing ID; an incompatible
+// one fails with the registry's error.
+func (r *Registry) Register(ctx context.Context, subject, schemaType, schema string) (int, error) {
+	body, err := json.Marshal(map[string]string{"schema": schema, "schemaType": schemaType})
+	if err != nil {
+		return 0, err
+	}
+	apiURL := fmt.Sprintf("%s/subjects/%s/versions", r.baseURL, url.PathEscape(subject))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, apiURL, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", registryContentType)
+	req.Header.Set("Accept", registryContentType)
+	if r.username != "" {
+		req.SetBasicAuth(r.username, r.password)
+	}
+
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return 0, &pmerrors.APIError{
+			StatusCode: resp.StatusCode,
+			Body:       string(body),
+			URL:        apiURL,
+		}
+	}
+
+	var registered struct {
+		ID int `json:"id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&registered); err != nil {
+		return 0, pmerrors.Decode("schema registry response", err)
+	}
+	return registered.ID, nil
+}
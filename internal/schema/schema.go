@@ -0,0 +1,221 @@
+// Package schema serializes Kafka record values as Avro or Protobuf, with
+// their schemas registered in a Confluent Schema Registry, in the Confluent
+// wire format: a zero magic byte, the 4-byte schema ID, then the payload.
+//
+// Schemas are derived from Go structs, named by their JSON tags. Fields are
+// only ever appended to a struct, so each version stays readable by
+// consumers of the previous ones; the Protobuf field numbers are the field
+// positions.
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Namespace is the Avro namespace and Protobuf package of every schema
+const Namespace = "pm_ingest"
+
+// kind is a field type supported by both encodings
+type kind int
+
+const (
+	kindString kind = iota
+	kindDouble
+	kindLong
+	kindBool
+	kindStrings
+	kindRecord
+)
+
+type field struct {
+	name   string
+	index  int // Go struct field index
+	kind   kind
+	record *recordType // kindRecord only
+}
+
+type recordType struct {
+	name   string
+	fields []field
+}
+
+// Schema is a versioned record type
+type Schema struct {
+	Name    string
+	Version int
+	typ     reflect.Type
+	record  *recordType
+}
+
+// New derives the schema of the struct v. Strings, floats, integers, bools,
+// string slices and nested structs are supported.
+func New(name string, version int, v any) (*Schema, error) {
+	typ := reflect.TypeOf(v)
+	record, err := newRecord(name, typ)
+	if err != nil {
+		return nil, err
+	}
+	return &Schema{Name: name, Version: version, typ: typ, record: record}, nil
+}
+
+// MustNew is New for package-level schemas
+func MustNew(name string, version int, v any) *Schema {
+	s, err := New(name, version, v)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// Subject is the registry subject of the schema, named after the record
+// rather than a topic so one registration covers every topic it's sent to
+func (s *Schema) Subject() string {
+	return Namespace + "." + s.Name
+}
+
+func newRecord(name string, typ reflect.Type) (*recordType, error) {
+	if typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("schema %s: %s is not a struct", name, typ)
+	}
+	r := &recordType{name: name}
+	for i := 0; i < typ.NumField(); i++ {
+		sf := typ.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		fname := sf.Name
+		if tag := strings.Split(sf.Tag.Get("json"), ",")[0]; tag == "-" {
+			continue
+		} else if tag != "" {
+			fname = tag
+		}
+
+		f := field{name: fname, index: i}
+		switch sf.Type.Kind() {
+		case reflect.String:
+			f.kind = kindString
+		case reflect.Float32, reflect.Float64:
+			f.kind = kindDouble
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			f.kind = kindLong
+		case reflect.Bool:
+			f.kind = kindBool
+		case reflect.Slice:
+			if sf.Type.Elem().Kind() != reflect.String {
+				return nil, fmt.Errorf("schema %s: field %s: only string slices are supported", name, fname)
+			}
+			f.kind = kindStrings
+		case reflect.Struct:
+			sub, err := newRecord(sf.Type.Name(), sf.Type)
+			if err != nil {
+				return nil, err
+			}
+			f.kind = kindRecord
+			f.record = sub
+		default:
+			return nil, fmt.Errorf("schema %s: field %s: unsupported type %s", name, fname, sf.Type)
+		}
+		r.fields = append(r.fields, f)
+	}
+	return r, nil
+}
+
+// Avro returns the Avro schema. Every field has a default, so records
+// written before a field was added still decode.
+func (s *Schema) Avro() string {
+	root := s.record.avro()
+	root["namespace"] = Namespace
+	root["doc"] = fmt.Sprintf("%s, version %d", s.Name, s.Version)
+	data, _ := json.Marshal(root)
+	return string(data)
+}
+
+func (r *recordType) avro() map[string]any {
+	fields := make([]map[string]any, len(r.fields))
+	for i, f := range r.fields {
+		fields[i] = map[string]any{"name": f.name, "type": f.avroType(), "default": f.avroDefault()}
+	}
+	return map[string]any{"type": "record", "name": r.name, "fields": fields}
+}
+
+func (f field) avroType() any {
+	switch f.kind {
+	case kindString:
+		return "string"
+	case kindDouble:
+		return "double"
+	case kindLong:
+		return "long"
+	case kindBool:
+		return "boolean"
+	case kindStrings:
+		return map[string]any{"type": "array", "items": "string"}
+	default:
+		return f.record.avro()
+	}
+}
+
+func (f field) avroDefault() any {
+	switch f.kind {
+	case kindString:
+		return ""
+	case kindDouble:
+		return 0.0
+	case kindLong:
+		return 0
+	case kindBool:
+		return false
+	case kindStrings:
+		return []string{}
+	default:
+		def := make(map[string]any, len(f.record.fields))
+		for _, sub := range f.record.fields {
+			def[sub.name] = sub.avroDefault()
+		}
+		return def
+	}
+}
+
+// Proto returns the Protobuf (proto3) schema
+func (s *Schema) Proto() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "syntax = \"proto3\";\npackage %s;\n\n", Namespace)
+	fmt.Fprintf(&b, "// %s, version %d. Fields are only ever appended.\n", s.Name, s.Version)
+	s.record.proto(&b, "")
+	return b.String()
+}
+
+func (r *recordType) proto(b *strings.Builder, indent string) {
+	fmt.Fprintf(b, "%smessage %s {\n", indent, r.name)
+	nested := map[string]bool{}
+	for _, f := range r.fields {
+		if f.kind == kindRecord && !nested[f.record.name] {
+			nested[f.record.name] = true
+			f.record.proto(b, indent+"  ")
+		}
+	}
+	for i, f := range r.fields {
+		fmt.Fprintf(b, "%s  %s %s = %d;\n", indent, f.protoType(), f.name, i+1)
+	}
+	fmt.Fprintf(b, "%s}\n", indent)
+}
+
+func (f field) protoType() string {
+	switch f.kind {
+	case kindString:
+		return "string"
+	case kindDouble:
+		return "double"
+	case kindLong:
+		return "int64"
+	case kindBool:
+		return "bool"
+	case kindStrings:
+		return "repeated string"
+	default:
+		return f.record.name
+	}
+}
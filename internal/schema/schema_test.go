@@ -0,0 +1,125 @@
+package schema
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type testInner struct {
+	Score float64 `json:"score"`
+	Count int     `json:"count"`
+}
+
+type testRecord struct {
+	Name    string    `json:"name"`
+	Price   float64   `json:"price"`
+	Size    int64     `json:"size"`
+	Taker   bool      `json:"taker"`
+	Labels  []string  `json:"labels,omitempty"`
+	Inner   testInner `json:"inner"`
+	Skipped string    `json:"-"`
+}
+
+var testSchema = MustNew("TestRecord", 1, testRecord{})
+
+// registryStub serves schema registrations, returning id for all of them
+func registryStub(t *testing.T, id int) *Registry {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/subjects/pm_ingest.TestRecord/versions" {
+			http.NotFound(w, r)
+			return
+		}
+		var body map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body["schema"] == "" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]int{"id": id})
+	}))
+	t.Cleanup(srv.Close)
+	return NewRegistry(srv.URL, "", "")
+}
+
+func TestRoundTrip(t *testing.T) {
+	in := testRecord{
+		Name:   "0xabc",
+		Price:  0.42,
+		Size:   -7,
+		Taker:  true,
+		Labels: []string{"whale", "new"},
+		Inner:  testInner{Score: 0.1, Count: 3},
+	}
+	for _, format := range []string{FormatJSON, FormatAvro, FormatProtobuf} {
+		s, err := NewSerializer(format, registryStub(t, 42))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Register(context.Background(), testSchema); err != nil {
+			t.Fatalf("%s: Register: %v", format, err)
+		}
+		data, err := s.Encode(testSchema, &in)
+		if err != nil {
+			t.Fatalf("%s: Encode: %v", format, err)
+		}
+		if format != FormatJSON && (data[0] != magicByte || data[4] != 42) {
+			t.Errorf("%s: header = %v, want magic byte and schema ID 42", format, data[:5])
+		}
+		var out testRecord
+		if err := s.Decode(testSchema, data, &out); err != nil {
+			t.Fatalf("%s: Decode: %v", format, err)
+		}
+		if !reflect.DeepEqual(in, out) {
+			t.Errorf("%s: round trip = %+v, want %+v", format, out, in)
+		}
+	}
+}
+
+func TestDecodeJSONWithSchemaFormat(t *testing.T) {
+	s, err := NewSerializer(FormatAvro, registryStub(t, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out testRecord
+	if err := s.Decode(testSchema, []byte(`{"name":"legacy","price":0.5}`), &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Name != "legacy" || out.Price != 0.5 {
+		t.Errorf("decoded %+v", out)
+	}
+}
+
+func TestEncodeUnregistered(t *testing.T) {
+	s, err := NewSerializer(FormatProtobuf, registryStub(t, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Encode(testSchema, testRecord{}); err == nil {
+		t.Error("Encode before Register succeeded")
+	}
+}
+
+func TestSchemaText(t *testing.T) {
+	proto := testSchema.Proto()
+	for _, want := range []string{"message TestRecord {", "message testInner {", "repeated string labels = 5;", "testInner inner = 6;"} {
+		if !strings.Contains(proto, want) {
+			t.Errorf("proto schema lacks %q:\n%s", want, proto)
+		}
+	}
+	if strings.Contains(proto, "Skipped") {
+		t.Error("proto schema has the json:\"-\" field")
+	}
+
+	var avro map[string]any
+	if err := json.Unmarshal([]byte(testSchema.Avro()), &avro); err != nil {
+		t.Fatalf("avro schema isn't JSON: %v", err)
+	}
+	if avro["namespace"] != Namespace || len(avro["fields"].([]any)) != 6 {
+		t.Errorf("avro schema = %v", avro)
+	}
+}
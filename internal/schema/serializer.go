@@ -0,0 +1,160 @@
+package schema
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// Serialization formats accepted in SCHEMA_FORMAT
+const (
+	FormatJSON     = "json"
+	FormatAvro     = "avro"
+	FormatProtobuf = "protobuf"
+)
+
+// magicByte starts every record in the Confluent wire format
+const magicByte = 0
+
+// ErrNotRegistered is returned when encoding with a schema that wasn't
+// registered at startup
+var ErrNotRegistered = errors.New("schema not registered")
+
+// Serializer encodes record values in one format. With FormatJSON values
+// are plain JSON and nothing is registered.
+type Serializer struct {
+	format   string
+	registry *Registry
+
+	mu  sync.RWMutex
+	ids map[*Schema]int
+}
+
+// NewSerializer creates a serializer for format; registry may be nil for
+// FormatJSON
+func NewSerializer(format string, registry *Registry) (*Serializer, error) {
+	switch format {
+	case FormatJSON:
+	case FormatAvro, FormatProtobuf:
+		if registry == nil {
+			return nil, fmt.Errorf("format %s needs a schema registry", format)
+		}
+	default:
+		return nil, fmt.Errorf("unknown serialization format %q", format)
+	}
+	return &Serializer{format: format, registry: registry, ids: make(map[*Schema]int)}, nil
+}
+
+// Format returns the serialization format
+func (s *Serializer) Format() string {
+	return s.format
+}
+
+// Register registers the schemas under their subjects, so they can be
+// encoded with
+func (s *Serializer) Register(ctx context.Context, schemas ...*Schema) error {
+	if s.format == FormatJSON {
+		return nil
+	}
+	for _, sc := range schemas {
+		schemaType, text := "AVRO", sc.Avro()
+		if s.format == FormatProtobuf {
+			schemaType, text = "PROTOBUF", sc.Proto()
+		}
+		id, err := s.registry.Register(ctx, sc.Subject(), schemaType, text)
+		if err != nil {
+			return fmt.Errorf("registering %s: %w", sc.Subject(), err)
+		}
+		s.mu.Lock()
+		s.ids[sc] = id
+		s.mu.Unlock()
+	}
+	return nil
+}
+
+// Encode serializes v, a value (or pointer to a value) of the schema's
+// struct
+func (s *Serializer) Encode(sc *Schema, v any) ([]byte, error) {
+	if s.format == FormatJSON {
+		return json.Marshal(v)
+	}
+	s.mu.RLock()
+	id, ok := s.ids[sc]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotRegistered, sc.Subject())
+	}
+	rv, err := sc.value(v)
+	if err != nil {
+		return nil, err
+	}
+
+	b := []byte{magicByte}
+	b = binary.BigEndian.AppendUint32(b, uint32(id))
+	if s.format == FormatProtobuf {
+		b = append(b, 0) // Message indexes: the first message of the schema
+		return appendProto(b, sc.record, rv), nil
+	}
+	return appendAvro(b, sc.record, rv), nil
+}
+
+// Decode deserializes data into v, a pointer to the schema's struct. Plain
+// JSON values, e.g. written before a format was configured, are decoded
+// whatever the format.
+func (s *Serializer) Decode(sc *Schema, data []byte, v any) error {
+	if len(data) == 0 || data[0] != magicByte {
+		return json.Unmarshal(data, v)
+	}
+	if s.format == FormatJSON {
+		return errors.New("schema-encoded record, but no format is configured")
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.Elem().Type() != sc.typ {
+		return fmt.Errorf("schema %s decodes into *%s, not %T", sc.Name, sc.typ, v)
+	}
+	if len(data) < 5 {
+		return errors.New("truncated schema ID")
+	}
+	payload := data[5:] // Any schema ID: versions only append fields
+
+	if s.format == FormatProtobuf {
+		var err error
+		if payload, err = skipMessageIndexes(payload); err != nil {
+			return err
+		}
+		return readProto(payload, sc.record, rv.Elem())
+	}
+	return readAvro(&avroReader{data: payload}, sc.record, rv.Elem())
+}
+
+// value returns the struct v, dereferencing a pointer
+func (sc *Schema) value(v any) (reflect.Value, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Pointer {
+		rv = rv.Elem()
+	}
+	if rv.Type() != sc.typ {
+		return reflect.Value{}, fmt.Errorf("schema %s encodes %s, not %T", sc.Name, sc.typ, v)
+	}
+	return rv, nil
+}
+
+// skipMessageIndexes skips the Protobuf message indexes following the
+// schema ID: a zigzag varint count, then that many indexes
+func skipMessageIndexes(data []byte) ([]byte, error) {
+	r := &avroReader{data: data} // Same zigzag varints as Avro longs
+	n, err := r.long()
+	if err != nil {
+		return nil, err
+	}
+	for ; n > 0; n-- {
+		if _, err := r.long(); err != nil {
+			return nil, err
+		}
+	}
+	return r.data, nil
+}
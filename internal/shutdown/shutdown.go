@@ -0,0 +1,154 @@
+// Package shutdown coordinates stopping the ingester: input is stopped and
+// buffered data delivered before the root context is cancelled, background
+// goroutines are waited for before the resources they use are closed, and
+// HTTP servers go last so probes and metrics are served throughout.
+package shutdown
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/FatwaArya/pm-ingest/internal/logging"
+)
+
+var shutdownLog = logging.For("shutdown")
+
+// Phase orders shutdown steps. Steps of a phase run one at a time, in the
+// order they were added.
+type Phase int
+
+const (
+	// PhaseIngest stops taking in data: WebSocket clients, leadership
+	PhaseIngest Phase = iota
+	// PhaseFlush delivers buffered data, e.g. producer and writer flushes
+	PhaseFlush
+	// PhaseClose runs once the root context is cancelled and the goroutines
+	// started with Go have returned: close consumers, writers and stores
+	PhaseClose
+	// PhaseServers stops the HTTP servers
+	PhaseServers
+
+	numPhases
+)
+
+var phaseNames = [numPhases]string{"ingest", "flush", "close", "servers"}
+
+func (p Phase) String() string {
+	if p < 0 || p >= numPhases {
+		return fmt.Sprintf("phase(%d)", int(p))
+	}
+	return phaseNames[p]
+}
+
+type step struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+// Coordinator runs the shutdown steps of every subsystem in phases, within
+// one overall timeout
+type Coordinator struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	timeout time.Duration
+
+	mu    sync.Mutex
+	steps [numPhases][]step
+	wg    sync.WaitGroup
+	once  sync.Once
+	err   error
+}
+
+// New creates a coordinator whose root context derives from parent.
+// Shutdown gives up on steps still running after timeout.
+func New(parent context.Context, timeout time.Duration) *Coordinator {
+	ctx, cancel := context.WithCancel(parent)
+	return &Coordinator{ctx: ctx, cancel: cancel, timeout: timeout}
+}
+
+// Context returns the root context, cancelled by Shutdown after the flush
+// phase; everything long-running should derive from it
+func (c *Coordinator) Context() context.Context {
+	return c.ctx
+}
+
+// Add registers a step to run in phase. fn gets a context bounded by the
+// shutdown timeout.
+func (c *Coordinator) Add(phase Phase, name string, fn func(ctx context.Context) error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.steps[phase] = append(c.steps[phase], step{name: name, fn: fn})
+}
+
+// Go runs fn with the root context in a goroutine. Shutdown waits for it to
+// return before the close phase; errors other than cancellation are logged.
+func (c *Coordinator) Go(name string, fn func(ctx context.Context) error) {
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		if err := fn(c.ctx); err != nil && !errors.Is(err, context.Canceled) {
+			shutdownLog.ErrorContext(c.ctx, "Background task error", "task", name, "error", err)
+		}
+	}()
+}
+
+// Shutdown runs the phases in order, cancelling the root context between
+// flush and close. It returns the errors of failed steps; only the first
+// call does anything.
+func (c *Coordinator) Shutdown() error {
+	c.once.Do(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+		defer cancel()
+
+		var errs []error
+		for phase := PhaseIngest; phase < numPhases; phase++ {
+			if phase == PhaseClose {
+				c.cancel()
+				if !c.wait(ctx) {
+					shutdownLog.WarnContext(ctx, "Background tasks still running", "timeout", c.timeout)
+				}
+			}
+			errs = append(errs, c.run(ctx, phase)...)
+		}
+		c.cancel()
+		c.err = errors.Join(errs...)
+	})
+	return c.err
+}
+
+// run runs the steps of a phase, returning their errors
+func (c *Coordinator) run(ctx context.Context, phase Phase) []error {
+	c.mu.Lock()
+	steps := c.steps[phase]
+	c.mu.Unlock()
+
+	var errs []error
+	for _, s := range steps {
+		start := time.Now()
+		if err := s.fn(ctx); err != nil {
+			shutdownLog.ErrorContext(ctx, "Shutdown step failed", "phase", phase.String(), "step", s.name,
+				"elapsed", time.Since(start).Round(time.Millisecond), "error", err)
+			errs = append(errs, fmt.Errorf("%s: %w", s.name, err))
+		}
+	}
+	return errs
+}
+
+// wait waits for the goroutines started with Go, reporting false if ctx
+// expired first
+func (c *Coordinator) wait(ctx context.Context) bool {
+	done := make(chan struct{})
+	go func() {
+		c.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
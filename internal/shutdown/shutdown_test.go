@@ -0,0 +1,55 @@
+package shutdown
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestShutdownOrder(t *testing.T) {
+	c := New(context.Background(), time.Second)
+	var (
+		mu    sync.Mutex
+		order []string
+	)
+	record := func(name string) {
+		mu.Lock()
+		defer mu.Unlock()
+		order = append(order, name)
+	}
+	step := func(name string, err error) func(context.Context) error {
+		return func(context.Context) error {
+			record(name)
+			return err
+		}
+	}
+
+	c.Add(PhaseServers, "server", step("server", nil))
+	c.Add(PhaseClose, "writer", step("writer", nil))
+	c.Add(PhaseFlush, "producer", step("producer", errors.New("flush failed")))
+	c.Add(PhaseIngest, "websocket", step("websocket", nil))
+	c.Go("consumer", func(ctx context.Context) error {
+		<-ctx.Done()
+		time.Sleep(10 * time.Millisecond) // A final write after cancellation
+		record("consumer")
+		return ctx.Err()
+	})
+
+	err := c.Shutdown()
+	if err == nil || err.Error() != "producer: flush failed" {
+		t.Errorf("Shutdown() = %v, want the producer error", err)
+	}
+	want := []string{"websocket", "producer", "consumer", "writer", "server"}
+	if !reflect.DeepEqual(order, want) {
+		t.Errorf("order = %v, want %v", order, want)
+	}
+	if c.Context().Err() == nil {
+		t.Error("root context not cancelled")
+	}
+	if err := c.Shutdown(); err == nil {
+		t.Error("second Shutdown() should return the first result")
+	}
+}
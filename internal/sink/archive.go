@@ -0,0 +1,69 @@
+package sink
+
+import (
+	"context"
+	"time"
+
+	internalqdb "github.com/FatwaArya/pm-ingest/internal"
+	"github.com/FatwaArya/pm-ingest/internal/archive"
+	"github.com/FatwaArya/pm-ingest/internal/health"
+	"github.com/FatwaArya/pm-ingest/pkg/rtds"
+)
+
+// ArchiveSink keeps trades in the hourly archive files. Writes go to local
+// disk; uploads happen in the background, and their outcome is what the
+// sink health tracks.
+type ArchiveSink struct {
+	archiver *archive.Archiver
+	health   *health.SinkHealth
+}
+
+// NewArchiveSink creates a sink writing to the archive in dir, uploading
+// finished files to store (nil keeps them on disk). h may be nil to disable
+// health tracking.
+func NewArchiveSink(dir, prefix string, store archive.Store, interval time.Duration, h *health.SinkHealth) (*ArchiveSink, error) {
+	s := &ArchiveSink{health: h}
+	archiver, err := archive.NewArchiver(dir, prefix, store, interval, s.record)
+	if err != nil {
+		return nil, err
+	}
+	s.archiver = archiver
+	return s, nil
+}
+
+// Archiver returns the underlying archiver, e.g. to archive raw messages
+func (s *ArchiveSink) Archiver() *archive.Archiver { return s.archiver }
+
+func (s *ArchiveSink) Name() string { return NameArchive }
+
+func (s *ArchiveSink) WriteTrade(_ context.Context, trade *rtds.ActivityTradePayload) error {
+	return s.archiver.WriteJSON(archive.StreamTrades, trade)
+}
+
+// WriteProfile does nothing; profiles can be fetched again
+func (s *ArchiveSink) WriteProfile(context.Context, *internalqdb.UserProfile) error {
+	return nil
+}
+
+func (s *ArchiveSink) Flush(context.Context) error {
+	return s.archiver.Flush()
+}
+
+func (s *ArchiveSink) Close(ctx context.Context) error {
+	return s.archiver.Close(ctx)
+}
+
+func (s *ArchiveSink) Healthy() bool {
+	return s.health == nil || !s.health.Degraded()
+}
+
+// record reports the outcome of a round of uploads to the sink health
+func (s *ArchiveSink) record(err error) {
+	switch {
+	case s.health == nil:
+	case err != nil:
+		s.health.RecordFailure(err)
+	default:
+		s.health.RecordSuccess()
+	}
+}
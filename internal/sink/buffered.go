@@ -0,0 +1,139 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	internalqdb "github.com/FatwaArya/pm-ingest/internal"
+	"github.com/FatwaArya/pm-ingest/internal/logging"
+	"github.com/FatwaArya/pm-ingest/pkg/rtds"
+)
+
+// ErrBufferFull is returned for records dropped because a buffered sink's
+// queue is full
+var ErrBufferFull = errors.New("sink buffer full")
+
+// bufferedErrLog limits logging of failed background writes
+var bufferedErrLog = logging.NewRateLimited(logging.For("sink"), 5*time.Second)
+
+// Buffered queues writes to a sink and applies them in the background, so
+// a stalled sink (e.g. QuestDB holding its writer through a slow flush)
+// doesn't hold up the other sinks of a fan-out. When the queue is full,
+// records are dropped with ErrBufferFull instead of blocking.
+type Buffered struct {
+	sink    Sink
+	queue   chan func(context.Context) error
+	dropped atomic.Uint64
+
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// NewBuffered wraps s with a queue of size records and starts the writer
+func NewBuffered(s Sink, size int) *Buffered {
+	b := &Buffered{
+		sink:  s,
+		queue: make(chan func(context.Context) error, size),
+		done:  make(chan struct{}),
+	}
+	b.wg.Add(1)
+	go b.run()
+	return b
+}
+
+// Unwrap returns the wrapped sink
+func (b *Buffered) Unwrap() Sink { return b.sink }
+
+// Dropped returns the number of records dropped on a full queue
+func (b *Buffered) Dropped() uint64 { return b.dropped.Load() }
+
+// Queued returns the number of records waiting to be written
+func (b *Buffered) Queued() int { return len(b.queue) }
+
+func (b *Buffered) Name() string { return b.sink.Name() }
+
+func (b *Buffered) WriteTrade(ctx context.Context, trade *rtds.ActivityTradePayload) error {
+	return b.enqueue(func(ctx context.Context) error { return b.sink.WriteTrade(ctx, trade) })
+}
+
+func (b *Buffered) WriteProfile(ctx context.Context, profile *internalqdb.UserProfile) error {
+	return b.enqueue(func(ctx context.Context) error { return b.sink.WriteProfile(ctx, profile) })
+}
+
+// Flush waits for the records queued so far to be written, then flushes
+// the wrapped sink
+func (b *Buffered) Flush(ctx context.Context) error {
+	written := make(chan struct{})
+	select {
+	case b.queue <- func(context.Context) error { close(written); return nil }:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-written:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return b.sink.Flush(ctx)
+}
+
+// Close writes the records still queued and closes the wrapped sink
+func (b *Buffered) Close(ctx context.Context) error {
+	b.closeOnce.Do(func() { close(b.done) })
+	b.wg.Wait()
+	return b.sink.Close(ctx)
+}
+
+func (b *Buffered) Healthy() bool { return b.sink.Healthy() }
+
+func (b *Buffered) enqueue(write func(context.Context) error) error {
+	select {
+	case b.queue <- write:
+		return nil
+	default:
+		b.dropped.Add(1)
+		return ErrBufferFull
+	}
+}
+
+func (b *Buffered) run() {
+	defer b.wg.Done()
+	for {
+		select {
+		case write := <-b.queue:
+			b.apply(write)
+		case <-b.done:
+			for {
+				select {
+				case write := <-b.queue:
+					b.apply(write)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// apply runs a queued write; sinks bound their own writes, so it has no
+// deadline of its own
+func (b *Buffered) apply(write func(context.Context) error) {
+	if err := write(context.Background()); err != nil {
+		bufferedErrLog.Printf("Sink %s write error: %v", b.sink.Name(), err)
+	}
+}
+
+// Underlying returns s without its Buffered (or other Unwrap) wrappers
+func Underlying(s Sink) Sink {
+	for {
+		w, ok := s.(interface{ Unwrap() Sink })
+		if !ok {
+			return s
+		}
+		s = w.Unwrap()
+	}
+}
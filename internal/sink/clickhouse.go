@@ -0,0 +1,72 @@
+package sink
+
+import (
+	"context"
+	"time"
+
+	internalqdb "github.com/FatwaArya/pm-ingest/internal"
+	"github.com/FatwaArya/pm-ingest/internal/clickhouse"
+	"github.com/FatwaArya/pm-ingest/internal/health"
+	"github.com/FatwaArya/pm-ingest/pkg/rtds"
+)
+
+// ClickHouseSink writes trades and profiles to ClickHouse. Writes only
+// buffer rows; inserts happen in batches in the background, and their
+// outcome is what the sink health tracks.
+type ClickHouseSink struct {
+	writer *clickhouse.Writer
+	health *health.SinkHealth
+}
+
+// NewClickHouseSink creates the tables if needed and starts inserting
+// batches of batchSize rows, at least every interval. h may be nil to
+// disable health tracking.
+func NewClickHouseSink(ctx context.Context, client *clickhouse.Client, tables clickhouse.Tables, batchSize int, interval time.Duration, h *health.SinkHealth) (*ClickHouseSink, error) {
+	s := &ClickHouseSink{health: h}
+	writer, err := clickhouse.NewWriter(ctx, client, tables, batchSize, interval, s.record)
+	if err != nil {
+		return nil, err
+	}
+	s.writer = writer
+	return s, nil
+}
+
+// Writer returns the underlying writer, e.g. to write confidence scores
+func (s *ClickHouseSink) Writer() *clickhouse.Writer { return s.writer }
+
+func (s *ClickHouseSink) Name() string { return NameClickHouse }
+
+func (s *ClickHouseSink) WriteTrade(_ context.Context, trade *rtds.ActivityTradePayload) error {
+	return s.writer.WriteTrade(trade)
+}
+
+func (s *ClickHouseSink) WriteProfile(_ context.Context, profile *internalqdb.UserProfile) error {
+	return s.writer.WriteProfile(profile)
+}
+
+func (s *ClickHouseSink) Flush(ctx context.Context) error {
+	err := s.writer.Flush(ctx)
+	s.record(err)
+	return err
+}
+
+func (s *ClickHouseSink) Close(ctx context.Context) error {
+	return s.writer.Close(ctx)
+}
+
+func (s *ClickHouseSink) Healthy() bool {
+	return s.health == nil || !s.health.Degraded()
+}
+
+// record reports the outcome of an insert to the sink health
+func (s *ClickHouseSink) record(err error) {
+	switch {
+	case err != nil:
+		flushErrLog.Printf("ClickHouse insert error: %v", err)
+		if s.health != nil {
+			s.health.RecordFailure(err)
+		}
+	case s.health != nil:
+		s.health.RecordSuccess()
+	}
+}
@@ -0,0 +1,110 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	internalqdb "github.com/FatwaArya/pm-ingest/internal"
+	"github.com/FatwaArya/pm-ingest/pkg/rtds"
+)
+
+// Fanout writes every record to all of its sinks. A failing sink doesn't
+// stop the others; the errors are joined and returned.
+type Fanout struct {
+	sinks  []Sink
+	counts []counts // By sink
+}
+
+type counts struct {
+	written, failed atomic.Uint64
+}
+
+// Counts are the records a sink of a Fanout was given
+type Counts struct {
+	Sink    string
+	Written uint64
+	Failed  uint64
+}
+
+// NewFanout creates a fan-out over sinks, written in order
+func NewFanout(sinks ...Sink) *Fanout {
+	return &Fanout{sinks: sinks, counts: make([]counts, len(sinks))}
+}
+
+// Name lists the wrapped sinks
+func (f *Fanout) Name() string {
+	name := "fanout("
+	for i, s := range f.sinks {
+		if i > 0 {
+			name += ","
+		}
+		name += s.Name()
+	}
+	return name + ")"
+}
+
+// Sinks returns the wrapped sinks
+func (f *Fanout) Sinks() []Sink {
+	return f.sinks
+}
+
+// Counts returns the records written to and failed by each sink, in order
+func (f *Fanout) Counts() []Counts {
+	out := make([]Counts, len(f.sinks))
+	for i, s := range f.sinks {
+		out[i] = Counts{Sink: s.Name(), Written: f.counts[i].written.Load(), Failed: f.counts[i].failed.Load()}
+	}
+	return out
+}
+
+func (f *Fanout) WriteTrade(ctx context.Context, trade *rtds.ActivityTradePayload) error {
+	return f.write(func(s Sink) error { return s.WriteTrade(ctx, trade) })
+}
+
+func (f *Fanout) WriteProfile(ctx context.Context, profile *internalqdb.UserProfile) error {
+	return f.write(func(s Sink) error { return s.WriteProfile(ctx, profile) })
+}
+
+func (f *Fanout) Flush(ctx context.Context) error {
+	return f.each(func(s Sink) error { return s.Flush(ctx) })
+}
+
+func (f *Fanout) Close(ctx context.Context) error {
+	return f.each(func(s Sink) error { return s.Close(ctx) })
+}
+
+// Healthy reports whether all sinks are healthy
+func (f *Fanout) Healthy() bool {
+	for _, s := range f.sinks {
+		if !s.Healthy() {
+			return false
+		}
+	}
+	return true
+}
+
+// write is each, counting the records written and failed per sink
+func (f *Fanout) write(fn func(Sink) error) error {
+	var errs []error
+	for i, s := range f.sinks {
+		if err := fn(s); err != nil {
+			f.counts[i].failed.Add(1)
+			errs = append(errs, fmt.Errorf("%s: %w", s.Name(), err))
+			continue
+		}
+		f.counts[i].written.Add(1)
+	}
+	return errors.Join(errs...)
+}
+
+func (f *Fanout) each(fn func(Sink) error) error {
+	var errs []error
+	for _, s := range f.sinks {
+		if err := fn(s); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", s.Name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,71 @@
+package sink
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	internalqdb "github.com/FatwaArya/pm-ingest/internal"
+	"github.com/FatwaArya/pm-ingest/pkg/rtds"
+)
+
+// FileSink appends records to a file as JSON lines, in the format of
+// StdoutSink. Lines are buffered; Flush writes and syncs them.
+type FileSink struct {
+	mu  sync.Mutex
+	f   *os.File
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+// NewFileSink opens path for appending, creating it and its directory
+func NewFileSink(path string) (*FileSink, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create directory for %s: %w", path, err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	w := bufio.NewWriter(f)
+	return &FileSink{f: f, w: w, enc: json.NewEncoder(w)}, nil
+}
+
+func (s *FileSink) Name() string { return NameFile }
+
+func (s *FileSink) WriteTrade(_ context.Context, trade *rtds.ActivityTradePayload) error {
+	return s.write("trade", trade)
+}
+
+func (s *FileSink) WriteProfile(_ context.Context, profile *internalqdb.UserProfile) error {
+	return s.write("profile", profile)
+}
+
+func (s *FileSink) Flush(context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.w.Flush(); err != nil {
+		return err
+	}
+	return s.f.Sync()
+}
+
+func (s *FileSink) Close(ctx context.Context) error {
+	if err := s.Flush(ctx); err != nil {
+		s.f.Close()
+		return err
+	}
+	return s.f.Close()
+}
+
+func (s *FileSink) Healthy() bool { return true }
+
+func (s *FileSink) write(kind string, data any) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.enc.Encode(stdoutRecord{Kind: kind, Data: data})
+}
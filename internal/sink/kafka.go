@@ -0,0 +1,70 @@
+package sink
+
+import (
+	"context"
+
+	internalqdb "github.com/FatwaArya/pm-ingest/internal"
+	internalkafka "github.com/FatwaArya/pm-ingest/internal/kafka"
+	"github.com/FatwaArya/pm-ingest/internal/wal"
+	"github.com/FatwaArya/pm-ingest/pkg/rtds"
+)
+
+// TopicRouter returns the topics a trade is copied to in addition to the
+// main trades topic
+type TopicRouter func(trade *rtds.ActivityTradePayload) []string
+
+// KafkaSink produces trades to the trades topic. Profiles are not
+// published to Kafka and are ignored.
+type KafkaSink struct {
+	producer *internalkafka.Producer
+	wal      *wal.WAL
+	router   TopicRouter
+}
+
+// NewKafkaSink wraps producer and its fallback WAL (which may be nil); the
+// sink takes ownership and closes both
+func NewKafkaSink(producer *internalkafka.Producer, w *wal.WAL) *KafkaSink {
+	return &KafkaSink{producer: producer, wal: w}
+}
+
+// SetRouter copies trades to the extra topics chosen by router
+func (s *KafkaSink) SetRouter(router TopicRouter) {
+	s.router = router
+}
+
+func (s *KafkaSink) Name() string { return NameKafka }
+
+func (s *KafkaSink) WriteTrade(ctx context.Context, trade *rtds.ActivityTradePayload) error {
+	if err := s.producer.ProduceTrade(ctx, trade); err != nil {
+		return err
+	}
+	if s.router == nil {
+		return nil
+	}
+	for _, topic := range s.router(trade) {
+		if err := s.producer.ProduceTradeTo(ctx, topic, trade); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *KafkaSink) WriteProfile(context.Context, *internalqdb.UserProfile) error {
+	return nil
+}
+
+func (s *KafkaSink) Flush(ctx context.Context) error {
+	return s.producer.Flush(ctx)
+}
+
+func (s *KafkaSink) Close(context.Context) error {
+	s.producer.Close()
+	if s.wal != nil {
+		return s.wal.Close()
+	}
+	return nil
+}
+
+func (s *KafkaSink) Healthy() bool {
+	return s.producer.Healthy()
+}
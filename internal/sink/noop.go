@@ -0,0 +1,19 @@
+package sink
+
+import (
+	"context"
+
+	internalqdb "github.com/FatwaArya/pm-ingest/internal"
+	"github.com/FatwaArya/pm-ingest/pkg/rtds"
+)
+
+// NoopSink discards every record, e.g. to run the pipeline and its
+// analytics without storing trades
+type NoopSink struct{}
+
+func (NoopSink) Name() string                                                 { return NameNone }
+func (NoopSink) WriteTrade(context.Context, *rtds.ActivityTradePayload) error { return nil }
+func (NoopSink) WriteProfile(context.Context, *internalqdb.UserProfile) error { return nil }
+func (NoopSink) Flush(context.Context) error                                  { return nil }
+func (NoopSink) Close(context.Context) error                                  { return nil }
+func (NoopSink) Healthy() bool                                                { return true }
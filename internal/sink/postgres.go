@@ -0,0 +1,83 @@
+package sink
+
+import (
+	"context"
+	"time"
+
+	internalqdb "github.com/FatwaArya/pm-ingest/internal"
+	"github.com/FatwaArya/pm-ingest/internal/health"
+	"github.com/FatwaArya/pm-ingest/internal/postgres"
+	"github.com/FatwaArya/pm-ingest/pkg/rtds"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PostgresSink writes trades and profiles to PostgreSQL, or TimescaleDB.
+// Writes only buffer rows; batches are copied in the background, and their
+// outcome is what the sink health tracks.
+type PostgresSink struct {
+	pool   *pgxpool.Pool
+	writer *postgres.Writer
+	health *health.SinkHealth
+}
+
+// NewPostgresSink connects to url, creates the tables if needed (as
+// hypertables with timescale) and starts copying batches of batchSize
+// rows, at least every interval. h may be nil to disable health tracking.
+func NewPostgresSink(ctx context.Context, url string, tables postgres.Tables, timescale bool, batchSize int, interval time.Duration, h *health.SinkHealth) (*PostgresSink, error) {
+	pool, err := pgxpool.New(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	s := &PostgresSink{pool: pool, health: h}
+	writer, err := postgres.NewWriter(ctx, pool, tables, timescale, batchSize, interval, s.record)
+	if err != nil {
+		pool.Close()
+		return nil, err
+	}
+	s.writer = writer
+	return s, nil
+}
+
+// Writer returns the underlying writer
+func (s *PostgresSink) Writer() *postgres.Writer { return s.writer }
+
+func (s *PostgresSink) Name() string { return NamePostgres }
+
+func (s *PostgresSink) WriteTrade(_ context.Context, trade *rtds.ActivityTradePayload) error {
+	s.writer.WriteTrade(trade)
+	return nil
+}
+
+func (s *PostgresSink) WriteProfile(_ context.Context, profile *internalqdb.UserProfile) error {
+	s.writer.WriteProfile(profile)
+	return nil
+}
+
+func (s *PostgresSink) Flush(ctx context.Context) error {
+	err := s.writer.Flush(ctx)
+	s.record(err)
+	return err
+}
+
+func (s *PostgresSink) Close(ctx context.Context) error {
+	err := s.writer.Close(ctx)
+	s.pool.Close()
+	return err
+}
+
+func (s *PostgresSink) Healthy() bool {
+	return s.health == nil || !s.health.Degraded()
+}
+
+// record reports the outcome of a copy to the sink health
+func (s *PostgresSink) record(err error) {
+	switch {
+	case err != nil:
+		flushErrLog.Printf("Postgres copy error: %v", err)
+		if s.health != nil {
+			s.health.RecordFailure(err)
+		}
+	case s.health != nil:
+		s.health.RecordSuccess()
+	}
+}
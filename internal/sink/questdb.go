@@ -0,0 +1,176 @@
+package sink
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	internalqdb "github.com/FatwaArya/pm-ingest/internal"
+	"github.com/FatwaArya/pm-ingest/internal/health"
+	"github.com/FatwaArya/pm-ingest/internal/logging"
+	"github.com/FatwaArya/pm-ingest/internal/tracing"
+	"github.com/FatwaArya/pm-ingest/pkg/clock"
+	"github.com/FatwaArya/pm-ingest/pkg/rtds"
+)
+
+// questdbFlushInterval is how often buffered ILP rows are sent; the TCP
+// sender has no auto-flush
+const questdbFlushInterval = time.Second
+
+// flushErrLog limits background flush error logging while QuestDB is down
+var flushErrLog = logging.NewRateLimited(logging.For("sink"), 5*time.Second)
+
+// QuestDBSink writes trades and profiles to QuestDB over ILP. While QuestDB
+// is degraded writes are dropped, with one probe per retry interval.
+type QuestDBSink struct {
+	trades   *internalqdb.TradeWriter
+	profiles *internalqdb.ProfileWriter
+	health   *health.SinkHealth
+	clock    clock.Clock
+	fault    atomic.Pointer[func() error]
+	latency  atomic.Pointer[LatencyObserver]
+
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// QuestDBTables overrides the trade and profile table settings; zero
+// values keep the writer defaults
+type QuestDBTables struct {
+	Trades   internalqdb.TableConfig
+	Profiles internalqdb.TableConfig
+}
+
+// NewQuestDBSink connects trade and profile writers to host:port and starts
+// the background flush, timed by c (nil for the wall clock). h may be nil to
+// disable health tracking.
+func NewQuestDBSink(ctx context.Context, host string, port int, tables QuestDBTables, h *health.SinkHealth, c clock.Clock) (*QuestDBSink, error) {
+	trades, err := internalqdb.NewTradeWriter(ctx, host, port, internalqdb.WithTable(tables.Trades))
+	if err != nil {
+		return nil, err
+	}
+	profiles, err := internalqdb.NewProfileWriter(ctx, host, port, internalqdb.WithTable(tables.Profiles))
+	if err != nil {
+		trades.Close(ctx)
+		return nil, err
+	}
+
+	s := &QuestDBSink{
+		trades:   trades,
+		profiles: profiles,
+		health:   h,
+		clock:    clock.OrReal(c),
+		done:     make(chan struct{}),
+	}
+	s.wg.Add(1)
+	go s.flushLoop()
+	return s, nil
+}
+
+// LatencyObserver receives the duration of QuestDB operations
+type LatencyObserver struct {
+	Write func(time.Duration)
+	Flush func(time.Duration)
+}
+
+// SetLatencyObserver reports write and flush latencies to o, e.g. to
+// metrics histograms
+func (s *QuestDBSink) SetLatencyObserver(o LatencyObserver) {
+	s.latency.Store(&o)
+}
+
+func (s *QuestDBSink) Name() string { return NameQuestDB }
+
+func (s *QuestDBSink) WriteTrade(ctx context.Context, trade *rtds.ActivityTradePayload) error {
+	if s.health != nil && !s.health.Allow() {
+		return nil
+	}
+	ctx, span := tracing.Start(ctx, "questdb.write trade")
+	start := time.Now()
+	err := s.trades.Write(ctx, trade)
+	if o := s.latency.Load(); o != nil && o.Write != nil {
+		o.Write(time.Since(start))
+	}
+	tracing.End(span, err)
+	return s.record(err)
+}
+
+func (s *QuestDBSink) WriteProfile(ctx context.Context, profile *internalqdb.UserProfile) error {
+	if s.health != nil && !s.health.Allow() {
+		return nil
+	}
+	ctx, span := tracing.Start(ctx, "questdb.write profile")
+	err := s.profiles.Write(ctx, profile)
+	tracing.End(span, err)
+	return s.record(err)
+}
+
+func (s *QuestDBSink) Flush(ctx context.Context) error {
+	if fault := s.fault.Load(); fault != nil {
+		if err := (*fault)(); err != nil {
+			return s.record(err)
+		}
+	}
+	start := time.Now()
+	err := s.trades.Flush(ctx)
+	if err == nil {
+		err = s.profiles.Flush(ctx)
+	}
+	if o := s.latency.Load(); o != nil && o.Flush != nil {
+		o.Flush(time.Since(start))
+	}
+	return s.record(err)
+}
+
+// SetFaultInjector makes Flush fail whenever fault returns an error, for
+// chaos testing
+func (s *QuestDBSink) SetFaultInjector(fault func() error) {
+	s.fault.Store(&fault)
+}
+
+// Close stops the background flush and closes both writers, flushing first
+func (s *QuestDBSink) Close(ctx context.Context) error {
+	s.closeOnce.Do(func() { close(s.done) })
+	s.wg.Wait()
+	if err := s.trades.Close(ctx); err != nil {
+		s.profiles.Close(ctx)
+		return err
+	}
+	return s.profiles.Close(ctx)
+}
+
+func (s *QuestDBSink) Healthy() bool {
+	return s.health == nil || !s.health.Degraded()
+}
+
+// record reports the outcome of a write to the sink health
+func (s *QuestDBSink) record(err error) error {
+	if s.health == nil {
+		return err
+	}
+	if err != nil {
+		s.health.RecordFailure(err)
+	} else {
+		s.health.RecordSuccess()
+	}
+	return err
+}
+
+func (s *QuestDBSink) flushLoop() {
+	defer s.wg.Done()
+	ticker := s.clock.NewTicker(questdbFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C():
+			if err := s.Flush(context.Background()); err != nil {
+				flushErrLog.Printf("QuestDB flush error: %v", err)
+			}
+		case <-s.done:
+			return
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+
+	internalqdb "github.com/FatwaArya/pm-ingest/internal"
+	"github.com/FatwaArya/pm-ingest/internal/health"
+	internalkafka "github.com/FatwaArya/pm-ingest/internal/kafka"
+	"github.com/FatwaArya/pm-ingest/pkg/rtds"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// QuestDBRelay derives the QuestDB trades table from the Kafka trades
+// topic instead of writing it alongside Kafka, so a crash between the two
+// writes can't leave them inconsistent. Offsets are committed only after a
+// batch is flushed, and the trades table deduplicates replayed rows, so
+// QuestDB converges on the topic's contents.
+type QuestDBRelay struct {
+	consumer *internalkafka.Consumer
+	trades   *internalqdb.TradeWriter
+	health   *health.SinkHealth
+}
+
+// NewQuestDBRelay consumes topic in groupID and writes trades over ILP/HTTP
+// to host:port. h may be nil to disable health tracking.
+func NewQuestDBRelay(ctx context.Context, brokers, topic, groupID, host string, port int, table internalqdb.TableConfig, h *health.SinkHealth) (*QuestDBRelay, error) {
+	trades, err := internalqdb.NewTradeWriterHTTP(ctx, host, port, internalqdb.WithTable(table))
+	if err != nil {
+		return nil, err
+	}
+	consumer, err := internalkafka.NewConsumer(brokers, topic, groupID, internalkafka.WithManualCommit())
+	if err != nil {
+		trades.Close(ctx)
+		return nil, fmt.Errorf("failed to create kafka consumer: %w", err)
+	}
+	return &QuestDBRelay{consumer: consumer, trades: trades, health: h}, nil
+}
+
+// Run relays trades until ctx is cancelled. While QuestDB is unavailable
+// the relay stops consuming and Kafka retains the backlog.
+func (r *QuestDBRelay) Run(ctx context.Context) error {
+	return r.consumer.RunBatches(ctx, r.writeBatch)
+}
+
+// writeBatch writes and flushes a batch of trade records
+func (r *QuestDBRelay) writeBatch(ctx context.Context, records []*kgo.Record) error {
+	trades := make([]*rtds.ActivityTradePayload, 0, len(records))
+	for _, record := range records {
+		msg, err := internalkafka.DecodeTrade(record.Value)
+		if err != nil {
+			flushErrLog.Printf("Skipping undecodable trade at %s/%d@%d: %v", record.Topic, record.Partition, record.Offset, err)
+			continue
+		}
+		trades = append(trades, msg.Payload())
+	}
+	err := r.trades.WriteBatch(ctx, trades)
+	if r.health != nil {
+		if err != nil {
+			r.health.RecordFailure(err)
+		} else {
+			r.health.RecordSuccess()
+		}
+	}
+	return err
+}
+
+// Healthy reports false while QuestDB is degraded
+func (r *QuestDBRelay) Healthy() bool {
+	return r.health == nil || !r.health.Degraded()
+}
+
+// Close stops consuming and closes the QuestDB writer
+func (r *QuestDBRelay) Close(ctx context.Context) error {
+	r.consumer.Close()
+	return r.trades.Close(ctx)
+}
+
+// Lag returns how many trades the relay is behind the topic
+func (r *QuestDBRelay) Lag() int64 {
+	return r.consumer.Lag()
+}
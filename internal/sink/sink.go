@@ -0,0 +1,35 @@
+// Package sink defines the outputs trades and profiles are written to and
+// the fan-out that writes each trade to every configured output.
+package sink
+
+import (
+	"context"
+
+	internalqdb "github.com/FatwaArya/pm-ingest/internal"
+	"github.com/FatwaArya/pm-ingest/pkg/rtds"
+)
+
+// Sink names accepted in the SINKS setting
+const (
+	NameKafka      = "kafka"
+	NameQuestDB    = "questdb"
+	NameStdout     = "stdout"
+	NameFile       = "file"
+	NameNone       = "none"
+	NameClickHouse = "clickhouse"
+	NamePostgres   = "postgres"
+	NameArchive    = "archive"
+)
+
+// Sink is an output for ingested data. Sinks that don't store a kind of
+// record (e.g. Kafka and profiles) accept and ignore it.
+type Sink interface {
+	Name() string
+	WriteTrade(ctx context.Context, trade *rtds.ActivityTradePayload) error
+	WriteProfile(ctx context.Context, profile *internalqdb.UserProfile) error
+	// Flush blocks until buffered records are delivered or ctx is done
+	Flush(ctx context.Context) error
+	Close(ctx context.Context) error
+	// Healthy reports false while the sink is degraded
+	Healthy() bool
+}
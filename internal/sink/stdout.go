@@ -0,0 +1,48 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+
+	internalqdb "github.com/FatwaArya/pm-ingest/internal"
+	"github.com/FatwaArya/pm-ingest/pkg/rtds"
+)
+
+// StdoutSink writes records as JSON lines, e.g. to os.Stdout for dry runs.
+// Each line is {"kind":"trade"|"profile","data":...}.
+type StdoutSink struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewStdoutSink writes JSON lines to w
+func NewStdoutSink(w io.Writer) *StdoutSink {
+	return &StdoutSink{enc: json.NewEncoder(w)}
+}
+
+type stdoutRecord struct {
+	Kind string `json:"kind"`
+	Data any    `json:"data"`
+}
+
+func (s *StdoutSink) Name() string { return NameStdout }
+
+func (s *StdoutSink) WriteTrade(_ context.Context, trade *rtds.ActivityTradePayload) error {
+	return s.write("trade", trade)
+}
+
+func (s *StdoutSink) WriteProfile(_ context.Context, profile *internalqdb.UserProfile) error {
+	return s.write("profile", profile)
+}
+
+func (s *StdoutSink) Flush(context.Context) error { return nil }
+func (s *StdoutSink) Close(context.Context) error { return nil }
+func (s *StdoutSink) Healthy() bool               { return true }
+
+func (s *StdoutSink) write(kind string, data any) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.enc.Encode(stdoutRecord{Kind: kind, Data: data})
+}
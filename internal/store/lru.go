@@ -0,0 +1,141 @@
+package store
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/FatwaArya/pm-ingest/pkg/clock"
+)
+
+type lruItem struct {
+	key   string
+	entry memoryEntry
+}
+
+// LRUStore is an in-process Store holding at most a fixed number of keys:
+// past that, setting a key evicts the least recently used one. It bounds
+// memory where a MemoryStore would grow with every key ever set, at the
+// cost of forgetting old keys.
+type LRUStore struct {
+	mu      sync.Mutex
+	clock   clock.Clock
+	max     int
+	order   *list.List // Front is the most recently used
+	keys    map[string]*list.Element
+	evicted atomic.Uint64
+}
+
+// NewLRUStore creates a store holding up to maxKeys keys (at least 1)
+func NewLRUStore(maxKeys int) *LRUStore {
+	return &LRUStore{
+		clock: clock.Real,
+		max:   max(maxKeys, 1),
+		order: list.New(),
+		keys:  make(map[string]*list.Element),
+	}
+}
+
+// SetClock replaces the clock that expiry is measured against
+func (l *LRUStore) SetClock(c clock.Clock) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.clock = clock.OrReal(c)
+}
+
+// lookup returns key's live element, marking it used; called with mu held
+func (l *LRUStore) lookup(key string, now time.Time) (*list.Element, bool) {
+	el, ok := l.keys[key]
+	if !ok {
+		return nil, false
+	}
+	if !el.Value.(*lruItem).entry.live(now) {
+		l.order.Remove(el)
+		delete(l.keys, key)
+		return nil, false
+	}
+	l.order.MoveToFront(el)
+	return el, true
+}
+
+// put sets key, evicting the least recently used key when full; called
+// with mu held
+func (l *LRUStore) put(key string, entry memoryEntry) {
+	if el, ok := l.keys[key]; ok {
+		el.Value.(*lruItem).entry = entry
+		l.order.MoveToFront(el)
+		return
+	}
+	l.keys[key] = l.order.PushFront(&lruItem{key: key, entry: entry})
+	if l.order.Len() > l.max {
+		oldest := l.order.Back()
+		l.order.Remove(oldest)
+		delete(l.keys, oldest.Value.(*lruItem).key)
+		l.evicted.Add(1)
+	}
+}
+
+// SetIfAbsent sets key if it is missing or expired
+func (l *LRUStore) SetIfAbsent(_ context.Context, key string, ttl time.Duration) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.clock.Now()
+	if _, ok := l.lookup(key, now); ok {
+		return false, nil
+	}
+	l.put(key, memoryEntry{expiry: expiryFor(now, ttl)})
+	return true, nil
+}
+
+// Get returns a copy of the value stored under key
+func (l *LRUStore) Get(_ context.Context, key string) ([]byte, bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	el, ok := l.lookup(key, l.clock.Now())
+	if !ok {
+		return nil, false, nil
+	}
+	return append([]byte(nil), el.Value.(*lruItem).entry.value...), true, nil
+}
+
+// Set stores a copy of value under key
+func (l *LRUStore) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.put(key, memoryEntry{
+		value:  append([]byte(nil), value...),
+		expiry: expiryFor(l.clock.Now(), ttl),
+	})
+	return nil
+}
+
+// Delete removes key
+func (l *LRUStore) Delete(_ context.Context, key string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if el, ok := l.keys[key]; ok {
+		l.order.Remove(el)
+		delete(l.keys, key)
+	}
+	return nil
+}
+
+// Close is a no-op for the in-memory store
+func (l *LRUStore) Close() error {
+	return nil
+}
+
+// Len returns the number of keys held, including expired ones not yet
+// looked up
+func (l *LRUStore) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.order.Len()
+}
+
+// Evicted returns the number of keys evicted to stay within the bound
+func (l *LRUStore) Evicted() uint64 {
+	return l.evicted.Load()
+}
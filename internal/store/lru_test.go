@@ -0,0 +1,49 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/FatwaArya/pm-ingest/pkg/clock"
+)
+
+func TestLRUStoreEviction(t *testing.T) {
+	ctx := context.Background()
+	l := NewLRUStore(2)
+
+	l.Set(ctx, "a", []byte("1"), 0)
+	l.Set(ctx, "b", []byte("2"), 0)
+	l.Get(ctx, "a") // b is now the least recently used
+	l.Set(ctx, "c", []byte("3"), 0)
+
+	if _, ok, _ := l.Get(ctx, "b"); ok {
+		t.Error("b should have been evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok, _ := l.Get(ctx, key); !ok {
+			t.Errorf("%s should be kept", key)
+		}
+	}
+	if l.Len() != 2 || l.Evicted() != 1 {
+		t.Errorf("Len = %d, Evicted = %d; want 2 and 1", l.Len(), l.Evicted())
+	}
+}
+
+func TestLRUStoreExpiry(t *testing.T) {
+	ctx := context.Background()
+	fake := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	l := NewLRUStore(10)
+	l.SetClock(fake)
+
+	if ok, _ := l.SetIfAbsent(ctx, "k", time.Minute); !ok {
+		t.Fatal("first SetIfAbsent should succeed")
+	}
+	if ok, _ := l.SetIfAbsent(ctx, "k", time.Minute); ok {
+		t.Fatal("key should still be live")
+	}
+	fake.Advance(time.Minute)
+	if ok, _ := l.SetIfAbsent(ctx, "k", time.Minute); !ok {
+		t.Fatal("key should have expired")
+	}
+}
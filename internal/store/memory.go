@@ -0,0 +1,121 @@
+package store
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/FatwaArya/pm-ingest/pkg/clock"
+)
+
+// sweepInterval is how often expired keys are removed from memory
+const sweepInterval = time.Minute
+
+type memoryEntry struct {
+	value  []byte
+	expiry time.Time // Zero = never
+}
+
+func (e memoryEntry) live(now time.Time) bool {
+	return e.expiry.IsZero() || now.Before(e.expiry)
+}
+
+// MemoryStore is an in-process Store with per-key expiry
+type MemoryStore struct {
+	mu        sync.Mutex
+	clock     clock.Clock
+	keys      map[string]memoryEntry
+	lastSweep time.Time
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		clock:     clock.Real,
+		keys:      make(map[string]memoryEntry),
+		lastSweep: time.Now(),
+	}
+}
+
+// SetClock replaces the clock that expiry is measured against
+func (m *MemoryStore) SetClock(c clock.Clock) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.clock = clock.OrReal(c)
+	m.lastSweep = m.clock.Now()
+}
+
+// SetIfAbsent sets key if it is missing or expired
+func (m *MemoryStore) SetIfAbsent(_ context.Context, key string, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.clock.Now()
+	m.sweep(now)
+
+	if entry, ok := m.keys[key]; ok && entry.live(now) {
+		return false, nil
+	}
+
+	m.keys[key] = memoryEntry{expiry: expiryFor(now, ttl)}
+	return true, nil
+}
+
+// Get returns a copy of the value stored under key
+func (m *MemoryStore) Get(_ context.Context, key string) ([]byte, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.keys[key]
+	if !ok || !entry.live(m.clock.Now()) {
+		return nil, false, nil
+	}
+	return append([]byte(nil), entry.value...), true, nil
+}
+
+// Set stores a copy of value under key
+func (m *MemoryStore) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.clock.Now()
+	m.sweep(now)
+	m.keys[key] = memoryEntry{
+		value:  append([]byte(nil), value...),
+		expiry: expiryFor(now, ttl),
+	}
+	return nil
+}
+
+// Delete removes key
+func (m *MemoryStore) Delete(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.keys, key)
+	return nil
+}
+
+// Close is a no-op for the in-memory store
+func (m *MemoryStore) Close() error {
+	return nil
+}
+
+// sweep drops expired keys; called with mu held
+func (m *MemoryStore) sweep(now time.Time) {
+	if now.Sub(m.lastSweep) < sweepInterval {
+		return
+	}
+	m.lastSweep = now
+	for key, entry := range m.keys {
+		if !entry.live(now) {
+			delete(m.keys, key)
+		}
+	}
+}
+
+func expiryFor(now time.Time, ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return now.Add(ttl)
+}
@@ -0,0 +1,36 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/FatwaArya/pm-ingest/pkg/clock"
+)
+
+func TestMemoryStoreExpiry(t *testing.T) {
+	ctx := context.Background()
+	fake := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	m := NewMemoryStore()
+	m.SetClock(fake)
+
+	if ok, _ := m.SetIfAbsent(ctx, "k", time.Minute); !ok {
+		t.Fatal("first SetIfAbsent should succeed")
+	}
+	fake.Advance(59 * time.Second)
+	if ok, _ := m.SetIfAbsent(ctx, "k", time.Minute); ok {
+		t.Fatal("key should still be live")
+	}
+	fake.Advance(time.Second)
+	if ok, _ := m.SetIfAbsent(ctx, "k", time.Minute); !ok {
+		t.Fatal("key should have expired")
+	}
+
+	if err := m.Set(ctx, "v", []byte("x"), 0); err != nil {
+		t.Fatal(err)
+	}
+	fake.Advance(24 * time.Hour)
+	if _, ok, _ := m.Get(ctx, "v"); !ok {
+		t.Fatal("key without ttl should never expire")
+	}
+}
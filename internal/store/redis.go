@@ -0,0 +1,79 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisStore is a Store shared across replicas via Redis
+type RedisStore struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisStore connects to Redis at url (redis://[user:pass@]host:port/db).
+// All keys are namespaced under prefix so several deployments can share a server.
+func NewRedisStore(ctx context.Context, url string, prefix string) (*RedisStore, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse redis url: %w", err)
+	}
+
+	client := redis.NewClient(opts)
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to redis: %w", err)
+	}
+
+	return &RedisStore{
+		client: client,
+		prefix: prefix,
+	}, nil
+}
+
+// SetIfAbsent uses SET NX so concurrent replicas agree on who set the key first
+func (r *RedisStore) SetIfAbsent(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	res, err := r.client.SetArgs(ctx, r.prefix+key, 1, redis.SetArgs{Mode: "NX", TTL: ttl}).Result()
+	if err == redis.Nil {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return res == "OK", nil
+}
+
+// Get returns the value stored under key
+func (r *RedisStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, err := r.client.Get(ctx, r.prefix+key).Bytes()
+	if err == redis.Nil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+// Set stores value under key
+func (r *RedisStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return r.client.Set(ctx, r.prefix+key, value, ttl).Err()
+}
+
+// Delete removes key
+func (r *RedisStore) Delete(ctx context.Context, key string) error {
+	return r.client.Del(ctx, r.prefix+key).Err()
+}
+
+// Ping checks that Redis is reachable
+func (r *RedisStore) Ping(ctx context.Context) error {
+	return r.client.Ping(ctx).Err()
+}
+
+// Close closes the Redis connection pool
+func (r *RedisStore) Close() error {
+	return r.client.Close()
+}
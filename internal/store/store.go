@@ -0,0 +1,43 @@
+package store
+
+import (
+	"context"
+	"time"
+)
+
+// Store is shared key state used for dedupe and rate limiting.
+// The in-memory implementation is per process; the Redis implementation
+// lets multiple replicas share the same state.
+type Store interface {
+	// SetIfAbsent atomically sets key if it does not exist yet and reports
+	// whether it was set. A ttl of 0 means the key never expires.
+	SetIfAbsent(ctx context.Context, key string, ttl time.Duration) (bool, error)
+	// Get returns the value stored under key and whether it exists
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Set stores value under key, overwriting any existing value.
+	// A ttl of 0 means the key never expires.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Delete removes key
+	Delete(ctx context.Context, key string) error
+	// Close releases any resources held by the store
+	Close() error
+}
+
+// Key prefixes for the different kinds of state
+const (
+	PrefixTrade      = "trade:"
+	PrefixSeen       = "seen:"
+	PrefixConfidence = "confidence:"
+	PrefixResult     = "confidence-result:"
+	PrefixMarket     = "market:"
+	PrefixOwner      = "owner:"
+	PrefixWallets    = "owner-wallets:"
+	PrefixProfile    = "profile:"    // Cached public profiles
+	PrefixFirstSeen  = "first-seen:" // When a wallet was first seen trading
+	PrefixExposure   = "exposure:"   // Wallets' open positions
+	// PrefixProfileClaim marks a profile being written (or queued for retry)
+	// so replicas don't write the same wallet concurrently
+	PrefixProfileClaim = "profile-claim:"
+	// PrefixConfidenceCache holds the latest confidence of each wallet
+	PrefixConfidenceCache = "confidence-cache:"
+)
@@ -0,0 +1,206 @@
+// Package stream fans processed trades and domain events out to clients
+// connected over WebSocket or server-sent events.
+package stream
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/FatwaArya/pm-ingest/internal/logging"
+	"github.com/FatwaArya/pm-ingest/pkg/rtds"
+)
+
+// Event types
+const (
+	TypeTrade      = "trade"
+	TypeProfile    = "profile"
+	TypeConfidence = "confidence"
+)
+
+var (
+	// ErrTooManyClients is returned by Subscribe when the hub is full
+	ErrTooManyClients = errors.New("too many stream clients")
+	// ErrClosed is returned by Subscribe once the hub is closed
+	ErrClosed = errors.New("stream closed")
+)
+
+var encodeErrLog = logging.NewRateLimited(logging.For("stream"), 5*time.Second)
+
+// Event describes a published value for filtering: its type, the wallet it
+// concerns, the keys of its market (condition ID, slug, ...) and its
+// notional. Events without a wallet or market pass those filters.
+type Event struct {
+	Type     string
+	Wallet   string
+	Markets  []string
+	Notional float64
+}
+
+// message is an event as sent to clients
+type message struct {
+	Type string `json:"type"`
+	Data any    `json:"data"`
+}
+
+// Filter selects the events a client receives; zero values match everything
+type Filter struct {
+	Types       map[string]bool
+	Markets     map[string]bool
+	Wallets     map[string]bool // Lowercase
+	MinNotional float64         // Of trades
+}
+
+// Match reports whether e passes the filter
+func (f Filter) Match(e Event) bool {
+	if len(f.Types) > 0 && !f.Types[e.Type] {
+		return false
+	}
+	if len(f.Wallets) > 0 && e.Wallet != "" && !f.Wallets[e.Wallet] {
+		return false
+	}
+	if len(f.Markets) > 0 && len(e.Markets) > 0 {
+		matched := false
+		for _, m := range e.Markets {
+			if f.Markets[m] {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return e.Type != TypeTrade || e.Notional >= f.MinNotional
+}
+
+// Subscription is one connected client. C delivers encoded messages and is
+// closed when the client unsubscribes.
+type Subscription struct {
+	C       <-chan []byte
+	ch      chan []byte
+	filter  Filter
+	dropped atomic.Uint64
+}
+
+// Dropped returns the number of messages dropped because the client fell behind
+func (s *Subscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Hub broadcasts events to its subscriptions. Publishing never blocks: a
+// client whose buffer is full misses the message.
+type Hub struct {
+	maxClients int
+	buffer     int
+	dropped    atomic.Uint64
+
+	mu     sync.RWMutex
+	subs   map[*Subscription]struct{}
+	closed bool
+}
+
+// NewHub creates a hub for up to maxClients clients, each buffering up to
+// buffer messages
+func NewHub(maxClients, buffer int) *Hub {
+	return &Hub{
+		maxClients: maxClients,
+		buffer:     buffer,
+		subs:       make(map[*Subscription]struct{}),
+	}
+}
+
+// Subscribe registers a client receiving the events matching filter
+func (h *Hub) Subscribe(filter Filter) (*Subscription, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return nil, ErrClosed
+	}
+	if len(h.subs) >= h.maxClients {
+		return nil, ErrTooManyClients
+	}
+	ch := make(chan []byte, h.buffer)
+	sub := &Subscription{C: ch, ch: ch, filter: filter}
+	h.subs[sub] = struct{}{}
+	return sub, nil
+}
+
+// Unsubscribe removes a client and closes its channel
+func (h *Hub) Unsubscribe(sub *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subs[sub]; ok {
+		delete(h.subs, sub)
+		close(sub.ch)
+	}
+}
+
+// Close disconnects every client and refuses new ones, so open streams
+// don't hold up the HTTP server's shutdown
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for sub := range h.subs {
+		delete(h.subs, sub)
+		close(sub.ch)
+	}
+}
+
+// Clients returns the number of connected clients
+func (h *Hub) Clients() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.subs)
+}
+
+// Dropped returns the number of messages dropped across every client
+func (h *Hub) Dropped() uint64 {
+	return h.dropped.Load()
+}
+
+// Publish sends v to the clients whose filter matches e. v is only
+// encoded if some client wants it.
+func (h *Hub) Publish(e Event, v any) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	var data []byte
+	for sub := range h.subs {
+		if !sub.filter.Match(e) {
+			continue
+		}
+		if data == nil {
+			var err error
+			if data, err = json.Marshal(message{Type: e.Type, Data: v}); err != nil {
+				encodeErrLog.Printf("Error encoding %s event: %v", e.Type, err)
+				return
+			}
+		}
+		select {
+		case sub.ch <- data:
+		default:
+			sub.dropped.Add(1)
+			h.dropped.Add(1)
+		}
+	}
+}
+
+// PublishTrade sends a trade, filtered by its wallet, market and notional
+func (h *Hub) PublishTrade(trade *rtds.ActivityTradePayload) {
+	var markets []string
+	for _, key := range []string{trade.ConditionID, trade.MarketSlug, trade.EventSlug} {
+		if key != "" {
+			markets = append(markets, key)
+		}
+	}
+	h.Publish(Event{
+		Type:     TypeTrade,
+		Wallet:   strings.ToLower(trade.ProxyWalletAddress),
+		Markets:  markets,
+		Notional: trade.Price * trade.Size,
+	}, trade)
+}
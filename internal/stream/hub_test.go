@@ -0,0 +1,61 @@
+package stream
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/FatwaArya/pm-ingest/pkg/rtds"
+)
+
+func TestHubFiltersTrades(t *testing.T) {
+	hub := NewHub(10, 10)
+	all, _ := hub.Subscribe(Filter{})
+	filtered, _ := hub.Subscribe(Filter{
+		Markets:     map[string]bool{"will-it-rain": true},
+		Wallets:     map[string]bool{"0xabc": true},
+		MinNotional: 100,
+	})
+
+	hub.PublishTrade(&rtds.ActivityTradePayload{MarketSlug: "will-it-rain", ProxyWalletAddress: "0xABC", Price: 0.5, Size: 400})
+	hub.PublishTrade(&rtds.ActivityTradePayload{MarketSlug: "will-it-rain", ProxyWalletAddress: "0xabc", Price: 0.5, Size: 10})
+	hub.PublishTrade(&rtds.ActivityTradePayload{MarketSlug: "other", ProxyWalletAddress: "0xabc", Price: 0.5, Size: 400})
+	hub.Publish(Event{Type: TypeProfile, Wallet: "0xabc"}, map[string]string{"address": "0xabc"})
+
+	if got := len(all.C); got != 4 {
+		t.Errorf("unfiltered client got %d messages, want 4", got)
+	}
+	if got := len(filtered.C); got != 2 {
+		t.Fatalf("filtered client got %d messages, want 2", got)
+	}
+	var msg struct {
+		Type string          `json:"type"`
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(<-filtered.C, &msg); err != nil || msg.Type != TypeTrade {
+		t.Errorf("first message = %+v (%v), want a trade", msg, err)
+	}
+}
+
+func TestHubDropsForSlowClients(t *testing.T) {
+	hub := NewHub(1, 1)
+	sub, _ := hub.Subscribe(Filter{})
+	if _, err := hub.Subscribe(Filter{}); err != ErrTooManyClients {
+		t.Errorf("second subscribe err = %v, want ErrTooManyClients", err)
+	}
+
+	for range 3 {
+		hub.Publish(Event{Type: TypeConfidence}, 1)
+	}
+	if sub.Dropped() != 2 || hub.Dropped() != 2 {
+		t.Errorf("dropped %d (hub %d), want 2", sub.Dropped(), hub.Dropped())
+	}
+
+	hub.Close()
+	<-sub.C
+	if _, ok := <-sub.C; ok {
+		t.Error("channel open after Close")
+	}
+	if _, err := hub.Subscribe(Filter{}); err != ErrClosed {
+		t.Errorf("subscribe after Close err = %v, want ErrClosed", err)
+	}
+}
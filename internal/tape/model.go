@@ -0,0 +1,243 @@
+package tape
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/FatwaArya/pm-ingest/internal/health"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const (
+	// maxTape is how many recent trades are kept for the tape
+	maxTape = 200
+	// volumeWindow is the window of the per-market volume bars
+	volumeWindow = 5 * time.Minute
+	// volumeBars is how many markets get a volume bar
+	volumeBars = 10
+)
+
+var (
+	titleStyle = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("12"))
+	whaleStyle = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("11"))
+	buyStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("10"))
+	sellStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("9"))
+	dimStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("8"))
+	errStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("9"))
+	panelStyle = lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).Padding(0, 1)
+)
+
+// marketTrade is a trade's contribution to its market's volume bar
+type marketTrade struct {
+	at       time.Time
+	notional float64
+}
+
+type model struct {
+	cfg    Config
+	width  int
+	height int
+
+	tape    []tradeMsg // Newest first
+	trades  int
+	whales  int
+	volume  map[string][]marketTrade // By market slug, oldest first
+	health  healthMsg
+	lastErr error
+}
+
+func newModel(cfg Config) model {
+	return model{cfg: cfg, volume: make(map[string][]marketTrade)}
+}
+
+// tickMsg prunes the volume window and redraws
+type tickMsg time.Time
+
+func tick() tea.Cmd {
+	return tea.Tick(time.Second, func(t time.Time) tea.Msg { return tickMsg(t) })
+}
+
+func (m model) Init() tea.Cmd {
+	return tick()
+}
+
+func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "q", "ctrl+c", "esc":
+			return m, tea.Quit
+		}
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+	case tradeMsg:
+		m.trades++
+		notional := msg.trade.Price * msg.trade.Size
+		if notional >= m.cfg.WhaleNotional {
+			m.whales++
+		}
+		m.tape = append([]tradeMsg{msg}, m.tape...)
+		if len(m.tape) > maxTape {
+			m.tape = m.tape[:maxTape]
+		}
+		market := msg.trade.Slug
+		if market == "" {
+			market = msg.trade.ConditionId
+		}
+		m.volume[market] = append(m.volume[market], marketTrade{at: msg.received, notional: notional})
+	case healthMsg:
+		m.health = msg
+	case consumeErrMsg:
+		m.lastErr = msg.err
+	case tickMsg:
+		m.pruneVolume(time.Time(msg))
+		return m, tick()
+	}
+	return m, nil
+}
+
+// pruneVolume drops trades that left the volume window
+func (m *model) pruneVolume(now time.Time) {
+	cutoff := now.Add(-volumeWindow)
+	for market, trades := range m.volume {
+		i := 0
+		for i < len(trades) && trades[i].at.Before(cutoff) {
+			i++
+		}
+		if i == len(trades) {
+			delete(m.volume, market)
+		} else {
+			m.volume[market] = trades[i:]
+		}
+	}
+}
+
+func (m model) View() string {
+	if m.width == 0 {
+		return "Waiting for trades..."
+	}
+	header := titleStyle.Render(fmt.Sprintf("%s  trades %d  whales %d (≥ $%.0f)",
+		m.cfg.Topic, m.trades, m.whales, m.cfg.WhaleNotional))
+	if m.lastErr != nil {
+		header += "  " + errStyle.Render("kafka: "+m.lastErr.Error())
+	}
+
+	leftWidth := m.width * 3 / 5
+	rightWidth := m.width - leftWidth - 4
+	bodyHeight := max(m.height-4, 5)
+
+	left := panelStyle.Width(leftWidth - 4).Height(bodyHeight - 2).Render(m.tapeView(leftWidth-4, bodyHeight-2))
+	healthView := m.healthView()
+	volumeHeight := max(bodyHeight-lipgloss.Height(healthView)-4, 3)
+	right := lipgloss.JoinVertical(lipgloss.Left,
+		panelStyle.Width(rightWidth).Height(volumeHeight).Render(m.volumeView(rightWidth, volumeHeight)),
+		panelStyle.Width(rightWidth).Render(healthView),
+	)
+
+	footer := dimStyle.Render("q to quit")
+	return lipgloss.JoinVertical(lipgloss.Left, header, lipgloss.JoinHorizontal(lipgloss.Top, left, right), footer)
+}
+
+func (m model) tapeView(width, height int) string {
+	lines := []string{titleStyle.Render("Trade tape")}
+	for _, t := range m.tape {
+		if len(lines) >= height {
+			break
+		}
+		trade := t.trade
+		notional := trade.Price * trade.Size
+		line := fmt.Sprintf("%s %-4s %9.2f @ %.3f $%9.0f %s",
+			time.Unix(trade.Timestamp, 0).Format("15:04:05"), trade.Side, trade.Size, trade.Price, notional,
+			truncate(trade.Slug+" "+trade.Outcome, max(width-48, 10)))
+		switch {
+		case notional >= m.cfg.WhaleNotional:
+			line = whaleStyle.Render("🐋 " + line)
+		case trade.Side == "BUY":
+			line = buyStyle.Render("   " + line)
+		default:
+			line = sellStyle.Render("   " + line)
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (m model) volumeView(width, height int) string {
+	type marketVolume struct {
+		market   string
+		notional float64
+	}
+	var markets []marketVolume
+	for market, trades := range m.volume {
+		var total float64
+		for _, t := range trades {
+			total += t.notional
+		}
+		markets = append(markets, marketVolume{market, total})
+	}
+	sort.Slice(markets, func(i, j int) bool { return markets[i].notional > markets[j].notional })
+
+	lines := []string{titleStyle.Render(fmt.Sprintf("Volume, last %s", volumeWindow))}
+	n := min(len(markets), volumeBars, max(height-1, 0))
+	if n == 0 {
+		return lines[0]
+	}
+	labelWidth := max(width/3, 10)
+	barWidth := max(width-labelWidth-14, 5)
+	for _, mv := range markets[:n] {
+		bar := int(mv.notional / markets[0].notional * float64(barWidth))
+		lines = append(lines, fmt.Sprintf("%-*s %s $%.0f",
+			labelWidth, truncate(mv.market, labelWidth), buyStyle.Render(strings.Repeat("█", max(bar, 1))), mv.notional))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (m model) healthView() string {
+	lines := []string{titleStyle.Render("Pipeline")}
+	if m.health.err != nil {
+		return strings.Join(append(lines, errStyle.Render("unreachable: "+m.health.err.Error())), "\n")
+	}
+	if m.health.status == "" {
+		return strings.Join(append(lines, dimStyle.Render("waiting for "+m.cfg.APIURL)), "\n")
+	}
+
+	status := m.health.status
+	if status != "ready" {
+		status = errStyle.Render(status)
+	}
+	lines = append(lines, "status: "+status)
+
+	sinks := make([]string, 0, len(m.health.sinks))
+	for name, s := range m.health.sinks {
+		state := s.State
+		if state != health.SinkHealthy {
+			state = errStyle.Render(state)
+		}
+		sinks = append(sinks, name+" "+state)
+	}
+	sort.Strings(sinks)
+	if len(sinks) > 0 {
+		lines = append(lines, "sinks: "+strings.Join(sinks, ", "))
+	}
+
+	for _, s := range m.health.stages {
+		lines = append(lines, fmt.Sprintf("%-8s in %-9d out %-9d queued %d/%d errors %d",
+			s.Name, s.In, s.Out, s.Queued, s.Capacity, s.Errors))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// truncate shortens s to at most n runes
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	if n <= 1 {
+		return string(r[:n])
+	}
+	return string(r[:n-1]) + "…"
+}
@@ -0,0 +1,145 @@
+// Package tape is a terminal UI showing the live trade tape from the trades
+// topic: recent trades with whales highlighted, per-market volume and the
+// ingester's pipeline health.
+package tape
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	internalkafka "github.com/FatwaArya/pm-ingest/internal/kafka"
+	"github.com/FatwaArya/pm-ingest/internal/pipeline"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// healthInterval is how often the ingester's health and stats are polled
+const healthInterval = 2 * time.Second
+
+// Config selects what the tape shows
+type Config struct {
+	Brokers       string  // Comma-separated Kafka brokers
+	Topic         string  // Trades topic
+	APIURL        string  // Ingester HTTP API, for pipeline health
+	WhaleNotional float64 // Trades of at least this USD notional are highlighted
+}
+
+// Run shows the tape until the user quits or ctx is cancelled
+func Run(ctx context.Context, cfg Config) error {
+	// The tape starts at the end of the topic: it shows what happens live
+	cl, err := kgo.NewClient(append(internalkafka.ClientOpts(cfg.Brokers),
+		kgo.ConsumeTopics(cfg.Topic),
+		kgo.ConsumeResetOffset(kgo.NewOffset().AtEnd()),
+	)...)
+	if err != nil {
+		return fmt.Errorf("failed to create kafka client: %w", err)
+	}
+	defer cl.Close()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	p := tea.NewProgram(newModel(cfg), tea.WithAltScreen(), tea.WithContext(ctx))
+	go consume(ctx, cl, p)
+	go pollHealth(ctx, cfg.APIURL, p)
+
+	_, err = p.Run()
+	if err != nil && ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
+
+// tradeMsg is a trade read from the topic
+type tradeMsg struct {
+	trade    internalkafka.TradeMessage
+	received time.Time
+}
+
+// healthMsg is the ingester's state, or the error fetching it
+type healthMsg struct {
+	status string
+	sinks  map[string]struct {
+		State string `json:"state"`
+	}
+	stages []pipeline.StageStats
+	err    error
+}
+
+// consumeErrMsg reports a fetch error
+type consumeErrMsg struct{ err error }
+
+func consume(ctx context.Context, cl *kgo.Client, p *tea.Program) {
+	for {
+		fetches := cl.PollFetches(ctx)
+		if ctx.Err() != nil || fetches.IsClientClosed() {
+			return
+		}
+		if errs := fetches.Errors(); len(errs) > 0 {
+			p.Send(consumeErrMsg{errs[0].Err})
+		}
+		now := time.Now()
+		fetches.EachRecord(func(r *kgo.Record) {
+			trade, err := internalkafka.DecodeTrade(r.Value)
+			if err != nil {
+				return
+			}
+			p.Send(tradeMsg{trade: trade, received: now})
+		})
+	}
+}
+
+func pollHealth(ctx context.Context, apiURL string, p *tea.Program) {
+	client := &http.Client{Timeout: healthInterval}
+	ticker := time.NewTicker(healthInterval)
+	defer ticker.Stop()
+	for {
+		p.Send(fetchHealth(ctx, client, strings.TrimRight(apiURL, "/")))
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func fetchHealth(ctx context.Context, client *http.Client, apiURL string) healthMsg {
+	var msg healthMsg
+	var ready struct {
+		Status string `json:"status"`
+		Sinks  map[string]struct {
+			State string `json:"state"`
+		} `json:"sinks"`
+	}
+	// /readyz answers 503 with a body while not ready, so the status code isn't checked
+	if err := getJSON(ctx, client, apiURL+"/readyz", &ready); err != nil {
+		return healthMsg{err: err}
+	}
+	msg.status, msg.sinks = ready.Status, ready.Sinks
+
+	var stats struct {
+		Stages []pipeline.StageStats `json:"stages"`
+	}
+	if err := getJSON(ctx, client, apiURL+"/pipeline/stats", &stats); err != nil {
+		return healthMsg{err: err}
+	}
+	msg.stages = stats.Stages
+	return msg
+}
+
+func getJSON(ctx context.Context, client *http.Client, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return json.NewDecoder(resp.Body).Decode(v)
+}
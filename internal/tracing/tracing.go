@@ -0,0 +1,110 @@
+// Package tracing sets up OpenTelemetry tracing. Spans follow a trade from
+// WebSocket receipt through the ingest pipeline and Kafka into the
+// consumers, their Polymarket API calls and QuestDB writes, so the stage
+// where end-to-end latency accumulates can be found.
+//
+// Without Setup (or with an empty endpoint) the global tracer provider is
+// OpenTelemetry's no-op one and spans cost next to nothing.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/FatwaArya/pm-ingest/internal/provenance"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentation = "github.com/FatwaArya/pm-ingest"
+
+// Config configures the exporter
+type Config struct {
+	Endpoint    string  // OTLP/HTTP collector URL, e.g. "http://otel-collector:4318"; empty disables tracing
+	ServiceName string  // service.name of the spans
+	SampleRatio float64 // Fraction of traces started here that are kept; 0 keeps none
+}
+
+// Setup installs the global tracer provider and W3C trace context
+// propagation. The returned function flushes spans still buffered and
+// stops the exporter.
+func Setup(ctx context.Context, cfg Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	if cfg.Endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(cfg.Endpoint))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace exporter: %w", err)
+	}
+	info := provenance.Current()
+	res := resource.NewSchemaless(
+		attribute.String("service.name", cfg.ServiceName),
+		attribute.String("service.version", info.Version),
+		attribute.String("service.instance.id", info.InstanceID),
+		attribute.String("host.name", info.Hostname),
+	)
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		// Traces continued from another process keep its sampling decision
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Start starts a span named name as a child of the span in ctx, if any
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentation).Start(ctx, name, opts...)
+}
+
+// End ends span, marking it failed when err isn't nil
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Transport wraps base so every request gets a client span and carries
+// the trace context to the server
+func Transport(base http.RoundTripper) http.RoundTripper {
+	return roundTripper(func(req *http.Request) (*http.Response, error) {
+		ctx, span := Start(req.Context(), "HTTP "+req.Method+" "+req.URL.Host,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				attribute.String("http.request.method", req.Method),
+				attribute.String("server.address", req.URL.Host),
+				attribute.String("url.path", req.URL.Path),
+			))
+		req = req.Clone(ctx)
+		otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+		resp, err := base.RoundTrip(req)
+		switch {
+		case err != nil:
+			End(span, err)
+		case resp.StatusCode >= 400:
+			span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+			End(span, fmt.Errorf("status %d", resp.StatusCode))
+		default:
+			span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+			span.End()
+		}
+		return resp, err
+	})
+}
+
+type roundTripper func(*http.Request) (*http.Response, error)
+
+func (f roundTripper) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
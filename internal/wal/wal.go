@@ -0,0 +1,174 @@
+package wal
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// WAL is a local append-only log of records that could not be delivered.
+// Each record is stored base64-encoded on its own line, so binary records
+// (Avro, Protobuf) can hold any byte. Lines starting with '{' are raw JSON
+// records left by earlier versions and are replayed as they are.
+type WAL struct {
+	path  string
+	mu    sync.Mutex
+	file  *os.File
+	count int
+}
+
+// Open opens (or creates) the WAL file at path
+func Open(path string) (*WAL, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create WAL directory: %w", err)
+	}
+
+	w := &WAL{path: path}
+
+	// A leftover replay file means we crashed mid-replay; fold it back in
+	replayPath := path + ".replay"
+	if leftover, err := os.ReadFile(replayPath); err == nil {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open WAL: %w", err)
+		}
+		_, err = f.Write(leftover)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to recover WAL: %w", err)
+		}
+		os.Remove(replayPath)
+	}
+
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+
+	// Count records left over from a previous run
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read WAL: %w", err)
+	}
+	w.count = bytes.Count(data, []byte{'\n'})
+
+	return w, nil
+}
+
+func (w *WAL) open() error {
+	f, err := os.OpenFile(w.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open WAL: %w", err)
+	}
+	w.file = f
+	return nil
+}
+
+// Append writes a record to the end of the WAL
+func (w *WAL) Append(record []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	line := base64.StdEncoding.AppendEncode(make([]byte, 0, base64.StdEncoding.EncodedLen(len(record))+1), record)
+	line = append(line, '\n')
+	if _, err := w.file.Write(line); err != nil {
+		return fmt.Errorf("failed to append to WAL: %w", err)
+	}
+	w.count++
+	return nil
+}
+
+// Len returns the number of records currently in the WAL
+func (w *WAL) Len() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.count
+}
+
+// Replay calls fn for every record in order. Records for which fn returns an
+// error are kept (together with everything after the first failure, to
+// preserve order); successfully replayed records are removed.
+//
+// The current contents are moved aside before fn is called, so Append can
+// keep accepting new records while a slow replay is in progress.
+func (w *WAL) Replay(fn func(record []byte) error) (int, error) {
+	w.mu.Lock()
+	if w.count == 0 {
+		w.mu.Unlock()
+		return 0, nil
+	}
+	if err := w.file.Close(); err != nil {
+		w.mu.Unlock()
+		return 0, fmt.Errorf("failed to close WAL: %w", err)
+	}
+	replayPath := w.path + ".replay"
+	if err := os.Rename(w.path, replayPath); err != nil {
+		w.mu.Unlock()
+		return 0, fmt.Errorf("failed to rotate WAL: %w", err)
+	}
+	w.count = 0
+	err := w.open()
+	w.mu.Unlock()
+	if err != nil {
+		return 0, err
+	}
+
+	data, err := os.ReadFile(replayPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read WAL: %w", err)
+	}
+
+	var remaining [][]byte
+	replayed := 0
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		record, err := decodeLine(line)
+		if err != nil {
+			continue // Drop corrupt lines rather than blocking the WAL
+		}
+		if remaining == nil {
+			if err := fn(record); err == nil {
+				replayed++
+				continue
+			}
+		}
+		remaining = append(remaining, record)
+	}
+
+	// Put undelivered records back; they end up after anything appended
+	// during the replay, which is acceptable for at-least-once delivery
+	for _, record := range remaining {
+		if err := w.Append(record); err != nil {
+			return replayed, err
+		}
+	}
+	return replayed, os.Remove(replayPath)
+}
+
+// decodeLine returns the record stored on line, in a new slice
+func decodeLine(line []byte) ([]byte, error) {
+	if line[0] == '{' {
+		return append([]byte(nil), line...), nil
+	}
+	return base64.StdEncoding.AppendDecode(nil, line)
+}
+
+// Close closes the WAL file
+func (w *WAL) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
@@ -0,0 +1,121 @@
+package wal_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/FatwaArya/pm-ingest/internal/kafka"
+	"github.com/FatwaArya/pm-ingest/internal/schema"
+	"github.com/FatwaArya/pm-ingest/internal/wal"
+)
+
+// avroTrade encodes a trade in Avro under schema ID 10, which puts a
+// newline byte in the wire format header
+func avroTrade(t *testing.T, msg *kafka.TradeMessage) []byte {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]int{"id": 10})
+	}))
+	t.Cleanup(srv.Close)
+
+	s, err := schema.NewSerializer(schema.FormatAvro, schema.NewRegistry(srv.URL, "", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Register(context.Background(), kafka.TradeSchema); err != nil {
+		t.Fatal(err)
+	}
+	value, err := s.Encode(kafka.TradeSchema, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.IndexByte(value, '\n') < 0 {
+		t.Fatalf("encoded trade %v has no newline byte", value)
+	}
+	return value
+}
+
+func TestSpillAndReplayBinaryRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trades.wal")
+	w, err := wal.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	value := avroTrade(t, &kafka.TradeMessage{
+		Side:            "BUY",
+		TransactionHash: "0x1",
+		ProxyWallet:     "0xabc",
+		OutcomeIndex:    5, // Zigzag-encodes to 0x0A too
+		Price:           0.42,
+		Size:            10,
+	})
+	if err := w.Append(value); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Append([]byte(`{"transactionHash":"0x2"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Reopening counts records, not the newline bytes inside them
+	w, err = wal.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if n := w.Len(); n != 2 {
+		t.Fatalf("Len() = %d, want 2", n)
+	}
+
+	var got [][]byte
+	replayed, err := w.Replay(func(record []byte) error {
+		got = append(got, record)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replayed != 2 || len(got) != 2 {
+		t.Fatalf("replayed %d records, want 2", replayed)
+	}
+	if !bytes.Equal(got[0], value) {
+		t.Errorf("replayed %v, want %v", got[0], value)
+	}
+	if string(got[1]) != `{"transactionHash":"0x2"}` {
+		t.Errorf("replayed %q", got[1])
+	}
+	if w.Len() != 0 {
+		t.Errorf("Len() = %d after replay, want 0", w.Len())
+	}
+}
+
+func TestReplayLegacyJSONLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trades.wal")
+	if err := os.WriteFile(path, []byte("{\"transactionHash\":\"0x1\"}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	w, err := wal.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	var got string
+	if _, err := w.Replay(func(record []byte) error {
+		got = string(record)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if got != `{"transactionHash":"0x1"}` {
+		t.Errorf("replayed %q", got)
+	}
+}
@@ -5,153 +5,1188 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	_ "net/http/pprof" // Enable pprof for Roumon
 	"os"
 	"os/signal"
+	"slices"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"syscall"
+	"time"
 
 	"github.com/FatwaArya/pm-ingest/config"
-	"github.com/FatwaArya/pm-ingest/internal"
+	internalqdb "github.com/FatwaArya/pm-ingest/internal"
+	"github.com/FatwaArya/pm-ingest/internal/api"
+	"github.com/FatwaArya/pm-ingest/internal/channels"
 	"github.com/FatwaArya/pm-ingest/internal/domain"
+	"github.com/FatwaArya/pm-ingest/internal/events"
+	"github.com/FatwaArya/pm-ingest/internal/gql"
+	"github.com/FatwaArya/pm-ingest/internal/health"
 	internalkafka "github.com/FatwaArya/pm-ingest/internal/kafka"
-	"github.com/FatwaArya/pm-ingest/utils"
+	"github.com/FatwaArya/pm-ingest/internal/logging"
+	"github.com/FatwaArya/pm-ingest/internal/metrics"
+	"github.com/FatwaArya/pm-ingest/internal/pipeline"
+	"github.com/FatwaArya/pm-ingest/internal/provenance"
+	"github.com/FatwaArya/pm-ingest/internal/shutdown"
+	"github.com/FatwaArya/pm-ingest/internal/sink"
+	"github.com/FatwaArya/pm-ingest/internal/store"
+	"github.com/FatwaArya/pm-ingest/internal/stream"
+	"github.com/FatwaArya/pm-ingest/internal/tracing"
+	"github.com/FatwaArya/pm-ingest/internal/wal"
+	"github.com/FatwaArya/pm-ingest/pkg/dataapi"
+	"github.com/FatwaArya/pm-ingest/pkg/gamma"
+	"github.com/FatwaArya/pm-ingest/pkg/rtds"
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func main() {
-	log.Printf("Starting application in %s mode on port %s", config.AppConfig.GinMode, config.AppConfig.AppPort)
+	configErr := config.Load()
+	// --validate-config prints the effective configuration and whether it's
+	// valid, without starting anything
+	if len(os.Args) > 1 && os.Args[1] == "--validate-config" {
+		config.Print(os.Stdout)
+		if configErr != nil {
+			os.Exit(1)
+		}
+		return
+	}
+	if configErr != nil {
+		log.Fatal(configErr)
+	}
+
+	// Config is validated by now, so Setup can only fail on a bug
+	if err := logging.Setup(os.Stderr, config.AppConfig.LogLevel, config.AppConfig.LogFormat); err != nil {
+		log.Fatal(err)
+	}
+
+	// Every Kafka client, subcommands included, connects with these settings
+	if err := internalkafka.Configure(kafkaSecurity()); err != nil {
+		log.Fatalf("invalid kafka security settings: %v", err)
+	}
+	if err := configureSchemas(); err != nil {
+		log.Fatalf("failed to register schemas: %v", err)
+	}
+
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "tape":
+			if err := runTape(os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		case "backfill-confidence":
+			if err := runConfidenceBackfill(os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		case "backfill-trades":
+			if err := runTradeBackfill(os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		case "replay":
+			if err := runReplay(os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		case "replay-dlq":
+			if err := runReplayDLQ(os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+	}
+
+	log.Printf("Starting application (env=%s) in %s mode on port %s", config.AppConfig.Env, config.AppConfig.GinMode, config.AppConfig.AppPort)
 	log.Printf("Kafka brokers: %s, topic: %s", config.AppConfig.KafkaBrokers, config.AppConfig.KafkaTopic)
 
+	provenance.SetInstanceID(config.AppConfig.InstanceID)
+	info := provenance.Current()
+	log.Printf("Instance %s on %s, version %s", info.InstanceID, info.Hostname, info.Version)
+
 	var processedTrades uint64
-	verbose := true
+
+	// Hot-path logs are rate limited/sampled so a burst of bad messages or a
+	// Kafka outage doesn't turn logging into the bottleneck
+	parseErrLog := logging.NewRateLimited(logging.For("ingest"), 5*time.Second)
+	produceErrLog := logging.NewRateLimited(logging.For("ingest"), 5*time.Second)
+	tradeLog := logging.For("ingest")
+	// Debug lines are worth formatting only when they'll be logged
+	verbose := slog.Default().Enabled(context.Background(), slog.LevelDebug)
+	sinkNames := config.AppConfig.Sinks
+	if config.AppConfig.DryRun {
+		log.Println("Dry-run enabled: trades are written to stdout instead of the configured sinks")
+		sinkNames = []string{sink.NameStdout}
+	}
 
 	// Setup graceful shutdown
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
-	ctx := context.Background()
+	// Subsystems register their shutdown steps with the coordinator. ctx is
+	// cancelled once shutdown has drained ingestion; everything long-running
+	// (consumers, API calls, handover) derives from it.
+	coordinator := shutdown.New(context.Background(), config.AppConfig.ShutdownTimeout)
+	ctx := coordinator.Context()
 
-	// Create subscriptions for activity trades (public, no auth needed)
-	subscriptions := []internal.Subscription{
-		internal.NewActivityTradesSubscription(),
+	// Create subscriptions for activity trades (public, no auth needed),
+	// restricted to the SUBSCRIPTION_* events, markets and wallets
+	tradeFilter := []rtds.FilterOption{
+		rtds.WithEvents(config.AppConfig.SubscriptionEvents...),
+		rtds.WithMarkets(config.AppConfig.SubscriptionMarkets...),
+		rtds.WithUsers(config.AppConfig.SubscriptionWallets...),
+		rtds.WithMinSize(config.AppConfig.SubscriptionMinSize),
+	}
+	subscriptions := []rtds.Subscription{
+		rtds.NewActivityTradesSubscription(tradeFilter...),
+	}
+
+	// The authenticated clob_user feed carries our own orders and fills
+	if config.AppConfig.ClobUserEnabled {
+		auth := &rtds.Auth{
+			APIKey:     config.AppConfig.PolymarketAPIKey,
+			Secret:     config.AppConfig.PolymarketSecret,
+			Passphrase: config.AppConfig.PolymarketPassphrase,
+		}
+		subscriptions = append(subscriptions, rtds.NewClobUserSubscription(auth))
 	}
 
-	// Optionally add clob_user subscription if auth is configured
-	// if config.AppConfig.PolymarketAPIKey != "" {
-	// 	auth := &internal.Auth{
-	// 		APIKey:     config.AppConfig.PolymarketAPIKey,
-	// 		Secret:     config.AppConfig.PolymarketSecret,
-	// 		Passphrase: config.AppConfig.PolymarketPassphrase,
-	// 	}
-	// 	subscriptions = append(subscriptions, internal.NewClobUserSubscription(auth))
-	// }
+	// Extra RTDS topics (comments, crypto prices, ...) from the channel
+	// registry, produced to their own Kafka topics
+	if symbols := config.AppConfig.CryptoPriceSymbols; len(symbols) > 0 {
+		channels.Register(channels.CryptoPrices(symbols...))
+	}
+	if symbols := config.AppConfig.ChainlinkPriceSymbols; len(symbols) > 0 {
+		channels.Register(channels.ChainlinkPrices(symbols...))
+	}
+	rtdsChannels, err := channels.Enabled(config.AppConfig.RTDSChannels)
+	if err != nil {
+		log.Fatalf("invalid rtds channels: %v", err)
+	}
+	channelSubs, err := channels.Subscriptions(rtdsChannels)
+	if err != nil {
+		log.Fatalf("invalid rtds channels: %v", err)
+	}
+	subscriptions = append(subscriptions, channelSubs...)
 
-	// Kafka producer for trades
+	// Lifecycle state backing the Kubernetes startup/liveness/readiness probes
+	lifecycle := health.NewLifecycle()
 	kafkaBrokers := strings.TrimSpace(config.AppConfig.KafkaBrokers)
-	producer, err := internalkafka.NewProducer(kafkaBrokers, config.AppConfig.KafkaTopic)
+	questdbAddr := net.JoinHostPort(config.AppConfig.QuestDBHost, config.AppConfig.QuestDBILPPort)
+
+	// Fault injection for resilience testing (never in prod)
+	injector := newChaos()
+
+	// Tracing wraps the default transport after chaos, so injected API
+	// faults show up as failed spans
+	shutdownTracing, err := tracing.Setup(ctx, tracing.Config{
+		Endpoint:    config.AppConfig.TracingEndpoint,
+		ServiceName: config.AppConfig.TracingServiceName,
+		SampleRatio: config.AppConfig.TracingSampleRatio,
+	})
 	if err != nil {
-		log.Fatalf("failed to create kafka producer: %v", err)
+		log.Fatalf("failed to set up tracing: %v", err)
 	}
-	defer producer.Close()
+	if config.AppConfig.TracingEndpoint != "" {
+		log.Printf("Tracing %.0f%% of messages to %s", config.AppConfig.TracingSampleRatio*100, config.AppConfig.TracingEndpoint)
+		http.DefaultTransport = tracing.Transport(http.DefaultTransport)
+	}
+	coordinator.Add(shutdown.PhaseServers, "tracing", shutdownTracing)
 
-	// Discovery service consumer for high-value traders
-	discoveryService, err := domain.NewDiscoveryService(
-		kafkaBrokers,
-		config.AppConfig.KafkaTopic,
-		"discovery-service-group", // Consumer group ID
+	// Data API clients share a rate limit and retry transient failures; set
+	// up before any client is created
+	useDataAPIPolicy()
+
+	// Kafka producer for trades; the producer doesn't connect until first
+	// use, so it's created before the startup gate to back the kafka check
+	var (
+		producer *internalkafka.Producer
+		tradeWAL *wal.WAL
 	)
-	if err != nil {
-		log.Fatalf("failed to create discovery service: %v", err)
+	if slices.Contains(sinkNames, sink.NameKafka) {
+		producer, tradeWAL, err = newKafkaProducer(lifecycle)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if injector != nil {
+			producer.SetFaultInjector(injector.KafkaFault())
+		}
+	} else if config.AppConfig.DiscoveryEnabled {
+		// Discovery consumes the trades topic even if this instance doesn't produce
+		lifecycle.AddCheck("kafka", health.TCPCheck(strings.Split(kafkaBrokers, ",")[0]))
+	}
+	if slices.Contains(sinkNames, sink.NameQuestDB) || config.AppConfig.DiscoveryEnabled || config.AppConfig.ClobUserEnabled ||
+		config.AppConfig.ActivityInterval > 0 || config.AppConfig.PriceFlushInterval > 0 {
+		lifecycle.AddCheck("questdb", health.TCPCheck(questdbAddr))
+	}
+	if slices.Contains(sinkNames, sink.NameClickHouse) {
+		lifecycle.AddCheck("clickhouse", newClickHouseClient().Ping)
+	}
+	if slices.Contains(sinkNames, sink.NamePostgres) {
+		addr, err := postgresAddr()
+		if err != nil {
+			log.Fatalf("invalid POSTGRES_URL: %v", err)
+		}
+		lifecycle.AddCheck("postgres", health.TCPCheck(addr))
 	}
-	defer discoveryService.Close()
 
-	// Run discovery service in a goroutine
-	go func() {
-		log.Println("Starting discovery service consumer...")
-		if err := discoveryService.Run(ctx); err != nil {
-			log.Printf("Discovery service error: %v", err)
+	// Shared state for dedupe and caches: Redis when configured so replicas
+	// don't duplicate work, otherwise in process memory
+	var sharedStore store.Store = store.NewMemoryStore()
+	if config.AppConfig.RedisURL != "" {
+		redisStore, err := store.NewRedisStore(ctx, config.AppConfig.RedisURL, config.AppConfig.RedisPrefix)
+		if err != nil {
+			log.Fatalf("failed to create redis store: %v", err)
 		}
-	}()
+		lifecycle.AddCheck("redis", redisStore.Ping)
+		sharedStore = redisStore
+	}
+	coordinator.Add(shutdown.PhaseClose, "store", func(context.Context) error { return sharedStore.Close() })
 
-	// // Confidence service for calculating user confidence based on new bets and closed positions
-	// confidenceService, err := domain.NewConfidenceService(
-	// 	kafkaBrokers,
-	// 	config.AppConfig.KafkaTopic,
-	// 	"confidence-service-group", // Consumer group ID
-	// )
-	// if err != nil {
-	// 	log.Fatalf("failed to create confidence service: %v", err)
-	// }
-	// defer confidenceService.Close()
-
-	// // Run confidence service in a goroutine
-	// go func() {
-	// 	log.Println("Starting confidence service consumer...")
-	// 	if err := confidenceService.Run(ctx); err != nil {
-	// 		log.Printf("Confidence service error: %v", err)
-	// 	}
-	// }()
-
-	// Create WebSocket client
-	client := internal.NewWebSocketClient(
-		subscriptions,
-		func(message []byte) {
-			// print raw and parsed
-
-			trade, err := utils.ParseActivityTrade(message)
-			if err != nil {
-				// Skip non-trade messages silently
-				if errors.Is(err, utils.ErrSkipMessage) {
-					return
-				}
-				log.Printf("Error parsing activity trade: %v", err)
-				return
-			}
+	// Trade middleware (filter, dedupe, ...), run in order in front of the sinks
+	lists, err := newIngestLists()
+	if err != nil {
+		log.Fatalf("invalid ingest lists: %v", err)
+	}
+	middleware, err := buildMiddleware(config.AppConfig.PipelineStages, sharedStore, lists, produceErrLog)
+	if err != nil {
+		log.Fatalf("invalid pipeline: %v", err)
+	}
+	// The server only filters by slug; wallets and size are checked here
+	if filter := rtds.NewActivityFilter(tradeFilter...); !filter.Empty() {
+		middleware = append([]pipeline.Middleware{pipeline.Filter(filter.Match)}, middleware...)
+	}
 
-			if err := producer.ProduceTrade(ctx, trade); err != nil {
-				log.Printf("Error producing trade to Kafka for id=%s: %v", trade.TransactionHash, err)
+	// Maker/taker flow, trade velocity and market liquidity, from trades that
+	// pass the middleware
+	flow := domain.NewFlowTracker(config.AppConfig.FlowRetention)
+	velocity := domain.NewVelocityTracker(domain.DefaultBurstRule)
+	liquidity := domain.NewLiquidityEstimator(config.AppConfig.FlowRetention)
+	middleware = append(middleware,
+		pipeline.Observe(flow.Record),
+		pipeline.Observe(velocity.Record),
+	)
+	if verbose {
+		// Put high-value trades in the context of their market's liquidity,
+		// assessed before the liquidity recorder below sees the trade itself
+		middleware = append(middleware, pipeline.Observe(func(trade *rtds.ActivityTradePayload) {
+			notional := trade.Size * trade.Price
+			if notional < domain.MinimumTradeSize {
 				return
 			}
-			if verbose {
-				count := atomic.AddUint64(&processedTrades, 1)
-				if count%100 == 0 {
-					log.Printf("Processed trades: %d", count)
-				}
+			if a := liquidity.Assess(domain.MarketKey(trade), notional); a.Known {
+				tradeLog.Debug("High-value trade",
+					"notional", notional, "market", trade.MarketSlug, "percentile", a.Percentile,
+					"impact_cents", a.EstimatedImpact*100, "large", a.Large,
+					logging.KeyTxHash, trade.TransactionHash, logging.KeyWallet, trade.ProxyWalletAddress)
 			}
-		},
-		verbose,
+		}))
+	}
+	middleware = append(middleware, pipeline.Observe(liquidity.Record))
+
+	// Domain events (market.new, wallet.settlement) go to Kafka when this
+	// instance produces, otherwise to the log
+	var emitter events.Emitter = events.LogEmitter{}
+	if producer != nil {
+		emitter = events.NewKafkaEmitter(producer, config.AppConfig.EventsTopic)
+	}
+	gammaClient := gamma.NewClient()
+	profiles := domain.NewProfileFetcher(sharedStore, gammaClient, config.AppConfig.ProfileCacheTTL)
+	owners := domain.NewOwnerResolver(sharedStore, gammaClient)
+	owners.SetProfileFetcher(profiles)
+	exposure := domain.NewExposureTracker(sharedStore, dataapi.NewClient(), config.AppConfig.ExposureCacheTTL)
+
+	// Per-asset VWAP and last price, shared by everything that needs prices
+	prices := domain.NewPriceService(config.AppConfig.PriceWindow)
+	middleware = append(middleware, pipeline.Observe(prices.Record))
+
+	// Per-minute market summaries, a compact alternative to the raw trades
+	if config.AppConfig.MarketSummaries {
+		if producer != nil {
+			summaries := domain.NewMarketSummarizer(producer, config.AppConfig.MarketSummaryTopic)
+			middleware = append(middleware, pipeline.Observe(summaries.Record))
+			go func() {
+				if err := summaries.Run(ctx); err != nil {
+					log.Printf("Market summarizer error: %v", err)
+				}
+			}()
+		} else {
+			log.Println("MARKET_SUMMARIES needs the kafka sink; summaries are disabled")
+		}
+	}
+
+	// Active hours, sessions and reaction times per wallet
+	activity := domain.NewActivityTracker(config.AppConfig.FlowRetention)
+	middleware = append(middleware, pipeline.Observe(activity.Record))
+
+	// Market metadata from Gamma, for category routing: trades of the
+	// configured categories are also produced to <prefix><category>...
+	catalog := domain.NewMarketCatalog(gammaClient)
+	var router sink.TopicRouter
+	if len(config.AppConfig.CategoryTopics) > 0 {
+		middleware = append(middleware, catalog.Middleware())
+		router = categoryRouter(catalog, config.AppConfig.CategoryTopics, config.AppConfig.CategoryTopicPrefix)
+	}
+	// ...and copied onto trades before they are written to Kafka and QuestDB
+	if config.AppConfig.MarketEnrichment {
+		catalog.SetEnrichWait(config.AppConfig.MarketEnrichmentWait)
+		middleware = append(middleware, pipeline.Enrich(catalog.Enrich))
+	}
+
+	// First trade on a condition ID: look the market up on Gamma and emit market.new
+	watchlist := domain.NewWatchlist()
+	if config.AppConfig.NewMarketDetection {
+		detector := domain.NewMarketDetector(sharedStore, gammaClient, emitter)
+		detector.OnNewMarket(catalog.AddNewMarket)
+		detector.OnNewMarket(activity.MarketListed)
+		if config.AppConfig.NewMarketAutoWatch {
+			watchlist.WatchNewMarkets(detector)
+		}
+		middleware = append(middleware, detector.Middleware())
+	}
+
+	// Wallet labels (whale, market_maker, ...), set by the detectors below
+	// or the admin API, and copied onto every trade of the wallet
+	labels := domain.NewWalletLabels(domain.DefaultLabelRules)
+	middleware = append(middleware,
+		pipeline.Observe(labels.Detect(flow, watchlist)),
+		pipeline.Enrich(labels.Enrich),
 	)
 
-	// Run WebSocket in a goroutine
-	go func() {
-		if err := client.Run(); err != nil {
-			log.Printf("WebSocket error: %v", err)
+	// Positions of high-value wallets, settled against the winning outcome
+	// when their markets resolve
+	positions := domain.NewPositionBook(domain.MinimumTradeSize)
+	if config.AppConfig.ResolutionInterval > 0 {
+		middleware = append(middleware, pipeline.Observe(positions.Record))
+	}
+	resolutions := domain.NewResolutionReconciler(positions, gammaClient, emitter, config.AppConfig.ResolutionInterval)
+
+	// Resolutions of every traded market, published as market.resolved and
+	// kept in QuestDB to label trades with the winning outcome
+	resolutionWatcher := domain.NewResolutionWatcher(gammaClient, emitter, config.AppConfig.ResolutionWatchInterval)
+	if config.AppConfig.ResolutionWatchInterval > 0 {
+		middleware = append(middleware, pipeline.Observe(resolutionWatcher.Record))
+		lifecycle.AddStatus("resolutions.watched", func() any { return resolutionWatcher.Watched() })
+	}
+
+	// Brier and log scores of wallets' trades against resolved outcomes,
+	// alongside the position-based ones
+	var tradeScorer *domain.TradeScorer
+	if config.AppConfig.TradeScoreMaxTrades > 0 {
+		tradeScorer = domain.NewTradeScorer(dataapi.NewClient(), resolutionWatcher, config.AppConfig.TradeScoreMaxTrades)
+	}
+
+	// The WebSocket read loop hands messages to the parse workers through a
+	// bounded queue, blocking or dropping the oldest message when it's full
+	ingestOverflow, err := pipeline.ParseOverflow(config.AppConfig.IngestOverflow)
+	if err != nil {
+		log.Fatalf("invalid INGEST_OVERFLOW: %v", err)
+	}
+	ingestOpts := []pipeline.IngestOption{
+		pipeline.WithParseQueue(config.AppConfig.IngestWorkers, config.AppConfig.IngestQueueSize, ingestOverflow),
+	}
+
+	// Our clob_user fills, checked against the public trades with the same
+	// order IDs once the grace period has passed
+	if config.AppConfig.ClobUserEnabled {
+		fills := domain.NewFillReconciler(config.AppConfig.PolymarketAPIKey, emitter, config.AppConfig.FillReconcileGrace)
+		// Ahead of the other stages, so filtered trades still count as public
+		middleware = append([]pipeline.Middleware{pipeline.Observe(fills.Record)}, middleware...)
+		go func() {
+			if err := fills.Run(ctx); err != nil {
+				log.Printf("Fill reconciler error: %v", err)
+			}
+		}()
+
+		// Our orders and fills are kept too: published to their own topics,
+		// with the order lifecycle in QuestDB
+		if producer == nil {
+			log.Println("CLOB_USER_ENABLED without the kafka sink; our orders and fills aren't published")
 		}
-	}()
+		user, err := newClobUser(ctx, producer)
+		if err != nil {
+			log.Fatalf("failed to create order writer: %v", err)
+		}
+		coordinator.Add(shutdown.PhaseClose, "order writer", user.Close)
+		coordinator.Go("Order writer", user.Run)
+		ingestOpts = append(ingestOpts,
+			pipeline.WithUserTrades(func(trade *rtds.ClobUserTrade) {
+				fills.RecordUserTrade(trade)
+				user.Trade(trade)
+			}),
+			pipeline.WithUserOrders(user.Order))
+	}
+
+	if len(rtdsChannels) > 0 {
+		var handlers []func(ctx context.Context, topic, messageType string, message any)
+		if producer != nil {
+			router := channels.NewRouter(producer, config.AppConfig.ChannelTopicPrefix, rtdsChannels)
+			handlers = append(handlers, router.Handle)
+		} else {
+			log.Println("RTDS_CHANNELS needs the kafka sink; channel messages aren't published")
+		}
+		// Crypto reference prices also go to QuestDB, next to the trades
+		if slices.ContainsFunc(rtdsChannels, func(ch channels.Channel) bool {
+			return ch.Topic() == rtds.TopicCryptoPrices || ch.Topic() == rtds.TopicCryptoPricesChainlink
+		}) {
+			prices, err := newCryptoPrices(ctx)
+			if err != nil {
+				log.Fatalf("failed to create crypto price writer: %v", err)
+			}
+			coordinator.Add(shutdown.PhaseClose, "crypto price writer", prices.Close)
+			coordinator.Go("Crypto price writer", prices.Run)
+			handlers = append(handlers, prices.Handle)
+		}
+		ingestOpts = append(ingestOpts, pipeline.WithChannels(func(ctx context.Context, topic, messageType string, message any) {
+			for _, handle := range handlers {
+				handle(ctx, topic, messageType, message)
+			}
+		}))
+	}
+
+	// Messages the pipeline drops, and records the consumers give up on, go
+	// to the dead-letter topic for replay-dlq
+	var dlq *internalkafka.DeadLetterQueue
+	if config.AppConfig.DLQEnabled {
+		if producer != nil {
+			dlq = internalkafka.NewDeadLetterQueue(producer, config.AppConfig.DLQTopic)
+			ingestOpts = append(ingestOpts, pipeline.WithDeadLetter(dlq.Publish))
+		} else {
+			log.Println("DLQ_ENABLED needs the kafka sink; dropped messages aren't kept")
+		}
+	}
+
+	// Options shared by the trade consumers
+	commitMode, err := internalkafka.ParseCommitMode(config.AppConfig.KafkaCommitMode)
+	if err != nil {
+		log.Fatalf("invalid KAFKA_COMMIT_MODE: %v", err)
+	}
+	consumerOpts := []internalkafka.ConsumerOption{
+		internalkafka.WithCommitMode(commitMode),
+		internalkafka.WithWorkers(config.AppConfig.ConsumerWorkers),
+	}
+	if dlq != nil {
+		consumerOpts = append(consumerOpts, internalkafka.WithDeadLetter(dlq))
+	}
+
+	// Order book events from the CLOB market channel, for depth analytics
+	var market *clobMarket
+	if config.AppConfig.ClobMarketEnabled {
+		if producer != nil {
+			logger := logging.Printer{Logger: logging.For("clob"), Level: slog.LevelDebug}
+			market = newClobMarket(ctx, producer, logger)
+			if config.AppConfig.ClobMarketFollowTrades {
+				middleware = append(middleware, pipeline.Observe(market.Follow))
+			}
+		} else {
+			log.Println("CLOB_MARKET_ENABLED needs the kafka sink; the market channel isn't ingested")
+		}
+	}
+
+	// Analytics replicas split the wallet space between them
+	shard, err := domain.NewShard(config.AppConfig.AnalyticsShardIndex, config.AppConfig.AnalyticsShardCount)
+	if err != nil {
+		log.Fatalf("invalid analytics shard: %v", err)
+	}
+	if shard.Enabled() {
+		log.Printf("Analytics shard %d of %d", shard.Index, shard.Count)
+	}
+
+	// Confidence calculations and profile saves run on a bounded pool rather
+	// than a goroutine per trade
+	analyticsOverflow, err := pipeline.ParseOverflow(config.AppConfig.AnalyticsOverflow)
+	if err != nil {
+		log.Fatalf("invalid ANALYTICS_OVERFLOW: %v", err)
+	}
+	analyticsPool := pipeline.NewPool(config.AppConfig.AnalyticsWorkers, config.AppConfig.AnalyticsQueueSize, analyticsOverflow)
+	coordinator.Add(shutdown.PhaseClose, "analytics workers", func(context.Context) error {
+		analyticsPool.Close()
+		return nil
+	})
+
+	// Latest confidence of each wallet, whichever service computed it: kept
+	// in Redis when configured, otherwise saved to a file on shutdown
+	confidenceCache := domain.NewConfidenceCache(config.AppConfig.ConfidenceCacheTTL)
+	if config.AppConfig.RedisURL != "" {
+		confidenceCache.SetStore(sharedStore)
+	} else if path := config.AppConfig.ConfidenceCachePath; path != "" {
+		if err := confidenceCache.Load(path); err != nil {
+			log.Printf("Starting with an empty confidence cache: %v", err)
+		}
+		coordinator.Add(shutdown.PhaseClose, "confidence cache", func(context.Context) error {
+			return confidenceCache.Save(path)
+		})
+	}
+	lifecycle.AddStatus("confidence.cache", func() any { return confidenceCache.Stats() })
+
+	// Confidence service scoring the wallet of every bet, publishing results
+	// to CONFIDENCE_TOPIC and keeping them in the confidence history table
+	// the history API and GraphQL read
+	var confidenceService *domain.ConfidenceService
+	if config.AppConfig.ConfidenceServiceEnabled {
+		confidenceService, err = domain.NewConfidenceService(
+			kafkaBrokers,
+			config.AppConfig.ConfidenceSourceTopic,
+			shard.GroupID(config.AppConfig.ConfidenceGroup),
+			consumerOpts...,
+		)
+		if err != nil {
+			log.Fatalf("failed to create confidence service: %v", err)
+		}
+		coordinator.Add(shutdown.PhaseClose, "confidence service", func(context.Context) error {
+			confidenceService.Close()
+			return nil
+		})
+		confidenceService.SetStore(sharedStore)
+		confidenceService.SetShard(shard)
+		confidenceService.SetWorkers(analyticsPool)
+		confidenceService.SetCache(confidenceCache)
+		confidenceService.SetTradeScorer(tradeScorer)
+
+		if producer != nil {
+			confidenceService.OnResult(publishConfidence(producer, config.AppConfig.ConfidenceTopic))
+		} else {
+			log.Println("CONFIDENCE_SERVICE_ENABLED without the kafka sink; results aren't published")
+		}
+		confidenceWriter, err := newConfidenceWriter(ctx, config.AppConfig.QuestDBConfidenceTable)
+		if err != nil {
+			log.Fatalf("failed to create confidence writer: %v", err)
+		}
+		coordinator.Add(shutdown.PhaseClose, "confidence service writer", confidenceWriter.Close)
+		writeScore := writeConfidence(confidenceWriter)
+		confidenceService.OnResult(func(ctx context.Context, result domain.ConfidenceResult) {
+			writeScore(ctx, result.Scored())
+		})
+	}
+
+	// Top wallets by confidence and recent volume, for dashboards
+	topTraders := domain.NewTopTraders(flow, config.AppConfig.TopTradersCount, config.AppConfig.TopTradersInterval)
+
+	// Wallets ranked by rolling 24h/7d/30d volume and realized PnL
+	leaderboard := domain.NewLeaderboard(dataapi.NewClient(), config.AppConfig.LeaderboardSize,
+		config.AppConfig.LeaderboardInterval, config.AppConfig.LeaderboardPnLTTL)
+	middleware = append(middleware, pipeline.Observe(leaderboard.Record))
+	if producer != nil && config.AppConfig.LeaderboardTopic != "" {
+		leaderboard.SetProducer(producer, config.AppConfig.LeaderboardTopic)
+	}
+
+	// Wallets tracked on purpose, managed through /api/v1/watchlist
+	walletWatchlist, err := domain.NewWalletWatchlist(config.AppConfig.WatchlistPath)
+	if err != nil {
+		log.Fatalf("failed to load wallet watchlist: %v", err)
+	}
+
+	// Open positions and mark-to-market PnL of discovered and watched
+	// wallets, refreshed on their trades
+	var positionTracker *domain.PositionTracker
+	if config.AppConfig.PositionsRefreshInterval > 0 {
+		positionTracker = domain.NewPositionTracker(exposure, config.AppConfig.PositionsRefreshInterval)
+		positionTracker.SetWatchlist(walletWatchlist)
+		middleware = append(middleware, pipeline.Observe(positionTracker.Record))
+		lifecycle.AddStatus("positions.tracked", func() any { return positionTracker.Tracked() })
+	}
+
+	// Copy-trading signals for the trades of tracked wallets
+	var signals *domain.SignalService
+	if config.AppConfig.Signals {
+		var emitters []events.Emitter
+		if producer != nil && config.AppConfig.SignalTopic != "" {
+			emitters = append(emitters, events.NewKafkaEmitter(producer, config.AppConfig.SignalTopic))
+		}
+		for _, url := range config.AppConfig.SignalWebhookURLs {
+			emitters = append(emitters, events.WebhookEmitter{URL: url})
+		}
+		if len(emitters) == 0 {
+			log.Println("SIGNALS has neither a Kafka topic nor a webhook; signals are logged")
+			emitters = append(emitters, events.LogEmitter{})
+		}
+		signals = domain.NewSignalService(config.AppConfig.SignalWallets, config.AppConfig.SignalMinNotional, emitters...)
+		if config.AppConfig.SignalCopyTargets {
+			signals.SetLabels(labels)
+		}
+		signals.SetWatchlist(walletWatchlist)
+		middleware = append(middleware, pipeline.Observe(signals.Record))
+		coordinator.Go("Signal service", signals.Run)
+	}
+
+	// Trades and discovery/confidence events streamed to UIs over WebSocket or SSE
+	var streamHub *stream.Hub
+	if config.AppConfig.StreamMaxClients > 0 {
+		streamHub = stream.NewHub(config.AppConfig.StreamMaxClients, config.AppConfig.StreamClientBuffer)
+		middleware = append(middleware, pipeline.Observe(streamHub.PublishTrade))
+		coordinator.Add(shutdown.PhaseClose, "stream clients", func(context.Context) error {
+			streamHub.Close()
+			return nil
+		})
+	}
 
 	// Setup Gin router
 	r := gin.Default()
 
-	r.GET("/ping", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{
-			"message": "pong",
+	lifecycle.Register(r)
+	api.RegisterFlow(r, flow)
+	api.RegisterVelocity(r, velocity)
+	api.RegisterActivity(r, activity)
+	api.RegisterPrices(r, prices)
+	api.RegisterLiquidity(r, liquidity)
+	api.RegisterWatchlist(r, watchlist)
+	api.RegisterOwners(r, owners)
+	api.RegisterExposure(r, exposure)
+	api.RegisterTopTraders(r, topTraders)
+	api.RegisterLeaderboard(r, leaderboard)
+	if streamHub != nil {
+		api.RegisterStream(r, streamHub)
+	}
+	questdbQueries := internalqdb.NewQueryClient(config.AppConfig.QuestDBHTTPAddr())
+	api.RegisterExport(r, questdbQueries, config.AppConfig.QuestDBTablePrefix+config.AppConfig.QuestDBTradesTable)
+	api.RegisterConfidenceHistory(r, questdbQueries, config.AppConfig.QuestDBTablePrefix+config.AppConfig.QuestDBConfidenceTable)
+	if confidenceService != nil {
+		api.RegisterUserConfidence(r, confidenceService)
+	}
+	api.RegisterConfidenceCache(r, confidenceCache)
+	if config.AppConfig.GraphQLEnabled {
+		cfg := config.AppConfig
+		handler, err := gql.NewHandler(questdbQueries, gql.Tables{
+			Trades:     cfg.QuestDBTablePrefix + cfg.QuestDBTradesTable,
+			Profiles:   cfg.QuestDBTablePrefix + cfg.QuestDBProfilesTable,
+			Confidence: cfg.QuestDBTablePrefix + cfg.QuestDBConfidenceTable,
 		})
+		if err != nil {
+			log.Fatalf("failed to create graphql handler: %v", err)
+		}
+		api.RegisterGraphQL(r, handler)
+	}
+
+	// Runtime administration, behind ADMIN_TOKEN when set
+	admin := r.Group("/admin", api.AdminAuth(config.AppConfig.AdminToken))
+	api.RegisterIngestLists(admin, lists)
+	if injector != nil {
+		registerChaos(admin, injector)
+	}
+	api.RegisterLabels(admin, labels)
+	api.RegisterWalletWatchlist(r.Group("/api/v1", api.AdminAuth(config.AppConfig.AdminToken)), walletWatchlist)
+
+	// Per-stage counters of the ingest pipeline, once it is running
+	var ingest atomic.Pointer[pipeline.Pipeline]
+	r.GET("/pipeline/stats", func(c *gin.Context) {
+		p := ingest.Load()
+		if p == nil {
+			c.JSON(http.StatusOK, gin.H{"stages": []pipeline.StageStats{}})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"stages": p.Stats()})
 	})
 
-	// Start server in a goroutine
+	// Pipeline and sink counters pushed to StatsD/DogStatsD or served to
+	// Prometheus on /metrics, when configured
+	reporter, prometheus, err := newMetricsReporter()
+	if err != nil {
+		log.Fatalf("failed to create metrics exporter: %v", err)
+	}
+	if reporter != nil {
+		reporter.AddSource(metrics.PipelineSource(ingest.Load))
+		reporter.AddSource(metrics.SinkSource(lifecycle))
+		reporter.AddSource(metrics.PoolSource("analytics", analyticsPool))
+		if streamHub != nil {
+			reporter.AddSource(func(r *metrics.Reporter) {
+				r.Gauge("stream.clients", float64(streamHub.Clients()))
+				r.Total("stream.dropped", streamHub.Dropped())
+			})
+		}
+		if signals != nil {
+			reporter.AddSource(metrics.CounterSource("signals.dropped", signals.Dropped))
+		}
+		reporter.AddSource(func(r *metrics.Reporter) {
+			stats := confidenceCache.Stats()
+			r.Gauge("confidence_cache.entries", float64(stats.Entries))
+			r.Total("confidence_cache.hits", stats.Hits)
+			r.Total("confidence_cache.misses", stats.Misses)
+		})
+	}
+	if prometheus != nil {
+		r.GET("/metrics", gin.WrapH(prometheus))
+	} else if reporter != nil {
+		coordinator.Go("Metrics reporter", func(ctx context.Context) error {
+			return reporter.Run(ctx, config.AppConfig.MetricsInterval)
+		})
+	}
+
+	srv := &http.Server{
+		Addr:    fmt.Sprintf(":%s", config.AppConfig.AppPort),
+		Handler: r,
+	}
+
+	coordinator.Add(shutdown.PhaseServers, "http server", srv.Shutdown)
+
+	// Start server in a goroutine so probes are served during startup
 	go func() {
-		if err := r.Run(fmt.Sprintf(":%s", config.AppConfig.AppPort)); err != nil {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			log.Printf("Server error: %v", err)
 		}
 	}()
 
+	// Gate startup on dependencies; a signal during startup aborts it
+	startupCtx, stopStartup := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	log.Println("Waiting for dependencies...")
+	if err := lifecycle.WaitForDependencies(startupCtx, config.AppConfig.StartupCheckInterval); err != nil {
+		stopStartup()
+		log.Printf("Startup aborted: %v", err)
+		coordinator.Shutdown()
+		return
+	}
+	stopStartup()
+	log.Println("All dependency checks passed")
+
+	// With QUESTDB_FROM_KAFKA, QuestDB is written from the trades topic by
+	// the relay rather than alongside Kafka, so the two can't diverge
+	if config.AppConfig.QuestDBFromKafka {
+		if slices.Contains(sinkNames, sink.NameKafka) && slices.Contains(sinkNames, sink.NameQuestDB) {
+			sinkNames = slices.DeleteFunc(slices.Clone(sinkNames), func(name string) bool { return name == sink.NameQuestDB })
+			relay, err := newQuestDBRelay(ctx, lifecycle)
+			if err != nil {
+				log.Fatalf("failed to create questdb relay: %v", err)
+			}
+			coordinator.Add(shutdown.PhaseClose, "questdb relay", relay.Close)
+			lifecycle.AddStatus("questdb-relay.lag", func() any { return relay.Lag() })
+			if reporter != nil {
+				reporter.AddSource(metrics.ConsumerLagSource("questdb-relay", relay.Lag))
+			}
+			coordinator.Go("QuestDB relay", func(ctx context.Context) error {
+				log.Println("Writing QuestDB trades from the Kafka topic")
+				return relay.Run(ctx)
+			})
+		} else {
+			log.Println("QUESTDB_FROM_KAFKA needs both the kafka and questdb sinks; ignoring it")
+		}
+	}
+
+	// Every trade is written to each configured sink
+	sinks, err := buildSinks(ctx, sinkNames, producer, tradeWAL, router, lifecycle)
+	if err != nil {
+		log.Fatalf("failed to create sinks: %v", err)
+	}
+	if injector != nil {
+		injectSinkFaults(injector, sinks)
+	}
+	if reporter != nil {
+		instrumentSinks(reporter, sinks)
+	}
+	// Deliver buffered trades before cancelling ctx fails them
+	coordinator.Add(shutdown.PhaseFlush, "sinks", sinks.Flush)
+	coordinator.Add(shutdown.PhaseClose, "sinks", sinks.Close)
+	// ClickHouse keeps confidence scores next to the trades and profiles
+	clickHouse := clickHouseWriter(sinks)
+	if clickHouse != nil && confidenceService != nil {
+		writeScore := writeClickHouseConfidence(clickHouse)
+		confidenceService.OnResult(func(ctx context.Context, result domain.ConfidenceResult) {
+			writeScore(ctx, result.Scored())
+		})
+	}
+
+	streamScore := func(ctx context.Context, score domain.ScoredConfidence) {
+		streamHub.Publish(stream.Event{Type: stream.TypeConfidence, Wallet: strings.ToLower(score.Wallet)}, score)
+	}
+	if streamHub != nil && confidenceService != nil {
+		confidenceService.OnResult(func(ctx context.Context, result domain.ConfidenceResult) {
+			streamScore(ctx, result.Scored())
+		})
+	}
+
+	// Discovery service consumer for high-value traders
+	var discoveryService *domain.DiscoveryService
+	if config.AppConfig.DiscoveryEnabled {
+		discoveryService, err = domain.NewDiscoveryService(
+			kafkaBrokers,
+			config.AppConfig.DiscoverySourceTopic,
+			shard.GroupID(config.AppConfig.DiscoveryGroup),
+			consumerOpts...,
+		)
+		if err != nil {
+			log.Fatalf("failed to create discovery service: %v", err)
+		}
+		coordinator.Add(shutdown.PhaseClose, "discovery", func(context.Context) error {
+			discoveryService.Close()
+			return nil
+		})
+		// Seen wallets: shared through Redis, or a bounded set in memory;
+		// either way QuestDB has the profiles saved before a restart
+		if config.AppConfig.RedisURL != "" {
+			discoveryService.SetStore(sharedStore)
+		} else {
+			discoveryService.SetStore(store.NewLRUStore(config.AppConfig.DiscoverySeenCacheSize))
+		}
+		discoveryService.SetProfileHistory(questdbQueries)
+		discoveryService.SetShard(shard)
+		discoveryService.SetWorkers(analyticsPool)
+
+		// Whale alerts ride on discovery, which sees every trade
+		notifier, err := newNotifier(config.AppConfig)
+		if err != nil {
+			log.Fatalf("failed to create whale alerts: %v", err)
+		}
+		if !notifier.Empty() {
+			notifier.LogTargets()
+			discoveryService.SetNotifier(notifier)
+		}
+		discoveryService.SetSinkHealth(lifecycle.TrackSink("questdb", config.AppConfig.SinkFailureThreshold, config.AppConfig.SinkRetryInterval))
+		discoveryService.SetOwnerResolver(owners)
+		discoveryService.SetProfileFetcher(profiles)
+		discoveryService.SetExposure(exposure)
+		discoveryService.SetTradeScorer(tradeScorer)
+		discoveryService.SetProfileRefresh(config.AppConfig.ProfileRefreshInterval)
+		if config.AppConfig.DiscoveryRule != "" {
+			rule, err := domain.CompileDiscoveryRule(config.AppConfig.DiscoveryRule, config.AppConfig.DiscoveryPriceWindow)
+			if err != nil {
+				log.Fatalf("failed to compile DISCOVERY_RULE: %v", err)
+			}
+			log.Printf("Discovering trades matching %s", rule)
+			discoveryService.SetRule(rule)
+		}
+		discoveryService.SetLabels(labels)
+		discoveryService.SetWatchlist(walletWatchlist)
+		if clickHouse != nil {
+			discoveryService.OnProfile(func(ctx context.Context, profile *internalqdb.UserProfile) {
+				if err := clickHouse.WriteProfile(profile); err != nil {
+					log.Printf("Error writing profile %s to ClickHouse: %v", profile.Address, err)
+				}
+			})
+		}
+		discoveryService.OnProfile(func(ctx context.Context, profile *internalqdb.UserProfile) {
+			leaderboard.MarkDiscovered(profile.Address)
+			if positionTracker != nil {
+				positionTracker.Track(profile.Address)
+			}
+			if streamHub != nil {
+				streamHub.Publish(stream.Event{Type: stream.TypeProfile, Wallet: strings.ToLower(profile.Address)}, profile)
+			}
+		})
+		lifecycle.AddStatus("discovery.lag", func() any { return discoveryService.ConsumerLag() })
+		if reporter != nil {
+			reporter.AddSource(metrics.ConsumerLagSource("discovery", discoveryService.ConsumerLag))
+		}
+		if config.AppConfig.ConfidenceRefresh > 0 {
+			refresher := domain.NewConfidenceRefresher(dataapi.NewClient(), emitter,
+				config.AppConfig.ConfidenceRefresh, config.AppConfig.ConfidenceStaleAfter)
+			refresher.SetTradeScorer(tradeScorer)
+			discoveryService.SetConfidenceRefresher(refresher)
+			topTraders.SetConfidence(refresher)
+			if signals != nil {
+				signals.SetConfidence(refresher)
+			}
+
+			// Every computed score is kept in QuestDB as confidence history
+			confidenceWriter, err := newConfidenceWriter(ctx, config.AppConfig.QuestDBConfidenceTable)
+			if err != nil {
+				log.Fatalf("failed to create confidence writer: %v", err)
+			}
+			coordinator.Add(shutdown.PhaseClose, "confidence writer", confidenceWriter.Close)
+			refresher.OnScore(writeConfidence(confidenceWriter))
+			if clickHouse != nil {
+				refresher.OnScore(writeClickHouseConfidence(clickHouse))
+			}
+			refresher.OnScore(labels.DetectCopyTargets())
+			refresher.OnScore(confidenceCache.Record)
+			if streamHub != nil {
+				refresher.OnScore(streamScore)
+			}
+			coordinator.Go("Confidence refresher", refresher.Run)
+		}
+		resolutions.OnSettlement(discoveryService.HandleSettlement)
+
+		// Run discovery service in a goroutine
+		coordinator.Go("Discovery service", func(ctx context.Context) error {
+			log.Println("Starting discovery service consumer...")
+			return discoveryService.Run(ctx)
+		})
+	}
+
+	// SIGHUP reloads the log level, discovery rule and alert channels
+	coordinator.Go("Config reload", func(ctx context.Context) error {
+		return reloadOnHangup(ctx, discoveryService)
+	})
+
+	if config.AppConfig.ActivityInterval > 0 {
+		activityWriter, err := newActivityWriter(ctx)
+		if err != nil {
+			log.Fatalf("failed to create activity writer: %v", err)
+		}
+		coordinator.Add(shutdown.PhaseClose, "activity writer", activityWriter.Close)
+		coordinator.Go("Activity writer", func(ctx context.Context) error {
+			return activity.Run(ctx, activityWriter, config.AppConfig.ActivityInterval)
+		})
+	}
+
+	if positionTracker != nil {
+		positionWriter, err := newPositionWriter(ctx)
+		if err != nil {
+			log.Fatalf("failed to create position writer: %v", err)
+		}
+		coordinator.Add(shutdown.PhaseClose, "position writer", positionWriter.Close)
+		coordinator.Go("Position tracker", func(ctx context.Context) error {
+			return positionTracker.Run(ctx, positionWriter)
+		})
+	}
+
+	if config.AppConfig.PriceFlushInterval > 0 {
+		priceWriter, err := newPriceBarWriter(ctx)
+		if err != nil {
+			log.Fatalf("failed to create price bar writer: %v", err)
+		}
+		coordinator.Add(shutdown.PhaseClose, "price bar writer", priceWriter.Close)
+		coordinator.Go("Price bar writer", func(ctx context.Context) error {
+			return prices.Run(ctx, priceWriter, config.AppConfig.PriceFlushInterval)
+		})
+	}
+
+	if config.AppConfig.TopTradersInterval > 0 {
+		go func() {
+			if err := topTraders.Run(ctx); err != nil {
+				log.Printf("Top traders snapshot error: %v", err)
+			}
+		}()
+	}
+
+	if config.AppConfig.LeaderboardInterval > 0 {
+		coordinator.Go("Leaderboard", leaderboard.Run)
+	}
+
+	if config.AppConfig.ResolutionWatchInterval > 0 {
+		resolutionWriter, err := newResolutionWriter(ctx)
+		if err != nil {
+			log.Fatalf("failed to create resolution writer: %v", err)
+		}
+		coordinator.Add(shutdown.PhaseClose, "resolution writer", resolutionWriter.Close)
+		resolutionWatcher.OnResolution(writeResolution(resolutionWriter))
+		coordinator.Go("Resolution watcher", resolutionWatcher.Run)
+	}
+
+	// The watcher polls every traded market, the tracked positions' among
+	// them, so with it running the reconciler only settles what it reports
+	if config.AppConfig.ResolutionInterval > 0 {
+		if config.AppConfig.ResolutionWatchInterval > 0 {
+			resolutionWatcher.OnResolution(resolutions.Settle)
+		} else {
+			coordinator.Go("Resolution reconciler", resolutions.Run)
+		}
+	}
+
+	if confidenceService != nil {
+		coordinator.Go("Confidence service", func(ctx context.Context) error {
+			log.Println("Starting confidence service consumer...")
+			return confidenceService.Run(ctx)
+		})
+	}
+
+	// Set when zero-gap handover is enabled; the first live trade announces
+	// this instance so the previous one can stop
+	var (
+		handover  *internalkafka.Handover
+		announced atomic.Bool
+	)
+
+	// writeTrade is the last pipeline stage. Sinks bound their own writes;
+	// ctx only fails records on shutdown.
+	writeTrade := func(ctx context.Context, trade *rtds.ActivityTradePayload) error {
+		if handover != nil && !announced.Swap(true) {
+			go handover.AnnounceReady(ctx)
+		}
+		if err := sinks.WriteTrade(ctx, trade); err != nil {
+			return fmt.Errorf("id=%s: %w", trade.TransactionHash, err)
+		}
+		if verbose {
+			count := atomic.AddUint64(&processedTrades, 1)
+			if count%100 == 0 {
+				tradeLog.Debug("Processed trades", "count", count)
+			}
+		}
+		return nil
+	}
+
+	// WebSocket messages flow parse -> process -> sink through bounded queues
+	ingestPipeline := pipeline.NewIngest(middleware, writeTrade, parseErrLog, produceErrLog, ingestOpts...)
+	ingestPipeline.Start(ctx)
+	ingest.Store(ingestPipeline)
+	// The archive keeps every message as received, for replays
+	archiver := rawArchiver(sinks)
+	submit := func(message []byte) {
+		if archiver != nil {
+			if err := archiver.WriteRaw(message); err != nil {
+				produceErrLog.Printf("Error archiving message: %v", err)
+			}
+		}
+		// A trace starts at receipt; the pipeline stages continue it
+		msgCtx, span := tracing.Start(ctx, "ws.message", trace.WithSpanKind(trace.SpanKindConsumer))
+		defer span.End()
+		if err := ingestPipeline.Submit(msgCtx, message); err != nil && !errors.Is(err, pipeline.ErrPipelineClosed) {
+			produceErrLog.Printf("Error queueing message: %v", err)
+		}
+	}
+
+	// The WebSocket client only logs connection-level events, at debug level
+	wsLogger := logging.Printer{Logger: logging.For("websocket"), Level: slog.LevelDebug}
+
+	// WebSocket ingestion is started/stopped as a unit so that, with leader
+	// election enabled, only the leader holds a live subscription.
+	var (
+		clientMu       sync.Mutex
+		client         *rtds.WebSocketClient
+		clientStarted  time.Time
+		pastReconnects uint64 // Of clients since stopped
+	)
+	if reporter != nil {
+		reporter.AddSource(metrics.CounterSource("websocket.reconnects", func() uint64 {
+			clientMu.Lock()
+			defer clientMu.Unlock()
+			if client == nil {
+				return pastReconnects
+			}
+			return pastReconnects + client.Reconnects()
+		}))
+	}
+	startIngest := func() {
+		clientMu.Lock()
+		defer clientMu.Unlock()
+		if client != nil {
+			return
+		}
+		client = rtds.NewWebSocketClient(
+			rtds.WithSubscriptions(subscriptions...),
+			rtds.WithMessageCallback(submit),
+			rtds.WithLogger(wsLogger),
+			rtds.WithReconnectPolicy(rtds.DefaultReconnectPolicy()),
+		)
+		clientStarted = time.Now()
+		c := client
+		go func() {
+			if err := c.Run(); err != nil {
+				log.Printf("WebSocket error: %v", err)
+			}
+		}()
+
+		if market != nil {
+			market.Start()
+		}
+	}
+	stopIngest := func() {
+		clientMu.Lock()
+		defer clientMu.Unlock()
+		if client == nil {
+			return
+		}
+		pastReconnects += client.Reconnects()
+		client.Close()
+		client = nil
+		if market != nil {
+			market.Stop()
+		}
+	}
+	// The WebSocket is a dependency once ingestion runs: silent for
+	// WS_STALE_AFTER, the instance is taken out of service; for
+	// WS_LIVENESS_TIMEOUT, it's restarted
+	wsStatus := func() (rtds.ConnectionStatus, bool) {
+		clientMu.Lock()
+		defer clientMu.Unlock()
+		if client == nil {
+			return rtds.ConnectionStatus{}, false
+		}
+		status := client.Status()
+		if status.LastMessage.IsZero() {
+			status.LastMessage = clientStarted // Silent since it started
+		}
+		return status, true
+	}
+	wsSilence := func(timeout time.Duration, needConnected bool) health.Check {
+		return func(context.Context) error {
+			status, running := wsStatus()
+			switch {
+			case !running || timeout <= 0:
+				return nil
+			case needConnected && !status.Connected:
+				return errors.New("not connected")
+			case time.Since(status.LastMessage) > timeout:
+				return fmt.Errorf("no message for %s", time.Since(status.LastMessage).Round(time.Second))
+			}
+			return nil
+		}
+	}
+	lifecycle.AddCheck("websocket", wsSilence(config.AppConfig.WSStaleAfter, true))
+	lifecycle.AddLivenessCheck("websocket", wsSilence(config.AppConfig.WSLivenessTimeout, false))
+	lifecycle.AddStatus("websocket", func() any {
+		status, running := wsStatus()
+		if !running {
+			return gin.H{"running": false}
+		}
+		return gin.H{"running": true, "connected": status.Connected, "silentSeconds": time.Since(status.LastMessage).Seconds()}
+	})
+
+	if injector != nil {
+		go injector.RunDisconnects(ctx, func() {
+			clientMu.Lock()
+			defer clientMu.Unlock()
+			if client != nil {
+				client.Disconnect()
+			}
+		})
+	}
+
+	if config.AppConfig.HandoverEnabled && !config.AppConfig.LeaderElection {
+		if config.AppConfig.RedisURL == "" {
+			log.Println("Handover enabled without REDIS_URL: trades seen by both instances during the overlap won't be deduplicated")
+		}
+		handover, err = internalkafka.NewHandover(kafkaBrokers, config.AppConfig.HandoverTopic, info.InstanceID, stopIngest)
+		if err != nil {
+			log.Fatalf("failed to create handover: %v", err)
+		}
+		coordinator.Add(shutdown.PhaseClose, "handover", func(context.Context) error {
+			handover.Close()
+			return nil
+		})
+
+		go func() {
+			if err := handover.Run(ctx); err != nil {
+				log.Printf("Handover error: %v", err)
+			}
+		}()
+	}
+
+	if config.AppConfig.LeaderElection {
+		// Standby replicas stay connected to Kafka and take over on failover
+		elector, err := internalkafka.NewLeaderElector(
+			kafkaBrokers,
+			config.AppConfig.LeaderTopic,
+			config.AppConfig.LeaderGroup,
+			startIngest,
+			stopIngest,
+		)
+		if err != nil {
+			log.Fatalf("failed to create leader elector: %v", err)
+		}
+		coordinator.Add(shutdown.PhaseClose, "leader elector", func(context.Context) error {
+			elector.Close()
+			return nil
+		})
+
+		go func() {
+			log.Println("Starting leader election, waiting for leadership...")
+			if err := elector.Run(ctx); err != nil {
+				log.Printf("Leader election error: %v", err)
+			}
+		}()
+	} else {
+		startIngest()
+	}
+
 	// Start pprof server for Roumon goroutine monitoring
+	pprofSrv := &http.Server{Addr: ":6060"}
+	coordinator.Add(shutdown.PhaseServers, "pprof server", pprofSrv.Shutdown)
 	go func() {
 		log.Println("pprof server running on :6060")
-		if err := http.ListenAndServe(":6060", nil); err != nil {
+		if err := pprofSrv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			log.Printf("pprof server error: %v", err)
 		}
 	}()
@@ -161,5 +1196,18 @@ func main() {
 	// Wait for shutdown signal
 	<-sigChan
 	log.Println("Shutting down...")
-	client.Close()
+
+	// Flip readiness first and give the orchestrator time to stop routing to us
+	lifecycle.BeginDrain(config.AppConfig.ShutdownDrainDelay)
+
+	// Stop reading, drain messages already read from the socket, flush the
+	// sinks, then cancel ctx and close everything, servers last
+	coordinator.Add(shutdown.PhaseIngest, "ingest", func(context.Context) error {
+		stopIngest()
+		ingestPipeline.Close()
+		return nil
+	})
+	if err := coordinator.Shutdown(); err != nil {
+		log.Printf("Shutdown error: %v", err)
+	}
 }
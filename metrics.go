@@ -0,0 +1,58 @@
+package main
+
+import (
+	"github.com/FatwaArya/pm-ingest/config"
+	"github.com/FatwaArya/pm-ingest/internal/metrics"
+	"github.com/FatwaArya/pm-ingest/internal/sink"
+)
+
+// newMetricsReporter creates the reporter for the configured exporter, or
+// nil when metrics export is disabled. With Prometheus the exporter is
+// returned too, to be served on /metrics.
+func newMetricsReporter() (*metrics.Reporter, *metrics.Prometheus, error) {
+	cfg := config.AppConfig
+	switch cfg.MetricsExporter {
+	case metrics.ExporterStatsD, metrics.ExporterDogStatsD:
+		exporter, err := metrics.NewStatsD(cfg.StatsDAddr, cfg.StatsDPrefix, cfg.StatsDTags,
+			cfg.MetricsExporter == metrics.ExporterDogStatsD)
+		if err != nil {
+			return nil, nil, err
+		}
+		return metrics.NewReporter(exporter), nil, nil
+	case metrics.ExporterPrometheus:
+		exporter := metrics.NewPrometheus(cfg.PrometheusPrefix)
+		reporter := metrics.NewReporter(exporter)
+		exporter.CollectOnScrape(reporter.Report)
+		return reporter, exporter, nil
+	default:
+		return nil, nil, nil
+	}
+}
+
+// instrumentSinks records the records written and failed per sink, the
+// write and flush latencies of the QuestDB sink, and the records dropped by
+// buffered sinks
+func instrumentSinks(reporter *metrics.Reporter, sinks *sink.Fanout) {
+	for i, s := range sinks.Sinks() {
+		reporter.AddSource(metrics.CounterSource("sink.written", func() uint64 { return sinks.Counts()[i].Written }, "sink:"+s.Name()))
+		reporter.AddSource(metrics.CounterSource("sink.failed", func() uint64 { return sinks.Counts()[i].Failed }, "sink:"+s.Name()))
+		if b, ok := s.(*sink.Buffered); ok {
+			reporter.AddSource(metrics.CounterSource("sink.buffer_dropped", b.Dropped, "sink:"+b.Name()))
+		}
+		if cs, ok := sink.Underlying(s).(*sink.ClickHouseSink); ok {
+			reporter.AddSource(metrics.CounterSource("sink.clickhouse_dropped", cs.Writer().Dropped, "sink:"+cs.Name()))
+		}
+		if ps, ok := sink.Underlying(s).(*sink.PostgresSink); ok {
+			reporter.AddSource(metrics.CounterSource("sink.postgres_dropped", ps.Writer().Dropped, "sink:"+ps.Name()))
+		}
+		qs, ok := sink.Underlying(s).(*sink.QuestDBSink)
+		if !ok {
+			continue
+		}
+		write := metrics.NewHistogram(metrics.LatencyBuckets)
+		flush := metrics.NewHistogram(metrics.LatencyBuckets)
+		qs.SetLatencyObserver(sink.LatencyObserver{Write: write.ObserveDuration, Flush: flush.ObserveDuration})
+		reporter.AddSource(metrics.HistogramSource("questdb.write_latency", write))
+		reporter.AddSource(metrics.HistogramSource("questdb.flush_latency", flush))
+	}
+}
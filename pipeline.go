@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"slices"
+
+	"github.com/FatwaArya/pm-ingest/config"
+	"github.com/FatwaArya/pm-ingest/internal/domain"
+	"github.com/FatwaArya/pm-ingest/internal/pipeline"
+	"github.com/FatwaArya/pm-ingest/internal/store"
+)
+
+// buildMiddleware creates the trade middleware named in PIPELINE_STAGES, in
+// order. Trades on the drop list are dropped first; trades on the full
+// fidelity list skip the min-size, filter and sample stages.
+func buildMiddleware(names []string, sharedStore store.Store, lists *domain.IngestLists, logger pipeline.Logger) ([]pipeline.Middleware, error) {
+	cfg := config.AppConfig
+	middleware := make([]pipeline.Middleware, 0, len(names)+1)
+	middleware = append(middleware, pipeline.Filter(lists.Keep))
+	for _, name := range names {
+		switch name {
+		case "min-size":
+			middleware = append(middleware, pipeline.Unless(lists.Full, pipeline.MinSize(cfg.MinTradeSizeUSD)))
+		case "filter":
+			if cfg.FilterExpr == "" {
+				return nil, fmt.Errorf("pipeline stage filter needs FILTER_EXPR")
+			}
+			filter, err := pipeline.Expr(cfg.FilterExpr, logger)
+			if err != nil {
+				return nil, err
+			}
+			middleware = append(middleware, pipeline.Unless(lists.Full, filter))
+		case "sample":
+			middleware = append(middleware, pipeline.Unless(lists.Full, pipeline.Sample(cfg.SampleRate, cfg.SampleWhaleUSD)))
+		case "dedupe":
+			middleware = append(middleware, pipeline.Dedupe(pipeline.NewDeduper(sharedStore, cfg.TradeDedupeTTL, cfg.TradeDedupeCacheSize), logger))
+		case "normalize":
+			middleware = append(middleware, pipeline.Normalize())
+		default:
+			return nil, fmt.Errorf("unknown pipeline stage %q", name)
+		}
+	}
+	if cfg.FilterExpr != "" && !slices.Contains(names, "filter") {
+		log.Printf("FILTER_EXPR is set but PIPELINE_STAGES has no filter stage; it is ignored")
+	}
+	return middleware, nil
+}
+
+// newIngestLists loads the configured full-fidelity and drop lists
+func newIngestLists() (*domain.IngestLists, error) {
+	cfg := config.AppConfig
+	lists := domain.NewIngestLists()
+	for _, l := range []struct {
+		mode            string
+		markets, events []string
+	}{
+		{domain.IngestFull, cfg.IngestFullMarkets, cfg.IngestFullEvents},
+		{domain.IngestDrop, cfg.IngestDropMarkets, cfg.IngestDropEvents},
+	} {
+		for _, m := range l.markets {
+			if err := lists.Set(domain.IngestRule{Market: m, Mode: l.mode}); err != nil {
+				return nil, err
+			}
+		}
+		for _, e := range l.events {
+			if err := lists.Set(domain.IngestRule{Event: e, Mode: l.mode}); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return lists, nil
+}
@@ -0,0 +1,249 @@
+package clob
+
+import (
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/FatwaArya/pm-ingest/pkg/clock"
+	"github.com/FatwaArya/pm-ingest/pkg/rtds"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// WebSocket URL of the CLOB market channel
+	MarketURL = "wss://ws-subscriptions-clob.polymarket.com/ws/market"
+	// The server drops connections that stay silent for more than 10s
+	PingInterval = 10 * time.Second
+)
+
+// subscribeMessage subscribes a new connection to assets
+type subscribeMessage struct {
+	AssetIDs []string `json:"assets_ids"`
+	Type     string   `json:"type"`
+}
+
+// updateMessage changes the assets of an open connection
+type updateMessage struct {
+	AssetIDs  []string `json:"assets_ids"`
+	Operation string   `json:"operation"` // subscribe or unsubscribe
+}
+
+// MarketClient manages a WebSocket connection to the CLOB market channel
+type MarketClient struct {
+	url          string
+	handler      Handler
+	logger       rtds.Logger
+	pingInterval time.Duration
+	dialer       *websocket.Dialer
+	reconnect    rtds.ReconnectPolicy
+	clock        clock.Clock
+
+	mu     sync.RWMutex
+	conn   *websocket.Conn
+	assets []string
+	known  map[string]bool
+	done   chan struct{}
+	closed atomic.Bool
+}
+
+// NewMarketClient creates a market channel client
+func NewMarketClient(opts ...Option) *MarketClient {
+	c := &MarketClient{
+		url:          MarketURL,
+		pingInterval: PingInterval,
+		dialer:       websocket.DefaultDialer,
+		reconnect:    rtds.NoReconnect,
+		clock:        clock.Real,
+		known:        make(map[string]bool),
+		done:         make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// logf logs through the configured logger, if any
+func (c *MarketClient) logf(format string, args ...any) {
+	if c.logger != nil {
+		c.logger.Printf(format, args...)
+	}
+}
+
+// addAsset records an asset, reporting whether it is new. Callers hold mu
+// (or own c exclusively, as options do).
+func (c *MarketClient) addAsset(assetID string) bool {
+	if assetID == "" || c.known[assetID] {
+		return false
+	}
+	c.known[assetID] = true
+	c.assets = append(c.assets, assetID)
+	return true
+}
+
+// Assets returns the subscribed token IDs
+func (c *MarketClient) Assets() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return append([]string(nil), c.assets...)
+}
+
+// Subscribe adds assets to the subscription. On an open connection the new
+// ones are subscribed right away; either way they are resubscribed after
+// every reconnect.
+func (c *MarketClient) Subscribe(assetIDs ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var added []string
+	for _, id := range assetIDs {
+		if c.addAsset(id) {
+			added = append(added, id)
+		}
+	}
+	if len(added) == 0 || c.conn == nil {
+		return nil
+	}
+	data, err := json.Marshal(updateMessage{AssetIDs: added, Operation: "subscribe"})
+	if err != nil {
+		return err
+	}
+	return c.conn.WriteMessage(websocket.TextMessage, data)
+}
+
+// connect dials the channel and subscribes to the known assets
+func (c *MarketClient) connect() (*websocket.Conn, error) {
+	c.logf("Connecting to %s", c.url)
+	conn, _, err := c.dialer.Dial(c.url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed.Load() {
+		conn.Close()
+		return nil, nil
+	}
+	data, err := json.Marshal(subscribeMessage{AssetIDs: c.assets, Type: "market"})
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	c.logf("Subscribing to %d assets", len(c.assets))
+	if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	c.conn = conn
+	return conn, nil
+}
+
+// startPing sends ping messages at regular intervals to keep connection alive
+func (c *MarketClient) startPing() {
+	ticker := c.clock.NewTicker(c.pingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C():
+			c.mu.Lock()
+			if c.conn != nil {
+				if err := c.conn.WriteMessage(websocket.TextMessage, []byte("PING")); err != nil {
+					c.logf("Ping error: %v", err)
+				}
+			}
+			c.mu.Unlock()
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// Run connects, subscribes and dispatches messages to the handler until
+// Close. With a reconnect policy, connection errors trigger a reconnect and
+// resubscribe after a backoff; otherwise the first error is returned.
+func (c *MarketClient) Run() error {
+	go c.startPing()
+
+	attempt := 0
+	for {
+		received, err := c.runConnection()
+		if err == nil || c.closed.Load() {
+			return nil
+		}
+		if received {
+			attempt = 0 // The connection was healthy; start backoff over
+		}
+
+		attempt++
+		delay, ok := c.reconnect.Backoff(attempt)
+		if !ok {
+			return err
+		}
+		c.logf("Connection error, reconnecting in %s (attempt %d): %v", delay, attempt, err)
+
+		timer := c.clock.NewTimer(delay)
+		select {
+		case <-timer.C():
+		case <-c.done:
+			timer.Stop()
+			return nil
+		}
+	}
+}
+
+// runConnection reads from one connection until it fails. It reports
+// whether any message was received, and returns nil when the client was
+// closed or the server closed the connection normally.
+func (c *MarketClient) runConnection() (bool, error) {
+	conn, err := c.connect()
+	if err != nil {
+		return false, err
+	}
+	if conn == nil {
+		return false, nil // Closed while connecting
+	}
+	defer func() {
+		c.mu.Lock()
+		if c.conn == conn {
+			c.conn = nil
+		}
+		c.mu.Unlock()
+		conn.Close()
+	}()
+
+	received := false
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			if c.closed.Load() {
+				return received, nil
+			}
+			if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				c.logf("Connection closed normally")
+				return received, nil
+			}
+			return received, err
+		}
+		received = true
+		if c.handler != nil {
+			Dispatch(message, c.handler)
+		}
+	}
+}
+
+// Close stops Run and closes the connection
+func (c *MarketClient) Close() {
+	if c.closed.Swap(true) {
+		return
+	}
+	close(c.done)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn != nil {
+		c.conn.Close()
+		c.conn = nil
+	}
+}
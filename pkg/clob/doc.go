@@ -0,0 +1,23 @@
+// Package clob is a client for the market channel of the Polymarket CLOB
+// WebSocket (wss://ws-subscriptions-clob.polymarket.com/ws/market), which
+// streams order book snapshots and updates per asset.
+//
+// Messages are parsed into typed events and dispatched to a Handler:
+//
+//	client := clob.NewMarketClient(
+//		clob.WithAssets(assetIDs...),
+//		clob.WithHandler(clob.HandlerFuncs{
+//			Book: func(book *clob.BookEvent) {
+//				if mid, ok := book.Mid(); ok {
+//					fmt.Println(book.AssetID, mid)
+//				}
+//			},
+//		}),
+//		clob.WithReconnectPolicy(rtds.DefaultReconnectPolicy()),
+//	)
+//	defer client.Close()
+//	err := client.Run()
+//
+// Like rtds, the package has no global configuration and does not log
+// unless a Logger is supplied.
+package clob
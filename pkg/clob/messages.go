@@ -0,0 +1,245 @@
+package clob
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+
+	"github.com/FatwaArya/pm-ingest/pkg/pmerrors"
+)
+
+// Market channel event types
+const (
+	EventBook           = "book"
+	EventPriceChange    = "price_change"
+	EventTickSizeChange = "tick_size_change"
+	EventLastTradePrice = "last_trade_price"
+)
+
+// OrderSummary is one price level of a book
+type OrderSummary struct {
+	Price string `json:"price"`
+	Size  string `json:"size"`
+}
+
+// BookEvent is a full order book snapshot for an asset, sent on subscribe
+// and after trades
+type BookEvent struct {
+	EventType string         `json:"event_type"`
+	AssetID   string         `json:"asset_id"`
+	Market    string         `json:"market"` // Condition ID
+	Bids      []OrderSummary `json:"bids"`
+	Asks      []OrderSummary `json:"asks"`
+	Timestamp string         `json:"timestamp"` // Unix milliseconds
+	Hash      string         `json:"hash"`
+}
+
+// BestBid returns the highest bid price, and false for an empty side
+func (b *BookEvent) BestBid() (float64, bool) {
+	return bestPrice(b.Bids, func(p, best float64) bool { return p > best })
+}
+
+// BestAsk returns the lowest ask price, and false for an empty side
+func (b *BookEvent) BestAsk() (float64, bool) {
+	return bestPrice(b.Asks, func(p, best float64) bool { return p < best })
+}
+
+// Mid returns the midpoint of the best bid and ask, and false unless both
+// sides have orders
+func (b *BookEvent) Mid() (float64, bool) {
+	bid, ok := b.BestBid()
+	if !ok {
+		return 0, false
+	}
+	ask, ok := b.BestAsk()
+	if !ok {
+		return 0, false
+	}
+	return (bid + ask) / 2, true
+}
+
+// bestPrice returns the level price that is better than all others. Levels
+// aren't assumed to be sorted.
+func bestPrice(levels []OrderSummary, better func(p, best float64) bool) (float64, bool) {
+	var best float64
+	found := false
+	for _, level := range levels {
+		p, err := strconv.ParseFloat(level.Price, 64)
+		if err != nil {
+			continue
+		}
+		if !found || better(p, best) {
+			best, found = p, true
+		}
+	}
+	return best, found
+}
+
+// PriceChange is the new size at one price level of an asset's book
+type PriceChange struct {
+	AssetID string `json:"asset_id"`
+	Price   string `json:"price"`
+	Size    string `json:"size"` // New aggregate size at the level; "0" removes it
+	Side    string `json:"side"` // BUY or SELL
+	Hash    string `json:"hash"`
+	BestBid string `json:"best_bid"`
+	BestAsk string `json:"best_ask"`
+}
+
+// PriceChangeEvent carries the level changes of a market caused by an order
+// being placed or cancelled
+type PriceChangeEvent struct {
+	EventType    string        `json:"event_type"`
+	Market       string        `json:"market"`
+	PriceChanges []PriceChange `json:"price_changes"`
+	Timestamp    string        `json:"timestamp"`
+}
+
+// TickSizeChangeEvent is sent when an asset's minimum tick size changes, as
+// its price nears 0 or 1
+type TickSizeChangeEvent struct {
+	EventType   string `json:"event_type"`
+	AssetID     string `json:"asset_id"`
+	Market      string `json:"market"`
+	OldTickSize string `json:"old_tick_size"`
+	NewTickSize string `json:"new_tick_size"`
+	Side        string `json:"side,omitempty"`
+	Timestamp   string `json:"timestamp"`
+}
+
+// LastTradePriceEvent is sent when a maker and taker order match
+type LastTradePriceEvent struct {
+	EventType  string `json:"event_type"`
+	AssetID    string `json:"asset_id"`
+	Market     string `json:"market"`
+	Price      string `json:"price"`
+	Side       string `json:"side"`
+	Size       string `json:"size"`
+	FeeRateBps string `json:"fee_rate_bps"`
+	Timestamp  string `json:"timestamp"`
+}
+
+// Handler receives typed market channel events.
+// Embed NopHandler to implement only the methods you need.
+type Handler interface {
+	OnBook(book *BookEvent)
+	OnPriceChange(change *PriceChangeEvent)
+	OnTickSizeChange(change *TickSizeChangeEvent)
+	OnLastTradePrice(trade *LastTradePriceEvent)
+	// OnError receives messages that could not be parsed
+	OnError(err error)
+}
+
+// NopHandler ignores every event
+type NopHandler struct{}
+
+func (NopHandler) OnBook(*BookEvent)                     {}
+func (NopHandler) OnPriceChange(*PriceChangeEvent)       {}
+func (NopHandler) OnTickSizeChange(*TickSizeChangeEvent) {}
+func (NopHandler) OnLastTradePrice(*LastTradePriceEvent) {}
+func (NopHandler) OnError(error)                         {}
+
+// HandlerFuncs adapts plain functions to a Handler; nil fields are ignored
+type HandlerFuncs struct {
+	Book           func(*BookEvent)
+	PriceChange    func(*PriceChangeEvent)
+	TickSizeChange func(*TickSizeChangeEvent)
+	LastTradePrice func(*LastTradePriceEvent)
+	Error          func(error)
+}
+
+func (h HandlerFuncs) OnBook(book *BookEvent) {
+	if h.Book != nil {
+		h.Book(book)
+	}
+}
+
+func (h HandlerFuncs) OnPriceChange(change *PriceChangeEvent) {
+	if h.PriceChange != nil {
+		h.PriceChange(change)
+	}
+}
+
+func (h HandlerFuncs) OnTickSizeChange(change *TickSizeChangeEvent) {
+	if h.TickSizeChange != nil {
+		h.TickSizeChange(change)
+	}
+}
+
+func (h HandlerFuncs) OnLastTradePrice(trade *LastTradePriceEvent) {
+	if h.LastTradePrice != nil {
+		h.LastTradePrice(trade)
+	}
+}
+
+func (h HandlerFuncs) OnError(err error) {
+	if h.Error != nil {
+		h.Error(err)
+	}
+}
+
+// Dispatch parses a raw market channel message and routes it to the
+// handler. Plain-text frames (PONG) and empty frames are ignored; a JSON
+// array, as sent with the initial book snapshots, is dispatched element by
+// element. Unknown event types are skipped.
+func Dispatch(message []byte, handler Handler) {
+	message = bytes.TrimSpace(message)
+	if len(message) == 0 {
+		return
+	}
+
+	switch message[0] {
+	case '{':
+	case '[':
+		var batch []json.RawMessage
+		if err := json.Unmarshal(message, &batch); err != nil {
+			handler.OnError(pmerrors.Decode("market message batch", err))
+			return
+		}
+		for _, m := range batch {
+			Dispatch(m, handler)
+		}
+		return
+	default:
+		return
+	}
+
+	var envelope struct {
+		EventType string `json:"event_type"`
+	}
+	if err := json.Unmarshal(message, &envelope); err != nil {
+		handler.OnError(pmerrors.Decode("market message", err))
+		return
+	}
+
+	switch envelope.EventType {
+	case EventBook:
+		var book BookEvent
+		if err := json.Unmarshal(message, &book); err != nil {
+			handler.OnError(pmerrors.Decode("book event", err))
+			return
+		}
+		handler.OnBook(&book)
+	case EventPriceChange:
+		var change PriceChangeEvent
+		if err := json.Unmarshal(message, &change); err != nil {
+			handler.OnError(pmerrors.Decode("price change event", err))
+			return
+		}
+		handler.OnPriceChange(&change)
+	case EventTickSizeChange:
+		var change TickSizeChangeEvent
+		if err := json.Unmarshal(message, &change); err != nil {
+			handler.OnError(pmerrors.Decode("tick size change event", err))
+			return
+		}
+		handler.OnTickSizeChange(&change)
+	case EventLastTradePrice:
+		var trade LastTradePriceEvent
+		if err := json.Unmarshal(message, &trade); err != nil {
+			handler.OnError(pmerrors.Decode("last trade price event", err))
+			return
+		}
+		handler.OnLastTradePrice(&trade)
+	}
+}
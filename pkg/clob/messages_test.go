@@ -0,0 +1,58 @@
+package clob
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/FatwaArya/pm-ingest/pkg/pmerrors"
+)
+
+func TestDispatch(t *testing.T) {
+	var (
+		books   []*BookEvent
+		changes []*PriceChangeEvent
+		ticks   []*TickSizeChangeEvent
+		trades  []*LastTradePriceEvent
+		errs    []error
+	)
+	handler := HandlerFuncs{
+		Book:           func(b *BookEvent) { books = append(books, b) },
+		PriceChange:    func(c *PriceChangeEvent) { changes = append(changes, c) },
+		TickSizeChange: func(c *TickSizeChangeEvent) { ticks = append(ticks, c) },
+		LastTradePrice: func(tr *LastTradePriceEvent) { trades = append(trades, tr) },
+		Error:          func(err error) { errs = append(errs, err) },
+	}
+
+	Dispatch([]byte(`[{"event_type":"book","asset_id":"1","market":"0xm","bids":[{"price":"0.48","size":"30"},{"price":"0.50","size":"10"}],"asks":[{"price":"0.54","size":"5"},{"price":"0.52","size":"20"}],"timestamp":"1757908892351","hash":"0xh"}]`), handler)
+	Dispatch([]byte(`{"event_type":"price_change","market":"0xm","price_changes":[{"asset_id":"1","price":"0.5","size":"200","side":"BUY","hash":"0xh","best_bid":"0.5","best_ask":"0.52"}],"timestamp":"1757908892351"}`), handler)
+	Dispatch([]byte(`{"event_type":"tick_size_change","asset_id":"1","market":"0xm","old_tick_size":"0.01","new_tick_size":"0.001","timestamp":"1"}`), handler)
+	Dispatch([]byte(`{"event_type":"last_trade_price","asset_id":"1","market":"0xm","price":"0.51","side":"BUY","size":"12","fee_rate_bps":"0","timestamp":"1"}`), handler)
+	Dispatch([]byte(`PONG`), handler)
+	Dispatch([]byte(`{"event_type":"new_market"}`), handler)
+	Dispatch([]byte(`{"event_type":"book","bids":"oops"}`), handler)
+
+	if len(books) != 1 || len(changes) != 1 || len(ticks) != 1 || len(trades) != 1 {
+		t.Fatalf("got %d books, %d price changes, %d tick size changes, %d trades; want 1 of each",
+			len(books), len(changes), len(ticks), len(trades))
+	}
+	if len(errs) != 1 || !errors.Is(errs[0], pmerrors.ErrSchemaMismatch) {
+		t.Fatalf("errors = %v, want one schema mismatch", errs)
+	}
+
+	book := books[0]
+	if bid, _ := book.BestBid(); bid != 0.50 {
+		t.Errorf("BestBid = %v, want 0.50", bid)
+	}
+	if ask, _ := book.BestAsk(); ask != 0.52 {
+		t.Errorf("BestAsk = %v, want 0.52", ask)
+	}
+	if mid, ok := book.Mid(); !ok || mid != 0.51 {
+		t.Errorf("Mid = %v, %v; want 0.51, true", mid, ok)
+	}
+	if got := changes[0].PriceChanges[0].BestAsk; got != "0.52" {
+		t.Errorf("price change best ask = %q, want 0.52", got)
+	}
+	if _, ok := (&BookEvent{Bids: book.Bids}).Mid(); ok {
+		t.Error("Mid of a one-sided book should not be ok")
+	}
+}
@@ -0,0 +1,78 @@
+package clob
+
+import (
+	"time"
+
+	"github.com/FatwaArya/pm-ingest/pkg/clock"
+	"github.com/FatwaArya/pm-ingest/pkg/rtds"
+	"github.com/gorilla/websocket"
+)
+
+// Option configures a MarketClient
+type Option func(*MarketClient)
+
+// WithURL overrides the WebSocket endpoint (default MarketURL)
+func WithURL(url string) Option {
+	return func(c *MarketClient) {
+		c.url = url
+	}
+}
+
+// WithAssets adds the token IDs subscribed to after every (re)connect
+func WithAssets(assetIDs ...string) Option {
+	return func(c *MarketClient) {
+		for _, id := range assetIDs {
+			c.addAsset(id)
+		}
+	}
+}
+
+// WithHandler sets the handler receiving parsed events (default: none)
+func WithHandler(handler Handler) Option {
+	return func(c *MarketClient) {
+		c.handler = handler
+	}
+}
+
+// WithPingInterval sets how often keep-alive pings are sent (default PingInterval)
+func WithPingInterval(interval time.Duration) Option {
+	return func(c *MarketClient) {
+		if interval > 0 {
+			c.pingInterval = interval
+		}
+	}
+}
+
+// WithDialer sets the dialer used to open connections (default websocket.DefaultDialer)
+func WithDialer(dialer *websocket.Dialer) Option {
+	return func(c *MarketClient) {
+		if dialer != nil {
+			c.dialer = dialer
+		}
+	}
+}
+
+// WithLogger sets the logger for connection-level events (default: none)
+func WithLogger(logger rtds.Logger) Option {
+	return func(c *MarketClient) {
+		c.logger = logger
+	}
+}
+
+// WithReconnectPolicy makes Run reconnect and resubscribe after connection
+// errors instead of returning (default: rtds.NoReconnect)
+func WithReconnectPolicy(policy rtds.ReconnectPolicy) Option {
+	return func(c *MarketClient) {
+		c.reconnect = policy
+	}
+}
+
+// WithClock sets the clock driving pings and reconnect backoff (default
+// clock.Real)
+func WithClock(clk clock.Clock) Option {
+	return func(c *MarketClient) {
+		if clk != nil {
+			c.clock = clk
+		}
+	}
+}
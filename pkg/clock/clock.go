@@ -0,0 +1,61 @@
+// Package clock abstracts time so time-dependent behavior (ping loops,
+// backoff, rate limits, TTLs, flush timers) can be driven by a fake clock
+// in tests.
+package clock
+
+import "time"
+
+// Clock tells the time and creates timers
+type Clock interface {
+	Now() time.Time
+	Since(t time.Time) time.Duration
+	NewTicker(d time.Duration) Ticker
+	NewTimer(d time.Duration) Timer
+}
+
+// Ticker delivers ticks on C, like time.Ticker
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// Timer fires once on C, like time.Timer
+type Timer interface {
+	C() <-chan time.Time
+	// Stop prevents the timer from firing and reports whether it was active
+	Stop() bool
+}
+
+// Real is the wall clock
+var Real Clock = realClock{}
+
+// OrReal returns c, or Real if c is nil
+func OrReal(c Clock) Clock {
+	if c == nil {
+		return Real
+	}
+	return c
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                  { return time.Now() }
+func (realClock) Since(t time.Time) time.Duration { return time.Since(t) }
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+type realTicker struct{ t *time.Ticker }
+
+func (r realTicker) C() <-chan time.Time { return r.t.C }
+func (r realTicker) Stop()               { r.t.Stop() }
+
+type realTimer struct{ t *time.Timer }
+
+func (r realTimer) C() <-chan time.Time { return r.t.C }
+func (r realTimer) Stop() bool          { return r.t.Stop() }
@@ -0,0 +1,155 @@
+package clock
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Fake is a Clock that only moves when told to. Timers and tickers fire
+// synchronously from Advance, in deadline order.
+type Fake struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []*fakeWaiter
+	changed chan struct{} // Closed and replaced whenever waiters change
+}
+
+type fakeWaiter struct {
+	at     time.Time
+	period time.Duration // Zero for timers
+	c      chan time.Time
+}
+
+// NewFake creates a fake clock set to now
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now, changed: make(chan struct{})}
+}
+
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *Fake) Since(t time.Time) time.Duration {
+	return f.Now().Sub(t)
+}
+
+func (f *Fake) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("clock: non-positive interval for NewTicker")
+	}
+	return &fakeTicker{f: f, w: f.add(d, d)}
+}
+
+// NewTimer creates a timer; one with d <= 0 fires immediately
+func (f *Fake) NewTimer(d time.Duration) Timer {
+	if d <= 0 {
+		w := &fakeWaiter{c: make(chan time.Time, 1)}
+		w.c <- f.Now()
+		return &fakeTimer{f: f, w: w}
+	}
+	return &fakeTimer{f: f, w: f.add(d, 0)}
+}
+
+// Advance moves the clock forward by d, firing every timer and tick due on
+// the way. Like the real ones, channels hold one value and drop ticks that
+// aren't received in time.
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	target := f.now.Add(d)
+	for {
+		sort.Slice(f.waiters, func(i, j int) bool { return f.waiters[i].at.Before(f.waiters[j].at) })
+		if len(f.waiters) == 0 || f.waiters[0].at.After(target) {
+			break
+		}
+		w := f.waiters[0]
+		f.now = w.at
+		select {
+		case w.c <- w.at:
+		default:
+		}
+		if w.period > 0 {
+			w.at = w.at.Add(w.period)
+		} else {
+			f.waiters = f.waiters[1:]
+			f.notify()
+		}
+	}
+	f.now = target
+}
+
+// Set moves the clock to t (if later than now), firing due timers
+func (f *Fake) Set(t time.Time) {
+	f.Advance(t.Sub(f.Now()))
+}
+
+// Waiters returns the number of active timers and tickers
+func (f *Fake) Waiters() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.waiters)
+}
+
+// BlockUntil waits until at least n timers/tickers are active, so a test
+// can advance the clock once a goroutine under test is waiting on it
+func (f *Fake) BlockUntil(n int) {
+	for {
+		f.mu.Lock()
+		if len(f.waiters) >= n {
+			f.mu.Unlock()
+			return
+		}
+		changed := f.changed
+		f.mu.Unlock()
+		<-changed
+	}
+}
+
+func (f *Fake) add(d, period time.Duration) *fakeWaiter {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	w := &fakeWaiter{at: f.now.Add(d), period: period, c: make(chan time.Time, 1)}
+	f.waiters = append(f.waiters, w)
+	f.notify()
+	return w
+}
+
+// remove deactivates w and reports whether it was active
+func (f *Fake) remove(w *fakeWaiter) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, other := range f.waiters {
+		if other == w {
+			f.waiters = append(f.waiters[:i], f.waiters[i+1:]...)
+			f.notify()
+			return true
+		}
+	}
+	return false
+}
+
+// notify wakes BlockUntil callers; called with mu held
+func (f *Fake) notify() {
+	close(f.changed)
+	f.changed = make(chan struct{})
+}
+
+type fakeTicker struct {
+	f *Fake
+	w *fakeWaiter
+}
+
+func (t *fakeTicker) C() <-chan time.Time { return t.w.c }
+func (t *fakeTicker) Stop()               { t.f.remove(t.w) }
+
+type fakeTimer struct {
+	f *Fake
+	w *fakeWaiter
+}
+
+func (t *fakeTimer) C() <-chan time.Time { return t.w.c }
+func (t *fakeTimer) Stop() bool          { return t.f.remove(t.w) }
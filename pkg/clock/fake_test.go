@@ -0,0 +1,69 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+var epoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func TestFakeTimer(t *testing.T) {
+	f := NewFake(epoch)
+	timer := f.NewTimer(10 * time.Second)
+
+	f.Advance(9 * time.Second)
+	select {
+	case <-timer.C():
+		t.Fatal("timer fired early")
+	default:
+	}
+
+	f.Advance(time.Second)
+	select {
+	case at := <-timer.C():
+		if !at.Equal(epoch.Add(10 * time.Second)) {
+			t.Fatalf("fired at %v", at)
+		}
+	default:
+		t.Fatal("timer did not fire")
+	}
+	if timer.Stop() {
+		t.Fatal("Stop reported a fired timer as active")
+	}
+}
+
+func TestFakeTickerDropsMissedTicks(t *testing.T) {
+	f := NewFake(epoch)
+	ticker := f.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	f.Advance(5 * time.Second)
+	if at := <-ticker.C(); !at.Equal(epoch.Add(time.Second)) {
+		t.Fatalf("first tick at %v", at)
+	}
+	select {
+	case <-ticker.C():
+		t.Fatal("ticks beyond the channel buffer were not dropped")
+	default:
+	}
+	if got := f.Now(); !got.Equal(epoch.Add(5 * time.Second)) {
+		t.Fatalf("Now = %v", got)
+	}
+}
+
+func TestFakeBlockUntil(t *testing.T) {
+	f := NewFake(epoch)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		<-f.NewTimer(time.Minute).C()
+	}()
+
+	f.BlockUntil(1)
+	f.Advance(time.Minute)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("goroutine was not woken by Advance")
+	}
+}
@@ -1,4 +1,4 @@
-package internal
+package dataapi
 
 import (
 	"context"
@@ -8,10 +8,16 @@ import (
 	"net/http"
 	"net/url"
 	"time"
+
+	"github.com/FatwaArya/pm-ingest/pkg/pmerrors"
 )
 
 const (
-	PolymarketAPIURL = "https://data-api.polymarket.com/closed-positions"
+	ClosedPositionsURL = "https://data-api.polymarket.com/closed-positions"
+	TradesURL          = "https://data-api.polymarket.com/trades"
+	PositionsURL       = "https://data-api.polymarket.com/positions"
+	ValueURL           = "https://data-api.polymarket.com/value"
+	HoldersURL         = "https://data-api.polymarket.com/holders"
 )
 
 // ClosedPosition represents a closed position from the Polymarket API
@@ -47,24 +53,47 @@ type ClosedPositionsQueryParams struct {
 	SortDirection string   // Sort direction: ASC, DESC (default: DESC)
 }
 
-// PolymarketAPIClient handles API calls to Polymarket
-type PolymarketAPIClient struct {
-	httpClient *http.Client
-	baseURL    string
+// Client handles API calls to the Polymarket Data API
+type Client struct {
+	httpClient   *http.Client
+	baseURL      string
+	tradesURL    string
+	positionsURL string
+	valueURL     string
+	holdersURL   string
 }
 
-// NewPolymarketAPIClient creates a new Polymarket API client
-func NewPolymarketAPIClient() *PolymarketAPIClient {
-	return &PolymarketAPIClient{
-		httpClient: &http.Client{
-			Timeout: 10 * time.Second,
-		},
-		baseURL: PolymarketAPIURL,
+// shared paces, retries and sheds the requests of every client created
+// after UsePolicy; without it each request is sent once
+var shared *Transport
+
+// UsePolicy makes the clients created from now on share one Transport
+// applying policy, so they share its rate limit and circuit breakers. Call
+// it once at startup; the Transport is returned for OnBreaker.
+func UsePolicy(policy Policy) *Transport {
+	shared = NewTransport(nil, policy)
+	return shared
+}
+
+// NewClient creates a new Data API client
+func NewClient() *Client {
+	httpClient := &http.Client{Timeout: 10 * time.Second}
+	if shared != nil {
+		// The transport times out each attempt, so retries get their own
+		httpClient = &http.Client{Transport: shared}
+	}
+	return &Client{
+		httpClient:   httpClient,
+		baseURL:      ClosedPositionsURL,
+		tradesURL:    TradesURL,
+		positionsURL: PositionsURL,
+		valueURL:     ValueURL,
+		holdersURL:   HoldersURL,
 	}
 }
 
 // GetClosedPositions fetches closed positions from the Polymarket API based on query parameters
-func (c *PolymarketAPIClient) GetClosedPositions(ctx context.Context, params ClosedPositionsQueryParams) ([]ClosedPosition, error) {
+func (c *Client) GetClosedPositions(ctx context.Context, params ClosedPositionsQueryParams) ([]ClosedPosition, error) {
 	// Build the API URL with query parameters
 	apiURL, err := url.Parse(c.baseURL)
 	if err != nil {
@@ -74,7 +103,7 @@ func (c *PolymarketAPIClient) GetClosedPositions(ctx context.Context, params Clo
 	// Add query parameters
 	q := url.Values{}
 	if params.User == "" {
-		return nil, fmt.Errorf("user parameter is required")
+		return nil, fmt.Errorf("%w: user parameter is required", pmerrors.ErrInvalidArgument)
 	}
 	q.Add("user", params.User)
 
@@ -129,14 +158,57 @@ func (c *PolymarketAPIClient) GetClosedPositions(ctx context.Context, params Clo
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
+		return nil, &pmerrors.APIError{
+			StatusCode: resp.StatusCode,
+			Body:       string(body),
+			URL:        apiURL.String(),
+		}
 	}
 
 	// Parse response
 	var positions []ClosedPosition
 	if err := json.NewDecoder(resp.Body).Decode(&positions); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+		return nil, pmerrors.Decode("closed positions response", err)
 	}
 
 	return positions, nil
 }
+
+const (
+	// closedPositionsPageSize is the largest page the API serves
+	closedPositionsPageSize = 50
+	// closedPositionsMaxPages bounds GetAllClosedPositions; the API serves
+	// offsets up to 10000
+	closedPositionsMaxPages = 200
+)
+
+// GetAllClosedPositions pages through the closed positions matching params
+// until the API runs out, max positions were fetched (max <= 0 fetches
+// everything) or closedPositionsMaxPages pages were read. params.Limit and
+// params.Offset are ignored; the order defaults to most profitable first.
+// The positions fetched so far are returned with the error of a failed page.
+func (c *Client) GetAllClosedPositions(ctx context.Context, params ClosedPositionsQueryParams, max int) ([]ClosedPosition, error) {
+	if params.SortBy == "" {
+		params.SortBy, params.SortDirection = "REALIZEDPNL", "DESC"
+	}
+	var all []ClosedPosition
+	for page := 0; page < closedPositionsMaxPages; page++ {
+		if err := ctx.Err(); err != nil {
+			return all, err
+		}
+		params.Limit = closedPositionsPageSize
+		if max > 0 {
+			params.Limit = min(params.Limit, max-len(all))
+		}
+		params.Offset = len(all)
+		positions, err := c.GetClosedPositions(ctx, params)
+		if err != nil {
+			return all, err
+		}
+		all = append(all, positions...)
+		if len(positions) < params.Limit || (max > 0 && len(all) >= max) {
+			break
+		}
+	}
+	return all, nil
+}
@@ -0,0 +1,43 @@
+package dataapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestGetAllClosedPositionsPages(t *testing.T) {
+	const total = 120
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		if limit > closedPositionsPageSize {
+			t.Errorf("limit %d above the page size", limit)
+		}
+		page := []ClosedPosition{}
+		for i := offset; i < min(offset+limit, total); i++ {
+			page = append(page, ClosedPosition{Asset: strconv.Itoa(i)})
+		}
+		json.NewEncoder(w).Encode(page)
+	}))
+	defer srv.Close()
+
+	client := NewClient()
+	client.baseURL = srv.URL
+	for _, tc := range []struct{ max, want int }{{0, total}, {75, 75}, {1000, total}} {
+		positions, err := client.GetAllClosedPositions(t.Context(), ClosedPositionsQueryParams{User: "0xabc"}, tc.max)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(positions) != tc.want {
+			t.Errorf("max %d: got %d positions, want %d", tc.max, len(positions), tc.want)
+		}
+		for i, p := range positions {
+			if p.Asset != strconv.Itoa(i) {
+				t.Fatalf("max %d: position %d is %s", tc.max, i, p.Asset)
+			}
+		}
+	}
+}
@@ -0,0 +1,3 @@
+// Package dataapi is a client for the Polymarket Data API
+// (https://data-api.polymarket.com).
+package dataapi
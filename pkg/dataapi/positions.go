@@ -0,0 +1,235 @@
+package dataapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/FatwaArya/pm-ingest/pkg/pmerrors"
+)
+
+// Position is an open position from the /positions endpoint
+type Position struct {
+	ProxyWallet        string  `json:"proxyWallet"`
+	Asset              string  `json:"asset"`
+	ConditionID        string  `json:"conditionId"`
+	Size               float64 `json:"size"`         // Shares held
+	AvgPrice           float64 `json:"avgPrice"`     // Average entry price
+	InitialValue       float64 `json:"initialValue"` // Cost: Size * AvgPrice
+	CurrentValue       float64 `json:"currentValue"` // Size * CurPrice
+	CashPnl            float64 `json:"cashPnl"`      // Unrealized: CurrentValue - InitialValue
+	PercentPnl         float64 `json:"percentPnl"`
+	TotalBought        float64 `json:"totalBought"`
+	RealizedPnl        float64 `json:"realizedPnl"` // From partial sells
+	PercentRealizedPnl float64 `json:"percentRealizedPnl"`
+	CurPrice           float64 `json:"curPrice"`
+	Redeemable         bool    `json:"redeemable"` // The market resolved; the position can be redeemed
+	Mergeable          bool    `json:"mergeable"`
+	Title              string  `json:"title"`
+	Slug               string  `json:"slug"`
+	Icon               string  `json:"icon"`
+	EventSlug          string  `json:"eventSlug"`
+	Outcome            string  `json:"outcome"`
+	OutcomeIndex       int     `json:"outcomeIndex"`
+	OppositeOutcome    string  `json:"oppositeOutcome"`
+	OppositeAsset      string  `json:"oppositeAsset"`
+	EndDate            string  `json:"endDate"`
+	NegativeRisk       bool    `json:"negativeRisk"`
+}
+
+// PositionsQueryParams represents query parameters for fetching open positions
+type PositionsQueryParams struct {
+	User          string   // The address of the user (required)
+	Market        []string // The conditionId of the market(s). Cannot be used with EventID
+	EventID       []int    // The event id(s)
+	Title         string   // Filter by market title
+	SizeThreshold *float64 // Minimum size (the API defaults to 1)
+	Redeemable    *bool    // Only (or no) positions of resolved markets
+	Mergeable     *bool
+	Limit         int    // The max number of positions to return (default: 100, max: 500)
+	Offset        int    // The starting index for pagination (default: 0, max: 10000)
+	SortBy        string // CURRENT, INITIAL, TOKENS, CASHPNL, PERCENTPNL, TITLE, RESOLVING, PRICE, AVGPRICE (default: TOKENS)
+	SortDirection string // ASC, DESC (default: DESC)
+}
+
+// GetPositions fetches a page of a user's open positions
+func (c *Client) GetPositions(ctx context.Context, params PositionsQueryParams) ([]Position, error) {
+	if params.User == "" {
+		return nil, fmt.Errorf("%w: user parameter is required", pmerrors.ErrInvalidArgument)
+	}
+	q := url.Values{}
+	q.Add("user", params.User)
+	if len(params.Market) > 0 {
+		q.Add("market", strings.Join(params.Market, ","))
+	}
+	for _, eventID := range params.EventID {
+		q.Add("eventId", strconv.Itoa(eventID))
+	}
+	if params.Title != "" {
+		q.Add("title", params.Title)
+	}
+	if params.SizeThreshold != nil {
+		q.Add("sizeThreshold", strconv.FormatFloat(*params.SizeThreshold, 'f', -1, 64))
+	}
+	if params.Redeemable != nil {
+		q.Add("redeemable", strconv.FormatBool(*params.Redeemable))
+	}
+	if params.Mergeable != nil {
+		q.Add("mergeable", strconv.FormatBool(*params.Mergeable))
+	}
+	if params.Limit > 0 {
+		q.Add("limit", strconv.Itoa(params.Limit))
+	}
+	if params.Offset > 0 {
+		q.Add("offset", strconv.Itoa(params.Offset))
+	}
+	if params.SortBy != "" {
+		q.Add("sortBy", params.SortBy)
+	}
+	if params.SortDirection != "" {
+		q.Add("sortDirection", params.SortDirection)
+	}
+
+	var positions []Position
+	if err := c.get(ctx, c.positionsURL, q, "positions", &positions); err != nil {
+		return nil, err
+	}
+	return positions, nil
+}
+
+// positionsPageSize is the largest page the API serves
+const positionsPageSize = 500
+
+// GetAllPositions pages through a user's open positions, largest current
+// value first, until the API runs out or max positions were fetched
+// (max <= 0 fetches everything)
+func (c *Client) GetAllPositions(ctx context.Context, user string, max int) ([]Position, error) {
+	var all []Position
+	for {
+		limit := positionsPageSize
+		if max > 0 {
+			limit = min(limit, max-len(all))
+		}
+		page, err := c.GetPositions(ctx, PositionsQueryParams{
+			User:          user,
+			Limit:         limit,
+			Offset:        len(all),
+			SortBy:        "CURRENT",
+			SortDirection: "DESC",
+		})
+		if err != nil {
+			return all, err
+		}
+		all = append(all, page...)
+		if len(page) < limit || (max > 0 && len(all) >= max) {
+			return all, nil
+		}
+	}
+}
+
+// GetValue returns the current value (USD) of a user's open positions,
+// optionally only those in the given markets (conditionIds)
+func (c *Client) GetValue(ctx context.Context, user string, markets ...string) (float64, error) {
+	if user == "" {
+		return 0, fmt.Errorf("%w: user parameter is required", pmerrors.ErrInvalidArgument)
+	}
+	q := url.Values{}
+	q.Add("user", user)
+	if len(markets) > 0 {
+		q.Add("market", strings.Join(markets, ","))
+	}
+	var values []struct {
+		User  string  `json:"user"`
+		Value float64 `json:"value"`
+	}
+	if err := c.get(ctx, c.valueURL, q, "value", &values); err != nil {
+		return 0, err
+	}
+	if len(values) == 0 {
+		return 0, nil
+	}
+	return values[0].Value, nil
+}
+
+// Holder is a wallet holding one outcome token of a market
+type Holder struct {
+	ProxyWallet  string  `json:"proxyWallet"`
+	Asset        string  `json:"asset"`
+	Amount       float64 `json:"amount"` // Shares held
+	OutcomeIndex int     `json:"outcomeIndex"`
+	Name         string  `json:"name"`
+	Pseudonym    string  `json:"pseudonym"`
+	Bio          string  `json:"bio"`
+	ProfileImage string  `json:"profileImage"`
+}
+
+// TokenHolders are the top holders of one outcome token
+type TokenHolders struct {
+	Token   string   `json:"token"`
+	Holders []Holder `json:"holders"`
+}
+
+// HoldersQueryParams represents query parameters for fetching top holders
+type HoldersQueryParams struct {
+	Market     []string // The conditionId of the market(s) (required)
+	Limit      int      // Holders per token (default: 20, max: 500)
+	MinBalance int      // Smallest balance listed (the API defaults to 1)
+}
+
+// GetHolders fetches the top holders of each outcome token of the markets
+func (c *Client) GetHolders(ctx context.Context, params HoldersQueryParams) ([]TokenHolders, error) {
+	if len(params.Market) == 0 {
+		return nil, fmt.Errorf("%w: market parameter is required", pmerrors.ErrInvalidArgument)
+	}
+	q := url.Values{}
+	q.Add("market", strings.Join(params.Market, ","))
+	if params.Limit > 0 {
+		q.Add("limit", strconv.Itoa(params.Limit))
+	}
+	if params.MinBalance > 0 {
+		q.Add("minBalance", strconv.Itoa(params.MinBalance))
+	}
+	var holders []TokenHolders
+	if err := c.get(ctx, c.holdersURL, q, "holders", &holders); err != nil {
+		return nil, err
+	}
+	return holders, nil
+}
+
+// get fetches rawURL with query q and decodes the JSON response into out;
+// what names the response in decode errors
+func (c *Client) get(ctx context.Context, rawURL string, q url.Values, what string, out any) error {
+	apiURL, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("failed to parse API URL: %w", err)
+	}
+	apiURL.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", apiURL.String(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return &pmerrors.APIError{
+			StatusCode: resp.StatusCode,
+			Body:       string(body),
+			URL:        apiURL.String(),
+		}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return pmerrors.Decode(what+" response", err)
+	}
+	return nil
+}
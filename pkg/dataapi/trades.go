@@ -0,0 +1,103 @@
+package dataapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/FatwaArya/pm-ingest/pkg/pmerrors"
+)
+
+// Trade is a trade from the /trades endpoint. The fields match the RTDS
+// activity trade payload.
+type Trade struct {
+	ProxyWallet     string  `json:"proxyWallet"`
+	Side            string  `json:"side"`
+	Asset           string  `json:"asset"`
+	ConditionID     string  `json:"conditionId"`
+	Size            float64 `json:"size"`
+	Price           float64 `json:"price"`
+	Timestamp       int64   `json:"timestamp"` // Unix seconds
+	Title           string  `json:"title"`
+	Slug            string  `json:"slug"`
+	Icon            string  `json:"icon"`
+	EventSlug       string  `json:"eventSlug"`
+	Outcome         string  `json:"outcome"`
+	OutcomeIndex    int     `json:"outcomeIndex"`
+	Name            string  `json:"name"`
+	Pseudonym       string  `json:"pseudonym"`
+	Bio             string  `json:"bio"`
+	ProfileImage    string  `json:"profileImage"`
+	TransactionHash string  `json:"transactionHash"`
+}
+
+// TradesQueryParams represents query parameters for fetching trades. Trades
+// are returned newest first; the API has no time range filter.
+type TradesQueryParams struct {
+	User      string   // Only trades of this wallet
+	Market    []string // Only trades of these conditionIds
+	Side      string   // BUY or SELL
+	TakerOnly *bool    // Only taker trades (the API defaults to true)
+	Limit     int      // The max number of trades to return (default: 100, max: 10000)
+	Offset    int      // The starting index for pagination (default: 0, max: 10000)
+}
+
+// GetTrades fetches a page of trades
+func (c *Client) GetTrades(ctx context.Context, params TradesQueryParams) ([]Trade, error) {
+	apiURL, err := url.Parse(c.tradesURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse API URL: %w", err)
+	}
+
+	q := url.Values{}
+	if params.User != "" {
+		q.Add("user", params.User)
+	}
+	if len(params.Market) > 0 {
+		q.Add("market", strings.Join(params.Market, ","))
+	}
+	if params.Side != "" {
+		q.Add("side", params.Side)
+	}
+	if params.TakerOnly != nil {
+		q.Add("takerOnly", strconv.FormatBool(*params.TakerOnly))
+	}
+	if params.Limit > 0 {
+		q.Add("limit", strconv.Itoa(params.Limit))
+	}
+	if params.Offset > 0 {
+		q.Add("offset", strconv.Itoa(params.Offset))
+	}
+	apiURL.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", apiURL.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, &pmerrors.APIError{
+			StatusCode: resp.StatusCode,
+			Body:       string(body),
+			URL:        apiURL.String(),
+		}
+	}
+
+	var trades []Trade
+	if err := json.NewDecoder(resp.Body).Decode(&trades); err != nil {
+		return nil, pmerrors.Decode("trades response", err)
+	}
+	return trades, nil
+}
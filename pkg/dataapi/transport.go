@@ -0,0 +1,317 @@
+package dataapi
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/FatwaArya/pm-ingest/pkg/clock"
+	"github.com/FatwaArya/pm-ingest/pkg/pmerrors"
+)
+
+// Policy controls how requests to the Data API are paced, retried and shed
+type Policy struct {
+	Rate             float64       // Requests per second, shared by every client; 0 is unlimited
+	Burst            int           // Requests sent at once before Rate applies (at least 1)
+	MaxRetries       int           // Retries of rate limits, 5xx responses and network errors
+	InitialBackoff   time.Duration // Delay before the first retry, doubling up to MaxBackoff
+	MaxBackoff       time.Duration // Also the longest Retry-After waited for; a longer one fails the request
+	AttemptTimeout   time.Duration // Per attempt, so retries get their own; 0 means none
+	BreakerThreshold int           // Consecutive failures of an endpoint that open its circuit; 0 disables the breaker
+	BreakerCooldown  time.Duration // How long an open circuit rejects requests before letting a trial through
+}
+
+// DefaultPolicy stays below the Data API's rate limits, retries 3 times
+// from 500ms and opens an endpoint's circuit for 30s after 5 failures
+func DefaultPolicy() Policy {
+	return Policy{
+		Rate:             15,
+		Burst:            30,
+		MaxRetries:       3,
+		InitialBackoff:   500 * time.Millisecond,
+		MaxBackoff:       10 * time.Second,
+		AttemptTimeout:   10 * time.Second,
+		BreakerThreshold: 5,
+		BreakerCooldown:  30 * time.Second,
+	}
+}
+
+// Transport is an http.RoundTripper that paces requests with a token bucket,
+// retries transient failures with backoff, honoring Retry-After, and stops
+// sending to an endpoint (host and path) that keeps failing. One Transport
+// shared by several clients shares the rate limit.
+type Transport struct {
+	base    http.RoundTripper
+	policy  Policy
+	clock   clock.Clock
+	limiter *tokenBucket
+
+	mu        sync.Mutex
+	breakers  map[string]*breaker
+	onBreaker []func(endpoint string, open bool)
+}
+
+// NewTransport creates a transport sending requests with base (nil uses
+// http.DefaultTransport at the time of the request)
+func NewTransport(base http.RoundTripper, policy Policy) *Transport {
+	return &Transport{
+		base:     base,
+		policy:   policy,
+		clock:    clock.Real,
+		limiter:  &tokenBucket{rate: policy.Rate, burst: float64(max(policy.Burst, 1)), tokens: float64(max(policy.Burst, 1))},
+		breakers: make(map[string]*breaker),
+	}
+}
+
+// SetClock replaces the clock driving the rate limit, backoff and breaker
+func (t *Transport) SetClock(c clock.Clock) {
+	t.clock = clock.OrReal(c)
+}
+
+// OnBreaker registers fn to be called when an endpoint's circuit opens or
+// closes again
+func (t *Transport) OnBreaker(fn func(endpoint string, open bool)) {
+	t.onBreaker = append(t.onBreaker, fn)
+}
+
+// RoundTrip sends req, waiting for the rate limit and retrying as the
+// policy allows. Only requests without a body (or with GetBody) are retried.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	endpoint := req.URL.Host + req.URL.Path
+	retryable := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+
+	for attempt := 0; ; attempt++ {
+		if err := t.limiter.wait(ctx, t.clock); err != nil {
+			return nil, err
+		}
+		if !t.allow(endpoint) {
+			return nil, fmt.Errorf("%s: %w", endpoint, pmerrors.ErrCircuitOpen)
+		}
+
+		resp, err := t.send(req, attempt)
+		if ctx.Err() != nil {
+			// Cancelled by the caller; says nothing about the endpoint
+			t.record(endpoint, false)
+			return resp, err
+		}
+		t.record(endpoint, err != nil || resp.StatusCode >= 500)
+
+		var retryAfter time.Duration
+		if err == nil {
+			if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < 500 {
+				return resp, nil
+			}
+			retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), t.clock.Now())
+			if resp.StatusCode == http.StatusTooManyRequests && retryAfter > 0 {
+				// Every request is over the limit, not just this one
+				t.limiter.pause(t.clock.Now().Add(retryAfter))
+			}
+		}
+		if !retryable || attempt >= t.policy.MaxRetries || retryAfter > t.policy.MaxBackoff {
+			return resp, err
+		}
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		delay := max(t.backoff(attempt), retryAfter)
+		timer := t.clock.NewTimer(delay)
+		select {
+		case <-timer.C():
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// send makes one attempt, with its own timeout
+func (t *Transport) send(req *http.Request, attempt int) (*http.Response, error) {
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if attempt > 0 && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		req = req.Clone(req.Context())
+		req.Body = body
+	}
+	if t.policy.AttemptTimeout <= 0 {
+		return base.RoundTrip(req)
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), t.policy.AttemptTimeout)
+	resp, err := base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	// The timeout covers reading the body too
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// backoff returns the delay before retry attempt+1
+func (t *Transport) backoff(attempt int) time.Duration {
+	delay := t.policy.InitialBackoff
+	for i := 0; i < attempt && delay < t.policy.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if t.policy.MaxBackoff > 0 {
+		delay = min(delay, t.policy.MaxBackoff)
+	}
+	return delay
+}
+
+// allow reports whether a request to endpoint may be sent. An open circuit
+// lets one trial request through once its cooldown is over.
+func (t *Transport) allow(endpoint string) bool {
+	if t.policy.BreakerThreshold <= 0 {
+		return true
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	b := t.breakers[endpoint]
+	if b == nil || b.failures < t.policy.BreakerThreshold {
+		return true
+	}
+	if t.clock.Now().Before(b.openUntil) || b.trial {
+		return false
+	}
+	b.trial = true
+	return true
+}
+
+// record counts the outcome of a request to endpoint
+func (t *Transport) record(endpoint string, failed bool) {
+	if t.policy.BreakerThreshold <= 0 {
+		return
+	}
+	t.mu.Lock()
+	b := t.breakers[endpoint]
+	if b == nil {
+		b = &breaker{}
+		t.breakers[endpoint] = b
+	}
+	wasOpen := b.failures >= t.policy.BreakerThreshold
+	b.trial = false
+	if failed {
+		b.failures++
+	} else {
+		b.failures = 0
+	}
+	open := b.failures >= t.policy.BreakerThreshold
+	if open && failed {
+		b.openUntil = t.clock.Now().Add(t.policy.BreakerCooldown)
+	}
+	hooks := t.onBreaker
+	t.mu.Unlock()
+
+	if open != wasOpen {
+		for _, fn := range hooks {
+			fn(endpoint, open)
+		}
+	}
+}
+
+// breaker is the circuit state of one endpoint; its circuit is open while
+// failures is at the policy's threshold or above
+type breaker struct {
+	failures  int       // Consecutive
+	openUntil time.Time // End of the cooldown
+	trial     bool      // A request is in flight after the cooldown
+}
+
+// tokenBucket paces requests to rate per second, allowing bursts
+type tokenBucket struct {
+	rate  float64
+	burst float64
+
+	mu          sync.Mutex
+	tokens      float64
+	last        time.Time
+	pausedUntil time.Time // Set by a Retry-After
+}
+
+// wait blocks until a request may be sent or ctx is done
+func (b *tokenBucket) wait(ctx context.Context, c clock.Clock) error {
+	for {
+		b.mu.Lock()
+		delay := b.reserve(c.Now())
+		b.mu.Unlock()
+		if delay <= 0 {
+			return nil
+		}
+		timer := c.NewTimer(delay)
+		select {
+		case <-timer.C():
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// reserve takes a token, or returns how long until one may be available;
+// called with mu held
+func (b *tokenBucket) reserve(now time.Time) time.Duration {
+	if now.Before(b.pausedUntil) {
+		return b.pausedUntil.Sub(now)
+	}
+	if b.rate <= 0 {
+		return 0
+	}
+	if !b.last.IsZero() {
+		b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	}
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+// pause holds every request until until
+func (b *tokenBucket) pause(until time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if until.After(b.pausedUntil) {
+		b.pausedUntil = until
+	}
+}
+
+// parseRetryAfter reads a Retry-After header, in seconds or an HTTP date
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return max(time.Duration(seconds)*time.Second, 0)
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(at.Sub(now), 0)
+	}
+	return 0
+}
+
+// cancelBody releases an attempt's timeout once the body is closed
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
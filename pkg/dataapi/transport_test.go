@@ -0,0 +1,117 @@
+package dataapi
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/FatwaArya/pm-ingest/pkg/pmerrors"
+)
+
+func testPolicy() Policy {
+	return Policy{
+		Burst:            1,
+		MaxRetries:       2,
+		InitialBackoff:   time.Millisecond,
+		MaxBackoff:       5 * time.Millisecond,
+		AttemptTimeout:   time.Second,
+		BreakerThreshold: 3,
+		BreakerCooldown:  time.Hour,
+	}
+}
+
+func TestTransportRetriesTransientFailures(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch calls.Add(1) {
+		case 1:
+			w.WriteHeader(http.StatusBadGateway)
+		case 2:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			w.Write([]byte("[]"))
+		}
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: NewTransport(nil, testPolicy())}
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || calls.Load() != 3 {
+		t.Fatalf("status %d after %d calls, want 200 after 3", resp.StatusCode, calls.Load())
+	}
+}
+
+func TestTransportGivesUpOnLongRetryAfter(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: NewTransport(nil, testPolicy())}
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests || calls.Load() != 1 {
+		t.Fatalf("status %d after %d calls, want 429 after 1", resp.StatusCode, calls.Load())
+	}
+}
+
+func TestTransportOpensCircuit(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	transport := NewTransport(nil, testPolicy())
+	var opened atomic.Bool
+	transport.OnBreaker(func(endpoint string, open bool) { opened.Store(open) })
+	client := &http.Client{Transport: transport}
+
+	// Three attempts fail and open the circuit
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if !opened.Load() {
+		t.Fatal("circuit not opened")
+	}
+
+	_, err = client.Get(srv.URL)
+	if !errors.Is(err, pmerrors.ErrCircuitOpen) {
+		t.Fatalf("err = %v, want ErrCircuitOpen", err)
+	}
+	if calls.Load() != 3 {
+		t.Fatalf("%d calls, want 3", calls.Load())
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for value, want := range map[string]time.Duration{
+		"":                              0,
+		"3":                             3 * time.Second,
+		"-1":                            0,
+		"soon":                          0,
+		"Wed, 01 Jan 2025 00:00:10 GMT": 10 * time.Second,
+	} {
+		if got := parseRetryAfter(value, now); got != want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", value, got, want)
+		}
+	}
+}
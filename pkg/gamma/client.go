@@ -0,0 +1,227 @@
+package gamma
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/FatwaArya/pm-ingest/pkg/pmerrors"
+)
+
+const (
+	BaseURL = "https://gamma-api.polymarket.com"
+)
+
+// Market is a market as returned by the Gamma API
+type Market struct {
+	ID            string  `json:"id"`
+	Question      string  `json:"question"`
+	ConditionID   string  `json:"conditionId"`
+	Slug          string  `json:"slug"`
+	Category      string  `json:"category,omitempty"`
+	Description   string  `json:"description,omitempty"`
+	StartDate     string  `json:"startDate,omitempty"`
+	EndDate       string  `json:"endDate,omitempty"`
+	CreatedAt     string  `json:"createdAt,omitempty"`
+	Outcomes      string  `json:"outcomes,omitempty"`      // JSON-encoded array, e.g. "[\"Yes\", \"No\"]"
+	OutcomePrices string  `json:"outcomePrices,omitempty"` // JSON-encoded array of decimal strings, aligned with Outcomes
+	ClobTokenIDs  string  `json:"clobTokenIds,omitempty"`  // JSON-encoded array of token IDs
+	UMAStatus     string  `json:"umaResolutionStatus,omitempty"`
+	Liquidity     float64 `json:"liquidityNum,omitempty"`
+	Volume        float64 `json:"volumeNum,omitempty"`
+	Active        bool    `json:"active"`
+	Closed        bool    `json:"closed"`
+	Tags          []Tag   `json:"tags,omitempty"`   // Only with MarketsQueryParams.IncludeTag
+	Events        []Event `json:"events,omitempty"` // The event the market belongs to, without its markets
+}
+
+// resolvedPrice is the outcome price at or above which a closed market is
+// considered resolved to that outcome
+const resolvedPrice = 0.99
+
+// TagLabels returns the labels of the market's tags
+func (m *Market) TagLabels() []string {
+	if len(m.Tags) == 0 {
+		return nil
+	}
+	labels := make([]string, len(m.Tags))
+	for i, t := range m.Tags {
+		labels[i] = t.Label
+	}
+	return labels
+}
+
+// OutcomeNames decodes Outcomes
+func (m *Market) OutcomeNames() ([]string, error) {
+	var names []string
+	if m.Outcomes == "" {
+		return names, nil
+	}
+	if err := json.Unmarshal([]byte(m.Outcomes), &names); err != nil {
+		return nil, pmerrors.Decode("market outcomes", err)
+	}
+	return names, nil
+}
+
+// TokenIDs decodes ClobTokenIDs, aligned with Outcomes
+func (m *Market) TokenIDs() ([]string, error) {
+	var ids []string
+	if m.ClobTokenIDs == "" {
+		return ids, nil
+	}
+	if err := json.Unmarshal([]byte(m.ClobTokenIDs), &ids); err != nil {
+		return nil, pmerrors.Decode("market token IDs", err)
+	}
+	return ids, nil
+}
+
+// Prices decodes OutcomePrices
+func (m *Market) Prices() ([]float64, error) {
+	var raw []string
+	if m.OutcomePrices == "" {
+		return nil, nil
+	}
+	if err := json.Unmarshal([]byte(m.OutcomePrices), &raw); err != nil {
+		return nil, pmerrors.Decode("market outcome prices", err)
+	}
+	prices := make([]float64, len(raw))
+	for i, p := range raw {
+		v, err := strconv.ParseFloat(p, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse outcome price %q: %w", p, pmerrors.ErrSchemaMismatch)
+		}
+		prices[i] = v
+	}
+	return prices, nil
+}
+
+// WinningOutcome returns the index of the outcome a closed market resolved
+// to, and false while the market is open or its prices don't settle on a
+// single outcome (e.g. a 50/50 resolution)
+func (m *Market) WinningOutcome() (int, bool) {
+	if !m.Closed {
+		return 0, false
+	}
+	prices, err := m.Prices()
+	if err != nil {
+		return 0, false
+	}
+	for i, p := range prices {
+		if p >= resolvedPrice {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// MarketsQueryParams represents query parameters for listing markets
+type MarketsQueryParams struct {
+	ConditionIDs []string // Condition ID(s) of the market(s)
+	Slugs        []string // Market slug(s)
+	Closed       *bool    // Only closed (true) or open (false) markets
+	IncludeTag   bool     // Fill Market.Tags in
+	Limit        int      // The max number of markets to return
+	Offset       int      // The starting index for pagination
+}
+
+// Client handles API calls to the Polymarket Gamma API
+type Client struct {
+	httpClient *http.Client
+	baseURL    string
+}
+
+// NewClient creates a new Gamma API client
+func NewClient() *Client {
+	return &Client{
+		httpClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+		baseURL: BaseURL,
+	}
+}
+
+// GetMarkets lists markets matching the query parameters
+func (c *Client) GetMarkets(ctx context.Context, params MarketsQueryParams) ([]Market, error) {
+	q := url.Values{}
+	for _, id := range params.ConditionIDs {
+		q.Add("condition_ids", id)
+	}
+	for _, slug := range params.Slugs {
+		q.Add("slug", slug)
+	}
+	if params.Closed != nil {
+		q.Add("closed", fmt.Sprintf("%t", *params.Closed))
+	}
+	if params.IncludeTag {
+		q.Add("include_tag", "true")
+	}
+	if params.Limit > 0 {
+		q.Add("limit", fmt.Sprintf("%d", params.Limit))
+	}
+	if params.Offset > 0 {
+		q.Add("offset", fmt.Sprintf("%d", params.Offset))
+	}
+
+	var markets []Market
+	if err := c.get(ctx, "/markets", q, "markets response", &markets); err != nil {
+		return nil, err
+	}
+	return markets, nil
+}
+
+// GetMarketByConditionID returns the market with the given condition ID,
+// with its tags
+func (c *Client) GetMarketByConditionID(ctx context.Context, conditionID string) (*Market, error) {
+	if conditionID == "" {
+		return nil, fmt.Errorf("%w: condition ID is required", pmerrors.ErrInvalidArgument)
+	}
+	markets, err := c.GetMarkets(ctx, MarketsQueryParams{ConditionIDs: []string{conditionID}, IncludeTag: true})
+	if err != nil {
+		return nil, err
+	}
+	for i := range markets {
+		if markets[i].ConditionID == conditionID {
+			return &markets[i], nil
+		}
+	}
+	return nil, &pmerrors.APIError{StatusCode: http.StatusNotFound, Body: "market not found", URL: c.baseURL + "/markets"}
+}
+
+// get fetches path with query q and decodes the JSON response into out
+func (c *Client) get(ctx context.Context, path string, q url.Values, what string, out any) error {
+	apiURL, err := url.Parse(c.baseURL + path)
+	if err != nil {
+		return fmt.Errorf("failed to parse API URL: %w", err)
+	}
+	apiURL.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", apiURL.String(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return &pmerrors.APIError{
+			StatusCode: resp.StatusCode,
+			Body:       string(body),
+			URL:        apiURL.String(),
+		}
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return pmerrors.Decode(what, err)
+	}
+	return nil
+}
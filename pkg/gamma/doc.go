@@ -0,0 +1,3 @@
+// Package gamma is a client for the Polymarket Gamma API
+// (https://gamma-api.polymarket.com), which serves market and event metadata.
+package gamma
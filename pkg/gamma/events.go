@@ -0,0 +1,115 @@
+package gamma
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/FatwaArya/pm-ingest/pkg/pmerrors"
+)
+
+// Tag is a label grouping markets and events, e.g. "Politics"
+type Tag struct {
+	ID    string `json:"id"`
+	Label string `json:"label"`
+	Slug  string `json:"slug"`
+}
+
+// Event groups related markets, e.g. the candidates of an election
+type Event struct {
+	ID          string   `json:"id"`
+	Slug        string   `json:"slug"`
+	Title       string   `json:"title"`
+	Description string   `json:"description,omitempty"`
+	Category    string   `json:"category,omitempty"`
+	StartDate   string   `json:"startDate,omitempty"`
+	EndDate     string   `json:"endDate,omitempty"`
+	Liquidity   float64  `json:"liquidity,omitempty"`
+	Volume      float64  `json:"volume,omitempty"`
+	Active      bool     `json:"active"`
+	Closed      bool     `json:"closed"`
+	NegRisk     bool     `json:"negRisk,omitempty"`
+	Markets     []Market `json:"markets,omitempty"`
+	Tags        []Tag    `json:"tags,omitempty"`
+}
+
+// EventsQueryParams represents query parameters for listing events
+type EventsQueryParams struct {
+	IDs    []string // Event ID(s)
+	Slugs  []string // Event slug(s)
+	TagID  string   // Only events with this tag
+	Closed *bool    // Only closed (true) or open (false) events
+	Limit  int      // The max number of events to return
+	Offset int      // The starting index for pagination
+}
+
+// GetEvents lists events matching the query parameters, with their markets
+// and tags
+func (c *Client) GetEvents(ctx context.Context, params EventsQueryParams) ([]Event, error) {
+	q := url.Values{}
+	for _, id := range params.IDs {
+		q.Add("id", id)
+	}
+	for _, slug := range params.Slugs {
+		q.Add("slug", slug)
+	}
+	if params.TagID != "" {
+		q.Add("tag_id", params.TagID)
+	}
+	if params.Closed != nil {
+		q.Add("closed", strconv.FormatBool(*params.Closed))
+	}
+	if params.Limit > 0 {
+		q.Add("limit", strconv.Itoa(params.Limit))
+	}
+	if params.Offset > 0 {
+		q.Add("offset", strconv.Itoa(params.Offset))
+	}
+
+	var events []Event
+	if err := c.get(ctx, "/events", q, "events response", &events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// GetEventBySlug returns the event with the given slug
+func (c *Client) GetEventBySlug(ctx context.Context, slug string) (*Event, error) {
+	if slug == "" {
+		return nil, fmt.Errorf("%w: event slug is required", pmerrors.ErrInvalidArgument)
+	}
+	events, err := c.GetEvents(ctx, EventsQueryParams{Slugs: []string{slug}})
+	if err != nil {
+		return nil, err
+	}
+	for i := range events {
+		if events[i].Slug == slug {
+			return &events[i], nil
+		}
+	}
+	return nil, &pmerrors.APIError{StatusCode: http.StatusNotFound, Body: "event not found", URL: c.baseURL + "/events"}
+}
+
+// TagsQueryParams represents query parameters for listing tags
+type TagsQueryParams struct {
+	Limit  int // The max number of tags to return
+	Offset int // The starting index for pagination
+}
+
+// GetTags lists tags
+func (c *Client) GetTags(ctx context.Context, params TagsQueryParams) ([]Tag, error) {
+	q := url.Values{}
+	if params.Limit > 0 {
+		q.Add("limit", strconv.Itoa(params.Limit))
+	}
+	if params.Offset > 0 {
+		q.Add("offset", strconv.Itoa(params.Offset))
+	}
+	var tags []Tag
+	if err := c.get(ctx, "/tags", q, "tags response", &tags); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
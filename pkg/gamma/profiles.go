@@ -0,0 +1,47 @@
+package gamma
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/FatwaArya/pm-ingest/pkg/pmerrors"
+)
+
+// PublicProfile is a user's public profile. ProxyWallet is the wallet that
+// trades; Users are the Polymarket accounts controlling it.
+type PublicProfile struct {
+	ProxyWallet           string        `json:"proxyWallet"`
+	Name                  string        `json:"name,omitempty"`
+	Pseudonym             string        `json:"pseudonym,omitempty"`
+	Bio                   string        `json:"bio,omitempty"`
+	ProfileImage          string        `json:"profileImage,omitempty"`
+	DisplayUsernamePublic bool          `json:"displayUsernamePublic"`
+	XUsername             string        `json:"xUsername,omitempty"`
+	VerifiedBadge         bool          `json:"verifiedBadge"`
+	CreatedAt             string        `json:"createdAt,omitempty"`
+	Users                 []ProfileUser `json:"users,omitempty"`
+}
+
+// ProfileUser is a Polymarket account attached to a profile
+type ProfileUser struct {
+	ID      string `json:"id"`
+	Creator bool   `json:"creator"`
+	Mod     bool   `json:"mod"`
+}
+
+// GetPublicProfile returns the public profile of a wallet address (proxy
+// wallet or owner)
+func (c *Client) GetPublicProfile(ctx context.Context, address string) (*PublicProfile, error) {
+	if address == "" {
+		return nil, fmt.Errorf("%w: address is required", pmerrors.ErrInvalidArgument)
+	}
+	q := url.Values{}
+	q.Add("address", address)
+
+	var profile PublicProfile
+	if err := c.get(ctx, "/public-profile", q, "public profile", &profile); err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
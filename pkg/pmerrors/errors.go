@@ -0,0 +1,84 @@
+// Package pmerrors defines the error kinds shared by the Polymarket clients,
+// so callers can branch with errors.Is / errors.As instead of matching strings.
+package pmerrors
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+)
+
+var (
+	// ErrNotConnected is returned when writing to a WebSocket that isn't connected
+	ErrNotConnected = errors.New("not connected")
+	// ErrRateLimited is matched by APIErrors with status 429
+	ErrRateLimited = errors.New("rate limited")
+	// ErrSchemaMismatch means a message was valid JSON but didn't match the expected types
+	ErrSchemaMismatch = errors.New("schema mismatch")
+	// ErrMalformedMessage means a message was not valid JSON
+	ErrMalformedMessage = errors.New("malformed message")
+	// ErrInvalidArgument means a request was rejected before being sent
+	ErrInvalidArgument = errors.New("invalid argument")
+	// ErrCircuitOpen means a request wasn't sent because its endpoint kept
+	// failing recently
+	ErrCircuitOpen = errors.New("circuit open")
+)
+
+// APIError is returned when an HTTP API responds with a non-2xx status
+type APIError struct {
+	StatusCode int
+	Body       string
+	URL        string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("API returned status %d: %s", e.StatusCode, e.Body)
+}
+
+// Is lets errors.Is(err, ErrRateLimited) match 429 responses
+func (e *APIError) Is(target error) bool {
+	return target == ErrRateLimited && e.StatusCode == http.StatusTooManyRequests
+}
+
+// Retryable reports whether the request may succeed if repeated
+func (e *APIError) Retryable() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// IsRetryable classifies err for retry policies: rate limits, 5xx responses
+// and network errors are retryable; schema/parse errors and other 4xx are not
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Retryable()
+	}
+	if errors.Is(err, ErrRateLimited) || errors.Is(err, ErrNotConnected) {
+		return true
+	}
+	// Timeouts, refused/reset connections and the like
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// Decode wraps a JSON decoding error of what with the matching error kind:
+// type errors become ErrSchemaMismatch, syntax errors ErrMalformedMessage
+func Decode(what string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+	switch {
+	case errors.As(err, &typeErr):
+		return fmt.Errorf("failed to parse %s: %w: %w", what, ErrSchemaMismatch, err)
+	case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF):
+		return fmt.Errorf("failed to parse %s: %w: %w", what, ErrMalformedMessage, err)
+	}
+	return fmt.Errorf("failed to parse %s: %w", what, err)
+}
@@ -0,0 +1,116 @@
+package rtds
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/FatwaArya/pm-ingest/pkg/pmerrors"
+)
+
+// topicTypes lists the message types each topic accepts
+var topicTypes = map[string][]string{
+	TopicActivity: {TypeTrades, TypeOrders, TypeOrdersMatched, TypeAll},
+	TopicComments: {TypeCommentCreated, TypeCommentRemoved, TypeAll},
+	TopicClobUser: {TypeOrder, TypeTrade, TypeAll},
+
+	TopicCryptoPrices:          {TypeUpdate, TypeAll},
+	TopicCryptoPricesChainlink: {TypeUpdate, TypeAll},
+	TopicRFQ: {
+		TypeRequestCreated, TypeRequestEdited, TypeRequestCanceled, TypeRequestExpired,
+		TypeQuoteCreated, TypeQuoteEdited, TypeQuoteCanceled, TypeQuoteExpired, TypeAll,
+	},
+}
+
+// SubscriptionBuilder builds a Subscription and validates it, since the
+// server silently ignores subscriptions it doesn't understand
+//
+//	sub, err := rtds.NewSubscription(rtds.TopicActivity).
+//		Type(rtds.TypeTrades).
+//		Filters(`{"event_slug":"us-election"}`).
+//		Build()
+type SubscriptionBuilder struct {
+	sub Subscription
+}
+
+// NewSubscription starts a subscription to topic for all message types
+func NewSubscription(topic string) *SubscriptionBuilder {
+	return &SubscriptionBuilder{sub: Subscription{Topic: topic, Type: TypeAll}}
+}
+
+// Type restricts the subscription to one message type
+func (b *SubscriptionBuilder) Type(messageType string) *SubscriptionBuilder {
+	b.sub.Type = messageType
+	return b
+}
+
+// Auth sets the credentials required by clob_user
+func (b *SubscriptionBuilder) Auth(auth *Auth) *SubscriptionBuilder {
+	if auth == nil {
+		b.sub.ClobAuth = nil
+		return b
+	}
+	b.sub.ClobAuth = &ClobAuth{
+		Key:        auth.APIKey,
+		Secret:     auth.Secret,
+		Passphrase: auth.Passphrase,
+	}
+	return b
+}
+
+// Filters sets the server-side filter, a JSON object or array
+func (b *SubscriptionBuilder) Filters(filters string) *SubscriptionBuilder {
+	b.sub.Filters = filters
+	return b
+}
+
+// Build validates and returns the subscription
+func (b *SubscriptionBuilder) Build() (Subscription, error) {
+	if err := b.sub.Validate(); err != nil {
+		return Subscription{}, err
+	}
+	return b.sub, nil
+}
+
+// Validate checks the topic/type combination, that auth is present exactly
+// when the topic requires it, and that filters are well-formed JSON (a
+// symbol list for crypto_prices).
+// Errors wrap pmerrors.ErrInvalidArgument.
+func (s Subscription) Validate() error {
+	types, ok := topicTypesFor(s.Topic)
+	if !ok {
+		return invalidSubscription(s, "unknown topic")
+	}
+	if !slices.Contains(types, s.Type) {
+		return invalidSubscription(s, fmt.Sprintf("type %q is not valid for topic (want one of %v)", s.Type, types))
+	}
+
+	if s.Topic == TopicClobUser {
+		if s.ClobAuth == nil {
+			return invalidSubscription(s, "clob_auth is required")
+		}
+		if s.ClobAuth.Key == "" || s.ClobAuth.Secret == "" || s.ClobAuth.Passphrase == "" {
+			return invalidSubscription(s, "clob_auth needs key, secret and passphrase")
+		}
+	} else if s.ClobAuth != nil {
+		return invalidSubscription(s, "clob_auth is only accepted by clob_user")
+	}
+
+	// crypto_prices filters by a comma-separated list of symbols
+	if s.Filters != "" && s.Topic != TopicCryptoPrices {
+		var filters any
+		if err := json.Unmarshal([]byte(s.Filters), &filters); err != nil {
+			return invalidSubscription(s, fmt.Sprintf("filters are not valid JSON: %v", err))
+		}
+		switch filters.(type) {
+		case map[string]any, []any:
+		default:
+			return invalidSubscription(s, "filters must be a JSON object or array")
+		}
+	}
+	return nil
+}
+
+func invalidSubscription(s Subscription, reason string) error {
+	return fmt.Errorf("%w: subscription %s/%s: %s", pmerrors.ErrInvalidArgument, s.Topic, s.Type, reason)
+}
@@ -0,0 +1,83 @@
+package rtds
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// Channel adds support for an RTDS topic without changes to the client or
+// parser: once registered, subscriptions to the topic validate and handlers
+// implementing ChannelHandler receive its messages parsed.
+type Channel interface {
+	// Topic is the RTDS topic name
+	Topic() string
+	// Types lists the message types the topic accepts, including TypeAll
+	Types() []string
+	// Subscriptions builds the subscriptions to send for the topic
+	Subscriptions() ([]Subscription, error)
+	// Parse decodes the payload of one of the topic's messages. A nil
+	// result with a nil error skips the message.
+	Parse(messageType string, payload json.RawMessage) (any, error)
+}
+
+// ChannelHandler can optionally be implemented by an EventHandler to
+// receive messages of registered channels. For built-in topics that are
+// also registered it replaces the typed callbacks.
+type ChannelHandler interface {
+	OnChannel(topic, messageType string, message any)
+}
+
+var (
+	channelsMu sync.RWMutex
+	channels   = make(map[string]Channel)
+)
+
+// RegisterChannel registers ch for its topic, replacing any channel
+// registered for it before. Register channels before connecting.
+func RegisterChannel(ch Channel) {
+	channelsMu.Lock()
+	defer channelsMu.Unlock()
+	channels[ch.Topic()] = ch
+}
+
+// LookupChannel returns the channel registered for topic
+func LookupChannel(topic string) (Channel, bool) {
+	channelsMu.RLock()
+	defer channelsMu.RUnlock()
+	ch, ok := channels[topic]
+	return ch, ok
+}
+
+// topicTypesFor returns the message types topic accepts, built in or
+// registered
+func topicTypesFor(topic string) ([]string, bool) {
+	if types, ok := topicTypes[topic]; ok {
+		return types, true
+	}
+	if ch, ok := LookupChannel(topic); ok {
+		return ch.Types(), true
+	}
+	return nil, false
+}
+
+// dispatchChannel passes a message of a registered topic to the handler,
+// reporting whether it was handled
+func dispatchChannel(incoming *IncomingMessage, handler EventHandler) bool {
+	chHandler, ok := handler.(ChannelHandler)
+	if !ok {
+		return false
+	}
+	ch, ok := LookupChannel(incoming.Topic)
+	if !ok {
+		return false
+	}
+	message, err := ch.Parse(incoming.Type, incoming.Payload)
+	if err != nil {
+		handler.OnError(err)
+		return true
+	}
+	if message != nil {
+		chHandler.OnChannel(incoming.Topic, incoming.Type, message)
+	}
+	return true
+}
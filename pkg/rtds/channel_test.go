@@ -0,0 +1,81 @@
+package rtds
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// quoteChannel is a made-up topic for exercising the channel registry
+type quoteChannel struct{}
+
+type quote struct {
+	Asset string  `json:"asset"`
+	Bid   float64 `json:"bid"`
+}
+
+func (quoteChannel) Topic() string   { return "test_quotes" }
+func (quoteChannel) Types() []string { return []string{"quote", TypeAll} }
+
+func (c quoteChannel) Subscriptions() ([]Subscription, error) {
+	sub, err := NewSubscription(c.Topic()).Build()
+	return []Subscription{sub}, err
+}
+
+func (quoteChannel) Parse(messageType string, payload json.RawMessage) (any, error) {
+	if messageType != "quote" {
+		return nil, nil
+	}
+	var q quote
+	err := json.Unmarshal(payload, &q)
+	return &q, err
+}
+
+type channelRecorder struct {
+	NopHandler
+	topics   []string
+	messages []any
+}
+
+func (h *channelRecorder) OnChannel(topic, _ string, message any) {
+	h.topics = append(h.topics, topic)
+	h.messages = append(h.messages, message)
+}
+
+func TestRegisteredChannel(t *testing.T) {
+	if _, err := NewSubscription("test_quotes").Build(); err == nil {
+		t.Fatal("subscription to an unregistered topic validated")
+	}
+	RegisterChannel(quoteChannel{})
+	t.Cleanup(func() {
+		channelsMu.Lock()
+		delete(channels, "test_quotes")
+		channelsMu.Unlock()
+	})
+
+	subs, err := quoteChannel{}.Subscriptions()
+	if err != nil || len(subs) != 1 {
+		t.Fatalf("Subscriptions() = %v, %v", subs, err)
+	}
+	if _, err := NewSubscription("test_quotes").Type("trades").Build(); err == nil {
+		t.Error("subscription with a type the channel doesn't accept validated")
+	}
+
+	h := &channelRecorder{}
+	Dispatch([]byte(`{"topic":"test_quotes","type":"quote","payload":{"asset":"1","bid":0.4}}`), h)
+	Dispatch([]byte(`{"topic":"test_quotes","type":"heartbeat","payload":{}}`), h)
+	if len(h.messages) != 1 || h.topics[0] != "test_quotes" {
+		t.Fatalf("got %d channel messages, want 1", len(h.messages))
+	}
+	if q, ok := h.messages[0].(*quote); !ok || q.Bid != 0.4 {
+		t.Errorf("message = %#v, want parsed quote", h.messages[0])
+	}
+
+	// Handlers without OnChannel see the topic as unknown
+	var unknown int
+	Dispatch([]byte(`{"topic":"test_quotes","type":"quote","payload":{}}`), HandlerFuncs{
+		Unknown: func(*IncomingMessage) { unknown++ },
+	})
+	if unknown != 1 {
+		t.Errorf("unknown = %d, want 1", unknown)
+	}
+}
@@ -0,0 +1,336 @@
+package rtds
+
+import (
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/FatwaArya/pm-ingest/pkg/clock"
+	"github.com/FatwaArya/pm-ingest/pkg/pmerrors"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// WebSocket URL for Polymarket real-time data
+	WsURL        = "wss://ws-live-data.polymarket.com"
+	PingInterval = 5 * time.Second
+)
+
+// MessageCallback is a function type for handling incoming messages
+type MessageCallback func(message []byte)
+
+// Logger receives connection-level events (connect, subscribe, ping errors).
+// *log.Logger satisfies it; a nil Logger disables logging.
+type Logger interface {
+	Printf(format string, args ...any)
+}
+
+// WebSocketClient manages the WebSocket connection to Polymarket
+type WebSocketClient struct {
+	url           string
+	subscriptions []Subscription
+	handler       EventHandler
+	logger        Logger
+	pingInterval  time.Duration
+	dialer        *websocket.Dialer
+	reconnect     ReconnectPolicy
+	messageBuffer int
+	clock         clock.Clock
+	conn          *websocket.Conn
+	mu            sync.RWMutex
+	done          chan struct{}
+	closed        atomic.Bool
+	reconnects    atomic.Uint64
+	subscribed    atomic.Bool
+	lastMessage   atomic.Int64 // Unix nanoseconds
+}
+
+// ConnectionStatus is the state of a client's connection
+type ConnectionStatus struct {
+	Connected   bool      `json:"connected"`   // Subscribed and reading
+	LastMessage time.Time `json:"lastMessage"` // Any message, pongs included; zero before the first
+}
+
+// NewWebSocketClient creates a new WebSocket connection handler
+func NewWebSocketClient(opts ...Option) *WebSocketClient {
+	w := &WebSocketClient{
+		url:          WsURL,
+		pingInterval: PingInterval,
+		dialer:       websocket.DefaultDialer,
+		reconnect:    NoReconnect,
+		clock:        clock.Real,
+		done:         make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w
+}
+
+// logf logs through the configured logger, if any
+func (w *WebSocketClient) logf(format string, args ...any) {
+	if w.logger != nil {
+		w.logger.Printf(format, args...)
+	}
+}
+
+// Connect establishes the WebSocket connection
+func (w *WebSocketClient) Connect() error {
+	w.logf("Connecting to %s", w.url)
+
+	conn, _, err := w.dialer.Dial(w.url, nil)
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	if w.conn != nil {
+		w.conn.Close() // Replace a broken connection on reconnect
+	}
+	w.conn = conn
+	w.mu.Unlock()
+
+	return nil
+}
+
+// Subscribe validates the subscriptions and sends the subscription message
+func (w *WebSocketClient) Subscribe() error {
+	for _, sub := range w.subscriptions {
+		if err := sub.Validate(); err != nil {
+			return err
+		}
+	}
+
+	msg := SubscriptionMessage{
+		Action:        "subscribe",
+		Subscriptions: w.subscriptions,
+	}
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	w.logf("Sending subscription: %s", string(data))
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.conn == nil {
+		return pmerrors.ErrNotConnected
+	}
+	return w.conn.WriteMessage(websocket.TextMessage, data)
+}
+
+// Unsubscribe sends the unsubscribe message for specific subscriptions
+func (w *WebSocketClient) Unsubscribe(subscriptions []Subscription) error {
+	msg := SubscriptionMessage{
+		Action:        "unsubscribe",
+		Subscriptions: subscriptions,
+	}
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	w.logf("Sending unsubscribe: %s", string(data))
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.conn == nil {
+		return pmerrors.ErrNotConnected
+	}
+	return w.conn.WriteMessage(websocket.TextMessage, data)
+}
+
+// startPing sends ping messages at regular intervals to keep connection alive
+func (w *WebSocketClient) startPing() {
+	ticker := w.clock.NewTicker(w.pingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C():
+			w.mu.Lock()
+			if w.conn != nil {
+				// Send lowercase "ping" as plain text per Polymarket spec
+				if err := w.conn.WriteMessage(websocket.TextMessage, []byte("ping")); err != nil {
+					w.logf("Ping error: %v", err)
+				}
+			}
+			w.mu.Unlock()
+		case <-w.done:
+			return
+		}
+	}
+}
+
+// Run starts the WebSocket connection and message handling loop.
+// With a reconnect policy, connection errors trigger a reconnect and
+// resubscribe after a backoff; otherwise the first error is returned.
+func (w *WebSocketClient) Run() error {
+	// Invalid subscriptions won't get better by reconnecting
+	for _, sub := range w.subscriptions {
+		if err := sub.Validate(); err != nil {
+			return err
+		}
+	}
+
+	// Start ping goroutine
+	go w.startPing()
+
+	handle := w.handleMessage
+	deliver := handle
+	if w.messageBuffer > 0 {
+		queue := make(chan []byte, w.messageBuffer)
+		dispatched := make(chan struct{})
+		go func() {
+			defer close(dispatched)
+			for message := range queue {
+				handle(message)
+			}
+		}()
+		defer func() {
+			close(queue)
+			<-dispatched
+		}()
+		deliver = func(message []byte) {
+			queue <- message
+		}
+	}
+
+	attempt := 0
+	for {
+		subscribed, err := w.runConnection(deliver)
+		if err == nil || w.closed.Load() {
+			return nil
+		}
+		if subscribed {
+			attempt = 0 // The connection was healthy; start backoff over
+		}
+
+		attempt++
+		delay, ok := w.reconnect.Backoff(attempt)
+		if !ok {
+			return err
+		}
+		w.reconnects.Add(1)
+		w.logf("Connection error, reconnecting in %s (attempt %d): %v", delay, attempt, err)
+
+		timer := w.clock.NewTimer(delay)
+		select {
+		case <-timer.C():
+		case <-w.done:
+			timer.Stop()
+			return nil
+		}
+	}
+}
+
+// runConnection connects, subscribes and reads until the connection fails.
+// It reports whether the subscription was sent, and returns nil when the
+// client was closed or the server closed the connection normally.
+func (w *WebSocketClient) runConnection(deliver MessageCallback) (bool, error) {
+	if err := w.Connect(); err != nil {
+		return false, err
+	}
+
+	// Subscribe to topics
+	if err := w.Subscribe(); err != nil {
+		return false, err
+	}
+
+	w.mu.RLock()
+	conn := w.conn
+	w.mu.RUnlock()
+	if conn == nil {
+		return true, nil // Closed while subscribing
+	}
+	w.subscribed.Store(true)
+	defer w.subscribed.Store(false)
+
+	// Message reading loop
+	for {
+		select {
+		case <-w.done:
+			return true, nil
+		default:
+			_, message, err := conn.ReadMessage()
+			if err != nil {
+				// Check if we're shutting down
+				if w.closed.Load() {
+					return true, nil
+				}
+				if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+					w.logf("Connection closed normally")
+					return true, nil
+				}
+				return true, err
+			}
+			w.lastMessage.Store(w.clock.Now().UnixNano())
+
+			// Check if it's a pong response (plain text)
+			if string(message) == "pong" {
+				continue
+			}
+
+			// Pass message to the handler
+			deliver(message)
+		}
+	}
+}
+
+// handleMessage passes a message to the handler: raw for callback adapters,
+// parsed and dispatched by type otherwise
+func (w *WebSocketClient) handleMessage(message []byte) {
+	if w.handler == nil {
+		return
+	}
+	if raw, ok := w.handler.(rawHandler); ok {
+		raw.OnRaw(message)
+		return
+	}
+	Dispatch(message, w.handler)
+}
+
+// Reconnects returns how many times Run has reconnected after a
+// connection error
+func (w *WebSocketClient) Reconnects() uint64 {
+	return w.reconnects.Load()
+}
+
+// Status returns the state of the connection
+func (w *WebSocketClient) Status() ConnectionStatus {
+	status := ConnectionStatus{Connected: w.subscribed.Load()}
+	if ns := w.lastMessage.Load(); ns > 0 {
+		status.LastMessage = time.Unix(0, ns)
+	}
+	return status
+}
+
+// Disconnect drops the current connection without closing the client, as
+// a network failure would: with a reconnect policy Run reconnects and
+// resubscribes, otherwise it returns the read error
+func (w *WebSocketClient) Disconnect() {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.conn != nil {
+		w.conn.Close()
+	}
+}
+
+// Close gracefully closes the WebSocket connection
+func (w *WebSocketClient) Close() {
+	// Use atomic to prevent double-close panic
+	if w.closed.Swap(true) {
+		return // Already closed
+	}
+
+	close(w.done)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.conn != nil {
+		w.conn.Close()
+		w.conn = nil
+	}
+}
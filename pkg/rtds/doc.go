@@ -0,0 +1,28 @@
+// Package rtds is a client for the Polymarket real-time data socket
+// (wss://ws-live-data.polymarket.com).
+//
+// It manages the WebSocket connection, subscriptions and keep-alive pings, and
+// provides the message types and parsers for the activity and clob_user
+// topics. The package has no global configuration and does not log unless a
+// Logger is supplied.
+//
+//	client := rtds.NewWebSocketClient(
+//		rtds.WithSubscriptions(rtds.NewActivityTradesSubscription()),
+//		rtds.WithMessageCallback(func(message []byte) {
+//			trade, err := rtds.ParseActivityTrade(message)
+//			if err != nil {
+//				return // rtds.ErrSkipMessage for pongs and other topics
+//			}
+//			fmt.Println(trade.Side, trade.Size, trade.Price)
+//		}),
+//		rtds.WithReconnectPolicy(rtds.DefaultReconnectPolicy()),
+//	)
+//	defer client.Close()
+//	err := client.Run()
+//
+// Subscriptions other than the New*Subscription presets can be built with
+// NewSubscription, which validates topic/type, auth and filters up front.
+//
+// Topics the package doesn't know yet are supported by implementing Channel
+// and registering it with RegisterChannel.
+package rtds
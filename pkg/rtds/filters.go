@@ -0,0 +1,96 @@
+package rtds
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+)
+
+// ActivityFilter restricts activity trades to some events, markets and
+// wallets, and a minimum size. The server filters by event and market slug
+// only (see Filters); use Match to apply the whole filter to received
+// trades. The zero value matches every trade.
+type ActivityFilter struct {
+	EventSlugs  []string
+	MarketSlugs []string
+	Wallets     []string // Proxy wallet addresses
+	MinSize     float64  // In shares
+}
+
+// FilterOption sets a field of an ActivityFilter
+type FilterOption func(*ActivityFilter)
+
+// WithEvents restricts trades to the events with these slugs
+func WithEvents(slugs ...string) FilterOption {
+	return func(f *ActivityFilter) { f.EventSlugs = append(f.EventSlugs, slugs...) }
+}
+
+// WithMarkets restricts trades to the markets with these slugs
+func WithMarkets(slugs ...string) FilterOption {
+	return func(f *ActivityFilter) { f.MarketSlugs = append(f.MarketSlugs, slugs...) }
+}
+
+// WithUsers restricts trades to these proxy wallets. Applied by Match only.
+func WithUsers(wallets ...string) FilterOption {
+	return func(f *ActivityFilter) { f.Wallets = append(f.Wallets, wallets...) }
+}
+
+// WithMinSize drops trades of fewer than size shares. Applied by Match only.
+func WithMinSize(size float64) FilterOption {
+	return func(f *ActivityFilter) { f.MinSize = size }
+}
+
+// NewActivityFilter builds a filter from opts
+func NewActivityFilter(opts ...FilterOption) ActivityFilter {
+	var f ActivityFilter
+	for _, opt := range opts {
+		opt(&f)
+	}
+	return f
+}
+
+// Empty reports whether the filter matches every trade
+func (f ActivityFilter) Empty() bool {
+	return len(f.EventSlugs) == 0 && len(f.MarketSlugs) == 0 && len(f.Wallets) == 0 && f.MinSize <= 0
+}
+
+// Filters serializes the slugs in the subscription filter format: one
+// {"event_slug"} or {"market_slug"} object, or an array of them. It is ""
+// when no slugs are set.
+func (f ActivityFilter) Filters() string {
+	var filters []map[string]string
+	for _, s := range f.EventSlugs {
+		filters = append(filters, map[string]string{"event_slug": s})
+	}
+	for _, s := range f.MarketSlugs {
+		filters = append(filters, map[string]string{"market_slug": s})
+	}
+
+	var data []byte
+	switch len(filters) {
+	case 0:
+		return ""
+	case 1:
+		data, _ = json.Marshal(filters[0])
+	default:
+		data, _ = json.Marshal(filters)
+	}
+	return string(data)
+}
+
+// Match reports whether trade passes the filter. A trade must match one of
+// the event or market slugs when any are set (either list), and one of the
+// wallets when any are set.
+func (f ActivityFilter) Match(trade *ActivityTradePayload) bool {
+	if len(f.EventSlugs) > 0 || len(f.MarketSlugs) > 0 {
+		if !slices.Contains(f.EventSlugs, trade.EventSlug) && !slices.Contains(f.MarketSlugs, trade.MarketSlug) {
+			return false
+		}
+	}
+	if len(f.Wallets) > 0 && !slices.ContainsFunc(f.Wallets, func(w string) bool {
+		return strings.EqualFold(w, trade.ProxyWalletAddress)
+	}) {
+		return false
+	}
+	return trade.Size >= f.MinSize
+}
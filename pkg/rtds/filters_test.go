@@ -0,0 +1,48 @@
+package rtds
+
+import "testing"
+
+func TestActivityFilterFilters(t *testing.T) {
+	tests := []struct {
+		opts []FilterOption
+		want string
+	}{
+		{nil, ""},
+		{[]FilterOption{WithUsers("0xabc"), WithMinSize(10)}, ""},
+		{[]FilterOption{WithEvents("us-election")}, `{"event_slug":"us-election"}`},
+		{[]FilterOption{WithEvents("a"), WithMarkets("b")}, `[{"event_slug":"a"},{"market_slug":"b"}]`},
+	}
+	for _, tt := range tests {
+		got := NewActivityFilter(tt.opts...).Filters()
+		if got != tt.want {
+			t.Errorf("Filters() = %s, want %s", got, tt.want)
+		}
+		sub := NewActivityTradesSubscription(tt.opts...)
+		if err := sub.Validate(); err != nil {
+			t.Errorf("Validate(%s): %v", got, err)
+		}
+	}
+}
+
+func TestActivityFilterMatch(t *testing.T) {
+	filter := NewActivityFilter(WithEvents("us-election"), WithMarkets("fed-cut"), WithUsers("0xABC"), WithMinSize(10))
+	tests := []struct {
+		name  string
+		trade ActivityTradePayload
+		want  bool
+	}{
+		{"event", ActivityTradePayload{EventSlug: "us-election", ProxyWalletAddress: "0xabc", Size: 10}, true},
+		{"market", ActivityTradePayload{MarketSlug: "fed-cut", ProxyWalletAddress: "0xabc", Size: 20}, true},
+		{"other event", ActivityTradePayload{EventSlug: "nba", ProxyWalletAddress: "0xabc", Size: 20}, false},
+		{"other wallet", ActivityTradePayload{EventSlug: "us-election", ProxyWalletAddress: "0xdef", Size: 20}, false},
+		{"too small", ActivityTradePayload{EventSlug: "us-election", ProxyWalletAddress: "0xabc", Size: 5}, false},
+	}
+	for _, tt := range tests {
+		if got := filter.Match(&tt.trade); got != tt.want {
+			t.Errorf("%s: Match = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+	if !(ActivityFilter{}).Match(&ActivityTradePayload{}) {
+		t.Error("zero filter should match every trade")
+	}
+}
@@ -0,0 +1,188 @@
+package rtds
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/FatwaArya/pm-ingest/pkg/pmerrors"
+)
+
+// EventHandler receives typed events dispatched by the client.
+// Embed NopHandler to implement only the methods you need.
+type EventHandler interface {
+	OnTrade(trade *ActivityTradePayload)
+	OnOrder(order *ClobUserOrder)
+	OnComment(comment *Comment)
+	// OnUnknown receives messages on topics/types without a typed handler
+	OnUnknown(message *IncomingMessage)
+	// OnError receives messages that could not be parsed
+	OnError(err error)
+}
+
+// UserTradeHandler can optionally be implemented by an EventHandler to
+// receive clob_user trade updates; otherwise they go to OnUnknown
+type UserTradeHandler interface {
+	OnUserTrade(trade *ClobUserTrade)
+}
+
+// rawHandler is implemented by handlers that want the unparsed bytes.
+// The client skips dispatching for them.
+type rawHandler interface {
+	OnRaw(message []byte)
+}
+
+// NopHandler ignores every event
+type NopHandler struct{}
+
+func (NopHandler) OnTrade(*ActivityTradePayload) {}
+func (NopHandler) OnOrder(*ClobUserOrder)        {}
+func (NopHandler) OnComment(*Comment)            {}
+func (NopHandler) OnUnknown(*IncomingMessage)    {}
+func (NopHandler) OnError(error)                 {}
+
+// HandlerFuncs adapts plain functions to an EventHandler; nil fields are ignored
+type HandlerFuncs struct {
+	Trade     func(*ActivityTradePayload)
+	Order     func(*ClobUserOrder)
+	UserTrade func(*ClobUserTrade)
+	Comment   func(*Comment)
+	Unknown   func(*IncomingMessage)
+	Error     func(error)
+}
+
+func (h HandlerFuncs) OnTrade(trade *ActivityTradePayload) {
+	if h.Trade != nil {
+		h.Trade(trade)
+	}
+}
+
+func (h HandlerFuncs) OnOrder(order *ClobUserOrder) {
+	if h.Order != nil {
+		h.Order(order)
+	}
+}
+
+func (h HandlerFuncs) OnUserTrade(trade *ClobUserTrade) {
+	if h.UserTrade != nil {
+		h.UserTrade(trade)
+	}
+}
+
+func (h HandlerFuncs) OnComment(comment *Comment) {
+	if h.Comment != nil {
+		h.Comment(comment)
+	}
+}
+
+func (h HandlerFuncs) OnUnknown(message *IncomingMessage) {
+	if h.Unknown != nil {
+		h.Unknown(message)
+	}
+}
+
+func (h HandlerFuncs) OnError(err error) {
+	if h.Error != nil {
+		h.Error(err)
+	}
+}
+
+// CallbackHandler is the default adapter for a raw MessageCallback: the
+// callback receives every message unparsed, exactly as before handlers existed
+type CallbackHandler struct {
+	NopHandler
+	Callback MessageCallback
+}
+
+// OnRaw passes the raw message to the callback
+func (h CallbackHandler) OnRaw(message []byte) {
+	if h.Callback != nil {
+		h.Callback(message)
+	}
+}
+
+// WithEventHandler makes the client parse messages and dispatch typed events
+// to handler. It replaces any callback set with WithMessageCallback.
+func WithEventHandler(handler EventHandler) Option {
+	return func(w *WebSocketClient) {
+		w.handler = handler
+	}
+}
+
+// Dispatch parses a raw WebSocket message and routes it to the handler.
+// Plain-text frames (pong) and empty frames are ignored; a JSON array of
+// messages is dispatched element by element.
+func Dispatch(message []byte, handler EventHandler) {
+	message = bytes.TrimSpace(message)
+	if len(message) == 0 {
+		return
+	}
+
+	switch message[0] {
+	case '{':
+	case '[':
+		var batch []json.RawMessage
+		if err := json.Unmarshal(message, &batch); err != nil {
+			handler.OnError(pmerrors.Decode("message batch", err))
+			return
+		}
+		for _, m := range batch {
+			Dispatch(m, handler)
+		}
+		return
+	default:
+		return // Non-JSON frames such as "pong"
+	}
+
+	var incoming IncomingMessage
+	if err := json.Unmarshal(message, &incoming); err != nil {
+		handler.OnError(pmerrors.Decode("incoming message", err))
+		return
+	}
+
+	if dispatchChannel(&incoming, handler) {
+		return
+	}
+
+	switch {
+	case incoming.Topic == TopicActivity && (incoming.Type == TypeTrades || incoming.Type == TypeOrdersMatched):
+		var trade ActivityTradePayload
+		if err := json.Unmarshal(incoming.Payload, &trade); err != nil {
+			handler.OnError(pmerrors.Decode("activity trade payload", err))
+			return
+		}
+		trade.Envelope = Envelope{ConnectionID: incoming.ConnectionID, Topic: incoming.Topic, Type: incoming.Type}
+		handler.OnTrade(&trade)
+
+	case incoming.Topic == TopicClobUser && incoming.Type == TypeOrder:
+		order, err := ParseClobUserOrder(incoming.Payload)
+		if err != nil {
+			handler.OnError(err)
+			return
+		}
+		handler.OnOrder(order)
+
+	case incoming.Topic == TopicClobUser && incoming.Type == TypeTrade:
+		utHandler, ok := handler.(UserTradeHandler)
+		if !ok {
+			handler.OnUnknown(&incoming)
+			return
+		}
+		trade, err := ParseClobUserTrade(incoming.Payload)
+		if err != nil {
+			handler.OnError(err)
+			return
+		}
+		utHandler.OnUserTrade(trade)
+
+	case incoming.Topic == TopicComments && (incoming.Type == TypeCommentCreated || incoming.Type == TypeCommentRemoved):
+		comment, err := ParseComment(incoming.Payload)
+		if err != nil {
+			handler.OnError(err)
+			return
+		}
+		handler.OnComment(comment)
+
+	default:
+		handler.OnUnknown(&incoming)
+	}
+}
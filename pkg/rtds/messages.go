@@ -0,0 +1,340 @@
+package rtds
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/FatwaArya/pm-ingest/pkg/pmerrors"
+)
+
+// IncomingMessage represents the wrapper structure for WebSocket messages
+type IncomingMessage struct {
+	ConnectionID string          `json:"connection_id"`
+	Payload      json.RawMessage `json:"payload"`
+	Timestamp    int64           `json:"timestamp"`
+	Topic        string          `json:"topic"`
+	Type         string          `json:"type"`
+}
+
+// ActivityTradePayload represents a trade from the activity topic
+type ActivityTradePayload struct {
+	ID                 string  `json:"id,omitempty"`
+	Market             string  `json:"market,omitempty"`
+	Asset              string  `json:"asset"`
+	Side               string  `json:"side"`  // BUY/SELL
+	Price              float64 `json:"price"` // Price in decimal (e.g., 0.55)
+	Size               float64 `json:"size"`
+	Fee                float64 `json:"fee,omitempty"`
+	Timestamp          int64   `json:"timestamp"`
+	TransactionHash    string  `json:"transactionHash,omitempty"`
+	Maker              string  `json:"maker,omitempty"`
+	Taker              string  `json:"taker,omitempty"`
+	MakerOrderID       string  `json:"makerOrderId,omitempty"`
+	TakerOrderID       string  `json:"takerOrderId,omitempty"`
+	ConditionID        string  `json:"conditionId,omitempty"`
+	OutcomeIndex       int     `json:"outcomeIndex,omitempty"`
+	QuestionID         string  `json:"questionId,omitempty"`
+	MarketSlug         string  `json:"slug,omitempty"` // JSON field is "slug"
+	EventSlug          string  `json:"eventSlug,omitempty"`
+	EventTitle         string  `json:"title,omitempty"`       // JSON field is "title"
+	OutcomeTitle       string  `json:"outcome,omitempty"`     // JSON field is "outcome"
+	ProxyWalletAddress string  `json:"proxyWallet,omitempty"` // JSON field is "proxyWallet"
+	// Additional fields from the actual payload
+	Name         string `json:"name,omitempty"`
+	Pseudonym    string `json:"pseudonym,omitempty"`
+	Bio          string `json:"bio,omitempty"`
+	Icon         string `json:"icon,omitempty"`
+	ProfileImage string `json:"profileImage,omitempty"`
+	// Labels of the wallet (whale, market_maker, ...), set by the ingester
+	// rather than sent by RTDS
+	Labels []string `json:"labels,omitempty"`
+	// Market metadata from the Gamma API, also set by the ingester
+	Category        string   `json:"category,omitempty"`
+	MarketEndDate   string   `json:"marketEndDate,omitempty"` // RFC 3339
+	MarketLiquidity float64  `json:"marketLiquidity,omitempty"`
+	MarketVolume    float64  `json:"marketVolume,omitempty"`
+	Outcomes        []string `json:"outcomes,omitempty"` // Names, by outcome index
+	Tags            []string `json:"tags,omitempty"`
+	// The message the trade came in, set by Dispatch
+	Envelope Envelope `json:"-"`
+}
+
+// Envelope describes the WebSocket message a payload came in
+type Envelope struct {
+	ConnectionID string
+	Topic        string
+	Type         string
+}
+
+// DedupeKey identifies a fill. A transaction can settle several fills, so the
+// hash alone isn't unique; combined with asset, wallet, side, size and price
+// it is stable across reconnects and replays of the same message.
+func (t *ActivityTradePayload) DedupeKey() string {
+	return fmt.Sprintf("%s:%s:%s:%s:%g:%g",
+		t.TransactionHash, t.Asset, strings.ToLower(t.ProxyWalletAddress), t.Side, t.Size, t.Price)
+}
+
+// Trade roles
+const (
+	RoleMaker = "maker"
+	RoleTaker = "taker"
+)
+
+// Role returns whether ProxyWalletAddress was the maker or the taker of the
+// fill, or "" if the payload doesn't say
+func (t *ActivityTradePayload) Role() string {
+	switch wallet := t.ProxyWalletAddress; {
+	case wallet == "":
+		return ""
+	case strings.EqualFold(t.Taker, wallet):
+		return RoleTaker
+	case strings.EqualFold(t.Maker, wallet):
+		return RoleMaker
+	}
+	return ""
+}
+
+// AggressorSide returns the taker's side of the fill: Side if the wallet
+// took liquidity, the opposite side if it provided it, and "" if the role
+// is unknown
+func (t *ActivityTradePayload) AggressorSide() string {
+	switch t.Role() {
+	case RoleTaker:
+		return t.Side
+	case RoleMaker:
+		return OppositeSide(t.Side)
+	}
+	return ""
+}
+
+// OppositeSide returns SELL for BUY and vice versa
+func OppositeSide(side string) string {
+	switch side {
+	case SideBuy:
+		return SideSell
+	case SideSell:
+		return SideBuy
+	}
+	return ""
+}
+
+// ClobUserOrder represents an order update from clob_user topic
+type ClobUserOrder struct {
+	ID              string   `json:"id"`
+	Market          string   `json:"market"`
+	AssetID         string   `json:"asset_id"`
+	Side            string   `json:"side"`
+	Price           string   `json:"price"`
+	OriginalSize    string   `json:"original_size"`
+	SizeMatched     string   `json:"size_matched"`
+	Type            string   `json:"type"` // PLACEMENT, UPDATE, CANCELLATION
+	Outcome         string   `json:"outcome"`
+	Owner           string   `json:"owner"`
+	Timestamp       string   `json:"timestamp"`
+	AssociateTrades []string `json:"associate_trades,omitempty"`
+}
+
+// ClobUserTrade represents a trade update from clob_user topic
+type ClobUserTrade struct {
+	ID           string       `json:"id"`
+	Market       string       `json:"market"`
+	AssetID      string       `json:"asset_id"`
+	Side         string       `json:"side"`
+	Price        string       `json:"price"`
+	Size         string       `json:"size"`
+	Status       string       `json:"status"` // MATCHED, MINED, CONFIRMED, RETRYING, FAILED
+	Outcome      string       `json:"outcome"`
+	Owner        string       `json:"owner"`
+	TakerOrderID string       `json:"taker_order_id"`
+	Timestamp    string       `json:"timestamp"`
+	MatchTime    string       `json:"matchtime,omitempty"`
+	LastUpdate   string       `json:"last_update,omitempty"`
+	MakerOrders  []MakerOrder `json:"maker_orders,omitempty"`
+}
+
+// MakerOrder represents a maker order in a trade
+type MakerOrder struct {
+	AssetID       string `json:"asset_id"`
+	MatchedAmount string `json:"matched_amount"`
+	OrderID       string `json:"order_id"`
+	Outcome       string `json:"outcome"`
+	Owner         string `json:"owner"`
+	Price         string `json:"price"`
+}
+
+// Comment represents a comment event from the comments topic
+type Comment struct {
+	ID               string          `json:"id"`
+	Body             string          `json:"body"`
+	ParentEntityID   int64           `json:"parentEntityID"`
+	ParentEntityType string          `json:"parentEntityType"` // Event, Series, ...
+	ParentCommentID  string          `json:"parentCommentID,omitempty"`
+	UserAddress      string          `json:"userAddress"`
+	ReplyAddress     string          `json:"replyAddress,omitempty"`
+	CreatedAt        string          `json:"createdAt"`
+	ReactionCount    int             `json:"reactionCount"`
+	Profile          *CommentProfile `json:"profile,omitempty"`
+}
+
+// CommentProfile is the author profile embedded in a comment
+type CommentProfile struct {
+	BaseAddress string `json:"baseAddress"`
+	Name        string `json:"name,omitempty"`
+	Pseudonym   string `json:"pseudonym,omitempty"`
+}
+
+// CryptoPrice is a reference price update from the crypto_prices topics,
+// used to settle crypto markets
+type CryptoPrice struct {
+	Symbol    string  `json:"symbol"`    // btcusdt on crypto_prices, btc/usd on crypto_prices_chainlink
+	Timestamp int64   `json:"timestamp"` // Unix milliseconds
+	Value     float64 `json:"value"`
+	// Feed the price came from (binance or chainlink), set by the ingester
+	// rather than sent by RTDS
+	Source string `json:"source,omitempty"`
+}
+
+// RFQRequest is a request for quote from the rfq topic: a user asking
+// market makers to quote a size of a token
+type RFQRequest struct {
+	RequestID    string  `json:"requestId"`
+	ProxyAddress string  `json:"proxyAddress"`
+	Market       string  `json:"market"` // Condition ID
+	Token        string  `json:"token"`
+	Complement   string  `json:"complement,omitempty"` // The market's other token
+	State        string  `json:"state"`
+	Side         string  `json:"side"` // BUY/SELL
+	SizeIn       float64 `json:"sizeIn"`
+	SizeOut      float64 `json:"sizeOut"`
+	Price        float64 `json:"price"`
+	Expiry       int64   `json:"expiry"` // Unix seconds
+}
+
+// RFQQuote is a market maker's quote answering an RFQRequest
+type RFQQuote struct {
+	QuoteID      string  `json:"quoteId"`
+	RequestID    string  `json:"requestId"`
+	ProxyAddress string  `json:"proxyAddress"`
+	Condition    string  `json:"condition"` // Condition ID
+	Token        string  `json:"token"`
+	Complement   string  `json:"complement,omitempty"`
+	State        string  `json:"state"`
+	Side         string  `json:"side"`
+	SizeIn       float64 `json:"sizeIn"`
+	SizeOut      float64 `json:"sizeOut"`
+	Price        float64 `json:"price"`
+	Expiry       int64   `json:"expiry"`
+}
+
+// Trade status constants
+const (
+	TradeStatusMatched   = "MATCHED"
+	TradeStatusMined     = "MINED"
+	TradeStatusConfirmed = "CONFIRMED"
+	TradeStatusRetrying  = "RETRYING"
+	TradeStatusFailed    = "FAILED"
+)
+
+// Order type constants
+const (
+	OrderTypePlacement    = "PLACEMENT"
+	OrderTypeUpdate       = "UPDATE"
+	OrderTypeCancellation = "CANCELLATION"
+)
+
+// Side constants
+const (
+	SideBuy  = "BUY"
+	SideSell = "SELL"
+)
+
+// ErrSkipMessage is returned when a message should be skipped (not a trade)
+var ErrSkipMessage = errors.New("skip message")
+
+// ParseActivityTrade parses the full WebSocket message and extracts the trade payload
+func ParseActivityTrade(message []byte) (*ActivityTradePayload, error) {
+	// Skip empty messages
+	if len(message) == 0 {
+		return nil, ErrSkipMessage
+	}
+
+	// Skip non-JSON messages (like "pong")
+	if message[0] != '{' {
+		return nil, ErrSkipMessage
+	}
+
+	// First, parse the wrapper message
+	var incoming IncomingMessage
+	if err := json.Unmarshal(message, &incoming); err != nil {
+		return nil, pmerrors.Decode("incoming message", err)
+	}
+
+	// Skip non-trade messages silently
+	if incoming.Topic != TopicActivity || incoming.Type != TypeTrades {
+		return nil, ErrSkipMessage
+	}
+
+	// Parse the actual trade payload
+	var trade ActivityTradePayload
+	if err := json.Unmarshal(incoming.Payload, &trade); err != nil {
+		return nil, pmerrors.Decode("activity trade payload", err)
+	}
+
+	return &trade, nil
+}
+
+// ParseComment parses a comment payload from the comments topic
+func ParseComment(payload json.RawMessage) (*Comment, error) {
+	var comment Comment
+	if err := json.Unmarshal(payload, &comment); err != nil {
+		return nil, pmerrors.Decode("comment", err)
+	}
+	return &comment, nil
+}
+
+// ParseClobUserOrder parses an order message from clob_user topic
+func ParseClobUserOrder(payload json.RawMessage) (*ClobUserOrder, error) {
+	var order ClobUserOrder
+	if err := json.Unmarshal(payload, &order); err != nil {
+		return nil, pmerrors.Decode("clob_user order", err)
+	}
+	return &order, nil
+}
+
+// ParseClobUserTrade parses a trade message from clob_user topic
+func ParseClobUserTrade(payload json.RawMessage) (*ClobUserTrade, error) {
+	var trade ClobUserTrade
+	if err := json.Unmarshal(payload, &trade); err != nil {
+		return nil, pmerrors.Decode("clob_user trade", err)
+	}
+	return &trade, nil
+}
+
+// ParseCryptoPrice parses a price update from the crypto_prices topics
+func ParseCryptoPrice(payload json.RawMessage) (*CryptoPrice, error) {
+	var price CryptoPrice
+	if err := json.Unmarshal(payload, &price); err != nil {
+		return nil, pmerrors.Decode("crypto price", err)
+	}
+	return &price, nil
+}
+
+// ParseRFQRequest parses a request event from the rfq topic
+func ParseRFQRequest(payload json.RawMessage) (*RFQRequest, error) {
+	var request RFQRequest
+	if err := json.Unmarshal(payload, &request); err != nil {
+		return nil, pmerrors.Decode("rfq request", err)
+	}
+	return &request, nil
+}
+
+// ParseRFQQuote parses a quote event from the rfq topic
+func ParseRFQQuote(payload json.RawMessage) (*RFQQuote, error) {
+	var quote RFQQuote
+	if err := json.Unmarshal(payload, &quote); err != nil {
+		return nil, pmerrors.Decode("rfq quote", err)
+	}
+	return &quote, nil
+}
@@ -0,0 +1,191 @@
+package rtds
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/FatwaArya/pm-ingest/internal/fixtures"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files in testdata/golden")
+
+// goldenResult is what gets compared against testdata/golden/<fixture>.golden
+type goldenResult struct {
+	Skip   bool   `json:"skip,omitempty"`
+	Error  string `json:"error,omitempty"`
+	Result any    `json:"result,omitempty"`
+}
+
+// parseFixture runs the parser that matches the fixture's kind
+func parseFixture(name string, data []byte) goldenResult {
+	var (
+		result any
+		err    error
+	)
+	switch {
+	case strings.HasPrefix(name, "clob_user_order"):
+		result, err = ParseClobUserOrder(data)
+	case strings.HasPrefix(name, "clob_user_trade"):
+		result, err = ParseClobUserTrade(data)
+	case strings.HasPrefix(name, "crypto_price"):
+		result, err = ParseCryptoPrice(data)
+	case strings.HasPrefix(name, "rfq_request"):
+		result, err = ParseRFQRequest(data)
+	case strings.HasPrefix(name, "rfq_quote"):
+		result, err = ParseRFQQuote(data)
+	default:
+		result, err = ParseActivityTrade(data)
+	}
+
+	if errors.Is(err, ErrSkipMessage) {
+		return goldenResult{Skip: true}
+	}
+	if err != nil {
+		return goldenResult{Error: err.Error()}
+	}
+	return goldenResult{Result: result}
+}
+
+func TestParseGolden(t *testing.T) {
+	all := fixtures.All()
+	if len(all) == 0 {
+		t.Fatal("fixture corpus is empty")
+	}
+
+	for _, f := range all {
+		t.Run(f.Name, func(t *testing.T) {
+			got, err := json.MarshalIndent(parseFixture(f.Name, f.Data), "", "  ")
+			if err != nil {
+				t.Fatalf("marshal result: %v", err)
+			}
+			got = append(got, '\n')
+
+			goldenPath := filepath.Join("testdata", "golden", f.Name+".golden")
+			if *update {
+				if err := os.MkdirAll(filepath.Dir(goldenPath), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(goldenPath, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			want, err := os.ReadFile(goldenPath)
+			if err != nil {
+				t.Fatalf("read golden file (run with -update to create): %v", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("parse output differs from %s\n--- got ---\n%s\n--- want ---\n%s", goldenPath, got, want)
+			}
+		})
+	}
+}
+
+func FuzzParseActivityTrade(f *testing.F) {
+	for _, fx := range fixtures.All() {
+		f.Add(fx.Data)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		trade, err := ParseActivityTrade(data)
+		if err != nil {
+			if trade != nil {
+				t.Errorf("non-nil trade returned with error %v", err)
+			}
+			return
+		}
+		if trade == nil {
+			t.Error("nil trade returned without error")
+		}
+	})
+}
+
+func FuzzParseClobUserOrder(f *testing.F) {
+	for _, fx := range fixtures.WithPrefix("clob_user_") {
+		f.Add(fx.Data)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		order, err := ParseClobUserOrder(data)
+		if err == nil && order == nil {
+			t.Error("nil order returned without error")
+		}
+	})
+}
+
+func FuzzParseClobUserTrade(f *testing.F) {
+	for _, fx := range fixtures.WithPrefix("clob_user_") {
+		f.Add(fx.Data)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		trade, err := ParseClobUserTrade(data)
+		if err == nil && trade == nil {
+			t.Error("nil trade returned without error")
+		}
+	})
+}
+
+func FuzzParseCryptoPrice(f *testing.F) {
+	for _, fx := range fixtures.WithPrefix("crypto_price") {
+		f.Add(fx.Data)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		price, err := ParseCryptoPrice(data)
+		if err == nil && price == nil {
+			t.Error("nil price returned without error")
+		}
+	})
+}
+
+func FuzzParseRFQ(f *testing.F) {
+	for _, fx := range fixtures.WithPrefix("rfq_") {
+		f.Add(fx.Data)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if request, err := ParseRFQRequest(data); err == nil && request == nil {
+			t.Error("nil request returned without error")
+		}
+		if quote, err := ParseRFQQuote(data); err == nil && quote == nil {
+			t.Error("nil quote returned without error")
+		}
+	})
+}
+
+func FuzzParseComment(f *testing.F) {
+	for _, fx := range fixtures.WithPrefix("comment_") {
+		f.Add(fx.Data)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		comment, err := ParseComment(data)
+		if err == nil && comment == nil {
+			t.Error("nil comment returned without error")
+		}
+	})
+}
+
+func FuzzDispatch(f *testing.F) {
+	for _, fx := range fixtures.All() {
+		f.Add(fx.Data)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		Dispatch(data, HandlerFuncs{
+			Trade: func(trade *ActivityTradePayload) {
+				if trade == nil {
+					t.Error("nil trade dispatched")
+				}
+			},
+		})
+	})
+}
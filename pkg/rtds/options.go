@@ -0,0 +1,132 @@
+package rtds
+
+import (
+	"time"
+
+	"github.com/FatwaArya/pm-ingest/pkg/clock"
+	"github.com/gorilla/websocket"
+)
+
+// Option configures a WebSocketClient
+type Option func(*WebSocketClient)
+
+// WithURL overrides the WebSocket endpoint (default WsURL)
+func WithURL(url string) Option {
+	return func(w *WebSocketClient) {
+		w.url = url
+	}
+}
+
+// WithSubscriptions sets the subscriptions sent after every (re)connect
+func WithSubscriptions(subscriptions ...Subscription) Option {
+	return func(w *WebSocketClient) {
+		w.subscriptions = append(w.subscriptions, subscriptions...)
+	}
+}
+
+// WithMessageCallback sets the function that receives every raw message.
+// It replaces any handler set with WithEventHandler.
+func WithMessageCallback(callback MessageCallback) Option {
+	return func(w *WebSocketClient) {
+		w.handler = CallbackHandler{Callback: callback}
+	}
+}
+
+// WithPingInterval sets how often keep-alive pings are sent (default PingInterval)
+func WithPingInterval(interval time.Duration) Option {
+	return func(w *WebSocketClient) {
+		if interval > 0 {
+			w.pingInterval = interval
+		}
+	}
+}
+
+// WithDialer sets the dialer used to open connections (default websocket.DefaultDialer)
+func WithDialer(dialer *websocket.Dialer) Option {
+	return func(w *WebSocketClient) {
+		if dialer != nil {
+			w.dialer = dialer
+		}
+	}
+}
+
+// WithLogger sets the logger for connection-level events (default: none)
+func WithLogger(logger Logger) Option {
+	return func(w *WebSocketClient) {
+		w.logger = logger
+	}
+}
+
+// WithReconnectPolicy makes Run reconnect and resubscribe after connection
+// errors instead of returning (default: NoReconnect)
+func WithReconnectPolicy(policy ReconnectPolicy) Option {
+	return func(w *WebSocketClient) {
+		w.reconnect = policy
+	}
+}
+
+// WithMessageBuffer decouples reading from message handling: up to size
+// messages are queued for the callback so a slow callback doesn't stall the
+// socket read loop. The default (0) calls the callback on the read goroutine.
+func WithMessageBuffer(size int) Option {
+	return func(w *WebSocketClient) {
+		if size > 0 {
+			w.messageBuffer = size
+		}
+	}
+}
+
+// WithClock sets the clock driving pings and reconnect backoff (default
+// clock.Real); tests pass a *clock.Fake
+func WithClock(c clock.Clock) Option {
+	return func(w *WebSocketClient) {
+		if c != nil {
+			w.clock = c
+		}
+	}
+}
+
+// ReconnectPolicy controls reconnection with exponential backoff
+type ReconnectPolicy struct {
+	MaxAttempts    int           // Consecutive failed attempts before giving up; 0 means never reconnect, -1 retries forever
+	InitialBackoff time.Duration // Delay before the first reconnect
+	MaxBackoff     time.Duration // Upper bound for the delay
+	Multiplier     float64       // Backoff growth per attempt (values < 1 are treated as 1)
+}
+
+// NoReconnect returns from Run on the first connection error
+var NoReconnect = ReconnectPolicy{}
+
+// DefaultReconnectPolicy retries forever with backoff from 1s up to 30s
+func DefaultReconnectPolicy() ReconnectPolicy {
+	return ReconnectPolicy{
+		MaxAttempts:    -1,
+		InitialBackoff: time.Second,
+		MaxBackoff:     30 * time.Second,
+		Multiplier:     2,
+	}
+}
+
+// Backoff returns the delay before reconnect attempt n (starting at 1), and
+// false if the policy doesn't allow another attempt
+func (p ReconnectPolicy) Backoff(attempt int) (time.Duration, bool) {
+	if p.MaxAttempts == 0 || (p.MaxAttempts > 0 && attempt > p.MaxAttempts) {
+		return 0, false
+	}
+
+	delay := p.InitialBackoff
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+	for i := 1; i < attempt; i++ {
+		delay = time.Duration(float64(delay) * multiplier)
+		if p.MaxBackoff > 0 && delay >= p.MaxBackoff {
+			return p.MaxBackoff, true
+		}
+	}
+	if p.MaxBackoff > 0 && delay > p.MaxBackoff {
+		delay = p.MaxBackoff
+	}
+	return delay, true
+}
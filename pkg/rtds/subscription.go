@@ -0,0 +1,157 @@
+package rtds
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Topic constants
+const (
+	TopicActivity = "activity"
+	TopicComments = "comments"
+	TopicClobUser = "clob_user"
+	// Reference prices of crypto markets: Binance spot and Chainlink feeds
+	TopicCryptoPrices          = "crypto_prices"
+	TopicCryptoPricesChainlink = "crypto_prices_chainlink"
+	// Requests for quote and the quotes answering them
+	TopicRFQ = "rfq"
+)
+
+// Type constants
+const (
+	TypeTrades         = "trades"
+	TypeOrders         = "orders"
+	TypeOrdersMatched  = "orders_matched"
+	TypeOrder          = "order" // clob_user order update
+	TypeTrade          = "trade" // clob_user trade update
+	TypeCommentCreated = "comment_created"
+	TypeCommentRemoved = "comment_removed"
+	TypeUpdate         = "update" // crypto_prices update
+	// rfq request and quote lifecycle
+	TypeRequestCreated  = "request_created"
+	TypeRequestEdited   = "request_edited"
+	TypeRequestCanceled = "request_canceled"
+	TypeRequestExpired  = "request_expired"
+	TypeQuoteCreated    = "quote_created"
+	TypeQuoteEdited     = "quote_edited"
+	TypeQuoteCanceled   = "quote_canceled"
+	TypeQuoteExpired    = "quote_expired"
+	TypeAll             = "*"
+)
+
+// Auth holds the authentication credentials for private topics
+type Auth struct {
+	APIKey     string `json:"key"`
+	Secret     string `json:"secret"`
+	Passphrase string `json:"passphrase"`
+}
+
+// ClobAuth is the auth structure for clob_user subscriptions
+type ClobAuth struct {
+	Key        string `json:"key"`
+	Secret     string `json:"secret"`
+	Passphrase string `json:"passphrase"`
+}
+
+// Subscription represents a single topic subscription
+type Subscription struct {
+	Topic    string    `json:"topic"`
+	Type     string    `json:"type"`
+	ClobAuth *ClobAuth `json:"clob_auth,omitempty"`
+	Filters  string    `json:"filters,omitempty"`
+}
+
+// SubscriptionMessage is the message sent to subscribe/unsubscribe
+type SubscriptionMessage struct {
+	Action        string         `json:"action"`
+	Subscriptions []Subscription `json:"subscriptions"`
+}
+
+// NewActivityTradesSubscription creates an activity trades subscription,
+// filtered server-side by the event and market slugs of opts:
+//
+//	rtds.NewActivityTradesSubscription(rtds.WithEvents("us-election"))
+func NewActivityTradesSubscription(opts ...FilterOption) Subscription {
+	return Subscription{
+		Topic:   TopicActivity,
+		Type:    TypeTrades,
+		Filters: NewActivityFilter(opts...).Filters(),
+	}
+}
+
+// NewActivityAllSubscription creates an activity subscription for all types
+func NewActivityAllSubscription() Subscription {
+	return Subscription{
+		Topic: TopicActivity,
+		Type:  TypeAll,
+	}
+}
+
+// NewCommentsSubscription creates a comments subscription for all types
+func NewCommentsSubscription() Subscription {
+	return Subscription{
+		Topic: TopicComments,
+		Type:  TypeAll,
+	}
+}
+
+// NewClobUserSubscription creates a clob_user subscription with auth
+func NewClobUserSubscription(auth *Auth) Subscription {
+	return Subscription{
+		Topic: TopicClobUser,
+		Type:  TypeAll,
+		ClobAuth: &ClobAuth{
+			Key:        auth.APIKey,
+			Secret:     auth.Secret,
+			Passphrase: auth.Passphrase,
+		},
+	}
+}
+
+// NewCryptoPricesSubscription creates a subscription to Binance prices of
+// symbols (e.g. btcusdt), or of every symbol when none are given
+func NewCryptoPricesSubscription(symbols ...string) Subscription {
+	return Subscription{
+		Topic:   TopicCryptoPrices,
+		Type:    TypeUpdate,
+		Filters: strings.ToLower(strings.Join(symbols, ",")),
+	}
+}
+
+// NewChainlinkPricesSubscription creates a subscription to the Chainlink
+// price of symbol (e.g. btc/usd), or of every symbol when it is empty
+func NewChainlinkPricesSubscription(symbol string) Subscription {
+	sub := Subscription{Topic: TopicCryptoPricesChainlink, Type: TypeAll}
+	if symbol != "" {
+		filters, _ := json.Marshal(map[string]string{"symbol": strings.ToLower(symbol)})
+		sub.Filters = string(filters)
+	}
+	return sub
+}
+
+// NewRFQSubscription creates an rfq subscription for all request and quote
+// events
+func NewRFQSubscription() Subscription {
+	return Subscription{
+		Topic: TopicRFQ,
+		Type:  TypeAll,
+	}
+}
+
+// IsRFQRequest reports whether an rfq message type is a request event
+func IsRFQRequest(messageType string) bool {
+	switch messageType {
+	case TypeRequestCreated, TypeRequestEdited, TypeRequestCanceled, TypeRequestExpired:
+		return true
+	}
+	return false
+}
+
+// IsRFQQuote reports whether an rfq message type is a quote event
+func IsRFQQuote(messageType string) bool {
+	switch messageType {
+	case TypeQuoteCreated, TypeQuoteEdited, TypeQuoteCanceled, TypeQuoteExpired:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/FatwaArya/pm-ingest/config"
+	"github.com/FatwaArya/pm-ingest/internal/domain"
+	"github.com/FatwaArya/pm-ingest/internal/logging"
+)
+
+// reloadOnHangup reads the configuration (environment and CONFIG_FILE)
+// again on every SIGHUP and applies what can change at runtime: the log
+// level, the discovery rule and the whale alert channels. discovery may be
+// nil. Other changes are logged as needing a restart.
+func reloadOnHangup(ctx context.Context, discovery *domain.DiscoveryService) error {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	current := config.AppConfig
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-hup:
+		}
+		next, err := config.Reload()
+		if err != nil {
+			log.Printf("Configuration not reloaded: %v", err)
+			continue
+		}
+		applied, _ := next.Changes(current)
+		alerts := false
+		for _, field := range applied {
+			// The alert settings together make one notifier
+			if strings.HasPrefix(field, "Alert") {
+				if alerts {
+					continue
+				}
+				alerts = true
+			}
+			if err := applyReload(field, next, discovery); err != nil {
+				log.Printf("Error applying reloaded %s: %v", field, err)
+			}
+		}
+		current = next
+		if _, restart := next.Changes(config.AppConfig); len(restart) > 0 {
+			log.Printf("Configuration reloaded; changes to %s need a restart", strings.Join(restart, ", "))
+		} else {
+			log.Printf("Configuration reloaded")
+		}
+	}
+}
+
+// applyReload puts the new value of field into effect
+func applyReload(field string, cfg config.Config, discovery *domain.DiscoveryService) error {
+	switch {
+	case field == "LogLevel":
+		return logging.SetLevel(cfg.LogLevel)
+	case discovery == nil:
+		return nil
+	case field == "DiscoveryRule":
+		if cfg.DiscoveryRule == "" {
+			discovery.SetRule(nil)
+			return nil
+		}
+		rule, err := domain.CompileDiscoveryRule(cfg.DiscoveryRule, config.AppConfig.DiscoveryPriceWindow)
+		if err != nil {
+			return err
+		}
+		log.Printf("Discovering trades matching %s", rule)
+		discovery.SetRule(rule)
+	case strings.HasPrefix(field, "Alert"):
+		notifier, err := newNotifier(cfg)
+		if err != nil {
+			return err
+		}
+		notifier.LogTargets()
+		if notifier.Empty() {
+			notifier = nil
+		}
+		discovery.SetNotifier(notifier)
+	}
+	return nil
+}
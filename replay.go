@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/FatwaArya/pm-ingest/config"
+	"github.com/FatwaArya/pm-ingest/internal/archive"
+	"github.com/FatwaArya/pm-ingest/internal/health"
+	internalkafka "github.com/FatwaArya/pm-ingest/internal/kafka"
+	"github.com/FatwaArya/pm-ingest/internal/logging"
+	"github.com/FatwaArya/pm-ingest/internal/pipeline"
+	"github.com/FatwaArya/pm-ingest/internal/replay"
+	"github.com/FatwaArya/pm-ingest/internal/sink"
+	"github.com/FatwaArya/pm-ingest/internal/store"
+	"github.com/FatwaArya/pm-ingest/internal/wal"
+)
+
+// runReplay runs `pm-ingest replay`: it feeds captured WebSocket messages
+// through the ingest pipeline (middleware and sinks) again, from archive
+// files, the archive bucket or a Kafka topic
+func runReplay(args []string) error {
+	cfg := config.AppConfig
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	files := fs.String("files", "", "comma-separated archive files or directories (.jsonl or .jsonl.gz)")
+	prefix := fs.String("archive-prefix", "", "replay the files under this key prefix of ARCHIVE_BUCKET, e.g. raw/dt=2025-03-01/")
+	topic := fs.String("topic", "", "replay a Kafka topic of messages or trades from its start")
+	speed := fs.Float64("speed", 0, "pace: 0 for full speed, 1 for the original pace, 2 for twice as fast...")
+	sinks := fs.String("sinks", strings.Join(cfg.Sinks, ","), "comma-separated sinks to write to")
+	fs.Parse(args)
+
+	var src replay.Source
+	sources := 0
+	if *files != "" {
+		src = replay.Files(strings.Split(*files, ",")...)
+		sources++
+	}
+	if *prefix != "" {
+		store, err := archiveStore()
+		if err != nil {
+			return err
+		}
+		s3, ok := store.(*archive.S3Store)
+		if !ok {
+			return errors.New("-archive-prefix needs ARCHIVE_BUCKET")
+		}
+		src = replay.Bucket(s3, *prefix)
+		sources++
+	}
+	if *topic != "" {
+		src = kafkaReplaySource(*topic)
+		sources++
+	}
+	if sources != 1 {
+		return errors.New("replay needs one of -files, -archive-prefix or -topic")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// The same middleware and sinks as the live feed
+	sinkNames := strings.Split(*sinks, ",")
+	lifecycle := health.NewLifecycle()
+	var (
+		producer *internalkafka.Producer
+		tradeWAL *wal.WAL
+		err      error
+	)
+	for _, name := range sinkNames {
+		if name == sink.NameKafka {
+			if producer, tradeWAL, err = newKafkaProducer(lifecycle, internalkafka.WithSource(internalkafka.SourceReplay)); err != nil {
+				return err
+			}
+		}
+	}
+	out, err := buildSinks(ctx, sinkNames, producer, tradeWAL, nil, lifecycle)
+	if err != nil {
+		return fmt.Errorf("failed to create sinks: %w", err)
+	}
+
+	var sharedStore store.Store = store.NewMemoryStore()
+	if cfg.RedisURL != "" {
+		if sharedStore, err = store.NewRedisStore(ctx, cfg.RedisURL, cfg.RedisPrefix); err != nil {
+			return fmt.Errorf("failed to create redis store: %w", err)
+		}
+	}
+	defer sharedStore.Close()
+	lists, err := newIngestLists()
+	if err != nil {
+		return fmt.Errorf("invalid ingest lists: %w", err)
+	}
+	replayLog := logging.For("replay")
+	errLog := logging.NewRateLimited(replayLog, 5*time.Second)
+	middleware, err := buildMiddleware(cfg.PipelineStages, sharedStore, lists, errLog)
+	if err != nil {
+		return fmt.Errorf("invalid pipeline: %w", err)
+	}
+	ingest := pipeline.NewIngest(middleware, out.WriteTrade, errLog, errLog)
+	ingest.Start(ctx)
+
+	start := time.Now()
+	stats, err := replay.New(*speed).Run(ctx, src, func(ctx context.Context, message []byte) error {
+		return ingest.Submit(ctx, message)
+	})
+
+	// Drain the pipeline and deliver what's buffered, even when interrupted
+	ingest.Close()
+	flushCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if flushErr := out.Flush(flushCtx); flushErr != nil {
+		replayLog.ErrorContext(ctx, "Error flushing replayed trades", "error", flushErr)
+	}
+	if closeErr := out.Close(flushCtx); closeErr != nil {
+		replayLog.ErrorContext(ctx, "Error closing sinks", "error", closeErr)
+	}
+
+	replayLog.InfoContext(ctx, "Replay finished", "messages", stats.Messages, "elapsed", time.Since(start).Round(time.Millisecond))
+	if !stats.First.IsZero() {
+		replayLog.InfoContext(ctx, "Replayed message range", "first", stats.First.UTC(), "last", stats.Last.UTC())
+	}
+	for _, s := range ingest.Stats() {
+		replayLog.InfoContext(ctx, "Stage stats", "stage", s.Name, "in", s.In, "out", s.Out, "filtered", s.Filtered, "errors", s.Errors)
+	}
+	return err
+}
+
+// kafkaReplaySource reads topic from its start. Records of the trades topic
+// are wrapped in the activity message they came in.
+func kafkaReplaySource(topic string) replay.Source {
+	return func(ctx context.Context, emit func(replay.Message) error) error {
+		_, err := internalkafka.ReadTopic(ctx, strings.TrimSpace(config.AppConfig.KafkaBrokers), topic,
+			func(ctx context.Context, value []byte, at time.Time) error {
+				return emit(replay.Message{At: at, Data: replay.TradeMessage(value, at)})
+			})
+		return err
+	}
+}
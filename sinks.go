@@ -0,0 +1,432 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/FatwaArya/pm-ingest/config"
+	internalqdb "github.com/FatwaArya/pm-ingest/internal"
+	"github.com/FatwaArya/pm-ingest/internal/archive"
+	"github.com/FatwaArya/pm-ingest/internal/clickhouse"
+	"github.com/FatwaArya/pm-ingest/internal/domain"
+	"github.com/FatwaArya/pm-ingest/internal/events"
+	"github.com/FatwaArya/pm-ingest/internal/health"
+	internalkafka "github.com/FatwaArya/pm-ingest/internal/kafka"
+	"github.com/FatwaArya/pm-ingest/internal/postgres"
+	"github.com/FatwaArya/pm-ingest/internal/schema"
+	"github.com/FatwaArya/pm-ingest/internal/sink"
+	"github.com/FatwaArya/pm-ingest/internal/wal"
+	"github.com/FatwaArya/pm-ingest/pkg/rtds"
+	"github.com/jackc/pgx/v5"
+)
+
+// kafkaSecurity returns the configured client ID, TLS and SASL settings
+func kafkaSecurity() internalkafka.Security {
+	cfg := config.AppConfig
+	return internalkafka.Security{
+		ClientID:              cfg.KafkaClientID,
+		TLS:                   cfg.KafkaTLSEnabled,
+		TLSCAFile:             cfg.KafkaTLSCAFile,
+		TLSCertFile:           cfg.KafkaTLSCertFile,
+		TLSKeyFile:            cfg.KafkaTLSKeyFile,
+		TLSInsecureSkipVerify: cfg.KafkaTLSInsecureSkipVerify,
+		SASLMechanism:         cfg.KafkaSASLMechanism,
+		SASLUsername:          cfg.KafkaSASLUsername,
+		SASLPassword:          cfg.KafkaSASLPassword,
+		SASLToken:             cfg.KafkaSASLOAuthToken,
+	}
+}
+
+// kafkaProducerSettings returns the configured batching and delivery
+// settings, for every producer
+func kafkaProducerSettings() internalkafka.ProducerOption {
+	cfg := config.AppConfig
+	return internalkafka.WithSettings(internalkafka.ProducerSettings{
+		DisableIdempotence: !cfg.KafkaIdempotent,
+		Compression:        cfg.KafkaCompression,
+		Linger:             cfg.KafkaLinger,
+		BatchMaxBytes:      int32(cfg.KafkaBatchMaxBytes),
+		MaxInFlight:        cfg.KafkaMaxInFlight,
+		Retries:            cfg.KafkaRetries,
+		PartitionBy:        internalkafka.PartitionBy(cfg.KafkaPartitionBy),
+	})
+}
+
+// configureSchemas registers the record schemas when SCHEMA_FORMAT is avro
+// or protobuf, and has the Kafka values encoded with them
+func configureSchemas() error {
+	cfg := config.AppConfig
+	if cfg.SchemaFormat == schema.FormatJSON {
+		return nil
+	}
+	registry := schema.NewRegistry(cfg.SchemaRegistryURL, cfg.SchemaRegistryUsername, cfg.SchemaRegistryPassword)
+	serializer, err := schema.NewSerializer(cfg.SchemaFormat, registry)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := serializer.Register(ctx, internalkafka.TradeSchema, domain.ConfidenceSchema, events.EventSchema); err != nil {
+		return err
+	}
+	internalkafka.UseSerializer(serializer)
+	log.Printf("Kafka values are encoded as %s, schemas registered at %s", cfg.SchemaFormat, cfg.SchemaRegistryURL)
+	return nil
+}
+
+// newKafkaProducer creates the trades producer with its readiness check,
+// tracking Kafka health and spilling undeliverable trades to the local WAL
+func newKafkaProducer(lifecycle *health.Lifecycle, options ...internalkafka.ProducerOption) (*internalkafka.Producer, *wal.WAL, error) {
+	cfg := config.AppConfig
+	options = append([]internalkafka.ProducerOption{kafkaProducerSettings()}, options...)
+	producer, err := internalkafka.NewProducer(strings.TrimSpace(cfg.KafkaBrokers), cfg.KafkaTopic, options...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create kafka producer: %w", err)
+	}
+	lifecycle.AddCheck("kafka", producer.Ping)
+
+	tradeWAL, err := wal.Open(cfg.WALPath)
+	if err != nil {
+		producer.Close()
+		return nil, nil, fmt.Errorf("failed to open WAL: %w", err)
+	}
+	producer.EnableFallback(lifecycle.TrackSink("kafka", cfg.SinkFailureThreshold, cfg.SinkRetryInterval), tradeWAL)
+	return producer, tradeWAL, nil
+}
+
+// questdbPort parses the configured QuestDB ILP port
+func questdbPort() (int, error) {
+	port, err := strconv.Atoi(config.AppConfig.QuestDBILPPort)
+	if err != nil {
+		return 0, fmt.Errorf("invalid QuestDB ILP port %q", config.AppConfig.QuestDBILPPort)
+	}
+	return port, nil
+}
+
+// questdbTable applies the configured prefix and partitioning to a table
+func questdbTable(name string) internalqdb.WriterOption {
+	cfg := config.AppConfig
+	return internalqdb.WithTable(internalqdb.TableConfig{
+		Name:        cfg.QuestDBTablePrefix + name,
+		PartitionBy: cfg.QuestDBPartitionBy,
+		SchemaAddr:  cfg.QuestDBSchemaAddr(),
+	})
+}
+
+// newQuestDBRelay creates the relay writing trades from the Kafka topic to
+// QuestDB, used instead of the questdb sink when QUESTDB_FROM_KAFKA is set
+func newQuestDBRelay(ctx context.Context, lifecycle *health.Lifecycle) (*sink.QuestDBRelay, error) {
+	cfg := config.AppConfig
+	port, err := strconv.Atoi(cfg.QuestDBHTTPPort)
+	if err != nil {
+		return nil, fmt.Errorf("invalid QuestDB HTTP port %q", cfg.QuestDBHTTPPort)
+	}
+	table := internalqdb.TableConfig{
+		Name:        cfg.QuestDBTablePrefix + cfg.QuestDBTradesTable,
+		PartitionBy: cfg.QuestDBPartitionBy,
+		SchemaAddr:  cfg.QuestDBSchemaAddr(),
+		Symbols:     cfg.QuestDBTradeSymbols,
+	}
+	return sink.NewQuestDBRelay(ctx, strings.TrimSpace(cfg.KafkaBrokers), cfg.KafkaTopic, cfg.QuestDBRelayGroup,
+		cfg.QuestDBHost, port, table, lifecycle.TrackSink("questdb", cfg.SinkFailureThreshold, cfg.SinkRetryInterval))
+}
+
+// newActivityWriter connects the wallet activity writer to QuestDB
+func newActivityWriter(ctx context.Context) (*internalqdb.ActivityWriter, error) {
+	port, err := questdbPort()
+	if err != nil {
+		return nil, err
+	}
+	return internalqdb.NewActivityWriter(ctx, config.AppConfig.QuestDBHost, port, questdbTable("wallet_activity"))
+}
+
+// newPositionWriter connects the wallet position writer to QuestDB
+func newPositionWriter(ctx context.Context) (*internalqdb.PositionWriter, error) {
+	port, err := questdbPort()
+	if err != nil {
+		return nil, err
+	}
+	return internalqdb.NewPositionWriter(ctx, config.AppConfig.QuestDBHost, port, questdbTable(config.AppConfig.QuestDBPositionsTable))
+}
+
+// newResolutionWriter connects the market resolution writer to QuestDB
+func newResolutionWriter(ctx context.Context) (*internalqdb.ResolutionWriter, error) {
+	port, err := questdbPort()
+	if err != nil {
+		return nil, err
+	}
+	return internalqdb.NewResolutionWriter(ctx, config.AppConfig.QuestDBHost, port, questdbTable(config.AppConfig.QuestDBResolutionsTable))
+}
+
+// writeResolution returns an OnResolution hook keeping each resolution in
+// the market resolutions table
+func writeResolution(w *internalqdb.ResolutionWriter) func(ctx context.Context, r domain.MarketResolution) {
+	return func(ctx context.Context, r domain.MarketResolution) {
+		err := w.Write(ctx, &internalqdb.MarketResolution{
+			ConditionID:    r.ConditionID,
+			Slug:           r.Slug,
+			WinningOutcome: r.WinningOutcome,
+			WinningAsset:   r.WinningAsset,
+			WinningIndex:   r.WinningIndex,
+			ResolvedAt:     time.Unix(r.ResolvedAt, 0),
+		})
+		if err != nil {
+			log.Printf("Error writing resolution of %s: %v", r.ConditionID, err)
+		}
+	}
+}
+
+// newPriceBarWriter connects the price bar writer to QuestDB
+func newPriceBarWriter(ctx context.Context) (*internalqdb.PriceBarWriter, error) {
+	port, err := questdbPort()
+	if err != nil {
+		return nil, err
+	}
+	return internalqdb.NewPriceBarWriter(ctx, config.AppConfig.QuestDBHost, port, questdbTable("asset_prices"))
+}
+
+// newConfidenceWriter connects a confidence writer for table to QuestDB
+func newConfidenceWriter(ctx context.Context, table string) (*internalqdb.ConfidenceWriter, error) {
+	port, err := questdbPort()
+	if err != nil {
+		return nil, err
+	}
+	return internalqdb.NewConfidenceWriter(ctx, config.AppConfig.QuestDBHost, port, questdbTable(table))
+}
+
+// confidenceScore converts a score to its history row
+func confidenceScore(score domain.ScoredConfidence) *internalqdb.ConfidenceScore {
+	p := score.Prediction
+	return &internalqdb.ConfidenceScore{
+		Wallet:             score.Wallet,
+		BrierScore:         p.BrierScore,
+		Calibration:        p.Calibration,
+		WinRate:            p.WinRate,
+		ConfidenceInterval: p.ConfidenceInterval,
+		SampleSize:         p.SampleSize,
+		AvgRealizedPnl:     p.AvgRealizedPnl,
+		TotalRealizedPnl:   p.TotalRealizedPnl,
+		PnlVolatility:      p.PnlVolatility,
+		SharpeRatio:        p.SharpeRatio,
+		MaxDrawdown:        p.MaxDrawdown,
+		ProfitFactor:       p.ProfitFactor,
+		NoLosses:           p.NoLosses,
+		ROI:                p.ROI,
+		ComputedAt:         score.ComputedAt,
+	}
+}
+
+// writeConfidence returns an OnScore hook keeping each score in the
+// confidence history table
+func writeConfidence(w *internalqdb.ConfidenceWriter) func(ctx context.Context, score domain.ScoredConfidence) {
+	return func(ctx context.Context, score domain.ScoredConfidence) {
+		if err := w.Write(ctx, confidenceScore(score)); err != nil {
+			log.Printf("Error writing confidence for %s: %v", score.Wallet, err)
+		}
+	}
+}
+
+// newClickHouseClient returns a client for the configured ClickHouse server
+func newClickHouseClient() *clickhouse.Client {
+	cfg := config.AppConfig
+	return clickhouse.NewClient(clickhouse.Config{
+		URL:         cfg.ClickHouseURL,
+		Database:    cfg.ClickHouseDatabase,
+		User:        cfg.ClickHouseUser,
+		Password:    cfg.ClickHousePassword,
+		AsyncInsert: cfg.ClickHouseAsyncInsert,
+	})
+}
+
+// newClickHouseSink creates the tables the ClickHouse sink writes trades,
+// profiles and confidence scores to
+func newClickHouseSink(ctx context.Context, lifecycle *health.Lifecycle) (*sink.ClickHouseSink, error) {
+	cfg := config.AppConfig
+	client := newClickHouseClient()
+	tables := clickhouse.Tables{
+		Trades:     cfg.ClickHouseTradesTable,
+		Profiles:   cfg.ClickHouseProfilesTable,
+		Confidence: cfg.ClickHouseConfidenceTable,
+	}
+	return sink.NewClickHouseSink(ctx, client, tables, cfg.ClickHouseBatchSize, cfg.ClickHouseFlushInterval,
+		lifecycle.TrackSink("clickhouse", cfg.SinkFailureThreshold, cfg.SinkRetryInterval))
+}
+
+// archiveStore returns the configured bucket for the archive, or nil to
+// keep it on disk
+func archiveStore() (archive.Store, error) {
+	cfg := config.AppConfig
+	if cfg.ArchiveBucket == "" {
+		return nil, nil
+	}
+	return archive.NewS3Store(archive.S3Config{
+		Endpoint:  cfg.ArchiveEndpoint,
+		Bucket:    cfg.ArchiveBucket,
+		Region:    cfg.ArchiveRegion,
+		AccessKey: cfg.ArchiveAccessKey,
+		SecretKey: cfg.ArchiveSecretKey,
+		Insecure:  cfg.ArchiveInsecure,
+	})
+}
+
+// rawArchiver returns the archive sink's archiver when raw messages are
+// archived, or nil
+func rawArchiver(sinks *sink.Fanout) *archive.Archiver {
+	if !config.AppConfig.ArchiveRaw {
+		return nil
+	}
+	for _, s := range sinks.Sinks() {
+		if as, ok := sink.Underlying(s).(*sink.ArchiveSink); ok {
+			return as.Archiver()
+		}
+	}
+	return nil
+}
+
+// postgresAddr returns the host:port of the configured Postgres server
+func postgresAddr() (string, error) {
+	pc, err := pgx.ParseConfig(config.AppConfig.PostgresURL)
+	if err != nil {
+		return "", err
+	}
+	return net.JoinHostPort(pc.Host, strconv.Itoa(int(pc.Port))), nil
+}
+
+// clickHouseWriter returns the ClickHouse sink's writer, or nil without
+// that sink
+func clickHouseWriter(sinks *sink.Fanout) *clickhouse.Writer {
+	for _, s := range sinks.Sinks() {
+		if cs, ok := sink.Underlying(s).(*sink.ClickHouseSink); ok {
+			return cs.Writer()
+		}
+	}
+	return nil
+}
+
+// writeClickHouseConfidence returns an OnScore hook copying each score to
+// ClickHouse
+func writeClickHouseConfidence(w *clickhouse.Writer) func(ctx context.Context, score domain.ScoredConfidence) {
+	return func(ctx context.Context, score domain.ScoredConfidence) {
+		if err := w.WriteConfidence(confidenceScore(score)); err != nil {
+			log.Printf("Error writing confidence for %s to ClickHouse: %v", score.Wallet, err)
+		}
+	}
+}
+
+// publishConfidence returns an OnResult hook publishing each confidence
+// result to topic, keyed by wallet
+func publishConfidence(producer *internalkafka.Producer, topic string) func(ctx context.Context, result domain.ConfidenceResult) {
+	return func(ctx context.Context, result domain.ConfidenceResult) {
+		value, err := json.Marshal(result)
+		if err != nil {
+			log.Printf("Error encoding confidence for %s: %v", result.UserAddress, err)
+			return
+		}
+		producer.Produce(ctx, topic, []byte(strings.ToLower(result.UserAddress)), value)
+	}
+}
+
+// categoryRouter routes trades of the listed categories to prefix+category.
+// Trades of markets whose metadata isn't in the catalog yet aren't routed.
+func categoryRouter(catalog *domain.MarketCatalog, categories []string, prefix string) sink.TopicRouter {
+	topics := make(map[string]string, len(categories))
+	for _, c := range categories {
+		slug := domain.CategorySlug(c)
+		topics[slug] = prefix + slug
+	}
+	return func(trade *rtds.ActivityTradePayload) []string {
+		if topic, ok := topics[catalog.Category(trade.ConditionID)]; ok {
+			return []string{topic}
+		}
+		return nil
+	}
+}
+
+// buildSinks creates the named sinks once their dependencies are up. The
+// Kafka sink takes ownership of producer and tradeWAL, and copies trades to
+// the topics chosen by router (which may be nil).
+func buildSinks(ctx context.Context, names []string, producer *internalkafka.Producer, tradeWAL *wal.WAL, router sink.TopicRouter, lifecycle *health.Lifecycle) (*sink.Fanout, error) {
+	cfg := config.AppConfig
+	var sinks []sink.Sink
+	for _, name := range names {
+		var s sink.Sink
+		switch name {
+		case sink.NameKafka:
+			ks := sink.NewKafkaSink(producer, tradeWAL)
+			ks.SetRouter(router)
+			s = ks
+		case sink.NameQuestDB:
+			port, err := questdbPort()
+			if err != nil {
+				return nil, err
+			}
+			tables := sink.QuestDBTables{
+				Trades: internalqdb.TableConfig{
+					Name:        cfg.QuestDBTablePrefix + cfg.QuestDBTradesTable,
+					PartitionBy: cfg.QuestDBPartitionBy,
+					SchemaAddr:  cfg.QuestDBSchemaAddr(),
+					Symbols:     cfg.QuestDBTradeSymbols,
+				},
+				Profiles: internalqdb.TableConfig{
+					Name:        cfg.QuestDBTablePrefix + cfg.QuestDBProfilesTable,
+					PartitionBy: cfg.QuestDBPartitionBy,
+					SchemaAddr:  cfg.QuestDBSchemaAddr(),
+					Symbols:     cfg.QuestDBProfileSymbols,
+				},
+			}
+			qs, err := sink.NewQuestDBSink(ctx, cfg.QuestDBHost, port, tables,
+				lifecycle.TrackSink("questdb", cfg.SinkFailureThreshold, cfg.SinkRetryInterval), nil)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create questdb sink: %w", err)
+			}
+			s = qs
+		case sink.NameClickHouse:
+			cs, err := newClickHouseSink(ctx, lifecycle)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create clickhouse sink: %w", err)
+			}
+			s = cs
+		case sink.NamePostgres:
+			tables := postgres.Tables{Trades: cfg.PostgresTradesTable, Profiles: cfg.PostgresProfilesTable}
+			ps, err := sink.NewPostgresSink(ctx, cfg.PostgresURL, tables, cfg.PostgresTimescale,
+				cfg.PostgresBatchSize, cfg.PostgresFlushInterval,
+				lifecycle.TrackSink("postgres", cfg.SinkFailureThreshold, cfg.SinkRetryInterval))
+			if err != nil {
+				return nil, fmt.Errorf("failed to create postgres sink: %w", err)
+			}
+			s = ps
+		case sink.NameArchive:
+			store, err := archiveStore()
+			if err != nil {
+				return nil, err
+			}
+			as, err := sink.NewArchiveSink(cfg.ArchiveDir, cfg.ArchivePrefix, store, cfg.ArchiveUploadInterval,
+				lifecycle.TrackSink("archive", cfg.SinkFailureThreshold, cfg.SinkRetryInterval))
+			if err != nil {
+				return nil, fmt.Errorf("failed to create archive sink: %w", err)
+			}
+			s = as
+		case sink.NameStdout:
+			s = sink.NewStdoutSink(os.Stdout)
+		case sink.NameFile:
+			fs, err := sink.NewFileSink(cfg.SinkFilePath)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create file sink: %w", err)
+			}
+			s = fs
+		case sink.NameNone:
+			s = sink.NoopSink{}
+		default:
+			return nil, fmt.Errorf("unknown sink %q", name)
+		}
+		sinks = append(sinks, s)
+	}
+	return sink.NewFanout(sinks...), nil
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/FatwaArya/pm-ingest/config"
+	"github.com/FatwaArya/pm-ingest/internal/domain"
+	"github.com/FatwaArya/pm-ingest/internal/tape"
+)
+
+// runTape runs the `pm-ingest tape` terminal UI on the configured trades
+// topic instead of the ingester
+func runTape(args []string) error {
+	fs := flag.NewFlagSet("tape", flag.ExitOnError)
+	cfg := tape.Config{
+		Brokers: config.AppConfig.KafkaBrokers,
+		Topic:   config.AppConfig.KafkaTopic,
+	}
+	fs.StringVar(&cfg.APIURL, "api", "http://localhost:"+config.AppConfig.AppPort, "ingester HTTP API, for pipeline health")
+	fs.Float64Var(&cfg.WhaleNotional, "whale", domain.MinimumTradeSize, "highlight trades of at least this USD notional")
+	fs.Parse(args)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return tape.Run(ctx, cfg)
+}